@@ -0,0 +1,206 @@
+package jshapi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-stdlogger"
+)
+
+/*
+Logger is the interface used by AccessLog, see StdLogger and SlogLogger for
+adapters of the standard library loggers.
+*/
+type Logger interface {
+	// Printf logs a formatted message
+	Printf(format string, v ...interface{})
+	// Log logs a structured entry
+	Log(fields map[string]interface{})
+}
+
+/*
+AccessLog returns a middleware logging a single structured entry per request with
+the following fields:
+
+	method      the effective request method
+	path        the matched route pattern, such as /users/:id
+	resource    the resource type, when a resource route matched
+	operation   the resource Operation, when a resource route matched
+	status      the response status
+	bytes       the number of response body bytes written
+	duration    the time spent handling the request, as a time.Duration
+	request_id  the request id, when the RequestID middleware runs before it
+	outcome     "client_gone" when the client went away before the response was
+	            sent, the status is then StatusClientClosedRequest
+
+Route patterns are logged instead of raw paths to keep the cardinality low.
+*/
+func AccessLog(logger Logger) func(goji.Handler) goji.Handler {
+	return func(next goji.Handler) goji.Handler {
+		return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			ctx, info := withRouteInfo(ctx)
+			if info.apiPattern == "" {
+				// the API only starts route reporting when tracing or metrics need it
+				info.recordAPIPattern(ctx)
+			}
+
+			writer, recorder := wrapAccessWriter(w)
+			start := time.Now()
+
+			next.ServeHTTPC(ctx, writer, r)
+
+			fields := map[string]interface{}{
+				"method":   r.Method,
+				"path":     info.routePattern(),
+				"status":   info.reportedStatus(recorder.status),
+				"bytes":    recorder.bytes,
+				"duration": time.Since(start),
+			}
+
+			if info.resourceType != "" {
+				fields["resource"] = info.resourceType
+				fields["operation"] = string(info.op)
+			}
+
+			requestID := GetRequestID(ctx)
+			if requestID != "" {
+				fields["request_id"] = requestID
+			}
+
+			if info.clientGone {
+				fields["outcome"] = "client_gone"
+			}
+
+			logger.Log(fields)
+		})
+	}
+}
+
+// accessWriter records the status and size of a response, optionally copying the
+// body of responses to tee, as decided by onHeader
+type accessWriter struct {
+	http.ResponseWriter
+	status   int
+	bytes    int
+	onHeader func(status int) io.Writer
+	tee      io.Writer
+}
+
+func (w *accessWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.recordStatus(status)
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessWriter) Write(content []byte) (int, error) {
+	if w.status == 0 {
+		w.recordStatus(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(content)
+	w.bytes += n
+
+	if w.tee != nil {
+		w.tee.Write(content[:n])
+	}
+
+	return n, err
+}
+
+func (w *accessWriter) recordStatus(status int) {
+	w.status = status
+
+	if w.onHeader != nil {
+		w.tee = w.onHeader(status)
+	}
+}
+
+// the following wrappers preserve the optional interfaces of the wrapped writer so
+// that streaming and connection hijacking keep working
+
+type flushAccessWriter struct {
+	*accessWriter
+}
+
+func (w flushAccessWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+type hijackAccessWriter struct {
+	*accessWriter
+}
+
+func (w hijackAccessWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type flushHijackAccessWriter struct {
+	*accessWriter
+}
+
+func (w flushHijackAccessWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w flushHijackAccessWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// wrapAccessWriter wraps w in the accessWriter variant matching its optional
+// interfaces, returning the writer to use and its recorder
+func wrapAccessWriter(w http.ResponseWriter) (http.ResponseWriter, *accessWriter) {
+	recorder := &accessWriter{ResponseWriter: w}
+
+	_, canFlush := w.(http.Flusher)
+	_, canHijack := w.(http.Hijacker)
+
+	switch {
+	case canFlush && canHijack:
+		return flushHijackAccessWriter{recorder}, recorder
+	case canFlush:
+		return flushAccessWriter{recorder}, recorder
+	case canHijack:
+		return hijackAccessWriter{recorder}, recorder
+	default:
+		return recorder, recorder
+	}
+}
+
+/*
+StdLogger adapts a standard library style logger, such as *log.Logger, to the
+Logger interface. Structured entries are logged as space separated key=value
+pairs, sorted by key.
+*/
+func StdLogger(logger std.Logger) Logger {
+	return &stdLogger{logger}
+}
+
+type stdLogger struct {
+	std.Logger
+}
+
+func (l *stdLogger) Log(fields map[string]interface{}) {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, fields[key]))
+	}
+
+	l.Printf("%s", strings.Join(pairs, " "))
+}
@@ -0,0 +1,42 @@
+//go:build go1.21
+// +build go1.21
+
+package jshapi
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+/*
+SlogLogger adapts a *slog.Logger to the Logger interface. Structured entries are
+logged at the Info level with one attribute per field.
+*/
+func SlogLogger(logger *slog.Logger) Logger {
+	return &slogLogger{logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l *slogLogger) Printf(format string, v ...interface{}) {
+	l.logger.Info(fmt.Sprintf(format, v...))
+}
+
+func (l *slogLogger) Log(fields map[string]interface{}) {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, key := range keys {
+		attrs = append(attrs, slog.Any(key, fields[key]))
+	}
+
+	l.logger.LogAttrs(context.Background(), slog.LevelInfo, "request", attrs...)
+}
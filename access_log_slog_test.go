@@ -0,0 +1,26 @@
+//go:build go1.21
+// +build go1.21
+
+package jshapi
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSlogLogger(t *testing.T) {
+
+	Convey("->SlogLogger()", t, func() {
+
+		Convey("should log fields as attributes", func() {
+			buffer := &bytes.Buffer{}
+			logger := SlogLogger(slog.New(slog.NewTextHandler(buffer, nil)))
+
+			logger.Log(map[string]interface{}{"status": 200, "method": "GET"})
+			So(buffer.String(), ShouldContainSubstring, "msg=request method=GET status=200")
+		})
+	})
+}
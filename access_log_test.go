@@ -0,0 +1,84 @@
+package jshapi
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recordLogger keeps the structured entries it receives
+type recordLogger struct {
+	entries []map[string]interface{}
+}
+
+func (l *recordLogger) Printf(format string, v ...interface{}) {}
+
+func (l *recordLogger) Log(fields map[string]interface{}) {
+	l.entries = append(l.entries, fields)
+}
+
+func TestAccessLog(t *testing.T) {
+
+	Convey("Access Log Tests", t, func() {
+
+		logger := &recordLogger{}
+
+		api := New("api")
+		api.UseC(RequestID())
+		api.UseC(AccessLog(logger))
+		api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/api/" + testResourceType
+
+		Convey("should log route patterns instead of raw paths", func() {
+			resp, content := negotiationRequest("GET", url+"/1", "", "", "")
+			So(logger.entries, ShouldHaveLength, 1)
+
+			entry := logger.entries[0]
+			So(entry["method"], ShouldEqual, "GET")
+			So(entry["path"], ShouldEqual, "/api/bars/:id")
+			So(entry["resource"], ShouldEqual, testResourceType)
+			So(entry["operation"], ShouldEqual, "read")
+			So(entry["status"], ShouldEqual, http.StatusOK)
+			So(entry["bytes"], ShouldEqual, len(content))
+			So(entry["request_id"], ShouldEqual, resp.Header.Get(RequestIDHeader))
+			So(entry["duration"], ShouldNotBeNil)
+		})
+
+		Convey("should fall back to the API pattern for unmatched routes", func() {
+			negotiationRequest("GET", url+"/1/unknown", "", "", "")
+			So(logger.entries, ShouldHaveLength, 1)
+			So(logger.entries[0]["path"], ShouldEqual, "/api/bars/*")
+			So(logger.entries[0]["status"], ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("->wrapAccessWriter()", func() {
+
+			Convey("should preserve optional writer interfaces", func() {
+				writer, _ := wrapAccessWriter(httptest.NewRecorder())
+
+				_, canFlush := writer.(http.Flusher)
+				So(canFlush, ShouldBeTrue)
+
+				_, canHijack := writer.(http.Hijacker)
+				So(canHijack, ShouldBeFalse)
+			})
+		})
+
+		Convey("->StdLogger()", func() {
+
+			Convey("should log sorted key=value pairs", func() {
+				buffer := &bytes.Buffer{}
+				StdLogger(log.New(buffer, "", 0)).Log(map[string]interface{}{"status": 200, "method": "GET"})
+				So(buffer.String(), ShouldEqual, "method=GET status=200\n")
+			})
+		})
+	})
+}
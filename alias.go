@@ -0,0 +1,113 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"goji.io"
+	"goji.io/pat"
+	"golang.org/x/net/context"
+)
+
+// resourceAlias is an alternate type segment serving the routes of a resource
+type resourceAlias struct {
+	name       string
+	deprecated bool
+}
+
+/*
+Alias serves all the routes of the resource, CRUD, relationships, actions and
+custom routes alike, under the alternate type segment altType as well, such as
+`/organisations` for the "organizations" resource. Aliases mount the resource
+itself a second time, they register no route of their own. Routes are listed by
+API.Routes under both patterns, those of the alias with AliasOf set. Responses,
+such as Location headers, keep using the canonical type.
+*/
+func (res *Resource) Alias(altType string) {
+	defer res.record(func(clone *Resource) { clone.Alias(altType) })()
+
+	res.addAlias(resourceAlias{name: altType})
+}
+
+/*
+DeprecatedAlias is an Alias answering with a `Deprecation: true` header, and a Link
+header pointing at the canonical URL of the request, so that clients can migrate
+during a deprecation window.
+*/
+func (res *Resource) DeprecatedAlias(altType string) {
+	defer res.record(func(clone *Resource) { clone.DeprecatedAlias(altType) })()
+
+	res.addAlias(resourceAlias{name: altType, deprecated: true})
+}
+
+// addAlias records an alias, mounting it right away if the resource is already
+// added to an API
+func (res *Resource) addAlias(alias resourceAlias) {
+	res.checkRegistration(fmt.Sprintf("alias '%s'", alias.name))
+
+	if alias.name == res.CollectionPath() {
+		panic(fmt.Sprintf("jshapi: unable to alias resource '%s' to itself", res.Type))
+	}
+	for _, existing := range res.aliases {
+		if existing.name == alias.name {
+			panic(fmt.Sprintf("jshapi: alias '%s' is already registered on resource '%s'", alias.name, res.Type))
+		}
+	}
+
+	res.aliases = append(res.aliases, alias)
+
+	if res.api != nil {
+		res.api.checkRegistration(fmt.Sprintf("alias '%s'", alias.name))
+		res.api.checkConflicts(res)
+		res.api.mountAlias(res, alias)
+	}
+}
+
+// mountAlias routes the alias paths of a resource, as API.Add does for the
+// canonical ones
+func (a *API) mountAlias(resource *Resource, alias resourceAlias) {
+	var handler goji.Handler = resource
+	if alias.deprecated {
+		handler = deprecatedAliasHandler{resource: resource, alias: alias.name}
+	}
+
+	// /(prefix/)(resource prefix/)alias and /(prefix/)(resource prefix/)alias/*
+	matcher := resource.basePath(alias.name)
+	a.Mux.HandleC(pat.New(matcher), handler)
+	a.Mux.HandleC(pat.New(path.Join(matcher, "*")), handler)
+}
+
+// deprecatedAliasHandler flags the responses of a deprecated alias
+type deprecatedAliasHandler struct {
+	resource *Resource
+	alias    string
+}
+
+func (h deprecatedAliasHandler) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	successor := h.resource.linkBase(r) + h.resource.basePath(h.resource.CollectionPath()) +
+		strings.TrimPrefix(r.URL.EscapedPath(), h.resource.basePath(h.alias))
+
+	w.Header().Set("Deprecation", "true")
+	w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+
+	h.resource.ServeHTTPC(ctx, w, r)
+}
+
+// aliasRoutes lists the routes of the resource under each of its aliases
+func (res *Resource) aliasRoutes(routes []Route) []Route {
+	aliased := []Route{}
+
+	for _, alias := range res.aliases {
+		for _, route := range routes {
+			aliasRoute := route
+			aliasRoute.Pattern = res.basePath(alias.name) + strings.TrimPrefix(route.Pattern, res.basePath(res.CollectionPath()))
+			aliasRoute.AliasOf = route.Pattern
+			aliasRoute.Name = ""
+			aliased = append(aliased, aliasRoute)
+		}
+	}
+
+	return aliased
+}
@@ -0,0 +1,100 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAlias(t *testing.T) {
+
+	Convey("Alias Tests", t, func() {
+
+		storage := &MockStorage{ResourceType: "organizations", ResourceAttributes: testObjAttrs, ListCount: 1}
+		members := func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return jsh.List{}, nil
+		}
+
+		resource := NewResource("organizations")
+		resource.Get(storage.Get)
+		resource.Post(storage.Save)
+		resource.ToMany("members", members)
+		resource.Action("archive", storage.Get)
+
+		api := New("api")
+
+		send := func(method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("->Alias()", func() {
+			resource.Alias("organisations")
+			api.Add(resource)
+
+			Convey("should serve every route under the alias", func() {
+				So(send("GET", "/api/organisations/1", "").Code, ShouldEqual, http.StatusOK)
+				So(send("GET", "/api/organisations/1/members", "").Code, ShouldEqual, http.StatusOK)
+				So(send("GET", "/api/organisations/1/relationships/members", "").Code, ShouldEqual, http.StatusOK)
+				So(send("GET", "/api/organisations/1/archive", "").Code, ShouldEqual, http.StatusOK)
+				So(send("GET", "/api/organizations/1", "").Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("should keep canonical Location headers", func() {
+				recorder := send("POST", "/api/organisations", `{"data": {"type": "organizations", "attributes": {"foo": "bar"}}}`)
+				So(recorder.Code, ShouldEqual, http.StatusCreated)
+				So(recorder.Header().Get("Location"), ShouldEqual, "/api/organizations/1")
+				So(recorder.Header().Get("Deprecation"), ShouldEqual, "")
+			})
+
+			Convey("should flag alias routes", func() {
+				So(api.RouteTree(), ShouldContainSubstring,
+					"GET - /api/organisations/:id (alias of /api/organizations/:id)\n")
+				So(api.RouteTree(), ShouldContainSubstring, "GET - /api/organizations/:id\n")
+
+				aliases := 0
+				for _, route := range api.Routes() {
+					if route.AliasOf != "" {
+						aliases++
+					}
+				}
+				So(aliases, ShouldEqual, 5)
+			})
+
+			Convey("should panic on duplicate aliases", func() {
+				So(func() { resource.Alias("organisations") }, ShouldPanic)
+				So(func() { resource.Alias("organizations") }, ShouldPanic)
+			})
+		})
+
+		Convey("->DeprecatedAlias()", func() {
+			api.Add(resource)
+			resource.DeprecatedAlias("organisations")
+
+			Convey("should flag responses as deprecated", func() {
+				recorder := send("GET", "/api/organisations/1/members", "")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Header().Get("Deprecation"), ShouldEqual, "true")
+				So(recorder.Header().Get("Link"), ShouldEqual, `</api/organizations/1/members>; rel="successor-version"`)
+
+				So(send("GET", "/api/organizations/1", "").Header().Get("Deprecation"), ShouldEqual, "")
+			})
+		})
+
+		Convey("should panic once frozen", func() {
+			api.Add(resource)
+			api.Freeze()
+
+			So(func() { resource.Alias("organisations") }, ShouldPanic)
+		})
+	})
+}
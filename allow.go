@@ -0,0 +1,91 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+methodNotAllowedRoutes returns a route per registered path answering the methods
+no route handles with a 405 and an Allow header listing the supported ones, and
+OPTIONS requests with a 204 and the same Allow header. Sorted along the others,
+each of them follows the routes of its path, so that GET /export answers 405 rather
+than being served by GET /:id. Paths with a route accepting any method are left
+alone.
+*/
+func (res *Resource) methodNotAllowedRoutes(routes []pendingRoute) []pendingRoute {
+	paths := []string{}
+	allowed := map[string]map[string]bool{}
+	anyMethod := map[string]bool{}
+
+	for _, route := range routes {
+		typed, isPat := route.pattern.(*pat.Pattern)
+		if !isPat {
+			continue
+		}
+
+		path := typed.String()
+		if _, seen := allowed[path]; !seen {
+			paths = append(paths, path)
+			allowed[path] = map[string]bool{"OPTIONS": true}
+		}
+
+		methods := typed.HTTPMethods()
+		if methods == nil {
+			anyMethod[path] = true
+		}
+		for method := range methods {
+			allowed[path][method] = true
+		}
+	}
+
+	notAllowed := []pendingRoute{}
+	for _, path := range paths {
+		if anyMethod[path] {
+			continue
+		}
+
+		p := pat.New(path)
+		allow := allowHeader(allowed[path])
+		notAllowed = append(notAllowed, pendingRoute{pattern: p, register: func() {
+			res.Mux.HandleFuncC(p, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Allow", allow)
+				if r.Method == "OPTIONS" {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+
+				res.send(ctx, w, r, methodNotAllowed(r.Method, allow))
+			})
+		}})
+	}
+
+	return notAllowed
+}
+
+// allowHeader lists methods as an Allow header value, sorted
+func allowHeader(methods map[string]bool) string {
+	list := []string{}
+	for method := range methods {
+		list = append(list, method)
+	}
+	sort.Strings(list)
+
+	return strings.Join(list, ", ")
+}
+
+// methodNotAllowed builds the 405 error for a method no route handles
+func methodNotAllowed(method string, allow string) *jsh.Error {
+	return &jsh.Error{
+		Title:  "Method Not Allowed",
+		Detail: fmt.Sprintf("Method %s is not allowed, the supported methods are: %s", method, allow),
+		Status: http.StatusMethodNotAllowed,
+	}
+}
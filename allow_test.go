@@ -0,0 +1,68 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMethodNotAllowed(t *testing.T) {
+
+	Convey("Method Not Allowed Tests", t, func() {
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+		resource.ToMany("foos", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return jsh.List{}, nil
+		})
+		resource.HandleFuncC(pat.Post("/import"), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		})
+		resource.HandleFuncC(pat.New("/any"), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+		api := New("")
+		api.Add(resource)
+
+		send := func(method string, url string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest(method, url, nil))
+			return recorder
+		}
+
+		Convey("should respond 405 with the supported methods", func() {
+			recorder := send("PUT", "/bars/1")
+
+			So(recorder.Code, ShouldEqual, http.StatusMethodNotAllowed)
+			So(recorder.Header().Get("Allow"), ShouldEqual, "DELETE, GET, HEAD, OPTIONS, PATCH")
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, jsh.ContentType)
+			So(recorder.Body.String(), ShouldContainSubstring, "Method PUT is not allowed")
+
+			So(send("DELETE", "/bars").Header().Get("Allow"), ShouldEqual, "GET, HEAD, OPTIONS, POST")
+			So(send("POST", "/bars/1/foos").Header().Get("Allow"), ShouldEqual, "GET, HEAD, OPTIONS")
+		})
+
+		Convey("should list the methods of the most specific path", func() {
+			recorder := send("GET", "/bars/import")
+
+			So(recorder.Code, ShouldEqual, http.StatusMethodNotAllowed)
+			So(recorder.Header().Get("Allow"), ShouldEqual, "OPTIONS, POST")
+		})
+
+		Convey("should keep serving supported methods", func() {
+			So(send("GET", "/bars/1").Code, ShouldEqual, http.StatusOK)
+			So(send("POST", "/bars/import").Code, ShouldEqual, http.StatusAccepted)
+			So(send("PUT", "/bars/any").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("should keep unknown paths 404", func() {
+			So(send("GET", "/bars/1/unknown").Code, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
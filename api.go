@@ -3,13 +3,18 @@ package jshapi
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"goji.io"
 	"goji.io/pat"
+	"golang.org/x/net/context"
 
+	"github.com/derekdowling/go-json-spec-handler"
 	"github.com/derekdowling/go-stdlogger"
 	"github.com/derekdowling/goji2-logger"
 )
@@ -17,9 +22,62 @@ import (
 // API is used to direct HTTP requests to resources
 type API struct {
 	*goji.Mux
-	prefix    string
-	Resources map[string]*Resource
-	Debug     bool
+	prefix       string
+	Resources    map[string]*Resource
+	Debug        bool
+	maxBodyBytes int64
+	// extensions and profiles are the supported JSON API media type parameters
+	extensions map[string]bool
+	profiles   map[string]bool
+	// frozen is set by Freeze, registration panics once it is
+	frozen int32
+	// methodOverride enables the X-HTTP-Method-Override header
+	methodOverride bool
+	// authorizer is used by resources without their own
+	authorizer Authorizer
+	// metrics receives request and storage observations when set
+	metrics MetricsRecorder
+	// tracer starts spans around handlers and storage calls when set
+	tracer Tracer
+	// audit queues events for the auditor when set
+	audit    *auditQueue
+	identity IdentityFunc
+	// events queues resource events for the event sink when set
+	events *eventQueue
+	// debug enables the capture of failed requests when set
+	debug *DebugOptions
+	// routes registered by the API itself rather than by its resources
+	routes []Route
+	// readOnly is set in maintenance read-only mode, writes are then answered
+	// with readOnlyNotice
+	readOnly       int32
+	readOnlyNotice atomic.Value
+	// selfLinks adds self links to sent documents, prefixed with baseURL or the
+	// forwarded scheme and host when trusted
+	selfLinks    bool
+	baseURL      string
+	trustedProxy func(r *http.Request) bool
+	// tenant mounts the routes under a tenant prefix when set
+	tenant *tenantScope
+	// dryRun enables the X-Dry-Run header on creations and updates
+	dryRun bool
+	// keyCasing converts the attribute names of resources without their own
+	keyCasing *keyCasing
+	// translator translates the error objects sent when set
+	translator ErrorTranslator
+	// inFlight counts the requests being served by resource routes and atomic
+	// operations
+	inFlight atomic.Int64
+	// draining is set by Shutdown, new requests are then answered with a 503, and
+	// drained is closed to wake up the requests waiting for changes
+	draining  int32
+	drained   chan struct{}
+	drainOnce sync.Once
+	// simpleJSON answers reads accepting application/json in simple JSON
+	simpleJSON bool
+	// storageErrors counts failed storage calls by call once debug endpoints are
+	// mounted, see MountDebug
+	storageErrors *storageErrorCounts
 }
 
 /*
@@ -39,11 +97,22 @@ func New(prefix string) *API {
 	}
 
 	// create our new API
-	return &API{
-		Mux:       goji.NewMux(),
-		prefix:    prefix,
-		Resources: map[string]*Resource{},
+	api := &API{
+		Mux:          goji.NewMux(),
+		prefix:       prefix,
+		Resources:    map[string]*Resource{},
+		maxBodyBytes: DefaultMaxBodyBytes,
+		extensions:   map[string]bool{},
+		profiles:     map[string]bool{},
+		drained:      make(chan struct{}),
 	}
+
+	// record the matched pattern for route reporting, then validate JSON API media
+	// type parameters before any resource handler runs
+	api.UseC(recordAPIPattern)
+	api.UseC(api.negotiationMiddleware)
+
+	return api
 }
 
 /*
@@ -67,6 +136,9 @@ func Default(prefix string, debug bool, logger std.Logger) *API {
 	api := New(prefix)
 	SendHandler = DefaultSender(logger)
 
+	// assign request ids before logging so that error documents carry them
+	api.UseC(RequestID())
+
 	// register logger middleware
 	gojilogger := gojilogger.New(logger, debug)
 	api.UseC(gojilogger.Middleware)
@@ -77,32 +149,135 @@ func Default(prefix string, debug bool, logger std.Logger) *API {
 // Add implements mux support for a given resource which is effectively handled as:
 // pat.New("/(prefix/)resource.Plu*)
 func (a *API) Add(resource *Resource) {
+	a.checkRegistration(fmt.Sprintf("resource '%s'", resource.Type))
+	a.checkRouteNames(resource)
+	a.checkConflicts(resource)
 
 	// track our associated resources, will enable auto-generation docs later
 	a.Resources[resource.Type] = resource
+	resource.api = a
+
+	// nested resources are routed by their parent
+	if resource.parent != nil {
+		return
+	}
 
 	// Because of how prefix matches work:
 	// https://godoc.org/github.com/goji/goji/pat#hdr-Prefix_Matches
 	// We need two separate routes,
-	// /(prefix/)resources
-	matcher := path.Join(a.prefix, resource.Type)
+	// /(prefix/)(resource prefix/)resources
+	matcher := path.Join(a.prefix, resource.prefix, resource.CollectionPath())
 	a.Mux.HandleC(pat.New(matcher), resource)
 
 	// And:
-	// /(prefix/)resources/*
-	idMatcher := path.Join(a.prefix, resource.Type, "*")
+	// /(prefix/)(resource prefix/)resources/*
+	idMatcher := path.Join(a.prefix, resource.prefix, resource.CollectionPath(), "*")
 	a.Mux.HandleC(pat.New(idMatcher), resource)
+
+	for _, alias := range resource.aliases {
+		a.mountAlias(resource, alias)
+	}
+}
+
+// checkConflicts panics when resource shares its type, or one of the paths it is
+// mounted under, with a resource already added
+func (a *API) checkConflicts(resource *Resource) {
+	paths := resource.mountPaths(a.prefix)
+
+	for _, added := range a.Resources {
+		if added == resource {
+			continue
+		}
+		if added.Type == resource.Type {
+			panic(fmt.Sprintf("jshapi: resource type '%s' is already registered", resource.Type))
+		}
+
+		for _, mounted := range added.mountPaths(a.prefix) {
+			for _, mountPath := range paths {
+				if mounted == mountPath {
+					panic(fmt.Sprintf("jshapi: path '%s' of resource '%s' is already routed to resource '%s'", mountPath, resource.Type, added.Type))
+				}
+			}
+		}
+	}
+}
+
+// mountPaths lists the paths a resource is mounted under in an API with prefix,
+// its collection path and aliases
+func (res *Resource) mountPaths(prefix string) []string {
+	paths := []string{path.Join(prefix, res.prefix, res.CollectionPath())}
+	for _, alias := range res.aliases {
+		paths = append(paths, path.Join(prefix, res.prefix, alias.name))
+	}
+
+	return paths
 }
 
 // RouteTree prints out all accepted routes for the API that use jshapi implemented
 // ways of adding routes through resources: NewCRUDResource(), .Get(), .Post, .Delete(),
-// .Patch(), .List(), and .NewAction()
+// .Patch(), .List(), .ToOne(), .ToMany() and .Action(), along with the API's own
+// routes. Each line reads "METHOD - pattern", sorted by pattern then method, see
+// Routes for the structured equivalent.
 func (a *API) RouteTree() string {
-	var routes string
+	return routeTree(a.Routes())
+}
+
+// Prefix returns the "/" prefixed path under which the API's resources are routed
+func (a *API) Prefix() string {
+	return a.prefix
+}
+
+// ServeHTTP implements http.Handler, the request context is canceled when the
+// client goes away
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.ServeHTTPC(r.Context(), w, r)
+}
+
+// ServeHTTPC implements goji.Handler, keeping bodyless responses empty, turning
+// requests away while draining, applying method overrides and starting route
+// reporting before routing
+func (a *API) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	w = wrapBodylessWriter(w, r)
+
+	if a.Draining() {
+		sendDraining(ctx, w, r)
+		return
+	}
+
+	if a.methodOverride {
+		err := overrideMethod(r)
+		if err != nil {
+			SendHandler(ctx, w, r, err)
+			return
+		}
+	}
+
+	// route reporting is only needed for storage spans and metrics
+	var info *routeInfo
+	if a.tracer != nil || a.metrics != nil {
+		ctx, info = withRouteInfo(ctx)
+		info.api = a
+	}
+
+	if a.debug != nil {
+		var report func()
+		w, report = a.captureDebug(ctx, w, r)
+		defer report()
+	}
+
+	if a.tenant != nil {
+		var err jsh.ErrorType
+		ctx, r, err = a.tenant.resolve(ctx, r)
+		if HasError(err) {
+			SendHandler(ctx, w, r, err)
+			return
+		}
+	}
 
-	for _, resource := range a.Resources {
-		routes = strings.Join([]string{routes, resource.RouteTree()}, "")
+	if a.metrics == nil {
+		a.Mux.ServeHTTPC(ctx, w, r)
+		return
 	}
 
-	return routes
+	a.serveObserved(ctx, w, r, info)
 }
@@ -0,0 +1,628 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+// AtomicExtension is the URI of the JSON API atomic operations extension
+const AtomicExtension = "https://jsonapi.org/ext/atomic"
+
+// AtomicContentType is the media type used by atomic operations requests and responses
+const AtomicContentType = jsh.ContentType + `; ext="` + AtomicExtension + `"`
+
+const (
+	atomicAdd    = "add"
+	atomicUpdate = "update"
+	atomicRemove = "remove"
+)
+
+// atomicRequest is the top level document of an atomic operations request
+type atomicRequest struct {
+	Operations []*atomicOperation `json:"atomic:operations"`
+}
+
+// atomicOperation is a single operation within an atomic operations request
+type atomicOperation struct {
+	Op   string          `json:"op"`
+	Ref  *atomicRef      `json:"ref,omitempty"`
+	Href string          `json:"href,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// atomicRef identifies the target of an operation
+type atomicRef struct {
+	Type         string `json:"type"`
+	ID           string `json:"id,omitempty"`
+	LID          string `json:"lid,omitempty"`
+	Relationship string `json:"relationship,omitempty"`
+}
+
+// atomicResult is the outcome of a single successful operation
+type atomicResult struct {
+	Data *jsh.Object `json:"data,omitempty"`
+}
+
+// atomicResponse is the top level document of an atomic operations response
+type atomicResponse struct {
+	Results []*atomicResult `json:"atomic:results"`
+}
+
+/*
+AtomicOperations registers a `POST /(prefix/)<route>` endpoint implementing the
+JSON API atomic operations extension: https://jsonapi.org/ext/atomic
+
+Each "add", "update", and "remove" operation is dispatched to the Post, Patch, and
+Delete storage of the API resource matching its type, and local ids ("lid") are
+resolved across operations so that later operations can reference resources created
+by earlier ones. Operations are applied in order, the first failure aborts the
+request with an error pointing at the failing operation. Each operation is
+authorized before storage is touched, and the objects of "add" and "update"
+operations go through the checks of the `POST` and `PATCH` routes of their
+resource: type, member names, schema, validators and client id policy.
+
+When the CRUD storage of the resources involved implements store.Transactional,
+all operations run within a transaction that is rolled back on failure. Storage
+without transaction support cannot be rolled back, operations applied before the
+failing one remain applied.
+*/
+func (a *API) AtomicOperations(route string) {
+	a.checkRegistration("atomic operations")
+
+	a.SupportExtension(AtomicExtension)
+
+	matcher := path.Join(a.prefix, route)
+	a.Mux.HandleFuncC(pat.Post(matcher), a.atomicHandler)
+	a.routes = append(a.routes, Route{Method: post, Pattern: matcher})
+}
+
+// POST /(prefix/)operations
+func (a *API) atomicHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	ctx, w, endSpan := a.traceHandler(ctx, w, "jshapi.operations")
+	defer endSpan()
+
+	a.inFlight.Add(1)
+	defer a.inFlight.Add(-1)
+
+	if a.ReadOnlyMode() {
+		sendReadOnly(ctx, w, r, a.notice())
+		return
+	}
+
+	if a.dryRunRequested(r) {
+		SendHandler(ctx, w, r, dryRunUnsupported(post))
+		return
+	}
+
+	if !hasAtomicExtension(r.Header.Get("Content-Type")) {
+		SendHandler(ctx, w, r, &jsh.Error{
+			Title:  "Unsupported Media Type",
+			Detail: fmt.Sprintf("Expected Content-Type header to be %s", AtomicContentType),
+			Status: http.StatusUnsupportedMediaType,
+		})
+		return
+	}
+
+	mediaTypeErr := MediaTypeError(ctx)
+	if mediaTypeErr != nil {
+		SendHandler(ctx, w, r, mediaTypeErr)
+		return
+	}
+
+	raw, readErr := readBody(w, r, a.maxBodyBytes)
+	if readErr != nil {
+		SendHandler(ctx, w, r, readErr)
+		return
+	}
+
+	request := &atomicRequest{}
+	decodeErr := json.Unmarshal(raw, request)
+	if decodeErr != nil || len(request.Operations) == 0 {
+		SendHandler(ctx, w, r, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: "Request body must contain a non-empty 'atomic:operations' array",
+			Status: http.StatusBadRequest,
+		})
+		return
+	}
+
+	batch := &atomicBatch{api: a, r: r, lids: map[string]string{}}
+
+	results := []*atomicResult{}
+	for index, operation := range request.Operations {
+		result, err := batch.apply(ctx, operation)
+		if err != nil {
+			batch.rollback()
+			SendHandler(ctx, w, r, scopeErrors(err, fmt.Sprintf("/atomic:operations/%d", index)))
+			return
+		}
+
+		results = append(results, result)
+	}
+
+	commitErr := batch.commit()
+	if commitErr != nil {
+		SendHandler(ctx, w, r, commitErr)
+		return
+	}
+
+	for _, audit := range batch.audits {
+		audit()
+	}
+
+	if clientGone(ctx) {
+		return
+	}
+
+	sendAtomicResults(w, results)
+}
+
+// atomicBatch tracks the state shared by the operations of a single request
+type atomicBatch struct {
+	api *API
+	// r is the request operations are authorized against
+	r *http.Request
+	// lids maps local ids to the ids assigned by storage
+	lids map[string]string
+	// ctx is the context passed to storage, carries any open transactions
+	ctx context.Context
+	// transactions that have been started, in order
+	transactions []store.Transactional
+	// contexts are the contexts returned by each Begin call
+	contexts []context.Context
+	// audits are queued once the batch has been committed
+	audits []func()
+}
+
+// apply runs a single operation against the storage of the targeted resource
+func (b *atomicBatch) apply(ctx context.Context, operation *atomicOperation) (*atomicResult, jsh.ErrorType) {
+	if b.ctx == nil {
+		b.ctx = ctx
+	}
+
+	if operation == nil {
+		return nil, atomicError("Operation must be an object")
+	}
+
+	resourceType, id, err := b.target(operation)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, registered := b.api.Resources[resourceType]
+	if !registered {
+		return nil, atomicError(fmt.Sprintf("Resource type '%s' does not exist", resourceType))
+	}
+	if resource.ReadOnlyMode() {
+		return nil, readOnlyError(b.api.notice())
+	}
+
+	if id != "" {
+		idErr := resource.idError(id)
+		if idErr != nil {
+			return nil, idErr
+		}
+	}
+
+	switch operation.Op {
+	case atomicAdd:
+		if resource.storage.save == nil {
+			return nil, atomicError(fmt.Sprintf("Resource type '%s' does not support 'add'", resourceType))
+		}
+
+		err = resource.authorizeRequest(b.ctx, b.r, OpCreate, "")
+		if err != nil {
+			return nil, err
+		}
+
+		object, lid, err := b.write(resource, post, operation.Data, "")
+		if err != nil {
+			return nil, err
+		}
+
+		storageCtx, finish := startStorage(b.ctx, b.r, "save")
+		saved, saveErr := resource.storage.save(storageCtx, object)
+		saveErr = finish(saveErr)
+		if HasError(saveErr) {
+			return nil, saveErr
+		}
+
+		if lid != "" && saved != nil {
+			b.lids[lid] = saved.ID
+		}
+
+		b.audit(resource, OpCreate, "", object, saved)
+
+		return b.result(resource, saved)
+
+	case atomicUpdate:
+		if id == "" {
+			id = b.dataID(operation.Data)
+		}
+		if id == "" {
+			return nil, atomicError("Operation 'update' requires a target id")
+		}
+		idErr := resource.idError(id)
+		if idErr != nil {
+			return nil, idErr
+		}
+
+		if resource.storage.update == nil {
+			return nil, atomicError(fmt.Sprintf("Resource type '%s' does not support 'update'", resourceType))
+		}
+
+		err = resource.authorizeRequest(b.ctx, b.r, OpUpdate, id)
+		if err != nil {
+			return nil, err
+		}
+
+		object, _, err := b.write(resource, patch, operation.Data, id)
+		if err != nil {
+			return nil, err
+		}
+
+		storageCtx, finish := startStorage(b.ctx, b.r, "update")
+		updated, updateErr := resource.storage.update(withPatchFields(storageCtx, object), object)
+		updateErr = finish(updateErr)
+		if HasError(updateErr) {
+			return nil, updateErr
+		}
+
+		b.audit(resource, OpUpdate, id, object, updated)
+		return b.result(resource, updated)
+
+	case atomicRemove:
+		if id == "" {
+			return nil, atomicError("Operation 'remove' requires a target id")
+		}
+
+		if resource.storage.delete == nil {
+			return nil, atomicError(fmt.Sprintf("Resource type '%s' does not support 'remove'", resourceType))
+		}
+
+		err = resource.authorizeRequest(b.ctx, b.r, OpDelete, id)
+		if err != nil {
+			return nil, err
+		}
+
+		err = b.begin(resource)
+		if err != nil {
+			return nil, err
+		}
+
+		storageCtx, finish := startStorage(b.ctx, b.r, "delete")
+		deleteErr := resource.storage.delete(storageCtx, id)
+		deleteErr = finish(deleteErr)
+		if HasError(deleteErr) {
+			return nil, deleteErr
+		}
+
+		b.audit(resource, OpDelete, id, nil, nil)
+		return &atomicResult{}, nil
+
+	default:
+		return nil, atomicError(fmt.Sprintf("Unsupported operation '%s'", operation.Op))
+	}
+}
+
+// audit defers the audit event of an operation until the batch is committed
+func (b *atomicBatch) audit(resource *Resource, op Operation, id string, received *jsh.Object, returned *jsh.Object) {
+	ctx := b.ctx
+	b.audits = append(b.audits, func() {
+		resource.audit(ctx, op, id, received, returned)
+	})
+}
+
+// target resolves the resource type and id an operation applies to from either
+// its "ref", "href", or "data" member
+func (b *atomicBatch) target(operation *atomicOperation) (string, string, jsh.ErrorType) {
+	switch {
+	case operation.Ref != nil:
+		if operation.Ref.Relationship != "" {
+			return "", "", atomicError("Relationship operations are not supported")
+		}
+
+		id := operation.Ref.ID
+		if id == "" && operation.Ref.LID != "" {
+			resolved, exists := b.lids[operation.Ref.LID]
+			if !exists {
+				return "", "", atomicError(fmt.Sprintf("Unknown local id '%s'", operation.Ref.LID))
+			}
+			id = resolved
+		}
+
+		return operation.Ref.Type, id, nil
+
+	case operation.Href != "":
+		return b.hrefTarget(operation.Href)
+
+	default:
+		data := struct {
+			Type string `json:"type"`
+		}{}
+
+		if len(operation.Data) == 0 || json.Unmarshal(operation.Data, &data) != nil || data.Type == "" {
+			return "", "", atomicError("Operation requires a 'ref', 'href', or typed 'data' member")
+		}
+
+		return data.Type, "", nil
+	}
+}
+
+/*
+hrefTarget resolves the resource type and id of an href from the paths the
+resources of the API are mounted under, collection paths, aliases and nested
+prefixes included.
+*/
+func (b *atomicBatch) hrefTarget(href string) (string, string, jsh.ErrorType) {
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(href, tenantPath(b.r)), "/"), "/")
+
+	for _, resource := range b.api.Resources {
+		for _, mountPath := range resource.mountPaths(b.api.prefix) {
+			mounted := strings.Split(strings.Trim(mountPath, "/"), "/")
+			if matchSegments(mounted, segments) {
+				return resource.Type, "", nil
+			}
+			if matchSegments(append(mounted, ":id"), segments) {
+				return resource.Type, segments[len(mounted)], nil
+			}
+		}
+	}
+
+	return "", "", atomicError(fmt.Sprintf("Href '%s' does not match any resource", href))
+}
+
+/*
+write opens the transaction of the resource and builds the object of an add or
+update operation, its id set to id for updates, running the checks of the write
+routes of the resource against it, which leave it with its stored attributes.
+*/
+func (b *atomicBatch) write(resource *Resource, method string, raw json.RawMessage, id string) (*jsh.Object, string, jsh.ErrorType) {
+	err := b.begin(resource)
+	if err != nil {
+		return nil, "", err
+	}
+
+	object, lid, err := b.object(raw)
+	if err != nil {
+		return nil, "", err
+	}
+	if id != "" {
+		object.ID = id
+	}
+
+	errs := resource.objectErrors(b.ctx, method, object)
+	if len(errs) > 0 {
+		return nil, "", errs
+	}
+
+	return object, lid, nil
+}
+
+// dataID returns the id of the data of an operation, its local id resolved, or an
+// empty string if it has none
+func (b *atomicBatch) dataID(raw json.RawMessage) string {
+	data := struct {
+		ID  string `json:"id"`
+		LID string `json:"lid"`
+	}{}
+	if json.Unmarshal(raw, &data) != nil {
+		return ""
+	}
+
+	if data.ID == "" && data.LID != "" {
+		return b.lids[data.LID]
+	}
+	return data.ID
+}
+
+// result holds the object returned by the storage of an operation as sent by its
+// resource, redacted by its field policy
+func (b *atomicBatch) result(resource *Resource, object *jsh.Object) (*atomicResult, jsh.ErrorType) {
+	if object == nil {
+		return &atomicResult{}, nil
+	}
+
+	switch prepared := resource.prepare(b.ctx, object).(type) {
+	case *jsh.Object:
+		return &atomicResult{Data: prepared}, nil
+	case jsh.ErrorType:
+		return nil, prepared
+	}
+
+	return nil, jsh.ISE(fmt.Sprintf("Unable to prepare the '%s' object of an operation", object.Type))
+}
+
+// object builds the resource object of an operation after replacing local ids with
+// the ids they resolve to, returns the object's own lid when it has one
+func (b *atomicBatch) object(raw json.RawMessage) (*jsh.Object, string, jsh.ErrorType) {
+	data := map[string]interface{}{}
+	if len(raw) == 0 || json.Unmarshal(raw, &data) != nil {
+		return nil, "", atomicError("Operation requires a resource object as 'data'")
+	}
+
+	lid, _ := data["lid"].(string)
+	if _, hasID := data["id"]; !hasID && lid != "" {
+		if id, resolved := b.lids[lid]; resolved {
+			data["id"] = id
+		}
+	}
+
+	relationships, _ := data["relationships"].(map[string]interface{})
+	for _, relationship := range relationships {
+		relationship, isObject := relationship.(map[string]interface{})
+		if !isObject {
+			continue
+		}
+
+		switch linkage := relationship["data"].(type) {
+		case map[string]interface{}:
+			b.resolveIdentifier(linkage)
+		case []interface{}:
+			for _, identifier := range linkage {
+				if identifier, isObject := identifier.(map[string]interface{}); isObject {
+					b.resolveIdentifier(identifier)
+				}
+			}
+		}
+	}
+
+	resolved, err := json.Marshal(data)
+	if err != nil {
+		return nil, "", jsh.ISE(fmt.Sprintf("Unable to marshal operation data: %s", err.Error()))
+	}
+
+	object := &jsh.Object{}
+	err = json.Unmarshal(resolved, object)
+	if err != nil {
+		return nil, "", atomicError("Operation 'data' is not a valid resource object")
+	}
+
+	if object.Type == "" {
+		return nil, "", atomicError("Operation 'data' must have a 'type'")
+	}
+
+	attributesErr := attributesError(object)
+	if attributesErr != nil {
+		return nil, "", attributesErr
+	}
+
+	return object, lid, nil
+}
+
+// resolveIdentifier swaps the lid of a resource identifier for its resolved id
+func (b *atomicBatch) resolveIdentifier(identifier map[string]interface{}) {
+	lid, hasLID := identifier["lid"].(string)
+	if !hasLID {
+		return
+	}
+
+	if id, resolved := b.lids[lid]; resolved {
+		identifier["id"] = id
+	}
+}
+
+// begin opens a transaction on the resource storage if it supports them and one
+// isn't already open
+func (b *atomicBatch) begin(resource *Resource) jsh.ErrorType {
+	if resource.tx == nil {
+		return nil
+	}
+
+	for _, tx := range b.transactions {
+		if tx == resource.tx {
+			return nil
+		}
+	}
+
+	ctx, err := resource.tx.Begin(b.ctx)
+	if HasError(err) {
+		return err
+	}
+
+	b.ctx = ctx
+	b.transactions = append(b.transactions, resource.tx)
+	b.contexts = append(b.contexts, ctx)
+
+	return nil
+}
+
+// commit commits all open transactions, rolling back any remaining ones if a
+// commit fails
+func (b *atomicBatch) commit() jsh.ErrorType {
+	for len(b.transactions) > 0 {
+		last := len(b.transactions) - 1
+
+		err := b.transactions[last].Commit(b.contexts[last])
+		b.transactions = b.transactions[:last]
+		b.contexts = b.contexts[:last]
+
+		if HasError(err) {
+			b.rollback()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollback rolls back all open transactions
+func (b *atomicBatch) rollback() {
+	for index := len(b.transactions) - 1; index >= 0; index-- {
+		b.transactions[index].Rollback(b.contexts[index])
+	}
+
+	b.transactions = nil
+	b.contexts = nil
+}
+
+// hasAtomicExtension checks whether a media type carries the atomic extension
+func hasAtomicExtension(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != jsh.ContentType {
+		return false
+	}
+
+	for _, ext := range strings.Fields(params["ext"]) {
+		if ext == AtomicExtension {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sendAtomicResults writes an atomic operations response, or a 204 when none of
+// the operations produced data
+func sendAtomicResults(w http.ResponseWriter, results []*atomicResult) {
+	hasData := false
+	for _, result := range results {
+		if result.Data != nil {
+			hasData = true
+		}
+	}
+
+	if !hasData {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	content, err := json.Marshal(&atomicResponse{Results: results})
+	if err == nil {
+		err = writeJSON(w, AtomicContentType, http.StatusOK, content)
+	}
+	if err != nil {
+		http.Error(w, jsh.DefaultErrorTitle, http.StatusInternalServerError)
+	}
+}
+
+// atomicError builds a 400 error for a malformed or unsupported operation
+func atomicError(detail string) *jsh.Error {
+	return &jsh.Error{
+		Title:  "Bad Request",
+		Detail: detail,
+		Status: http.StatusBadRequest,
+	}
+}
+
+// scopeErrors prefixes the source pointer of every error with "prefix"
+func scopeErrors(err jsh.ErrorType, prefix string) jsh.ErrorList {
+	scoped := jsh.ErrorList{}
+	for _, singleErr := range toErrorList(err) {
+		copied := *singleErr
+		copied.Source.Pointer = prefix + singleErr.Source.Pointer
+		scoped = append(scoped, &copied)
+	}
+
+	return scoped
+}
@@ -0,0 +1,200 @@
+package jshapi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+// txStorage is a MockStorage that records transaction calls
+type txStorage struct {
+	MockStorage
+	saved      int
+	began      int
+	committed  int
+	rolledBack int
+}
+
+func (s *txStorage) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	if object.ID == "" {
+		s.saved++
+		object.ID = strconv.Itoa(s.saved)
+	}
+	return object, nil
+}
+
+func (s *txStorage) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	if object.ID == "missing" {
+		return nil, jsh.NotFound(object.Type, object.ID)
+	}
+	return object, nil
+}
+
+func (s *txStorage) Begin(ctx context.Context) (context.Context, jsh.ErrorType) {
+	s.began++
+	return ctx, nil
+}
+
+func (s *txStorage) Commit(ctx context.Context) jsh.ErrorType {
+	s.committed++
+	return nil
+}
+
+func (s *txStorage) Rollback(ctx context.Context) jsh.ErrorType {
+	s.rolledBack++
+	return nil
+}
+
+func TestAtomicOperations(t *testing.T) {
+
+	Convey("Atomic Operations Tests", t, func() {
+
+		storage := &txStorage{MockStorage: MockStorage{ResourceType: testResourceType}}
+
+		validated := 0
+		resource := NewCRUDResource(testResourceType, storage)
+		resource.AddValidator(func(ctx context.Context, object *jsh.Object) jsh.ErrorType {
+			validated++
+			return nil
+		})
+
+		api := New("api")
+		api.SetAuthorizer(AuthorizerFunc(func(ctx context.Context, r *http.Request, op Operation, resourceType string, id string) jsh.ErrorType {
+			if id == "locked" {
+				return &jsh.Error{Title: "Forbidden", Detail: "Object is locked", Status: http.StatusForbidden}
+			}
+			return nil
+		}))
+		resource.Alias("barz")
+		api.Add(resource)
+		api.Add(NewCRUDResource("person", storage, WithCollectionPath("people")))
+		api.AtomicOperations("operations")
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/api/operations"
+
+		Convey("should apply operations in order and resolve local ids", func() {
+			resp, body := postAtomic(url, AtomicContentType, `{"atomic:operations": [
+				{"op": "add", "data": {"type": "bars", "lid": "a", "attributes": {"foo": "bar"}}},
+				{"op": "update", "ref": {"type": "bars", "lid": "a"}, "data": {"type": "bars", "lid": "a", "attributes": {"foo": "baz"}}},
+				{"op": "remove", "href": "/api/bars/1"}
+			]}`)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Content-Type"), ShouldEqual, AtomicContentType)
+			So(body, ShouldContainSubstring, `"atomic:results"`)
+			So(body, ShouldContainSubstring, `"id": "1"`)
+			So(storage.began, ShouldEqual, 1)
+			So(storage.committed, ShouldEqual, 1)
+			So(storage.rolledBack, ShouldEqual, 0)
+		})
+
+		Convey("should roll back and point at the failing operation", func() {
+			resp, body := postAtomic(url, AtomicContentType, `{"atomic:operations": [
+				{"op": "add", "data": {"type": "bars", "attributes": {"foo": "bar"}}},
+				{"op": "update", "ref": {"type": "bars", "id": "missing"}, "data": {"type": "bars"}}
+			]}`)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(body, ShouldContainSubstring, `"pointer": "/atomic:operations/1"`)
+			So(storage.committed, ShouldEqual, 0)
+			So(storage.rolledBack, ShouldEqual, 1)
+		})
+
+		Convey("should run the checks of the write routes", func() {
+			resp, body := postAtomic(url, AtomicContentType, `{"atomic:operations": [
+				{"op": "add", "data": {"type": "bars", "attributes": {"foo": "bar"}}},
+				{"op": "update", "ref": {"type": "bars", "id": "1"}, "data": {"type": "other", "attributes": {"foo": "baz"}}}
+			]}`)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+			So(body, ShouldContainSubstring, `"pointer": "/atomic:operations/1/data/type"`)
+			So(validated, ShouldEqual, 2)
+			So(storage.rolledBack, ShouldEqual, 1)
+		})
+
+		Convey("should authorize operations before opening transactions", func() {
+			resp, body := postAtomic(url, AtomicContentType, `{"atomic:operations": [
+				{"op": "update", "ref": {"type": "bars", "id": "locked"}, "data": {"type": "bars", "attributes": {"foo": "baz"}}}
+			]}`)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(body, ShouldContainSubstring, `"pointer": "/atomic:operations/0"`)
+			So(storage.began, ShouldEqual, 0)
+			So(validated, ShouldEqual, 0)
+		})
+
+		Convey("should redact results with the field policy", func() {
+			resource.fieldPolicy = func(ctx context.Context, objectType string) []string {
+				return []string{}
+			}
+
+			resp, body := postAtomic(url, AtomicContentType, `{"atomic:operations": [
+				{"op": "add", "data": {"type": "bars", "attributes": {"secret": "x"}}}
+			]}`)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(body, ShouldContainSubstring, `"id": "1"`)
+			So(body, ShouldNotContainSubstring, "secret")
+		})
+
+		Convey("should resolve hrefs by the paths resources are routed under", func() {
+			resp, _ := postAtomic(url, AtomicContentType, `{"atomic:operations": [
+				{"op": "remove", "href": "/api/people/1"},
+				{"op": "remove", "href": "/api/bars/1"},
+				{"op": "remove", "href": "/api/barz/1"}
+			]}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+
+			resp, body := postAtomic(url, AtomicContentType, `{"atomic:operations": [
+				{"op": "remove", "href": "/api/person/1"}
+			]}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(body, ShouldContainSubstring, "Href '/api/person/1' does not match any resource")
+		})
+
+		Convey("should reject unknown local ids", func() {
+			resp, body := postAtomic(url, AtomicContentType, `{"atomic:operations": [
+				{"op": "remove", "ref": {"type": "bars", "lid": "nope"}}
+			]}`)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(body, ShouldContainSubstring, `"pointer": "/atomic:operations/0"`)
+		})
+
+		Convey("should require the atomic extension media type", func() {
+			resp, _ := postAtomic(url, jsh.ContentType, `{"atomic:operations": []}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnsupportedMediaType)
+		})
+	})
+}
+
+func postAtomic(url string, contentType string, body string) (*http.Response, string) {
+	req, err := http.NewRequest("POST", url, bytes.NewBufferString(body))
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+
+	return resp, string(raw)
+}
@@ -0,0 +1,624 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// KeyCase is a naming convention of attribute names
+type KeyCase int
+
+const (
+	// DashCase names attributes such as "created-at"
+	DashCase KeyCase = iota + 1
+	// SnakeCase names attributes such as "created_at"
+	SnakeCase
+	// CamelCase names attributes such as "createdAt"
+	CamelCase
+)
+
+/*
+KeyCasing converts the top-level attribute names of the objects sent by the
+resources of the API from the casing used by their storage to the casing of the
+API, and those of the objects received back, for resources without their own:
+
+	api.KeyCasing(jshapi.DashCase, jshapi.SnakeCase)
+
+See Resource.KeyCasing.
+*/
+func (a *API) KeyCasing(sent KeyCase, stored KeyCase) {
+	a.checkRegistration("a key casing")
+
+	a.keyCasing = newKeyCasing(sent, stored)
+}
+
+/*
+KeyCasing converts the top-level attribute names of the objects of the resource
+type from the casing used by storage to the casing of the API, wherever they are
+sent, included objects and atomic results alike, and the names of the objects
+received back to the storage casing before validators and storage see them:
+
+	posts.KeyCasing(jshapi.DashCase, jshapi.SnakeCase)
+
+sends `created_at` as `created-at` and stores `created-at` as `created_at`. Names
+are only converted when the conversion is reversible, `userID` is sent as is
+rather than as a `user-id` that would be stored back as `user_id`, so that PATCH
+round-trips keep the names of storage. Field policies, sparse fieldsets, response
+hooks and schemas see the sent names. Takes precedence over the casing of the API.
+*/
+func (res *Resource) KeyCasing(sent KeyCase, stored KeyCase) {
+	res.checkRegistration("a key casing")
+
+	res.keyCasing = newKeyCasing(sent, stored)
+}
+
+/*
+Codec converts the JSON value of an attribute between its stored and sent forms.
+Decode errors are answered with a 400 pointing at the attribute.
+*/
+type Codec interface {
+	Encode(stored json.RawMessage) (json.RawMessage, error)
+	Decode(sent json.RawMessage) (json.RawMessage, error)
+}
+
+/*
+AttributeCodec converts the values of an attribute of the objects of the resource
+type, named as sent, with codec: values are encoded wherever objects of the type
+are sent and decoded when received, after the key casing. Null values are left as
+they are. Panics when the field is empty or the codec nil.
+
+	events.AttributeCodec("starts-at", jshapi.UTCTime)
+*/
+func (res *Resource) AttributeCodec(field string, codec Codec) {
+	res.checkRegistration("an attribute codec")
+
+	if field == "" || codec == nil {
+		panic(fmt.Sprintf("jshapi: attribute codec of '%s' requires a field and a codec", res.Type))
+	}
+
+	if res.codecs == nil {
+		res.codecs = map[string]Codec{}
+	}
+	res.codecs[field] = codec
+}
+
+// UTCTime sends RFC3339 times in UTC whatever the offset they are stored with, and
+// rejects received values that are not RFC3339 times
+var UTCTime Codec = utcTime{}
+
+type utcTime struct{}
+
+func (utcTime) Encode(stored json.RawMessage) (json.RawMessage, error) {
+	return utcTimeValue(stored)
+}
+
+func (utcTime) Decode(sent json.RawMessage) (json.RawMessage, error) {
+	return utcTimeValue(sent)
+}
+
+// utcTimeValue parses value as an RFC3339 time string and formats it in UTC
+func utcTimeValue(value json.RawMessage) (json.RawMessage, error) {
+	var text string
+	err := json.Unmarshal(value, &text)
+	if err != nil {
+		return nil, fmt.Errorf("expected an RFC3339 time string")
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		return nil, fmt.Errorf("expected an RFC3339 time, got '%s'", text)
+	}
+
+	return json.Marshal(parsed.UTC().Format(time.RFC3339Nano))
+}
+
+// keyCasing converts attribute names between their sent and stored casing
+type keyCasing struct {
+	sent, stored KeyCase
+	// names caches the sent names of stored names, which storage bounds, unlike
+	// the names received
+	names sync.Map
+}
+
+// newKeyCasing builds a casing, panicking on unknown cases
+func newKeyCasing(sent KeyCase, stored KeyCase) *keyCasing {
+	for _, casing := range []KeyCase{sent, stored} {
+		if casing < DashCase || casing > CamelCase {
+			panic(fmt.Sprintf("jshapi: unknown key casing %d", casing))
+		}
+	}
+
+	return &keyCasing{sent: sent, stored: stored}
+}
+
+// encode returns the sent name of a stored name
+func (c *keyCasing) encode(name string) string {
+	cached, found := c.names.Load(name)
+	if found {
+		return cached.(string)
+	}
+
+	sent := convertCase(name, c.sent, c.stored)
+	c.names.Store(name, sent)
+	return sent
+}
+
+// decode returns the stored name of a sent name
+func (c *keyCasing) decode(name string) string {
+	return convertCase(name, c.stored, c.sent)
+}
+
+// convertCase converts name to a casing, or returns it as is when converting the
+// result back to the original casing doesn't give name
+func convertCase(name string, to KeyCase, from KeyCase) string {
+	words := splitWords(name)
+	converted := joinWords(words, to)
+	if joinWords(splitWords(converted), from) != name {
+		return name
+	}
+
+	return converted
+}
+
+// splitWords splits a name in lower case words, at dashes, underscores and case
+// changes, "HTTPServer" giving "http" and "server"
+func splitWords(name string) []string {
+	words := []string{}
+	runes := []rune(name)
+
+	start := 0
+	for index, current := range runes {
+		if current == '-' || current == '_' {
+			if index > start {
+				words = append(words, strings.ToLower(string(runes[start:index])))
+			}
+			start = index + 1
+			continue
+		}
+
+		if index > start && unicode.IsUpper(current) {
+			previous := runes[index-1]
+			nextLower := index+1 < len(runes) && unicode.IsLower(runes[index+1])
+			if !unicode.IsUpper(previous) || nextLower {
+				words = append(words, strings.ToLower(string(runes[start:index])))
+				start = index
+			}
+		}
+	}
+	if start < len(runes) {
+		words = append(words, strings.ToLower(string(runes[start:])))
+	}
+
+	return words
+}
+
+// joinWords joins lower case words in a casing
+func joinWords(words []string, casing KeyCase) string {
+	switch casing {
+	case DashCase:
+		return strings.Join(words, "-")
+	case SnakeCase:
+		return strings.Join(words, "_")
+	}
+
+	joined := make([]string, len(words))
+	for index, word := range words {
+		if index == 0 {
+			joined[index] = word
+			continue
+		}
+		first, size := utf8.DecodeRuneInString(word)
+		joined[index] = string(unicode.ToUpper(first)) + word[size:]
+	}
+	return strings.Join(joined, "")
+}
+
+// activeCasing returns the key casing in effect for the resource, if any
+func (res *Resource) activeCasing() *keyCasing {
+	if res.keyCasing != nil {
+		return res.keyCasing
+	}
+	if res.api != nil {
+		return res.api.keyCasing
+	}
+
+	return nil
+}
+
+/*
+encode returns sendable with the attributes of its objects as sent by the
+resources of their types, computed attributes included, copying the objects
+converted. Error documents and other sendables are returned as is.
+*/
+func (res *Resource) encode(ctx context.Context, sendable jsh.Sendable) jsh.Sendable {
+	switch typed := sendable.(type) {
+	case *jsh.Object:
+		encoded, err := res.encodeObject(ctx, typed, nil)
+		if HasError(err) {
+			return err
+		}
+		return encoded
+	case jsh.List:
+		encoded, err := res.encodeList(ctx, typed, nil)
+		if HasError(err) {
+			return err
+		}
+		if sameList(encoded, typed) {
+			return sendable
+		}
+		return encoded
+	case *jsh.Document:
+		if typed.HasErrors() {
+			return sendable
+		}
+
+		data, err := res.encodeList(ctx, typed.Data, nil)
+		if HasError(err) {
+			return err
+		}
+		included, err := res.encodeList(ctx, typed.Included, nil)
+		if HasError(err) {
+			return err
+		}
+		if sameList(data, typed.Data) && sameList(included, typed.Included) {
+			return sendable
+		}
+
+		document := *typed
+		document.Data, document.Included = data, included
+		return &document
+	}
+
+	return sendable
+}
+
+// encodeList returns list with its objects encoded, copied when any is
+func (res *Resource) encodeList(ctx context.Context, list jsh.List, fields fieldsets) (jsh.List, jsh.ErrorType) {
+	var encoded jsh.List
+	for index, object := range list {
+		converted, err := res.encodeObject(ctx, object, fields)
+		if HasError(err) {
+			return nil, err
+		}
+		if converted == object {
+			continue
+		}
+
+		if encoded == nil {
+			encoded = append(jsh.List{}, list...)
+		}
+		encoded[index] = converted
+	}
+
+	if encoded == nil {
+		return list, nil
+	}
+	return encoded, nil
+}
+
+// sameList reports whether an encoded list is the list it was encoded from
+func sameList(encoded jsh.List, list jsh.List) bool {
+	return len(list) == 0 || &encoded[0] == &list[0]
+}
+
+/*
+encodeObject returns a copy of object with its attributes as sent by the resource
+of its type, or object when they are sent as stored. Computed attributes left out
+by fields are not computed, and sealed attributes left out by fields or the field
+policy are not opened.
+*/
+func (res *Resource) encodeObject(ctx context.Context, object *jsh.Object, fields fieldsets) (*jsh.Object, jsh.ErrorType) {
+	if object == nil {
+		return object, nil
+	}
+
+	target := res.linkTarget(object.Type)
+	if target == nil {
+		return object, nil
+	}
+	casing := target.activeCasing()
+	if casing == nil && target.codecs == nil && target.computed == nil && target.sealed == nil {
+		return object, nil
+	}
+
+	var sent map[string]bool
+	if target.sealed != nil {
+		sent = res.sentFields(ctx, object.Type, fields[object.Type])
+	}
+
+	attributes, err := rewriteAttributes(object.Attributes, func(name string, value json.RawMessage) (string, json.RawMessage, error) {
+		if casing != nil {
+			name = casing.encode(name)
+		}
+		if target.computed[name] != nil {
+			// computed values replace stored ones
+			return "", nil, nil
+		}
+
+		sealCodec := target.sealed[name]
+		if sealCodec != nil && !isNull(value) {
+			if sent != nil && !sent[name] {
+				// left out of the response anyway, without opening it
+				return "", nil, nil
+			}
+
+			opened, err := openAttribute(sealCodec, name, value)
+			switch {
+			case err != nil && target.failedOpens == RedactFailedOpens:
+				return "", nil, nil
+			case err != nil:
+				return "", nil, fmt.Errorf("attribute '%s': %s", name, err)
+			}
+			value = opened
+		}
+
+		codec := target.codecs[name]
+		if codec == nil || isNull(value) {
+			return name, value, nil
+		}
+
+		value, err := codec.Encode(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("attribute '%s': %s", name, err)
+		}
+		return name, value, nil
+	})
+	if err != nil {
+		return nil, jsh.ISE(fmt.Sprintf("Unable to encode '%s' attributes: %s", object.Type, err))
+	}
+
+	attributes, computeErr := target.computeAttributes(ctx, object, attributes, fields[object.Type])
+	if computeErr != nil {
+		return nil, computeErr
+	}
+
+	encoded := documentCopy(object)
+	encoded.Attributes = attributes
+	return encoded, nil
+}
+
+// attributeError is a decoding failure of an attribute of an incoming object
+type attributeError struct {
+	name string
+	err  error
+}
+
+func (e *attributeError) Error() string {
+	return e.err.Error()
+}
+
+/*
+decodeAttributes converts the attributes of an incoming object of the resource
+to their stored names and values, in place. Attributes that are not a valid JSON
+object are left for storage to reject.
+*/
+func (res *Resource) decodeAttributes(object *jsh.Object) *jsh.Error {
+	casing := res.activeCasing()
+	if object == nil || (casing == nil && res.codecs == nil) {
+		return nil
+	}
+
+	attributes, err := rewriteAttributes(object.Attributes, func(name string, value json.RawMessage) (string, json.RawMessage, error) {
+		codec := res.codecs[name]
+		if codec != nil && !isNull(value) {
+			decoded, err := codec.Decode(value)
+			if err != nil {
+				return "", nil, &attributeError{name: name, err: err}
+			}
+			value = decoded
+		}
+
+		if casing != nil {
+			name = casing.decode(name)
+		}
+		return name, value, nil
+	})
+
+	invalid, isAttributeErr := err.(*attributeError)
+	if isAttributeErr {
+		decodeErr := &jsh.Error{
+			Title:  "Bad Request",
+			Detail: fmt.Sprintf("Attribute '%s' is invalid: %s", invalid.name, invalid.err),
+			Status: http.StatusBadRequest,
+		}
+		decodeErr.Source.Pointer = "/data/attributes/" + escapePointer(invalid.name)
+		return decodeErr
+	}
+	if err == nil {
+		object.Attributes = attributes
+	}
+
+	return nil
+}
+
+/*
+rewriteAttributes rewrites the members of an attributes object with rewrite,
+keeping their order, members renamed to an empty name being left out. Values are
+scanned rather than decoded, attributes being valid JSON once parsed or marshaled
+by jsh. Attributes that are not an object are returned as is, and the errors of
+rewrite returned.
+*/
+func rewriteAttributes(attributes json.RawMessage, rewrite func(name string, value json.RawMessage) (string, json.RawMessage, error)) (json.RawMessage, error) {
+	data := bytes.TrimSpace(attributes)
+	if len(data) == 0 || data[0] != '{' {
+		return attributes, nil
+	}
+
+	rewritten := bytes.NewBuffer(make([]byte, 0, len(data)+len(data)/8))
+	rewritten.WriteByte('{')
+
+	index := skipSpace(data, 1)
+	if index < len(data) && data[index] == '}' {
+		return append(rewritten.Bytes(), '}'), nil
+	}
+
+	for {
+		if index >= len(data) || data[index] != '"' {
+			return nil, errMalformedAttributes
+		}
+		nameEnd := stringEnd(data, index)
+		if nameEnd < 0 {
+			return nil, errMalformedAttributes
+		}
+		name, err := memberName(data[index:nameEnd])
+		if err != nil {
+			return nil, err
+		}
+
+		index = skipSpace(data, nameEnd)
+		if index >= len(data) || data[index] != ':' {
+			return nil, errMalformedAttributes
+		}
+		index = skipSpace(data, index+1)
+		end := valueEnd(data, index)
+		if end < 0 {
+			return nil, errMalformedAttributes
+		}
+
+		name, value, err := rewrite(name, json.RawMessage(data[index:end]))
+		if err != nil {
+			return nil, err
+		}
+
+		if name != "" {
+			if rewritten.Len() > 1 {
+				rewritten.WriteByte(',')
+			}
+			writeName(rewritten, name)
+			rewritten.WriteByte(':')
+			rewritten.Write(value)
+		}
+
+		index = skipSpace(data, end)
+		if index < len(data) && data[index] == ',' {
+			index = skipSpace(data, index+1)
+			continue
+		}
+		if index < len(data) && data[index] == '}' {
+			break
+		}
+		return nil, errMalformedAttributes
+	}
+	rewritten.WriteByte('}')
+
+	return rewritten.Bytes(), nil
+}
+
+var errMalformedAttributes = errors.New("malformed attributes object")
+
+// memberName returns the name of a quoted member name, unquoting escaped ones
+func memberName(quoted []byte) (string, error) {
+	if bytes.IndexByte(quoted, '\\') < 0 {
+		return string(quoted[1 : len(quoted)-1]), nil
+	}
+
+	var name string
+	err := json.Unmarshal(quoted, &name)
+	return name, err
+}
+
+// skipSpace returns the index of the first non-space byte of data from index
+func skipSpace(data []byte, index int) int {
+	for index < len(data) {
+		switch data[index] {
+		case ' ', '\t', '\r', '\n':
+			index++
+		default:
+			return index
+		}
+	}
+
+	return index
+}
+
+// stringEnd returns the index following the string starting at index, -1 if it
+// is not terminated
+func stringEnd(data []byte, index int) int {
+	for index++; index < len(data); index++ {
+		switch data[index] {
+		case '\\':
+			index++
+		case '"':
+			return index + 1
+		}
+	}
+
+	return -1
+}
+
+// valueEnd returns the index following the value starting at index, -1 if it is
+// not terminated
+func valueEnd(data []byte, index int) int {
+	if index >= len(data) {
+		return -1
+	}
+
+	switch data[index] {
+	case '"':
+		return stringEnd(data, index)
+	case '{', '[':
+		depth := 0
+		for index < len(data) {
+			switch data[index] {
+			case '"':
+				index = stringEnd(data, index)
+				if index < 0 {
+					return -1
+				}
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return index + 1
+				}
+			}
+			index++
+		}
+		return -1
+	}
+
+	for index < len(data) {
+		switch data[index] {
+		case ',', '}', ']', ' ', '\t', '\r', '\n':
+			return index
+		}
+		index++
+	}
+	return index
+}
+
+// writeName writes name as a JSON string, quoting plain ASCII names without
+// marshaling them
+func writeName(buffer *bytes.Buffer, name string) {
+	for index := 0; index < len(name); index++ {
+		char := name[index]
+		if char < 0x20 || char >= utf8.RuneSelf || char == '"' || char == '\\' || char == '<' || char == '>' || char == '&' {
+			quoted, _ := json.Marshal(name)
+			buffer.Write(quoted)
+			return
+		}
+	}
+
+	buffer.WriteByte('"')
+	buffer.WriteString(name)
+	buffer.WriteByte('"')
+}
+
+// isNull reports whether a JSON value is null
+func isNull(value json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+}
@@ -0,0 +1,215 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAttributeCodec(t *testing.T) {
+
+	Convey("Attribute Codec Tests", t, func() {
+
+		attributes := map[string]string{
+			"first_name": "Jo",
+			"created_at": "2020-01-02T03:04:05+02:00",
+			"userID":     "jo",
+		}
+		user := func(id string) *jsh.Object {
+			object, _ := jsh.NewObject(id, "users", attributes)
+			return object
+		}
+
+		stored := user("1")
+		var saved map[string]interface{}
+
+		users := NewResource("users")
+		users.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return stored, nil
+		})
+		users.Patch(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+			saved = map[string]interface{}{}
+			json.Unmarshal(object.Attributes, &saved)
+			return user(object.ID), nil
+		})
+
+		posts := NewResource("posts")
+		posts.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return jsh.NewObject(id, "posts", map[string]string{"post_title": "Hello"})
+		})
+		posts.ToOne("author", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return user("1"), nil
+		})
+
+		api := New("")
+		serve := func(method string, url string, body string) *httptest.ResponseRecorder {
+			if len(api.Resources) == 0 {
+				api.Add(users)
+				api.Add(posts)
+			}
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			if body != "" {
+				request.Header.Set("Content-Type", jsh.ContentType)
+			}
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("->KeyCasing()", func() {
+			users.KeyCasing(DashCase, SnakeCase)
+
+			Convey("should send attribute names in the casing of the API", func() {
+				recorder := serve("GET", "/users/1", "")
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"first-name": "Jo"`)
+				So(recorder.Body.String(), ShouldContainSubstring, `"created-at"`)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "first_name")
+			})
+
+			Convey("should keep names that can't be converted back", func() {
+				recorder := serve("GET", "/users/1", "")
+				So(recorder.Body.String(), ShouldContainSubstring, `"userID": "jo"`)
+			})
+
+			Convey("should leave the objects of storage untouched", func() {
+				serve("GET", "/users/1", "")
+				So(string(stored.Attributes), ShouldContainSubstring, "first_name")
+			})
+
+			Convey("should store received names in the casing of storage", func() {
+				body := `{"data": {"type": "users", "id": "1", "attributes": {"first-name": "Al", "userID": "al"}}}`
+				recorder := serve("PATCH", "/users/1", body)
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(saved, ShouldResemble, map[string]interface{}{"first_name": "Al", "userID": "al"})
+				So(recorder.Body.String(), ShouldContainSubstring, `"first-name"`)
+			})
+
+			Convey("should convert included objects of the type", func() {
+				recorder := serve("GET", "/posts/1?include=author", "")
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"first-name": "Jo"`)
+				So(recorder.Body.String(), ShouldContainSubstring, `"post_title"`)
+			})
+
+			Convey("should select sparse fieldsets by sent name", func() {
+				recorder := serve("GET", "/users/1?fields[users]=first-name", "")
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"first-name"`)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "created")
+			})
+
+			Convey("should convert atomic operations", func() {
+				serve("GET", "/users/1", "")
+				api.AtomicOperations("operations")
+
+				recorder := httptest.NewRecorder()
+				request := httptest.NewRequest("POST", "/operations", strings.NewReader(`{"atomic:operations": [
+					{"op": "update", "data": {"type": "users", "id": "1", "attributes": {"first-name": "Al"}}}
+				]}`))
+				request.Header.Set("Content-Type", AtomicContentType)
+				api.ServeHTTP(recorder, request)
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(saved, ShouldResemble, map[string]interface{}{"first_name": "Al"})
+				So(recorder.Body.String(), ShouldContainSubstring, `"first-name": "Jo"`)
+			})
+
+			Convey("should take precedence over the casing of the API", func() {
+				api.KeyCasing(CamelCase, SnakeCase)
+
+				So(serve("GET", "/users/1", "").Body.String(), ShouldContainSubstring, `"first-name"`)
+				So(serve("GET", "/posts/1", "").Body.String(), ShouldContainSubstring, `"postTitle"`)
+			})
+		})
+
+		Convey("->AttributeCodec()", func() {
+			users.KeyCasing(DashCase, SnakeCase)
+			users.AttributeCodec("created-at", UTCTime)
+
+			Convey("should encode sent values", func() {
+				recorder := serve("GET", "/users/1", "")
+				So(recorder.Body.String(), ShouldContainSubstring, `"created-at": "2020-01-02T01:04:05Z"`)
+			})
+
+			Convey("should decode received values", func() {
+				body := `{"data": {"type": "users", "id": "1", "attributes": {"created-at": "2020-01-02T03:04:05-01:00"}}}`
+				recorder := serve("PATCH", "/users/1", body)
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(saved, ShouldResemble, map[string]interface{}{"created_at": "2020-01-02T04:04:05Z"})
+			})
+
+			Convey("should leave null values as they are", func() {
+				body := `{"data": {"type": "users", "id": "1", "attributes": {"created-at": null}}}`
+				recorder := serve("PATCH", "/users/1", body)
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(saved, ShouldResemble, map[string]interface{}{"created_at": nil})
+			})
+
+			Convey("should reject values that fail to decode", func() {
+				body := `{"data": {"type": "users", "id": "1", "attributes": {"created-at": "yesterday"}}}`
+				recorder := serve("PATCH", "/users/1", body)
+
+				So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+				So(recorder.Body.String(), ShouldContainSubstring, `"pointer": "/data/attributes/created-at"`)
+				So(saved, ShouldBeNil)
+			})
+
+			Convey("should fail responses whose values fail to encode", func() {
+				attributes["created_at"] = "yesterday"
+				stored = user("1")
+
+				So(serve("GET", "/users/1", "").Code, ShouldEqual, http.StatusInternalServerError)
+			})
+
+			Convey("should panic without a field or a codec", func() {
+				So(func() { users.AttributeCodec("", UTCTime) }, ShouldPanicWith,
+					"jshapi: attribute codec of 'users' requires a field and a codec")
+				So(func() { users.AttributeCodec("born-at", nil) }, ShouldPanic)
+			})
+		})
+
+		Convey("should convert names reversibly", func() {
+			So(convertCase("created_at", DashCase, SnakeCase), ShouldEqual, "created-at")
+			So(convertCase("created-at", SnakeCase, DashCase), ShouldEqual, "created_at")
+			So(convertCase("createdAt", SnakeCase, CamelCase), ShouldEqual, "created_at")
+			So(convertCase("created_at", CamelCase, SnakeCase), ShouldEqual, "createdAt")
+			So(convertCase("HTTPServer", SnakeCase, CamelCase), ShouldEqual, "HTTPServer")
+			So(convertCase("_id", DashCase, SnakeCase), ShouldEqual, "_id")
+			So(splitWords("HTTPServer_port2"), ShouldResemble, []string{"http", "server", "port2"})
+		})
+
+		Convey("should rewrite attributes keeping their values and order", func() {
+			rename := func(name string, value json.RawMessage) (string, json.RawMessage, error) {
+				return "<" + name + ">", value, nil
+			}
+
+			attributes := json.RawMessage(` { "b_2" : {"x": ["}", "\"", 1]}, "a\u0041": null ,"c":true } `)
+			rewritten, err := rewriteAttributes(attributes, rename)
+
+			So(err, ShouldBeNil)
+			So(string(rewritten), ShouldEqual, `{"\u003cb_2\u003e":{"x": ["}", "\"", 1]},"\u003caA\u003e":null,"\u003cc\u003e":true}`)
+
+			_, err = rewriteAttributes(json.RawMessage(`{"a": "b"`), rename)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("should panic on unknown casings", func() {
+			So(func() { users.KeyCasing(KeyCase(0), SnakeCase) }, ShouldPanicWith, "jshapi: unknown key casing 0")
+		})
+	})
+}
@@ -0,0 +1,132 @@
+package jshapi
+
+import (
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// DefaultAuditQueueSize is the number of audit events that can be pending before
+// new ones are dropped
+const DefaultAuditQueueSize = 1024
+
+/*
+AuditEvent records a successful create, update, or delete.
+*/
+type AuditEvent struct {
+	Operation    Operation
+	ResourceType string
+	ID           string
+	// Received is the object as received from the client, nil for deletes
+	Received *jsh.Object
+	// Returned is the object as returned by storage, nil for deletes
+	Returned *jsh.Object
+	// RequestID is set when the RequestID middleware is in use
+	RequestID string
+	// Principal is extracted by the IdentityFunc, if any
+	Principal string
+	Time      time.Time
+}
+
+// IdentityFunc extracts the principal performing a request
+type IdentityFunc func(ctx context.Context) string
+
+/*
+SetAuditor installs the hook receiving an AuditEvent for every successful
+mutation, once storage has succeeded and a 2XX response is being sent. The hook
+runs asynchronously: events are queued, and dropped when DefaultAuditQueueSize
+events are already pending so that a slow audit sink never delays responses, see
+AuditDropped. The context passed to the hook is the one of the request, it may
+have been canceled by then.
+*/
+func (a *API) SetAuditor(auditor func(ctx context.Context, event AuditEvent)) {
+	a.checkRegistration("an auditor")
+
+	a.audit = newAuditQueue(auditor, DefaultAuditQueueSize)
+}
+
+// SetIdentityFunc installs the function extracting the principal of audit events
+func (a *API) SetIdentityFunc(identity IdentityFunc) {
+	a.checkRegistration("an identity function")
+
+	a.identity = identity
+}
+
+// AuditDropped returns the number of audit events dropped because the queue was
+// full
+func (a *API) AuditDropped() uint64 {
+	if a.audit == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&a.audit.dropped)
+}
+
+// auditQueue delivers audit events to the auditor from a single goroutine
+type auditQueue struct {
+	events  chan queuedAudit
+	dropped uint64
+}
+
+type queuedAudit struct {
+	ctx   context.Context
+	event AuditEvent
+}
+
+func newAuditQueue(auditor func(ctx context.Context, event AuditEvent), size int) *auditQueue {
+	queue := &auditQueue{events: make(chan queuedAudit, size)}
+
+	go func() {
+		for queued := range queue.events {
+			auditor(queued.ctx, queued.event)
+		}
+	}()
+
+	return queue
+}
+
+// push queues an event without blocking, counting it as dropped if the queue is
+// full
+func (q *auditQueue) push(ctx context.Context, event AuditEvent) {
+	select {
+	case q.events <- queuedAudit{ctx: ctx, event: event}:
+	default:
+		atomic.AddUint64(&q.dropped, 1)
+	}
+}
+
+// audit queues the AuditEvent of a successful mutation if an auditor is installed,
+// and its ResourceEvent if an event sink is
+func (res *Resource) audit(ctx context.Context, op Operation, id string, received *jsh.Object, returned *jsh.Object) {
+	if res.api == nil {
+		return
+	}
+
+	if id == "" && returned != nil {
+		id = returned.ID
+	}
+
+	res.publish(ctx, op, id, returned)
+	if res.api.audit == nil {
+		return
+	}
+
+	event := AuditEvent{
+		Operation:    op,
+		ResourceType: res.Type,
+		ID:           id,
+		Received:     received,
+		Returned:     returned,
+		RequestID:    GetRequestID(ctx),
+		Time:         time.Now(),
+	}
+
+	if res.api.identity != nil {
+		event.Principal = res.api.identity(ctx)
+	}
+
+	res.api.audit.push(ctx, event)
+}
@@ -0,0 +1,87 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAudit(t *testing.T) {
+
+	Convey("Audit Tests", t, func() {
+
+		events := make(chan AuditEvent, 10)
+
+		api := New("")
+		api.UseC(RequestID())
+		api.SetAuditor(func(ctx context.Context, event AuditEvent) {
+			events <- event
+		})
+		api.SetIdentityFunc(func(ctx context.Context) string {
+			return "alice"
+		})
+		api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/" + testResourceType
+
+		Convey("should audit successful mutations", func() {
+			resp, _ := negotiationRequest("PATCH", url+"/1", jsh.ContentType, "", `{"data": {"type": "bars", "id": "1", "attributes": {"foo": "bar"}}}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			event := <-events
+			So(event.Operation, ShouldEqual, OpUpdate)
+			So(event.ResourceType, ShouldEqual, testResourceType)
+			So(event.ID, ShouldEqual, "1")
+			So(event.Received, ShouldNotBeNil)
+			So(event.Returned, ShouldNotBeNil)
+			So(event.Principal, ShouldEqual, "alice")
+			So(event.RequestID, ShouldEqual, resp.Header.Get(RequestIDHeader))
+
+			resp, _ = negotiationRequest("DELETE", url+"/1", "", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+
+			event = <-events
+			So(event.Operation, ShouldEqual, OpDelete)
+			So(event.ID, ShouldEqual, "1")
+			So(event.Returned, ShouldBeNil)
+		})
+
+		Convey("should not audit failed mutations", func() {
+			resp, _ := negotiationRequest("POST", url, jsh.ContentType, "", `{"data": {"type": "foos", "attributes": {"foo": "bar"}}}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+
+			select {
+			case <-events:
+				So("unexpected audit event", ShouldBeEmpty)
+			case <-time.After(20 * time.Millisecond):
+			}
+		})
+
+		Convey("->auditQueue", func() {
+
+			Convey("should drop events when full instead of blocking", func() {
+				release := make(chan struct{})
+				queue := newAuditQueue(func(ctx context.Context, event AuditEvent) {
+					<-release
+				}, 1)
+
+				for i := 0; i < 5; i++ {
+					queue.push(context.Background(), AuditEvent{})
+				}
+				close(release)
+
+				// one event is being audited and one is queued
+				So(queue.dropped, ShouldBeBetweenOrEqual, 3, 4)
+			})
+		})
+	})
+}
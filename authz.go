@@ -0,0 +1,144 @@
+package jshapi
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// Operation identifies the kind of request being handled by a resource
+type Operation string
+
+const (
+	// OpCreate is a POST /resources request
+	OpCreate Operation = "create"
+	// OpRead is a GET /resources/:id request
+	OpRead Operation = "read"
+	// OpList is a GET /resources request
+	OpList Operation = "list"
+	// OpUpdate is a PATCH /resources/:id request
+	OpUpdate Operation = "update"
+	// OpDelete is a DELETE /resources/:id request
+	OpDelete Operation = "delete"
+	// OpRelationship is a GET /resources/:id/(relationships/)<type> request
+	OpRelationship Operation = "relationship"
+	// OpAction is a GET /resources/:id/<action> request
+	OpAction Operation = "action"
+	// OpInclude is the inclusion of a relationship in a compound document, see
+	// DenyIncludes
+	OpInclude Operation = "include"
+)
+
+/*
+Authorizer is the single place to enforce authorization of API requests. It is
+called once the route has been matched, before the request body is parsed or
+storage is touched. Any returned error is sent to the client as is, typically a
+401 or 403:
+
+	func (a *auth) Authorize(ctx context.Context, r *http.Request, op jshapi.Operation, resourceType string, id string) jsh.ErrorType {
+		if op != jshapi.OpRead && op != jshapi.OpList && !isAdmin(r) {
+			return &jsh.Error{Title: "Forbidden", Status: http.StatusForbidden}
+		}
+		return nil
+	}
+
+id is empty for operations that do not target a specific object.
+*/
+type Authorizer interface {
+	Authorize(ctx context.Context, r *http.Request, op Operation, resourceType string, id string) jsh.ErrorType
+}
+
+/*
+ObjectAuthorizer can optionally be implemented by an Authorizer to run checks
+that depend on the loaded object. It is called after storage returns the object of
+a single object read, and before it is sent to the client.
+*/
+type ObjectAuthorizer interface {
+	AuthorizeObject(ctx context.Context, r *http.Request, op Operation, object *jsh.Object) jsh.ErrorType
+}
+
+// AuthorizerFunc allows the use of an ordinary function as an Authorizer
+type AuthorizerFunc func(ctx context.Context, r *http.Request, op Operation, resourceType string, id string) jsh.ErrorType
+
+// Authorize implements Authorizer
+func (f AuthorizerFunc) Authorize(ctx context.Context, r *http.Request, op Operation, resourceType string, id string) jsh.ErrorType {
+	return f(ctx, r, op, resourceType, id)
+}
+
+/*
+SetAuthorizer installs the Authorizer used by all resources of the API that do not
+have their own.
+*/
+func (a *API) SetAuthorizer(authorizer Authorizer) {
+	a.checkRegistration("an authorizer")
+
+	a.authorizer = authorizer
+}
+
+/*
+SetAuthorizer installs an Authorizer for the resource, taking precedence over the
+one of the API.
+*/
+func (res *Resource) SetAuthorizer(authorizer Authorizer) {
+	res.checkRegistration("an authorizer")
+
+	res.authorizer = authorizer
+}
+
+// CurrentOperation returns the operation of the resource request being handled,
+// or an empty string outside of resource routes
+func CurrentOperation(ctx context.Context) Operation {
+	info, _ := RouteInfoFromContext(ctx)
+	return info.Operation
+}
+
+// activeAuthorizer returns the authorizer in effect for the resource, if any
+func (res *Resource) activeAuthorizer() Authorizer {
+	if res.authorizer != nil {
+		return res.authorizer
+	}
+
+	if res.api != nil {
+		return res.api.authorizer
+	}
+
+	return nil
+}
+
+// authorizeRequest runs the active authorizer for an operation on the resource
+func (res *Resource) authorizeRequest(ctx context.Context, r *http.Request, op Operation, id string) jsh.ErrorType {
+	authorizer := res.activeAuthorizer()
+	if authorizer == nil {
+		return nil
+	}
+
+	err := authorizer.Authorize(ctx, r, op, res.Type, id)
+	if HasError(err) {
+		return err
+	}
+
+	return nil
+}
+
+// authorizeObject runs the object level hook of the active authorizer, if any
+func (res *Resource) authorizeObject(ctx context.Context, r *http.Request, object *jsh.Object) jsh.ErrorType {
+	return res.authorizeObjectFor(ctx, r, CurrentOperation(ctx), object)
+}
+
+// authorizeObjectFor runs the object level hook of the active authorizer for an
+// operation, if any
+func (res *Resource) authorizeObjectFor(ctx context.Context, r *http.Request, op Operation, object *jsh.Object) jsh.ErrorType {
+	objectAuthorizer, implemented := res.activeAuthorizer().(ObjectAuthorizer)
+	if !implemented || object == nil {
+		return nil
+	}
+
+	err := objectAuthorizer.AuthorizeObject(ctx, r, op, object)
+	if HasError(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testAuthorizer records authorization calls and denies the configured operation
+type testAuthorizer struct {
+	deny       Operation
+	denyObject string
+	calls      []Operation
+	ids        []string
+}
+
+func (a *testAuthorizer) Authorize(ctx context.Context, r *http.Request, op Operation, resourceType string, id string) jsh.ErrorType {
+	a.calls = append(a.calls, op)
+	a.ids = append(a.ids, id)
+
+	if op == a.deny {
+		return &jsh.Error{Title: "Forbidden", Status: http.StatusForbidden}
+	}
+	return nil
+}
+
+func (a *testAuthorizer) AuthorizeObject(ctx context.Context, r *http.Request, op Operation, object *jsh.Object) jsh.ErrorType {
+	if object.ID == a.denyObject {
+		return &jsh.Error{Title: "Not Found", Status: http.StatusNotFound}
+	}
+	return nil
+}
+
+func TestAuthorizer(t *testing.T) {
+
+	Convey("Authorizer Tests", t, func() {
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+		resource.ToOne("foo", (&MockStorage{ResourceType: "foo", ResourceAttributes: testObjAttrs}).Get)
+
+		api := New("")
+		api.Add(resource)
+
+		authorizer := &testAuthorizer{}
+		api.SetAuthorizer(authorizer)
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/" + testResourceType
+
+		Convey("should pass the operation and id of each route", func() {
+			negotiationRequest("GET", url, "", "", "")
+			negotiationRequest("GET", url+"/1", "", "", "")
+			negotiationRequest("GET", url+"/1/relationships/foo", "", "", "")
+			negotiationRequest("DELETE", url+"/1", "", "", "")
+
+			So(authorizer.calls, ShouldResemble, []Operation{OpList, OpRead, OpRelationship, OpDelete})
+			So(authorizer.ids, ShouldResemble, []string{"", "1", "1", "1"})
+		})
+
+		Convey("should send authorization errors before parsing the body", func() {
+			authorizer.deny = OpCreate
+
+			resp, _ := negotiationRequest("POST", url, jsh.ContentType, "", `{invalid`)
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("should run object checks after loading a read", func() {
+			authorizer.denyObject = "1"
+
+			resp, _ := negotiationRequest("GET", url+"/1", "", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("should prefer the resource authorizer", func() {
+			var current Operation
+			resource.SetAuthorizer(AuthorizerFunc(func(ctx context.Context, r *http.Request, op Operation, resourceType string, id string) jsh.ErrorType {
+				current = CurrentOperation(ctx)
+				return &jsh.Error{Title: "Unauthorized", Status: http.StatusUnauthorized}
+			}))
+
+			resp, _ := negotiationRequest("GET", url, "", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+			So(current, ShouldEqual, OpList)
+			So(authorizer.calls, ShouldBeEmpty)
+		})
+	})
+}
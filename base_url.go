@@ -0,0 +1,185 @@
+package jshapi
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+/*
+BaseURL sets the public scheme and host, such as "https://api.example.com", of
+every link generated by the API: Location headers, self and relationship links,
+and the Link header of deprecated aliases. It takes precedence over TrustProxies,
+an empty url restores proxy detection, or relative links.
+*/
+func (a *API) BaseURL(url string) {
+	a.checkRegistration("a base URL")
+
+	a.baseURL = strings.TrimSuffix(url, "/")
+}
+
+/*
+TrustProxies derives the base URL of links from the headers set by reverse
+proxies, for requests accepted by trusted, when no BaseURL is set: the proto and
+host of the Forwarded header, or the X-Forwarded-Proto, X-Forwarded-Host and
+X-Forwarded-Port headers, falling back to the request itself. Only the first of
+comma separated values is used, the one set by the proxy closest to the client.
+Requests rejected by trusted, which could spoof the host of links, get relative
+links instead. See TrustedProxies for a predicate matching proxy addresses.
+*/
+func (a *API) TrustProxies(trusted func(r *http.Request) bool) {
+	a.checkRegistration("trusted proxies")
+
+	a.trustedProxy = trusted
+}
+
+// TrustProxyHeaders trusts the proxy headers of every request, or none, see
+// TrustProxies
+func (a *API) TrustProxyHeaders(trust bool) {
+	if !trust {
+		a.TrustProxies(nil)
+		return
+	}
+
+	a.TrustProxies(func(r *http.Request) bool { return true })
+}
+
+/*
+TrustedProxies returns a TrustProxies predicate accepting requests whose remote
+address belongs to one of the networks given in CIDR notation, such as
+"10.0.0.0/8", or is one of the given IP addresses. Panics on invalid networks.
+*/
+func TrustedProxies(networks ...string) func(r *http.Request) bool {
+	trusted := make([]*net.IPNet, len(networks))
+	for index, network := range networks {
+		if !strings.Contains(network, "/") {
+			ip := net.ParseIP(network)
+			if ip == nil {
+				panic(fmt.Sprintf("jshapi: invalid trusted proxy address '%s'", network))
+			}
+			trusted[index] = &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+			continue
+		}
+
+		_, parsed, err := net.ParseCIDR(network)
+		if err != nil {
+			panic(fmt.Sprintf("jshapi: invalid trusted proxy network '%s': %s", network, err.Error()))
+		}
+		trusted[index] = parsed
+	}
+
+	return func(r *http.Request) bool {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false
+		}
+
+		for _, network := range trusted {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// linkBase is the scheme and host prefixed to every link generated for a
+// request, empty for relative links
+func (a *API) linkBase(r *http.Request) string {
+	if a.baseURL != "" {
+		return a.baseURL
+	}
+	if a.trustedProxy == nil || !a.trustedProxy(r) {
+		return ""
+	}
+
+	forwarded := forwardedParams(r.Header.Get("Forwarded"))
+
+	scheme := forwarded["proto"]
+	if scheme == "" {
+		scheme = firstHeaderValue(r, "X-Forwarded-Proto")
+	}
+	scheme = strings.ToLower(scheme)
+	if scheme != "http" && scheme != "https" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+
+	host := forwarded["host"]
+	if host == "" {
+		host = firstHeaderValue(r, "X-Forwarded-Host")
+		port := firstHeaderValue(r, "X-Forwarded-Port")
+		if host != "" && port != "" && !hasPort(host) && port != defaultPort(scheme) {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+		}
+	}
+	if host == "" || strings.ContainsAny(host, "/\\@ ?#") {
+		host = r.Host
+	}
+
+	return scheme + "://" + host
+}
+
+// linkBase is the base URL of the links of a request, see API.linkBase, followed
+// by the tenant segment of tenanted APIs
+func (res *Resource) linkBase(r *http.Request) string {
+	if res.api == nil {
+		return ""
+	}
+
+	return res.api.linkBase(r) + tenantPath(r)
+}
+
+// forwardedParams returns the parameters of the first element of a Forwarded
+// header, by lowercase name, with quoted values unquoted
+func forwardedParams(header string) map[string]string {
+	params := map[string]string{}
+	if header == "" {
+		return params
+	}
+
+	first := strings.Split(header, ",")[0]
+	for _, pair := range strings.Split(first, ";") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		value := strings.TrimSpace(parts[1])
+		if len(value) > 1 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = strings.Replace(value[1:len(value)-1], `\"`, `"`, -1)
+		}
+		params[strings.ToLower(parts[0])] = value
+	}
+
+	return params
+}
+
+// firstHeaderValue returns the first of the comma separated values of a header,
+// the one set by the proxy closest to the client
+func firstHeaderValue(r *http.Request, header string) string {
+	return strings.TrimSpace(strings.Split(r.Header.Get(header), ",")[0])
+}
+
+// hasPort reports whether a host, possibly an IPv6 literal, carries a port
+func hasPort(host string) bool {
+	return strings.LastIndex(host, ":") > strings.LastIndex(host, "]")
+}
+
+// defaultPort is the port implied by a scheme
+func defaultPort(scheme string) string {
+	if scheme == "https" {
+		return "443"
+	}
+
+	return "80"
+}
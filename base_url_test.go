@@ -0,0 +1,127 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBaseURL(t *testing.T) {
+
+	Convey("Base URL Tests", t, func() {
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+		api := New("api")
+		api.Add(resource)
+
+		base := func(remoteAddr string, headers map[string]string) string {
+			request := httptest.NewRequest("GET", "http://internal:8080/api/bars", nil)
+			request.RemoteAddr = remoteAddr
+			for name, value := range headers {
+				request.Header.Set(name, value)
+			}
+			return api.linkBase(request)
+		}
+
+		Convey("should produce relative links by default", func() {
+			So(base("10.0.0.1:1234", map[string]string{"X-Forwarded-Host": "evil.com"}), ShouldEqual, "")
+		})
+
+		Convey("->BaseURL()", func() {
+			api.TrustProxyHeaders(true)
+			api.BaseURL("https://api.example.com/")
+
+			Convey("should take precedence over proxy headers", func() {
+				So(base("10.0.0.1:1234", map[string]string{"X-Forwarded-Host": "proxy.example.com"}), ShouldEqual, "https://api.example.com")
+			})
+
+			Convey("should prefix Location headers", func() {
+				recorder := httptest.NewRecorder()
+				request := httptest.NewRequest("POST", "/api/bars", strings.NewReader(`{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`))
+				request.Header.Set("Content-Type", jsh.ContentType)
+				api.ServeHTTP(recorder, request)
+
+				So(recorder.Code, ShouldEqual, http.StatusCreated)
+				So(recorder.Header().Get("Location"), ShouldStartWith, "https://api.example.com/api/bars/")
+			})
+		})
+
+		Convey("->TrustProxies()", func() {
+			api.TrustProxies(TrustedProxies("10.0.0.0/8", "192.168.1.1"))
+
+			Convey("should ignore untrusted clients", func() {
+				So(base("203.0.113.7:1234", map[string]string{"X-Forwarded-Host": "evil.com"}), ShouldEqual, "")
+			})
+
+			Convey("should trust single addresses", func() {
+				So(base("192.168.1.1:1234", map[string]string{"X-Forwarded-Host": "api.example.com"}), ShouldEqual, "http://api.example.com")
+			})
+
+			Convey("should fall back to the request without headers", func() {
+				So(base("10.0.0.1:1234", nil), ShouldEqual, "http://internal:8080")
+			})
+
+			Convey("should use the Forwarded header", func() {
+				So(base("10.0.0.1:1234", map[string]string{
+					"Forwarded":         `for=203.0.113.7;proto=https;host="api.example.com:8443", for=10.0.0.2;proto=http;host=internal`,
+					"X-Forwarded-Host":  "ignored.example.com",
+					"X-Forwarded-Proto": "http",
+				}), ShouldEqual, "https://api.example.com:8443")
+			})
+
+			Convey("should use the first of comma separated values", func() {
+				So(base("10.0.0.1:1234", map[string]string{
+					"X-Forwarded-Proto": "https, http",
+					"X-Forwarded-Host":  "api.example.com, internal:8080",
+				}), ShouldEqual, "https://api.example.com")
+			})
+
+			Convey("should keep non-standard ports", func() {
+				So(base("10.0.0.1:1234", map[string]string{
+					"X-Forwarded-Proto": "https",
+					"X-Forwarded-Host":  "api.example.com",
+					"X-Forwarded-Port":  "8443",
+				}), ShouldEqual, "https://api.example.com:8443")
+
+				So(base("10.0.0.1:1234", map[string]string{
+					"X-Forwarded-Proto": "https",
+					"X-Forwarded-Host":  "api.example.com",
+					"X-Forwarded-Port":  "443",
+				}), ShouldEqual, "https://api.example.com")
+
+				So(base("10.0.0.1:1234", map[string]string{
+					"X-Forwarded-Host": "api.example.com:8080",
+					"X-Forwarded-Port": "9090",
+				}), ShouldEqual, "http://api.example.com:8080")
+			})
+
+			Convey("should ignore invalid values", func() {
+				So(base("10.0.0.1:1234", map[string]string{
+					"X-Forwarded-Proto": "javascript",
+					"X-Forwarded-Host":  "evil.com/path",
+				}), ShouldEqual, "http://internal:8080")
+			})
+
+			Convey("should prefix deprecated alias links", func() {
+				resource.DeprecatedAlias("legacy")
+
+				recorder := httptest.NewRecorder()
+				request := httptest.NewRequest("GET", "/api/legacy/1", nil)
+				request.RemoteAddr = "10.0.0.1:1234"
+				request.Header.Set("X-Forwarded-Host", "api.example.com")
+				api.ServeHTTP(recorder, request)
+
+				So(recorder.Header().Get("Link"), ShouldEqual, `<http://api.example.com/api/bars/1>; rel="successor-version"`)
+			})
+		})
+
+		Convey("->TrustedProxies()", func() {
+			So(func() { TrustedProxies("10.0.0.0/33") }, ShouldPanic)
+			So(func() { TrustedProxies("proxy") }, ShouldPanic)
+		})
+	})
+}
@@ -0,0 +1,159 @@
+package jshapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store/memstore"
+)
+
+// benchmarkAPI serves a "bars" resource backed by a memstore holding count objects
+func benchmarkAPI(b *testing.B, count int) *API {
+	return benchmarkAPIWith(b, count, testObjAttrs)
+}
+
+// benchmarkAPIWith serves a "bars" resource backed by a memstore holding count
+// objects with the given attributes
+func benchmarkAPIWith(b *testing.B, count int, attributes map[string]string) *API {
+	api := New("")
+	api.Add(NewCRUDResource(testResourceType, benchmarkStorage(b, count, attributes)))
+
+	return api
+}
+
+// benchmarkStorage returns a memstore holding count "bars" with the given attributes
+func benchmarkStorage(b *testing.B, count int, attributes map[string]string) *memstore.Store {
+	storage := memstore.New(testResourceType)
+	for i := 0; i < count; i++ {
+		object, err := jsh.NewObject("", testResourceType, attributes)
+		if err != nil {
+			b.Fatal(err)
+		}
+		storage.Save(context.Background(), object)
+	}
+
+	return storage
+}
+
+// benchmarkRequest serves the same request repeatedly, failing on unexpected status
+func benchmarkRequest(b *testing.B, api *API, method string, url string, body []byte, status int) {
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		request := httptest.NewRequest(method, url, bytes.NewReader(body))
+		request.Header.Set("Content-Type", jsh.ContentType)
+
+		recorder := httptest.NewRecorder()
+		api.ServeHTTP(recorder, request)
+
+		if recorder.Code != status {
+			b.Fatalf("%s %s: expected status %d, got %d\n%s", method, url, status, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	benchmarkRequest(b, benchmarkAPI(b, 1), "GET", "/bars/1", nil, http.StatusOK)
+}
+
+func BenchmarkList(b *testing.B) {
+	benchmarkRequest(b, benchmarkAPI(b, 20), "GET", "/bars", nil, http.StatusOK)
+}
+
+// BenchmarkListLarge sends a list response of about 1MB, dominated by serialization
+func BenchmarkListLarge(b *testing.B) {
+	attributes := map[string]string{"foo": "bar", "description": strings.Repeat("lorem ipsum ", 40)}
+	benchmarkRequest(b, benchmarkAPIWith(b, 2000, attributes), "GET", "/bars", nil, http.StatusOK)
+}
+
+func BenchmarkPost(b *testing.B) {
+	body := []byte(`{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`)
+	benchmarkRequest(b, benchmarkAPI(b, 0), "POST", "/bars", body, http.StatusCreated)
+}
+
+// BenchmarkRoute serves a custom route doing no work, measuring the overhead of
+// routing and route handling alone
+func BenchmarkRoute(b *testing.B) {
+	resource := NewResource(testResourceType)
+	resource.HandleFuncC(pat.Get("/:id/ping"), resource.Wrap("ping", "/:id/ping",
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		},
+	))
+
+	api := New("")
+	api.Add(resource)
+
+	benchmarkRequest(b, api, "GET", "/bars/1/ping", nil, http.StatusNoContent)
+}
+
+// benchmarkRawAPI serves a "bars" list of about 100KB from storage at /bars, and
+// the same document already serialized as a RawDocument at /bars/raw
+func benchmarkRawAPI(b *testing.B) *API {
+	attributes := map[string]string{"foo": "bar", "description": strings.Repeat("lorem ipsum ", 80)}
+	storage := benchmarkStorage(b, 100, attributes)
+
+	listAPI := New("")
+	listAPI.Add(NewCRUDResource(testResourceType, storage))
+
+	recorder := httptest.NewRecorder()
+	listAPI.ServeHTTP(recorder, httptest.NewRequest("GET", "/bars", nil))
+	raw := &RawDocument{Body: recorder.Body.Bytes()}
+
+	resource := NewCRUDResource(testResourceType, storage)
+	resource.HandleFuncC(pat.Get("/raw"), resource.Wrap(OpList, "/raw",
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			resource.send(ctx, w, r, raw)
+		},
+	))
+
+	api := New("")
+	api.Add(resource)
+
+	return api
+}
+
+// BenchmarkList100KB sends a list of about 100KB built from storage objects
+func BenchmarkList100KB(b *testing.B) {
+	benchmarkRequest(b, benchmarkRawAPI(b), "GET", "/bars", nil, http.StatusOK)
+}
+
+// BenchmarkRaw100KB sends the same list as BenchmarkList100KB as a RawDocument
+func BenchmarkRaw100KB(b *testing.B) {
+	benchmarkRequest(b, benchmarkRawAPI(b), "GET", "/bars/raw", nil, http.StatusOK)
+}
+
+// benchmarkCasedAPI serves "bars" whose snake_case attributes are sent dash-cased,
+// with a time attribute sent in UTC
+func benchmarkCasedAPI(b *testing.B, count int) *API {
+	attributes := map[string]string{"first_name": "bar", "last_name": "baz", "created_at": "2020-01-02T03:04:05+02:00"}
+
+	resource := NewCRUDResource(testResourceType, benchmarkStorage(b, count, attributes))
+	resource.KeyCasing(DashCase, SnakeCase)
+	resource.AttributeCodec("created-at", UTCTime)
+
+	api := New("")
+	api.Add(resource)
+	return api
+}
+
+func BenchmarkGetCased(b *testing.B) {
+	benchmarkRequest(b, benchmarkCasedAPI(b, 1), "GET", "/bars/1", nil, http.StatusOK)
+}
+
+func BenchmarkListCased(b *testing.B) {
+	benchmarkRequest(b, benchmarkCasedAPI(b, 20), "GET", "/bars", nil, http.StatusOK)
+}
+
+func BenchmarkPatchCased(b *testing.B) {
+	body := []byte(`{"data": {"type": "bars", "id": "1", "attributes": {"first-name": "foo", "created-at": "2020-01-02T03:04:05Z"}}}`)
+	benchmarkRequest(b, benchmarkCasedAPI(b, 1), "PATCH", "/bars/1", body, http.StatusOK)
+}
@@ -0,0 +1,153 @@
+package jshapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// DefaultMaxBodyBytes is the request body size limit used when neither the API
+// nor the resource have been configured with one.
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// inheritBodyLimit signals that a resource uses the body size limit of its API
+const inheritBodyLimit int64 = -1
+
+/*
+MaxBodyBytes sets the maximum number of bytes a request body may contain before the
+API rejects it with a 413 error. A limit of 0 disables the check entirely.
+Resources can override this value via Resource.MaxBodyBytes().
+*/
+func (a *API) MaxBodyBytes(n int64) {
+	a.checkRegistration("a body size limit")
+
+	a.maxBodyBytes = n
+}
+
+/*
+MaxBodyBytes overrides the API wide request body size limit for this resource,
+useful for resources that legitimately receive large payloads. A limit of 0
+disables the check entirely.
+*/
+func (res *Resource) MaxBodyBytes(n int64) {
+	res.checkRegistration("a body size limit")
+
+	res.maxBodyBytes = n
+}
+
+// bodyLimit returns the effective request body size limit for the resource
+func (res *Resource) bodyLimit() int64 {
+	if res.maxBodyBytes != inheritBodyLimit {
+		return res.maxBodyBytes
+	}
+
+	if res.api != nil {
+		return res.api.maxBodyBytes
+	}
+
+	return DefaultMaxBodyBytes
+}
+
+// readBody buffers the request body while enforcing the resource body size limit,
+// then rewinds r.Body so that it can be parsed as usual.
+func (res *Resource) readBody(w http.ResponseWriter, r *http.Request) ([]byte, *jsh.Error) {
+	return readBody(w, r, res.bodyLimit())
+}
+
+// readBody buffers the request body while enforcing the provided size limit
+func readBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, *jsh.Error) {
+	body := r.Body
+	if limit > 0 {
+		body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	defer body.Close()
+
+	raw, err := ioutil.ReadAll(body)
+	if err != nil {
+		if _, tooLarge := err.(*http.MaxBytesError); tooLarge {
+			return nil, bodyTooLarge(limit)
+		}
+
+		return nil, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: "Unable to read request body",
+			Status: http.StatusBadRequest,
+			ISE:    err.Error(),
+		}
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	return raw, nil
+}
+
+// parseObject reads the request body and parses it into a single JSON API object
+func (res *Resource) parseObject(w http.ResponseWriter, r *http.Request) (*jsh.Object, *jsh.Error) {
+	_, readErr := res.readBody(w, r)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	parser := jsh.NewParser(r)
+	if isJSONAPIContentType(parser.Headers.Get("Content-Type")) {
+		// media type parameters have already been validated during negotiation,
+		// jsh expects the bare media type
+		parser.Headers = http.Header{"Content-Type": []string{jsh.ContentType}}
+	}
+
+	document, err := parser.Document(r.Body, jsh.ObjectMode)
+	if err != nil && err.Status == http.StatusInternalServerError {
+		// jsh reports undecodable bodies as internal errors
+		return nil, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: "Request body must be a valid JSON API document",
+			Status: http.StatusBadRequest,
+			ISE:    err.ISE,
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !document.HasData() {
+		return nil, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: "Member 'data' must contain a resource object",
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	object := document.First()
+	if r.Method != "POST" && object.ID == "" {
+		return nil, jsh.InputError("Missing mandatory object attribute", "id")
+	}
+
+	return object, nil
+}
+
+// bodyTooLarge builds the 413 error returned for oversized request bodies
+func bodyTooLarge(limit int64) *jsh.Error {
+	return &jsh.Error{
+		Title:  "Request Entity Too Large",
+		Detail: fmt.Sprintf("Request body must not exceed %d bytes", limit),
+		Status: http.StatusRequestEntityTooLarge,
+	}
+}
+
+/*
+drainBody reads and closes the body of a request whose body is ignored, up to the
+resource body size limit, so that the connection can be reused. Past the limit,
+the connection is closed instead.
+*/
+func (res *Resource) drainBody(w http.ResponseWriter, r *http.Request) {
+	body := r.Body
+	if limit := res.bodyLimit(); limit > 0 {
+		body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	io.Copy(ioutil.Discard, body)
+	body.Close()
+}
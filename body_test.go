@@ -0,0 +1,86 @@
+package jshapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/go-json-spec-handler/client"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMaxBodyBytes(t *testing.T) {
+
+	Convey("Body Size Limit Tests", t, func() {
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+
+		api := New("")
+		api.Add(resource)
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		largeAttrs := map[string]string{"foo": strings.Repeat("a", 2048)}
+
+		Convey("should default to DefaultMaxBodyBytes", func() {
+			So(api.maxBodyBytes, ShouldEqual, DefaultMaxBodyBytes)
+			So(resource.bodyLimit(), ShouldEqual, DefaultMaxBodyBytes)
+		})
+
+		Convey("should accept bodies within the limit", func() {
+			api.MaxBodyBytes(4096)
+
+			object := sampleObject("", testResourceType, largeAttrs)
+			_, resp, err := jsc.Post(server.URL, object)
+
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+		})
+
+		Convey("should reject oversized bodies with a 413", func() {
+			api.MaxBodyBytes(1024)
+
+			object := sampleObject("", testResourceType, largeAttrs)
+			resp := postRaw(server.URL+"/"+testResourceType, object)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusRequestEntityTooLarge)
+		})
+
+		Convey("should prefer the resource limit over the API limit", func() {
+			api.MaxBodyBytes(1024)
+			resource.MaxBodyBytes(0)
+
+			object := sampleObject("", testResourceType, largeAttrs)
+			resp := postRaw(server.URL+"/"+testResourceType, object)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+		})
+	})
+}
+
+// postRaw sends an object as a POST request without going through jsc, so that
+// tests can inspect error responses
+func postRaw(url string, object *jsh.Object) *http.Response {
+	doc := jsh.Build(object)
+	body, err := doc.MarshalJSON()
+	if err != nil {
+		panic(err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("Content-Type", jsh.ContentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+
+	return resp
+}
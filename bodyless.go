@@ -0,0 +1,103 @@
+package jshapi
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+/*
+bodylessWriter guarantees that responses which must not have a body have none,
+whatever the handler, sender, or jsh write: HEAD responses, and 1XX, 204 and 304
+responses. Bodyless statuses also lose their Content-Type and Content-Length
+headers, which describe a body that isn't there and confuse strict proxies. HEAD
+responses keep them, as they describe the body of the matching GET response.
+*/
+type bodylessWriter struct {
+	http.ResponseWriter
+	head        bool
+	discard     bool
+	wroteHeader bool
+}
+
+func (w *bodylessWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+
+		if bodylessStatus(status) {
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Length")
+		}
+		w.discard = w.head || bodylessStatus(status)
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *bodylessWriter) Write(content []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.discard {
+		return len(content), nil
+	}
+
+	return w.ResponseWriter.Write(content)
+}
+
+// bodylessStatus reports whether responses with the status can't have a body
+func bodylessStatus(status int) bool {
+	return status < 200 || status == http.StatusNoContent || status == http.StatusNotModified
+}
+
+// the following wrappers preserve the optional interfaces of the wrapped writer so
+// that streaming and connection hijacking keep working
+
+type flushBodylessWriter struct {
+	*bodylessWriter
+}
+
+func (w flushBodylessWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+type hijackBodylessWriter struct {
+	*bodylessWriter
+}
+
+func (w hijackBodylessWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type flushHijackBodylessWriter struct {
+	*bodylessWriter
+}
+
+func (w flushHijackBodylessWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w flushHijackBodylessWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// wrapBodylessWriter wraps w in the bodylessWriter variant matching its optional
+// interfaces
+func wrapBodylessWriter(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	writer := &bodylessWriter{ResponseWriter: w, head: r.Method == "HEAD"}
+
+	_, canFlush := w.(http.Flusher)
+	_, canHijack := w.(http.Hijacker)
+
+	switch {
+	case canFlush && canHijack:
+		return flushHijackBodylessWriter{writer}
+	case canFlush:
+		return flushBodylessWriter{writer}
+	case canHijack:
+		return hijackBodylessWriter{writer}
+	default:
+		return writer
+	}
+}
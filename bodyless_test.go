@@ -0,0 +1,95 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBodylessResponses(t *testing.T) {
+
+	Convey("Bodyless Response Tests", t, func() {
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+		resource.NoContent(OpUpdate)
+
+		// sends a document along with statuses that can't have a body, as a custom
+		// sender might
+		resource.HandleFuncC(pat.Get("/:id/cached"), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", jsh.ContentType)
+			w.Header().Set("Content-Length", "2")
+			w.WriteHeader(http.StatusNotModified)
+			w.Write([]byte("{}\n"))
+		})
+		resource.HandleFuncC(pat.Post("/:id/forced"), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			document := jsh.Build(sampleObject("1", testResourceType, testObjAttrs))
+			document.Status = http.StatusNoContent
+			sendDocument(w, r, document, nil)
+		})
+
+		api := New("")
+		api.UseC(RequestID())
+		api.SupportProfile("https://example.com/profile")
+		api.Add(resource)
+		api.AtomicOperations("operations")
+
+		send := func(method string, url string, body string, contentType string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", contentType)
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		shouldBeEmpty := func(recorder *httptest.ResponseRecorder, status int) {
+			So(recorder.Code, ShouldEqual, status)
+			So(recorder.Body.Len(), ShouldEqual, 0)
+			So(recorder.Header().Get("Content-Type"), ShouldBeEmpty)
+			So(recorder.Header().Get("Content-Length"), ShouldBeEmpty)
+		}
+
+		Convey("should send nothing but headers for deletes", func() {
+			shouldBeEmpty(send("DELETE", "/bars/1", "", jsh.ContentType), http.StatusNoContent)
+		})
+
+		Convey("should send nothing but headers for 204 updates", func() {
+			body := `{"data": {"type": "bars", "id": "1", "attributes": {"foo": "bar"}}}`
+			shouldBeEmpty(send("PATCH", "/bars/1", body, jsh.ContentType), http.StatusNoContent)
+		})
+
+		Convey("should send nothing but headers for 204 atomic operations", func() {
+			body := `{"atomic:operations": [{"op": "remove", "ref": {"type": "bars", "id": "1"}}]}`
+			shouldBeEmpty(send("POST", "/operations", body, AtomicContentType), http.StatusNoContent)
+		})
+
+		Convey("should suppress bodies written along with a 204", func() {
+			shouldBeEmpty(send("POST", "/bars/1/forced", "", jsh.ContentType), http.StatusNoContent)
+		})
+
+		Convey("should suppress bodies written along with a 304", func() {
+			shouldBeEmpty(send("GET", "/bars/1/cached", "", jsh.ContentType), http.StatusNotModified)
+		})
+
+		Convey("should send the headers of the GET response to HEAD requests", func() {
+			get := send("GET", "/bars/1", "", jsh.ContentType)
+			head := send("HEAD", "/bars/1", "", jsh.ContentType)
+
+			So(head.Code, ShouldEqual, http.StatusOK)
+			So(head.Body.Len(), ShouldEqual, 0)
+			So(head.Header().Get("Content-Type"), ShouldEqual, jsh.ContentType)
+			So(head.Header().Get("Content-Length"), ShouldEqual, get.Header().Get("Content-Length"))
+		})
+
+		Convey("should keep the bodies of other responses", func() {
+			recorder := send("GET", "/bars/1", "", jsh.ContentType)
+			So(recorder.Body.Len(), ShouldBeGreaterThan, 0)
+		})
+	})
+}
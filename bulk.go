@@ -0,0 +1,217 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+// DefaultMaxBatchSize is the maximum number of resource objects accepted by a bulk
+// request when the resource has not been configured with its own limit.
+const DefaultMaxBatchSize = 100
+
+/*
+PostBulk registers a `POST /resource` handler that accepts either a single resource
+object or an array of resource objects as the request "data" member, and hands
+them to storage as a single batch:
+
+	POST /resource
+	{"data": [{"type": "user", "attributes": {...}}, ...]}
+
+Bulk creation is all-or-nothing: if storage returns an error, it is sent as the
+sole response and the client should assume that none of the objects were created.
+Errors relating to a specific object in the batch should point at it via
+BulkItemError so the client can tell which object failed. On success, a list of
+the created objects is returned with a 201. A single object request receives a
+single object response.
+
+PostBulk takes the place of Post, registering both on the same resource panics.
+*/
+func (res *Resource) PostBulk(storage store.SaveList, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.PostBulk(storage, opts...) })()
+
+	res.checkRegistration("a route")
+
+	res.nameRoute(res.handleRoute(
+		pat.Post(patRoot),
+		OpCreate,
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.postBulkHandler(ctx, w, r, storage)
+		},
+	), opts)
+
+	res.addRoute(post, patRoot)
+}
+
+/*
+MaxBatchSize sets the maximum number of resource objects a single bulk request
+may contain, defaults to DefaultMaxBatchSize. A negative value removes the limit.
+*/
+func (res *Resource) MaxBatchSize(n int) {
+	res.checkRegistration("a batch size limit")
+
+	res.maxBatchSize = n
+}
+
+/*
+BulkItemError scopes an error to the resource object at "index" within a bulk
+request by rewriting its source pointer, i.e. "/data/attributes/name" becomes
+"/data/5/attributes/name".
+*/
+func BulkItemError(index int, err *jsh.Error) *jsh.Error {
+	scoped := *err
+
+	pointer := strings.TrimPrefix(err.Source.Pointer, "/data")
+	scoped.Source.Pointer = fmt.Sprintf("/data/%d%s", index, pointer)
+
+	return &scoped
+}
+
+// POST /resources (bulk)
+func (res *Resource) postBulkHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.SaveList) {
+	list, isList, parseErr := res.parseList(ctx, w, r)
+	if parseErr != nil {
+		res.send(ctx, w, r, parseErr)
+		return
+	}
+
+	storageCtx, finish := startStorage(ctx, r, "save_list")
+	created, err := storage(storageCtx, list)
+	err = finish(err)
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		res.send(ctx, w, r, err)
+		return
+	}
+
+	if !isList {
+		if len(created) != 1 {
+			res.send(ctx, w, r, jsh.ISE(fmt.Sprintf(
+				"Expected storage to return a single object, got %d", len(created),
+			)))
+			return
+		}
+
+		res.audit(ctx, OpCreate, "", list[0], created[0])
+		res.setLocation(w, r, created[0])
+		res.send(ctx, w, r, created[0])
+		return
+	}
+
+	// storage returns the created objects in the order they were received
+	for index, object := range created {
+		var received *jsh.Object
+		if index < len(list) {
+			received = list[index]
+		}
+
+		res.audit(ctx, OpCreate, "", received, object)
+	}
+
+	doc := jsh.Build(created)
+	doc.Status = http.StatusCreated
+	res.send(ctx, w, r, doc)
+}
+
+// parseList reads a request body whose "data" member is either an object or an
+// array of objects, isList reports which of the two was received.
+func (res *Resource) parseList(ctx context.Context, w http.ResponseWriter, r *http.Request) (jsh.List, bool, jsh.ErrorType) {
+	raw, readErr := res.readBody(w, r)
+	if readErr != nil {
+		return nil, false, readErr
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if !isJSONAPIContentType(contentType) {
+		return nil, false, jsh.SpecificationError(fmt.Sprintf(
+			"Expected Content-Type header to be %s, got: %s",
+			jsh.ContentType,
+			contentType,
+		))
+	}
+
+	payload := struct {
+		Data json.RawMessage `json:"data"`
+	}{}
+
+	decodeErr := json.Unmarshal(raw, &payload)
+	if decodeErr != nil || len(payload.Data) == 0 {
+		return nil, false, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: "Request body must be a JSON API document with a 'data' member",
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	isList := bytes.HasPrefix(bytes.TrimSpace(payload.Data), []byte("["))
+
+	list := jsh.List{}
+	decodeErr = json.Unmarshal(payload.Data, &list)
+	if decodeErr != nil {
+		return nil, false, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: "Member 'data' must contain resource objects",
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	if len(list) == 0 {
+		return nil, false, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: "Member 'data' must contain at least one resource object",
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	limit := res.batchLimit()
+	if limit > 0 && len(list) > limit {
+		return nil, false, &jsh.Error{
+			Title:  "Request Entity Too Large",
+			Detail: fmt.Sprintf("Bulk requests must not exceed %d resource objects", limit),
+			Status: http.StatusRequestEntityTooLarge,
+		}
+	}
+
+	errs := jsh.ErrorList{}
+
+	mediaTypeErr := MediaTypeError(ctx)
+	if mediaTypeErr != nil {
+		errs = append(errs, mediaTypeErr)
+	}
+
+	for index, object := range list {
+		validationErr := object.Validate(r, false)
+		if validationErr != nil {
+			errs = append(errs, BulkItemError(index, validationErr))
+		}
+
+		for _, objectErr := range res.objectErrors(ctx, r.Method, object) {
+			errs = append(errs, BulkItemError(index, objectErr))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, false, aggregateErrors(errs)
+	}
+
+	return list, isList, nil
+}
+
+// batchLimit returns the effective maximum batch size for the resource
+func (res *Resource) batchLimit() int {
+	if res.maxBatchSize != 0 {
+		return res.maxBatchSize
+	}
+
+	return DefaultMaxBatchSize
+}
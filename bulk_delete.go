@@ -0,0 +1,258 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+// HideDeletedCount makes the route registered by DeleteBulk reply with a 204
+// rather than the number of deleted objects. Ignored by other helpers.
+func HideDeletedCount() RouteOption {
+	return func(opts *routeOptions) {
+		opts.hideDeletedCount = true
+	}
+}
+
+/*
+DeleteBulk registers a `DELETE /resource` handler deleting several objects at once,
+either by id, with the identifiers of the objects as the request "data" member:
+
+	DELETE /resource
+	{"data": [{"type": "user", "id": "1"}, {"type": "user", "id": "2"}]}
+
+which are handed to ids, or by filter, with `filter[<name>]` query parameters:
+
+	DELETE /resource?filter[status]=archived
+
+which are handed to matching. Either storage may be nil when the resource doesn't
+support deleting that way. An empty set of identifiers or filters is rejected with
+a 400, rather than deleting everything, and the number of identifiers is capped by
+MaxBatchSize. The number of deleted objects is sent with a 200:
+
+	{"meta": {"deleted-count": 2}}
+
+or a 204 is sent instead with HideDeletedCount.
+*/
+func (res *Resource) DeleteBulk(ids store.DeleteMany, matching store.DeleteMatching, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.DeleteBulk(ids, matching, opts...) })()
+
+	res.checkRegistration("a route")
+
+	if ids == nil && matching == nil {
+		panic(fmt.Sprintf("jshapi: bulk delete of resource '%s' requires a storage", res.Type))
+	}
+
+	options := applyRouteOptions(opts)
+
+	res.nameRoute(res.handleRoute(
+		pat.Delete(patRoot),
+		OpDelete,
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.deleteBulkHandler(ctx, w, r, ids, matching, options)
+		},
+	), opts)
+
+	res.addRoute(delete, patRoot)
+}
+
+// DELETE /resources (bulk)
+func (res *Resource) deleteBulkHandler(
+	ctx context.Context,
+	w http.ResponseWriter,
+	r *http.Request,
+	ids store.DeleteMany,
+	matching store.DeleteMatching,
+	options routeOptions,
+) {
+	raw, readErr := res.readBody(w, r)
+	if readErr != nil {
+		res.send(ctx, w, r, readErr)
+		return
+	}
+
+	filter, filterErr := parseFilter(r)
+	if filterErr != nil {
+		res.send(ctx, w, r, filterErr)
+		return
+	}
+
+	hasBody := len(strings.TrimSpace(string(raw))) > 0
+	if hasBody == (len(filter) > 0) {
+		res.send(ctx, w, r, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: "Bulk deletes require either a 'data' array of resource identifiers or 'filter' query parameters",
+			Status: http.StatusBadRequest,
+		})
+		return
+	}
+
+	var deleted int64
+	var err jsh.ErrorType
+	var deletedIDs []string
+
+	switch {
+	case hasBody && ids != nil:
+		deletedIDs, err = res.parseIdentifiers(ctx, r, raw)
+		if HasError(err) {
+			res.send(ctx, w, r, err)
+			return
+		}
+
+		storageCtx, finish := startStorage(ctx, r, "delete_many")
+		deleted, err = ids(storageCtx, deletedIDs)
+		err = finish(err)
+	case !hasBody && matching != nil:
+		storageCtx, finish := startStorage(ctx, r, "delete_matching")
+		deleted, err = matching(storageCtx, filter)
+		err = finish(err)
+	default:
+		method := "by id"
+		if !hasBody {
+			method = "by filter"
+		}
+		res.send(ctx, w, r, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: fmt.Sprintf("Resource '%s' does not support bulk deletes %s", res.Type, method),
+			Status: http.StatusBadRequest,
+		})
+		return
+	}
+
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		res.send(ctx, w, r, err)
+		return
+	}
+
+	for _, id := range deletedIDs {
+		res.audit(ctx, OpDelete, id, nil, nil)
+	}
+
+	if options.hideDeletedCount {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	sendMeta(w, map[string]interface{}{"deleted-count": deleted})
+}
+
+// parseIdentifiers reads the ids of a bulk delete body, whose "data" member must
+// be a non-empty array of identifiers of the resource
+func (res *Resource) parseIdentifiers(ctx context.Context, r *http.Request, raw []byte) ([]string, jsh.ErrorType) {
+	contentType := r.Header.Get("Content-Type")
+	if !isJSONAPIContentType(contentType) {
+		return nil, jsh.SpecificationError(fmt.Sprintf(
+			"Expected Content-Type header to be %s, got: %s",
+			jsh.ContentType,
+			contentType,
+		))
+	}
+
+	payload := struct {
+		Data []struct {
+			Type string `json:"type"`
+			ID   string `json:"id"`
+		} `json:"data"`
+	}{}
+
+	decodeErr := json.Unmarshal(raw, &payload)
+	if decodeErr != nil || len(payload.Data) == 0 {
+		return nil, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: "Member 'data' must be a non-empty array of resource identifiers",
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	limit := res.batchLimit()
+	if limit > 0 && len(payload.Data) > limit {
+		return nil, &jsh.Error{
+			Title:  "Request Entity Too Large",
+			Detail: fmt.Sprintf("Bulk requests must not exceed %d resource objects", limit),
+			Status: http.StatusRequestEntityTooLarge,
+		}
+	}
+
+	errs := jsh.ErrorList{}
+
+	mediaTypeErr := MediaTypeError(ctx)
+	if mediaTypeErr != nil {
+		errs = append(errs, mediaTypeErr)
+	}
+
+	ids := []string{}
+	listed := map[string]bool{}
+
+	for index, identifier := range payload.Data {
+		if identifier.Type != res.Type {
+			conflict := &jsh.Error{
+				Title:  "Conflict",
+				Detail: fmt.Sprintf("Expected object type '%s', got '%s'", res.Type, identifier.Type),
+				Status: http.StatusConflict,
+			}
+			conflict.Source.Pointer = "/data/type"
+			errs = append(errs, BulkItemError(index, conflict))
+		}
+
+		if identifier.ID == "" {
+			missing := jsh.SpecificationError("ID must be set for resource identifiers")
+			missing.Source.Pointer = "/data/id"
+			errs = append(errs, BulkItemError(index, missing))
+			continue
+		}
+
+		if !listed[identifier.ID] {
+			listed[identifier.ID] = true
+			ids = append(ids, identifier.ID)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, aggregateErrors(errs)
+	}
+
+	return ids, nil
+}
+
+// parseFilter reads the `filter[<name>]` query parameters of a request, none of
+// which may be empty
+func parseFilter(r *http.Request) (map[string]string, *jsh.Error) {
+	filter := map[string]string{}
+
+	names := []string{}
+	query := r.URL.Query()
+	for param := range query {
+		if param == "filter" || strings.HasPrefix(param, "filter[") {
+			names = append(names, param)
+		}
+	}
+	sort.Strings(names)
+
+	for _, param := range names {
+		name := strings.TrimSuffix(strings.TrimPrefix(param, "filter["), "]")
+		value := query.Get(param)
+
+		if param == "filter" || name == "" || value == "" {
+			return nil, &jsh.Error{
+				Title:  "Bad Request",
+				Detail: fmt.Sprintf("Query parameter '%s' must name a filter and give it a value", param),
+				Status: http.StatusBadRequest,
+			}
+		}
+
+		filter[name] = value
+	}
+
+	return filter, nil
+}
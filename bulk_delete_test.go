@@ -0,0 +1,119 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+func TestDeleteBulk(t *testing.T) {
+
+	Convey("Bulk Delete Tests", t, func() {
+
+		var deletedIDs []string
+		var filters []map[string]string
+
+		ids := func(ctx context.Context, ids []string) (int64, jsh.ErrorType) {
+			deletedIDs = ids
+			return int64(len(ids)), nil
+		}
+		matching := func(ctx context.Context, filter map[string]string) (int64, jsh.ErrorType) {
+			filters = append(filters, filter)
+			return 7, nil
+		}
+
+		resource := NewResource(testResourceType)
+		resource.MaxBatchSize(3)
+
+		api := New("")
+
+		serve := func() string {
+			api.Add(resource)
+			server := httptest.NewServer(api)
+			Reset(server.Close)
+			return server.URL + "/" + testResourceType
+		}
+
+		Convey("should not register the route unless enabled", func() {
+			resp, _ := sendBulk("DELETE", serve()+"?filter[foo]=bar", "")
+			So(resp.StatusCode, ShouldNotEqual, http.StatusOK)
+			So(filters, ShouldBeEmpty)
+		})
+
+		Convey("should require a storage", func() {
+			So(func() { resource.DeleteBulk(nil, nil) }, ShouldPanicWith,
+				"jshapi: bulk delete of resource 'bars' requires a storage")
+		})
+
+		Convey("->DeleteBulk()", func() {
+			resource.DeleteBulk(ids, matching)
+			url := serve()
+
+			Convey("should delete by id", func() {
+				resp, body := sendBulk("DELETE", url, `{"data": [
+					{"type": "bars", "id": "1"}, {"type": "bars", "id": "2"}, {"type": "bars", "id": "1"}
+				]}`)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(body, ShouldContainSubstring, `"deleted-count": 2`)
+				So(deletedIDs, ShouldResemble, []string{"1", "2"})
+			})
+
+			Convey("should delete by filter", func() {
+				resp, body := sendBulk("DELETE", url+"?filter[foo]=bar&filter[baz]=qux", "")
+
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(body, ShouldContainSubstring, `"deleted-count": 7`)
+				So(filters, ShouldResemble, []map[string]string{{"foo": "bar", "baz": "qux"}})
+			})
+
+			Convey("should reject requests without ids or filter", func() {
+				resp, _ := sendBulk("DELETE", url, "")
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+
+				resp, _ = sendBulk("DELETE", url, `{"data": []}`)
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+
+				resp, _ = sendBulk("DELETE", url+"?filter[foo]=", "")
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+
+				So(deletedIDs, ShouldBeNil)
+				So(filters, ShouldBeEmpty)
+			})
+
+			Convey("should point at invalid identifiers", func() {
+				resp, body := sendBulk("DELETE", url, `{"data": [{"type": "bars", "id": "1"}, {"type": "bars"}]}`)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusNotAcceptable)
+				So(body, ShouldContainSubstring, `"pointer": "/data/1/id"`)
+			})
+
+			Convey("should enforce the max batch size", func() {
+				resp, _ := sendBulk("DELETE", url, `{"data": [
+					{"type": "bars", "id": "1"}, {"type": "bars", "id": "2"},
+					{"type": "bars", "id": "3"}, {"type": "bars", "id": "4"}
+				]}`)
+				So(resp.StatusCode, ShouldEqual, http.StatusRequestEntityTooLarge)
+			})
+		})
+
+		Convey("should reject deletes the storage doesn't support", func() {
+			resource.DeleteBulk(ids, nil)
+
+			resp, _ := sendBulk("DELETE", serve()+"?filter[foo]=bar", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("should send a 204 with HideDeletedCount", func() {
+			resource.DeleteBulk(nil, matching, HideDeletedCount())
+
+			resp, body := sendBulk("DELETE", serve()+"?filter[foo]=bar", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+			So(body, ShouldBeEmpty)
+		})
+	})
+}
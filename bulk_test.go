@@ -0,0 +1,106 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+func TestPostBulk(t *testing.T) {
+
+	Convey("Bulk Create Tests", t, func() {
+
+		saveList := func(ctx context.Context, list jsh.List) (jsh.List, jsh.ErrorType) {
+			for index, object := range list {
+				object.ID = string('1' + rune(index))
+			}
+			return list, nil
+		}
+
+		resource := NewResource(testResourceType)
+		resource.PostBulk(saveList)
+		resource.MaxBatchSize(2)
+
+		api := New("")
+		api.Add(resource)
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/" + testResourceType
+
+		Convey("should create a list of objects", func() {
+			resp, body := postBulk(url, `{"data": [
+				{"type": "bars", "attributes": {"foo": "bar"}},
+				{"type": "bars", "attributes": {"foo": "baz"}}
+			]}`)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+			So(body, ShouldContainSubstring, `"id": "1"`)
+			So(body, ShouldContainSubstring, `"id": "2"`)
+		})
+
+		Convey("should create a single object", func() {
+			resp, body := postBulk(url, `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+
+			doc := map[string]interface{}{}
+			So(json.Unmarshal([]byte(body), &doc), ShouldBeNil)
+			_, isObject := doc["data"].(map[string]interface{})
+			So(isObject, ShouldBeTrue)
+		})
+
+		Convey("should enforce the max batch size", func() {
+			resp, _ := postBulk(url, `{"data": [
+				{"type": "bars"}, {"type": "bars"}, {"type": "bars"}
+			]}`)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusRequestEntityTooLarge)
+		})
+
+		Convey("should index errors by object position", func() {
+			resp, body := postBulk(url, `{"data": [{"type": "bars"}, {"attributes": {}}]}`)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusNotAcceptable)
+			So(body, ShouldContainSubstring, `"pointer": "/data/1"`)
+		})
+
+		Convey("->BulkItemError()", func() {
+			err := BulkItemError(5, jsh.InputError("invalid", "name"))
+			So(err.Source.Pointer, ShouldEqual, "/data/5/attributes/name")
+		})
+	})
+}
+
+func postBulk(url string, body string) (*http.Response, string) {
+	return sendBulk("POST", url, body)
+}
+
+func sendBulk(method string, url string, body string) (*http.Response, string) {
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("Content-Type", jsh.ContentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+
+	return resp, string(raw)
+}
@@ -0,0 +1,219 @@
+package jshapi
+
+import (
+	"net/http"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+// BulkMode sets how a bulk update handles objects that fail among the others
+type BulkMode int
+
+const (
+	// AllOrNothing stops at the first failing object and rolls back the updates
+	// already made, the default
+	AllOrNothing BulkMode = iota
+	// BestEffort attempts every object and keeps the updates that succeeded
+	BestEffort
+)
+
+/*
+PatchBulk registers a `PATCH /resource` handler that accepts an array of resource
+objects, each with an id, as the request "data" member, and hands them to storage
+as a single batch:
+
+	PATCH /resource
+	{"data": [{"type": "user", "id": "1", "attributes": {...}}, ...]}
+
+Storage is expected to treat the batch as all-or-nothing, errors relating to a
+specific object should point at it via BulkItemError. On success, the list of the
+updated objects is returned with a 200. The batch is capped by MaxBatchSize.
+*/
+func (res *Resource) PatchBulk(storage store.UpdateList, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.PatchBulk(storage, opts...) })()
+
+	res.checkRegistration("a route")
+
+	res.nameRoute(res.handleRoute(
+		pat.Patch(patRoot),
+		OpUpdate,
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.patchBulkHandler(ctx, w, r, storage)
+		},
+	), opts)
+
+	res.addRoute(patch, patRoot)
+}
+
+/*
+PatchBulkEach registers the same `PATCH /resource` handler as PatchBulk, for
+storage that can only update one object at a time. The objects are updated in
+order, within a single transaction when the CRUD storage of the resource
+implements store.Transactional. How failing objects are handled depends on
+BulkUpdateMode:
+
+	AllOrNothing: the first error is sent, and the transaction rolled back
+	BestEffort: every object is attempted, the updates that succeeded are
+	committed and the errors of the others are sent
+
+Without a transaction, the updates made before an error are kept in both modes.
+*/
+func (res *Resource) PatchBulkEach(storage store.Update, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.PatchBulkEach(storage, opts...) })()
+
+	res.checkRegistration("a route")
+
+	res.nameRoute(res.handleRoute(
+		pat.Patch(patRoot),
+		OpUpdate,
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.patchEachHandler(ctx, w, r, storage)
+		},
+	), opts)
+
+	res.addRoute(patch, patRoot)
+}
+
+// BulkUpdateMode sets how PatchBulkEach handles failing objects, defaults to
+// AllOrNothing
+func (res *Resource) BulkUpdateMode(mode BulkMode) {
+	res.checkRegistration("a bulk update mode")
+
+	res.bulkMode = mode
+}
+
+// PATCH /resources (bulk)
+func (res *Resource) patchBulkHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.UpdateList) {
+	list, parseErr := res.parseUpdateList(ctx, w, r)
+	if parseErr != nil {
+		res.send(ctx, w, r, parseErr)
+		return
+	}
+
+	storageCtx, finish := startStorage(ctx, r, "update_list")
+	updated, err := storage(storageCtx, list)
+	err = finish(err)
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		res.send(ctx, w, r, err)
+		return
+	}
+
+	for _, object := range updated {
+		if object == nil {
+			res.send(ctx, w, r, res.unsavedObject("update_list"))
+			return
+		}
+	}
+
+	// storage returns the updated objects in the order they were received
+	for index, object := range updated {
+		var received *jsh.Object
+		if index < len(list) {
+			received = list[index]
+		}
+
+		res.audit(ctx, OpUpdate, object.ID, received, object)
+	}
+
+	res.send(ctx, w, r, updated)
+}
+
+// PATCH /resources (bulk, one object at a time)
+func (res *Resource) patchEachHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Update) {
+	list, parseErr := res.parseUpdateList(ctx, w, r)
+	if parseErr != nil {
+		res.send(ctx, w, r, parseErr)
+		return
+	}
+
+	txCtx := ctx
+	if res.tx != nil {
+		begun, err := res.tx.Begin(ctx)
+		if HasError(err) {
+			res.send(ctx, w, r, err)
+			return
+		}
+		txCtx = begun
+	}
+
+	received := jsh.List{}
+	updated := jsh.List{}
+	errs := jsh.ErrorList{}
+
+	for index, object := range list {
+		storageCtx, finish := startStorage(withPatchFields(txCtx, object), r, "update")
+		result, err := storage(storageCtx, object)
+		err = finish(err)
+		if !HasError(err) && result == nil {
+			err = res.unsavedObject("update")
+		}
+
+		if HasError(err) {
+			for _, itemErr := range toErrorList(err) {
+				errs = append(errs, BulkItemError(index, itemErr))
+			}
+			if res.bulkMode == AllOrNothing {
+				break
+			}
+			continue
+		}
+
+		received = append(received, object)
+		updated = append(updated, result)
+	}
+
+	if clientGone(ctx) || (len(errs) > 0 && res.bulkMode == AllOrNothing) {
+		if res.tx != nil {
+			res.tx.Rollback(txCtx)
+		}
+		if len(errs) > 0 {
+			res.send(ctx, w, r, aggregateErrors(errs))
+		}
+		return
+	}
+
+	if res.tx != nil {
+		commitErr := res.tx.Commit(txCtx)
+		if HasError(commitErr) {
+			res.send(ctx, w, r, commitErr)
+			return
+		}
+	}
+
+	for index, object := range updated {
+		res.audit(ctx, OpUpdate, object.ID, received[index], object)
+	}
+
+	if len(errs) > 0 {
+		res.send(ctx, w, r, aggregateErrors(errs))
+		return
+	}
+
+	res.send(ctx, w, r, updated)
+}
+
+// parseUpdateList reads the body of a bulk update, which must hold an array of
+// objects
+func (res *Resource) parseUpdateList(ctx context.Context, w http.ResponseWriter, r *http.Request) (jsh.List, jsh.ErrorType) {
+	list, isList, parseErr := res.parseList(ctx, w, r)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	if !isList {
+		return nil, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: "Member 'data' of a bulk update must be an array of resource objects",
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	return list, nil
+}
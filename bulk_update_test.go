@@ -0,0 +1,121 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+func TestPatchBulk(t *testing.T) {
+
+	Convey("Bulk Update Tests", t, func() {
+
+		storage := &txStorage{MockStorage: MockStorage{ResourceType: testResourceType}}
+		resource := NewCRUDResource(testResourceType, storage)
+		resource.MaxBatchSize(3)
+
+		api := New("")
+
+		serve := func() string {
+			api.Add(resource)
+			server := httptest.NewServer(api)
+			Reset(server.Close)
+			return server.URL + "/" + testResourceType
+		}
+
+		Convey("->PatchBulkEach()", func() {
+			resource.PatchBulkEach(storage.Update)
+
+			Convey("should update every object within a transaction", func() {
+				resp, body := sendBulk("PATCH", serve(), `{"data": [
+					{"type": "bars", "id": "1", "attributes": {"foo": "bar"}},
+					{"type": "bars", "id": "2", "attributes": {"foo": "baz"}}
+				]}`)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(body, ShouldContainSubstring, `"id": "1"`)
+				So(body, ShouldContainSubstring, `"id": "2"`)
+				So(storage.began, ShouldEqual, 1)
+				So(storage.committed, ShouldEqual, 1)
+			})
+
+			Convey("should roll back and point at the failing object", func() {
+				resp, body := sendBulk("PATCH", serve(), `{"data": [
+					{"type": "bars", "id": "1"}, {"type": "bars", "id": "missing"}, {"type": "bars", "id": "3"}
+				]}`)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+				So(body, ShouldContainSubstring, `"pointer": "/data/1"`)
+				So(storage.committed, ShouldEqual, 0)
+				So(storage.rolledBack, ShouldEqual, 1)
+			})
+
+			Convey("should keep the successful updates in best effort mode", func() {
+				resource.BulkUpdateMode(BestEffort)
+				resp, body := sendBulk("PATCH", serve(), `{"data": [
+					{"type": "bars", "id": "missing"}, {"type": "bars", "id": "2"}, {"type": "bars", "id": "missing"}
+				]}`)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+				So(body, ShouldContainSubstring, `"pointer": "/data/0"`)
+				So(body, ShouldContainSubstring, `"pointer": "/data/2"`)
+				So(storage.committed, ShouldEqual, 1)
+				So(storage.rolledBack, ShouldEqual, 0)
+			})
+
+			Convey("should require an id on every object", func() {
+				resp, body := sendBulk("PATCH", serve(), `{"data": [{"type": "bars", "id": "1"}, {"type": "bars"}]}`)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusNotAcceptable)
+				So(body, ShouldContainSubstring, `"pointer": "/data/1"`)
+				So(storage.began, ShouldEqual, 0)
+			})
+
+			Convey("should require an array", func() {
+				resp, _ := sendBulk("PATCH", serve(), `{"data": {"type": "bars", "id": "1"}}`)
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+
+			Convey("should enforce the max batch size", func() {
+				resp, _ := sendBulk("PATCH", serve(), `{"data": [
+					{"type": "bars", "id": "1"}, {"type": "bars", "id": "2"},
+					{"type": "bars", "id": "3"}, {"type": "bars", "id": "4"}
+				]}`)
+				So(resp.StatusCode, ShouldEqual, http.StatusRequestEntityTooLarge)
+			})
+		})
+
+		Convey("->PatchBulk()", func() {
+			batches := []int{}
+			resource.PatchBulk(func(ctx context.Context, list jsh.List) (jsh.List, jsh.ErrorType) {
+				batches = append(batches, len(list))
+				if list[0].ID == "lost" {
+					return jsh.List{nil}, nil
+				}
+				return list, nil
+			})
+
+			Convey("should hand the whole batch to storage", func() {
+				resp, _ := sendBulk("PATCH", serve(), `{"data": [{"type": "bars", "id": "1"}, {"type": "bars", "id": "2"}]}`)
+
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(batches, ShouldResemble, []int{2})
+				So(resource.Routes, ShouldContain, "PATCH - /bars")
+			})
+
+			Convey("should send a 500 when storage returns nil objects", func() {
+				resp, _ := sendBulk("PATCH", serve(), `{"data": [{"type": "bars", "id": "lost"}]}`)
+				So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+
+		Convey("should not register the route unless enabled", func() {
+			resp, _ := sendBulk("PATCH", serve(), `{"data": [{"type": "bars", "id": "1"}]}`)
+			So(resp.StatusCode, ShouldNotEqual, http.StatusOK)
+		})
+	})
+}
@@ -0,0 +1,44 @@
+package jshapi
+
+import (
+	stdcontext "context"
+
+	"golang.org/x/net/context"
+)
+
+/*
+StatusClientClosedRequest is reported to metrics and access logs in place of the
+response status when the client went away before the response could be sent.
+*/
+const StatusClientClosedRequest = 499
+
+/*
+clientGone checks whether the request was canceled because the client went away,
+in which case the response is not worth writing. The outcome is recorded for route
+reporting.
+*/
+func clientGone(ctx context.Context) bool {
+	err := ctx.Err()
+	if err != context.Canceled && err != stdcontext.Canceled {
+		return false
+	}
+
+	info, _ := ctx.Value(routeInfoKey).(*routeInfo)
+	if info != nil {
+		info.clientGone = true
+	}
+
+	return true
+}
+
+// reportedStatus is the status reported for a served request
+func (i *routeInfo) reportedStatus(status int) int {
+	switch {
+	case i.clientGone:
+		return StatusClientClosedRequest
+	case status == 0:
+		return 200
+	default:
+		return status
+	}
+}
@@ -0,0 +1,72 @@
+package jshapi
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// failingWriter fails writes after accepting a few bytes
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w *failingWriter) Write(content []byte) (int, error) {
+	w.ResponseRecorder.Write(content[:10])
+	return 10, errors.New("broken pipe")
+}
+
+func TestClientGone(t *testing.T) {
+
+	Convey("Client Gone Tests", t, func() {
+
+		Convey("should skip the response when the client went away during storage", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			resource := NewResource(testResourceType)
+			resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+				cancel()
+				return (&MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs}).Get(ctx, id)
+			})
+
+			metrics := &recordMetrics{}
+			logger := &recordLogger{}
+
+			api := New("")
+			api.SetMetrics(metrics)
+			api.UseC(AccessLog(logger))
+			api.Add(resource)
+
+			request, err := http.NewRequest("GET", "/"+testResourceType+"/1", nil)
+			So(err, ShouldBeNil)
+
+			recorder := httptest.NewRecorder()
+			api.ServeHTTPC(ctx, recorder, request)
+
+			So(recorder.Body.Len(), ShouldEqual, 0)
+			So(metrics.statuses, ShouldResemble, []int{StatusClientClosedRequest})
+			So(logger.entries[0]["outcome"], ShouldEqual, "client_gone")
+		})
+
+		Convey("should log write errors with the number of bytes written", func() {
+			buffer := &bytes.Buffer{}
+			sender := DefaultSender(log.New(buffer, "", 0))
+
+			request, err := http.NewRequest("GET", "/"+testResourceType+"/1", nil)
+			So(err, ShouldBeNil)
+
+			object, _ := (&MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs}).Get(context.Background(), "1")
+			sender(context.Background(), &failingWriter{httptest.NewRecorder()}, request, object)
+
+			So(buffer.String(), ShouldContainSubstring, "Error writing response after 10 bytes: broken pipe")
+		})
+	})
+}
@@ -0,0 +1,147 @@
+package jshapi
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+/*
+Clone returns a copy of the resource that can be modified, and added to another
+API such as a /v2 one, without affecting the original. goji muxes can't be copied,
+so the clone replays every registration call made on the resource, Get, CRUD,
+ToMany, HandleFuncC, Use, Alias and so on, onto a fresh mux, after copying its
+options and settings. Handlers given to HandleFuncC and the like are replayed as
+is: those built with the original's Wrap keep reporting it.
+
+Options apply to the clone before its routes are replayed, such as a different
+sender for the v2 API:
+
+	v2 := resource.Clone(jshapi.WithSender(v2Sender))
+	v2.Action("archive", archive)
+
+The clone is neither frozen nor added to an API, even if the original is.
+*/
+func (res *Resource) Clone(opts ...ResourceOption) *Resource {
+	clone := NewResource(res.Type)
+
+	clone.maxBodyBytes = res.maxBodyBytes
+	clone.maxBatchSize = res.maxBatchSize
+	clone.bulkMode = res.bulkMode
+	clone.importBatchSize = res.importBatchSize
+	clone.maxImportRows = res.maxImportRows
+	clone.strictMembers = res.strictMembers
+	clone.memberDepth = res.memberDepth
+	clone.validators = append([]Validator{}, res.validators...)
+	clone.schema = res.schema
+	clone.authorizer = res.authorizer
+	clone.objectFilter = res.objectFilter
+	clone.fieldPolicy = res.fieldPolicy
+	clone.storageTimeout = res.storageTimeout
+	clone.prefix = res.prefix
+	clone.collection = res.collection
+	clone.sender = res.sender
+	clone.pluralize = res.pluralize
+	clone.clientIDs = res.clientIDs
+	clone.idPattern = res.idPattern
+	clone.invalidIDStatus = res.invalidIDStatus
+	clone.idParam = res.idParam
+	clone.compositeID = res.compositeID
+	clone.readOnly = atomic.LoadInt32(&res.readOnly)
+	clone.relationshipLinks = res.relationshipLinks
+	clone.maxIncludeDepth = res.maxIncludeDepth
+	clone.maxIncluded = res.maxIncluded
+	clone.allowedIncludes = res.allowedIncludes
+	clone.defaultIncludes = res.defaultIncludes
+	clone.deniedIncludes = res.deniedIncludes
+	clone.includeConcurrency = res.includeConcurrency
+	clone.failedIncludes = res.failedIncludes
+	clone.keyCasing = res.keyCasing
+	clone.timestamps = res.timestamps
+	clone.defaults = append([]defaultAttribute{}, res.defaults...)
+
+	if res.computed != nil {
+		clone.computed = map[string]ComputedFunc{}
+		for name, compute := range res.computed {
+			clone.computed[name] = compute
+		}
+	}
+
+	clone.unknownLogger = res.unknownLogger
+	clone.describer = res.describer
+	clone.notFound = res.notFound
+	clone.failedOpens = res.failedOpens
+	if res.sealed != nil {
+		clone.sealed = map[string]SealCodec{}
+		for name, codec := range res.sealed {
+			clone.sealed[name] = codec
+		}
+	}
+
+	if res.knownAttributes != nil {
+		clone.knownAttributes = map[string]bool{}
+		for name := range res.knownAttributes {
+			clone.knownAttributes[name] = true
+		}
+	}
+
+	if res.codecs != nil {
+		clone.codecs = map[string]Codec{}
+		for name, codec := range res.codecs {
+			clone.codecs[name] = codec
+		}
+	}
+
+	if res.responseHooks != nil {
+		clone.responseHooks = map[ResponseStage][]ResponseHook{}
+		for stage, hooks := range res.responseHooks {
+			clone.responseHooks[stage] = append([]ResponseHook{}, hooks...)
+		}
+	}
+
+	if res.noContent != nil {
+		clone.noContent = map[Operation]bool{}
+		for op, enabled := range res.noContent {
+			clone.noContent[op] = enabled
+		}
+	}
+
+	if res.storageTimeouts != nil {
+		clone.storageTimeouts = map[Operation]time.Duration{}
+		for op, timeout := range res.storageTimeouts {
+			clone.storageTimeouts[op] = timeout
+		}
+	}
+
+	clone.Apply(opts...)
+
+	for _, replay := range res.registrations {
+		replay(clone)
+	}
+
+	return clone
+}
+
+/*
+record is deferred by registration calls, with the same call made on a clone:
+
+	defer res.record(func(clone *Resource) { clone.Get(storage) })()
+
+Only the outermost call is recorded, CRUD rather than the Get it makes, and only
+once it succeeded.
+*/
+func (res *Resource) record(replay func(*Resource)) func() {
+	res.recording++
+
+	return func() {
+		res.recording--
+
+		failure := recover()
+		if failure != nil {
+			panic(failure)
+		}
+
+		if res.recording == 0 {
+			res.registrations = append(res.registrations, replay)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClone(t *testing.T) {
+
+	Convey("Clone Tests", t, func() {
+
+		storage := &MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs, ListCount: 1}
+		foos := func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return jsh.List{}, nil
+		}
+
+		original := NewCRUDResource(testResourceType, storage)
+		original.ToMany("foos", foos)
+		original.HandleFuncC(pat.Get("/export"), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		})
+
+		clone := original.Clone()
+
+		v1 := New("v1")
+		v1.Add(original)
+		v2 := New("v2")
+		v2.Add(clone)
+
+		send := func(api *API, method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should replay every registration", func() {
+			So(strings.Replace(v2.RouteTree(), "/v2/", "/v1/", -1), ShouldEqual, v1.RouteTree())
+			So(clone.Relationships, ShouldResemble, original.Relationships)
+
+			So(send(v2, "GET", "/v2/bars", "").Code, ShouldEqual, http.StatusOK)
+			So(send(v2, "GET", "/v2/bars/1/foos", "").Code, ShouldEqual, http.StatusOK)
+			So(send(v2, "GET", "/v2/bars/export", "").Code, ShouldEqual, http.StatusAccepted)
+		})
+
+		Convey("should not affect the original", func() {
+			clone.Action("testAction", storage.Get)
+			clone.ToMany("bazs", foos)
+			clone.NoContent(OpUpdate)
+
+			So(send(v2, "GET", "/v2/bars/1/testAction", "").Code, ShouldEqual, http.StatusOK)
+			So(send(v1, "GET", "/v1/bars/1/testAction", "").Code, ShouldEqual, http.StatusNotFound)
+
+			So(clone.Relationships, ShouldContainKey, "bazs")
+			So(original.Relationships, ShouldNotContainKey, "bazs")
+			So(original.RouteList(), ShouldNotContain, "GET - /bars/:id/testAction")
+
+			update := `{"data": {"type": "bars", "id": "1", "attributes": {"foo": "baz"}}}`
+			So(send(v2, "PATCH", "/v2/bars/1", update).Code, ShouldEqual, http.StatusNoContent)
+			So(send(v1, "PATCH", "/v1/bars/1", update).Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("should apply options before replaying routes", func() {
+			sender := func(ctx context.Context, w http.ResponseWriter, r *http.Request, sendable jsh.Sendable) {
+				w.WriteHeader(http.StatusTeapot)
+			}
+
+			v3 := New("v3")
+			v3.Add(original.Clone(WithSender(sender)))
+
+			So(send(v3, "GET", "/v3/bars/1", "").Code, ShouldEqual, http.StatusTeapot)
+			So(send(v1, "GET", "/v1/bars/1", "").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("should clone frozen resources unfrozen", func() {
+			v1.Freeze()
+			So(func() { original.Action("testAction", storage.Get) }, ShouldPanic)
+
+			unfrozen := original.Clone()
+			So(unfrozen.Frozen(), ShouldBeFalse)
+			So(unfrozen.RouteList(), ShouldNotContain, "GET - /bars/:id/testAction")
+
+			unfrozen.Action("testAction", storage.Get)
+			So(unfrozen.RouteList(), ShouldContain, "GET - /bars/:id/testAction")
+		})
+	})
+}
@@ -0,0 +1,191 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"goji.io/pattern"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+// compositeSeparator separates the escaped parts of a composite id, it never
+// appears in a part once path escaped
+const compositeSeparator = ","
+
+/*
+WithCompositeID keys the resource by several values, each matched by its own
+route variable: WithCompositeID("region", "id") generates `/deployments/:region/:id`
+and relationship and action routes below it. Storage and handlers receive, and
+objects carry, the composite id encoding all values, see CompositeID. Panics on
+less than two names, or invalid or duplicate names.
+*/
+func WithCompositeID(names ...string) ResourceOption {
+	return func(res *Resource) {
+		if len(names) < 2 {
+			panic(fmt.Sprintf("jshapi: composite id of '%s' requires at least two names", res.Type))
+		}
+
+		seen := map[string]bool{}
+		for _, name := range names {
+			if !validIDParam.MatchString(name) || seen[name] {
+				panic(fmt.Sprintf("jshapi: invalid composite id name '%s' for '%s'", name, res.Type))
+			}
+			seen[name] = true
+		}
+
+		res.compositeID = names
+	}
+}
+
+/*
+CompositeID encodes the values of a composite key, in the order of the names given
+to WithCompositeID, into an object id. The encoding is deterministic and reversible
+with ParseCompositeID: values are path escaped and joined with ",", so that
+CompositeID("eu", "42") is "eu,42".
+*/
+func CompositeID(values ...string) string {
+	escaped := make([]string, len(values))
+	for index, value := range values {
+		escaped[index] = url.PathEscape(value)
+	}
+
+	return strings.Join(escaped, compositeSeparator)
+}
+
+// ParseCompositeID decodes an id encoded by CompositeID into its count values
+func ParseCompositeID(id string, count int) ([]string, error) {
+	values := strings.Split(id, compositeSeparator)
+	if len(values) != count {
+		return nil, fmt.Errorf("composite id '%s' has %d values, expected %d", id, len(values), count)
+	}
+
+	for index, value := range values {
+		unescaped, err := url.PathUnescape(value)
+		if err != nil {
+			return nil, fmt.Errorf("composite id '%s' is not properly escaped", id)
+		}
+		values[index] = unescaped
+	}
+
+	return values, nil
+}
+
+// ResourceKey returns the values matched by the id variables of a route of res
+// by name, or nil for routes without an id
+func ResourceKey(ctx context.Context, res *Resource) map[string]string {
+	key := map[string]string{}
+	for _, name := range res.idParams() {
+		value, hasValue := ctx.Value(pattern.Variable(name)).(string)
+		if !hasValue {
+			return nil
+		}
+		key[name] = value
+	}
+
+	return key
+}
+
+// GetComposite registers a `GET /resource/:name1/:name2` handler for a resource
+// keyed by WithCompositeID, with storage receiving the key by name
+func (res *Resource) GetComposite(storage store.CompositeGet, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.GetComposite(storage, opts...) })()
+
+	res.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		key, err := res.compositeKey(id)
+		if err != nil {
+			return nil, jsh.NotFound(res.Type, id)
+		}
+
+		return storage(ctx, key)
+	}, opts...)
+}
+
+// idParams lists the id variables of the resource routes
+func (res *Resource) idParams() []string {
+	if res.compositeID != nil {
+		return res.compositeID
+	}
+
+	return []string{res.idParam}
+}
+
+// idKeys returns the context keys of the id variables of the resource routes,
+// computed at registration for the route handling the request
+func (res *Resource) idKeys(ctx context.Context) []interface{} {
+	meta := routeFromContext(ctx)
+	if meta != nil && meta.res == res {
+		return meta.idKeys
+	}
+
+	return variableKeys(res.idParams())
+}
+
+// variableKeys returns the context keys of pattern variables
+func variableKeys(names []string) []interface{} {
+	keys := make([]interface{}, len(names))
+	for index, name := range names {
+		keys[index] = pattern.Variable(name)
+	}
+
+	return keys
+}
+
+// routeID returns the id matched by a route, encoding composite ids, or false for
+// routes without one
+func (res *Resource) routeID(ctx context.Context) (string, bool) {
+	keys := res.idKeys(ctx)
+	if res.compositeID == nil {
+		id, hasID := ctx.Value(keys[0]).(string)
+		return id, hasID
+	}
+
+	values := make([]string, len(keys))
+	for index, key := range keys {
+		value, hasValue := ctx.Value(key).(string)
+		if !hasValue {
+			return "", false
+		}
+		values[index] = value
+	}
+
+	return CompositeID(values...), true
+}
+
+// compositeKey decodes a composite id of the resource into its key by name
+func (res *Resource) compositeKey(id string) (map[string]string, error) {
+	values, err := ParseCompositeID(id, len(res.compositeID))
+	if err != nil {
+		return nil, err
+	}
+
+	key := make(map[string]string, len(values))
+	for index, name := range res.compositeID {
+		key[name] = values[index]
+	}
+
+	return key, nil
+}
+
+// idPath is the path of an object below the resource path, with one segment per
+// value of composite ids
+func (res *Resource) idPath(id string) string {
+	if res.compositeID == nil {
+		return id
+	}
+
+	values, err := ParseCompositeID(id, len(res.compositeID))
+	if err != nil {
+		return id
+	}
+
+	for index, value := range values {
+		values[index] = url.PathEscape(value)
+	}
+
+	return path.Join(values...)
+}
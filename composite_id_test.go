@@ -0,0 +1,115 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompositeID(t *testing.T) {
+
+	Convey("Composite ID Tests", t, func() {
+
+		var key map[string]string
+		var received string
+
+		get := func(ctx context.Context, k map[string]string) (*jsh.Object, jsh.ErrorType) {
+			key = k
+			return sampleObject(CompositeID(k["region"], k["id"]), "deployments", testObjAttrs), nil
+		}
+		save := func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+			object.ID = CompositeID("eu/west", "7")
+			return object, nil
+		}
+		owners := func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			received = id
+			return jsh.List{}, nil
+		}
+
+		resource := NewResource("deployments", WithCompositeID("region", "id"))
+		resource.GetComposite(get)
+		resource.Post(save)
+		resource.ToMany("owners", owners)
+
+		api := New("")
+		api.Add(resource)
+
+		send := func(method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should generate a variable per key value", func() {
+			So(api.RouteTree(), ShouldEqual, strings.Join([]string{
+				"POST - /deployments",
+				"GET - /deployments/:region/:id",
+				"GET - /deployments/:region/:id/owners",
+				"GET - /deployments/:region/:id/relationships/owners",
+				"",
+			}, "\n"))
+		})
+
+		Convey("should pass the key to composite storage", func() {
+			recorder := send("GET", "/deployments/eu/42", "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(key, ShouldResemble, map[string]string{"region": "eu", "id": "42"})
+			So(recorder.Body.String(), ShouldContainSubstring, `"id": "eu,42"`)
+
+			So(send("GET", "/deployments/eu%2Fwest/7", "").Code, ShouldEqual, http.StatusOK)
+			So(key, ShouldResemble, map[string]string{"region": "eu/west", "id": "7"})
+		})
+
+		Convey("should pass the composite id to relationship storage", func() {
+			So(send("GET", "/deployments/eu/42/owners", "").Code, ShouldEqual, http.StatusOK)
+			So(received, ShouldEqual, "eu,42")
+		})
+
+		Convey("should point the Location header at the key values", func() {
+			recorder := send("POST", "/deployments", `{"data": {"type": "deployments", "attributes": {"foo": "bar"}}}`)
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+			So(recorder.Header().Get("Location"), ShouldEqual, "/deployments/eu%2Fwest/7")
+		})
+
+		Convey("should check each value against the id pattern", func() {
+			resource.IDPattern(Slug)
+
+			So(send("GET", "/deployments/eu/42", "").Code, ShouldEqual, http.StatusOK)
+			So(send("GET", "/deployments/EU/42", "").Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("->ResourceKey()", func() {
+			So(ResourceKey(context.Background(), resource), ShouldBeNil)
+		})
+
+		Convey("->CompositeID()", func() {
+			values := []string{"a,b", "c/d", "e%f", ""}
+			id := CompositeID(values...)
+			So(id, ShouldEqual, "a%2Cb,c%2Fd,e%25f,")
+
+			parsed, err := ParseCompositeID(id, len(values))
+			So(err, ShouldBeNil)
+			So(parsed, ShouldResemble, values)
+
+			_, err = ParseCompositeID(id, 3)
+			So(err, ShouldNotBeNil)
+
+			_, err = ParseCompositeID("a%zz,b", 2)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("should panic on invalid names", func() {
+			So(func() { NewResource("deployments", WithCompositeID("id")) }, ShouldPanic)
+			So(func() { NewResource("deployments", WithCompositeID("id", "id")) }, ShouldPanic)
+			So(func() { NewResource("deployments", WithCompositeID("region", "the-id")) }, ShouldPanic)
+		})
+	})
+}
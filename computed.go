@@ -0,0 +1,169 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// defaultAttribute is an attribute added to created objects omitting it
+type defaultAttribute struct {
+	name  string
+	value json.RawMessage
+}
+
+/*
+DefaultAttributes adds attributes, named as sent, to the objects created through
+the resource when omitted, before they are validated. Later calls add to or
+replace the defaults of earlier ones. Panics when a value can't be marshaled.
+
+	tasks.DefaultAttributes(map[string]interface{}{"status": "open", "priority": 3})
+*/
+func (res *Resource) DefaultAttributes(defaults map[string]interface{}) {
+	res.checkRegistration("default attributes")
+
+	for name, value := range defaults {
+		marshaled, err := json.Marshal(value)
+		if err != nil {
+			panic(fmt.Sprintf("jshapi: default attribute '%s' of '%s' can't be marshaled: %s", name, res.Type, err))
+		}
+
+		replaced := false
+		for index, attribute := range res.defaults {
+			if attribute.name == name {
+				res.defaults[index].value, replaced = marshaled, true
+			}
+		}
+		if !replaced {
+			res.defaults = append(res.defaults, defaultAttribute{name: name, value: marshaled})
+		}
+	}
+
+	// defaults are added in a stable order
+	sort.Slice(res.defaults, func(i, j int) bool {
+		return res.defaults[i].name < res.defaults[j].name
+	})
+}
+
+// applyDefaults adds the default attributes omitted by a created object
+func (res *Resource) applyDefaults(object *jsh.Object) {
+	if len(res.defaults) == 0 || object == nil {
+		return
+	}
+
+	written := attributeNames(object.Attributes)
+	for _, attribute := range res.defaults {
+		if !written[attribute.name] {
+			object.Attributes = appendAttribute(object.Attributes, attribute.name, attribute.value)
+		}
+	}
+}
+
+// ComputedFunc computes the value of an attribute of an object sent, from the
+// object as returned by storage
+type ComputedFunc func(ctx context.Context, object *jsh.Object) (interface{}, error)
+
+/*
+ComputedAttribute adds an attribute, derived from the other attributes of the
+objects of the resource type, to those objects wherever they are sent, primary
+data and included objects alike:
+
+	users.ComputedAttribute("display-name", func(ctx context.Context, object *jsh.Object) (interface{}, error) {
+		user := &User{}
+		err := object.Unmarshal("users", user)
+		...
+		return user.FirstName + " " + user.LastName, err
+	})
+
+The object is the one returned by storage, before key casing and codecs apply, and
+the computed value replaces any stored one. Attributes left out by the sparse
+fieldsets of the request are not computed, and failures fail the request with a
+500 naming the attribute. Objects written by clients with the attribute are
+rejected with a 400 pointing at it.
+*/
+func (res *Resource) ComputedAttribute(name string, compute ComputedFunc) {
+	res.checkRegistration("a computed attribute")
+
+	if name == "" || compute == nil {
+		panic(fmt.Sprintf("jshapi: computed attribute of '%s' requires a name and a function", res.Type))
+	}
+
+	if res.computed == nil {
+		res.computed = map[string]ComputedFunc{}
+	}
+	res.computed[name] = compute
+}
+
+/*
+computeAttributes returns the attributes of an object of the resource, as sent,
+with its computed attributes added, those left out of fieldset skipped.
+*/
+func (res *Resource) computeAttributes(ctx context.Context, object *jsh.Object, attributes json.RawMessage, fieldset map[string]bool) (json.RawMessage, jsh.ErrorType) {
+	if len(res.computed) == 0 {
+		return attributes, nil
+	}
+
+	for _, name := range res.computedNames(fieldset) {
+		value, err := res.computed[name](ctx, object)
+		var marshaled json.RawMessage
+		if err == nil {
+			marshaled, err = json.Marshal(value)
+		}
+		if err != nil {
+			return nil, &jsh.Error{
+				Title:  jsh.DefaultErrorTitle,
+				Detail: fmt.Sprintf("Unable to compute attribute '%s' of '%s'", name, object.Type),
+				Status: http.StatusInternalServerError,
+				ISE:    err.Error(),
+			}
+		}
+
+		attributes = appendAttribute(attributes, name, marshaled)
+	}
+
+	return attributes, nil
+}
+
+// computedNames returns the names of the computed attributes in fieldset, or all
+// of them without fieldset, in order
+func (res *Resource) computedNames(fieldset map[string]bool) []string {
+	names := make([]string, 0, len(res.computed))
+	for name := range res.computed {
+		if fieldset == nil || fieldset[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// computedErrors rejects the computed attributes written by clients
+func (res *Resource) computedErrors(object *jsh.Object) jsh.ErrorList {
+	if len(res.computed) == 0 || object == nil {
+		return nil
+	}
+
+	var errs jsh.ErrorList
+	written := attributeNames(object.Attributes)
+	for _, name := range res.computedNames(nil) {
+		if !written[name] {
+			continue
+		}
+
+		err := &jsh.Error{
+			Title:  "Bad Request",
+			Detail: fmt.Sprintf("Attribute '%s' is computed by the server", name),
+			Status: http.StatusBadRequest,
+		}
+		err.Source.Pointer = "/data/attributes/" + escapePointer(name)
+		errs = append(errs, err)
+	}
+
+	return errs
+}
@@ -0,0 +1,161 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestComputedAttributes(t *testing.T) {
+
+	Convey("Computed Attributes Tests", t, func() {
+
+		user := func(id string) *jsh.Object {
+			object, _ := jsh.NewObject(id, "users", map[string]string{"first": "Jo", "last": "Doe"})
+			object.Relationships = map[string]*jsh.Relationship{
+				"manager": {Data: jsh.ResourceLinkage{{Type: "users", ID: "9"}}},
+			}
+			return object
+		}
+
+		var saved map[string]interface{}
+		save := func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+			saved = map[string]interface{}{}
+			json.Unmarshal(object.Attributes, &saved)
+			return user("1"), nil
+		}
+
+		users := NewResource("users")
+		users.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return user(id), nil
+		})
+		users.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+			return jsh.List{user("1"), user("2")}, nil
+		})
+		users.ToOne("manager", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return user("9"), nil
+		})
+		users.Post(save)
+		users.Patch(save)
+
+		computed := 0
+		var computeErr error
+		users.ComputedAttribute("display-name", func(ctx context.Context, object *jsh.Object) (interface{}, error) {
+			computed++
+			attributes := map[string]string{}
+			json.Unmarshal(object.Attributes, &attributes)
+			return attributes["first"] + " " + attributes["last"] + " (" + object.ID + ")", computeErr
+		})
+
+		api := New("")
+		serve := func(method string, url string, body string) *httptest.ResponseRecorder {
+			if len(api.Resources) == 0 {
+				api.Add(users)
+			}
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			if body != "" {
+				request.Header.Set("Content-Type", jsh.ContentType)
+			}
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("->ComputedAttribute()", func() {
+
+			Convey("should add computed attributes to objects", func() {
+				recorder := serve("GET", "/users/1", "")
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"display-name": "Jo Doe (1)"`)
+			})
+
+			Convey("should add computed attributes to lists and included objects", func() {
+				So(serve("GET", "/users", "").Body.String(), ShouldContainSubstring, `"display-name": "Jo Doe (2)"`)
+				So(serve("GET", "/users/1?include=manager", "").Body.String(), ShouldContainSubstring, `"display-name": "Jo Doe (9)"`)
+			})
+
+			Convey("should respect sparse fieldsets", func() {
+				recorder := serve("GET", "/users/1?fields[users]=first", "")
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "display-name")
+				So(computed, ShouldEqual, 0)
+
+				recorder = serve("GET", "/users/1?fields[users]=display-name", "")
+				So(recorder.Body.String(), ShouldContainSubstring, `"display-name": "Jo Doe (1)"`)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "first")
+			})
+
+			Convey("should fail requests with a 500 naming the attribute", func() {
+				computeErr = errors.New("no name")
+
+				recorder := serve("GET", "/users/1", "")
+				So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+				So(recorder.Body.String(), ShouldContainSubstring, "Unable to compute attribute 'display-name' of 'users'")
+				So(recorder.Body.String(), ShouldNotContainSubstring, "no name")
+			})
+
+			Convey("should reject clients writing computed attributes", func() {
+				recorder := serve("PATCH", "/users/1", `{"data": {"type": "users", "id": "1", "attributes": {"display-name": "Al"}}}`)
+
+				So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+				So(recorder.Body.String(), ShouldContainSubstring, `"pointer": "/data/attributes/display-name"`)
+				So(saved, ShouldBeNil)
+			})
+
+			Convey("should panic without a name or a function", func() {
+				So(func() { users.ComputedAttribute("", nil) }, ShouldPanicWith,
+					"jshapi: computed attribute of 'users' requires a name and a function")
+			})
+		})
+
+		Convey("->DefaultAttributes()", func() {
+			users.DefaultAttributes(map[string]interface{}{"role": "member", "active": true})
+
+			Convey("should add omitted attributes to created objects", func() {
+				recorder := serve("POST", "/users", `{"data": {"type": "users", "attributes": {"first": "Al", "role": "admin"}}}`)
+
+				So(recorder.Code, ShouldEqual, http.StatusCreated)
+				So(saved, ShouldResemble, map[string]interface{}{"first": "Al", "role": "admin", "active": true})
+			})
+
+			Convey("should add defaults before validation", func() {
+				var validated map[string]interface{}
+				users.AddValidator(func(ctx context.Context, object *jsh.Object) jsh.ErrorType {
+					validated = map[string]interface{}{}
+					json.Unmarshal(object.Attributes, &validated)
+					return nil
+				})
+
+				serve("POST", "/users", `{"data": {"type": "users"}}`)
+				So(validated, ShouldResemble, map[string]interface{}{"role": "member", "active": true})
+			})
+
+			Convey("should leave updated objects as they are", func() {
+				serve("PATCH", "/users/1", `{"data": {"type": "users", "id": "1", "attributes": {"first": "Al"}}}`)
+				So(saved, ShouldResemble, map[string]interface{}{"first": "Al"})
+			})
+
+			Convey("should replace earlier defaults", func() {
+				users.DefaultAttributes(map[string]interface{}{"role": "guest"})
+
+				serve("POST", "/users", `{"data": {"type": "users", "attributes": {}}}`)
+				So(saved, ShouldResemble, map[string]interface{}{"role": "guest", "active": true})
+			})
+
+			Convey("should panic on values that can't be marshaled", func() {
+				So(func() { users.DefaultAttributes(map[string]interface{}{"bad": func() {}}) }, ShouldPanic)
+			})
+		})
+	})
+}
@@ -0,0 +1,228 @@
+package jshapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store/memstore"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	// concurrentWorkers is the number of clients sending requests at once
+	concurrentWorkers = 16
+	// concurrentRequests is the number of requests sent by each client
+	concurrentRequests = 30
+)
+
+// countMetrics counts observations, it is safe for concurrent use
+type countMetrics struct {
+	requests int64
+	storage  int64
+}
+
+func (m *countMetrics) ObserveRequest(route Route, status int, duration time.Duration, reqBytes, respBytes int64) {
+	atomic.AddInt64(&m.requests, 1)
+}
+
+func (m *countMetrics) ObserveStorage(route Route, call string, duration time.Duration, failed bool) {
+	atomic.AddInt64(&m.storage, 1)
+}
+
+// countTracer counts the spans it starts, it is safe for concurrent use
+type countTracer struct {
+	spans int64
+}
+
+func (t *countTracer) Start(ctx context.Context, name string) (context.Context, func(error)) {
+	atomic.AddInt64(&t.spans, 1)
+	return ctx, func(error) {}
+}
+
+// registry is a copy of the route and relationship registries of an API
+type registry struct {
+	routes        []Route
+	resources     map[string][]string
+	relationships map[string]map[string]Relationship
+}
+
+// snapshotRegistry copies the registries of an API
+func snapshotRegistry(api *API) registry {
+	snapshot := registry{
+		routes:        api.Routes(),
+		resources:     map[string][]string{},
+		relationships: map[string]map[string]Relationship{},
+	}
+
+	for resourceType, resource := range api.Resources {
+		snapshot.resources[resourceType] = resource.RouteList()
+		snapshot.relationships[resourceType] = resource.RelationshipMap()
+	}
+
+	return snapshot
+}
+
+// concurrentAPI registers CRUD, relationship, and action routes on several
+// resources, along with every optional API feature that keeps request state
+func concurrentAPI() *API {
+	ctx := context.Background()
+
+	foos := memstore.New("foos")
+	bars := memstore.New(testResourceType)
+	for i := 0; i < 3; i++ {
+		foos.Save(ctx, sampleObject("", "foos", testObjAttrs))
+		bars.Save(ctx, sampleObject("", testResourceType, testObjAttrs))
+	}
+	bars.Link("1", "foos", "1", "2")
+
+	barResource := NewCRUDResource(testResourceType, bars)
+	barResource.ToMany("foos", bars.ToMany("foos", foos))
+	barResource.ToOne("foo", foos.Get)
+	barResource.Action("touch", bars.Get)
+	barResource.StrictMemberNames(3)
+	barResource.AddValidator(func(ctx context.Context, object *jsh.Object) jsh.ErrorType {
+		return nil
+	})
+
+	api := New("api")
+	api.UseC(RequestID())
+	api.UseC(AccessLog(StdLogger(log.New(ioutil.Discard, "", 0))))
+	api.SetMetrics(&countMetrics{})
+	api.SetTracer(&countTracer{})
+	api.Add(barResource)
+	api.Add(NewCRUDResource("foos", foos))
+	api.Add(NewCRUDResource("bazs", memstore.New("bazs")))
+	api.AtomicOperations("operations")
+	api.Freeze()
+
+	return api
+}
+
+// concurrentRequest builds the i-th request of the mixed request sequence
+func concurrentRequest(baseURL string, i int) (*http.Request, error) {
+	type call struct {
+		method string
+		path   string
+		body   string
+	}
+
+	created := fmt.Sprintf("%d", 4+i%50)
+	calls := []call{
+		{"GET", "/bars", ""},
+		{"GET", "/bars/1", ""},
+		{"HEAD", "/bars/1", ""},
+		{"POST", "/bars", `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`},
+		{"PATCH", "/bars/2", `{"data": {"type": "bars", "id": "2", "attributes": {"foo": "baz"}}}`},
+		{"DELETE", "/bars/" + created, ""},
+		{"GET", "/bars/1/foos", ""},
+		{"GET", "/bars/1/relationships/foos", ""},
+		{"GET", "/bars/1/foo", ""},
+		{"GET", "/bars/3/touch", ""},
+		{"GET", "/foos", ""},
+		{"POST", "/foos", `{"data": {"type": "foos", "attributes": {"foo": "bar"}}}`},
+		{"POST", "/bazs", `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`},
+		{"GET", "/bazs/missing", ""},
+		{"GET", "/unknown", ""},
+		{"POST", "/operations", `{"atomic:operations": [{"op": "add", "data": {"type": "bazs", "attributes": {"foo": "bar"}}}]}`},
+	}
+
+	selected := calls[i%len(calls)]
+	request, err := http.NewRequest(selected.method, baseURL+"/api"+selected.path, strings.NewReader(selected.body))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", jsh.ContentType)
+	if selected.path == "/operations" {
+		request.Header.Set("Content-Type", AtomicContentType)
+	}
+
+	return request, nil
+}
+
+// sendConcurrently sends the mixed requests from concurrent clients, returning a
+// description of every failed request
+func sendConcurrently(baseURL string) []string {
+	var mutex sync.Mutex
+	failures := []string{}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrentWorkers; worker++ {
+		wg.Add(1)
+
+		go func(worker int) {
+			defer wg.Done()
+
+			for i := 0; i < concurrentRequests; i++ {
+				failure := sendOnce(baseURL, worker*concurrentRequests+i)
+				if failure != "" {
+					mutex.Lock()
+					failures = append(failures, failure)
+					mutex.Unlock()
+				}
+			}
+		}(worker)
+	}
+
+	wg.Wait()
+	return failures
+}
+
+// sendOnce sends a single request, describing it if it failed
+func sendOnce(baseURL string, i int) string {
+	request, err := concurrentRequest(baseURL, i)
+	if err != nil {
+		return err.Error()
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Sprintf("%s %s: %s", request.Method, request.URL.Path, err.Error())
+	}
+	defer response.Body.Close()
+
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode >= 500 {
+		return fmt.Sprintf("%s %s: %d\n%s", request.Method, request.URL.Path, response.StatusCode, body)
+	}
+
+	return ""
+}
+
+/*
+TestConcurrentRequests serves mixed requests from concurrent clients against a
+fully registered API, run it with the race detector:
+
+	go test -race -run TestConcurrentRequests
+*/
+func TestConcurrentRequests(t *testing.T) {
+
+	Convey("Concurrent Request Tests", t, func() {
+
+		api := concurrentAPI()
+		registered := snapshotRegistry(api)
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		Convey("should serve concurrent requests without server errors", func() {
+			So(sendConcurrently(server.URL), ShouldBeEmpty)
+		})
+
+		Convey("should not modify the registries at request time", func() {
+			sendConcurrently(server.URL)
+			So(snapshotRegistry(api), ShouldResemble, registered)
+		})
+	})
+}
@@ -0,0 +1,470 @@
+/*
+Package conformance runs a battery of JSON API specification checks against a
+jshapi Resource, reporting each failure with the section of the specification it
+violates:
+
+	func TestUsersConformance(t *testing.T) {
+		users := memstore.New("users")
+		conformance.Run(t, jshapi.NewCRUDResource("users", users), conformance.Options{
+			Attributes: map[string]interface{}{"name": "jane"},
+		})
+	}
+
+The checks only rely on the registered routes, so the suite can run against real
+storage too. Checks exercising a feature the resource has not registered are
+skipped rather than failed.
+*/
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api"
+	"github.com/derekdowling/jsh-api/jshapitest"
+)
+
+// SpecURL is the base URL of the specification sections referenced in failures
+const SpecURL = "https://jsonapi.org/format/"
+
+// MissingID is used as the id of an object that does not exist
+const MissingID = "jshapi-conformance-missing"
+
+// Options configures a conformance run
+type Options struct {
+	/*
+		Seed stores an object of the resource type and returns its id. It is called
+		by the checks that need an existing object, which is created through POST
+		when Seed is not set. Those checks are skipped when neither is available.
+	*/
+	Seed func(t testing.TB) string
+	// Attributes are those of the objects created through POST, they must pass
+	// the validation of the resource
+	Attributes map[string]interface{}
+}
+
+// check is a single conformance check
+type check struct {
+	name string
+	run  func(s *suite)
+}
+
+var checks = []check{
+	{"content-type", checkContentType},
+	{"not-found", checkNotFound},
+	{"empty-list", checkList},
+	{"fetch", checkFetch},
+	{"create", checkCreate},
+	{"type-conflict", checkTypeConflict},
+	{"id-conflict", checkIDConflict},
+	{"relationships", checkRelationships},
+	{"delete", checkDelete},
+}
+
+/*
+Run mounts the resource on a new API without prefix and runs every conformance
+check against it as a subtest.
+*/
+func Run(t *testing.T, resource *jshapi.Resource, opts Options) {
+	api := jshapi.New("")
+	api.Add(resource)
+
+	for _, c := range checks {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.run(newSuite(t, api, resource, opts))
+		})
+	}
+}
+
+// suite holds the state of a single check
+type suite struct {
+	t          testing.TB
+	client     *jshapitest.Client
+	resource   *jshapi.Resource
+	operations map[jshapi.Operation]bool
+	opts       Options
+}
+
+func newSuite(t testing.TB, api *jshapi.API, resource *jshapi.Resource, opts Options) *suite {
+	operations := map[jshapi.Operation]bool{}
+	for _, route := range api.Routes() {
+		if route.ResourceType == resource.Type {
+			operations[route.Operation] = true
+		}
+	}
+
+	return &suite{
+		t:          t,
+		client:     jshapitest.NewClient(t, api),
+		resource:   resource,
+		operations: operations,
+		opts:       opts,
+	}
+}
+
+// fail reports a violation of the given specification section
+func (s *suite) fail(section string, format string, args ...interface{}) {
+	s.t.Helper()
+	s.t.Errorf("%s (%s#%s)", fmt.Sprintf(format, args...), SpecURL, section)
+}
+
+// require skips the check unless the resource registered all given operations
+func (s *suite) require(operations ...jshapi.Operation) {
+	s.t.Helper()
+
+	for _, op := range operations {
+		if !s.operations[op] {
+			s.t.Skipf("resource '%s' does not register the %s operation", s.resource.Type, op)
+		}
+	}
+}
+
+// existing returns the id of an existing object, seeding or creating one
+func (s *suite) existing() string {
+	s.t.Helper()
+
+	if s.opts.Seed != nil {
+		return s.opts.Seed(s.t)
+	}
+
+	if !s.operations[jshapi.OpCreate] {
+		s.t.Skipf("resource '%s' has no Seed and does not register the create operation", s.resource.Type)
+	}
+
+	return s.client.Post(s.resource.Type, s.opts.Attributes).ID
+}
+
+// do sends a request and checks the content type of the response
+func (s *suite) do(method string, path string, body interface{}) (*jshapitest.Response, map[string]interface{}) {
+	s.t.Helper()
+
+	response := s.client.Do(method, path, body)
+	if response.StatusCode == http.StatusNoContent {
+		return response, nil
+	}
+
+	contentType := response.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != jsh.ContentType {
+		s.fail("content-negotiation-servers", "%s %s: expected Content-Type %s, got '%s'", method, path, jsh.ContentType, contentType)
+		return response, nil
+	}
+
+	document := map[string]interface{}{}
+	err = json.Unmarshal(response.Body, &document)
+	if err != nil {
+		s.fail("document-structure", "%s %s: response is not a JSON object: %s", method, path, err.Error())
+		return response, nil
+	}
+
+	s.checkTopLevel(method, path, document)
+	return response, document
+}
+
+// objectBody builds a request document for a single object
+func (s *suite) objectBody(id string, resourceType string) map[string]interface{} {
+	attributes := s.opts.Attributes
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+
+	data := map[string]interface{}{
+		"type":       resourceType,
+		"attributes": attributes,
+	}
+	if id != "" {
+		data["id"] = id
+	}
+
+	return map[string]interface{}{"data": data}
+}
+
+// checkTopLevel validates the members of a response document
+func (s *suite) checkTopLevel(method string, path string, document map[string]interface{}) {
+	s.t.Helper()
+
+	_, hasData := document["data"]
+	errs, hasErrors := document["errors"]
+	_, hasMeta := document["meta"]
+
+	if !hasData && !hasErrors && !hasMeta {
+		s.fail("document-top-level", "%s %s: document must contain at least one of data, errors, or meta", method, path)
+	}
+
+	if hasData && hasErrors {
+		s.fail("document-top-level", "%s %s: data and errors must not coexist", method, path)
+	}
+
+	if hasErrors {
+		s.checkErrors(method, path, errs)
+	}
+}
+
+// checkErrors validates the structure of error objects
+func (s *suite) checkErrors(method string, path string, errs interface{}) {
+	s.t.Helper()
+
+	list, isArray := errs.([]interface{})
+	if !isArray || len(list) == 0 {
+		s.fail("error-objects", "%s %s: errors must be a non-empty array", method, path)
+		return
+	}
+
+	for index, item := range list {
+		errObject, isObject := item.(map[string]interface{})
+		if !isObject {
+			s.fail("error-objects", "%s %s: errors[%d] must be an object", method, path, index)
+			continue
+		}
+
+		for _, member := range []string{"id", "status", "code", "title", "detail"} {
+			value, exists := errObject[member]
+			if _, isString := value.(string); exists && !isString {
+				s.fail("error-objects", "%s %s: errors[%d].%s must be a string", method, path, index, member)
+			}
+		}
+
+		source, hasSource := errObject["source"]
+		if _, isObject := source.(map[string]interface{}); hasSource && !isObject {
+			s.fail("error-objects", "%s %s: errors[%d].source must be an object", method, path, index)
+		}
+	}
+}
+
+// checkIdentifier validates the type and id members of a resource object or
+// resource identifier object
+func (s *suite) checkIdentifier(section string, method string, path string, value interface{}, expectedType string) (string, bool) {
+	s.t.Helper()
+
+	object, isObject := value.(map[string]interface{})
+	if !isObject {
+		s.fail(section, "%s %s: expected a resource object, got %s", method, path, describe(value))
+		return "", false
+	}
+
+	objectType, _ := object["type"].(string)
+	id, _ := object["id"].(string)
+
+	if objectType == "" || id == "" {
+		s.fail("document-resource-object-identification", "%s %s: resource objects must have string type and id members", method, path)
+		return "", false
+	}
+
+	if expectedType != "" && objectType != expectedType {
+		s.fail(section, "%s %s: expected type '%s', got '%s'", method, path, expectedType, objectType)
+	}
+
+	return id, true
+}
+
+// describe names the JSON type of a decoded value for failure messages
+func describe(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// checkContentType requests the collection or an object to check response headers,
+// the other checks verify the content type of each response they receive
+func checkContentType(s *suite) {
+	switch {
+	case s.operations[jshapi.OpList]:
+		s.do("GET", s.client.Path(s.resource.Type), nil)
+	case s.operations[jshapi.OpRead]:
+		s.do("GET", s.client.Path(s.resource.Type, MissingID), nil)
+	default:
+		s.t.Skipf("resource '%s' does not register the list or read operations", s.resource.Type)
+	}
+}
+
+// checkNotFound fetches an object that does not exist
+func checkNotFound(s *suite) {
+	s.require(jshapi.OpRead)
+
+	path := s.client.Path(s.resource.Type, MissingID)
+	response, document := s.do("GET", path, nil)
+
+	if response.StatusCode != http.StatusNotFound {
+		s.fail("fetching-resources-responses-404", "GET %s: expected status 404, got %d", path, response.StatusCode)
+	}
+
+	if document != nil && document["errors"] == nil {
+		s.fail("errors-processing", "GET %s: expected an error document", path)
+	}
+}
+
+// checkList fetches the collection, which must be an array even when empty
+func checkList(s *suite) {
+	s.require(jshapi.OpList)
+
+	path := s.client.Path(s.resource.Type)
+	response, document := s.do("GET", path, nil)
+
+	if response.StatusCode != http.StatusOK {
+		s.fail("fetching-resources-responses-200", "GET %s: expected status 200, got %d", path, response.StatusCode)
+		return
+	}
+
+	if document == nil {
+		return
+	}
+
+	list, isArray := document["data"].([]interface{})
+	if !isArray {
+		s.fail("fetching-resources-responses-200", "GET %s: collections must be arrays, empty ones [], got %s", path, describe(document["data"]))
+		return
+	}
+
+	for _, item := range list {
+		s.checkIdentifier("fetching-resources-responses-200", "GET", path, item, s.resource.Type)
+	}
+}
+
+// checkFetch fetches an existing object
+func checkFetch(s *suite) {
+	s.require(jshapi.OpRead)
+
+	id := s.existing()
+	path := s.client.Path(s.resource.Type, id)
+	response, document := s.do("GET", path, nil)
+
+	if response.StatusCode != http.StatusOK {
+		s.fail("fetching-resources-responses-200", "GET %s: expected status 200, got %d", path, response.StatusCode)
+		return
+	}
+
+	if document == nil {
+		return
+	}
+
+	fetchedID, valid := s.checkIdentifier("fetching-resources-responses-200", "GET", path, document["data"], s.resource.Type)
+	if valid && fetchedID != id {
+		s.fail("fetching-resources-responses-200", "GET %s: expected id '%s', got '%s'", path, id, fetchedID)
+	}
+}
+
+// checkCreate creates an object
+func checkCreate(s *suite) {
+	s.require(jshapi.OpCreate)
+
+	path := s.client.Path(s.resource.Type)
+	response, document := s.do("POST", path, s.objectBody("", s.resource.Type))
+
+	if response.StatusCode != http.StatusCreated {
+		s.fail("crud-creating-responses-201", "POST %s: expected status 201, got %d\n%s", path, response.StatusCode, response.Body)
+		return
+	}
+
+	if document == nil {
+		return
+	}
+
+	id, valid := s.checkIdentifier("crud-creating-responses-201", "POST", path, document["data"], s.resource.Type)
+	if !valid {
+		return
+	}
+
+	location := response.Header.Get("Location")
+	if !strings.HasSuffix(location, s.client.Path(s.resource.Type, id)) {
+		s.fail("crud-creating-responses-201", "POST %s: expected a Location header pointing at the new object, got '%s'", path, location)
+	}
+}
+
+// checkTypeConflict creates an object of another type
+func checkTypeConflict(s *suite) {
+	s.require(jshapi.OpCreate)
+
+	path := s.client.Path(s.resource.Type)
+	response, _ := s.do("POST", path, s.objectBody("", s.resource.Type+"-conformance-mismatch"))
+
+	if response.StatusCode != http.StatusConflict {
+		s.fail("crud-creating-responses-409", "POST %s: expected status 409 for a mismatched type, got %d", path, response.StatusCode)
+	}
+}
+
+// checkIDConflict updates an object with a mismatched id
+func checkIDConflict(s *suite) {
+	s.require(jshapi.OpUpdate)
+
+	id := s.existing()
+	path := s.client.Path(s.resource.Type, id)
+	response, _ := s.do("PATCH", path, s.objectBody(id+"-conformance-mismatch", s.resource.Type))
+
+	if response.StatusCode != http.StatusConflict {
+		s.fail("crud-updating-responses-409", "PATCH %s: expected status 409 for a mismatched id, got %d", path, response.StatusCode)
+	}
+}
+
+// checkRelationships fetches the relationships of an existing object, whose data
+// must be resource linkage
+func checkRelationships(s *suite) {
+	s.require(jshapi.OpRelationship)
+
+	id := s.existing()
+	for name, kind := range s.resource.Relationships {
+		path := s.client.Path(s.resource.Type, id, "relationships", name)
+		response, document := s.do("GET", path, nil)
+
+		if response.StatusCode != http.StatusOK {
+			s.fail("fetching-relationships-responses-200", "GET %s: expected status 200, got %d", path, response.StatusCode)
+			continue
+		}
+
+		if document == nil {
+			continue
+		}
+
+		data := document["data"]
+		switch kind {
+		case jshapi.ToOne:
+			if data != nil {
+				s.checkIdentifier("document-resource-object-linkage", "GET", path, data, "")
+			}
+
+		case jshapi.ToMany:
+			list, isArray := data.([]interface{})
+			if !isArray {
+				s.fail("document-resource-object-linkage", "GET %s: to-many linkage must be an array, got %s", path, describe(data))
+				continue
+			}
+
+			for _, item := range list {
+				s.checkIdentifier("document-resource-object-linkage", "GET", path, item, "")
+			}
+		}
+	}
+}
+
+// checkDelete deletes an existing object, which must no longer be found
+func checkDelete(s *suite) {
+	s.require(jshapi.OpDelete)
+
+	id := s.existing()
+	path := s.client.Path(s.resource.Type, id)
+	response, _ := s.do("DELETE", path, nil)
+
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK {
+		s.fail("crud-deleting-responses", "DELETE %s: expected status 204 or 200, got %d", path, response.StatusCode)
+		return
+	}
+
+	if s.operations[jshapi.OpRead] {
+		response, _ = s.do("GET", path, nil)
+		if response.StatusCode != http.StatusNotFound {
+			s.fail("fetching-resources-responses-404", "GET %s: expected status 404 after deletion, got %d", path, response.StatusCode)
+		}
+	}
+}
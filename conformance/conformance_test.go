@@ -0,0 +1,132 @@
+package conformance
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api"
+	"github.com/derekdowling/jsh-api/store/memstore"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recorder stands in for *testing.T to capture the failures of a check
+type recorder struct {
+	testing.TB
+	errors  []string
+	skipped string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+func (r *recorder) Skipf(format string, args ...interface{}) {
+	r.skipped = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// runCheck runs a single check against the resource and records its outcome
+func runCheck(resource *jshapi.Resource, opts Options, run func(s *suite)) *recorder {
+	api := jshapi.New("")
+	api.Add(resource)
+
+	t := &recorder{}
+	done := make(chan bool)
+
+	go func() {
+		defer close(done)
+		run(newSuite(t, api, resource, opts))
+	}()
+
+	<-done
+	return t
+}
+
+func TestRun(t *testing.T) {
+	tasks := memstore.New("tasks")
+	tags := memstore.New("tags")
+
+	resource := jshapi.NewCRUDResource("tasks", tasks)
+	resource.ToMany("tags", tasks.ToMany("tags", tags))
+
+	Run(t, resource, Options{
+		Seed: func(t testing.TB) string {
+			tag, _ := tags.Save(context.Background(), &jsh.Object{Type: "tags"})
+			task, _ := tasks.Save(context.Background(), &jsh.Object{Type: "tasks"})
+			tasks.Link(task.ID, "tags", tag.ID)
+
+			return task.ID
+		},
+		Attributes: map[string]interface{}{"title": "write tests"},
+	})
+}
+
+func TestChecks(t *testing.T) {
+
+	Convey("Conformance Check Tests", t, func() {
+
+		tasks := memstore.New("tasks")
+
+		Convey("should skip checks for unregistered features", func() {
+			resource := jshapi.NewResource("tasks")
+			resource.List(tasks.List)
+
+			result := runCheck(resource, Options{}, checkDelete)
+			So(result.skipped, ShouldEqual, "resource 'tasks' does not register the delete operation")
+			So(result.errors, ShouldBeEmpty)
+		})
+
+		Convey("should skip checks needing an object without Seed or create", func() {
+			resource := jshapi.NewResource("tasks")
+			resource.Get(tasks.Get)
+
+			result := runCheck(resource, Options{}, checkFetch)
+			So(result.skipped, ShouldContainSubstring, "has no Seed")
+		})
+
+		Convey("should report violations with the specification section", func() {
+			resource := jshapi.NewResource("tasks")
+			resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+				return nil, jsh.ISE("lookup failed")
+			})
+
+			result := runCheck(resource, Options{}, checkNotFound)
+			So(result.errors, ShouldHaveLength, 1)
+			So(result.errors[0], ShouldEqual, fmt.Sprintf(
+				"GET /tasks/%s: expected status 404, got 500 (https://jsonapi.org/format/#fetching-resources-responses-404)",
+				MissingID,
+			))
+		})
+
+		Convey("should report collections that are not arrays", func() {
+			resource := jshapi.NewResource("tasks")
+			resource.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+				return jsh.List{{Type: "tasks"}}, nil
+			})
+
+			result := runCheck(resource, Options{}, checkList)
+			So(result.errors, ShouldNotBeEmpty)
+		})
+
+		Convey("should report invalid linkage", func() {
+			resource := jshapi.NewCRUDResource("tasks", tasks)
+			resource.ToMany("tags", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+				return jsh.List{{Type: "tags"}}, nil
+			})
+
+			result := runCheck(resource, Options{}, checkRelationships)
+			So(len(result.errors), ShouldBeGreaterThan, 0)
+		})
+	})
+}
@@ -0,0 +1,35 @@
+package jshapi
+
+// contextKey is the type of all context keys set by jshapi
+type contextKey int
+
+const (
+	// mediaTypeKey holds the negotiated JSON API media type parameters
+	mediaTypeKey contextKey = iota
+	// mediaTypeErrorKey holds a Content-Type error left for write handlers to report
+	mediaTypeErrorKey
+	// patchFieldsKey holds the attributes present in a PATCH request body
+	patchFieldsKey
+	// resourceRouteKey holds the *routeMeta of the resource route being handled
+	resourceRouteKey
+	// routeInfoKey holds the *routeInfo filled in by resource routes
+	routeInfoKey
+	// RequestIDKey holds the id assigned to the request by the RequestID middleware
+	RequestIDKey
+	// sendingAPIKey holds the *API of the resource sending a response
+	sendingAPIKey
+	// tenantKey holds the resolvedTenant of the request in tenanted APIs
+	tenantKey
+	// parentScopeKey holds the parent ids of requests to nested resources
+	parentScopeKey
+	// storageOperationKey holds the Operation of the storage calls made with a
+	// context, when it differs from the route's
+	storageOperationKey
+	// unknownAttributesKey holds the unknown attributes written by the request when
+	// warning about them
+	unknownAttributesKey
+	// languageKey holds the *errorLanguage of requests to APIs translating errors
+	languageKey
+	// simpleJSONKey is set on reads answered in simple JSON, see AllowSimpleJSON
+	simpleJSONKey
+)
@@ -0,0 +1,225 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-stdlogger"
+)
+
+const (
+	// DefaultDebugMaxBodyBytes is the default size of captured bodies
+	DefaultDebugMaxBodyBytes = 4096
+	// redacted replaces the value of redacted attributes
+	redacted = "[REDACTED]"
+)
+
+// DebugOptions configures the capture of failed requests, see API.EnableDebug
+type DebugOptions struct {
+	// MaxBodyBytes caps the size of each captured body, defaults to
+	// DefaultDebugMaxBodyBytes
+	MaxBodyBytes int
+	// Threshold is the lowest response status captured, defaults to 400
+	Threshold int
+	// Redact lists the attribute names whose values are redacted from captures
+	Redact []string
+	// Callback receives the captures, the Logger is used when not set
+	Callback func(ctx context.Context, capture DebugCapture)
+	// Logger defaults to logging to stderr
+	Logger std.Logger
+}
+
+// DebugCapture holds the bodies of a failed request
+type DebugCapture struct {
+	Method       string
+	URL          string
+	Status       int
+	RequestBody  []byte
+	ResponseBody []byte
+	// Truncated is set when a body exceeded MaxBodyBytes, redaction then replaces
+	// the whole body as it can't be parsed
+	Truncated bool
+}
+
+/*
+EnableDebug captures the request and response bodies of failed requests, so that
+the exact body of a reported error can be found in the logs. Request bodies are
+copied as they are read, up to MaxBodyBytes, while response bodies are only
+copied once the response status is known to reach the threshold.
+*/
+func (a *API) EnableDebug(opts DebugOptions) {
+	a.checkRegistration("debug capture")
+
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultDebugMaxBodyBytes
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = http.StatusBadRequest
+	}
+	if opts.Callback == nil {
+		logger := opts.Logger
+		if logger == nil {
+			logger = log.New(os.Stderr, "jshapi: ", log.LstdFlags)
+		}
+		opts.Callback = logDebugCapture(logger)
+	}
+
+	a.Debug = true
+	a.debug = &opts
+}
+
+// captureDebug wraps a request and its writer for capture, returning the function
+// reporting the capture once the request has been served
+func (a *API) captureDebug(ctx context.Context, w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	opts := a.debug
+
+	request := &limitedBuffer{limit: opts.MaxBodyBytes}
+	if r.Body != nil {
+		r.Body = &teeReadCloser{ReadCloser: r.Body, tee: request}
+	}
+
+	response := &limitedBuffer{limit: opts.MaxBodyBytes}
+	writer, recorder := wrapAccessWriter(w)
+	recorder.onHeader = func(status int) io.Writer {
+		if status >= opts.Threshold {
+			return response
+		}
+		return nil
+	}
+
+	return writer, func() {
+		if recorder.status < opts.Threshold {
+			return
+		}
+
+		capture := DebugCapture{
+			Method:       r.Method,
+			URL:          r.URL.String(),
+			Status:       recorder.status,
+			RequestBody:  redactBody(request.Bytes(), request.truncated, opts.Redact),
+			ResponseBody: redactBody(response.Bytes(), response.truncated, opts.Redact),
+			Truncated:    request.truncated || response.truncated,
+		}
+
+		opts.Callback(ctx, capture)
+	}
+}
+
+// logDebugCapture logs captures with a logger
+func logDebugCapture(logger std.Logger) func(ctx context.Context, capture DebugCapture) {
+	return func(ctx context.Context, capture DebugCapture) {
+		var logPrefix string
+		requestID := GetRequestID(ctx)
+		if requestID != "" {
+			logPrefix = "[" + requestID + "] "
+		}
+
+		logger.Printf(
+			"%s%s %s returned %d\nRequest: %s\nResponse: %s\n",
+			logPrefix, capture.Method, capture.URL, capture.Status,
+			capture.RequestBody, capture.ResponseBody,
+		)
+	}
+}
+
+/*
+redactBody replaces the values of the redacted attributes of a JSON API body.
+Bodies that can't be parsed, including truncated ones, are entirely redacted when
+there are attributes to redact.
+*/
+func redactBody(body []byte, truncated bool, redact []string) []byte {
+	if len(redact) == 0 || len(body) == 0 {
+		return body
+	}
+
+	var document interface{}
+	if truncated || json.Unmarshal(body, &document) != nil {
+		return []byte(redacted)
+	}
+
+	names := map[string]bool{}
+	for _, name := range redact {
+		names[name] = true
+	}
+
+	if !redactAttributes(document, names) {
+		return body
+	}
+
+	content, err := json.Marshal(document)
+	if err != nil {
+		return []byte(redacted)
+	}
+
+	return content
+}
+
+// redactAttributes walks a decoded document, redacting within "attributes" objects,
+// and reports whether anything was redacted
+func redactAttributes(value interface{}, names map[string]bool) bool {
+	var found bool
+
+	switch typed := value.(type) {
+	case []interface{}:
+		for _, item := range typed {
+			found = redactAttributes(item, names) || found
+		}
+
+	case map[string]interface{}:
+		for key, member := range typed {
+			attributes, isObject := member.(map[string]interface{})
+			if key == "attributes" && isObject {
+				for name := range attributes {
+					if names[name] {
+						attributes[name] = redacted
+						found = true
+					}
+				}
+				continue
+			}
+
+			found = redactAttributes(member, names) || found
+		}
+	}
+
+	return found
+}
+
+// limitedBuffer keeps the first limit bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(content []byte) (int, error) {
+	room := b.limit - b.Len()
+	if len(content) > room {
+		b.truncated = true
+		content = content[:room]
+	}
+
+	b.Buffer.Write(content)
+	return len(content), nil
+}
+
+// teeReadCloser copies what is read from a body
+type teeReadCloser struct {
+	io.ReadCloser
+	tee io.Writer
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		t.tee.Write(p[:n])
+	}
+
+	return n, err
+}
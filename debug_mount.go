@@ -0,0 +1,158 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"goji.io"
+	"goji.io/pat"
+	"golang.org/x/net/context"
+)
+
+/*
+DebugVars is the snapshot of the API internals served by the `/vars` debug
+endpoint, see MountDebug.
+*/
+type DebugVars struct {
+	// InFlight is the number of requests being served by resource routes
+	InFlight int64 `json:"in-flight"`
+	// Goroutines is the number of goroutines of the process
+	Goroutines int `json:"goroutines"`
+	// Routes lists the resource routes with the number of requests they served
+	Routes []RouteHits `json:"routes"`
+	// StorageErrors counts the failed storage calls by call, such as "get", since
+	// the debug endpoints were mounted
+	StorageErrors map[string]uint64 `json:"storage-errors"`
+}
+
+// RouteHits is the number of requests served by a route
+type RouteHits struct {
+	Method  string `json:"method"`
+	Pattern string `json:"pattern"`
+	Hits    uint64 `json:"hits"`
+}
+
+/*
+MountDebug registers runtime debug endpoints under `/(prefix/)<route>`, every one
+of them behind middleware, which is required and is expected to authenticate the
+requests:
+
+	api.MountDebug("debug", adminOnly)
+
+The `pprof/` endpoints serve the profiles of net/http/pprof, such as
+`/debug/pprof/heap`, and the `vars` endpoint a JSON snapshot of the API, see
+DebugVars. Nothing is registered unless MountDebug is called, and storage errors
+are only counted from then on.
+*/
+func (a *API) MountDebug(route string, middleware func(goji.Handler) goji.Handler) {
+	a.checkRegistration("debug endpoints")
+
+	if middleware == nil {
+		panic("jshapi: debug endpoints require a middleware protecting them")
+	}
+
+	debug := goji.SubMux()
+	debug.UseC(middleware)
+	debug.HandleFunc(pat.Get("/pprof/"), pprof.Index)
+	debug.HandleFunc(pat.Get("/pprof/cmdline"), pprof.Cmdline)
+	debug.HandleFunc(pat.Get("/pprof/profile"), pprof.Profile)
+	debug.HandleFunc(pat.Get("/pprof/symbol"), pprof.Symbol)
+	debug.HandleFunc(pat.Post("/pprof/symbol"), pprof.Symbol)
+	debug.HandleFunc(pat.Get("/pprof/trace"), pprof.Trace)
+	// pprof.Index only serves named profiles under /debug/pprof/
+	debug.HandleFuncC(pat.Get("/pprof/:profile"), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		pprof.Handler(pat.Param(ctx, "profile")).ServeHTTP(w, r)
+	})
+	debug.HandleFuncC(pat.Get("/vars"), a.debugVarsHandler)
+
+	a.storageErrors = &storageErrorCounts{}
+
+	matcher := path.Join(a.prefix, route)
+	a.Mux.HandleC(pat.New(path.Join(matcher, "*")), debug)
+	a.routes = append(a.routes,
+		Route{Method: get, Pattern: path.Join(matcher, "pprof") + "/"},
+		Route{Method: get, Pattern: path.Join(matcher, "pprof", ":profile")},
+		Route{Method: get, Pattern: path.Join(matcher, "vars")},
+	)
+}
+
+// Vars returns a snapshot of the API internals, see DebugVars
+func (a *API) Vars() DebugVars {
+	vars := DebugVars{
+		InFlight:      a.inFlight.Load(),
+		Goroutines:    runtime.NumGoroutine(),
+		Routes:        []RouteHits{},
+		StorageErrors: a.storageErrors.snapshot(),
+	}
+
+	for _, resource := range a.Resources {
+		for registered, meta := range resource.routeOperations {
+			p := registered.(*pat.Pattern)
+			for _, method := range routeMethods(p) {
+				vars.Routes = append(vars.Routes, RouteHits{
+					Method:  method,
+					Pattern: resource.fullPattern(p.String()),
+					Hits:    meta.hits.Load(),
+				})
+			}
+		}
+	}
+	sort.Slice(vars.Routes, func(i, j int) bool {
+		if vars.Routes[i].Pattern != vars.Routes[j].Pattern {
+			return vars.Routes[i].Pattern < vars.Routes[j].Pattern
+		}
+		return vars.Routes[i].Method < vars.Routes[j].Method
+	})
+
+	return vars
+}
+
+// GET /(prefix/)debug/vars
+func (a *API) debugVarsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	content, err := json.MarshalIndent(a.Vars(), "", " ")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Write(content)
+}
+
+// storageErrorCounts counts failed storage calls by call
+type storageErrorCounts struct {
+	counts sync.Map
+}
+
+// add counts a failed call
+func (c *storageErrorCounts) add(call string) {
+	count, loaded := c.counts.Load(call)
+	if !loaded {
+		count, _ = c.counts.LoadOrStore(call, new(atomic.Uint64))
+	}
+
+	count.(*atomic.Uint64).Add(1)
+}
+
+// snapshot returns the current counts, empty before debug endpoints are mounted
+func (c *storageErrorCounts) snapshot() map[string]uint64 {
+	counts := map[string]uint64{}
+	if c == nil {
+		return counts
+	}
+
+	c.counts.Range(func(call, count interface{}) bool {
+		counts[call.(string)] = count.(*atomic.Uint64).Load()
+		return true
+	})
+
+	return counts
+}
@@ -0,0 +1,96 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMountDebug(t *testing.T) {
+
+	Convey("Mount Debug Tests", t, func() {
+
+		resource := NewResource("users")
+		resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			if id == "missing" {
+				return nil, jsh.NotFound("users", id)
+			}
+			return jsh.NewObject(id, "users", map[string]string{"name": "user"})
+		})
+
+		api := New("api")
+		api.Add(resource)
+
+		adminOnly := func(next goji.Handler) goji.Handler {
+			return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "admin" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTPC(ctx, w, r)
+			})
+		}
+
+		send := func(url string, authorization string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest("GET", url, nil)
+			request.Header.Set("Authorization", authorization)
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should not register endpoints unless mounted", func() {
+			So(send("/api/debug/vars", "admin").Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("->MountDebug()", func() {
+			api.MountDebug("debug", adminOnly)
+
+			Convey("should protect the endpoints with the middleware", func() {
+				So(send("/api/debug/vars", "").Code, ShouldEqual, http.StatusUnauthorized)
+				So(send("/api/debug/pprof/heap", "").Code, ShouldEqual, http.StatusUnauthorized)
+			})
+
+			Convey("should serve pprof profiles", func() {
+				So(send("/api/debug/pprof/", "admin").Code, ShouldEqual, http.StatusOK)
+				So(send("/api/debug/pprof/goroutine?debug=1", "admin").Body.String(), ShouldContainSubstring, "goroutine profile")
+			})
+
+			Convey("should serve counters of the API", func() {
+				send("/api/users/1", "")
+				send("/api/users/missing", "")
+
+				recorder := send("/api/debug/vars", "admin")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+
+				vars := DebugVars{}
+				So(json.Unmarshal(recorder.Body.Bytes(), &vars), ShouldBeNil)
+				So(vars.InFlight, ShouldEqual, 0)
+				var userHits *RouteHits
+				for index, route := range vars.Routes {
+					if route.Method == "GET" && route.Pattern == "/api/users/:id" {
+						userHits = &vars.Routes[index]
+					}
+				}
+				So(userHits, ShouldNotBeNil)
+				So(*userHits, ShouldResemble, RouteHits{Method: "GET", Pattern: "/api/users/:id", Hits: 2})
+				So(vars.StorageErrors, ShouldResemble, map[string]uint64{"get": 1})
+			})
+
+			Convey("should list the routes", func() {
+				So(api.RouteTree(), ShouldContainSubstring, "GET - /api/debug/vars\n")
+			})
+		})
+
+		Convey("should require a middleware", func() {
+			So(func() { api.MountDebug("debug", nil) }, ShouldPanicWith, "jshapi: debug endpoints require a middleware protecting them")
+		})
+	})
+}
@@ -0,0 +1,65 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDebug(t *testing.T) {
+
+	Convey("Debug Tests", t, func() {
+
+		var captures []DebugCapture
+
+		api := New("")
+		api.EnableDebug(DebugOptions{
+			Redact: []string{"password"},
+			Callback: func(ctx context.Context, capture DebugCapture) {
+				captures = append(captures, capture)
+			},
+		})
+		api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/" + testResourceType
+
+		Convey("should capture the bodies of failed requests", func() {
+			body := `{"data": {"type": "foos", "attributes": {"foo": "bar", "password": "secret"}}}`
+			resp, content := negotiationRequest("POST", url, jsh.ContentType, "", body)
+			So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+
+			So(captures, ShouldHaveLength, 1)
+			So(captures[0].Method, ShouldEqual, "POST")
+			So(captures[0].Status, ShouldEqual, http.StatusConflict)
+			So(string(captures[0].RequestBody), ShouldContainSubstring, `"foo":"bar"`)
+			So(string(captures[0].RequestBody), ShouldContainSubstring, `"password":"[REDACTED]"`)
+			So(string(captures[0].RequestBody), ShouldNotContainSubstring, "secret")
+			So(string(captures[0].ResponseBody), ShouldEqual, content)
+		})
+
+		Convey("should not capture successful requests", func() {
+			resp, _ := negotiationRequest("POST", url, jsh.ContentType, "", `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+			So(captures, ShouldBeEmpty)
+		})
+
+		Convey("->redactBody()", func() {
+
+			Convey("should redact truncated bodies entirely", func() {
+				So(string(redactBody([]byte(`{"data": {"attrib`), true, []string{"password"})), ShouldEqual, redacted)
+			})
+
+			Convey("should leave bodies untouched without redacted attributes", func() {
+				So(string(redactBody([]byte(`{"data`), true, nil)), ShouldEqual, `{"data`)
+			})
+		})
+	})
+}
@@ -0,0 +1,122 @@
+package jshapi
+
+import (
+	"fmt"
+	"strings"
+
+	"goji.io"
+	"goji.io/pat"
+
+	"github.com/derekdowling/jsh-api/store"
+)
+
+/*
+Disable removes the route of the resource matching method and pattern, such as
+`res.Disable("DELETE", "/:id")` for a resource built by third party code. The
+pattern is either relative to the resource, as given to the registration helpers,
+or full, as listed by RouteTree. The path of the route then answers the method
+with a 405 and an Allow header listing the remaining methods, OPTIONS included,
+and the route disappears from RegisteredRoutes. Storage of disabled CRUD routes
+is no longer used by atomic operations either.
+
+HEAD is served by GET routes and can't be disabled on its own. Disable returns an
+error if no such route is registered, and panics once the resource serves requests
+or is frozen, like other registrations.
+*/
+func (res *Resource) Disable(method string, pattern string) error {
+	defer res.record(func(clone *Resource) { clone.Disable(method, pattern) })()
+
+	res.checkRegistration(fmt.Sprintf("a change to route %s %s", method, pattern))
+	if res.registry.isDone() {
+		panic(fmt.Sprintf("jshapi: unable to disable route %s %s, resource '%s' already serves requests", method, pattern, res.Type))
+	}
+
+	method = strings.ToUpper(method)
+	if method == "HEAD" {
+		return fmt.Errorf("jshapi: HEAD is served by GET routes, disable GET %s instead", pattern)
+	}
+
+	relative := strings.TrimPrefix(pattern, res.fullPattern(""))
+	for index, route := range res.registry.pending {
+		typed, isPat := route.pattern.(*pat.Pattern)
+		if !isPat || (typed.String() != pattern && typed.String() != relative) {
+			continue
+		}
+		if _, handles := typed.HTTPMethods()[method]; !handles {
+			continue
+		}
+
+		res.registry.pending = append(res.registry.pending[:index], res.registry.pending[index+1:]...)
+		res.forgetRoute(typed, method)
+		return nil
+	}
+
+	return fmt.Errorf("jshapi: no route %s %s is registered on resource '%s'", method, pattern, res.Type)
+}
+
+// forgetRoute removes a route from the registry and the storage of its operation
+func (res *Resource) forgetRoute(p *pat.Pattern, method string) {
+	meta, registered := res.routeOperations[p]
+	if !registered {
+		return
+	}
+
+	// the delete builtin is shadowed by the package method constant
+	operations := make(map[goji.Pattern]*routeMeta, len(res.routeOperations))
+	for registered, registeredMeta := range res.routeOperations {
+		if registered != p {
+			operations[registered] = registeredMeta
+		}
+	}
+	res.routeOperations = operations
+	res.forgetRouteName(meta)
+
+	listed := []string{fmt.Sprintf("%s - /%s%s", method, res.CollectionPath(), p.String())}
+	if meta.op == OpList {
+		listed = append(listed, fmt.Sprintf("%s - /%s%s", list, res.CollectionPath(), p.String()))
+	}
+
+	routes := []string{}
+	for _, route := range res.Routes {
+		if !containsString(listed, route) {
+			routes = append(routes, route)
+		}
+	}
+	res.Routes = routes
+
+	switch meta.op {
+	case OpRelationship:
+		res.forgetInclude(p.String())
+	case OpCreate:
+		res.storage.save = nil
+	case OpRead:
+		res.storage.get = nil
+	case OpUpdate:
+		res.storage.update = nil
+	case OpDelete:
+		res.storage.delete = nil
+	}
+}
+
+// forgetInclude stops including a relationship once its related route, rather
+// than its relationships one, is disabled
+func (res *Resource) forgetInclude(route string) {
+	storage := make(map[string]store.ToMany, len(res.includeStorage))
+	for name, related := range res.includeStorage {
+		if route != fmt.Sprintf("%s/%s", res.idRoute(), name) {
+			storage[name] = related
+		}
+	}
+	res.includeStorage = storage
+}
+
+// containsString reports whether list holds value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
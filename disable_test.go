@@ -0,0 +1,74 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDisable(t *testing.T) {
+
+	Convey("Disable Tests", t, func() {
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+
+		api := New("")
+		api.Add(resource)
+
+		send := func(method string, url string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest(method, url, nil))
+			return recorder
+		}
+
+		Convey("should answer disabled routes with a 405", func() {
+			So(resource.Disable("DELETE", "/:id"), ShouldBeNil)
+
+			recorder := send("DELETE", "/bars/1")
+			So(recorder.Code, ShouldEqual, http.StatusMethodNotAllowed)
+			So(recorder.Header().Get("Allow"), ShouldEqual, "GET, HEAD, OPTIONS, PATCH")
+
+			recorder = send("OPTIONS", "/bars/1")
+			So(recorder.Code, ShouldEqual, http.StatusNoContent)
+			So(recorder.Header().Get("Allow"), ShouldEqual, "GET, HEAD, OPTIONS, PATCH")
+
+			So(send("GET", "/bars/1").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("should remove disabled routes from the registry", func() {
+			So(resource.Disable("delete", "/bars/:id"), ShouldBeNil)
+			So(resource.Disable("GET", ""), ShouldBeNil)
+
+			So(resource.RouteTree(), ShouldNotContainSubstring, "DELETE - /bars/:id")
+			So(resource.RouteTree(), ShouldNotContainSubstring, "GET - /bars\n")
+			So(resource.RouteList(), ShouldNotContain, "DELETE - /bars/:id")
+			So(resource.RouteList(), ShouldNotContain, "LIST - /bars")
+			So(resource.storage.delete, ShouldBeNil)
+
+			So(send("GET", "/bars").Header().Get("Allow"), ShouldEqual, "OPTIONS, POST")
+		})
+
+		Convey("should carry over to clones", func() {
+			So(resource.Disable("DELETE", "/:id"), ShouldBeNil)
+			So(resource.Clone().RouteTree(), ShouldNotContainSubstring, "DELETE - /bars/:id")
+		})
+
+		Convey("should fail on unknown routes", func() {
+			So(resource.Disable("PUT", "/:id"), ShouldNotBeNil)
+			So(resource.Disable("DELETE", "/:id/unknown"), ShouldNotBeNil)
+			So(resource.Disable("HEAD", "/:id"), ShouldNotBeNil)
+		})
+
+		Convey("should panic once serving requests", func() {
+			send("GET", "/bars/1")
+			So(func() { resource.Disable("DELETE", "/:id") }, ShouldPanic)
+		})
+
+		Convey("should panic once frozen", func() {
+			api.Freeze()
+			So(func() { resource.Disable("DELETE", "/:id") }, ShouldPanic)
+		})
+	})
+}
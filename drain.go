@@ -0,0 +1,103 @@
+package jshapi
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+const (
+	// drainRetryAfter is the Retry-After delay of the requests turned away while
+	// draining, time for load balancers to route them elsewhere
+	drainRetryAfter = 5 * time.Second
+	// drainPollInterval is the interval at which Shutdown checks for requests in
+	// flight
+	drainPollInterval = 10 * time.Millisecond
+)
+
+/*
+Shutdown drains the API before the server stops, such as on SIGTERM:
+
+	go func() {
+		<-sigterm
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		api.Shutdown(ctx)
+		server.Shutdown(ctx)
+	}()
+
+From the first call, new requests are answered with a 503, a Retry-After header,
+and `Connection: close`, the health endpoint failing readiness along with them,
+see HealthEndpoint. Event streams are ended with a final `shutdown` event, and
+long polls are answered with a 304, see LongPoll. Shutdown then waits for the
+requests served by resource routes and atomic operations to complete, returning
+nil once they have or the error of ctx if it ends first.
+*/
+func (a *API) Shutdown(ctx context.Context) error {
+	setFlag(&a.draining, true)
+	a.drainOnce.Do(func() { close(a.drained) })
+
+	for _, resource := range a.Resources {
+		if resource.stream != nil {
+			resource.stream.shutdown()
+		}
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for a.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// Draining reports whether the API is shutting down, see Shutdown
+func (a *API) Draining() bool {
+	return atomic.LoadInt32(&a.draining) == 1
+}
+
+/*
+HealthEndpoint registers a `GET /(prefix/)<route>` endpoint for readiness probes,
+answering a meta-only document with `{"status": "ok"}`, or a 503 as soon as the
+API is draining, see Shutdown:
+
+	api.HealthEndpoint("healthz")
+*/
+func (a *API) HealthEndpoint(route string) {
+	a.MetaEndpoint(route, func(ctx context.Context, r *http.Request) (map[string]interface{}, jsh.ErrorType) {
+		if a.Draining() {
+			return nil, drainingError()
+		}
+
+		return map[string]interface{}{"status": "ok"}, nil
+	})
+}
+
+// drainingError is the error of the requests turned away while draining
+func drainingError() *jsh.Error {
+	return &jsh.Error{
+		Title:  "Service Unavailable",
+		Detail: "The API is shutting down, please retry",
+		Status: http.StatusServiceUnavailable,
+	}
+}
+
+// sendDraining turns a request away while draining, closing its connection
+func sendDraining(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(drainRetryAfter.Seconds()))))
+
+	SendHandler(ctx, w, r, drainingError())
+}
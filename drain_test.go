@@ -0,0 +1,92 @@
+package jshapi
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestShutdown(t *testing.T) {
+
+	Convey("Shutdown Tests", t, func() {
+
+		release := make(chan struct{})
+		resource := NewResource("jobs")
+		resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			if id == "slow" {
+				<-release
+			}
+			return jsh.NewObject(id, "jobs", map[string]string{"state": "done"})
+		})
+		resource.EventStream(EventStreamOptions{})
+
+		api := New("")
+		api.HealthEndpoint("healthz")
+		api.Add(resource)
+
+		send := func(url string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+			return recorder
+		}
+
+		Convey("should turn new requests away", func() {
+			So(send("/healthz").Code, ShouldEqual, http.StatusOK)
+			So(send("/jobs/1").Code, ShouldEqual, http.StatusOK)
+
+			So(api.Shutdown(context.Background()), ShouldBeNil)
+			So(api.Draining(), ShouldBeTrue)
+
+			recorder := send("/jobs/1")
+			So(recorder.Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(recorder.Header().Get("Connection"), ShouldEqual, "close")
+			So(recorder.Header().Get("Retry-After"), ShouldEqual, "5")
+
+			So(send("/healthz").Code, ShouldEqual, http.StatusServiceUnavailable)
+		})
+
+		Convey("should wait for requests in flight", func() {
+			done := make(chan int)
+			go func() { done <- send("/jobs/slow").Code }()
+			for api.inFlight.Load() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			So(api.Shutdown(ctx), ShouldEqual, context.DeadlineExceeded)
+
+			close(release)
+			So(<-done, ShouldEqual, http.StatusOK)
+			So(api.Shutdown(context.Background()), ShouldBeNil)
+		})
+
+		Convey("should end event streams with a final event", func() {
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + "/jobs/events")
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+
+			for api.inFlight.Load() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			So(api.Shutdown(context.Background()), ShouldBeNil)
+
+			lines := []string{}
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+			So(lines, ShouldResemble, []string{"event: shutdown", "data: {}", ""})
+		})
+	})
+}
@@ -0,0 +1,91 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// DryRunHeader asks for a write to be checked without being persisted, see
+// AllowDryRun
+const DryRunHeader = "X-Dry-Run"
+
+/*
+AllowDryRun enables dry runs of `POST /resource` and `PATCH /resource/:id`
+requests carrying a `X-Dry-Run: true` header. They are parsed, authorized and
+validated as usual, but storage isn't called: the would-be object is sent with a
+200 instead, flagged in the document meta:
+
+	{"data": {"type": "users", "attributes": {...}}, "meta": {"dry-run": true}}
+
+CRUD storage implementing store.DryRunner is called in place of Save and Update,
+to run its own checks, such as uniqueness constraints, and return the object as
+it would be stored. No audit record or resource event is emitted for dry runs.
+Other write routes, atomic operations included, reject dry runs with a 400 rather
+than persisting them.
+*/
+func (a *API) AllowDryRun(enabled bool) {
+	a.checkRegistration("dry runs")
+
+	a.dryRun = enabled
+}
+
+// dryRun reports whether a request asks for a dry run allowed by the API
+func (res *Resource) dryRun(r *http.Request) bool {
+	return res.api != nil && res.api.dryRunRequested(r)
+}
+
+// dryRunRequested reports whether a request asks for a dry run allowed by the API
+func (a *API) dryRunRequested(r *http.Request) bool {
+	if !a.dryRun {
+		return false
+	}
+
+	return strings.EqualFold(strings.TrimSpace(r.Header.Get(DryRunHeader)), "true")
+}
+
+// dryRunUnsupported is sent to dry runs of write routes that would persist them
+func dryRunUnsupported(method string) *jsh.Error {
+	return &jsh.Error{
+		Title:  "Bad Request",
+		Detail: fmt.Sprintf("Dry runs are not supported by this %s route", method),
+		Status: http.StatusBadRequest,
+	}
+}
+
+// sendDryRun checks a parsed object with the dry runner of the storage, if any,
+// and sends the object that would have been written
+func (res *Resource) sendDryRun(ctx context.Context, w http.ResponseWriter, r *http.Request, op Operation, parsed *jsh.Object) {
+	object := parsed
+
+	if res.dryRunner != nil {
+		call, run := "dry_run_save", res.dryRunner.DryRunSave
+		if op == OpUpdate {
+			call, run = "dry_run_update", res.dryRunner.DryRunUpdate
+		}
+
+		storageCtx, finish := startStorage(ctx, r, call)
+		checked, err := run(storageCtx, parsed)
+		err = finish(err)
+		if clientGone(ctx) {
+			return
+		}
+		if HasError(err) {
+			res.send(ctx, w, r, err)
+			return
+		}
+		if checked != nil {
+			object = checked
+		}
+	}
+
+	doc := jsh.Build(object)
+	doc.Status = http.StatusOK
+	doc.Meta = map[string]interface{}{"dry-run": true}
+	addUnknownAttributes(ctx, doc)
+	res.send(ctx, w, r, doc)
+}
@@ -0,0 +1,161 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// dryRunStorage counts writes and dry runs, rejecting the "taken" name
+type dryRunStorage struct {
+	MockStorage
+	written int
+	checked int
+}
+
+func (s *dryRunStorage) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	s.written++
+	object.ID = "1"
+	return object, nil
+}
+
+func (s *dryRunStorage) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	s.written++
+	return object, nil
+}
+
+func (s *dryRunStorage) DryRunSave(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	s.checked++
+	if strings.Contains(string(object.Attributes), "taken") {
+		return nil, &jsh.Error{Title: "Conflict", Detail: "Name is taken", Status: http.StatusConflict}
+	}
+	return object, nil
+}
+
+func (s *dryRunStorage) DryRunUpdate(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	s.checked++
+	return object, nil
+}
+
+func TestDryRun(t *testing.T) {
+
+	Convey("Dry Run Tests", t, func() {
+
+		storage := &dryRunStorage{}
+		resource := NewCRUDResource("bars", storage)
+		resource.AddValidator(func(ctx context.Context, object *jsh.Object) jsh.ErrorType {
+			if strings.Contains(string(object.Attributes), "invalid") {
+				return jsh.InputError("Invalid name", "name")
+			}
+			return nil
+		})
+		resource.ImportEach(storage.Save)
+
+		events := make(chan ResourceEvent, 10)
+		audits := make(chan AuditEvent, 10)
+
+		api := New("")
+		api.AllowDryRun(true)
+		api.Events(ChannelSink(events))
+		api.SetAuditor(func(ctx context.Context, event AuditEvent) {
+			audits <- event
+		})
+		api.Add(resource)
+		api.AtomicOperations("operations")
+
+		serve := func(method string, url string, body string, dryRun bool) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			if dryRun {
+				request.Header.Set(DryRunHeader, "true")
+			}
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should send the would-be object of creations without saving it", func() {
+			recorder := serve("POST", "/bars", `{"data": {"type": "bars", "attributes": {"name": "new"}}}`, true)
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"dry-run": true`)
+			So(recorder.Body.String(), ShouldContainSubstring, `"name": "new"`)
+			So(recorder.Header().Get("Location"), ShouldBeEmpty)
+			So(storage.written, ShouldEqual, 0)
+			So(storage.checked, ShouldEqual, 1)
+		})
+
+		Convey("should check updates without saving them", func() {
+			recorder := serve("PATCH", "/bars/1", `{"data": {"type": "bars", "id": "1", "attributes": {"name": "new"}}}`, true)
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"dry-run": true`)
+			So(storage.written, ShouldEqual, 0)
+			So(storage.checked, ShouldEqual, 1)
+		})
+
+		Convey("should send the errors of the dry runner", func() {
+			recorder := serve("POST", "/bars", `{"data": {"type": "bars", "attributes": {"name": "taken"}}}`, true)
+
+			So(recorder.Code, ShouldEqual, http.StatusConflict)
+			So(storage.written, ShouldEqual, 0)
+		})
+
+		Convey("should run validators", func() {
+			recorder := serve("POST", "/bars", `{"data": {"type": "bars", "attributes": {"name": "invalid"}}}`, true)
+
+			So(recorder.Code, ShouldEqual, http.StatusUnprocessableEntity)
+			So(storage.checked, ShouldEqual, 0)
+		})
+
+		Convey("should not emit audit records or events", func() {
+			serve("POST", "/bars", `{"data": {"type": "bars", "attributes": {"name": "new"}}}`, true)
+			serve("POST", "/bars", `{"data": {"type": "bars", "attributes": {"name": "new"}}}`, false)
+
+			<-events
+			<-audits
+			So(events, ShouldBeEmpty)
+			So(audits, ShouldBeEmpty)
+			So(storage.written, ShouldEqual, 1)
+		})
+
+		Convey("should reject dry runs of routes that would persist them", func() {
+			recorder := serve("POST", "/bars/import", `{"data": [{"type": "bars", "attributes": {"name": "new"}}]}`, true)
+
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(storage.written, ShouldEqual, 0)
+
+			recorder = httptest.NewRecorder()
+			request := httptest.NewRequest("POST", "/operations", strings.NewReader(`{"atomic:operations": [
+				{"op": "add", "data": {"type": "bars", "attributes": {"name": "new"}}}
+			]}`))
+			request.Header.Set("Content-Type", AtomicContentType)
+			request.Header.Set(DryRunHeader, "true")
+			api.ServeHTTP(recorder, request)
+
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, "Dry runs are not supported by this POST route")
+			So(storage.written, ShouldEqual, 0)
+		})
+
+		Convey("should ignore the header unless allowed", func() {
+			api := New("")
+			api.Add(NewCRUDResource("bars", storage))
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest("POST", "/bars", strings.NewReader(`{"data": {"type": "bars", "attributes": {"name": "new"}}}`))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			request.Header.Set(DryRunHeader, "true")
+			api.ServeHTTP(recorder, request)
+
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+			So(storage.written, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,46 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/internal/nilness"
+)
+
+/*
+HasError reports whether err holds an error. Storage commonly returns a nil
+*jsh.Error, which makes for a non nil jsh.ErrorType, so checking err != nil is not
+enough. Any nil value of a pointer, map, slice, func, chan or unsafe pointer type
+counts as no error, while values of other kinds, such as structs, always hold one.
+It never panics, and can be shared by middleware and storage wrappers handling
+jsh errors:
+
+	object, err := storage(ctx, id)
+	if jshapi.HasError(err) {
+		jshapi.SendHandler(ctx, w, r, err)
+		return
+	}
+*/
+func HasError(err jsh.ErrorType) bool {
+	return nilness.HasError(err)
+}
+
+// missingObjectError is the default error of a missing object, see missingObject
+func (res *Resource) missingObjectError(relationship string, id string) *jsh.Error {
+	if relationship == "" {
+		return jsh.NotFound(res.Type, id)
+	}
+
+	return &jsh.Error{
+		Title:  "Not Found",
+		Detail: fmt.Sprintf("No '%s' is related to the resource of type '%s' with ID: %s", relationship, res.Type, id),
+		Status: http.StatusNotFound,
+	}
+}
+
+// unsavedObject is sent when a store.Save or store.Update returns neither an object
+// nor an error, these must return the object they stored
+func (res *Resource) unsavedObject(call string) *jsh.Error {
+	return jsh.ISE(fmt.Sprintf("Storage '%s' of resource type '%s' returned neither an object nor an error", call, res.Type))
+}
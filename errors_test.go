@@ -0,0 +1,128 @@
+package jshapi
+
+import (
+	"net/http"
+	"testing"
+	"unsafe"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/internal/nilness"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// wrappedError is a non-pointer error type wrapping a possibly nil error
+type wrappedError struct {
+	err *jsh.Error
+}
+
+func (e wrappedError) Error() string {
+	return "wrapped"
+}
+
+func (e wrappedError) Validate(r *http.Request, response bool) *jsh.Error {
+	return nil
+}
+
+func (e wrappedError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// mapError is a map error type, which may be nil
+type mapError map[string]string
+
+func (e mapError) Error() string {
+	return "map"
+}
+
+func (e mapError) Validate(r *http.Request, response bool) *jsh.Error {
+	return nil
+}
+
+func (e mapError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+func TestHasError(t *testing.T) {
+
+	Convey("HasError Tests", t, func() {
+
+		Convey("should ignore nil and typed nil errors", func() {
+			var nilError *jsh.Error
+			var nilList jsh.ErrorList
+			var nilMap mapError
+			var nilWrapped *wrappedError
+
+			So(HasError(nil), ShouldBeFalse)
+			So(HasError(nilError), ShouldBeFalse)
+			So(HasError(nilList), ShouldBeFalse)
+			So(HasError(nilMap), ShouldBeFalse)
+			So(HasError(nilWrapped), ShouldBeFalse)
+		})
+
+		Convey("should report errors", func() {
+			So(HasError(jsh.ISE("failure")), ShouldBeTrue)
+			So(HasError(jsh.ErrorList{jsh.ISE("failure")}), ShouldBeTrue)
+			So(HasError(mapError{}), ShouldBeTrue)
+			So(HasError(&wrappedError{}), ShouldBeTrue)
+		})
+
+		Convey("should report non pointer errors wrapping a nil error without panicking", func() {
+			So(HasError(wrappedError{}), ShouldBeTrue)
+		})
+	})
+}
+
+func TestIsNil(t *testing.T) {
+
+	Convey("isNil Tests", t, func() {
+
+		value := 1
+
+		tests := []struct {
+			kind  string
+			value interface{}
+			isNil bool
+		}{
+			{"nil", nil, true},
+			{"bool", false, false},
+			{"int", 0, false},
+			{"int8", int8(0), false},
+			{"int16", int16(0), false},
+			{"int32", int32(0), false},
+			{"int64", int64(0), false},
+			{"uint", uint(0), false},
+			{"uint8", uint8(0), false},
+			{"uint16", uint16(0), false},
+			{"uint32", uint32(0), false},
+			{"uint64", uint64(0), false},
+			{"uintptr", uintptr(0), false},
+			{"float32", float32(0), false},
+			{"float64", float64(0), false},
+			{"complex64", complex64(0), false},
+			{"complex128", complex128(0), false},
+			{"array", [1]int{}, false},
+			{"string", "", false},
+			{"struct", struct{}{}, false},
+			{"nil chan", (chan int)(nil), true},
+			{"chan", make(chan int), false},
+			{"nil func", (func())(nil), true},
+			{"func", func() {}, false},
+			{"nil map", (map[string]int)(nil), true},
+			{"map", map[string]int{}, false},
+			{"nil ptr", (*int)(nil), true},
+			{"ptr", &value, false},
+			{"nil slice", ([]int)(nil), true},
+			{"slice", []int{}, false},
+			{"nil unsafe pointer", unsafe.Pointer(nil), true},
+			{"unsafe pointer", unsafe.Pointer(&value), false},
+			{"nil pointer to interface", (*error)(nil), true},
+		}
+
+		for _, test := range tests {
+			Convey("should handle a "+test.kind, func() {
+				So(func() { nilness.IsNil(test.value) }, ShouldNotPanic)
+				So(nilness.IsNil(test.value), ShouldEqual, test.isNil)
+			})
+		}
+	})
+}
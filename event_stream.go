@@ -0,0 +1,341 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+const (
+	// DefaultHeartbeat is the interval between the heartbeats of event streams
+	// when their options leave it unset
+	DefaultHeartbeat = 15 * time.Second
+	// DefaultReplaySize is the number of events event streams keep for clients
+	// resuming with Last-Event-ID when their options leave it unset
+	DefaultReplaySize = 100
+)
+
+// streamClientBuffer is the number of events a client of an event stream may lag
+// behind before being disconnected
+const streamClientBuffer = 64
+
+// EventStreamOptions configures the event stream of a resource, see EventStream
+type EventStreamOptions struct {
+	// Heartbeat is the interval between the comments keeping idle connections
+	// open, defaults to DefaultHeartbeat
+	Heartbeat time.Duration
+	// ReplaySize is the number of past events kept for clients resuming with
+	// Last-Event-ID, defaults to DefaultReplaySize
+	ReplaySize int
+}
+
+/*
+EventStream registers a `GET /resource/events` route streaming the changes made to
+the objects of the resource through this API instance as Server-Sent Events, from
+the ResourceEvents of successful mutations:
+
+	id: 42
+	event: update
+	data: {"data": {"type": "posts", "id": "1", "attributes": {...}}}
+
+Each event carries the JSON API document of the changed object as the resource
+sends it to the client, its key casing, codecs, computed and sealed attributes
+applied and its fields redacted by the field policy, or its identifier only for
+deletes. Objects the object filter does not permit the client to see are not
+sent. Clients reconnecting with a Last-Event-ID header are first sent the events
+they missed, as long as they are still among the last opts.ReplaySize. Clients
+may restrict the events they receive with the `filter[id]` and
+`filter[operation]` query parameters, taking comma separated values. In tenanted
+APIs, clients are only sent the events of the tenant their request resolved to,
+replayed ones included. Idle connections are kept open by a comment every
+opts.Heartbeat, and clients too slow to keep up are disconnected, free to
+resume. API.Shutdown ends every stream with a `shutdown` event.
+*/
+func (res *Resource) EventStream(opts EventStreamOptions) {
+	defer res.record(func(clone *Resource) { clone.EventStream(opts) })()
+
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = DefaultHeartbeat
+	}
+	if opts.ReplaySize <= 0 {
+		opts.ReplaySize = DefaultReplaySize
+	}
+
+	matcher := "/events"
+	res.handleRoute(
+		pat.Get(matcher),
+		OpList,
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.eventStreamHandler(ctx, w, r, opts)
+		},
+	)
+	res.addRoute(get, matcher)
+
+	res.stream = &eventStream{
+		replaySize: opts.ReplaySize,
+		clients:    map[chan streamEvent]string{},
+	}
+}
+
+// streamEvent is an event of an event stream
+type streamEvent struct {
+	id        uint64
+	operation Operation
+	objectID  string
+	tenant    string
+	// object is the object returned by storage, nil for deletes
+	object *jsh.Object
+}
+
+// eventStream dispatches the events of a resource to the clients of its stream
+type eventStream struct {
+	mu         sync.Mutex
+	lastID     uint64
+	replay     []streamEvent
+	replaySize int
+	// clients are the channels of the clients, by tenant
+	clients map[chan streamEvent]string
+	// closed is set once the stream is shut down, see API.Shutdown
+	closed bool
+}
+
+// publish numbers an event, keeps it for replay, and sends it to the clients of
+// its tenant, disconnecting those that lag behind. The object returned by storage
+// is encoded separately for each client.
+func (stream *eventStream) publish(event ResourceEvent, returned *jsh.Object) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	stream.lastID++
+	sent := streamEvent{
+		id:        stream.lastID,
+		operation: event.Operation,
+		objectID:  event.ID,
+		tenant:    event.Tenant,
+		object:    returned,
+	}
+
+	stream.replay = append(stream.replay, sent)
+	if len(stream.replay) > stream.replaySize {
+		stream.replay = stream.replay[len(stream.replay)-stream.replaySize:]
+	}
+
+	for client, tenant := range stream.clients {
+		if tenant != sent.tenant {
+			continue
+		}
+
+		select {
+		case client <- sent:
+		default:
+			stream.drop(client)
+		}
+	}
+}
+
+// subscribe adds a client of tenant to the stream, returning the events of the
+// tenant it missed since lastID
+func (stream *eventStream) subscribe(tenant string, lastID uint64, resume bool) (chan streamEvent, []streamEvent) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	missed := []streamEvent{}
+	if resume {
+		for _, event := range stream.replay {
+			if event.id > lastID && event.tenant == tenant {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	client := make(chan streamEvent, streamClientBuffer)
+	if stream.closed {
+		close(client)
+		return client, missed
+	}
+	stream.clients[client] = tenant
+
+	return client, missed
+}
+
+// unsubscribe removes a client from the stream
+func (stream *eventStream) unsubscribe(client chan streamEvent) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	_, subscribed := stream.clients[client]
+	if subscribed {
+		stream.drop(client)
+	}
+}
+
+// shutdown disconnects every client, which are sent a final shutdown event
+func (stream *eventStream) shutdown() {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	stream.closed = true
+	for client := range stream.clients {
+		close(client)
+	}
+	stream.clients = map[chan streamEvent]string{}
+}
+
+// isShutdown reports whether the stream was shut down
+func (stream *eventStream) isShutdown() bool {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	return stream.closed
+}
+
+// drop removes a client and closes its channel, the stream lock being held
+func (stream *eventStream) drop(client chan streamEvent) {
+	clients := make(map[chan streamEvent]string, len(stream.clients))
+	for other, tenant := range stream.clients {
+		if other != client {
+			clients[other] = tenant
+		}
+	}
+	stream.clients = clients
+
+	close(client)
+}
+
+/*
+streamDocument builds the JSON API document of a stream event for the client of
+ctx, the object being sent as it would be by the resource. It returns nil when
+the client may not see the object, or it fails to encode.
+*/
+func (res *Resource) streamDocument(ctx context.Context, event streamEvent) []byte {
+	var data interface{} = map[string]string{"type": res.Type, "id": event.objectID}
+	if event.object != nil {
+		if !res.permits(ctx, event.object) {
+			return nil
+		}
+
+		prepared, isObject := res.prepare(ctx, event.object).(*jsh.Object)
+		if !isObject {
+			return nil
+		}
+		data = prepared
+	}
+
+	document, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		return nil
+	}
+
+	return document
+}
+
+// GET /resources/events
+func (res *Resource) eventStreamHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, opts EventStreamOptions) {
+	flusher, canFlush := w.(http.Flusher)
+	if !canFlush {
+		res.send(ctx, w, r, jsh.ISE("Event streams require a response writer that can flush"))
+		return
+	}
+
+	filter, filterErr := streamFilter(r)
+	if filterErr != nil {
+		res.send(ctx, w, r, filterErr)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	lastID, parseErr := strconv.ParseUint(lastEventID, 10, 64)
+	resume := lastEventID != "" && parseErr == nil
+
+	client, missed := res.stream.subscribe(TenantFromContext(ctx), lastID, resume)
+	defer res.stream.unsubscribe(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for _, event := range missed {
+		res.writeStreamEvent(ctx, w, event, filter)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(opts.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case event, open := <-client:
+			if !open {
+				if res.stream.isShutdown() {
+					fmt.Fprint(w, "event: shutdown\ndata: {}\n\n")
+					flusher.Flush()
+				}
+				return
+			}
+			res.writeStreamEvent(ctx, w, event, filter)
+		}
+		flusher.Flush()
+	}
+}
+
+// writeStreamEvent writes an event in the Server-Sent Events format, unless
+// filtered out or not visible to the client of ctx
+func (res *Resource) writeStreamEvent(ctx context.Context, w http.ResponseWriter, event streamEvent, filter map[string]map[string]bool) {
+	if filter["id"] != nil && !filter["id"][event.objectID] {
+		return
+	}
+	if filter["operation"] != nil && !filter["operation"][string(event.operation)] {
+		return
+	}
+
+	document := res.streamDocument(ctx, event)
+	if document == nil {
+		return
+	}
+
+	message := &bytes.Buffer{}
+	fmt.Fprintf(message, "id: %d\nevent: %s\ndata: %s\n\n", event.id, event.operation, document)
+	w.Write(message.Bytes())
+}
+
+// streamFilter reads the filter query parameters of an event stream, by name then
+// accepted value
+func streamFilter(r *http.Request) (map[string]map[string]bool, *jsh.Error) {
+	filter, err := parseFilter(r)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := map[string]map[string]bool{}
+	for name, value := range filter {
+		if name != "id" && name != "operation" {
+			return nil, &jsh.Error{
+				Title:  "Bad Request",
+				Detail: fmt.Sprintf("Event streams can't be filtered by '%s'", name),
+				Status: http.StatusBadRequest,
+			}
+		}
+
+		parsed[name] = map[string]bool{}
+		for _, accepted := range strings.Split(value, ",") {
+			parsed[name][accepted] = true
+		}
+	}
+
+	return parsed, nil
+}
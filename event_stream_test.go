@@ -0,0 +1,228 @@
+package jshapi
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEventStream(t *testing.T) {
+
+	Convey("Event Stream Tests", t, func() {
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+		resource.EventStream(EventStreamOptions{Heartbeat: 20 * time.Millisecond, ReplaySize: 2})
+
+		api := New("")
+		api.Add(resource)
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/" + testResourceType
+
+		patchAt := func(collection string, id string) {
+			resp, _ := negotiationRequest("PATCH", collection+"/"+id, jsh.ContentType, "",
+				`{"data": {"type": "bars", "id": "`+id+`", "attributes": {"foo": "bar"}}}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		}
+		patch := func(id string) {
+			patchAt(url, id)
+		}
+
+		// connectAt opens the stream of a collection and returns its lines, the
+		// stream is closed once the test is done
+		connectAt := func(collection string, query string, header http.Header) (*http.Response, chan string) {
+			request, _ := http.NewRequest("GET", collection+"/events"+query, nil)
+			for name, values := range header {
+				request.Header[name] = values
+			}
+
+			resp, err := http.DefaultClient.Do(request)
+			So(err, ShouldBeNil)
+			Reset(func() { resp.Body.Close() })
+
+			lines := make(chan string, 100)
+			go func() {
+				scanner := bufio.NewScanner(resp.Body)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+				close(lines)
+			}()
+			return resp, lines
+		}
+		connect := func(query string, lastEventID string) (*http.Response, chan string) {
+			header := http.Header{}
+			if lastEventID != "" {
+				header.Set("Last-Event-ID", lastEventID)
+			}
+			return connectAt(url, query, header)
+		}
+
+		// next returns the next event, skipping heartbeats
+		next := func(lines chan string) []string {
+			event := []string{}
+			timeout := time.After(time.Second)
+			for {
+				select {
+				case line := <-lines:
+					if line == "" && len(event) > 0 {
+						return event
+					}
+					if line != "" && !strings.HasPrefix(line, ":") {
+						event = append(event, line)
+					}
+				case <-timeout:
+					return event
+				}
+			}
+		}
+
+		Convey("should stream the changes of objects", func() {
+			resp, lines := connect("", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Content-Type"), ShouldEqual, "text/event-stream")
+
+			patch("1")
+			event := next(lines)
+			So(event, ShouldHaveLength, 3)
+			So(event[0], ShouldEqual, "id: 1")
+			So(event[1], ShouldEqual, "event: update")
+			So(event[2], ShouldStartWith, `data: {"data":{"type":"bars","id":"1"`)
+		})
+
+		Convey("should send heartbeats", func() {
+			_, lines := connect("", "")
+
+			select {
+			case line := <-lines:
+				So(line, ShouldEqual, ": heartbeat")
+			case <-time.After(time.Second):
+				So("missing heartbeat", ShouldBeEmpty)
+			}
+		})
+
+		Convey("should replay missed events from Last-Event-ID", func() {
+			patch("1")
+			patch("2")
+			patch("3")
+
+			_, lines := connect("", "1")
+			So(next(lines)[0], ShouldEqual, "id: 2")
+			So(next(lines)[0], ShouldEqual, "id: 3")
+		})
+
+		Convey("should filter events per connection", func() {
+			_, lines := connect("?filter[id]=2", "")
+
+			patch("1")
+			patch("2")
+			So(next(lines)[0], ShouldEqual, "id: 2")
+		})
+
+		Convey("should only send the events of the tenant of the client", func() {
+			tenanted := NewMockResource(testResourceType, 1, testObjAttrs)
+			tenanted.EventStream(EventStreamOptions{Heartbeat: 20 * time.Millisecond, ReplaySize: 10})
+
+			tenantedAPI := New("api")
+			tenantedAPI.Tenanted("t/:tenant", func(ctx context.Context, r *http.Request) (string, jsh.ErrorType) {
+				return pat.Param(ctx, "tenant"), nil
+			})
+			tenantedAPI.Add(tenanted)
+
+			tenantedServer := httptest.NewServer(tenantedAPI)
+			defer tenantedServer.Close()
+
+			tenantA := tenantedServer.URL + "/t/a/api/" + testResourceType
+			tenantB := tenantedServer.URL + "/t/b/api/" + testResourceType
+
+			patchAt(tenantB, "1")
+			patchAt(tenantA, "1")
+
+			resp, lines := connectAt(tenantA, "", http.Header{"Last-Event-ID": {"0"}})
+			defer resp.Body.Close()
+			So(next(lines)[0], ShouldEqual, "id: 2")
+
+			patchAt(tenantB, "1")
+			patchAt(tenantA, "1")
+			So(next(lines)[0], ShouldEqual, "id: 4")
+		})
+
+		Convey("should send objects as the resource sends them to each client", func() {
+			sending := NewMockResource(testResourceType, 1, testObjAttrs)
+			sending.KeyCasing(CamelCase, SnakeCase)
+			sending.FieldPolicy(func(ctx context.Context, objectType string) []string {
+				if GetRequestID(ctx) == "admin" {
+					return nil
+				}
+				return []string{"fooBar"}
+			})
+			sending.SetObjectFilter(ObjectFilterFunc(func(ctx context.Context, object *jsh.Object) bool {
+				return object.ID != "2" || GetRequestID(ctx) == "admin"
+			}))
+			sending.EventStream(EventStreamOptions{Heartbeat: 20 * time.Millisecond})
+
+			sendingAPI := New("")
+			sendingAPI.UseC(RequestID())
+			sendingAPI.Add(sending)
+
+			sendingServer := httptest.NewServer(sendingAPI)
+			defer sendingServer.Close()
+
+			collection := sendingServer.URL + "/" + testResourceType
+			userResp, userLines := connectAt(collection, "", nil)
+			defer userResp.Body.Close()
+			adminResp, adminLines := connectAt(collection, "", http.Header{RequestIDHeader: {"admin"}})
+			defer adminResp.Body.Close()
+
+			for _, id := range []string{"2", "1"} {
+				resp, _ := negotiationRequest("PATCH", collection+"/"+id, jsh.ContentType, "",
+					`{"data": {"type": "bars", "id": "`+id+`", "attributes": {"fooBar": "baz", "secret": "x"}}}`)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			}
+
+			event := next(userLines)
+			So(event[0], ShouldEqual, "id: 2")
+			So(event[2], ShouldEqual, `data: {"data":{"type":"bars","id":"1","attributes":{"fooBar":"baz"}}}`)
+
+			So(next(adminLines)[2], ShouldContainSubstring, `"id":"2","attributes":{"fooBar":"baz","secret":"x"}`)
+			So(next(adminLines)[2], ShouldContainSubstring, `"id":"1","attributes":{"fooBar":"baz","secret":"x"}`)
+		})
+
+		Convey("should reject unknown filters", func() {
+			resp, _ := connect("?filter[foo]=bar", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("should unsubscribe disconnected clients", func() {
+			resp, lines := connect("", "")
+			<-lines
+			resp.Body.Close()
+
+			deadline := time.Now().Add(time.Second)
+			for time.Now().Before(deadline) {
+				resource.stream.mu.Lock()
+				clients := len(resource.stream.clients)
+				resource.stream.mu.Unlock()
+				if clients == 0 {
+					break
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			resource.stream.mu.Lock()
+			defer resource.stream.mu.Unlock()
+			So(resource.stream.clients, ShouldBeEmpty)
+		})
+	})
+}
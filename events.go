@@ -0,0 +1,179 @@
+package jshapi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// DefaultEventQueueSize is the number of resource events that can be pending
+// before new ones are dropped
+const DefaultEventQueueSize = 1024
+
+// WebhookSignatureHeader holds the HMAC-SHA256 signature of the payloads sent by
+// WebhookSink, as "sha256=<hex digest>"
+const WebhookSignatureHeader = "X-Jshapi-Signature"
+
+/*
+ResourceEvent notifies downstream systems of a successful create, update, or
+delete. It is sent as is, JSON encoded, by WebhookSink.
+*/
+type ResourceEvent struct {
+	Operation    Operation `json:"operation"`
+	ResourceType string    `json:"type"`
+	ID           string    `json:"id"`
+	// Object is the JSON encoded object returned by storage, empty for deletes
+	Object    json.RawMessage `json:"object,omitempty"`
+	RequestID string          `json:"request-id,omitempty"`
+	// Tenant is the tenant of the mutation, empty outside of tenanted APIs
+	Tenant string    `json:"tenant,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// EventSink receives the events of the API, see Events
+type EventSink func(ctx context.Context, event ResourceEvent)
+
+/*
+Events installs the sink receiving a ResourceEvent for every successful mutation,
+once storage has succeeded and a 2XX response is being sent, so that no event is
+emitted for failed storage calls. Like the auditor, the sink runs asynchronously
+from a bounded queue, events being dropped when DefaultEventQueueSize events are
+already pending, see EventsDropped. Delivery is at most once: retries and backoff
+are left to the sink.
+*/
+func (a *API) Events(sink EventSink) {
+	a.checkRegistration("an event sink")
+
+	a.events = newEventQueue(sink, DefaultEventQueueSize)
+}
+
+// EventsDropped returns the number of resource events dropped because the queue
+// was full
+func (a *API) EventsDropped() uint64 {
+	if a.events == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&a.events.dropped)
+}
+
+/*
+WebhookSink returns an EventSink posting each event, JSON encoded, to endpoint,
+giving up after timeout. Payloads are signed with secret, the hex encoded
+HMAC-SHA256 of the body being sent in the WebhookSignatureHeader:
+
+	X-Jshapi-Signature: sha256=<hex digest>
+
+Failed deliveries are not retried.
+*/
+func WebhookSink(endpoint string, secret []byte, timeout time.Duration) EventSink {
+	client := &http.Client{Timeout: timeout}
+
+	return func(ctx context.Context, event ResourceEvent) {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+
+		request, err := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set(WebhookSignatureHeader, "sha256="+signPayload(secret, payload))
+
+		response, err := client.Do(request)
+		if err != nil {
+			return
+		}
+		response.Body.Close()
+	}
+}
+
+// ChannelSink returns an EventSink sending events to channel, blocking the event
+// queue while the channel is full, meant for tests and in-process consumers
+func ChannelSink(channel chan<- ResourceEvent) EventSink {
+	return func(ctx context.Context, event ResourceEvent) {
+		channel <- event
+	}
+}
+
+// signPayload returns the hex encoded HMAC-SHA256 of a webhook payload
+func signPayload(secret []byte, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// eventQueue delivers resource events to the sink from a single goroutine
+type eventQueue struct {
+	events  chan queuedEvent
+	dropped uint64
+}
+
+type queuedEvent struct {
+	ctx   context.Context
+	event ResourceEvent
+}
+
+func newEventQueue(sink EventSink, size int) *eventQueue {
+	queue := &eventQueue{events: make(chan queuedEvent, size)}
+
+	go func() {
+		for queued := range queue.events {
+			sink(queued.ctx, queued.event)
+		}
+	}()
+
+	return queue
+}
+
+// push queues an event without blocking, counting it as dropped if the queue is
+// full
+func (q *eventQueue) push(ctx context.Context, event ResourceEvent) {
+	select {
+	case q.events <- queuedEvent{ctx: ctx, event: event}:
+	default:
+		atomic.AddUint64(&q.dropped, 1)
+	}
+}
+
+// publish queues the ResourceEvent of a successful mutation if an event sink is
+// installed, and sends it to the event stream of the resource if it has one
+func (res *Resource) publish(ctx context.Context, op Operation, id string, returned *jsh.Object) {
+	if res.stream == nil && (res.api == nil || res.api.events == nil) {
+		return
+	}
+
+	event := ResourceEvent{
+		Operation:    op,
+		ResourceType: res.Type,
+		ID:           id,
+		RequestID:    GetRequestID(ctx),
+		Tenant:       TenantFromContext(ctx),
+		Time:         time.Now(),
+	}
+
+	if returned != nil {
+		encoded, err := json.Marshal(returned)
+		if err == nil {
+			event.Object = encoded
+		}
+	}
+
+	if res.stream != nil {
+		res.stream.publish(event, returned)
+	}
+	if res.api != nil && res.api.events != nil {
+		res.api.events.push(ctx, event)
+	}
+}
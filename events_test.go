@@ -0,0 +1,91 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEvents(t *testing.T) {
+
+	Convey("Event Tests", t, func() {
+
+		api := New("")
+		api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+
+		Convey("->ChannelSink()", func() {
+			events := make(chan ResourceEvent, 10)
+			api.Events(ChannelSink(events))
+
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			url := server.URL + "/" + testResourceType
+
+			Convey("should emit events of successful mutations", func() {
+				resp, _ := negotiationRequest("PATCH", url+"/1", jsh.ContentType, "", `{"data": {"type": "bars", "id": "1", "attributes": {"foo": "bar"}}}`)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+				event := <-events
+				So(event.Operation, ShouldEqual, OpUpdate)
+				So(event.ResourceType, ShouldEqual, testResourceType)
+				So(event.ID, ShouldEqual, "1")
+
+				object := jsh.Object{}
+				So(json.Unmarshal(event.Object, &object), ShouldBeNil)
+				So(object.ID, ShouldEqual, "1")
+
+				resp, _ = negotiationRequest("DELETE", url+"/1", "", "", "")
+				So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+
+				event = <-events
+				So(event.Operation, ShouldEqual, OpDelete)
+				So(event.Object, ShouldBeNil)
+			})
+
+			Convey("should not emit events of failed mutations", func() {
+				resp, _ := negotiationRequest("POST", url, jsh.ContentType, "", `{"data": {"type": "foos", "attributes": {"foo": "bar"}}}`)
+				So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+
+				select {
+				case <-events:
+					So("unexpected resource event", ShouldBeEmpty)
+				case <-time.After(20 * time.Millisecond):
+				}
+			})
+		})
+
+		Convey("->WebhookSink()", func() {
+			received := make(chan *http.Request, 1)
+			bodies := make(chan []byte, 1)
+			hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				received <- r
+				bodies <- body
+			}))
+			defer hook.Close()
+
+			api.Events(WebhookSink(hook.URL, []byte("secret"), time.Second))
+
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			resp, _ := negotiationRequest("POST", server.URL+"/"+testResourceType, jsh.ContentType, "", `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+
+			request, body := <-received, <-bodies
+			So(request.Header.Get(WebhookSignatureHeader), ShouldEqual, "sha256="+signPayload([]byte("secret"), body))
+
+			event := ResourceEvent{}
+			So(json.Unmarshal(body, &event), ShouldBeNil)
+			So(event.Operation, ShouldEqual, OpCreate)
+			So(event.ResourceType, ShouldEqual, testResourceType)
+		})
+	})
+}
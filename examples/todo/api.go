@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api"
+	"github.com/derekdowling/jsh-api/store/memstore"
+)
+
+const (
+	// defaultPageSize is used when the client does not request a page size
+	defaultPageSize = 10
+	// maxPageSize caps the page sizes clients can request
+	maxPageSize = 100
+)
+
+// pageKey is the context key of the requested page
+type pageKey struct{}
+
+// page is the page of a collection requested through page[number] and page[size]
+type page struct {
+	number int
+	size   int
+}
+
+// newAPI builds the todo API with a few sample tasks and tags
+func newAPI(logger *log.Logger) *jshapi.API {
+	jshapi.SendHandler = jshapi.DefaultSender(logger)
+
+	api := jshapi.New("")
+	api.UseC(jshapi.RequestID())
+	api.UseC(jshapi.AccessLog(jshapi.StdLogger(logger)))
+	api.UseC(recoverer(logger))
+
+	tags := memstore.New("tags")
+	tasks := memstore.New("tasks")
+	seed(tasks, tags)
+
+	taskResource := jshapi.NewCRUDResource("tasks", &pagedStore{tasks})
+	taskResource.UseC(pagination)
+	taskResource.ToMany("tags", tasks.ToMany("tags", tags))
+	taskResource.Action("complete", complete(tasks))
+
+	api.Add(taskResource)
+	api.Add(jshapi.NewCRUDResource("tags", tags))
+	api.Freeze()
+
+	return api
+}
+
+// seed stores sample tasks and tags
+func seed(tasks *memstore.Store, tags *memstore.Store) {
+	ctx := context.Background()
+
+	for _, name := range []string{"home", "work"} {
+		tags.Save(ctx, object("tags", map[string]interface{}{"name": name}))
+	}
+
+	for _, title := range []string{"buy milk", "write report", "call mom"} {
+		tasks.Save(ctx, object("tasks", map[string]interface{}{"title": title, "done": false}))
+	}
+
+	tasks.Link("1", "tags", "1")
+	tasks.Link("2", "tags", "2")
+}
+
+// object builds a resource object, panicking on invalid attributes
+func object(resourceType string, attributes map[string]interface{}) *jsh.Object {
+	created, err := jsh.NewObject("", resourceType, attributes)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return created
+}
+
+// complete marks a task as done, GET /tasks/:id/complete
+func complete(tasks *memstore.Store) func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	return func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		return tasks.Update(ctx, &jsh.Object{
+			Type:       "tasks",
+			ID:         id,
+			Attributes: json.RawMessage(`{"done": true}`),
+		})
+	}
+}
+
+// pagedStore lists the page of tasks requested through the pagination middleware
+type pagedStore struct {
+	*memstore.Store
+}
+
+// List returns the requested page of tasks
+func (s *pagedStore) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	list, err := s.Store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	requested, found := ctx.Value(pageKey{}).(page)
+	if !found {
+		requested = page{number: 1, size: defaultPageSize}
+	}
+
+	start := (requested.number - 1) * requested.size
+	if start >= len(list) {
+		return jsh.List{}, nil
+	}
+
+	end := start + requested.size
+	if end > len(list) {
+		end = len(list)
+	}
+
+	return list[start:end], nil
+}
+
+// pagination parses the page[number] and page[size] query parameters
+func pagination(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		requested := page{number: 1, size: defaultPageSize}
+
+		query := r.URL.Query()
+		for parameter, target := range map[string]*int{
+			"page[number]": &requested.number,
+			"page[size]":   &requested.size,
+		} {
+			value := query.Get(parameter)
+			if value == "" {
+				continue
+			}
+
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 {
+				jshapi.SendHandler(ctx, w, r, &jsh.Error{
+					Title:  "Invalid Query Parameter",
+					Detail: fmt.Sprintf("%s must be a positive integer", parameter),
+					Status: http.StatusBadRequest,
+				})
+				return
+			}
+			*target = parsed
+		}
+
+		if requested.size > maxPageSize {
+			requested.size = maxPageSize
+		}
+
+		next.ServeHTTPC(context.WithValue(ctx, pageKey{}, requested), w, r)
+	})
+}
+
+// recoverer turns handler panics into JSON API 500 responses
+func recoverer(logger *log.Logger) func(goji.Handler) goji.Handler {
+	return func(next goji.Handler) goji.Handler {
+		return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				recovered := recover()
+				if recovered != nil {
+					logger.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, recovered, debug.Stack())
+					jshapi.SendHandler(ctx, w, r, jsh.ISE(fmt.Sprintf("panic: %v", recovered)))
+				}
+			}()
+
+			next.ServeHTTPC(ctx, w, r)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+/*
+Todo is a runnable demo of a jshapi service, backed by in-memory storage:
+
+	go run ./examples/todo -port 8000
+
+	curl localhost:8000/tasks?page[size]=2
+	curl localhost:8000/tasks/1/relationships/tags
+	curl localhost:8000/tasks/1/complete
+
+It serves:
+
+	GET    /tasks                           paginated with page[number] and page[size]
+	POST   /tasks
+	GET    /tasks/:id
+	PATCH  /tasks/:id
+	DELETE /tasks/:id
+	GET    /tasks/:id/complete              marks the task as done
+	GET    /tasks/:id/(relationships/)tags
+	GET    /tags
+	POST   /tags
+	GET    /tags/:id
+	PATCH  /tags/:id
+	DELETE /tags/:id
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+)
+
+func main() {
+	port := flag.Int("port", 8000, "port to listen on")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "todo: ", log.LstdFlags)
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	logger.Printf("listening on %s", listener.Addr())
+	logger.Fatal(http.Serve(listener, newAPI(logger)))
+}
@@ -0,0 +1,242 @@
+//go:build integration
+// +build integration
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// todoCase is a request to the example server along with its expected outcome
+type todoCase struct {
+	method string
+	path   string
+	// pattern is the route exercised by the request
+	pattern string
+	body    string
+	status  int
+	check   func(document map[string]interface{}, header http.Header)
+}
+
+// send issues a request to the server and decodes the response document
+func send(baseURL string, method string, path string, body string) (*http.Response, map[string]interface{}) {
+	request, err := http.NewRequest(method, baseURL+path, bytes.NewBufferString(body))
+	So(err, ShouldBeNil)
+
+	request.Header.Set("Accept", jsh.ContentType)
+	if body != "" {
+		request.Header.Set("Content-Type", jsh.ContentType)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	So(err, ShouldBeNil)
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	So(err, ShouldBeNil)
+
+	var document map[string]interface{}
+	if len(content) > 0 {
+		So(json.Unmarshal(content, &document), ShouldBeNil)
+	}
+
+	return response, document
+}
+
+// data returns the primary data of a document as a list
+func data(document map[string]interface{}) []interface{} {
+	switch typed := document["data"].(type) {
+	case []interface{}:
+		return typed
+	case nil:
+		return nil
+	default:
+		return []interface{}{typed}
+	}
+}
+
+// attribute reads an attribute of the single object of a document
+func attribute(document map[string]interface{}, name string) interface{} {
+	object := data(document)[0].(map[string]interface{})
+	return object["attributes"].(map[string]interface{})[name]
+}
+
+func TestTodo(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	logger := log.New(ioutil.Discard, "", 0)
+	api := newAPI(logger)
+	go http.Serve(listener, api)
+
+	baseURL := "http://" + listener.Addr().String()
+
+	cases := []todoCase{
+		{
+			method: "GET", path: "/tasks?page[size]=2", pattern: "/tasks", status: http.StatusOK,
+			check: func(document map[string]interface{}, header http.Header) {
+				So(data(document), ShouldHaveLength, 2)
+			},
+		},
+		{
+			method: "GET", path: "/tasks?page[number]=2&page[size]=2", pattern: "/tasks", status: http.StatusOK,
+			check: func(document map[string]interface{}, header http.Header) {
+				So(data(document), ShouldHaveLength, 1)
+				So(data(document)[0].(map[string]interface{})["id"], ShouldEqual, "3")
+			},
+		},
+		{
+			method: "GET", path: "/tasks?page[size]=0", pattern: "/tasks", status: http.StatusBadRequest,
+		},
+		{
+			method: "POST", path: "/tasks", pattern: "/tasks", status: http.StatusCreated,
+			body: `{"data": {"type": "tasks", "attributes": {"title": "water plants", "done": false}}}`,
+			check: func(document map[string]interface{}, header http.Header) {
+				So(header.Get("Location"), ShouldEqual, "/tasks/4")
+				So(header.Get("X-Request-ID"), ShouldNotBeEmpty)
+			},
+		},
+		{
+			method: "GET", path: "/tasks/4", pattern: "/tasks/:id", status: http.StatusOK,
+			check: func(document map[string]interface{}, header http.Header) {
+				So(attribute(document, "title"), ShouldEqual, "water plants")
+			},
+		},
+		{
+			method: "PATCH", path: "/tasks/4", pattern: "/tasks/:id", status: http.StatusOK,
+			body: `{"data": {"type": "tasks", "id": "4", "attributes": {"title": "water all plants"}}}`,
+			check: func(document map[string]interface{}, header http.Header) {
+				So(attribute(document, "title"), ShouldEqual, "water all plants")
+				So(attribute(document, "done"), ShouldEqual, false)
+			},
+		},
+		{
+			method: "GET", path: "/tasks/1/complete", pattern: "/tasks/:id/complete", status: http.StatusOK,
+			check: func(document map[string]interface{}, header http.Header) {
+				So(attribute(document, "done"), ShouldEqual, true)
+			},
+		},
+		{
+			method: "GET", path: "/tasks/1/tags", pattern: "/tasks/:id/tags", status: http.StatusOK,
+			check: func(document map[string]interface{}, header http.Header) {
+				So(data(document), ShouldHaveLength, 1)
+			},
+		},
+		{
+			method: "GET", path: "/tasks/3/relationships/tags", pattern: "/tasks/:id/relationships/tags", status: http.StatusOK,
+			check: func(document map[string]interface{}, header http.Header) {
+				So(data(document), ShouldBeEmpty)
+			},
+		},
+		{
+			method: "DELETE", path: "/tasks/4", pattern: "/tasks/:id", status: http.StatusNoContent,
+		},
+		{
+			method: "GET", path: "/tasks/4", pattern: "/tasks/:id", status: http.StatusNotFound,
+		},
+		{
+			method: "GET", path: "/tags", pattern: "/tags", status: http.StatusOK,
+			check: func(document map[string]interface{}, header http.Header) {
+				So(data(document), ShouldHaveLength, 2)
+			},
+		},
+		{
+			method: "POST", path: "/tags", pattern: "/tags", status: http.StatusCreated,
+			body: `{"data": {"type": "tags", "attributes": {"name": "garden"}}}`,
+		},
+		{
+			method: "GET", path: "/tags/3", pattern: "/tags/:id", status: http.StatusOK,
+		},
+		{
+			method: "PATCH", path: "/tags/3", pattern: "/tags/:id", status: http.StatusOK,
+			body: `{"data": {"type": "tags", "id": "3", "attributes": {"name": "yard"}}}`,
+		},
+		{
+			method: "DELETE", path: "/tags/3", pattern: "/tags/:id", status: http.StatusNoContent,
+		},
+	}
+
+	// serveCases issues every case to the server at baseURL
+	serveCases := func(baseURL string) {
+		for _, c := range cases {
+			response, document := send(baseURL, c.method, c.path, c.body)
+			// the request is part of the compared values to identify failing cases
+			So(fmt.Sprintf("%s %s: %d", c.method, c.path, response.StatusCode), ShouldEqual, fmt.Sprintf("%s %s: %d", c.method, c.path, c.status))
+
+			if response.StatusCode != http.StatusNoContent {
+				So(response.Header.Get("Content-Type"), ShouldEqual, jsh.ContentType)
+			}
+
+			if c.check != nil {
+				c.check(document, response.Header)
+			}
+		}
+	}
+
+	Convey("Todo Example Tests", t, func() {
+
+		Convey("should serve every route", func() {
+			serveCases(baseURL)
+		})
+
+		Convey("should pass its own spec", func() {
+			specAPI := newAPI(logger)
+			spec, err := specAPI.Schema()
+			So(err, ShouldBeNil)
+
+			mismatches := &bytes.Buffer{}
+			validated := jshapi.ValidateAgainstSpec(spec, jshapi.SpecOptions{Fail: true, Logger: log.New(mismatches, "", 0)})(specAPI)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				validated.ServeHTTPC(context.Background(), w, r)
+			}))
+			defer server.Close()
+
+			serveCases(server.URL)
+			So(mismatches.String(), ShouldEqual, "")
+		})
+
+		Convey("should exercise every registered route", func() {
+			covered := map[string]bool{}
+			for _, c := range cases {
+				covered[c.method+" "+c.pattern] = true
+			}
+
+			for _, route := range api.Routes() {
+				So(covered, ShouldContainKey, route.Method+" "+route.Pattern)
+			}
+		})
+
+		Convey("should recover from panics", func() {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest("GET", "/tasks", nil)
+			recoverer(logger)(panicking{}).ServeHTTPC(context.Background(), recorder, request)
+
+			So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, jsh.ContentType)
+		})
+	})
+}
+
+// panicking is a handler that always panics
+type panicking struct{}
+
+func (panicking) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	panic("boom")
+}
@@ -0,0 +1,368 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+// exportFlushRows is the number of objects written between two flushes of an
+// export
+const exportFlushRows = 100
+
+// ExportFormat is a file format a collection can be exported to, see Export
+type ExportFormat struct {
+	name        string
+	contentType string
+	newEncoder  func(w io.Writer) exportEncoder
+}
+
+// exportEncoder writes the objects of an export in a file format
+type exportEncoder interface {
+	encode(object *jsh.Object) error
+	// flush writes out buffered objects, it is called once more after the last
+	// object
+	flush() error
+}
+
+/*
+CSV exports collections as CSV, one row per object, with an attribute in each of
+columns, in order, "id" standing for the id of the object. Without columns, the id
+is followed by the attributes of the first object, sorted by name. Attributes that
+aren't strings, such as numbers or nested objects, are JSON encoded into their
+cell, and missing or null ones are left empty.
+*/
+func CSV(columns ...string) ExportFormat {
+	return ExportFormat{
+		name:        "csv",
+		contentType: "text/csv",
+		newEncoder: func(w io.Writer) exportEncoder {
+			return &csvEncoder{writer: csv.NewWriter(w), columns: columns}
+		},
+	}
+}
+
+// NDJSON exports collections as newline delimited JSON, one resource object per
+// line
+func NDJSON() ExportFormat {
+	return ExportFormat{
+		name:        "ndjson",
+		contentType: "application/x-ndjson",
+		newEncoder: func(w io.Writer) exportEncoder {
+			return &ndjsonEncoder{writer: w}
+		},
+	}
+}
+
+/*
+Export registers a `GET /resource/export` route downloading the whole collection
+as a file in one of formats, picked by the "format" query parameter, such as
+`?format=csv`, or else by the Accept header, defaulting to the first one:
+
+	orders.Export(jshapi.CSV("id", "total", "status"), jshapi.NDJSON())
+
+Objects are streamed from the ListStream storage of the resource when registered,
+or else loaded from its List storage. The route runs the middleware of the
+resource like its list route does, so that filtering or sorting applied there
+also apply to exports. Objects are exported with their attributes as the resource
+sends them, redacted by its field policy. The output is flushed every 100
+objects, and cut short if the storage fails once it has started.
+*/
+func (res *Resource) Export(formats ...ExportFormat) {
+	defer res.record(func(clone *Resource) { clone.Export(formats...) })()
+
+	if len(formats) == 0 {
+		panic(fmt.Sprintf("jshapi: export of resource '%s' requires a format", res.Type))
+	}
+
+	matcher := "/export"
+	res.handleRoute(
+		pat.Get(matcher),
+		OpList,
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.exportHandler(ctx, w, r, formats)
+		},
+	)
+
+	res.addRoute(get, matcher)
+}
+
+// ListStream registers the storage streaming the objects of the resource, used by
+// Export
+func (res *Resource) ListStream(storage store.ListStream) {
+	defer res.record(func(clone *Resource) { clone.ListStream(storage) })()
+
+	res.checkRegistration("a stream loader")
+
+	res.storage.stream = storage
+}
+
+// GET /resources/export
+func (res *Resource) exportHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, formats []ExportFormat) {
+	format, negotiationErr := negotiateExport(r, formats)
+	if negotiationErr != nil {
+		res.send(ctx, w, r, negotiationErr)
+		return
+	}
+
+	if res.storage.stream == nil && res.storage.list == nil {
+		res.send(ctx, w, r, jsh.ISE(fmt.Sprintf("Resource '%s' has no list storage to export", res.Type)))
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+
+	var encoder exportEncoder
+	start := func() {
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": fmt.Sprintf("%s-%s.%s", res.Type, time.Now().UTC().Format("20060102-150405"), format.name),
+		}))
+		w.WriteHeader(http.StatusOK)
+		encoder = format.newEncoder(w)
+	}
+
+	written := 0
+	emit := func(object *jsh.Object) jsh.ErrorType {
+		if !res.permits(ctx, object) {
+			return nil
+		}
+
+		var exported *jsh.Object
+		switch prepared := res.prepare(ctx, object).(type) {
+		case *jsh.Object:
+			exported = prepared
+		case jsh.ErrorType:
+			return prepared
+		}
+		if exported == nil {
+			return nil
+		}
+		if encoder == nil {
+			start()
+		}
+
+		encodeErr := encoder.encode(exported)
+		if encodeErr == nil {
+			written++
+			if written%exportFlushRows == 0 {
+				encodeErr = encoder.flush()
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+		}
+		if encodeErr != nil {
+			return jsh.ISE(fmt.Sprintf("Unable to export object: %s", encodeErr.Error()))
+		}
+
+		// stop the storage once the client is gone
+		if ctx.Err() != nil {
+			return jsh.ISE("Export was cancelled")
+		}
+		return nil
+	}
+
+	var err jsh.ErrorType
+	if res.storage.stream != nil {
+		storageCtx, finish := startStorage(ctx, r, "list_stream")
+		err = res.storage.stream(storageCtx, emit)
+		err = finish(err)
+	} else {
+		storageCtx, finish := startStorage(ctx, r, "list")
+		var list jsh.List
+		list, _, err = res.storage.list(storageCtx)
+		err = finish(err)
+
+		for _, object := range list {
+			if HasError(err) {
+				break
+			}
+			err = emit(object)
+		}
+	}
+
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		if encoder == nil {
+			res.send(ctx, w, r, err)
+		}
+		return
+	}
+
+	if encoder == nil {
+		start()
+	}
+	encoder.flush()
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// negotiateExport picks the format of an export from the "format" query
+// parameter, or else the Accept header
+func negotiateExport(r *http.Request, formats []ExportFormat) (ExportFormat, *jsh.Error) {
+	requested := r.URL.Query().Get("format")
+	if requested != "" {
+		for _, format := range formats {
+			if format.name == requested {
+				return format, nil
+			}
+		}
+
+		return ExportFormat{}, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: fmt.Sprintf("Export format '%s' is not supported", requested),
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return formats[0], nil
+	}
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		name, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+
+		if name == "*/*" {
+			return formats[0], nil
+		}
+		for _, format := range formats {
+			if format.contentType == name {
+				return format, nil
+			}
+		}
+	}
+
+	return ExportFormat{}, &jsh.Error{
+		Title:  "Not Acceptable",
+		Detail: "None of the export formats is acceptable",
+		Status: http.StatusNotAcceptable,
+	}
+}
+
+// csvEncoder writes objects as CSV rows, see CSV
+type csvEncoder struct {
+	writer  *csv.Writer
+	columns []string
+	header  bool
+}
+
+func (e *csvEncoder) encode(object *jsh.Object) error {
+	attributes := map[string]json.RawMessage{}
+	if len(object.Attributes) > 0 {
+		err := json.Unmarshal(object.Attributes, &attributes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !e.header {
+		if e.columns == nil {
+			e.columns = []string{"id"}
+			names := make([]string, 0, len(attributes))
+			for name := range attributes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			e.columns = append(e.columns, names...)
+		}
+
+		err := e.writeHeader()
+		if err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, len(e.columns))
+	for index, column := range e.columns {
+		if column == "id" {
+			row[index] = object.ID
+			continue
+		}
+		row[index] = csvCell(attributes[column])
+	}
+
+	return e.writer.Write(row)
+}
+
+func (e *csvEncoder) flush() error {
+	if !e.header {
+		if e.columns == nil {
+			e.columns = []string{"id"}
+		}
+
+		err := e.writeHeader()
+		if err != nil {
+			return err
+		}
+	}
+
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvEncoder) writeHeader() error {
+	e.header = true
+	return e.writer.Write(e.columns)
+}
+
+// csvCell formats an attribute value as a CSV cell
+func csvCell(value json.RawMessage) string {
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return ""
+	}
+
+	if trimmed[0] == '"' {
+		text := ""
+		if json.Unmarshal(trimmed, &text) == nil {
+			return text
+		}
+	}
+
+	compacted := &bytes.Buffer{}
+	if json.Compact(compacted, trimmed) != nil {
+		return string(trimmed)
+	}
+
+	return compacted.String()
+}
+
+// ndjsonEncoder writes objects as lines of JSON, see NDJSON
+type ndjsonEncoder struct {
+	writer io.Writer
+}
+
+func (e *ndjsonEncoder) encode(object *jsh.Object) error {
+	line, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.writer.Write(append(line, '\n'))
+	return err
+}
+
+func (e *ndjsonEncoder) flush() error {
+	return nil
+}
@@ -0,0 +1,143 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExport(t *testing.T) {
+
+	Convey("Export Tests", t, func() {
+
+		order := func(id string, attributes map[string]interface{}) *jsh.Object {
+			created, _ := jsh.NewObject(id, "orders", attributes)
+			return created
+		}
+
+		orders := NewResource("orders")
+		orders.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+			return jsh.List{
+				order("1", map[string]interface{}{"total": 12.5, "status": "paid, shipped", "lines": []int{1, 2}}),
+				order("2", map[string]interface{}{"total": 3, "status": nil}),
+			}, nil
+		})
+
+		api := New("")
+
+		get := func(url string, accept string) *httptest.ResponseRecorder {
+			api.Add(orders)
+
+			request := httptest.NewRequest("GET", url, nil)
+			if accept != "" {
+				request.Header.Set("Accept", accept)
+			}
+
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should export the collection as CSV", func() {
+			orders.Export(CSV("id", "total", "status", "lines"), NDJSON())
+
+			recorder := get("/orders/export", "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, "text/csv")
+			So(recorder.Header().Get("Content-Disposition"), ShouldStartWith, `attachment; filename=orders-`)
+			So(recorder.Header().Get("Content-Disposition"), ShouldEndWith, `.csv`)
+			So(recorder.Body.String(), ShouldEqual, "id,total,status,lines\n"+
+				"1,12.5,\"paid, shipped\",\"[1,2]\"\n"+
+				"2,3,,\n")
+			So(recorder.Flushed, ShouldBeTrue)
+		})
+
+		Convey("should default the columns to the sorted attributes", func() {
+			orders.Export(CSV())
+
+			recorder := get("/orders/export", "")
+			So(strings.SplitN(recorder.Body.String(), "\n", 2)[0], ShouldEqual, "id,lines,status,total")
+		})
+
+		Convey("should export the collection as NDJSON", func() {
+			orders.Export(CSV(), NDJSON())
+
+			recorder := get("/orders/export?format=ndjson", "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, "application/x-ndjson")
+
+			lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+			So(lines, ShouldHaveLength, 2)
+
+			object := jsh.Object{}
+			So(json.Unmarshal([]byte(lines[1]), &object), ShouldBeNil)
+			So(object.ID, ShouldEqual, "2")
+			So(object.Type, ShouldEqual, "orders")
+		})
+
+		Convey("should negotiate the format", func() {
+			orders.Export(CSV(), NDJSON())
+
+			So(get("/orders/export", "application/x-ndjson").Header().Get("Content-Type"), ShouldEqual, "application/x-ndjson")
+			So(get("/orders/export", "*/*").Header().Get("Content-Type"), ShouldEqual, "text/csv")
+			So(get("/orders/export?format=csv", "application/x-ndjson").Header().Get("Content-Type"), ShouldEqual, "text/csv")
+			So(get("/orders/export", "application/pdf").Code, ShouldEqual, http.StatusNotAcceptable)
+			So(get("/orders/export?format=xlsx", "").Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("should redact the fields hidden by the field policy", func() {
+			orders.FieldPolicy(func(ctx context.Context, objectType string) []string {
+				return []string{"total"}
+			})
+			orders.Export(CSV(), NDJSON())
+
+			recorder := get("/orders/export?format=csv", "")
+			So(recorder.Body.String(), ShouldEqual, "id,total\n1,12.5\n2,3\n")
+
+			recorder = get("/orders/export?format=ndjson", "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"total"`)
+			So(recorder.Body.String(), ShouldNotContainSubstring, "status")
+			So(recorder.Body.String(), ShouldNotContainSubstring, "lines")
+		})
+
+		Convey("should stream from the ListStream storage", func() {
+			orders.ListStream(func(ctx context.Context, emit func(object *jsh.Object) jsh.ErrorType) jsh.ErrorType {
+				for index := 1; index <= 250; index++ {
+					err := emit(order(strconv.Itoa(index), map[string]interface{}{"total": index}))
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			orders.Export(NDJSON())
+
+			recorder := get("/orders/export", "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(strings.Count(recorder.Body.String(), "\n"), ShouldEqual, 250)
+		})
+
+		Convey("should send storage errors before the export starts", func() {
+			orders.ListStream(func(ctx context.Context, emit func(object *jsh.Object) jsh.ErrorType) jsh.ErrorType {
+				return jsh.ISE("database is down")
+			})
+			orders.Export(CSV())
+
+			recorder := get("/orders/export", "")
+			So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+
+		Convey("should require a format", func() {
+			So(func() { orders.Export() }, ShouldPanicWith, "jshapi: export of resource 'orders' requires a format")
+		})
+	})
+}
@@ -0,0 +1,129 @@
+package jshapi
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+FieldPolicyFunc returns the attributes and relationships of objects of type
+objectType the caller may see, all of them when it returns nil, none of them when
+it returns an empty list.
+*/
+type FieldPolicyFunc func(ctx context.Context, objectType string) []string
+
+/*
+FieldPolicy redacts the attributes and relationships of the objects sent by the
+resource, primary data and included objects alike, to those policy allows for
+their type:
+
+	users.FieldPolicy(func(ctx context.Context, objectType string) []string {
+		if objectType == "users" && !isAdmin(ctx) {
+			return []string{"name", "avatar", "posts"}
+		}
+		return nil
+	})
+
+Redacted members are left out of the response, as with sparse fieldsets, which
+are intersected with the allowed fields. Request bodies are parsed and validated
+regardless of the policy, which only applies to the objects sent.
+*/
+func (res *Resource) FieldPolicy(policy FieldPolicyFunc) {
+	res.checkRegistration("a field policy")
+
+	res.fieldPolicy = policy
+}
+
+// policyFields returns the fieldsets of a request restricted by the field policy
+// of the resource for the types of objects
+func (res *Resource) policyFields(ctx context.Context, fields fieldsets, objects jsh.List) fieldsets {
+	if res.fieldPolicy == nil {
+		return fields
+	}
+
+	restricted := fieldsets{}
+	for resourceType, fieldset := range fields {
+		restricted[resourceType] = fieldset
+	}
+
+	checked := map[string]bool{}
+	for _, object := range objects {
+		if object == nil || checked[object.Type] {
+			continue
+		}
+		checked[object.Type] = true
+
+		allowed := res.fieldPolicy(ctx, object.Type)
+		if allowed == nil {
+			continue
+		}
+
+		requested, hasFieldset := restricted[object.Type]
+		fieldset := map[string]bool{}
+		for _, field := range allowed {
+			if !hasFieldset || requested[field] {
+				fieldset[field] = true
+			}
+		}
+		restricted[object.Type] = fieldset
+	}
+
+	return restricted
+}
+
+// redact returns the sendable with the field policy of the resource applied to
+// copies of its objects, leaving the objects of storage untouched
+func (res *Resource) redact(ctx context.Context, sendable jsh.Sendable) jsh.Sendable {
+	if res.fieldPolicy == nil {
+		return sendable
+	}
+
+	var redacted jsh.Sendable
+	var objects jsh.List
+
+	switch typed := sendable.(type) {
+	case *jsh.Object:
+		if typed == nil {
+			return sendable
+		}
+		copied := documentCopy(typed)
+		redacted, objects = copied, jsh.List{copied}
+	case jsh.List:
+		objects = documentCopies(typed)
+		redacted = objects
+	case *jsh.Document:
+		if typed.HasErrors() {
+			return sendable
+		}
+		document := *typed
+		document.Data = documentCopies(typed.Data)
+		document.Included = documentCopies(typed.Included)
+		redacted, objects = &document, append(append(jsh.List{}, document.Data...), document.Included...)
+	default:
+		return sendable
+	}
+
+	err := res.policyFields(ctx, nil, objects).apply(objects)
+	if HasError(err) {
+		return err
+	}
+
+	return redacted
+}
+
+// documentCopies returns a copy of list holding copies of its objects
+func documentCopies(list jsh.List) jsh.List {
+	if list == nil {
+		return nil
+	}
+
+	copied := make(jsh.List, len(list))
+	for index, object := range list {
+		if object != nil {
+			copied[index] = documentCopy(object)
+		}
+	}
+
+	return copied
+}
@@ -0,0 +1,113 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFieldPolicy(t *testing.T) {
+
+	Convey("Field Policy Tests", t, func() {
+
+		user := func(id string) *jsh.Object {
+			object, _ := jsh.NewObject(id, "users", map[string]string{"name": "user" + id, "email": "user" + id + "@example.com"})
+			object.Relationships = map[string]*jsh.Relationship{
+				"manager": {Data: jsh.ResourceLinkage{{Type: "users", ID: "9"}}},
+				"reviews": {Data: jsh.ResourceLinkage{{Type: "reviews", ID: "3"}}},
+			}
+			return object
+		}
+
+		stored := user("1")
+
+		users := NewResource("users")
+		users.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			if id == stored.ID {
+				return stored, nil
+			}
+			return user(id), nil
+		})
+		users.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+			return jsh.List{user("1"), user("2")}, nil
+		})
+		users.ToOne("manager", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return user("9"), nil
+		})
+		users.Patch(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+			return user(object.ID), nil
+		})
+		users.FieldPolicy(func(ctx context.Context, objectType string) []string {
+			return []string{"name", "manager"}
+		})
+
+		api := New("")
+		api.Add(users)
+
+		serve := func(method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			if body != "" {
+				request.Header.Set("Content-Type", jsh.ContentType)
+			}
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should remove redacted attributes and relationships from objects", func() {
+			recorder := serve("GET", "/users/1", "")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"name": "user1"`)
+			So(recorder.Body.String(), ShouldNotContainSubstring, "email")
+			So(recorder.Body.String(), ShouldContainSubstring, "manager")
+			So(recorder.Body.String(), ShouldNotContainSubstring, "reviews")
+		})
+
+		Convey("should leave the objects of storage untouched", func() {
+			serve("GET", "/users/1", "")
+			So(string(stored.Attributes), ShouldContainSubstring, "email")
+			So(stored.Relationships, ShouldContainKey, "reviews")
+		})
+
+		Convey("should redact lists", func() {
+			recorder := serve("GET", "/users", "")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"name": "user2"`)
+			So(recorder.Body.String(), ShouldNotContainSubstring, "email")
+		})
+
+		Convey("should redact included objects", func() {
+			recorder := serve("GET", "/users/1?include=manager&fields[users]=name,email,manager", "")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"name": "user9"`)
+			So(recorder.Body.String(), ShouldNotContainSubstring, "email")
+			So(recorder.Body.String(), ShouldNotContainSubstring, "reviews")
+		})
+
+		Convey("should intersect sparse fieldsets with the allowed fields", func() {
+			recorder := serve("GET", "/users/1?fields[users]=email,manager", "")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "manager")
+			So(recorder.Body.String(), ShouldNotContainSubstring, "email")
+			So(recorder.Body.String(), ShouldNotContainSubstring, "name")
+		})
+
+		Convey("should redact write responses without affecting validation", func() {
+			recorder := serve("PATCH", "/users/1", `{"data": {"type": "users", "id": "1", "attributes": {"email": "new@example.com"}}}`)
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"name": "user1"`)
+			So(recorder.Body.String(), ShouldNotContainSubstring, "email")
+		})
+	})
+}
@@ -0,0 +1,97 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// fieldsets holds the sparse fieldsets of a request by resource type, such as
+// {"users": {"name": true}} for "fields[users]=name"
+type fieldsets map[string]map[string]bool
+
+/*
+parseFields parses the `fields[type]` query parameters of a request. Each lists the
+attributes and relationships sent for the objects of its type, primary data and
+included objects alike, an empty list sending none of them. Parameters named
+"fields" without a type are rejected with a 400.
+*/
+func parseFields(r *http.Request) (fieldsets, *jsh.Error) {
+	var fields fieldsets
+	for param, values := range r.URL.Query() {
+		if param != "fields" && !strings.HasPrefix(param, "fields[") {
+			continue
+		}
+
+		resourceType := strings.TrimSuffix(strings.TrimPrefix(param, "fields["), "]")
+		if !strings.HasSuffix(param, "]") || resourceType == "" || strings.ContainsAny(resourceType, "[]") {
+			return nil, &jsh.Error{
+				Title:  "Bad Request",
+				Detail: fmt.Sprintf("Invalid sparse fieldset parameter '%s', expected 'fields[type]'", param),
+				Status: http.StatusBadRequest,
+			}
+		}
+
+		fieldset := map[string]bool{}
+		for _, field := range strings.Split(values[0], ",") {
+			field = strings.TrimSpace(field)
+			if field != "" {
+				fieldset[field] = true
+			}
+		}
+
+		if fields == nil {
+			fields = fieldsets{}
+		}
+		fields[resourceType] = fieldset
+	}
+
+	return fields, nil
+}
+
+// apply trims the attributes and relationships of objects to the fieldset of
+// their type, objects must be copies owned by the document
+func (fields fieldsets) apply(objects jsh.List) jsh.ErrorType {
+	for _, object := range objects {
+		fieldset, requested := fields[object.Type]
+		if !requested {
+			continue
+		}
+
+		if len(object.Attributes) > 0 {
+			attributes := map[string]json.RawMessage{}
+			err := json.Unmarshal(object.Attributes, &attributes)
+			if err != nil {
+				return jsh.ISE(fmt.Sprintf("Unable to apply the fieldset of '%s': %s", object.Type, err.Error()))
+			}
+
+			trimmed := map[string]json.RawMessage{}
+			for name, value := range attributes {
+				if fieldset[name] {
+					trimmed[name] = value
+				}
+			}
+
+			object.Attributes = nil
+			if len(trimmed) > 0 {
+				object.Attributes, err = json.Marshal(trimmed)
+				if err != nil {
+					return jsh.ISE(fmt.Sprintf("Unable to apply the fieldset of '%s': %s", object.Type, err.Error()))
+				}
+			}
+		}
+
+		relationships := map[string]*jsh.Relationship{}
+		for name, relationship := range object.Relationships {
+			if fieldset[name] {
+				relationships[name] = relationship
+			}
+		}
+		object.Relationships = relationships
+	}
+
+	return nil
+}
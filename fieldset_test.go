@@ -0,0 +1,98 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSparseFieldsets(t *testing.T) {
+
+	Convey("Sparse Fieldsets Tests", t, func() {
+
+		post, _ := jsh.NewObject("1", "posts", map[string]string{"title": "Hello", "body": "World"})
+		post.Relationships["tags"] = &jsh.Relationship{}
+
+		posts := NewResource("posts")
+		posts.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return post, nil
+		})
+		posts.ToOne("author", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return jsh.NewObject("9", "users", map[string]string{"name": "Ann", "email": "ann@example.com"})
+		})
+
+		api := New("")
+		api.Add(posts)
+
+		type resource struct {
+			Type          string
+			Attributes    map[string]string
+			Relationships map[string]json.RawMessage
+		}
+		type document struct {
+			Data     resource
+			Included []resource
+		}
+
+		get := func(url string) (*httptest.ResponseRecorder, document) {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+
+			doc := document{}
+			json.Unmarshal(recorder.Body.Bytes(), &doc)
+			return recorder, doc
+		}
+
+		Convey("should send every field by default", func() {
+			recorder, doc := get("/posts/1")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(doc.Data.Attributes, ShouldResemble, map[string]string{"title": "Hello", "body": "World"})
+		})
+
+		Convey("should trim attributes and relationships to the fieldset", func() {
+			recorder, doc := get("/posts/1?fields[posts]=title")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(doc.Data.Attributes, ShouldResemble, map[string]string{"title": "Hello"})
+			So(doc.Data.Relationships, ShouldBeEmpty)
+
+			_, doc = get("/posts/1?fields[posts]=tags")
+			So(doc.Data.Attributes, ShouldBeEmpty)
+			So(doc.Data.Relationships, ShouldContainKey, "tags")
+		})
+
+		Convey("should send no fields for an empty fieldset", func() {
+			recorder, doc := get("/posts/1?fields[posts]=")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(doc.Data.Type, ShouldEqual, "posts")
+			So(doc.Data.Attributes, ShouldBeEmpty)
+		})
+
+		Convey("should trim included objects by their own type", func() {
+			recorder, doc := get("/posts/1?include=author&fields[users]=name")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(doc.Data.Attributes, ShouldHaveLength, 2)
+			So(doc.Included, ShouldHaveLength, 1)
+			So(doc.Included[0].Attributes, ShouldResemble, map[string]string{"name": "Ann"})
+		})
+
+		Convey("should not amend the objects of the storage", func() {
+			get("/posts/1?fields[posts]=title")
+			So(string(post.Attributes), ShouldContainSubstring, "body")
+			So(post.Relationships, ShouldContainKey, "tags")
+		})
+
+		Convey("should reject fieldsets without a type", func() {
+			recorder, _ := get("/posts/1?fields=title")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+
+			recorder, _ = get("/posts/1?fields[]=title")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
@@ -0,0 +1,166 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"goji.io"
+	"golang.org/x/net/context"
+)
+
+/*
+Freeze ends the setup of the API and of all its resources: registering a route,
+a middleware, a resource, or changing a setting afterwards panics. Registration is
+not synchronized with request handling, so it must be done before the API serves
+its first request. Freezing the API once it is set up turns a late registration
+into an immediate failure rather than a data race:
+
+	api := jshapi.New("api")
+	api.Add(users)
+	api.Freeze()
+
+	http.ListenAndServe(":8000", api)
+
+Since nothing changes once frozen, requests read the registries without locking.
+*/
+func (a *API) Freeze() {
+	for _, resource := range a.Resources {
+		resource.Freeze()
+	}
+
+	atomic.StoreInt32(&a.frozen, 1)
+}
+
+// Frozen reports whether Freeze was called on the API
+func (a *API) Frozen() bool {
+	return atomic.LoadInt32(&a.frozen) == 1
+}
+
+/*
+Freeze ends the setup of the resource, any later registration panics. API.Freeze
+freezes all resources of the API.
+*/
+func (res *Resource) Freeze() {
+	res.registerRoutes()
+	atomic.StoreInt32(&res.frozen, 1)
+}
+
+// Frozen reports whether Freeze was called on the resource
+func (res *Resource) Frozen() bool {
+	return atomic.LoadInt32(&res.frozen) == 1
+}
+
+// RouteList returns a copy of the routes registered to the resource
+func (res *Resource) RouteList() []string {
+	return append([]string{}, res.Routes...)
+}
+
+// RelationshipMap returns a copy of the relationships registered to the resource
+func (res *Resource) RelationshipMap() map[string]Relationship {
+	relationships := make(map[string]Relationship, len(res.Relationships))
+	for name, relationship := range res.Relationships {
+		relationships[name] = relationship
+	}
+
+	return relationships
+}
+
+// checkRegistration panics when registering "what" on a frozen API
+func (a *API) checkRegistration(what string) {
+	if a.Frozen() {
+		panic(fmt.Sprintf("jshapi: unable to register %s, the API is frozen", what))
+	}
+}
+
+// checkRegistration panics when registering "what" on a frozen resource
+func (res *Resource) checkRegistration(what string) {
+	if res.Frozen() {
+		panic(fmt.Sprintf("jshapi: unable to register %s, resource '%s' is frozen", what, res.Type))
+	}
+}
+
+// Handle implements goji.Mux.Handle, it panics once the API is frozen
+func (a *API) Handle(p goji.Pattern, h http.Handler) {
+	a.checkRegistration("a route")
+	a.Mux.Handle(p, h)
+}
+
+// HandleC implements goji.Mux.HandleC, it panics once the API is frozen
+func (a *API) HandleC(p goji.Pattern, h goji.Handler) {
+	a.checkRegistration("a route")
+	a.Mux.HandleC(p, h)
+}
+
+// HandleFunc implements goji.Mux.HandleFunc, it panics once the API is frozen
+func (a *API) HandleFunc(p goji.Pattern, h func(http.ResponseWriter, *http.Request)) {
+	a.checkRegistration("a route")
+	a.Mux.HandleFunc(p, h)
+}
+
+// HandleFuncC implements goji.Mux.HandleFuncC, it panics once the API is frozen
+func (a *API) HandleFuncC(p goji.Pattern, h func(context.Context, http.ResponseWriter, *http.Request)) {
+	a.checkRegistration("a route")
+	a.Mux.HandleFuncC(p, h)
+}
+
+// Use implements goji.Mux.Use, it panics once the API is frozen
+func (a *API) Use(middleware func(http.Handler) http.Handler) {
+	a.checkRegistration("a middleware")
+	a.Mux.Use(middleware)
+}
+
+// UseC implements goji.Mux.UseC, it panics once the API is frozen
+func (a *API) UseC(middleware func(goji.Handler) goji.Handler) {
+	a.checkRegistration("a middleware")
+	a.Mux.UseC(middleware)
+}
+
+// Handle implements goji.Mux.Handle, it panics once the resource is frozen. Routes
+// are matched most specific first, see handleOrdered.
+func (res *Resource) Handle(p goji.Pattern, h http.Handler) {
+	defer res.record(func(clone *Resource) { clone.Handle(p, h) })()
+
+	res.checkRegistration("a route")
+	res.handleOrdered(p, func() { res.Mux.Handle(p, h) })
+}
+
+// HandleC implements goji.Mux.HandleC, it panics once the resource is frozen
+func (res *Resource) HandleC(p goji.Pattern, h goji.Handler) {
+	defer res.record(func(clone *Resource) { clone.HandleC(p, h) })()
+
+	res.checkRegistration("a route")
+	res.handleOrdered(p, func() { res.Mux.HandleC(p, h) })
+}
+
+// HandleFunc implements goji.Mux.HandleFunc, it panics once the resource is frozen
+func (res *Resource) HandleFunc(p goji.Pattern, h func(http.ResponseWriter, *http.Request)) {
+	defer res.record(func(clone *Resource) { clone.HandleFunc(p, h) })()
+
+	res.checkRegistration("a route")
+	res.handleOrdered(p, func() { res.Mux.HandleFunc(p, h) })
+}
+
+// HandleFuncC implements goji.Mux.HandleFuncC, it panics once the resource is frozen
+func (res *Resource) HandleFuncC(p goji.Pattern, h func(context.Context, http.ResponseWriter, *http.Request)) {
+	defer res.record(func(clone *Resource) { clone.HandleFuncC(p, h) })()
+
+	res.checkRegistration("a route")
+	res.handleOrdered(p, func() { res.Mux.HandleFuncC(p, h) })
+}
+
+// Use implements goji.Mux.Use, it panics once the resource is frozen
+func (res *Resource) Use(middleware func(http.Handler) http.Handler) {
+	defer res.record(func(clone *Resource) { clone.Use(middleware) })()
+
+	res.checkRegistration("a middleware")
+	res.Mux.Use(middleware)
+}
+
+// UseC implements goji.Mux.UseC, it panics once the resource is frozen
+func (res *Resource) UseC(middleware func(goji.Handler) goji.Handler) {
+	defer res.record(func(clone *Resource) { clone.UseC(middleware) })()
+
+	res.checkRegistration("a middleware")
+	res.Mux.UseC(middleware)
+}
@@ -0,0 +1,76 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFreeze(t *testing.T) {
+
+	Convey("Freeze Tests", t, func() {
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+		resource.ToMany("foos", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return jsh.List{}, nil
+		})
+
+		api := New("")
+		api.Add(resource)
+		api.Freeze()
+
+		Convey("->Freeze()", func() {
+
+			Convey("should freeze the API and its resources", func() {
+				So(api.Frozen(), ShouldBeTrue)
+				So(resource.Frozen(), ShouldBeTrue)
+			})
+
+			Convey("should panic on resource registration", func() {
+				So(func() { resource.Action("reset", nil) }, ShouldPanicWith, "jshapi: unable to register route /:id/reset, resource 'bars' is frozen")
+				So(func() { resource.ToOne("baz", nil) }, ShouldPanic)
+				So(func() { resource.Post(nil) }, ShouldPanic)
+				So(func() { resource.AddValidator(nil) }, ShouldPanic)
+				So(func() { resource.UseC(resource.routeInfoMiddleware) }, ShouldPanic)
+				So(func() { resource.HandleFuncC(pat.Get("/custom"), nil) }, ShouldPanic)
+
+				So(resource.Relationships, ShouldNotContainKey, "baz")
+			})
+
+			Convey("should panic on API registration", func() {
+				So(func() { api.Add(NewResource("bazs")) }, ShouldPanicWith, "jshapi: unable to register resource 'bazs', the API is frozen")
+				So(func() { api.AtomicOperations("operations") }, ShouldPanic)
+				So(func() { api.SupportExtension("https://example.com/ext") }, ShouldPanic)
+				So(func() { api.UseC(recordAPIPattern) }, ShouldPanic)
+			})
+
+			Convey("should keep serving requests", func() {
+				recorder := httptest.NewRecorder()
+				api.ServeHTTP(recorder, httptest.NewRequest("GET", "/bars/1/foos", nil))
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("->RouteList()", func() {
+			routes := resource.RouteList()
+			So(routes, ShouldResemble, resource.Routes)
+
+			routes[0] = "modified"
+			So(resource.Routes[0], ShouldNotEqual, "modified")
+		})
+
+		Convey("->RelationshipMap()", func() {
+			relationships := resource.RelationshipMap()
+			So(relationships, ShouldResemble, map[string]Relationship{"foos": ToMany})
+
+			relationships["bazs"] = ToMany
+			So(resource.Relationships, ShouldNotContainKey, "bazs")
+		})
+	})
+}
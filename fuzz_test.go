@@ -0,0 +1,161 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store/memstore"
+)
+
+/*
+The fuzz targets feed arbitrary request bodies and headers through the request
+parsing entry points. Handlers must never panic, and must answer invalid input with
+a well-formed JSON API error document rather than a 5XX. Crashers found by
+`go test -fuzz` are kept as regression seeds in testdata/fuzz.
+*/
+
+// fuzzAPI serves a "bars" resource with a stored "1" and strict member names, a
+// "foos" resource accepting bulk creation, and atomic operations
+func fuzzAPI(t testing.TB) *API {
+	storage := memstore.New(testResourceType)
+
+	object, err := jsh.NewObject("1", testResourceType, testObjAttrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.Save(context.Background(), object)
+
+	resource := NewCRUDResource(testResourceType, storage)
+	resource.StrictMemberNames(3)
+
+	bulkStorage := memstore.New("foos")
+	bulk := NewResource("foos")
+	bulk.PostBulk(func(ctx context.Context, list jsh.List) (jsh.List, jsh.ErrorType) {
+		created := jsh.List{}
+		for _, object := range list {
+			saved, err := bulkStorage.Save(ctx, object)
+			if HasError(err) {
+				return nil, err
+			}
+			created = append(created, saved)
+		}
+
+		return created, nil
+	})
+
+	api := New("")
+	api.Add(resource)
+	api.Add(bulk)
+	api.AtomicOperations("operations")
+
+	return api
+}
+
+// fuzzRequest serves a request, checking that the response is either a success or
+// a JSON API client error
+func fuzzRequest(t *testing.T, api *API, request *http.Request) {
+	recorder := httptest.NewRecorder()
+	api.ServeHTTP(recorder, request)
+
+	label := request.Method + " " + request.URL.Path
+	if recorder.Code >= 500 {
+		t.Fatalf("%s: unexpected status %d\n%s", label, recorder.Code, recorder.Body.String())
+	}
+
+	if recorder.Code < 400 {
+		return
+	}
+
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), jsh.ContentType) {
+		t.Fatalf("%s: unexpected error Content-Type %q", label, recorder.Header().Get("Content-Type"))
+	}
+
+	document := struct {
+		Errors []*jsh.Error `json:"errors"`
+	}{}
+	err := json.Unmarshal(recorder.Body.Bytes(), &document)
+	if err != nil || len(document.Errors) == 0 {
+		t.Fatalf("%s: malformed error document\n%s", label, recorder.Body.String())
+	}
+}
+
+// fuzzBody sends a body to each of the given routes
+func fuzzBody(f *testing.F, method string, urls []string, contentType string, seeds ...string) {
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		api := fuzzAPI(t)
+
+		for _, url := range urls {
+			request := httptest.NewRequest(method, url, bytes.NewReader(body))
+			request.Header.Set("Content-Type", contentType)
+
+			fuzzRequest(t, api, request)
+		}
+	})
+}
+
+func FuzzPostBody(f *testing.F) {
+	fuzzBody(f, "POST", []string{"/bars", "/foos"}, jsh.ContentType,
+		`{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`,
+		`{"data": [{"type": "foos", "attributes": {"foo": "bar"}}, {"type": "foos"}]}`,
+		`{"data": {"type": "bars", "id": "1"}}`,
+		`{"data": {"type": "foos"}}`,
+		`{"data": null}`,
+		`{"data": []}`,
+		`{"data": {"type": "bars", "attributes": {"a b": {"c": [1, {"d e": 2}]}}}}`,
+		`{"data": {"type": "bars", "attributes": []}}`,
+		`[]`,
+		``,
+	)
+}
+
+func FuzzPatchBody(f *testing.F) {
+	fuzzBody(f, "PATCH", []string{"/bars/1"}, jsh.ContentType,
+		`{"data": {"type": "bars", "id": "1", "attributes": {"foo": "baz"}}}`,
+		`{"data": {"type": "bars", "id": "2", "attributes": {"foo": "baz"}}}`,
+		`{"data": {"type": "bars", "attributes": {"foo": "baz"}}}`,
+		`{"data": {"type": "bars", "id": "1", "attributes": {"foo": null}}}`,
+	)
+}
+
+func FuzzAtomicOperations(f *testing.F) {
+	fuzzBody(f, "POST", []string{"/operations"}, AtomicContentType,
+		`{"atomic:operations": [{"op": "add", "data": {"type": "bars", "lid": "a", "attributes": {"foo": "bar"}}}]}`,
+		`{"atomic:operations": [{"op": "update", "ref": {"type": "bars", "id": "1"}, "data": {"type": "bars", "id": "1"}}]}`,
+		`{"atomic:operations": [{"op": "remove", "href": "/bars/1"}]}`,
+		`{"atomic:operations": [{"op": "remove", "ref": {"type": "bars", "lid": "missing"}}]}`,
+		`{"atomic:operations": [{"op": "add", "ref": {"type": "bars", "id": "1", "relationship": "foos"}}]}`,
+		`{"atomic:operations": []}`,
+	)
+}
+
+func FuzzMediaTypes(f *testing.F) {
+	f.Add(jsh.ContentType, "")
+	f.Add(AtomicContentType, jsh.ContentType)
+	f.Add(jsh.ContentType+`; profile="https://example.com/a https://example.com/b"`, "*/*")
+	f.Add(jsh.ContentType+`; foo=bar`, jsh.ContentType+`; ext="https://example.com/ext", text/html`)
+	f.Add("application/json", `application/*; q=0.5, `+jsh.ContentType+`;q=0`)
+
+	f.Fuzz(func(t *testing.T, contentType string, accept string) {
+		api := fuzzAPI(t)
+
+		for _, method := range []string{"GET", "POST"} {
+			body := `{"data": {"type": "bars"}}`
+			request := httptest.NewRequest(method, "/bars", strings.NewReader(body))
+			request.Header.Set("Content-Type", contentType)
+			request.Header.Set("Accept", accept)
+
+			fuzzRequest(t, api, request)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package jshapi
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// defaultIDParam names the id variable of resource routes, as in /users/:id
+const defaultIDParam = "id"
+
+// validIDParam matches the variable names goji patterns accept
+var validIDParam = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+/*
+WithIDParam names the id variable of every route of the resource, such as "user_id"
+for `/users/:user_id`, rather than "id". Relationship and action routes are built
+with it as well. Handlers read the id with ResourceID, or pat.Param and the same
+name. Panics if name is not a valid pattern variable name.
+*/
+func WithIDParam(name string) ResourceOption {
+	return func(res *Resource) {
+		if !validIDParam.MatchString(name) {
+			panic(fmt.Sprintf("jshapi: invalid id parameter name '%s' for '%s'", name, res.Type))
+		}
+
+		res.idParam = name
+	}
+}
+
+// IDParam returns the name of the id variable of the resource routes, see
+// ResourceKey for composite ids
+func (res *Resource) IDParam() string {
+	return res.idParam
+}
+
+// ResourceID returns the id matched by a route of res, or an empty string for
+// routes without one, whatever the id variable is named, see WithIDParam. Composite
+// ids are encoded with CompositeID.
+func ResourceID(ctx context.Context, res *Resource) string {
+	id, _ := res.routeID(ctx)
+	return id
+}
+
+// idRoute is the route of a single object of the resource, such as /:id
+func (res *Resource) idRoute() string {
+	return "/:" + strings.Join(res.idParams(), "/:")
+}
@@ -0,0 +1,89 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIDParam(t *testing.T) {
+
+	Convey("ID Param Tests", t, func() {
+
+		var received string
+		get := func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			received = id
+			return sampleObject(id, "users", testObjAttrs), nil
+		}
+		update := func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+			return object, nil
+		}
+		posts := func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			received = id
+			return jsh.List{}, nil
+		}
+
+		resource := NewResource("users", WithIDParam("user_id"))
+		resource.Get(get)
+		resource.Patch(update)
+		resource.ToMany("posts", posts)
+		resource.HandleFuncC(pat.Get("/:user_id/export"), resource.Wrap(OpAction, "/:user_id/export",
+			func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				received = ResourceID(ctx, resource)
+				w.WriteHeader(http.StatusOK)
+			},
+		))
+
+		api := New("")
+		api.Add(resource)
+
+		send := func(method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should name the id variable of every route", func() {
+			So(resource.IDParam(), ShouldEqual, "user_id")
+			So(api.RouteTree(), ShouldEqual, strings.Join([]string{
+				"GET - /users/:user_id",
+				"PATCH - /users/:user_id",
+				"GET - /users/:user_id/posts",
+				"GET - /users/:user_id/relationships/posts",
+				"",
+			}, "\n"))
+		})
+
+		Convey("should pass the id to storage", func() {
+			So(send("GET", "/users/5", "").Code, ShouldEqual, http.StatusOK)
+			So(received, ShouldEqual, "5")
+
+			So(send("GET", "/users/6/relationships/posts", "").Code, ShouldEqual, http.StatusOK)
+			So(received, ShouldEqual, "6")
+
+			recorder := send("PATCH", "/users/7", `{"data": {"type": "users", "id": "8", "attributes": {"foo": "bar"}}}`)
+			So(recorder.Code, ShouldEqual, http.StatusConflict)
+		})
+
+		Convey("->ResourceID()", func() {
+			So(send("GET", "/users/9/export", "").Code, ShouldEqual, http.StatusOK)
+			So(received, ShouldEqual, "9")
+
+			So(ResourceID(context.Background(), resource), ShouldEqual, "")
+		})
+
+		Convey("should panic on invalid names", func() {
+			So(func() { NewResource("users", WithIDParam("user-id")) }, ShouldPanic)
+			So(func() { NewResource("users", WithIDParam("")) }, ShouldPanic)
+		})
+	})
+}
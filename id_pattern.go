@@ -0,0 +1,101 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// Common id patterns, see IDPattern
+const (
+	// UUIDv4 matches version 4 UUIDs, in any case
+	UUIDv4 = `(?i)[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}`
+	// Numeric matches unsigned integers
+	Numeric = `[0-9]+`
+	// Slug matches lowercase alphanumerics separated by single dashes
+	Slug = `[a-z0-9]+(?:-[a-z0-9]+)*`
+)
+
+/*
+IDPattern restricts the ids of the resource to those fully matching the regular
+expression pattern, such as UUIDv4 or Numeric. Every route with an id, including
+relationship, action and custom routes added through Wrap, checks it before running
+storage and responds 404, or the InvalidIDStatus, on mismatch. Atomic operations
+are checked as well. Panics if pattern is not a valid regular expression.
+*/
+func (res *Resource) IDPattern(pattern string) {
+	res.checkRegistration("an id pattern")
+
+	compiled, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", pattern))
+	if err != nil {
+		panic(fmt.Sprintf("jshapi: invalid id pattern for '%s': %s", res.Type, err.Error()))
+	}
+
+	res.idPattern = compiled
+}
+
+// InvalidIDStatus sets the status of the responses to ids not matching IDPattern,
+// either 404 Not Found, the default, or 400 Bad Request
+func (res *Resource) InvalidIDStatus(status int) {
+	res.checkRegistration("an invalid id status")
+
+	if status != http.StatusNotFound && status != http.StatusBadRequest {
+		panic(fmt.Sprintf("jshapi: invalid id status %d for '%s', expected 404 or 400", status, res.Type))
+	}
+
+	res.invalidIDStatus = status
+}
+
+// WithIDPattern sets the IDPattern of the resource
+func WithIDPattern(pattern string) ResourceOption {
+	return func(res *Resource) {
+		res.IDPattern(pattern)
+	}
+}
+
+// WithInvalidIDStatus sets the InvalidIDStatus of the resource
+func WithInvalidIDStatus(status int) ResourceOption {
+	return func(res *Resource) {
+		res.InvalidIDStatus(status)
+	}
+}
+
+// idError checks an id against the id pattern of the resource, each of their
+// values for composite ids
+func (res *Resource) idError(id string) *jsh.Error {
+	if res.idPattern == nil || res.idMatches(id) {
+		return nil
+	}
+
+	if res.invalidIDStatus != http.StatusBadRequest {
+		return jsh.NotFound(res.Type, id)
+	}
+
+	return &jsh.Error{
+		Title:  "Bad Request",
+		Detail: fmt.Sprintf("Invalid id '%s' for resources of type '%s'", id, res.Type),
+		Status: http.StatusBadRequest,
+	}
+}
+
+// idMatches reports whether an id matches the id pattern
+func (res *Resource) idMatches(id string) bool {
+	if res.compositeID == nil {
+		return res.idPattern.MatchString(id)
+	}
+
+	values, err := ParseCompositeID(id, len(res.compositeID))
+	if err != nil {
+		return false
+	}
+
+	for _, value := range values {
+		if !res.idPattern.MatchString(value) {
+			return false
+		}
+	}
+
+	return true
+}
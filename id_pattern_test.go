@@ -0,0 +1,98 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIDPattern(t *testing.T) {
+
+	Convey("ID Pattern Tests", t, func() {
+
+		called := 0
+		get := func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			called++
+			return sampleObject(id, testResourceType, testObjAttrs), nil
+		}
+		toMany := func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			called++
+			return jsh.List{}, nil
+		}
+
+		resource := NewResource(testResourceType, WithIDPattern(Numeric))
+		resource.Get(get)
+		resource.ToMany("foos", toMany)
+		resource.Action("testAction", get)
+
+		api := New("")
+		api.Add(resource)
+
+		send := func(url string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+			return recorder
+		}
+
+		Convey("should serve matching ids", func() {
+			So(send("/bars/12").Code, ShouldEqual, http.StatusOK)
+			So(send("/bars/12/foos").Code, ShouldEqual, http.StatusOK)
+			So(send("/bars/12/testAction").Code, ShouldEqual, http.StatusOK)
+			So(called, ShouldEqual, 3)
+		})
+
+		Convey("should respond 404 to mismatching ids without calling storage", func() {
+			So(send("/bars/abc").Code, ShouldEqual, http.StatusNotFound)
+			So(send("/bars/%20").Code, ShouldEqual, http.StatusNotFound)
+			So(send("/bars/..").Code, ShouldEqual, http.StatusNotFound)
+			So(send("/bars/abc/foos").Code, ShouldEqual, http.StatusNotFound)
+			So(send("/bars/abc/relationships/foos").Code, ShouldEqual, http.StatusNotFound)
+			So(send("/bars/abc/testAction").Code, ShouldEqual, http.StatusNotFound)
+			So(called, ShouldEqual, 0)
+		})
+
+		Convey("->InvalidIDStatus()", func() {
+			resource.InvalidIDStatus(http.StatusBadRequest)
+
+			recorder := send("/bars/abc")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, "Invalid id 'abc'")
+			So(called, ShouldEqual, 0)
+
+			So(func() { resource.InvalidIDStatus(http.StatusTeapot) }, ShouldPanic)
+		})
+
+		Convey("should panic on invalid patterns", func() {
+			So(func() { NewResource(testResourceType, WithIDPattern("[")) }, ShouldPanic)
+		})
+
+		Convey("->presets", func() {
+			tests := []struct {
+				pattern string
+				id      string
+				matches bool
+			}{
+				{UUIDv4, "0b5b3e43-6f4e-4c1d-9a3e-2f1c7f1e8d2a", true},
+				{UUIDv4, "0B5B3E43-6F4E-4C1D-9A3E-2F1C7F1E8D2A", true},
+				{UUIDv4, "0b5b3e43-6f4e-1c1d-9a3e-2f1c7f1e8d2a", false},
+				{UUIDv4, "0b5b3e43-6f4e-4c1d-9a3e-2f1c7f1e8d2a/..", false},
+				{Numeric, "42", true},
+				{Numeric, "-42", false},
+				{Numeric, "", false},
+				{Slug, "hello-world-2", true},
+				{Slug, "hello--world", false},
+				{Slug, "Hello", false},
+			}
+
+			for _, test := range tests {
+				preset := NewResource(testResourceType, WithIDPattern(test.pattern))
+				So(preset.idError(test.id) == nil, ShouldEqual, test.matches)
+			}
+		})
+	})
+}
@@ -0,0 +1,504 @@
+package jshapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+const (
+	// DefaultImportBatchSize is the number of objects saved at once by imports when
+	// the resource has not been configured with its own batch size
+	DefaultImportBatchSize = 100
+	// DefaultMaxImportRows is the maximum number of objects accepted by an import
+	// when the resource has not been configured with its own limit
+	DefaultMaxImportRows = 10000
+	// ndjsonContentType is the media type of newline delimited JSON
+	ndjsonContentType = "application/x-ndjson"
+)
+
+/*
+Import registers a `POST /resource/import` handler creating objects from an upload
+too large for PostBulk, either a JSON API document with an array of resource
+objects as "data", or, with the "application/x-ndjson" Content-Type, one resource
+object per line, such as produced by Export. The upload is read as it arrives,
+never in full: its objects are validated one at a time like those of POST
+requests, and the valid ones handed to storage in batches of the size set by
+ImportLimits, each within a transaction when the CRUD storage of the resource
+implements store.Transactional.
+
+The response is newline delimited JSON, a line per batch holding the progress of
+the import as meta, and the errors of the objects that failed, with the line of
+the object in NDJSON uploads, or its index in the "data" array:
+
+	{"meta": {"imported": 100, "failed": 1}, "errors": [{"title": "Conflict", "meta": {"line": 42}, ...}]}
+
+the last line marking the end of the import with `"done": true`. Storage errors of
+Import fail their whole batch, as do those of ImportEach within a transaction.
+*/
+func (res *Resource) Import(storage store.SaveList, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.Import(storage, opts...) })()
+
+	res.handleImport(func(ctx context.Context, r *http.Request, rows []*importRow) []importError {
+		list := make(jsh.List, len(rows))
+		for index, row := range rows {
+			list[index] = row.object
+		}
+
+		storageCtx, finish := startStorage(ctx, r, "save_list")
+		created, err := storage(storageCtx, list)
+		err = finish(err)
+		if HasError(err) {
+			return batchErrors(rows, toErrorList(err))
+		}
+		if len(created) != len(rows) {
+			return batchErrors(rows, jsh.ErrorList{jsh.ISE(fmt.Sprintf(
+				"Expected storage to return %d objects, got %d", len(rows), len(created),
+			))})
+		}
+
+		for index, row := range rows {
+			row.created = created[index]
+		}
+		return nil
+	}, opts)
+}
+
+// ImportEach registers the same `POST /resource/import` handler as Import, for
+// storage that can only save one object at a time
+func (res *Resource) ImportEach(storage store.Save, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.ImportEach(storage, opts...) })()
+
+	res.handleImport(func(ctx context.Context, r *http.Request, rows []*importRow) []importError {
+		errs := []importError{}
+		for _, row := range rows {
+			storageCtx, finish := startStorage(ctx, r, "save")
+			created, err := storage(storageCtx, row.object)
+			err = finish(err)
+			if !HasError(err) && created == nil {
+				err = res.unsavedObject("save")
+			}
+
+			if HasError(err) {
+				for _, rowErr := range toErrorList(err) {
+					errs = append(errs, row.error(rowErr))
+				}
+				continue
+			}
+			row.created = created
+		}
+
+		return errs
+	}, opts)
+}
+
+/*
+ImportLimits sets the number of objects an import saves at once, defaults to
+DefaultImportBatchSize, and the maximum number of objects it accepts, defaults to
+DefaultMaxImportRows. A negative maxRows removes the limit.
+*/
+func (res *Resource) ImportLimits(batchSize int, maxRows int) {
+	res.checkRegistration("import limits")
+
+	res.importBatchSize = batchSize
+	res.maxImportRows = maxRows
+}
+
+// importRow is an object read from an import
+type importRow struct {
+	object  *jsh.Object
+	created *jsh.Object
+	// position is the line of the object in NDJSON uploads, or else its index
+	position int
+	label    string
+}
+
+// error scopes an error to the row, see importError
+func (row *importRow) error(err *jsh.Error) importError {
+	return importError{Error: err, Meta: map[string]int{row.label: row.position}}
+}
+
+// importError is an error of an import, whose meta locates the objects it
+// relates to
+type importError struct {
+	*jsh.Error
+	Meta map[string]int `json:"meta"`
+}
+
+// batchErrors scopes errors to a whole batch of rows
+func batchErrors(rows []*importRow, errs jsh.ErrorList) []importError {
+	first, last := rows[0], rows[len(rows)-1]
+
+	scoped := make([]importError, len(errs))
+	for index, err := range errs {
+		scoped[index] = importError{Error: err, Meta: map[string]int{
+			"first-" + first.label: first.position,
+			"last-" + last.label:   last.position,
+		}}
+	}
+
+	return scoped
+}
+
+// importSaver saves a batch of rows, setting the objects created, and returns the
+// errors of the rows that weren't
+type importSaver func(ctx context.Context, r *http.Request, rows []*importRow) []importError
+
+// handleImport registers the import route of the resource
+func (res *Resource) handleImport(save importSaver, opts []RouteOption) {
+	res.checkRegistration("a route")
+
+	matcher := "/import"
+	res.nameRoute(res.handleRoute(
+		pat.Post(matcher),
+		OpCreate,
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.importHandler(ctx, w, r, save)
+		},
+	), opts)
+
+	res.addRoute(post, matcher)
+}
+
+// importProgress is a line of the response of an import
+type importProgress struct {
+	Meta   map[string]interface{} `json:"meta"`
+	Errors []importError          `json:"errors,omitempty"`
+}
+
+// POST /resources/import
+func (res *Resource) importHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, save importSaver) {
+	reader, readerErr := res.importReader(ctx, r)
+	if readerErr != nil {
+		res.send(ctx, w, r, readerErr)
+		return
+	}
+
+	batchSize, maxRows := res.importLimits()
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	started := false
+	imported, failed, read := 0, 0, 0
+
+	// report writes a progress line, errors being sent as a JSON API error
+	// document if nothing was written yet
+	report := func(errs []importError, done bool) {
+		if !started {
+			if done && len(errs) == 1 && imported == 0 && failed == 0 {
+				res.send(ctx, w, r, errs[0].Error)
+				return
+			}
+
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+
+		progress := importProgress{
+			Meta:   map[string]interface{}{"imported": imported, "failed": failed},
+			Errors: errs,
+		}
+		if done {
+			progress.Meta["done"] = true
+		}
+
+		encoder.Encode(progress)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	rows := []*importRow{}
+	errs := []importError{}
+
+	// flush saves the pending rows and reports the progress of the import
+	flush := func(done bool) {
+		if len(rows) > 0 {
+			saveErrs := res.saveImportBatch(ctx, r, save, rows)
+			errs = append(errs, saveErrs...)
+
+			for _, row := range rows {
+				if row.created == nil {
+					failed++
+					continue
+				}
+
+				imported++
+				res.audit(ctx, OpCreate, "", row.object, row.created)
+			}
+		}
+
+		report(errs, done)
+		rows, errs = []*importRow{}, []importError{}
+	}
+
+	for {
+		row, rowErr, fatal := reader.next()
+		if fatal == io.EOF {
+			break
+		}
+		if fatal != nil {
+			errs = append(errs, importError{Error: fatal.(*jsh.Error)})
+			flush(true)
+			return
+		}
+
+		read++
+		if maxRows > 0 && read > maxRows {
+			errs = append(errs, importError{Error: &jsh.Error{
+				Title:  "Request Entity Too Large",
+				Detail: fmt.Sprintf("Imports must not exceed %d resource objects", maxRows),
+				Status: http.StatusRequestEntityTooLarge,
+			}})
+			flush(true)
+			return
+		}
+
+		if rowErr != nil {
+			failed++
+			errs = append(errs, row.error(rowErr))
+		} else if objectErrs := res.importErrors(ctx, r, row.object); len(objectErrs) > 0 {
+			failed++
+			for _, objectErr := range objectErrs {
+				errs = append(errs, row.error(objectErr))
+			}
+		} else {
+			rows = append(rows, row)
+		}
+
+		if len(rows) >= batchSize || len(errs) >= batchSize {
+			flush(false)
+			if clientGone(ctx) {
+				return
+			}
+		}
+	}
+
+	flush(true)
+}
+
+// saveImportBatch saves a batch of an import, within a transaction when
+// supported, in which case no row of a failing batch is kept
+func (res *Resource) saveImportBatch(ctx context.Context, r *http.Request, save importSaver, rows []*importRow) []importError {
+	if res.tx == nil {
+		return save(ctx, r, rows)
+	}
+
+	txCtx, beginErr := res.tx.Begin(ctx)
+	if HasError(beginErr) {
+		return batchErrors(rows, toErrorList(beginErr))
+	}
+
+	errs := save(txCtx, r, rows)
+	if len(errs) == 0 {
+		commitErr := res.tx.Commit(txCtx)
+		if !HasError(commitErr) {
+			return nil
+		}
+		errs = batchErrors(rows, toErrorList(commitErr))
+	} else {
+		res.tx.Rollback(txCtx)
+	}
+
+	for _, row := range rows {
+		row.created = nil
+	}
+	return errs
+}
+
+// importErrors runs the checks of POST requests against an imported object
+func (res *Resource) importErrors(ctx context.Context, r *http.Request, object *jsh.Object) jsh.ErrorList {
+	errs := jsh.ErrorList{}
+
+	validationErr := object.Validate(r, false)
+	if validationErr != nil {
+		errs = append(errs, validationErr)
+	}
+
+	return append(errs, res.objectErrors(ctx, r.Method, object)...)
+}
+
+// importLimits returns the effective batch size and row limit of imports
+func (res *Resource) importLimits() (int, int) {
+	batchSize, maxRows := res.importBatchSize, res.maxImportRows
+	if batchSize <= 0 {
+		batchSize = DefaultImportBatchSize
+	}
+	if maxRows == 0 {
+		maxRows = DefaultMaxImportRows
+	}
+
+	return batchSize, maxRows
+}
+
+// rowReader reads the objects of an import one at a time, returning io.EOF once
+// done, or an error ending the import
+type rowReader interface {
+	next() (*importRow, *jsh.Error, error)
+}
+
+// importReader returns the reader of an import body, according to its
+// Content-Type
+func (res *Resource) importReader(ctx context.Context, r *http.Request) (rowReader, *jsh.Error) {
+	contentType := r.Header.Get("Content-Type")
+	name, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case name == ndjsonContentType:
+		limit := res.bodyLimit()
+		if limit <= 0 {
+			limit = DefaultMaxBodyBytes
+		}
+
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(make([]byte, 0, 4096), int(limit))
+		return &ndjsonRowReader{scanner: scanner}, nil
+	case isJSONAPIContentType(contentType):
+		mediaTypeErr := MediaTypeError(ctx)
+		if mediaTypeErr != nil {
+			return nil, mediaTypeErr
+		}
+
+		return &documentRowReader{decoder: json.NewDecoder(r.Body)}, nil
+	default:
+		return nil, unsupportedMediaType(fmt.Sprintf(
+			"Expected Content-Type header to be %s or %s, got: %s",
+			jsh.ContentType,
+			ndjsonContentType,
+			contentType,
+		))
+	}
+}
+
+// ndjsonRowReader reads an object per line, skipping blank lines
+type ndjsonRowReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (reader *ndjsonRowReader) next() (*importRow, *jsh.Error, error) {
+	for reader.scanner.Scan() {
+		reader.line++
+
+		content := bytes.TrimSpace(reader.scanner.Bytes())
+		if len(content) == 0 {
+			continue
+		}
+
+		row := &importRow{object: &jsh.Object{}, position: reader.line, label: "line"}
+		if json.Unmarshal(content, row.object) != nil {
+			return row, &jsh.Error{
+				Title:  "Bad Request",
+				Detail: "Line must be a JSON resource object",
+				Status: http.StatusBadRequest,
+			}, nil
+		}
+
+		return row, nil, nil
+	}
+
+	if reader.scanner.Err() != nil {
+		return nil, nil, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: fmt.Sprintf("Unable to read line %d: %s", reader.line+1, reader.scanner.Err().Error()),
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	return nil, nil, io.EOF
+}
+
+// documentRowReader reads the objects of the "data" array of a JSON API document,
+// one at a time
+type documentRowReader struct {
+	decoder *json.Decoder
+	inData  bool
+	index   int
+}
+
+func (reader *documentRowReader) next() (*importRow, *jsh.Error, error) {
+	if !reader.inData {
+		err := reader.openData()
+		if err != nil {
+			return nil, nil, err
+		}
+		reader.inData = true
+	}
+
+	if !reader.decoder.More() {
+		return nil, nil, io.EOF
+	}
+
+	row := &importRow{object: &jsh.Object{}, position: reader.index, label: "index"}
+	reader.index++
+
+	raw := json.RawMessage{}
+	if reader.decoder.Decode(&raw) != nil {
+		return nil, nil, invalidImportDocument()
+	}
+	if json.Unmarshal(raw, row.object) != nil {
+		return row, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: "Member 'data' must contain resource objects",
+			Status: http.StatusBadRequest,
+		}, nil
+	}
+
+	return row, nil, nil
+}
+
+// openData moves the decoder to the first object of the "data" array, skipping
+// the members before it
+func (reader *documentRowReader) openData() *jsh.Error {
+	token, err := reader.decoder.Token()
+	if err != nil || token != json.Delim('{') {
+		return invalidImportDocument()
+	}
+
+	for reader.decoder.More() {
+		token, err = reader.decoder.Token()
+		if err != nil {
+			return invalidImportDocument()
+		}
+
+		if key, _ := token.(string); key != "data" {
+			skipped := json.RawMessage{}
+			if reader.decoder.Decode(&skipped) != nil {
+				return invalidImportDocument()
+			}
+			continue
+		}
+
+		token, err = reader.decoder.Token()
+		if err != nil || token != json.Delim('[') {
+			return &jsh.Error{
+				Title:  "Bad Request",
+				Detail: "Member 'data' of an import must be an array of resource objects",
+				Status: http.StatusBadRequest,
+			}
+		}
+		return nil
+	}
+
+	return invalidImportDocument()
+}
+
+// invalidImportDocument is the error of JSON API imports that can't be read
+func invalidImportDocument() *jsh.Error {
+	return &jsh.Error{
+		Title:  "Bad Request",
+		Detail: "Request body must be a JSON API document with a 'data' member",
+		Status: http.StatusBadRequest,
+	}
+}
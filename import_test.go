@@ -0,0 +1,147 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestImport(t *testing.T) {
+
+	Convey("Import Tests", t, func() {
+
+		storage := &txStorage{MockStorage: MockStorage{ResourceType: testResourceType}}
+		resource := NewCRUDResource(testResourceType, storage)
+		resource.ImportLimits(2, 5)
+
+		batches := []int{}
+		saveList := func(ctx context.Context, list jsh.List) (jsh.List, jsh.ErrorType) {
+			batches = append(batches, len(list))
+			for _, object := range list {
+				if object.ID == "fail" {
+					return nil, jsh.ISE("storage failed")
+				}
+			}
+			return list, nil
+		}
+
+		api := New("")
+
+		type progress struct {
+			Meta   map[string]interface{}
+			Errors []struct {
+				Title string
+				Meta  map[string]int
+			}
+		}
+
+		post := func(contentType string, body string) (*httptest.ResponseRecorder, []progress) {
+			api.Add(resource)
+
+			request := httptest.NewRequest("POST", "/"+testResourceType+"/import", bytes.NewBufferString(body))
+			request.Header.Set("Content-Type", contentType)
+
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, request)
+
+			lines := []progress{}
+			for _, line := range strings.Split(strings.TrimSpace(recorder.Body.String()), "\n") {
+				parsed := progress{}
+				json.Unmarshal([]byte(line), &parsed)
+				lines = append(lines, parsed)
+			}
+			return recorder, lines
+		}
+
+		line := func(id string) string {
+			return `{"type": "bars", "id": "` + id + `", "attributes": {"foo": "bar"}}`
+		}
+
+		Convey("->Import()", func() {
+			resource.Import(saveList)
+
+			Convey("should import NDJSON in batches", func() {
+				recorder, lines := post(ndjsonContentType, strings.Join([]string{
+					line("1"), "", line("2"), `{"type": "foos"}`, line("3"),
+				}, "\n"))
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Header().Get("Content-Type"), ShouldEqual, ndjsonContentType)
+				So(batches, ShouldResemble, []int{2, 1})
+				So(storage.began, ShouldEqual, 2)
+				So(storage.committed, ShouldEqual, 2)
+
+				So(lines, ShouldHaveLength, 2)
+				So(lines[1].Meta, ShouldResemble, map[string]interface{}{"imported": 3.0, "failed": 1.0, "done": true})
+				So(lines[1].Errors, ShouldHaveLength, 1)
+				So(lines[1].Errors[0].Title, ShouldEqual, "Conflict")
+				So(lines[1].Errors[0].Meta, ShouldResemble, map[string]int{"line": 4})
+			})
+
+			Convey("should import the data array of a JSON API document", func() {
+				recorder, lines := post(jsh.ContentType, `{"meta": {"source": "x"}, "data": [`+
+					line("1")+`, {"type": "bars", "attributes": 1}, `+line("2")+`]}`)
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(batches, ShouldResemble, []int{2})
+				last := lines[len(lines)-1]
+				So(last.Meta["imported"], ShouldEqual, 2)
+				So(last.Meta["failed"], ShouldEqual, 1)
+				So(lines[0].Errors[0].Meta, ShouldResemble, map[string]int{"index": 1})
+			})
+
+			Convey("should fail and roll back whole batches", func() {
+				_, lines := post(ndjsonContentType, strings.Join([]string{line("1"), line("fail"), line("3")}, "\n"))
+
+				So(storage.rolledBack, ShouldEqual, 1)
+				last := lines[len(lines)-1]
+				So(last.Meta["imported"], ShouldEqual, 1)
+				So(last.Meta["failed"], ShouldEqual, 2)
+				So(lines[0].Errors[0].Meta, ShouldResemble, map[string]int{"first-line": 1, "last-line": 2})
+			})
+
+			Convey("should cap the number of rows", func() {
+				rows := []string{}
+				for index := 1; index <= 6; index++ {
+					rows = append(rows, line(strconv.Itoa(index)))
+				}
+				_, lines := post(ndjsonContentType, strings.Join(rows, "\n"))
+
+				last := lines[len(lines)-1]
+				So(last.Meta["imported"], ShouldEqual, 5)
+				So(last.Meta["done"], ShouldEqual, true)
+				So(last.Errors[0].Title, ShouldEqual, "Request Entity Too Large")
+			})
+
+			Convey("should send errors as a JSON API document before any progress", func() {
+				recorder, _ := post(jsh.ContentType, `{"data": {"type": "bars"}}`)
+				So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+				So(batches, ShouldBeEmpty)
+
+				recorder, _ = post("text/csv", "id\n1")
+				So(recorder.Code, ShouldEqual, http.StatusUnsupportedMediaType)
+			})
+		})
+
+		Convey("->ImportEach()", func() {
+			resource.ImportEach(storage.Save)
+
+			Convey("should save objects one at a time", func() {
+				recorder, lines := post(ndjsonContentType, `{"type": "bars", "attributes": {"foo": "bar"}}`)
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(lines[0].Meta["imported"], ShouldEqual, 1)
+				So(storage.saved, ShouldEqual, 1)
+			})
+		})
+	})
+}
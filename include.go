@@ -0,0 +1,571 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+// DefaultMaxIncludeDepth is the number of relationships an include path may
+// traverse, such as "comments.author", unless set with MaxIncludeDepth
+const DefaultMaxIncludeDepth = 2
+
+// DefaultMaxIncluded is the number of resource objects a compound document may
+// include, unless set with MaxIncluded
+const DefaultMaxIncluded = 500
+
+/*
+MaxIncludeDepth sets the number of relationships an include path may traverse,
+defaults to DefaultMaxIncludeDepth. Deeper paths are rejected with a 400.
+*/
+func (res *Resource) MaxIncludeDepth(n int) {
+	res.checkRegistration("an include depth limit")
+
+	res.maxIncludeDepth = n
+}
+
+/*
+MaxIncluded sets the number of resource objects a compound document may include,
+nested ones included, defaults to DefaultMaxIncluded. Requests including more are
+rejected with a 400 "include too large" error. A negative value removes the limit.
+*/
+func (res *Resource) MaxIncluded(n int) {
+	res.checkRegistration("an included resources limit")
+
+	res.maxIncluded = n
+}
+
+/*
+AllowInclude restricts the include paths clients may request to paths, such as
+"author", "comments" and "comments.author": requesting any other path is rejected
+with a 400 naming it, before any storage call. Intermediate paths must be allowed
+explicitly to be requested on their own.
+*/
+func (res *Resource) AllowInclude(paths ...string) {
+	res.checkRegistration("allowed include paths")
+
+	res.allowedIncludes = append([]string{}, paths...)
+}
+
+// AllowedIncludes returns the include paths set with AllowInclude, or nil when
+// any path is allowed, for exports such as the values of the include parameter
+func (res *Resource) AllowedIncludes() []string {
+	if res.allowedIncludes == nil {
+		return nil
+	}
+
+	return append([]string{}, res.allowedIncludes...)
+}
+
+// DefaultInclude sets the include paths applied to requests without an include
+// parameter, an empty include parameter includes nothing
+func (res *Resource) DefaultInclude(paths ...string) {
+	res.checkRegistration("default include paths")
+
+	res.defaultIncludes = append([]string{}, paths...)
+}
+
+// includeTree holds the include paths of a request by relationship name, such as
+// {"comments": {"author": {}}} for "comments.author"
+type includeTree map[string]includeTree
+
+/*
+parseInclude parses the include query parameter of a request for the resource. The
+first relationship of each path must be registered on the resource, nested ones
+are checked as their objects are fetched.
+*/
+func (res *Resource) parseInclude(r *http.Request) (includeTree, *jsh.Error) {
+	param := strings.Join(res.defaultIncludes, ",")
+	if values, requested := r.URL.Query()["include"]; requested {
+		param = values[0]
+	}
+	if param == "" {
+		return nil, nil
+	}
+
+	depth := res.maxIncludeDepth
+	if depth == 0 {
+		depth = DefaultMaxIncludeDepth
+	}
+
+	tree := includeTree{}
+	for _, includePath := range strings.Split(param, ",") {
+		includePath = strings.TrimSpace(includePath)
+		if res.allowedIncludes != nil && !containsString(res.allowedIncludes, includePath) {
+			return nil, includeError(fmt.Sprintf(
+				"Include path '%s' is not allowed on '%s'", includePath, res.Type,
+			))
+		}
+
+		names := strings.Split(includePath, ".")
+		if len(names) > depth {
+			return nil, includeError(fmt.Sprintf(
+				"Include path '%s' exceeds the maximum depth of %d", includePath, depth,
+			))
+		}
+		if _, metaOnly := res.metaOnly[names[0]]; metaOnly {
+			return nil, includeError(fmt.Sprintf(
+				"Unable to include '%s', '%s' is served without linkage", includePath, names[0],
+			))
+		}
+		if _, registered := res.includeStorage[names[0]]; !registered {
+			return nil, includeError(fmt.Sprintf(
+				"Unable to include '%s', '%s' is not a relationship of '%s'", includePath, names[0], res.Type,
+			))
+		}
+
+		branch := tree
+		for _, name := range names {
+			if name == "" {
+				return nil, includeError(fmt.Sprintf("Invalid include path '%s'", includePath))
+			}
+			if branch[name] == nil {
+				branch[name] = includeTree{}
+			}
+			branch = branch[name]
+		}
+	}
+
+	return tree, nil
+}
+
+// includeError is the error of an invalid include query parameter
+func includeError(detail string) *jsh.Error {
+	return &jsh.Error{
+		Title:  "Bad Request",
+		Detail: detail,
+		Status: http.StatusBadRequest,
+	}
+}
+
+/*
+include resolves the objects included by tree for primary data, whose objects are
+copies owned by the document. The objects supplied by the storage along with
+primary data are resolved already: they are included when the relationship linkage
+of an included path identifies them, and are otherwise dropped.
+*/
+func (res *Resource) include(ctx context.Context, r *http.Request, tree includeTree, data jsh.List, supplied jsh.List) (jsh.List, jsh.ErrorType) {
+	inclusion := &inclusion{
+		res:        res,
+		ctx:        context.WithValue(ctx, storageOperationKey, OpInclude),
+		r:          r,
+		limit:      res.maxIncluded,
+		seen:       map[string]*jsh.Object{},
+		fetched:    map[string]*jsh.Object{},
+		loadable:   map[string]bool{},
+		pathErrors: map[string]jsh.ErrorType{},
+		workers:    res.includeConcurrency,
+		dropFailed: res.failedIncludes == DropFailedIncludes,
+	}
+	if inclusion.limit == 0 {
+		inclusion.limit = DefaultMaxIncluded
+	}
+	if inclusion.workers == 0 {
+		inclusion.workers = 1
+	}
+	for _, object := range data {
+		inclusion.seen[includeKey(object)] = object
+	}
+	for _, object := range supplied {
+		if object != nil && object.ID != "" {
+			inclusion.fetched[includeKey(object)] = object
+		}
+	}
+
+	err := inclusion.include(res, data, tree)
+	if HasError(err) {
+		return nil, err
+	}
+
+	return inclusion.included, nil
+}
+
+// includeWorkers bounds the concurrent Get calls loading the related objects of
+// resources without GetMany storage
+const includeWorkers = 8
+
+/*
+inclusion collects the objects included in a compound document. Objects are
+deduplicated by type and id across the whole document, primary data included, so
+that cyclic relationships such as "author.posts.author" include each object
+once. The objects of the document are copies, amended with the linkage of the
+included relationships, see link. Resolution follows the finite include tree, at
+most once per node and type, which guarantees termination whatever the shape of
+the relationship graph.
+*/
+type inclusion struct {
+	res   *Resource
+	ctx   context.Context
+	r     *http.Request
+	limit int
+	// seen holds the copies of the primary and included objects by type and id
+	seen     map[string]*jsh.Object
+	included []*jsh.Object
+	// fetched caches the objects loaded from relationship linkage by type and id,
+	// loadable the types they can be loaded for
+	fetched  map[string]*jsh.Object
+	loadable map[string]bool
+	// pathErrors caches the authorization of relationships by owner type and name
+	pathErrors map[string]jsh.ErrorType
+	// workers bounds the concurrent storage calls of a level of the include tree,
+	// dropFailed leaves out the relationships whose storage fails
+	workers    int
+	dropFailed bool
+}
+
+/*
+include adds the objects related to parents, of the type of owner, along the
+include tree, for the relationships allowed by the authorizer. The storage calls
+of each level of the tree are made up front, see prefetch: the related objects of
+parents carrying the linkage of a relationship are batch loaded, the relationship
+storage is called for the others. The relationships are then included in order,
+and the linkage of each parent set to the objects included for it.
+*/
+func (inc *inclusion) include(owner *Resource, parents jsh.List, tree includeTree) jsh.ErrorType {
+	names, err := inc.paths(owner, tree)
+	if HasError(err) {
+		return err
+	}
+
+	fetched, err := inc.prefetch(owner, parents, names)
+	if HasError(err) {
+		return err
+	}
+
+	for _, name := range names {
+		if fetched.failed[name] {
+			continue
+		}
+
+		children, err := inc.includePath(parents, name, fetched.related[name])
+		if HasError(err) {
+			return err
+		}
+
+		if len(tree[name]) == 0 {
+			continue
+		}
+
+		err = inc.includeNested(children, tree[name])
+		if HasError(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// paths returns the relationships of owner named by the include tree, in order,
+// leaving out those denied by the authorizer
+func (inc *inclusion) paths(owner *Resource, tree includeTree) ([]string, jsh.ErrorType) {
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	allowed := names[:0]
+	for _, name := range names {
+		_, registered := owner.includeStorage[name]
+		if !registered {
+			return nil, includeError(fmt.Sprintf(
+				"Unable to include '%s', it is not a relationship of '%s'", name, owner.Type,
+			))
+		}
+
+		isAllowed, err := inc.authorizeInclude(owner, name)
+		if HasError(err) {
+			return nil, err
+		}
+		if isAllowed {
+			allowed = append(allowed, name)
+		}
+	}
+
+	return allowed, nil
+}
+
+// includePath includes the objects of a relationship of parents, from their
+// linkage or from the related objects fetched for them, and returns them
+func (inc *inclusion) includePath(parents jsh.List, name string, fetched map[*jsh.Object]jsh.List) (jsh.List, jsh.ErrorType) {
+	children := jsh.List{}
+	childKeys := map[string]bool{}
+	for _, parent := range parents {
+		related, linked := inc.linkedObjects(parent, name)
+		if !linked {
+			related = fetched[parent]
+		}
+
+		linkage := jsh.ResourceLinkage{}
+		listed := map[string]bool{}
+		for _, object := range related {
+			if object == nil || listed[includeKey(object)] {
+				continue
+			}
+
+			if !childKeys[includeKey(object)] {
+				if !inc.authorized(object) {
+					continue
+				}
+
+				documented, err := inc.add(object)
+				if HasError(err) {
+					return nil, err
+				}
+
+				childKeys[includeKey(object)] = true
+				children = append(children, documented)
+			}
+
+			listed[includeKey(object)] = true
+			linkage = append(linkage, &jsh.ResourceIdentifier{Type: object.Type, ID: object.ID})
+		}
+
+		inc.link(parent, name, linkage)
+	}
+
+	return children, nil
+}
+
+// add includes a copy of an object, unless it is already part of the document,
+// and returns the copy held by the document
+func (inc *inclusion) add(object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	key := includeKey(object)
+	if inc.seen[key] != nil {
+		return inc.seen[key], nil
+	}
+
+	if inc.limit > 0 && len(inc.included) == inc.limit {
+		return nil, &jsh.Error{
+			Title:  "Include Too Large",
+			Detail: fmt.Sprintf("Unable to include more than %d resources, request fewer relationships", inc.limit),
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	documented := documentCopy(object)
+	inc.seen[key] = documented
+	inc.included = append(inc.included, documented)
+	return documented, nil
+}
+
+// authorized reports whether an object may be included, as checked by the object
+// filter and the object authorizer of its resource with OpInclude
+func (inc *inclusion) authorized(object *jsh.Object) bool {
+	if !inc.res.permits(inc.ctx, object) {
+		return false
+	}
+
+	target := inc.res.linkTarget(object.Type)
+	return target == nil || !HasError(target.authorizeObjectFor(inc.ctx, inc.r, OpInclude, object))
+}
+
+// includeKey identifies an object within a compound document
+func includeKey(object *jsh.Object) string {
+	return object.Type + "/" + object.ID
+}
+
+// linkedObjects returns the documented or fetched objects of the linkage of a
+// relationship of parent, in linkage order, or false if parent doesn't carry a
+// loadable one
+func (inc *inclusion) linkedObjects(parent *jsh.Object, name string) (jsh.List, bool) {
+	relationship := parent.Relationships[name]
+	if relationship == nil || relationship.Data == nil {
+		return nil, false
+	}
+
+	related := jsh.List{}
+	for _, identifier := range relationship.Data {
+		key := identifier.Type + "/" + identifier.ID
+
+		object := inc.seen[key]
+		if object == nil {
+			object = inc.fetched[key]
+		}
+		if object == nil && !inc.loadable[identifier.Type] {
+			return nil, false
+		}
+
+		if object != nil {
+			related = append(related, object)
+		}
+	}
+
+	return related, true
+}
+
+// getEach loads objects one id at a time with at most includeWorkers concurrent
+// calls, leaving out those not found
+func (inc *inclusion) getEach(ctx context.Context, storage store.Get, ids []string) (jsh.List, jsh.ErrorType) {
+	objects := make(jsh.List, len(ids))
+	errs := make([]jsh.ErrorType, len(ids))
+
+	workers := make(chan struct{}, includeWorkers)
+	var wait sync.WaitGroup
+	for index, id := range ids {
+		wait.Add(1)
+		workers <- struct{}{}
+
+		go func(index int, id string) {
+			defer func() {
+				<-workers
+				wait.Done()
+			}()
+
+			storageCtx, finish := startStorage(ctx, inc.r, "get")
+			objects[index], errs[index] = storage(storageCtx, id)
+			errs[index] = finish(errs[index])
+		}(index, id)
+	}
+	wait.Wait()
+
+	for _, err := range errs {
+		if HasError(err) && err.StatusCode() != http.StatusNotFound {
+			return nil, err
+		}
+	}
+
+	return objects, nil
+}
+
+// includeNested includes the relationships of children, through the resources
+// serving each of their types
+func (inc *inclusion) includeNested(children jsh.List, tree includeTree) jsh.ErrorType {
+	byType := map[string]jsh.List{}
+	types := []string{}
+	for _, child := range children {
+		if byType[child.Type] == nil {
+			types = append(types, child.Type)
+		}
+		byType[child.Type] = append(byType[child.Type], child)
+	}
+	sort.Strings(types)
+
+	for _, childType := range types {
+		owner := inc.res.linkTarget(childType)
+		if owner == nil {
+			return includeError(fmt.Sprintf(
+				"Unable to include the relationships of '%s', it is not served by the API", childType,
+			))
+		}
+
+		err := inc.include(owner, byType[childType], tree)
+		if HasError(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// toOneInclude adapts the storage of a ToOne relationship to include its object,
+// empty relationships are not found
+func toOneInclude(storage store.Get) store.ToMany {
+	return func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+		object, err := storage(ctx, id)
+		if HasError(err) && err.StatusCode() == http.StatusNotFound {
+			return nil, nil
+		}
+		if HasError(err) || object == nil {
+			return nil, err
+		}
+
+		return jsh.List{object}, nil
+	}
+}
+
+// withoutIncluded adapts Get storage to GetIncluded, supplying no included objects
+func withoutIncluded(storage store.Get) store.GetIncluded {
+	return func(ctx context.Context, id string) (*jsh.Object, jsh.List, jsh.ErrorType) {
+		object, err := storage(ctx, id)
+		return object, nil, err
+	}
+}
+
+// listWithoutIncluded adapts List storage to ListIncluded, see withoutIncluded
+func listWithoutIncluded(storage store.List) store.ListIncluded {
+	return func(ctx context.Context) (jsh.List, jsh.List, jsh.ErrorType) {
+		list, err := storage(ctx)
+		return list, nil, err
+	}
+}
+
+/*
+GetIncluded registers a `GET /resource/:id` handler for the resource, like Get,
+with storage supplying related objects loaded by the same query, such as the
+author of a post fetched with a join:
+
+	posts.GetIncluded(func(ctx context.Context, id string) (*jsh.Object, jsh.List, jsh.ErrorType) {
+		post, author, err := db.PostWithAuthor(ctx, id)
+		...
+		post.Relationships["author"] = &jsh.Relationship{
+			Data: jsh.ResourceLinkage{{Type: "users", ID: author.ID}},
+		}
+		return post, jsh.List{author}, nil
+	})
+
+Supplied objects are matched to the requested include paths through the
+relationship linkage of the objects, which the storage must set: they are included
+as if fetched by the include resolver, which only fetches what's missing, and are
+dropped when no requested path reaches them.
+*/
+func (res *Resource) GetIncluded(storage store.GetIncluded, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.GetIncluded(storage, opts...) })()
+
+	res.checkRegistration("a route")
+
+	res.storage.get = func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		object, _, err := storage(ctx, id)
+		return object, err
+	}
+
+	res.nameRoute(res.handleRoute(
+		pat.Get(res.idRoute()),
+		OpRead,
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.getHandler(ctx, w, r, storage, "")
+		},
+	), opts)
+
+	res.addRoute(get, res.idRoute())
+}
+
+// ListIncluded registers a `GET /resource` handler for the resource, like List,
+// with storage supplying related objects loaded by the same query, see GetIncluded
+func (res *Resource) ListIncluded(storage store.ListIncluded, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.ListIncluded(storage, opts...) })()
+
+	res.storage.list = storage
+
+	res.nameRoute(res.handleRoute(
+		pat.Get(patRoot),
+		OpList,
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.listHandler(ctx, w, r, storage)
+		},
+	), opts)
+
+	res.addRoute(get, patRoot)
+}
+
+/*
+GetMany registers the storage loading several objects of the resource in a single
+call. Compound documents use it to load the objects of the relationship linkage of
+primary data, such as the authors of a page of posts carrying
+`relationships.author.data`, instead of one Get call per object.
+*/
+func (res *Resource) GetMany(storage store.GetMany) {
+	defer res.record(func(clone *Resource) { clone.GetMany(storage) })()
+
+	res.checkRegistration("a batch loader")
+
+	res.storage.getMany = storage
+}
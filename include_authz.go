@@ -0,0 +1,58 @@
+package jshapi
+
+import (
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// DeniedIncludePolicy sets how a resource answers requests including relationships
+// denied by the authorizer
+type DeniedIncludePolicy int
+
+const (
+	// DropDeniedIncludes leaves denied relationships out of compound documents,
+	// the default
+	DropDeniedIncludes DeniedIncludePolicy = iota
+	// RejectDeniedIncludes sends the error returned by the authorizer, typically
+	// a 403
+	RejectDeniedIncludes
+)
+
+/*
+DeniedIncludes sets how the resource answers requests including relationships
+denied by the authorizer. The Authorizer is called with OpInclude once per
+relationship of the requested include paths, with the type of the resource owning
+the relationship and the relationship name as id, before its storage is called:
+
+	if op == jshapi.OpInclude && resourceType == "patients" && id == "medicalRecords" && !isDoctor(r) {
+		return &jsh.Error{Title: "Forbidden", Status: http.StatusForbidden}
+	}
+
+Denied relationships, and the paths nested under them, are dropped by default. The
+objects loaded for allowed relationships are checked one by one by the
+ObjectAuthorizer, with OpInclude, and those denied are always dropped.
+*/
+func (res *Resource) DeniedIncludes(policy DeniedIncludePolicy) {
+	res.checkRegistration("a denied include policy")
+
+	res.deniedIncludes = policy
+}
+
+// authorizeInclude reports whether a relationship of owner may be included,
+// returning the error to send instead of the document when it is rejected
+func (inc *inclusion) authorizeInclude(owner *Resource, name string) (bool, jsh.ErrorType) {
+	key := owner.Type + "." + name
+	err, checked := inc.pathErrors[key]
+	if !checked {
+		err = owner.authorizeRequest(inc.ctx, inc.r, OpInclude, name)
+		inc.pathErrors[key] = err
+	}
+
+	if !HasError(err) {
+		return true, nil
+	}
+	if inc.res.deniedIncludes == RejectDeniedIncludes {
+		return false, err
+	}
+
+	return false, nil
+}
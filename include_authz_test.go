@@ -0,0 +1,131 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// includeAuthorizer denies the medical records relationship, and the records
+// marked private
+type includeAuthorizer struct {
+	checks []string
+}
+
+func (a *includeAuthorizer) Authorize(ctx context.Context, r *http.Request, op Operation, resourceType string, id string) jsh.ErrorType {
+	if op != OpInclude {
+		return nil
+	}
+
+	a.checks = append(a.checks, resourceType+"."+id)
+	if id == "records" {
+		return &jsh.Error{Title: "Forbidden", Detail: "records are confidential", Status: http.StatusForbidden}
+	}
+	return nil
+}
+
+func (a *includeAuthorizer) AuthorizeObject(ctx context.Context, r *http.Request, op Operation, object *jsh.Object) jsh.ErrorType {
+	if op == OpInclude && object.ID == "private" {
+		return &jsh.Error{Title: "Forbidden", Status: http.StatusForbidden}
+	}
+	return nil
+}
+
+func TestIncludeAuthorization(t *testing.T) {
+
+	Convey("Include Authorization Tests", t, func() {
+
+		object := func(id string, resourceType string) *jsh.Object {
+			created, _ := jsh.NewObject(id, resourceType, testObjAttrs)
+			return created
+		}
+
+		recordCalls := 0
+		patients := NewResource("patients")
+		patients.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return object(id, "patients"), nil
+		})
+		patients.ToMany("records", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			recordCalls++
+			return jsh.List{object("1", "records")}, nil
+		})
+		patients.ToMany("doctors", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return jsh.List{object("1", "doctors"), object("private", "doctors")}, nil
+		})
+
+		doctors := NewResource("doctors")
+		doctors.ToMany("records", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			recordCalls++
+			return jsh.List{object("2", "records")}, nil
+		})
+
+		authorizer := &includeAuthorizer{}
+
+		api := New("")
+		api.SetAuthorizer(authorizer)
+
+		get := func(url string) (*httptest.ResponseRecorder, []string) {
+			api.Add(patients)
+			api.Add(doctors)
+
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+
+			doc := struct {
+				Included []struct {
+					Type string
+					ID   string
+				}
+			}{}
+			json.Unmarshal(recorder.Body.Bytes(), &doc)
+
+			keys := []string{}
+			for _, object := range doc.Included {
+				keys = append(keys, object.Type+"/"+object.ID)
+			}
+			return recorder, keys
+		}
+
+		Convey("should drop denied relationships without calling their storage", func() {
+			recorder, included := get("/patients/1?include=records,doctors")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(included, ShouldResemble, []string{"doctors/1"})
+			So(recordCalls, ShouldEqual, 0)
+			So(recorder.Body.String(), ShouldNotContainSubstring, `"records"`)
+		})
+
+		Convey("should check each relationship of a path once", func() {
+			get("/patients/1?include=doctors.records")
+			So(authorizer.checks, ShouldResemble, []string{"patients.doctors", "doctors.records"})
+			So(recordCalls, ShouldEqual, 0)
+		})
+
+		Convey("should drop included objects denied by the object authorizer", func() {
+			_, included := get("/patients/1?include=doctors")
+			So(included, ShouldResemble, []string{"doctors/1"})
+		})
+
+		Convey("->DeniedIncludes(RejectDeniedIncludes)", func() {
+			patients.DeniedIncludes(RejectDeniedIncludes)
+
+			Convey("should send the error of the authorizer", func() {
+				recorder, _ := get("/patients/1?include=doctors,records")
+				So(recorder.Code, ShouldEqual, http.StatusForbidden)
+				So(recorder.Body.String(), ShouldContainSubstring, "records are confidential")
+				So(recordCalls, ShouldEqual, 0)
+			})
+
+			Convey("should still drop denied objects", func() {
+				recorder, included := get("/patients/1?include=doctors")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(included, ShouldResemble, []string{"doctors/1"})
+			})
+		})
+	})
+}
@@ -0,0 +1,303 @@
+package jshapi
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+IncludeConcurrency lets the resource make up to n storage calls at once to resolve
+the include paths of a request. The relationships included at each level of the
+include tree, such as "author" and "comments" for "author,comments.author", are
+then fetched concurrently rather than one after the other, along with the batch
+loads of their linked objects. Objects are included in the same order whatever the
+order the calls complete in. Defaults to 1, panics when n is less than 1.
+
+The first failing call cancels the calls in progress and fails the request with
+its error, see FailedIncludes to leave the failing relationships out instead.
+*/
+func (res *Resource) IncludeConcurrency(n int) {
+	res.checkRegistration("an include concurrency")
+
+	if n < 1 {
+		panic(fmt.Sprintf("jshapi: include concurrency of '%s' must be at least 1, got %d", res.Type, n))
+	}
+
+	res.includeConcurrency = n
+}
+
+// FailedIncludePolicy sets how a resource answers requests including relationships
+// whose storage fails
+type FailedIncludePolicy int
+
+const (
+	// RejectFailedIncludes sends the error of the first failing storage call, the
+	// default
+	RejectFailedIncludes FailedIncludePolicy = iota
+	// DropFailedIncludes leaves the relationships whose storage fails out of
+	// compound documents, sending the rest
+	DropFailedIncludes
+)
+
+/*
+FailedIncludes sets how the resource answers requests including relationships
+whose storage fails. Dropped relationships, and the paths nested under them, are
+left out as if they had not been requested: the document is sent without their
+objects, and their linkage is left as the storage of the primary data set it.
+Failures are still reported to metrics, and requests whose context ends are
+failed whatever the policy.
+*/
+func (res *Resource) FailedIncludes(policy FailedIncludePolicy) {
+	res.checkRegistration("a failed include policy")
+
+	res.failedIncludes = policy
+}
+
+// includeTask is a storage call made to resolve a level of the include tree
+type includeTask struct {
+	// paths are the relationships of the level that need the call
+	paths []string
+	call  func(ctx context.Context) (jsh.List, jsh.ErrorType)
+	// relatedType is the type of the objects batch loaded by the call, parent the
+	// object whose related objects it lists otherwise
+	relatedType string
+	parent      *jsh.Object
+
+	objects jsh.List
+	err     jsh.ErrorType
+}
+
+// prefetched holds the results of the storage calls of a level of the include
+// tree, other than batch loads
+type prefetched struct {
+	// related holds the related objects of the parents whose linkage can't be
+	// loaded, by relationship
+	related map[string]map[*jsh.Object]jsh.List
+	// failed holds the relationships left out as their storage failed
+	failed map[string]bool
+}
+
+/*
+prefetch makes the storage calls needed to include the relationships of parents
+named by names: a batch load per type for the objects identified by their linkage,
+across relationships, and a call of the relationship storage for each parent whose
+linkage can't be loaded. Batch loaded objects are kept with the fetched ones.
+*/
+func (inc *inclusion) prefetch(owner *Resource, parents jsh.List, names []string) (*prefetched, jsh.ErrorType) {
+	tasks := []*includeTask{}
+
+	ids := map[string][]string{}
+	types := []string{}
+	dependents := map[string][]string{}
+	queued := map[string]bool{}
+	for _, name := range names {
+		for _, parent := range parents {
+			relationship := parent.Relationships[name]
+			if relationship == nil {
+				continue
+			}
+
+			for _, identifier := range relationship.Data {
+				key := identifier.Type + "/" + identifier.ID
+				if identifier.ID == "" || inc.seen[key] != nil || inc.fetched[key] != nil {
+					continue
+				}
+
+				paths := dependents[identifier.Type]
+				if len(paths) == 0 || paths[len(paths)-1] != name {
+					dependents[identifier.Type] = append(paths, name)
+				}
+
+				if queued[key] {
+					continue
+				}
+				queued[key] = true
+
+				if ids[identifier.Type] == nil {
+					types = append(types, identifier.Type)
+				}
+				ids[identifier.Type] = append(ids[identifier.Type], identifier.ID)
+			}
+		}
+	}
+
+	loading := map[string]bool{}
+	for _, relatedType := range types {
+		target := inc.res.linkTarget(relatedType)
+		if target == nil || (target.storage.getMany == nil && target.storage.get == nil) {
+			continue
+		}
+		loading[relatedType] = true
+
+		typeIDs := ids[relatedType]
+		tasks = append(tasks, &includeTask{
+			paths:       dependents[relatedType],
+			relatedType: relatedType,
+			call: func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+				if target.storage.getMany == nil {
+					return inc.getEach(ctx, target.storage.get, typeIDs)
+				}
+
+				storageCtx, finish := startStorage(ctx, inc.r, "get_many")
+				objects, err := target.storage.getMany(storageCtx, typeIDs)
+				return objects, finish(err)
+			},
+		})
+	}
+
+	for _, name := range names {
+		storage := owner.includeStorage[name]
+		for _, parent := range parents {
+			if inc.linkageLoaded(parent, name, loading) {
+				continue
+			}
+
+			id := parent.ID
+			tasks = append(tasks, &includeTask{
+				paths:  []string{name},
+				parent: parent,
+				call: func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+					storageCtx, finish := startStorage(ctx, inc.r, "include")
+					related, err := storage(storageCtx, id)
+					return related, finish(err)
+				},
+			})
+		}
+	}
+
+	failed, err := inc.run(tasks)
+	if HasError(err) {
+		return nil, err
+	}
+
+	fetched := &prefetched{related: map[string]map[*jsh.Object]jsh.List{}, failed: failed}
+	for _, task := range tasks {
+		if HasError(task.err) {
+			continue
+		}
+
+		if task.parent != nil {
+			name := task.paths[0]
+			if fetched.related[name] == nil {
+				fetched.related[name] = map[*jsh.Object]jsh.List{}
+			}
+			fetched.related[name][task.parent] = task.objects
+			continue
+		}
+
+		inc.loadable[task.relatedType] = true
+		for _, object := range task.objects {
+			if object != nil && object.Type == task.relatedType {
+				inc.fetched[includeKey(object)] = object
+			}
+		}
+	}
+
+	return fetched, nil
+}
+
+// linkageLoaded reports whether the objects of the linkage of a relationship of
+// parent are documented, fetched, or of a type being loaded, see linkedObjects
+func (inc *inclusion) linkageLoaded(parent *jsh.Object, name string, loading map[string]bool) bool {
+	relationship := parent.Relationships[name]
+	if relationship == nil || relationship.Data == nil {
+		return false
+	}
+
+	for _, identifier := range relationship.Data {
+		key := identifier.Type + "/" + identifier.ID
+		if inc.seen[key] == nil && inc.fetched[key] == nil &&
+			!inc.loadable[identifier.Type] && !loading[identifier.Type] {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+run makes the calls of tasks, with at most workers of them at a time. The first
+failing call cancels the calls in progress and its error is returned as soon as it
+fails, unless failed includes are dropped: the paths of the failed calls are then
+returned once every call completed. Requests whose context ends fail right away.
+*/
+func (inc *inclusion) run(tasks []*includeTask) (map[string]bool, jsh.ErrorType) {
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(inc.ctx)
+	defer cancel()
+
+	var mutex sync.Mutex
+	var first jsh.ErrorType
+
+	workers := make(chan struct{}, inc.workers)
+	var wait sync.WaitGroup
+
+launch:
+	for _, task := range tasks {
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			break launch
+		}
+
+		wait.Add(1)
+		go func(task *includeTask) {
+			defer func() {
+				<-workers
+				wait.Done()
+			}()
+
+			objects, err := task.call(ctx)
+			task.objects, task.err = objects, err
+			if !HasError(err) || inc.dropFailed {
+				return
+			}
+
+			mutex.Lock()
+			if first == nil {
+				first = err
+				cancel()
+			}
+			mutex.Unlock()
+		}(task)
+	}
+
+	completed := make(chan struct{})
+	go func() {
+		wait.Wait()
+		close(completed)
+	}()
+
+	select {
+	case <-completed:
+	case <-ctx.Done():
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if first != nil {
+		return nil, first
+	}
+	if inc.ctx.Err() != nil {
+		return nil, jsh.ISE(fmt.Sprintf("Stopped including related resources: %s", inc.ctx.Err()))
+	}
+
+	failed := map[string]bool{}
+	for _, task := range tasks {
+		if HasError(task.err) {
+			for _, path := range task.paths {
+				failed[path] = true
+			}
+		}
+	}
+
+	return failed, nil
+}
@@ -0,0 +1,194 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIncludeConcurrency(t *testing.T) {
+
+	Convey("Include Concurrency Tests", t, func() {
+
+		var mutex sync.Mutex
+		inFlight, maxInFlight := 0, 0
+		delays := map[string]time.Duration{}
+		failures := map[string]jsh.ErrorType{}
+		canceled := make(chan string, 10)
+
+		// related lists count objects of a type after its delay, or until ctx ends
+		related := func(relatedType string, count int) func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+				mutex.Lock()
+				inFlight++
+				if inFlight > maxInFlight {
+					maxInFlight = inFlight
+				}
+				delay, failure := delays[relatedType], failures[relatedType]
+				mutex.Unlock()
+
+				defer func() {
+					mutex.Lock()
+					inFlight--
+					mutex.Unlock()
+				}()
+
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					canceled <- relatedType
+					return nil, jsh.ISE(ctx.Err().Error())
+				}
+				if failure != nil {
+					return nil, failure
+				}
+
+				list := jsh.List{}
+				for index := 0; index < count; index++ {
+					object, _ := jsh.NewObject(string(rune('a'+index)), relatedType, testObjAttrs)
+					list = append(list, object)
+				}
+				return list, nil
+			}
+		}
+
+		posts := NewResource("posts")
+		posts.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return jsh.NewObject(id, "posts", testObjAttrs)
+		})
+		posts.ToMany("comments", related("comments", 2))
+		posts.ToMany("likes", related("likes", 1))
+		posts.ToMany("tags", related("tags", 1))
+
+		// include requests the relationships of a post, returning the response and
+		// the included objects
+		include := func(ctx context.Context) (*httptest.ResponseRecorder, []string) {
+			api := New("")
+			api.Add(posts)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest("GET", "/posts/1?include=tags,comments,likes", nil)
+			api.ServeHTTP(recorder, request.WithContext(ctx))
+
+			doc := struct {
+				Included []struct {
+					Type string
+					ID   string
+				}
+			}{}
+			json.Unmarshal(recorder.Body.Bytes(), &doc)
+
+			keys := []string{}
+			for _, object := range doc.Included {
+				keys = append(keys, object.Type+"/"+object.ID)
+			}
+			return recorder, keys
+		}
+
+		expected := []string{"comments/a", "comments/b", "likes/a", "tags/a"}
+		for _, relatedType := range []string{"comments", "likes", "tags"} {
+			delays[relatedType] = 20 * time.Millisecond
+		}
+
+		Convey("should resolve paths one at a time by default", func() {
+			recorder, included := include(context.Background())
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(included, ShouldResemble, expected)
+			So(maxInFlight, ShouldEqual, 1)
+		})
+
+		Convey("should resolve independent paths concurrently", func() {
+			posts.IncludeConcurrency(3)
+
+			start := time.Now()
+			recorder, included := include(context.Background())
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(included, ShouldResemble, expected)
+			So(maxInFlight, ShouldEqual, 3)
+			So(time.Since(start), ShouldBeLessThan, 60*time.Millisecond)
+		})
+
+		Convey("should bound the concurrent calls", func() {
+			posts.IncludeConcurrency(2)
+
+			include(context.Background())
+			So(maxInFlight, ShouldEqual, 2)
+		})
+
+		Convey("should include in order whatever the completion order", func() {
+			posts.IncludeConcurrency(3)
+			delays["comments"] = 40 * time.Millisecond
+			delays["tags"] = 0
+
+			_, included := include(context.Background())
+			So(included, ShouldResemble, expected)
+		})
+
+		Convey("should fail with the error of a failing path, canceling the others", func() {
+			posts.IncludeConcurrency(3)
+			delays["comments"] = time.Second
+			delays["likes"] = 0
+			delays["tags"] = 0
+			failures["tags"] = &jsh.Error{Title: "Bad Gateway", Status: http.StatusBadGateway}
+
+			start := time.Now()
+			recorder, _ := include(context.Background())
+
+			So(recorder.Code, ShouldEqual, http.StatusBadGateway)
+			So(time.Since(start), ShouldBeLessThan, 500*time.Millisecond)
+			So(<-canceled, ShouldEqual, "comments")
+		})
+
+		Convey("should stop when the request context ends", func() {
+			posts.IncludeConcurrency(3)
+			delays["comments"] = time.Second
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			recorder, _ := include(ctx)
+
+			So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+			So(time.Since(start), ShouldBeLessThan, 500*time.Millisecond)
+		})
+
+		Convey("->FailedIncludes()", func() {
+			posts.IncludeConcurrency(3)
+			posts.FailedIncludes(DropFailedIncludes)
+			failures["likes"] = jsh.ISE("likes are down")
+
+			Convey("should leave failing paths out", func() {
+				recorder, included := include(context.Background())
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(included, ShouldResemble, []string{"comments/a", "comments/b", "tags/a"})
+			})
+
+			Convey("should still fail when the request context ends", func() {
+				delays["comments"] = time.Second
+
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				defer cancel()
+
+				recorder, _ := include(ctx)
+				So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+
+		Convey("should panic on a concurrency below 1", func() {
+			So(func() { posts.IncludeConcurrency(0) }, ShouldPanicWith,
+				"jshapi: include concurrency of 'posts' must be at least 1, got 0")
+		})
+	})
+}
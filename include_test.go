@@ -0,0 +1,490 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInclude(t *testing.T) {
+
+	Convey("Include Tests", t, func() {
+
+		object := func(id string, resourceType string) *jsh.Object {
+			created, _ := jsh.NewObject(id, resourceType, testObjAttrs)
+			return created
+		}
+
+		posts := NewResource("posts")
+		posts.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return object(id, "posts"), nil
+		})
+		posts.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+			return jsh.List{object("1", "posts"), object("2", "posts")}, nil
+		})
+		posts.ToOne("author", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			if id == "2" {
+				return nil, jsh.NotFound("users", "")
+			}
+			return object("1", "users"), nil
+		})
+		posts.ToMany("comments", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return jsh.List{object(id+"1", "comments"), object(id+"2", "comments")}, nil
+		})
+
+		comments := NewResource("comments")
+		comments.ToOne("author", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return object(id[len(id)-1:], "users"), nil
+		})
+
+		api := New("")
+		api.Add(posts)
+		api.Add(comments)
+
+		type document struct {
+			Data     json.RawMessage
+			Included []struct {
+				Type string
+				ID   string
+			}
+		}
+
+		get := func(url string) (*httptest.ResponseRecorder, document) {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+
+			doc := document{}
+			json.Unmarshal(recorder.Body.Bytes(), &doc)
+			return recorder, doc
+		}
+
+		included := func(doc document) []string {
+			keys := []string{}
+			for _, object := range doc.Included {
+				keys = append(keys, object.Type+"/"+object.ID)
+			}
+			return keys
+		}
+
+		Convey("should not include anything by default", func() {
+			recorder, doc := get("/posts/1")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(doc.Included, ShouldBeEmpty)
+		})
+
+		Convey("should include related objects", func() {
+			recorder, doc := get("/posts/1?include=author,comments")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(included(doc), ShouldResemble, []string{"users/1", "comments/11", "comments/12"})
+		})
+
+		Convey("should include nested relationships once", func() {
+			recorder, doc := get("/posts/1?include=author,comments.author")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(included(doc), ShouldResemble, []string{"users/1", "comments/11", "comments/12", "users/2"})
+		})
+
+		Convey("should include for lists, skipping empty relationships", func() {
+			recorder, doc := get("/posts?include=author")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(included(doc), ShouldResemble, []string{"users/1"})
+		})
+
+		Convey("should reject unknown paths", func() {
+			recorder, _ := get("/posts/1?include=tags")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+
+			recorder, _ = get("/posts/1?include=comments.tags")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+
+			recorder, _ = get("/posts/1?include=comments..author")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("->MaxIncludeDepth()", func() {
+			recorder, _ := get("/posts/1?include=comments.author.posts")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+
+			posts.MaxIncludeDepth(1)
+			recorder, _ = get("/posts/1?include=comments.author")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("->MaxIncluded()", func() {
+			posts.MaxIncluded(2)
+			recorder, _ := get("/posts/1?include=author,comments")
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+
+			posts.MaxIncluded(-1)
+			recorder, _ = get("/posts/1?include=author,comments")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
+
+func TestBatchedInclude(t *testing.T) {
+
+	Convey("Batched Include Tests", t, func() {
+
+		page := jsh.List{}
+		for index := 0; index < 50; index++ {
+			post, _ := jsh.NewObject(fmt.Sprintf("%d", index), "posts", testObjAttrs)
+			post.Relationships["author"] = &jsh.Relationship{Data: jsh.ResourceLinkage{
+				{Type: "users", ID: fmt.Sprintf("%d", 2-index%3)},
+			}}
+			page = append(page, post)
+		}
+
+		relationshipCalls := 0
+		posts := NewResource("posts")
+		posts.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+			return page, nil
+		})
+		posts.ToOne("author", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			relationshipCalls++
+			return nil, jsh.NotFound("users", "")
+		})
+
+		var getCalls int32
+		users := NewResource("users")
+		users.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			atomic.AddInt32(&getCalls, 1)
+			return jsh.NewObject(id, "users", testObjAttrs)
+		})
+
+		api := New("")
+		api.Add(posts)
+		api.Add(users)
+
+		included := func() []string {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", "/posts?include=author", nil))
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			doc := struct {
+				Included []struct {
+					Type string
+					ID   string
+				}
+			}{}
+			So(json.Unmarshal(recorder.Body.Bytes(), &doc), ShouldBeNil)
+
+			keys := []string{}
+			for _, object := range doc.Included {
+				keys = append(keys, object.Type+"/"+object.ID)
+			}
+			return keys
+		}
+
+		Convey("should batch load linked objects with GetMany", func() {
+			batches := [][]string{}
+			users.GetMany(func(ctx context.Context, ids []string) (jsh.List, jsh.ErrorType) {
+				batches = append(batches, ids)
+
+				list := jsh.List{}
+				for index := len(ids) - 1; index >= 0; index-- {
+					user, _ := jsh.NewObject(ids[index], "users", testObjAttrs)
+					list = append(list, user)
+				}
+				return list, nil
+			})
+
+			So(included(), ShouldResemble, []string{"users/2", "users/1", "users/0"})
+			So(batches, ShouldResemble, [][]string{{"2", "1", "0"}})
+			So(getCalls, ShouldEqual, 0)
+			So(relationshipCalls, ShouldEqual, 0)
+		})
+
+		Convey("should fall back to a Get per distinct id", func() {
+			So(included(), ShouldResemble, []string{"users/2", "users/1", "users/0"})
+			So(atomic.LoadInt32(&getCalls), ShouldEqual, 3)
+			So(relationshipCalls, ShouldEqual, 0)
+		})
+
+		Convey("should call the relationship storage without linkage", func() {
+			page[0].Relationships = map[string]*jsh.Relationship{}
+
+			included()
+			So(relationshipCalls, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestSuppliedInclude(t *testing.T) {
+
+	Convey("Supplied Include Tests", t, func() {
+
+		linked := func(id string, author string) *jsh.Object {
+			post, _ := jsh.NewObject(id, "posts", testObjAttrs)
+			post.Relationships["author"] = &jsh.Relationship{Data: jsh.ResourceLinkage{
+				{Type: "users", ID: author},
+			}}
+			return post
+		}
+		user := func(id string) *jsh.Object {
+			created, _ := jsh.NewObject(id, "users", map[string]string{"source": "storage"})
+			return created
+		}
+
+		posts := NewResource("posts")
+		posts.GetIncluded(func(ctx context.Context, id string) (*jsh.Object, jsh.List, jsh.ErrorType) {
+			tag, _ := jsh.NewObject("1", "tags", testObjAttrs)
+			return linked(id, "1"), jsh.List{user("1"), tag, nil}, nil
+		})
+		posts.ListIncluded(func(ctx context.Context) (jsh.List, jsh.List, jsh.ErrorType) {
+			return jsh.List{linked("1", "1"), linked("2", "2"), linked("3", "1")}, jsh.List{user("1")}, nil
+		})
+		posts.ToOne("author", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return nil, jsh.ISE("relationship storage called")
+		})
+
+		gets := []string{}
+		users := NewResource("users")
+		users.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			gets = append(gets, id)
+			return jsh.NewObject(id, "users", map[string]string{"source": "resolver"})
+		})
+
+		api := New("")
+		api.Add(posts)
+		api.Add(users)
+
+		type resource struct {
+			Type       string
+			ID         string
+			Attributes map[string]string
+		}
+
+		get := func(url string) (*httptest.ResponseRecorder, []resource) {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+
+			doc := struct{ Included []resource }{}
+			json.Unmarshal(recorder.Body.Bytes(), &doc)
+			return recorder, doc.Included
+		}
+
+		Convey("should include supplied objects without fetching them", func() {
+			recorder, included := get("/posts/1?include=author")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(included, ShouldResemble, []resource{
+				{Type: "users", ID: "1", Attributes: map[string]string{"source": "storage"}},
+			})
+			So(gets, ShouldBeEmpty)
+		})
+
+		Convey("should only fetch missing objects, once each", func() {
+			recorder, included := get("/posts?include=author")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(included, ShouldResemble, []resource{
+				{Type: "users", ID: "1", Attributes: map[string]string{"source": "storage"}},
+				{Type: "users", ID: "2", Attributes: map[string]string{"source": "resolver"}},
+			})
+			So(gets, ShouldResemble, []string{"2"})
+		})
+
+		Convey("should drop supplied objects of paths not requested", func() {
+			recorder, included := get("/posts/1")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(included, ShouldBeEmpty)
+			So(recorder.Body.String(), ShouldNotContainSubstring, "tags")
+		})
+
+		Convey("should serve single objects for GetIncluded", func() {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", "/posts/7", nil))
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"id": "7"`)
+		})
+	})
+}
+
+func TestIncludePolicy(t *testing.T) {
+
+	Convey("Include Policy Tests", t, func() {
+
+		relationshipCalls := 0
+		related := func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			relationshipCalls++
+			tag, _ := jsh.NewObject(id, "tags", testObjAttrs)
+			return jsh.List{tag}, nil
+		}
+
+		getCalls := 0
+		posts := NewResource("posts")
+		posts.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			getCalls++
+			return jsh.NewObject(id, "posts", testObjAttrs)
+		})
+		posts.ToManyExact("tags", related)
+		posts.ToManyExact("comments", related)
+
+		api := New("")
+		api.Add(posts)
+
+		get := func(url string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+			return recorder
+		}
+
+		Convey("->AllowInclude()", func() {
+			posts.AllowInclude("tags")
+			So(posts.AllowedIncludes(), ShouldResemble, []string{"tags"})
+
+			Convey("should serve allowed paths", func() {
+				recorder := get("/posts/1?include=tags")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"included"`)
+			})
+
+			Convey("should reject other paths before storage", func() {
+				recorder := get("/posts/1?include=tags,comments")
+				So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+				So(recorder.Body.String(), ShouldContainSubstring, "Include path 'comments' is not allowed on 'posts'")
+				So(getCalls, ShouldEqual, 0)
+				So(relationshipCalls, ShouldEqual, 0)
+			})
+		})
+
+		Convey("->DefaultInclude()", func() {
+			posts.DefaultInclude("comments")
+
+			Convey("should apply without an include parameter", func() {
+				recorder := get("/posts/1")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"included"`)
+				So(relationshipCalls, ShouldEqual, 1)
+			})
+
+			Convey("should not apply to an empty include parameter", func() {
+				recorder := get("/posts/1?include=")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldNotContainSubstring, `"included"`)
+			})
+
+			Convey("should be checked against allowed paths", func() {
+				posts.AllowInclude("tags")
+				So(get("/posts/1").Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("should allow any path by default", func() {
+			So(posts.AllowedIncludes(), ShouldBeNil)
+			So(get("/posts/1?include=comments").Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
+
+func TestCyclicInclude(t *testing.T) {
+
+	Convey("Cyclic Include Tests", t, func() {
+
+		// random graphs of nodes linked to each other, cycles and self links
+		// included
+		const nodeCount = 20
+		const maxDepth = 6
+
+		for seed := int64(1); seed <= 30; seed++ {
+			random := rand.New(rand.NewSource(seed))
+
+			edges := map[string][]string{}
+			for node := 0; node < nodeCount; node++ {
+				for edge := random.Intn(4); edge > 0; edge-- {
+					edges[fmt.Sprint(node)] = append(edges[fmt.Sprint(node)], fmt.Sprint(random.Intn(nodeCount)))
+				}
+			}
+
+			nodes := NewResource("nodes")
+			nodes.MaxIncludeDepth(maxDepth)
+			nodes.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+				return jsh.NewObject(id, "nodes", testObjAttrs)
+			})
+			nodes.ToManyExact("next", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+				list := jsh.List{}
+				for _, target := range edges[id] {
+					node, _ := jsh.NewObject(target, "nodes", testObjAttrs)
+					list = append(list, node)
+				}
+				return list, nil
+			})
+
+			api := New("")
+			api.Add(nodes)
+
+			depth := 1 + random.Intn(maxDepth)
+			primary := fmt.Sprint(random.Intn(nodeCount))
+
+			// the nodes reachable from the primary one in 1 to depth steps
+			reachable := map[string]bool{}
+			frontier := map[string]bool{primary: true}
+			for step := 0; step < depth; step++ {
+				next := map[string]bool{}
+				for node := range frontier {
+					for _, target := range edges[node] {
+						next[target] = true
+						if target != primary {
+							reachable[target] = true
+						}
+					}
+				}
+				frontier = next
+			}
+
+			path := strings.TrimSuffix(strings.Repeat("next.", depth), ".")
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", "/nodes/"+primary+"?include="+path, nil))
+
+			doc := struct {
+				Included []struct {
+					Type string
+					ID   string
+				}
+			}{}
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(json.Unmarshal(recorder.Body.Bytes(), &doc), ShouldBeNil)
+
+			included := map[string]bool{}
+			for _, object := range doc.Included {
+				So(included[object.ID], ShouldBeFalse)
+				So(object.ID, ShouldNotEqual, primary)
+				included[object.ID] = true
+			}
+			So(included, ShouldResemble, reachable)
+		}
+	})
+
+	Convey("should reject includes too large", t, func() {
+		nodes := NewResource("nodes")
+		nodes.MaxIncluded(5)
+		nodes.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return jsh.NewObject(id, "nodes", testObjAttrs)
+		})
+		nodes.ToManyExact("next", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			list := jsh.List{}
+			for index := 0; index < 10; index++ {
+				node, _ := jsh.NewObject(fmt.Sprintf("%s-%d", id, index), "nodes", testObjAttrs)
+				list = append(list, node)
+			}
+			return list, nil
+		})
+
+		api := New("")
+		api.Add(nodes)
+
+		recorder := httptest.NewRecorder()
+		api.ServeHTTP(recorder, httptest.NewRequest("GET", "/nodes/1?include=next", nil))
+		So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+		So(recorder.Body.String(), ShouldContainSubstring, "Include Too Large")
+	})
+}
@@ -0,0 +1,42 @@
+/*
+Package nilness holds the nil checks of jsh errors shared by jshapi and its store
+wrappers, which can't import each other.
+*/
+package nilness
+
+import (
+	"reflect"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// HasError reports whether err holds an error, see jshapi.HasError
+func HasError(err jsh.ErrorType) bool {
+	// the jsh error types are checked without reflection as they are met on
+	// every request
+	switch typed := err.(type) {
+	case nil:
+		return false
+	case *jsh.Error:
+		return typed != nil
+	case jsh.ErrorList:
+		return typed != nil
+	}
+
+	return !IsNil(err)
+}
+
+// IsNil reports whether value is nil or holds a nil value of a nil-able kind
+func IsNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface, reflect.UnsafePointer:
+		return reflected.IsNil()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,252 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+)
+
+// SchemaVersion is the version of the document built by API.Schema, bumped on
+// incompatible changes to its structure
+const SchemaVersion = "1"
+
+/*
+APISchema describes the resources of an API for client code generation, see
+API.Schema. Resources are sorted by type, and their members by name.
+*/
+type APISchema struct {
+	Version string `json:"version"`
+	// Routes are those registered by the API itself, such as AtomicOperations
+	Routes    []RouteSchema    `json:"routes,omitempty"`
+	Resources []ResourceSchema `json:"resources"`
+}
+
+// ResourceSchema describes a resource type, attributes are only described for
+// resources declaring them, see Resource.DescribeWith and Resource.KnownAttributes
+type ResourceSchema struct {
+	Type          string               `json:"type"`
+	Routes        []RouteSchema        `json:"routes"`
+	Attributes    []AttributeSchema    `json:"attributes,omitempty"`
+	Relationships []RelationshipSchema `json:"relationships"`
+	// Includes are the allowed include paths, omitted when any path is allowed
+	Includes []string `json:"includes,omitempty"`
+}
+
+// RouteSchema describes a route of a resource
+type RouteSchema struct {
+	Method    string    `json:"method"`
+	Pattern   string    `json:"pattern"`
+	Operation Operation `json:"operation,omitempty"`
+	Name      string    `json:"name,omitempty"`
+}
+
+// AttributeSchema describes an attribute, Type being the JSON type of its values
+// when known: "string", "number", "boolean", "array" or "object"
+type AttributeSchema struct {
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	ReadOnly bool   `json:"read-only,omitempty"`
+}
+
+// Relationship cardinalities of RelationshipSchema
+const (
+	CardinalityToOne  = "to-one"
+	CardinalityToMany = "to-many"
+)
+
+/*
+RelationshipSchema describes a relationship, RelatedType being the type of the
+related objects when known. Relationships are mutable when clients may write them
+in the objects they create or update.
+*/
+type RelationshipSchema struct {
+	Name        string `json:"name"`
+	RelatedType string `json:"related-type,omitempty"`
+	Cardinality string `json:"cardinality"`
+	Mutable     bool   `json:"mutable"`
+}
+
+// SchemaDescriber describes the attributes and relationships of a resource, such
+// as the mapper of its storage
+type SchemaDescriber interface {
+	DescribeSchema() ([]AttributeSchema, []RelationshipSchema)
+}
+
+/*
+DescribeWith describes the attributes and relationships of the resource with the
+descriptions of describer in its schema, see API.Schema:
+
+	users.DescribeWith(mapper.New("users", User{}))
+
+Relationships described but not registered on the resource are described as well.
+*/
+func (res *Resource) DescribeWith(describer SchemaDescriber) {
+	res.checkRegistration("a schema describer")
+
+	res.describer = describer
+}
+
+/*
+Schema builds the schema document of the API: every resource added, with its
+routes, attributes and relationships, and allowed include paths. The document is
+plain indented JSON in a stable order, so that it can be committed and compared
+across versions.
+*/
+func (a *API) Schema() ([]byte, error) {
+	schema := APISchema{Version: SchemaVersion, Resources: []ResourceSchema{}}
+
+	for _, route := range a.Routes() {
+		if route.ResourceType == "" {
+			schema.Routes = append(schema.Routes, RouteSchema{Method: route.Method, Pattern: route.Pattern, Name: route.Name})
+		}
+	}
+
+	for _, resource := range a.Resources {
+		schema.Resources = append(schema.Resources, resource.describe())
+	}
+	sort.Slice(schema.Resources, func(i, j int) bool {
+		return schema.Resources[i].Type < schema.Resources[j].Type
+	})
+
+	return json.MarshalIndent(schema, "", " ")
+}
+
+/*
+SchemaEndpoint registers a `GET /(prefix/)<route>` endpoint answering the schema
+document of the API, see Schema:
+
+	api.SchemaEndpoint("_schema")
+*/
+func (a *API) SchemaEndpoint(route string) {
+	a.checkRegistration("a schema endpoint")
+
+	matcher := path.Join(a.prefix, route)
+	a.Mux.HandleFuncC(pat.Get(matcher), a.schemaHandler)
+	a.routes = append(a.routes, Route{Method: get, Pattern: matcher})
+}
+
+// GET /(prefix/)_schema
+func (a *API) schemaHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	content, err := a.Schema()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Write(content)
+}
+
+// describe describes the resource in the schema document
+func (res *Resource) describe() ResourceSchema {
+	schema := ResourceSchema{
+		Type:          res.Type,
+		Routes:        []RouteSchema{},
+		Relationships: []RelationshipSchema{},
+		Includes:      res.AllowedIncludes(),
+	}
+
+	writable := false
+	for _, route := range res.RegisteredRoutes() {
+		schema.Routes = append(schema.Routes, RouteSchema{
+			Method:    route.Method,
+			Pattern:   route.Pattern,
+			Operation: route.Operation,
+			Name:      route.Name,
+		})
+		writable = writable || route.Operation == OpCreate || route.Operation == OpUpdate
+	}
+
+	var described []RelationshipSchema
+	if res.describer != nil {
+		schema.Attributes, described = res.describer.DescribeSchema()
+	}
+	schema.Attributes = res.knownAttributeSchemas(schema.Attributes)
+	schema.Relationships = res.relationshipSchemas(described, writable)
+
+	return schema
+}
+
+/*
+knownAttributeSchemas adds the known attributes that aren't described to the
+described ones, sorted by name. Attributes maintained by the resource are added to
+resources declaring their attributes, timestamps and computed attributes as
+read-only unless clients may write them.
+*/
+func (res *Resource) knownAttributeSchemas(described []AttributeSchema) []AttributeSchema {
+	if len(described) == 0 && len(res.knownAttributes) == 0 {
+		return nil
+	}
+
+	attributes := append([]AttributeSchema{}, described...)
+	seen := map[string]bool{}
+	for _, attribute := range attributes {
+		seen[attribute.Name] = true
+	}
+	add := func(name string, readOnly bool) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			attributes = append(attributes, AttributeSchema{Name: name, ReadOnly: readOnly})
+		}
+	}
+
+	for name := range res.knownAttributes {
+		add(name, false)
+	}
+	for _, attribute := range res.defaults {
+		add(attribute.name, false)
+	}
+	for name := range res.computed {
+		add(name, true)
+	}
+	if res.timestamps != nil {
+		add(res.timestamps.created, !res.timestamps.allowWrites)
+		add(res.timestamps.updated, !res.timestamps.allowWrites)
+	}
+
+	sort.Slice(attributes, func(i, j int) bool {
+		return attributes[i].Name < attributes[j].Name
+	})
+	return attributes
+}
+
+// relationshipSchemas merges the registered relationships with the described
+// ones, sorted by name, relationships being mutable on writable resources unless
+// described otherwise
+func (res *Resource) relationshipSchemas(described []RelationshipSchema, writable bool) []RelationshipSchema {
+	relationships := []RelationshipSchema{}
+
+	byName := map[string]RelationshipSchema{}
+	for _, relationship := range described {
+		relationship.Mutable = relationship.Mutable && writable
+		byName[relationship.Name] = relationship
+	}
+
+	for name, kind := range res.Relationships {
+		relationship, isDescribed := byName[name]
+		if !isDescribed {
+			relationship = RelationshipSchema{Name: name, Mutable: writable}
+		}
+
+		relationship.Cardinality = CardinalityToOne
+		if kind == ToMany {
+			relationship.Cardinality = CardinalityToMany
+		}
+		byName[name] = relationship
+	}
+
+	for _, relationship := range byName {
+		relationships = append(relationships, relationship)
+	}
+	sort.Slice(relationships, func(i, j int) bool {
+		return relationships[i].Name < relationships[j].Name
+	})
+
+	return relationships
+}
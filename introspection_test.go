@@ -0,0 +1,138 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// describer is a static SchemaDescriber
+type describer struct {
+	attributes    []AttributeSchema
+	relationships []RelationshipSchema
+}
+
+func (d describer) DescribeSchema() ([]AttributeSchema, []RelationshipSchema) {
+	return d.attributes, d.relationships
+}
+
+func TestSchema(t *testing.T) {
+
+	Convey("Schema Tests", t, func() {
+
+		get := func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return nil, nil
+		}
+		save := func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+			return object, nil
+		}
+		toMany := func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return nil, nil
+		}
+
+		posts := NewResource("posts")
+		posts.Get(get)
+		posts.Patch(save)
+		posts.ToOne("author", get)
+		posts.ToMany("comment", toMany)
+		posts.AllowInclude("author")
+		posts.KnownAttributes("title", "body")
+		posts.ComputedAttribute("summary", func(ctx context.Context, object *jsh.Object) (interface{}, error) {
+			return "", nil
+		})
+		posts.DescribeWith(describer{
+			attributes: []AttributeSchema{{Name: "title", Type: "string"}, {Name: "views", Type: "number", ReadOnly: true}},
+			relationships: []RelationshipSchema{
+				{Name: "author", RelatedType: "people", Cardinality: CardinalityToOne},
+				{Name: "tags", RelatedType: "tags", Cardinality: CardinalityToMany, Mutable: true},
+			},
+		})
+
+		people := NewResource("people")
+		people.Get(get)
+		people.ToOne("manager", get)
+
+		api := New("v1")
+		api.Add(posts)
+		api.Add(people)
+
+		schema := func() APISchema {
+			content, err := api.Schema()
+			So(err, ShouldBeNil)
+
+			decoded := APISchema{}
+			So(json.Unmarshal(content, &decoded), ShouldBeNil)
+			return decoded
+		}
+
+		Convey("->Schema()", func() {
+
+			Convey("should describe resources sorted by type", func() {
+				decoded := schema()
+
+				So(decoded.Version, ShouldEqual, SchemaVersion)
+				So(len(decoded.Resources), ShouldEqual, 2)
+				So(decoded.Resources[0].Type, ShouldEqual, "people")
+				So(decoded.Resources[1].Type, ShouldEqual, "posts")
+			})
+
+			Convey("should describe routes and include paths", func() {
+				decoded := schema()
+
+				So(decoded.Resources[1].Routes[0], ShouldResemble, RouteSchema{
+					Method: "GET", Pattern: "/v1/posts/:id", Operation: OpRead,
+				})
+				So(decoded.Resources[1].Includes, ShouldResemble, []string{"author"})
+				So(decoded.Resources[0].Includes, ShouldBeNil)
+			})
+
+			Convey("should merge described, known and maintained attributes", func() {
+				So(schema().Resources[1].Attributes, ShouldResemble, []AttributeSchema{
+					{Name: "body"},
+					{Name: "summary", ReadOnly: true},
+					{Name: "title", Type: "string"},
+					{Name: "views", Type: "number", ReadOnly: true},
+				})
+				So(schema().Resources[0].Attributes, ShouldBeNil)
+			})
+
+			Convey("should merge described and registered relationships", func() {
+				So(schema().Resources[1].Relationships, ShouldResemble, []RelationshipSchema{
+					{Name: "author", RelatedType: "people", Cardinality: CardinalityToOne},
+					{Name: "comments", Cardinality: CardinalityToMany, Mutable: true},
+					{Name: "tags", RelatedType: "tags", Cardinality: CardinalityToMany, Mutable: true},
+				})
+			})
+
+			Convey("should only describe relationships of writable resources as mutable", func() {
+				So(schema().Resources[0].Relationships, ShouldResemble, []RelationshipSchema{
+					{Name: "manager", Cardinality: CardinalityToOne},
+				})
+			})
+
+			Convey("should build the same document every time", func() {
+				first, _ := api.Schema()
+				second, _ := api.Schema()
+				So(string(first), ShouldEqual, string(second))
+			})
+		})
+
+		Convey("->SchemaEndpoint()", func() {
+			api.SchemaEndpoint("_schema")
+
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", "/v1/_schema", nil))
+
+			expected, _ := api.Schema()
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, "application/json")
+			So(recorder.Body.String(), ShouldEqual, string(expected))
+		})
+	})
+}
@@ -0,0 +1,255 @@
+/*
+Package jshapitest provides a high level client for testing jshapi resources and
+APIs, or any http.Handler serving JSON API documents, without a network listener:
+
+	func TestUsers(t *testing.T) {
+		c := jshapitest.NewClient(t, jshapi.NewCRUDResource("users", storage))
+
+		created := c.Post("users", map[string]interface{}{"name": "jane"})
+		user := c.Get("users", created.ID)
+		c.Delete("users", user.ID)
+	}
+
+Each typed helper sets the JSON API headers, asserts the response status and
+content type, and fails the test with the full response document on mismatch.
+Use Do for requests that are expected to fail or do not fit the helpers.
+*/
+package jshapitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api"
+)
+
+// Client sends requests to a handler and decodes its JSON API responses
+type Client struct {
+	t       testing.TB
+	handler http.Handler
+	prefix  string
+	// Header is added to every request sent by the client
+	Header http.Header
+}
+
+/*
+NewClient creates a client sending requests to handler. A *jshapi.API is addressed
+under its prefix, while a *jshapi.Resource is mounted on a new API without prefix,
+pass the API it belongs to instead when API level configuration matters.
+*/
+func NewClient(t testing.TB, handler http.Handler) *Client {
+	prefix := "/"
+
+	switch typed := handler.(type) {
+	case *jshapi.API:
+		prefix = typed.Prefix()
+	case *jshapi.Resource:
+		api := jshapi.New("")
+		api.Add(typed)
+		handler = api
+	}
+
+	return &Client{
+		t:       t,
+		handler: handler,
+		prefix:  prefix,
+		Header:  http.Header{},
+	}
+}
+
+// Response is a recorded response to a request sent through Do
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// request is the method and URL used in failure messages
+	request string
+}
+
+// Document decodes the response body as a JSON API document
+func (r *Response) Document() (*jsh.Document, error) {
+	document := &jsh.Document{Data: jsh.List{}}
+
+	err := json.Unmarshal(r.Body, document)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing JSON Document: %s", err.Error())
+	}
+
+	document.Status = r.StatusCode
+	return document, nil
+}
+
+// Result returns the response as an *http.Response, i.e. for use with Golden
+func (r *Response) Result() *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Header:        r.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+	}
+}
+
+// Get fetches a single resource object, expecting a 200
+func (c *Client) Get(resourceType string, id string) *jsh.Object {
+	c.t.Helper()
+
+	response := c.Do("GET", c.Path(resourceType, id), nil)
+	return c.expectObject(response, http.StatusOK)
+}
+
+// List fetches a resource collection, expecting a 200
+func (c *Client) List(resourceType string) jsh.List {
+	c.t.Helper()
+
+	response := c.Do("GET", c.Path(resourceType), nil)
+	return c.expectDocument(response, http.StatusOK).Data
+}
+
+// Post creates a resource object with the given attributes, expecting a 201
+func (c *Client) Post(resourceType string, attributes map[string]interface{}) *jsh.Object {
+	c.t.Helper()
+
+	body := c.objectBody("", resourceType, attributes)
+	response := c.Do("POST", c.Path(resourceType), body)
+	return c.expectObject(response, http.StatusCreated)
+}
+
+// PatchAttrs updates the given attributes of a resource object, expecting a 200
+func (c *Client) PatchAttrs(resourceType string, id string, attributes map[string]interface{}) *jsh.Object {
+	c.t.Helper()
+
+	body := c.objectBody(id, resourceType, attributes)
+	response := c.Do("PATCH", c.Path(resourceType, id), body)
+	return c.expectObject(response, http.StatusOK)
+}
+
+// Delete deletes a resource object, expecting a 204
+func (c *Client) Delete(resourceType string, id string) {
+	c.t.Helper()
+
+	response := c.Do("DELETE", c.Path(resourceType, id), nil)
+	c.expectStatus(response, http.StatusNoContent)
+}
+
+/*
+Do sends a raw request to the handler and returns the recorded response without
+asserting anything about it. The body may be nil, a []byte or string sent as is,
+or any other value marshaled as JSON. JSON API Content-Type and Accept headers are
+set unless provided through Client.Header.
+*/
+func (c *Client) Do(method string, urlStr string, body interface{}) *Response {
+	c.t.Helper()
+
+	var reader io.Reader
+	switch typed := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(typed)
+	case string:
+		reader = bytes.NewReader([]byte(typed))
+	default:
+		content, err := json.Marshal(typed)
+		if err != nil {
+			c.t.Fatalf("jshapitest: unable to marshal %s %s request body: %s", method, urlStr, err.Error())
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	request := httptest.NewRequest(method, urlStr, reader)
+	request.Header.Set("Accept", jsh.ContentType)
+	if reader != nil {
+		request.Header.Set("Content-Type", jsh.ContentType)
+	}
+
+	for name, values := range c.Header {
+		request.Header[name] = values
+	}
+
+	recorder := httptest.NewRecorder()
+	c.handler.ServeHTTP(recorder, request)
+
+	result := recorder.Result()
+	return &Response{
+		StatusCode: result.StatusCode,
+		Header:     result.Header,
+		Body:       recorder.Body.Bytes(),
+		request:    method + " " + urlStr,
+	}
+}
+
+// Path builds the path of a resource collection or object, under the API prefix
+func (c *Client) Path(resourceType string, id ...string) string {
+	return path.Join(append([]string{c.prefix, resourceType}, id...)...)
+}
+
+// objectBody builds a request document containing a single resource object
+func (c *Client) objectBody(id string, resourceType string, attributes map[string]interface{}) []byte {
+	c.t.Helper()
+
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+
+	object, err := jsh.NewObject(id, resourceType, attributes)
+	if err != nil {
+		c.t.Fatalf("jshapitest: unable to build %s object: %s", resourceType, err.Error())
+	}
+
+	content, marshalErr := json.Marshal(map[string]interface{}{"data": object})
+	if marshalErr != nil {
+		c.t.Fatalf("jshapitest: unable to marshal %s object: %s", resourceType, marshalErr.Error())
+	}
+
+	return content
+}
+
+// expectObject decodes a response containing a single resource object
+func (c *Client) expectObject(response *Response, status int) *jsh.Object {
+	c.t.Helper()
+
+	document := c.expectDocument(response, status)
+	if len(document.Data) != 1 {
+		c.t.Fatalf("jshapitest: %s: expected a single resource object, got %d\n%s", response.request, len(document.Data), response.Body)
+	}
+
+	return document.Data[0]
+}
+
+// expectDocument asserts the status and content type of a response and decodes it
+func (c *Client) expectDocument(response *Response, status int) *jsh.Document {
+	c.t.Helper()
+
+	c.expectStatus(response, status)
+
+	contentType := response.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != jsh.ContentType {
+		c.t.Fatalf("jshapitest: %s: expected Content-Type %s, got '%s'\n%s", response.request, jsh.ContentType, contentType, response.Body)
+	}
+
+	document, err := response.Document()
+	if err != nil {
+		c.t.Fatalf("jshapitest: %s: %s\n%s", response.request, err.Error(), response.Body)
+	}
+
+	return document
+}
+
+// expectStatus fails the test with the response document on a status mismatch
+func (c *Client) expectStatus(response *Response, status int) {
+	c.t.Helper()
+
+	if response.StatusCode != status {
+		c.t.Fatalf("jshapitest: %s: expected status %d, got %d\n%s", response.request, status, response.StatusCode, response.Body)
+	}
+}
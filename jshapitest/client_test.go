@@ -0,0 +1,151 @@
+package jshapitest
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"testing"
+
+	"github.com/derekdowling/jsh-api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testResourceType = "bars"
+
+var testObjAttrs = map[string]string{
+	"foo": "bar",
+}
+
+// failureRecorder stands in for *testing.T to capture the failures of a client
+type failureRecorder struct {
+	testing.TB
+	failure string
+}
+
+func (f *failureRecorder) Helper() {}
+
+func (f *failureRecorder) Fatalf(format string, args ...interface{}) {
+	f.failure = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// failure runs fn with a client whose test failures are recorded
+func failure(handler http.Handler, fn func(c *Client)) string {
+	recorder := &failureRecorder{}
+	done := make(chan bool)
+
+	go func() {
+		defer close(done)
+		fn(NewClient(recorder, handler))
+	}()
+
+	<-done
+	return recorder.failure
+}
+
+func TestClient(t *testing.T) {
+
+	Convey("Client Tests", t, func() {
+
+		resource := jshapi.NewMockResource(testResourceType, 2, testObjAttrs)
+
+		Convey("->NewClient()", func() {
+
+			Convey("should mount a bare resource", func() {
+				c := NewClient(t, resource)
+				So(c.Path(testResourceType, "1"), ShouldEqual, "/bars/1")
+				So(c.Get(testResourceType, "1").ID, ShouldEqual, "1")
+			})
+
+			Convey("should address an API under its prefix", func() {
+				api := jshapi.New("v1")
+				api.Add(resource)
+
+				c := NewClient(t, api)
+				So(c.Path(testResourceType), ShouldEqual, "/v1/bars")
+				So(len(c.List(testResourceType)), ShouldEqual, 2)
+			})
+
+			Convey("should accept any http.Handler", func() {
+				api := jshapi.New("")
+				api.Add(resource)
+
+				mux := http.NewServeMux()
+				mux.Handle("/", api)
+
+				c := NewClient(t, mux)
+				So(c.Get(testResourceType, "2").ID, ShouldEqual, "2")
+			})
+		})
+
+		Convey("->Post()", func() {
+			c := NewClient(t, resource)
+
+			object := c.Post(testResourceType, map[string]interface{}{"foo": "baz"})
+			So(object.ID, ShouldEqual, "1")
+			So(string(object.Attributes), ShouldContainSubstring, "baz")
+		})
+
+		Convey("->PatchAttrs()", func() {
+			c := NewClient(t, resource)
+
+			object := c.PatchAttrs(testResourceType, "1", map[string]interface{}{"foo": "qux"})
+			So(object.ID, ShouldEqual, "1")
+			So(string(object.Attributes), ShouldContainSubstring, "qux")
+		})
+
+		Convey("->Delete()", func() {
+			c := NewClient(t, resource)
+			c.Delete(testResourceType, "1")
+		})
+
+		Convey("->Do()", func() {
+			c := NewClient(t, resource)
+			c.Header.Set("Accept", `application/vnd.api+json; ext="https://example.com/ext"`)
+
+			response := c.Do("GET", c.Path(testResourceType, "1"), nil)
+			So(response.StatusCode, ShouldEqual, http.StatusNotAcceptable)
+
+			document, err := response.Document()
+			So(err, ShouldBeNil)
+			So(len(document.Errors), ShouldEqual, 1)
+			So(document.Status, ShouldEqual, http.StatusNotAcceptable)
+		})
+
+		Convey("should fail the test with the error document", func() {
+
+			Convey("on a status mismatch", func() {
+				message := failure(resource, func(c *Client) {
+					c.Get("missing", "1")
+				})
+
+				So(message, ShouldContainSubstring, "GET /missing/1: expected status 200, got 404")
+			})
+
+			Convey("on a content type mismatch", func() {
+				mux := http.NewServeMux()
+				mux.HandleFunc("/bars/1", func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(`{"data": null}`))
+				})
+
+				message := failure(mux, func(c *Client) {
+					c.Get(testResourceType, "1")
+				})
+
+				So(message, ShouldContainSubstring, "expected Content-Type application/vnd.api+json, got 'application/json'")
+				So(message, ShouldContainSubstring, `{"data": null}`)
+			})
+
+			Convey("on error responses", func() {
+				message := failure(resource, func(c *Client) {
+					c.Header.Set("Content-Type", "text/plain")
+					c.Post(testResourceType, map[string]interface{}{"foo": "baz"})
+				})
+
+				So(message, ShouldContainSubstring, "POST /bars: expected status 201, got 406")
+				So(message, ShouldContainSubstring, `"errors"`)
+			})
+		})
+	})
+}
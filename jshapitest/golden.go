@@ -0,0 +1,320 @@
+package jshapitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// update is set by running the tests with "-update" to rewrite golden files
+var update = flag.Bool("update", false, "rewrite jshapitest golden files")
+
+/*
+Normalizer rewrites a decoded JSON body before it is compared to a golden file,
+typically to replace values that change from one run to the next. Objects are
+decoded as map[string]interface{}, arrays as []interface{} and numbers as
+json.Number.
+*/
+type Normalizer func(value interface{}) interface{}
+
+/*
+Golden compares the JSON body of a response against testdata/<name>.json, failing
+the test with a line diff on mismatch. The body is canonicalized first, with
+sorted keys and consistent indentation, then passed through the normalizers:
+
+	jshapitest.Golden(t, "users_get", response,
+		jshapitest.ReplaceMember("created-at", "<timestamp>"),
+	)
+
+Running the tests with the -update flag rewrites the golden files instead. The
+response body is read and replaced, so it remains readable after the comparison.
+*/
+func Golden(t testing.TB, name string, response *http.Response, normalizers ...Normalizer) {
+	t.Helper()
+
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		t.Fatalf("jshapitest: unable to read response body for golden file '%s': %s", name, err.Error())
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	got, err := normalizeJSON(body, normalizers)
+	if err != nil {
+		t.Fatalf("jshapitest: response body for golden file '%s' is not JSON: %s\n%s", name, err.Error(), body)
+	}
+
+	goldenPath := filepath.Join("testdata", name+".json")
+
+	if *update {
+		err = os.MkdirAll(filepath.Dir(goldenPath), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(goldenPath, got, 0644)
+		}
+		if err != nil {
+			t.Fatalf("jshapitest: unable to write golden file: %s", err.Error())
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("jshapitest: unable to read golden file, run the tests with -update to create it: %s", err.Error())
+	}
+
+	if !bytes.Equal(want, got) {
+		t.Fatalf("jshapitest: response does not match %s (-want +got):\n%s", goldenPath, lineDiff(string(want), string(got)))
+	}
+}
+
+/*
+ReplaceMember replaces the value of every object member with the given name, at
+any depth, by a placeholder. Use it for generated ids and timestamps:
+
+	jshapitest.ReplaceMember("id", "<id>")
+*/
+func ReplaceMember(name string, placeholder interface{}) Normalizer {
+	return func(value interface{}) interface{} {
+		return walkJSON(value, func(member string, value interface{}) interface{} {
+			if member == name {
+				return placeholder
+			}
+			return value
+		})
+	}
+}
+
+// ReplaceMatches replaces every match of expression within string values by a
+// placeholder
+func ReplaceMatches(expression *regexp.Regexp, placeholder string) Normalizer {
+	return func(value interface{}) interface{} {
+		return walkJSON(value, func(member string, value interface{}) interface{} {
+			str, isString := value.(string)
+			if isString {
+				return expression.ReplaceAllString(str, placeholder)
+			}
+			return value
+		})
+	}
+}
+
+// walkJSON rewrites a decoded JSON value depth first, fn receives the name of the
+// member holding each value, or an empty string for array items and the root
+func walkJSON(value interface{}, fn func(member string, value interface{}) interface{}) interface{} {
+	var walk func(member string, value interface{}) interface{}
+	walk = func(member string, value interface{}) interface{} {
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			for name, child := range typed {
+				typed[name] = walk(name, child)
+			}
+		case []interface{}:
+			for index, child := range typed {
+				typed[index] = walk("", child)
+			}
+		}
+
+		return fn(member, value)
+	}
+
+	return walk("", value)
+}
+
+// normalizeJSON decodes a body, applies the normalizers and encodes it canonically,
+// an empty body is kept empty
+func normalizeJSON(body []byte, normalizers []Normalizer) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return []byte{}, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the top level value")
+	}
+
+	for _, normalizer := range normalizers {
+		value = normalizer(value)
+	}
+
+	buffer := &bytes.Buffer{}
+	err = writeCanonical(buffer, value, "")
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteByte('\n')
+
+	return buffer.Bytes(), nil
+}
+
+/*
+writeCanonical encodes a decoded JSON value with sorted object keys, two space
+indentation and no HTML escaping, so that equivalent documents always produce the
+same bytes.
+*/
+func writeCanonical(w *bytes.Buffer, value interface{}, indent string) error {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if len(typed) == 0 {
+			w.WriteString("{}")
+			return nil
+		}
+
+		names := make([]string, 0, len(typed))
+		for name := range typed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		w.WriteString("{\n")
+		for index, name := range names {
+			w.WriteString(indent + "  ")
+			writeString(w, name)
+			w.WriteString(": ")
+
+			err := writeCanonical(w, typed[name], indent+"  ")
+			if err != nil {
+				return err
+			}
+
+			if index < len(names)-1 {
+				w.WriteByte(',')
+			}
+			w.WriteByte('\n')
+		}
+		w.WriteString(indent + "}")
+
+	case []interface{}:
+		if len(typed) == 0 {
+			w.WriteString("[]")
+			return nil
+		}
+
+		w.WriteString("[\n")
+		for index, item := range typed {
+			w.WriteString(indent + "  ")
+
+			err := writeCanonical(w, item, indent+"  ")
+			if err != nil {
+				return err
+			}
+
+			if index < len(typed)-1 {
+				w.WriteByte(',')
+			}
+			w.WriteByte('\n')
+		}
+		w.WriteString(indent + "]")
+
+	case string:
+		writeString(w, typed)
+
+	default:
+		// null, booleans, numbers, and values set by normalizers
+		content, err := json.Marshal(typed)
+		if err != nil {
+			return err
+		}
+
+		if bytes.HasPrefix(content, []byte("{")) || bytes.HasPrefix(content, []byte("[")) {
+			// re-decode values such as structs so that they are canonical too
+			var decoded interface{}
+			decoder := json.NewDecoder(bytes.NewReader(content))
+			decoder.UseNumber()
+			err = decoder.Decode(&decoded)
+			if err != nil {
+				return err
+			}
+			return writeCanonical(w, decoded, indent)
+		}
+
+		w.Write(content)
+	}
+
+	return nil
+}
+
+// writeString encodes a JSON string without escaping HTML characters
+func writeString(w *bytes.Buffer, str string) {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(str)
+
+	// Encode terminates values with a newline
+	w.Truncate(w.Len() - 1)
+}
+
+/*
+lineDiff returns a line based diff of two texts, computed from their longest
+common subsequence of lines. Removed lines are prefixed with "-", added lines with
+"+" and unchanged lines with a space.
+*/
+func lineDiff(want string, got string) string {
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+
+	// lengths[i][j] is the length of the common subsequence of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	diff := &bytes.Buffer{}
+	writeLine := func(prefix string, line string) {
+		io.WriteString(diff, prefix+line+"\n")
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			writeLine(" ", a[i])
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			writeLine("-", a[i])
+			i++
+		default:
+			writeLine("+", b[j])
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		writeLine("-", a[i])
+	}
+	for ; j < len(b); j++ {
+		writeLine("+", b[j])
+	}
+
+	return diff.String()
+}
@@ -0,0 +1,122 @@
+package jshapitest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/derekdowling/jsh-api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGolden(t *testing.T) {
+
+	Convey("Golden Tests", t, func() {
+
+		resource := jshapi.NewMockResource(testResourceType, 2, testObjAttrs)
+
+		Convey("->Golden()", func() {
+			c := NewClient(t, resource)
+
+			Convey("should match success documents", func() {
+				response := c.Do("GET", c.Path(testResourceType), nil).Result()
+				Golden(t, "list", response, ReplaceMember("id", "<id>"))
+
+				Convey("and leave the body readable", func() {
+					body, err := ioutil.ReadAll(response.Body)
+					So(err, ShouldBeNil)
+					So(string(body), ShouldContainSubstring, `"data"`)
+				})
+			})
+
+			Convey("should match error documents", func() {
+				c.Header.Set("Accept", `application/vnd.api+json; ext="https://example.com/ext"`)
+
+				response := c.Do("GET", c.Path(testResourceType, "1"), nil).Result()
+				So(response.StatusCode, ShouldEqual, http.StatusNotAcceptable)
+				Golden(t, "not_acceptable", response, ReplaceMatches(regexp.MustCompile(`https://[^']+`), "<uri>"))
+			})
+
+			Convey("should fail with a diff on mismatch", func() {
+				defer func(updating bool) { *update = updating }(*update)
+				*update = false
+
+				message := failure(resource, func(c *Client) {
+					response := c.Do("GET", c.Path(testResourceType), nil).Result()
+					Golden(c.t, "list", response)
+				})
+
+				So(message, ShouldContainSubstring, "response does not match testdata/list.json (-want +got)")
+				So(message, ShouldContainSubstring, `-      "id": "<id>",`)
+				So(message, ShouldContainSubstring, `+      "id": "1",`)
+			})
+
+			Convey("should fail on missing golden files", func() {
+				defer func(updating bool) { *update = updating }(*update)
+				*update = false
+
+				message := failure(resource, func(c *Client) {
+					response := c.Do("GET", c.Path(testResourceType), nil).Result()
+					Golden(c.t, "missing", response)
+				})
+
+				So(message, ShouldContainSubstring, "run the tests with -update to create it")
+			})
+		})
+
+		Convey("->normalizeJSON()", func() {
+
+			Convey("should sort keys and preserve numbers and characters", func() {
+				body := []byte(`{"b": [1, 2.50, {"d": null, "c": true}], "a": "<&>", "e": {}, "f": []}`)
+
+				normalized, err := normalizeJSON(body, nil)
+				So(err, ShouldBeNil)
+				So(string(normalized), ShouldEqual, `{
+  "a": "<&>",
+  "b": [
+    1,
+    2.50,
+    {
+      "c": true,
+      "d": null
+    }
+  ],
+  "e": {},
+  "f": []
+}
+`)
+			})
+
+			Convey("should apply normalizers in order", func() {
+				body := []byte(`{"data": {"id": "42", "attributes": {"at": "2016-01-02T15:04:05Z"}}}`)
+
+				normalized, err := normalizeJSON(body, []Normalizer{
+					ReplaceMember("id", map[string]int{"n": 1}),
+					ReplaceMatches(regexp.MustCompile(`\d{4}-\d{2}-\d{2}T[\d:]+Z`), "<time>"),
+				})
+				So(err, ShouldBeNil)
+				So(string(normalized), ShouldContainSubstring, `"at": "<time>"`)
+				So(string(normalized), ShouldContainSubstring, "\"id\": {\n      \"n\": 1\n    }")
+			})
+
+			Convey("should keep empty bodies empty", func() {
+				normalized, err := normalizeJSON([]byte(" \n"), nil)
+				So(err, ShouldBeNil)
+				So(normalized, ShouldBeEmpty)
+			})
+
+			Convey("should reject invalid JSON", func() {
+				_, err := normalizeJSON([]byte(`{"a": 1} {}`), nil)
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("->lineDiff()", func() {
+			diff := lineDiff("a\nb\nc", "a\nx\nc\nd")
+			So(diff, ShouldEqual, " a\n-b\n+x\n c\n+d\n")
+			So(bytes.Count([]byte(lineDiff("a", "a")), []byte("\n")), ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,154 @@
+package jshapitest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api"
+)
+
+/*
+AssertRoutes fails the test with a diff when the routes of the API, as listed by
+API.Routes, differ from the expected ones. The expected routes may be given in any
+order:
+
+	jshapitest.AssertRoutes(t, api, []jshapi.Route{
+		{Method: "GET", Pattern: "/users", ResourceType: "users", Operation: jshapi.OpList},
+		{Method: "GET", Pattern: "/users/:id", ResourceType: "users", Operation: jshapi.OpRead},
+	})
+*/
+func AssertRoutes(t testing.TB, api *jshapi.API, expected []jshapi.Route) {
+	t.Helper()
+
+	want := formatRoutes(expected)
+	got := formatRoutes(api.Routes())
+
+	if want != got {
+		t.Fatalf("jshapitest: routes do not match (-want +got):\n%s", lineDiff(want, got))
+	}
+}
+
+// formatRoutes lists routes one per line, sorted so that order does not matter
+func formatRoutes(routes []jshapi.Route) string {
+	lines := make([]string, 0, len(routes))
+	for _, route := range routes {
+		line := fmt.Sprintf(
+			"%-7s %s %s %s",
+			route.Method, route.Pattern, route.ResourceType, route.Operation,
+		)
+		if route.AliasOf != "" {
+			line += " alias of " + route.AliasOf
+		}
+		lines = append(lines, line)
+	}
+
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+/*
+AssertServes issues a request without body through handler and fails the test if
+the response status differs from wantStatus, proving that the route is actually
+served rather than only registered.
+*/
+func AssertServes(t testing.TB, handler http.Handler, method string, path string, wantStatus int) {
+	t.Helper()
+
+	response := NewClient(t, handler).Do(method, path, nil)
+	if response.StatusCode != wantStatus {
+		t.Fatalf("jshapitest: %s %s: expected status %d, got %d\n%s", method, path, wantStatus, response.StatusCode, response.Body)
+	}
+}
+
+/*
+AssertNoServerErrors sends a minimal valid request to every route of the API, with
+route parameters such as :id set to "1", and fails the test listing the routes
+responding with a 5xx status. Wire the resources to EmptyStorage, or other storage
+holding no data, to check that every route copes with it.
+*/
+func AssertNoServerErrors(t testing.TB, api *jshapi.API) {
+	t.Helper()
+
+	failures := []string{}
+	for _, route := range api.Routes() {
+		c := NewClient(t, api)
+
+		var body interface{}
+		switch {
+		case route.ResourceType == "":
+			if route.Method == "POST" {
+				c.Header.Set("Content-Type", fmt.Sprintf(`%s; ext="%s"`, jsh.ContentType, jshapi.AtomicExtension))
+				body = map[string]interface{}{"atomic:operations": []interface{}{}}
+			}
+		case route.Method == "POST":
+			body = c.objectBody("", route.ResourceType, nil)
+		case route.Method == "PATCH":
+			body = c.objectBody("1", route.ResourceType, nil)
+		}
+
+		path := routePath(route.Pattern)
+		response := c.Do(route.Method, path, body)
+		if response.StatusCode >= 500 {
+			failures = append(failures, fmt.Sprintf("%s %s: %d\n%s", route.Method, path, response.StatusCode, response.Body))
+		}
+	}
+
+	if len(failures) > 0 {
+		t.Fatalf("jshapitest: routes responded with server errors:\n%s", strings.Join(failures, "\n"))
+	}
+}
+
+// routePath fills in the variables of a route pattern
+func routePath(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for index, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[index] = "1"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+/*
+EmptyStorage implements store.CRUD for a resource holding no objects: lists are
+empty, objects are not found, and created objects are returned with the id "1".
+*/
+type EmptyStorage struct {
+	ResourceType string
+}
+
+// Save returns the object, with the id "1" if it has none
+func (s *EmptyStorage) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	if object.ID == "" {
+		object.ID = "1"
+	}
+
+	return object, nil
+}
+
+// Get returns a 404
+func (s *EmptyStorage) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	return nil, jsh.NotFound(s.ResourceType, id)
+}
+
+// List returns an empty list
+func (s *EmptyStorage) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	return jsh.List{}, nil
+}
+
+// Update returns a 404
+func (s *EmptyStorage) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	return nil, jsh.NotFound(s.ResourceType, object.ID)
+}
+
+// Delete returns a 404
+func (s *EmptyStorage) Delete(ctx context.Context, id string) jsh.ErrorType {
+	return jsh.NotFound(s.ResourceType, id)
+}
@@ -0,0 +1,90 @@
+package jshapitest
+
+import (
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRoutes(t *testing.T) {
+
+	Convey("Routes Tests", t, func() {
+
+		resource := jshapi.NewCRUDResource(testResourceType, &EmptyStorage{ResourceType: testResourceType})
+		resource.ToMany("foos", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return jsh.List{}, nil
+		})
+
+		api := jshapi.New("")
+		api.Add(resource)
+		api.AtomicOperations("operations")
+
+		expected := []jshapi.Route{
+			{Method: "POST", Pattern: "/operations"},
+			{Method: "GET", Pattern: "/bars/:id/foos", ResourceType: testResourceType, Operation: jshapi.OpRelationship},
+			{Method: "GET", Pattern: "/bars/:id/relationships/foos", ResourceType: testResourceType, Operation: jshapi.OpRelationship},
+			{Method: "GET", Pattern: "/bars", ResourceType: testResourceType, Operation: jshapi.OpList},
+			{Method: "POST", Pattern: "/bars", ResourceType: testResourceType, Operation: jshapi.OpCreate},
+			{Method: "GET", Pattern: "/bars/:id", ResourceType: testResourceType, Operation: jshapi.OpRead},
+			{Method: "PATCH", Pattern: "/bars/:id", ResourceType: testResourceType, Operation: jshapi.OpUpdate},
+			{Method: "DELETE", Pattern: "/bars/:id", ResourceType: testResourceType, Operation: jshapi.OpDelete},
+		}
+
+		Convey("->AssertRoutes()", func() {
+
+			Convey("should accept routes in any order", func() {
+				AssertRoutes(t, api, expected)
+			})
+
+			Convey("should fail with a diff on mismatch", func() {
+				message := failure(api, func(c *Client) {
+					AssertRoutes(c.t, api, expected[1:])
+				})
+
+				So(message, ShouldContainSubstring, "routes do not match (-want +got)")
+				So(message, ShouldContainSubstring, "+POST    /operations")
+			})
+		})
+
+		Convey("->AssertServes()", func() {
+			AssertServes(t, api, "GET", "/bars", http.StatusOK)
+			AssertServes(t, api, "GET", "/bars/1/foos", http.StatusOK)
+
+			message := failure(api, func(c *Client) {
+				AssertServes(c.t, api, "GET", "/bazs", http.StatusOK)
+			})
+			So(message, ShouldContainSubstring, "GET /bazs: expected status 200, got 404")
+		})
+
+		Convey("->AssertNoServerErrors()", func() {
+
+			Convey("should pass with empty storage", func() {
+				AssertNoServerErrors(t, api)
+			})
+
+			Convey("should list failing routes", func() {
+				resource.Action("crash", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+					return nil, jsh.ISE("crash")
+				})
+
+				message := failure(api, func(c *Client) {
+					AssertNoServerErrors(c.t, api)
+				})
+				So(message, ShouldContainSubstring, "GET /bars/1/crash: 500")
+			})
+		})
+
+		Convey("->EmptyStorage", func() {
+			c := NewClient(t, api)
+
+			So(c.List(testResourceType), ShouldBeEmpty)
+			So(c.Post(testResourceType, nil).ID, ShouldEqual, "1")
+			So(c.Do("GET", "/bars/1", nil).StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
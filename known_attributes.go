@@ -0,0 +1,148 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/go-stdlogger"
+	"golang.org/x/net/context"
+)
+
+/*
+KnownAttributes declares the attributes clients may write to a resource, by name as
+sent. POST and PATCH requests writing other attributes are rejected with a 400
+error per unknown attribute, each pointing at it, unless WarnUnknownAttributes is
+set. Timestamps, default and computed attributes of the resource are known
+without being declared. Calls add to the names already declared, with a mapper
+the names are those of its struct:
+
+	res.KnownAttributes(posts.Attributes()...)
+*/
+func (res *Resource) KnownAttributes(names ...string) {
+	res.checkRegistration("known attributes")
+
+	if res.knownAttributes == nil {
+		res.knownAttributes = map[string]bool{}
+	}
+	for _, name := range names {
+		res.knownAttributes[name] = true
+	}
+}
+
+/*
+WarnUnknownAttributes accepts the unknown attributes written to a resource declaring
+its KnownAttributes rather than rejecting them. Unknown attributes are then logged
+to logger, or to stderr when nil, and listed by name in the "unknown-attributes"
+meta member of the response.
+*/
+func (res *Resource) WarnUnknownAttributes(logger std.Logger) {
+	res.checkRegistration("unknown attribute warnings")
+
+	if logger == nil {
+		logger = log.New(os.Stderr, "jshapi: ", log.LstdFlags)
+	}
+	res.unknownLogger = logger
+}
+
+// unknownAttributes returns the names of the attributes of object the resource
+// doesn't know, in document order
+func (res *Resource) unknownAttributes(object *jsh.Object) []string {
+	if res.knownAttributes == nil || object == nil {
+		return nil
+	}
+
+	var unknown []string
+	rewriteAttributes(object.Attributes, func(name string, value json.RawMessage) (string, json.RawMessage, error) {
+		if !res.knownAttribute(name) {
+			unknown = append(unknown, name)
+		}
+		return name, value, nil
+	})
+
+	return unknown
+}
+
+// knownAttribute reports whether name was declared or is maintained by the
+// resource
+func (res *Resource) knownAttribute(name string) bool {
+	if res.knownAttributes[name] || res.computed[name] != nil {
+		return true
+	}
+	if res.timestamps != nil && (name == res.timestamps.created || name == res.timestamps.updated) {
+		return true
+	}
+	for _, attribute := range res.defaults {
+		if attribute.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unknownAttributeErrors rejects the unknown attributes of object, or logs them
+// when warning about them
+func (res *Resource) unknownAttributeErrors(ctx context.Context, object *jsh.Object) jsh.ErrorList {
+	unknown := res.unknownAttributes(object)
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	if res.unknownLogger != nil {
+		var logPrefix string
+		if requestID := GetRequestID(ctx); requestID != "" {
+			logPrefix = "[" + requestID + "] "
+		}
+		res.unknownLogger.Printf("%sUnknown attributes written to '%s': %s\n", logPrefix, res.Type, strings.Join(unknown, ", "))
+		return nil
+	}
+
+	var errs jsh.ErrorList
+	for _, name := range unknown {
+		err := &jsh.Error{
+			Title:  "Bad Request",
+			Detail: fmt.Sprintf("Attribute '%s' is unknown", name),
+			Status: http.StatusBadRequest,
+		}
+		err.Source.Pointer = "/data/attributes/" + escapePointer(name)
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// withUnknownAttributes records the unknown attributes of object for the response
+// meta when warning about them
+func (res *Resource) withUnknownAttributes(ctx context.Context, object *jsh.Object) context.Context {
+	if res.unknownLogger == nil {
+		return ctx
+	}
+
+	unknown := res.unknownAttributes(object)
+	if len(unknown) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, unknownAttributesKey, unknown)
+}
+
+// addUnknownAttributes lists the unknown attributes recorded for the request in the
+// meta of doc
+func addUnknownAttributes(ctx context.Context, doc *jsh.Document) {
+	unknown, recorded := ctx.Value(unknownAttributesKey).([]string)
+	if !recorded {
+		return
+	}
+
+	meta, isMap := doc.Meta.(map[string]interface{})
+	if !isMap {
+		meta = map[string]interface{}{}
+		doc.Meta = meta
+	}
+	meta["unknown-attributes"] = unknown
+}
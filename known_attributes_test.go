@@ -0,0 +1,96 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKnownAttributes(t *testing.T) {
+
+	Convey("Known Attributes Tests", t, func() {
+
+		var saved map[string]interface{}
+		store := func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+			saved = map[string]interface{}{}
+			json.Unmarshal(object.Attributes, &saved)
+			object.ID = "1"
+			return object, nil
+		}
+
+		users := NewResource("users")
+		users.Post(store)
+		users.Patch(store)
+		users.KnownAttributes("name", "email")
+
+		api := New("")
+		serve := func(method string, url string, body string) *httptest.ResponseRecorder {
+			if len(api.Resources) == 0 {
+				api.Add(users)
+			}
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should accept known attributes", func() {
+			recorder := serve("POST", "/users", `{"data": {"type": "users", "attributes": {"name": "Jo", "email": "jo@example.com"}}}`)
+
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+			So(recorder.Body.String(), ShouldNotContainSubstring, "unknown-attributes")
+		})
+
+		Convey("should reject each unknown attribute", func() {
+			recorder := serve("PATCH", "/users/1", `{"data": {"type": "users", "id": "1", "attributes": {"nmae": "Jo", "email": "jo@example.com", "admin": true}}}`)
+
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, `"pointer": "/data/attributes/nmae"`)
+			So(recorder.Body.String(), ShouldContainSubstring, `"pointer": "/data/attributes/admin"`)
+			So(recorder.Body.String(), ShouldNotContainSubstring, `"pointer": "/data/attributes/email"`)
+			So(saved, ShouldBeNil)
+		})
+
+		Convey("should know the attributes maintained by the resource", func() {
+			users.DefaultAttributes(map[string]interface{}{"role": "member"})
+
+			recorder := serve("POST", "/users", `{"data": {"type": "users", "attributes": {"role": "admin"}}}`)
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+		})
+
+		Convey("should log and list unknown attributes when warning", func() {
+			logged := &bytes.Buffer{}
+			users.WarnUnknownAttributes(log.New(logged, "", 0))
+
+			recorder := serve("POST", "/users", `{"data": {"type": "users", "attributes": {"name": "Jo", "nmae": "Al"}}}`)
+
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+			So(saved, ShouldResemble, map[string]interface{}{"name": "Jo", "nmae": "Al"})
+			So(logged.String(), ShouldEqual, "Unknown attributes written to 'users': nmae\n")
+
+			body := map[string]interface{}{}
+			json.Unmarshal(recorder.Body.Bytes(), &body)
+			So(body["meta"], ShouldResemble, map[string]interface{}{"unknown-attributes": []interface{}{"nmae"}})
+		})
+
+		Convey("should leave resources without known attributes untouched", func() {
+			open := NewResource("users")
+			open.Post(store)
+			api.Add(open)
+
+			recorder := serve("POST", "/users", `{"data": {"type": "users", "attributes": {"anything": 1}}}`)
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+		})
+	})
+}
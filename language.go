@@ -0,0 +1,159 @@
+package jshapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"golang.org/x/net/context"
+)
+
+/*
+ErrorTranslator returns the error object e translated to lang, or nil to send e
+as it is. It receives a copy of the error, which it may modify and return.
+*/
+type ErrorTranslator func(ctx context.Context, lang string, e *jsh.Error) *jsh.Error
+
+/*
+SetErrorTranslator translates the title and detail of error objects to the language
+of the requester. The language is negotiated from the Accept-Language header of
+every request, highest quality first, see LanguageFromContext. SendHandler then
+passes each error object sent through translate, error responses varying by
+Accept-Language and carrying the Content-Language of the translations.
+*/
+func (a *API) SetErrorTranslator(translate ErrorTranslator) {
+	a.checkRegistration("an error translator")
+	a.translator = translate
+}
+
+// errorLanguage is the negotiated language of a request and its translator
+type errorLanguage struct {
+	lang      string
+	translate ErrorTranslator
+}
+
+// LanguageFromContext returns the language negotiated for the request, empty
+// without an error translator or an acceptable language
+func LanguageFromContext(ctx context.Context) string {
+	language, _ := ctx.Value(languageKey).(*errorLanguage)
+	if language == nil {
+		return ""
+	}
+
+	return language.lang
+}
+
+// withLanguage records the language of the request when errors are translated
+func (a *API) withLanguage(ctx context.Context, r *http.Request) context.Context {
+	if a.translator == nil {
+		return ctx
+	}
+
+	language := &errorLanguage{
+		lang:      negotiateLanguage(r.Header.Get("Accept-Language")),
+		translate: a.translator,
+	}
+	return context.WithValue(ctx, languageKey, language)
+}
+
+/*
+negotiateLanguage returns the language tag of an Accept-Language header with the
+highest quality, the first one on ties. Wildcards and tags of quality 0 are never
+chosen.
+*/
+func negotiateLanguage(header string) string {
+	var chosen string
+	var chosenQuality float64
+
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		tag := strings.TrimSpace(params[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+
+			parsed, err := strconv.ParseFloat(param[len("q="):], 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			quality = parsed
+		}
+
+		if quality > chosenQuality {
+			chosen, chosenQuality = tag, quality
+		}
+	}
+
+	return chosen
+}
+
+/*
+translateErrors returns sendable with its error objects translated to the language
+of the request, the errors left untranslated by the translator unchanged. Error
+responses are marked as varying by Accept-Language, and carry the Content-Language
+of their translations.
+*/
+func translateErrors(ctx context.Context, w http.ResponseWriter, sendable jsh.Sendable) jsh.Sendable {
+	language, _ := ctx.Value(languageKey).(*errorLanguage)
+	if language == nil {
+		return sendable
+	}
+
+	var errs jsh.ErrorList
+	document, isDocument := sendable.(*jsh.Document)
+	switch typed := sendable.(type) {
+	case *jsh.Error:
+		errs = jsh.ErrorList{typed}
+	case jsh.ErrorList:
+		errs = typed
+	case *jsh.Document:
+		errs = typed.Errors
+	}
+	if len(errs) == 0 {
+		return sendable
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+
+	translated := make(jsh.ErrorList, len(errs))
+	var anyTranslated bool
+	for index, err := range errs {
+		translated[index] = err
+		if err == nil {
+			continue
+		}
+
+		copied := *err
+		translation := language.translate(ctx, language.lang, &copied)
+		if translation != nil {
+			translated[index] = translation
+			anyTranslated = true
+		}
+	}
+
+	if !anyTranslated {
+		return sendable
+	}
+	if language.lang != "" {
+		w.Header().Set("Content-Language", language.lang)
+	}
+
+	if isDocument {
+		copied := *document
+		copied.Errors = translated
+		return &copied
+	}
+	if _, isSingle := sendable.(*jsh.Error); isSingle {
+		return translated[0]
+	}
+
+	return translated
+}
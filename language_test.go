@@ -0,0 +1,104 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestErrorTranslator(t *testing.T) {
+
+	Convey("Error Translator Tests", t, func() {
+
+		var negotiated string
+		users := NewResource("users")
+		users.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			negotiated = LanguageFromContext(ctx)
+			if id == "2" {
+				return nil, jsh.ErrorList{
+					{Title: "Not Found", Detail: "No user 2", Status: http.StatusNotFound},
+					{Title: "Gone", Detail: "Archived", Status: http.StatusNotFound},
+				}
+			}
+			return nil, jsh.NotFound("users", id)
+		})
+
+		api := New("")
+		api.Add(users)
+
+		serve := func(url string, acceptLanguage string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest("GET", url, nil)
+			if acceptLanguage != "" {
+				request.Header.Set("Accept-Language", acceptLanguage)
+			}
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should leave errors untouched without a translator", func() {
+			recorder := serve("/users/1", "fr")
+
+			So(recorder.Body.String(), ShouldContainSubstring, `"title": "Not Found"`)
+			So(recorder.Header().Get("Vary"), ShouldEqual, "")
+			So(negotiated, ShouldEqual, "")
+		})
+
+		Convey("->SetErrorTranslator()", func() {
+			var translatedTo string
+			api.SetErrorTranslator(func(ctx context.Context, lang string, e *jsh.Error) *jsh.Error {
+				translatedTo = lang
+				if lang != "fr" || e.Title != "Not Found" {
+					return nil
+				}
+
+				e.Title = "Introuvable"
+				return e
+			})
+
+			Convey("should translate errors to the preferred language", func() {
+				recorder := serve("/users/1", "de;q=0.5, fr, en;q=0.8")
+
+				So(recorder.Code, ShouldEqual, http.StatusNotFound)
+				So(recorder.Body.String(), ShouldContainSubstring, `"title": "Introuvable"`)
+				So(recorder.Header().Get("Content-Language"), ShouldEqual, "fr")
+				So(recorder.Header().Get("Vary"), ShouldEqual, "Accept-Language")
+				So(negotiated, ShouldEqual, "fr")
+			})
+
+			Convey("should pass untranslated errors through unchanged", func() {
+				recorder := serve("/users/2", "fr")
+
+				So(recorder.Body.String(), ShouldContainSubstring, `"title": "Introuvable"`)
+				So(recorder.Body.String(), ShouldContainSubstring, `"title": "Gone"`)
+
+				recorder = serve("/users/1", "en")
+				So(recorder.Body.String(), ShouldContainSubstring, `"title": "Not Found"`)
+				So(recorder.Header().Get("Content-Language"), ShouldEqual, "")
+				So(recorder.Header().Get("Vary"), ShouldEqual, "Accept-Language")
+			})
+
+			Convey("should translate errors raised before routing", func() {
+				recorder := httptest.NewRecorder()
+				request := httptest.NewRequest("GET", "/users/1", nil)
+				request.Header.Set("Accept", jsh.ContentType+"; charset=utf-8")
+				request.Header.Set("Accept-Language", "en")
+				api.ServeHTTP(recorder, request)
+
+				So(recorder.Code, ShouldEqual, http.StatusNotAcceptable)
+				So(translatedTo, ShouldEqual, "en")
+			})
+
+			Convey("should negotiate without a usable language", func() {
+				serve("/users/1", "*, fr;q=0")
+				So(negotiated, ShouldEqual, "")
+				So(translatedTo, ShouldEqual, "")
+			})
+		})
+	})
+}
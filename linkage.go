@@ -0,0 +1,233 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+link sets the linkage of a relationship of parent to the identifiers of the objects
+included for it, unless the storage provided its own linkage, which is trusted over
+the resolved one. parent is a copy owned by the document, its relationship is
+replaced rather than modified.
+*/
+func (inc *inclusion) link(parent *jsh.Object, name string, linkage jsh.ResourceLinkage) {
+	existing := parent.Relationships[name]
+	if existing != nil && existing.Data != nil {
+		return
+	}
+
+	relationship := &jsh.Relationship{Data: linkage}
+	if existing != nil {
+		relationship.Links = existing.Links
+		relationship.Meta = existing.Meta
+	}
+
+	if parent.Relationships == nil {
+		parent.Relationships = map[string]*jsh.Relationship{}
+	}
+	parent.Relationships[name] = relationship
+}
+
+// documentCopy returns a copy of object the document may amend, with its own
+// relationships
+func documentCopy(object *jsh.Object) *jsh.Object {
+	documented := *object
+	if object.Relationships != nil {
+		documented.Relationships = make(map[string]*jsh.Relationship, len(object.Relationships))
+		for name, relationship := range object.Relationships {
+			documented.Relationships[name] = relationship
+		}
+	}
+
+	return &documented
+}
+
+/*
+encodeDocument writes document to buffer as compact JSON in a single pass, with its
+top-level link as the self member of the links object, jsh.Document holding a single
+link. jsh always marshals linkage as an array, the linkage of ToOne relationships of
+resources of api is sent as a single resource identifier, or null when empty, and
+empty ToMany linkage as an empty array rather than being left out.
+*/
+func encodeDocument(buffer *bytes.Buffer, document *jsh.Document, api *API) error {
+	var links map[string]*jsh.Link
+	if document.Links != nil {
+		links = map[string]*jsh.Link{"self": document.Links}
+	}
+
+	var included interface{}
+	if len(document.Included) > 0 {
+		included = api.shapeList(document.Included)
+	}
+
+	var encoded interface{}
+	switch document.Mode {
+	case jsh.ErrorMode:
+		encoded = errorDocumentJSON{document.Errors, links, included, document.Meta, document.JSONAPI}
+	case jsh.ObjectMode:
+		var data interface{} = (*jsh.Object)(nil)
+		if len(document.Data) > 0 {
+			data = api.shapeObject(document.Data[0])
+		}
+		encoded = objectDocumentJSON{document.Errors, links, included, document.Meta, document.JSONAPI, data}
+	default:
+		encoded = listDocumentJSON{api.shapeList(document.Data), document.Errors, links, included, document.Meta, document.JSONAPI}
+	}
+
+	err := json.NewEncoder(buffer).Encode(encoded)
+	if err != nil {
+		return err
+	}
+
+	// Encode terminates the document with a newline, which jsh doesn't send
+	buffer.Truncate(buffer.Len() - 1)
+	return nil
+}
+
+// The document types below marshal documents with their members in the order jsh
+// sends them, which depends on the mode of the document
+
+// listDocumentJSON marshals a list document, see encodeDocument
+type listDocumentJSON struct {
+	Data     interface{}          `json:"data"`
+	Errors   jsh.ErrorList        `json:"errors,omitempty"`
+	Links    map[string]*jsh.Link `json:"links,omitempty"`
+	Included interface{}          `json:"included,omitempty"`
+	Meta     interface{}          `json:"meta,omitempty"`
+	JSONAPI  struct {
+		Version string `json:"version"`
+	} `json:"jsonapi"`
+}
+
+// objectDocumentJSON marshals an object document, see encodeDocument
+type objectDocumentJSON struct {
+	Errors   jsh.ErrorList        `json:"errors,omitempty"`
+	Links    map[string]*jsh.Link `json:"links,omitempty"`
+	Included interface{}          `json:"included,omitempty"`
+	Meta     interface{}          `json:"meta,omitempty"`
+	JSONAPI  struct {
+		Version string `json:"version"`
+	} `json:"jsonapi"`
+	Data interface{} `json:"data"`
+}
+
+// errorDocumentJSON marshals an error document, see encodeDocument
+type errorDocumentJSON struct {
+	Errors   jsh.ErrorList        `json:"errors,omitempty"`
+	Links    map[string]*jsh.Link `json:"links,omitempty"`
+	Included interface{}          `json:"included,omitempty"`
+	Meta     interface{}          `json:"meta,omitempty"`
+	JSONAPI  struct {
+		Version string `json:"version"`
+	} `json:"jsonapi"`
+}
+
+// shapesLinkage reports whether the linkage of objects is sent differently than
+// jsh marshals it
+func (a *API) shapesLinkage(objects jsh.List) bool {
+	for _, object := range objects {
+		if a.shapesObject(object) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shapesObject reports whether the linkage of object is sent differently than jsh
+// marshals it
+func (a *API) shapesObject(object *jsh.Object) bool {
+	if a == nil || object == nil {
+		return false
+	}
+
+	for name, relationship := range object.Relationships {
+		if relationship == nil || relationship.Data == nil {
+			continue
+		}
+		if len(relationship.Data) == 0 || a.relationshipKind(object.Type, name) == ToOne {
+			return true
+		}
+	}
+
+	return false
+}
+
+// relationshipKind returns the kind of a relationship registered on the resource
+// of objectType, if any
+func (a *API) relationshipKind(objectType string, name string) Relationship {
+	res := a.Resources[objectType]
+	if res == nil {
+		return ""
+	}
+
+	return res.Relationships[name]
+}
+
+// shapeList returns objects as they are marshaled with shaped linkage, see
+// encodeDocument
+func (a *API) shapeList(objects jsh.List) interface{} {
+	if !a.shapesLinkage(objects) {
+		return objects
+	}
+
+	shaped := make([]interface{}, len(objects))
+	for index, object := range objects {
+		shaped[index] = a.shapeObject(object)
+	}
+
+	return shaped
+}
+
+// shapeObject returns object as it is marshaled with shaped linkage, see
+// encodeDocument
+func (a *API) shapeObject(object *jsh.Object) interface{} {
+	if !a.shapesObject(object) {
+		return object
+	}
+
+	relationships := make(map[string]interface{}, len(object.Relationships))
+	for name, relationship := range object.Relationships {
+		relationships[name] = a.shapeRelationship(object.Type, name, relationship)
+	}
+
+	return shapedObject{object.Type, object.ID, object.Attributes, object.Links, relationships}
+}
+
+// shapedObject marshals like jsh.Object, with shaped relationships
+type shapedObject struct {
+	Type          string                 `json:"type"`
+	ID            string                 `json:"id"`
+	Attributes    json.RawMessage        `json:"attributes,omitempty"`
+	Links         map[string]*jsh.Link   `json:"links,omitempty"`
+	Relationships map[string]interface{} `json:"relationships,omitempty"`
+}
+
+// shapeRelationship returns a relationship as it is marshaled with shaped linkage,
+// see encodeDocument
+func (a *API) shapeRelationship(objectType string, name string, relationship *jsh.Relationship) interface{} {
+	if relationship == nil || relationship.Data == nil {
+		return relationship
+	}
+
+	var data interface{} = relationship.Data
+	if a.relationshipKind(objectType, name) == ToOne {
+		var identifier *jsh.ResourceIdentifier
+		if len(relationship.Data) > 0 {
+			identifier = relationship.Data[0]
+		}
+		data = identifier
+	}
+
+	return shapedRelationship{relationship.Links, data, relationship.Meta}
+}
+
+// shapedRelationship marshals like jsh.Relationship, with shaped linkage
+type shapedRelationship struct {
+	Links *jsh.Links             `json:"links,omitempty"`
+	Data  interface{}            `json:"data"`
+	Meta  map[string]interface{} `json:"meta,omitempty"`
+}
@@ -0,0 +1,155 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIncludeLinkage(t *testing.T) {
+
+	Convey("Include Linkage Tests", t, func() {
+
+		object := func(id string, resourceType string) *jsh.Object {
+			created, _ := jsh.NewObject(id, resourceType, testObjAttrs)
+			return created
+		}
+
+		stored := object("3", "posts")
+		stored.Relationships["comments"] = &jsh.Relationship{
+			Data: jsh.ResourceLinkage{{Type: "comments", ID: "31"}},
+		}
+
+		posts := NewResource("posts")
+		posts.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			if id == "3" {
+				return stored, nil
+			}
+			return object(id, "posts"), nil
+		})
+		posts.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+			return jsh.List{object("1", "posts"), object("2", "posts")}, nil
+		})
+		posts.ToOne("author", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			if id == "2" {
+				return nil, jsh.NotFound("users", "")
+			}
+			return object("1", "users"), nil
+		})
+		posts.ToMany("comments", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			if id == "2" {
+				return jsh.List{}, nil
+			}
+			return jsh.List{object(id+"1", "comments"), object(id+"2", "comments")}, nil
+		})
+
+		comments := NewResource("comments")
+		comments.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return object(id, "comments"), nil
+		})
+		comments.ToOne("author", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return object(id[len(id)-1:], "users"), nil
+		})
+
+		api := New("")
+		api.Add(posts)
+		api.Add(comments)
+
+		type relationships map[string]struct {
+			Data compact
+		}
+		type resource struct {
+			Type          string
+			ID            string
+			Relationships relationships
+		}
+
+		get := func(url string) (*httptest.ResponseRecorder, []byte) {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+			return recorder, recorder.Body.Bytes()
+		}
+
+		Convey("should link a to-one relationship with a single identifier", func() {
+			recorder, body := get("/posts/1?include=author")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			doc := struct{ Data resource }{}
+			So(json.Unmarshal(body, &doc), ShouldBeNil)
+			So(string(doc.Data.Relationships["author"].Data), ShouldEqual, `{"type":"users","id":"1"}`)
+		})
+
+		Convey("should link a to-many relationship with an identifier array", func() {
+			recorder, body := get("/posts/1?include=comments")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			doc := struct{ Data resource }{}
+			So(json.Unmarshal(body, &doc), ShouldBeNil)
+			So(string(doc.Data.Relationships["comments"].Data), ShouldEqual,
+				`[{"type":"comments","id":"11"},{"type":"comments","id":"12"}]`)
+		})
+
+		Convey("should link empty relationships as null and an empty array", func() {
+			recorder, body := get("/posts?include=author,comments")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			doc := struct{ Data []resource }{}
+			So(json.Unmarshal(body, &doc), ShouldBeNil)
+			So(doc.Data, ShouldHaveLength, 2)
+			So(string(doc.Data[1].Relationships["author"].Data), ShouldEqual, "null")
+			So(string(doc.Data[1].Relationships["comments"].Data), ShouldEqual, "[]")
+		})
+
+		Convey("should link the relationships of included objects", func() {
+			recorder, body := get("/posts/1?include=comments.author")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			doc := struct{ Included []resource }{}
+			So(json.Unmarshal(body, &doc), ShouldBeNil)
+			So(doc.Included, ShouldHaveLength, 4)
+			So(doc.Included[0].Type, ShouldEqual, "comments")
+			So(string(doc.Included[0].Relationships["author"].Data), ShouldEqual, `{"type":"users","id":"1"}`)
+		})
+
+		Convey("should trust the linkage provided by the storage", func() {
+			recorder, body := get("/posts/3?include=comments")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			doc := struct{ Data resource }{}
+			So(json.Unmarshal(body, &doc), ShouldBeNil)
+			So(string(doc.Data.Relationships["comments"].Data), ShouldEqual, `[{"type":"comments","id":"31"}]`)
+		})
+
+		Convey("should not amend the objects of the storage", func() {
+			get("/posts/3?include=author")
+			So(stored.Relationships["author"], ShouldBeNil)
+		})
+
+		Convey("should not link relationships without include", func() {
+			recorder, body := get("/posts/1")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			doc := struct{ Data resource }{}
+			So(json.Unmarshal(body, &doc), ShouldBeNil)
+			So(doc.Data.Relationships, ShouldBeEmpty)
+		})
+	})
+}
+
+// compact holds a JSON value compacted, regardless of the indentation it was sent
+// with
+type compact string
+
+func (c *compact) UnmarshalJSON(content []byte) error {
+	buffer := &bytes.Buffer{}
+	err := json.Compact(buffer, content)
+	*c = compact(buffer.String())
+	return err
+}
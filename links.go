@@ -0,0 +1,194 @@
+package jshapi
+
+import (
+	"net/http"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+EmitSelfLinks adds a `links.self` member to the resource objects sent by the
+resources of the API, pointing at the canonical URL of each object, as well as a
+top-level `links.self` holding the request URL, query included. Objects already
+carrying a self link keep it, and objects of types not added to the API get none.
+Links are relative unless BaseURL is set or TrustProxies is enabled.
+
+Top-level links are serialized as specified by the default SendHandler only,
+custom senders receive them in a jsh.Document.
+*/
+func (a *API) EmitSelfLinks(enabled bool) {
+	a.checkRegistration("self links")
+
+	a.selfLinks = enabled
+}
+
+/*
+EmitRelationshipLinks adds `links.self` and `links.related` members, such as
+`/posts/1/relationships/comments` and `/posts/1/comments`, to the relationships
+of the objects of the resource type, wherever they are sent by the API. Only the
+relationships registered with ToOne or ToMany, and populated by the object, are
+linked, and only with the routes that are still registered, see Disable.
+Existing links are kept.
+*/
+func (res *Resource) EmitRelationshipLinks(enabled bool) {
+	res.checkRegistration("relationship links")
+
+	res.relationshipLinks = enabled
+}
+
+// withLinks returns the sendable with self and relationship links added, leaving
+// the objects of storage untouched
+func (res *Resource) withLinks(r *http.Request, sendable jsh.Sendable) jsh.Sendable {
+	selfLinks := res.api != nil && res.api.selfLinks
+	if !selfLinks && !res.relationshipLinksEnabled() {
+		return sendable
+	}
+
+	base := res.linkBase(r)
+
+	var document *jsh.Document
+	switch typed := sendable.(type) {
+	case *jsh.Object:
+		if !selfLinks {
+			return res.linkObject(r, base, typed, false)
+		}
+		document = buildDocument(validationRequest(r), res.linkObject(r, base, typed, true))
+	case jsh.List:
+		if !selfLinks {
+			return res.linkList(r, base, typed, false)
+		}
+		document = buildDocument(validationRequest(r), res.linkList(r, base, typed, true))
+	case *jsh.Document:
+		if typed.HasErrors() {
+			return sendable
+		}
+
+		linked := *typed
+		linked.Data = res.linkList(r, base, typed.Data, selfLinks)
+		linked.Included = res.linkList(r, base, typed.Included, selfLinks)
+		document = &linked
+	default:
+		return sendable
+	}
+
+	if selfLinks && !document.HasErrors() && document.Links == nil {
+		document.Links = &jsh.Link{HREF: base + r.URL.RequestURI()}
+	}
+
+	return document
+}
+
+// relationshipLinksEnabled reports whether any resource the objects sent by res
+// may belong to emits relationship links
+func (res *Resource) relationshipLinksEnabled() bool {
+	if res.api == nil {
+		return res.relationshipLinks
+	}
+
+	for _, resource := range res.api.Resources {
+		if resource.relationshipLinks {
+			return true
+		}
+	}
+
+	return false
+}
+
+// linkTarget is the resource serving objects of a type, nil if unknown
+func (res *Resource) linkTarget(objectType string) *Resource {
+	if res.api != nil {
+		return res.api.Resources[objectType]
+	}
+	if objectType == res.Type {
+		return res
+	}
+
+	return nil
+}
+
+// linkList returns a copy of list with links added to its objects
+func (res *Resource) linkList(r *http.Request, base string, list jsh.List, selfLinks bool) jsh.List {
+	if list == nil {
+		return nil
+	}
+
+	linked := make(jsh.List, len(list))
+	for index, object := range list {
+		linked[index] = res.linkObject(r, base, object, selfLinks)
+	}
+
+	return linked
+}
+
+// linkObject returns a copy of object with a self link, unless it has one already,
+// and relationship links if its resource emits them. Objects of types unknown to
+// the API are returned as is.
+func (res *Resource) linkObject(r *http.Request, base string, object *jsh.Object, selfLinks bool) *jsh.Object {
+	if object == nil || object.ID == "" {
+		return object
+	}
+
+	target := res.linkTarget(object.Type)
+	if target == nil {
+		return object
+	}
+
+	objectPath, scoped := target.requestObjectPath(r, object.ID)
+	if !scoped {
+		return object
+	}
+
+	linked := *object
+	objectURL := base + objectPath
+
+	if selfLinks && object.Links["self"] == nil {
+		linked.Links = make(map[string]*jsh.Link, len(object.Links)+1)
+		for name, link := range object.Links {
+			linked.Links[name] = link
+		}
+		linked.Links["self"] = &jsh.Link{HREF: objectURL}
+	}
+
+	if target.relationshipLinks {
+		linked.Relationships = target.linkRelationships(objectURL, object.Relationships)
+	}
+
+	return &linked
+}
+
+// linkRelationships returns a copy of the relationships of an object with the
+// links of the registered and populated ones
+func (res *Resource) linkRelationships(objectURL string, relationships map[string]*jsh.Relationship) map[string]*jsh.Relationship {
+	linked := make(map[string]*jsh.Relationship, len(relationships))
+	for name, relationship := range relationships {
+		_, registered := res.Relationships[name]
+		if !registered || relationship == nil {
+			linked[name] = relationship
+			continue
+		}
+
+		links := jsh.Links{}
+		if relationship.Links != nil {
+			links = *relationship.Links
+		}
+		if links.Self == nil && res.hasRoute(get, res.idRoute()+"/relationships/"+name) {
+			links.Self = &jsh.Link{HREF: objectURL + "/relationships/" + name}
+		}
+		if links.Related == nil && res.hasRoute(get, res.idRoute()+"/"+name) {
+			links.Related = &jsh.Link{HREF: objectURL + "/" + name}
+		}
+
+		linkedRelationship := *relationship
+		if links.Self != nil || links.Related != nil {
+			linkedRelationship.Links = &links
+		}
+		linked[name] = &linkedRelationship
+	}
+
+	return linked
+}
+
+// hasRoute reports whether a route of the resource is registered, and not disabled
+func (res *Resource) hasRoute(method string, route string) bool {
+	return containsString(res.Routes, method+" - /"+res.CollectionPath()+route)
+}
@@ -0,0 +1,138 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSelfLinks(t *testing.T) {
+
+	Convey("Self Links Tests", t, func() {
+
+		mock := &MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs, ListCount: 2}
+		resource := NewResource(testResourceType)
+		resource.List(mock.List)
+		linked, _ := jsh.NewObject("7", testResourceType, testObjAttrs)
+		resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			if id != "7" {
+				return jsh.NewObject(id, testResourceType, testObjAttrs)
+			}
+			linked.Links["self"] = &jsh.Link{HREF: "/custom/7"}
+			return linked, nil
+		})
+
+		api := New("api")
+		api.Add(resource)
+
+		type links struct {
+			Self struct {
+				HREF string `json:"href"`
+			} `json:"self"`
+		}
+		type document struct {
+			Data  json.RawMessage `json:"data"`
+			Links *links          `json:"links"`
+		}
+
+		get := func(url string, headers map[string]string) (*httptest.ResponseRecorder, document) {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest("GET", url, nil)
+			for name, value := range headers {
+				request.Header.Set(name, value)
+			}
+			api.ServeHTTP(recorder, request)
+
+			doc := document{}
+			json.Unmarshal(recorder.Body.Bytes(), &doc)
+			return recorder, doc
+		}
+
+		Convey("should not emit links by default", func() {
+			recorder, doc := get("/api/bars/1", nil)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(doc.Links, ShouldBeNil)
+			So(string(doc.Data), ShouldNotContainSubstring, `"links"`)
+		})
+
+		Convey("->EmitSelfLinks()", func() {
+			api.EmitSelfLinks(true)
+
+			Convey("should link single objects and the document", func() {
+				recorder, doc := get("/api/bars/1", nil)
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(doc.Links, ShouldNotBeNil)
+				So(doc.Links.Self.HREF, ShouldEqual, "/api/bars/1")
+
+				object := struct{ Links links }{}
+				So(json.Unmarshal(doc.Data, &object), ShouldBeNil)
+				So(object.Links.Self.HREF, ShouldEqual, "/api/bars/1")
+			})
+
+			Convey("should link listed objects and keep the query", func() {
+				_, doc := get("/api/bars?page=2&sort=-foo", nil)
+				So(doc.Links.Self.HREF, ShouldEqual, "/api/bars?page=2&sort=-foo")
+
+				objects := []struct {
+					ID    string
+					Links links
+				}{}
+				So(json.Unmarshal(doc.Data, &objects), ShouldBeNil)
+				So(objects, ShouldHaveLength, 2)
+				for _, object := range objects {
+					So(object.Links.Self.HREF, ShouldEqual, "/api/bars/"+object.ID)
+				}
+			})
+
+			Convey("should keep existing links", func() {
+				_, doc := get("/api/bars/7", nil)
+
+				object := struct{ Links links }{}
+				So(json.Unmarshal(doc.Data, &object), ShouldBeNil)
+				So(object.Links.Self.HREF, ShouldEqual, "/custom/7")
+			})
+
+			Convey("should not link errors", func() {
+				recorder := httptest.NewRecorder()
+				api.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/api/bars", nil))
+				So(recorder.Code, ShouldEqual, http.StatusMethodNotAllowed)
+				So(recorder.Body.String(), ShouldNotContainSubstring, `"links"`)
+			})
+
+			Convey("should prefix links with the base URL", func() {
+				api.BaseURL("https://example.com/")
+
+				_, doc := get("/api/bars/1", map[string]string{"X-Forwarded-Host": "proxy.example.com"})
+				So(doc.Links.Self.HREF, ShouldEqual, "https://example.com/api/bars/1")
+			})
+
+			Convey("should ignore proxy headers unless trusted", func() {
+				_, doc := get("/api/bars/1", map[string]string{"X-Forwarded-Host": "proxy.example.com"})
+				So(doc.Links.Self.HREF, ShouldEqual, "/api/bars/1")
+			})
+
+			Convey("->TrustProxyHeaders()", func() {
+				api.TrustProxyHeaders(true)
+
+				Convey("should use the forwarded scheme and host", func() {
+					_, doc := get("/api/bars/1", map[string]string{
+						"X-Forwarded-Proto": "https, http",
+						"X-Forwarded-Host":  "proxy.example.com:8443, internal",
+					})
+					So(doc.Links.Self.HREF, ShouldEqual, "https://proxy.example.com:8443/api/bars/1")
+				})
+
+				Convey("should fall back to the request host", func() {
+					_, doc := get("/api/bars/1", nil)
+					So(doc.Links.Self.HREF, ShouldEqual, "http://example.com/api/bars/1")
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,148 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+const (
+	// DefaultMaxWait is the longest wait of long polls when their options leave it
+	// unset
+	DefaultMaxWait = 30 * time.Second
+	// DefaultMaxWaiting is the number of long polls allowed to wait at once when
+	// their options leave it unset
+	DefaultMaxWaiting = 100
+)
+
+// LongPollOptions configures the long polls of a resource, see LongPoll
+type LongPollOptions struct {
+	// MaxWait caps the wait requested by clients, defaults to DefaultMaxWait
+	MaxWait time.Duration
+	// MaxWaiting is the number of requests allowed to wait at once, those above
+	// being answered with a 503, defaults to DefaultMaxWaiting
+	MaxWaiting int
+}
+
+// longPoll holds the long poll settings of a resource and its waiting requests
+type longPoll struct {
+	watcher store.Watcher
+	opts    LongPollOptions
+	waiting atomic.Int64
+}
+
+/*
+LongPoll lets clients of `GET /resource/:id` wait for the object to change rather
+than polling it, by sending the ETag of the version they hold along with the time
+to wait for a new one:
+
+	GET /config/1?wait=30s
+	If-None-Match: "v42"
+
+	config.LongPoll(db, jshapi.LongPollOptions{MaxWait: time.Minute})
+
+The request blocks in watcher.Wait until the version of the object differs from
+the ETag, then fetches and sends the object as usual. It is answered with a 304
+Not Modified when the wait ends first, the wait being capped by opts.MaxWait, or
+the API starts draining, see API.Shutdown. Requests above opts.MaxWaiting are
+answered with a 503 and a Retry-After header. Requests without both the wait
+parameter and an If-None-Match header don't wait.
+*/
+func (res *Resource) LongPoll(watcher store.Watcher, opts LongPollOptions) {
+	defer res.record(func(clone *Resource) { clone.LongPoll(watcher, opts) })()
+
+	res.checkRegistration("a setting")
+
+	if opts.MaxWait <= 0 {
+		opts.MaxWait = DefaultMaxWait
+	}
+	if opts.MaxWaiting <= 0 {
+		opts.MaxWaiting = DefaultMaxWaiting
+	}
+
+	res.longPoll = &longPoll{watcher: watcher, opts: opts}
+}
+
+/*
+awaitChange blocks a long poll for the object identified by id until it changes,
+returning whether the object must then be sent. When it returns false, the
+request is already answered.
+*/
+func (res *Resource) awaitChange(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) bool {
+	poll := res.longPoll
+	requested := r.URL.Query().Get("wait")
+	etag := r.Header.Get("If-None-Match")
+	if poll == nil || requested == "" || etag == "" || etag == "*" {
+		return true
+	}
+
+	wait, parseErr := time.ParseDuration(requested)
+	if parseErr != nil || wait <= 0 {
+		res.send(ctx, w, r, &jsh.Error{
+			Title:  "Bad Request",
+			Detail: fmt.Sprintf("Invalid wait '%s', expected a positive duration such as 30s", requested),
+			Status: http.StatusBadRequest,
+		})
+		return false
+	}
+	if wait > poll.opts.MaxWait {
+		wait = poll.opts.MaxWait
+	}
+
+	defer poll.waiting.Add(-1)
+	if poll.waiting.Add(1) > int64(poll.opts.MaxWaiting) {
+		w.Header().Set("Retry-After", "1")
+		res.send(ctx, w, r, &jsh.Error{
+			Title:  "Service Unavailable",
+			Detail: "Too many requests are waiting for changes, please retry",
+			Status: http.StatusServiceUnavailable,
+		})
+		return false
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	var drained chan struct{}
+	if res.api != nil {
+		drained = res.api.drained
+	}
+	go func() {
+		select {
+		case <-drained:
+			cancel()
+		case <-waitCtx.Done():
+		}
+	}()
+
+	err := poll.watcher.Wait(waitCtx, id, etagVersion(etag))
+	switch {
+	case clientGone(ctx):
+		return false
+	case err == nil:
+		return true
+	case waitCtx.Err() != nil:
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return false
+	default:
+		res.send(ctx, w, r, jsh.ISE(fmt.Sprintf("Unable to wait for changes of '%s': %s", id, err.Error())))
+		return false
+	}
+}
+
+// etagVersion returns the version of an If-None-Match header, the first ETag it
+// lists without its quotes and weakness indicator
+func etagVersion(header string) string {
+	etag := strings.TrimSpace(strings.Split(header, ",")[0])
+	etag = strings.TrimPrefix(etag, "W/")
+
+	return strings.Trim(etag, `"`)
+}
@@ -0,0 +1,138 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// versionWatcher holds the versions of objects and wakes up the waits on them
+type versionWatcher struct {
+	sync.Mutex
+	versions map[string]string
+	changed  chan struct{}
+}
+
+func newVersionWatcher() *versionWatcher {
+	return &versionWatcher{versions: map[string]string{"1": "v1"}, changed: make(chan struct{})}
+}
+
+func (v *versionWatcher) set(id string, version string) {
+	v.Lock()
+	defer v.Unlock()
+	v.versions[id] = version
+	close(v.changed)
+	v.changed = make(chan struct{})
+}
+
+func (v *versionWatcher) Wait(ctx context.Context, id string, version string) error {
+	for {
+		v.Lock()
+		current, changed := v.versions[id], v.changed
+		v.Unlock()
+		if current != version {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func TestLongPoll(t *testing.T) {
+
+	Convey("Long Poll Tests", t, func() {
+
+		watcher := newVersionWatcher()
+		resource := NewResource("configs")
+		resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			watcher.Lock()
+			defer watcher.Unlock()
+			return jsh.NewObject(id, "configs", map[string]string{"version": watcher.versions[id]})
+		})
+		resource.LongPoll(watcher, LongPollOptions{MaxWait: 50 * time.Millisecond, MaxWaiting: 1})
+
+		api := New("")
+		api.Add(resource)
+
+		send := func(url string, etag string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest("GET", url, nil)
+			if etag != "" {
+				request.Header.Set("If-None-Match", etag)
+			}
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		waitFor := func(waiting int64) {
+			for resource.longPoll.waiting.Load() != waiting {
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		Convey("->LongPoll()", func() {
+
+			Convey("should answer at once without a wait or an ETag", func() {
+				So(send("/configs/1", "").Code, ShouldEqual, http.StatusOK)
+				So(send("/configs/1", `"v1"`).Code, ShouldEqual, http.StatusOK)
+				So(send("/configs/1?wait=1h", "").Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("should send the object once it changes", func() {
+				done := make(chan *httptest.ResponseRecorder)
+				go func() { done <- send("/configs/1?wait=1h", `W/"v1"`) }()
+				waitFor(1)
+
+				watcher.set("1", "v2")
+				recorder := <-done
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"version": "v2"`)
+			})
+
+			Convey("should answer a 304 once the capped wait ends", func() {
+				recorder := send("/configs/1?wait=1h", `"v1"`)
+				So(recorder.Code, ShouldEqual, http.StatusNotModified)
+				So(recorder.Header().Get("ETag"), ShouldEqual, `"v1"`)
+				So(recorder.Body.Len(), ShouldEqual, 0)
+			})
+
+			Convey("should reject invalid waits", func() {
+				So(send("/configs/1?wait=soon", `"v1"`).Code, ShouldEqual, http.StatusBadRequest)
+				So(send("/configs/1?wait=-1s", `"v1"`).Code, ShouldEqual, http.StatusBadRequest)
+			})
+
+			Convey("should turn away requests beyond MaxWaiting", func() {
+				done := make(chan int)
+				go func() { done <- send("/configs/1?wait=1h", `"v1"`).Code }()
+				waitFor(1)
+
+				recorder := send("/configs/1?wait=1h", `"v1"`)
+				So(recorder.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(recorder.Header().Get("Retry-After"), ShouldEqual, "1")
+				So(<-done, ShouldEqual, http.StatusNotModified)
+			})
+
+			Convey("should answer a 304 when the API drains", func() {
+				resource.longPoll.opts.MaxWait = time.Hour
+
+				done := make(chan int)
+				go func() { done <- send("/configs/1?wait=1h", `"v1"`).Code }()
+				waitFor(1)
+
+				So(api.Shutdown(context.Background()), ShouldBeNil)
+				So(<-done, ShouldEqual, http.StatusNotModified)
+			})
+		})
+	})
+}
@@ -0,0 +1,211 @@
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+// ErrNotFound is returned by typed storage for objects that don't exist, it is
+// answered with a 404
+var ErrNotFound = errors.New("not found")
+
+/*
+CRUD is the storage of a resource written in terms of the struct type of a
+mapper: values are pointers to structs of that type, lists slices of structs or of
+pointers to them. Update receives the names of the struct fields set by the
+request, the others holding zero values. Errors are converted by the adapters,
+see Mapper.Get.
+*/
+type CRUD interface {
+	Save(ctx context.Context, value interface{}) (interface{}, error)
+	Get(ctx context.Context, id string) (interface{}, error)
+	List(ctx context.Context) (interface{}, error)
+	Update(ctx context.Context, id string, value interface{}, fields []string) (interface{}, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// CRUD lifts typed storage into a store.CRUD
+func (m *Mapper) CRUD(storage CRUD) store.CRUD {
+	return &crud{
+		save:   m.Save(storage.Save),
+		get:    m.Get(storage.Get),
+		list:   m.List(storage.List),
+		update: m.Update(storage.Update),
+		delete: m.Delete(storage.Delete),
+	}
+}
+
+// crud implements store.CRUD with lifted storage functions
+type crud struct {
+	save   store.Save
+	get    store.Get
+	list   store.List
+	update store.Update
+	delete store.Delete
+}
+
+func (c *crud) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	return c.save(ctx, object)
+}
+
+func (c *crud) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	return c.get(ctx, id)
+}
+
+func (c *crud) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	return c.list(ctx)
+}
+
+func (c *crud) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	return c.update(ctx, object)
+}
+
+func (c *crud) Delete(ctx context.Context, id string) jsh.ErrorType {
+	return c.delete(ctx, id)
+}
+
+/*
+Get lifts a typed get into a store.Get. Errors of typed storage are converted:
+ErrNotFound, as well as a nil value, is answered with a 404, jsh errors are
+returned as they are, and other errors as a 500.
+*/
+func (m *Mapper) Get(get func(ctx context.Context, id string) (interface{}, error)) store.Get {
+	return func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		value, err := get(ctx, id)
+		if err == nil && isNil(value) {
+			err = ErrNotFound
+		}
+		if err != nil {
+			return nil, m.storageError(err, id)
+		}
+
+		return m.ToObject(id, value)
+	}
+}
+
+// List lifts a typed list into a store.List, see Get for errors
+func (m *Mapper) List(list func(ctx context.Context) (interface{}, error)) store.List {
+	return func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+		values, err := list(ctx)
+		if err != nil {
+			return nil, m.storageError(err, "")
+		}
+
+		return m.toList(values)
+	}
+}
+
+// Save lifts a typed save into a store.Save, the saved value must have its id
+// field set, see Get for errors
+func (m *Mapper) Save(save func(ctx context.Context, value interface{}) (interface{}, error)) store.Save {
+	return func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+		value, _, errs := m.decode(object)
+		if len(errs) > 0 {
+			return nil, errs
+		}
+
+		saved, err := save(ctx, value.Interface())
+		if err != nil {
+			return nil, m.storageError(err, object.ID)
+		}
+
+		return m.savedObject("", saved)
+	}
+}
+
+// Update lifts a typed update into a store.Update, see Get for errors
+func (m *Mapper) Update(update func(ctx context.Context, id string, value interface{}, fields []string) (interface{}, error)) store.Update {
+	return func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+		value, fields, errs := m.decode(object)
+		if len(errs) > 0 {
+			return nil, errs
+		}
+
+		updated, err := update(ctx, object.ID, value.Interface(), fields)
+		if err == nil && isNil(updated) {
+			err = ErrNotFound
+		}
+		if err != nil {
+			return nil, m.storageError(err, object.ID)
+		}
+
+		return m.savedObject(object.ID, updated)
+	}
+}
+
+// Delete lifts a typed delete into a store.Delete, see Get for errors
+func (m *Mapper) Delete(delete func(ctx context.Context, id string) error) store.Delete {
+	return func(ctx context.Context, id string) jsh.ErrorType {
+		err := delete(ctx, id)
+		if err != nil {
+			return m.storageError(err, id)
+		}
+
+		return nil
+	}
+}
+
+// savedObject returns the object of a value returned by a write, requiring an id
+func (m *Mapper) savedObject(id string, value interface{}) (*jsh.Object, jsh.ErrorType) {
+	object, err := m.ToObject(id, value)
+	if err != nil {
+		return nil, err
+	}
+	if object.ID == "" {
+		return nil, jsh.ISE(fmt.Sprintf("Storage of '%s' returned an object without id", m.resourceType))
+	}
+
+	return object, nil
+}
+
+// toList returns the objects of a slice of values
+func (m *Mapper) toList(values interface{}) (jsh.List, jsh.ErrorType) {
+	slice := reflect.ValueOf(values)
+	if values == nil {
+		return jsh.List{}, nil
+	}
+	if slice.Kind() != reflect.Slice {
+		return nil, jsh.ISE(fmt.Sprintf("Mapper of '%s' expects a slice, got %T", m.resourceType, values))
+	}
+
+	list := make(jsh.List, 0, slice.Len())
+	for index := 0; index < slice.Len(); index++ {
+		object, err := m.ToObject("", slice.Index(index).Interface())
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, object)
+	}
+
+	return list, nil
+}
+
+// storageError converts an error of typed storage
+func (m *Mapper) storageError(err error, id string) jsh.ErrorType {
+	if err == ErrNotFound {
+		return jsh.NotFound(m.resourceType, id)
+	}
+
+	jshErr, isJSH := err.(jsh.ErrorType)
+	if isJSH {
+		return jshErr
+	}
+
+	return jsh.ISE(fmt.Sprintf("Storage of '%s' failed: %s", m.resourceType, err))
+}
+
+// isNil reports whether value is nil or a nil pointer
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	reflected := reflect.ValueOf(value)
+	return reflected.Kind() == reflect.Ptr && reflected.IsNil()
+}
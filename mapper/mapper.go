@@ -0,0 +1,466 @@
+/*
+Package mapper converts between Go structs and resource objects, so that storage
+can be written in terms of structs:
+
+	type Post struct {
+		ID        string   `jsonapi:"id"`
+		Title     string   `jsonapi:"attr,title"`
+		CreatedAt string   `jsonapi:"attr,created-at,readonly"`
+		Author    string   `jsonapi:"relation,author,people"`
+		Tags      []string `jsonapi:"relation,tags,tags"`
+	}
+
+	posts := mapper.New("posts", Post{})
+	api.Add(jshapi.NewCRUDResource("posts", posts.CRUD(db)))
+
+Fields are mapped by their jsonapi tag only, untagged fields are left out:
+
+	jsonapi:"id"                        the string id of the object
+	jsonapi:"attr,<name>"               an attribute, marshaled as JSON
+	jsonapi:"relation,<name>,<type>"    a relationship to objects of type, as a
+	                                    string id for to-one relationships or a
+	                                    string slice of ids for to-many ones
+
+Attributes may add "omitempty" to be left out when zero, attributes and
+relationships "readonly" to be sent but rejected when written by clients. Mappers
+describe the attributes and relationships of schema documents, see
+jshapi.Resource.DescribeWith.
+*/
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api"
+)
+
+// Mapper converts between the values of a struct type and the objects of a
+// resource type
+type Mapper struct {
+	resourceType string
+	structType   reflect.Type
+	// id is the index of the id field, -1 without one
+	id            int
+	attributes    []*field
+	relationships []*field
+}
+
+// field is a struct field mapped to an attribute or a relationship
+type field struct {
+	index int
+	// goName is the name of the struct field, name the member name
+	goName string
+	name   string
+	// relatedType is the type of the related objects of relationships, toMany
+	// set for slices of ids
+	relatedType string
+	toMany      bool
+	readOnly    bool
+	omitEmpty   bool
+}
+
+/*
+New creates a mapper between objects of resourceType and the struct type of
+prototype, a struct or a pointer to one. Panics on prototypes of other types and
+on invalid tags.
+*/
+func New(resourceType string, prototype interface{}) *Mapper {
+	structType := reflect.TypeOf(prototype)
+	if structType != nil && structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType == nil || structType.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("jshapi: mapper of '%s' requires a struct, got %T", resourceType, prototype))
+	}
+
+	m := &Mapper{resourceType: resourceType, structType: structType, id: -1}
+	for index := 0; index < structType.NumField(); index++ {
+		structField := structType.Field(index)
+		tag, tagged := structField.Tag.Lookup("jsonapi")
+		if !tagged || tag == "-" {
+			continue
+		}
+
+		if structField.PkgPath != "" {
+			panic(fmt.Sprintf("jshapi: mapper of '%s' can't map unexported field '%s'", resourceType, structField.Name))
+		}
+
+		m.addField(index, structField, tag)
+	}
+
+	return m
+}
+
+// addField maps a tagged struct field, panicking on invalid tags
+func (m *Mapper) addField(index int, structField reflect.StructField, tag string) {
+	invalid := func(reason string) {
+		panic(fmt.Sprintf("jshapi: invalid jsonapi tag of field '%s' of '%s': %s", structField.Name, m.resourceType, reason))
+	}
+
+	parts := strings.Split(tag, ",")
+	mapped := &field{index: index, goName: structField.Name}
+
+	switch parts[0] {
+	case "id":
+		if structField.Type.Kind() != reflect.String {
+			invalid("ids must be strings")
+		}
+		if m.id >= 0 {
+			invalid("ids must be mapped once")
+		}
+		m.id = index
+		return
+
+	case "attr":
+		if len(parts) < 2 || parts[1] == "" {
+			invalid("attributes require a name")
+		}
+		mapped.name = parts[1]
+		parts = parts[2:]
+		m.attributes = append(m.attributes, mapped)
+
+	case "relation":
+		if len(parts) < 3 || parts[1] == "" || parts[2] == "" {
+			invalid("relationships require a name and a type")
+		}
+		mapped.name, mapped.relatedType = parts[1], parts[2]
+		parts = parts[3:]
+
+		switch {
+		case structField.Type.Kind() == reflect.String:
+		case structField.Type.Kind() == reflect.Slice && structField.Type.Elem().Kind() == reflect.String:
+			mapped.toMany = true
+		default:
+			invalid("relationships must be strings or slices of strings")
+		}
+		m.relationships = append(m.relationships, mapped)
+
+	default:
+		invalid(fmt.Sprintf("unknown kind '%s'", parts[0]))
+	}
+
+	for _, option := range parts {
+		switch {
+		case option == "readonly":
+			mapped.readOnly = true
+		case option == "omitempty" && mapped.relatedType == "":
+			mapped.omitEmpty = true
+		default:
+			invalid(fmt.Sprintf("unknown option '%s'", option))
+		}
+	}
+}
+
+// Type returns the resource type of the mapper
+func (m *Mapper) Type() string {
+	return m.resourceType
+}
+
+// Attributes returns the names of the attributes mapped, in field order
+func (m *Mapper) Attributes() []string {
+	names := make([]string, len(m.attributes))
+	for index, attribute := range m.attributes {
+		names[index] = attribute.name
+	}
+
+	return names
+}
+
+/*
+DescribeSchema describes the mapped attributes, with the JSON types of their fields,
+and relationships, implementing jshapi.SchemaDescriber:
+
+	res.DescribeWith(posts)
+*/
+func (m *Mapper) DescribeSchema() ([]jshapi.AttributeSchema, []jshapi.RelationshipSchema) {
+	attributes := []jshapi.AttributeSchema{}
+	for _, attribute := range m.attributes {
+		attributes = append(attributes, jshapi.AttributeSchema{
+			Name:     attribute.name,
+			Type:     jsonType(m.structType.Field(attribute.index).Type),
+			ReadOnly: attribute.readOnly,
+		})
+	}
+
+	relationships := []jshapi.RelationshipSchema{}
+	for _, relationship := range m.relationships {
+		cardinality := jshapi.CardinalityToOne
+		if relationship.toMany {
+			cardinality = jshapi.CardinalityToMany
+		}
+
+		relationships = append(relationships, jshapi.RelationshipSchema{
+			Name:        relationship.name,
+			RelatedType: relationship.relatedType,
+			Cardinality: cardinality,
+			Mutable:     !relationship.readOnly,
+		})
+	}
+
+	return attributes, relationships
+}
+
+// timeType is marshaled as a string despite being a struct
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonType returns the JSON type values of a Go type are marshaled to, empty when
+// it can't be told, such as for interfaces or custom marshalers
+func jsonType(goType reflect.Type) string {
+	for goType.Kind() == reflect.Ptr {
+		goType = goType.Elem()
+	}
+	if goType == timeType {
+		return "string"
+	}
+	if goType.Implements(marshalerType) || reflect.PtrTo(goType).Implements(marshalerType) {
+		return ""
+	}
+
+	switch goType.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		// byte slices are marshaled as base64 strings
+		if goType.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		return "array"
+	case reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+
+	return ""
+}
+
+// marshalerType is the type of the values marshaling themselves
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+/*
+ToObject builds the object of value, a struct of the mapper type or a pointer to
+one, identified by id or, when empty, by the id field of value. Attributes are in
+field order.
+*/
+func (m *Mapper) ToObject(id string, value interface{}) (*jsh.Object, jsh.ErrorType) {
+	structValue, err := m.structValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if id == "" && m.id >= 0 {
+		id = structValue.Field(m.id).String()
+	}
+
+	attributes := &bytes.Buffer{}
+	attributes.WriteByte('{')
+	for _, attribute := range m.attributes {
+		fieldValue := structValue.Field(attribute.index)
+		if attribute.omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+
+		marshaled, marshalErr := json.Marshal(fieldValue.Interface())
+		if marshalErr != nil {
+			return nil, jsh.ISE(fmt.Sprintf("Unable to marshal attribute '%s' of '%s': %s", attribute.name, m.resourceType, marshalErr))
+		}
+
+		if attributes.Len() > 1 {
+			attributes.WriteByte(',')
+		}
+		name, _ := json.Marshal(attribute.name)
+		attributes.Write(name)
+		attributes.WriteByte(':')
+		attributes.Write(marshaled)
+	}
+	attributes.WriteByte('}')
+
+	object := &jsh.Object{
+		ID:            id,
+		Type:          m.resourceType,
+		Attributes:    attributes.Bytes(),
+		Links:         map[string]*jsh.Link{},
+		Relationships: map[string]*jsh.Relationship{},
+	}
+
+	for _, relationship := range m.relationships {
+		fieldValue := structValue.Field(relationship.index)
+
+		ids := []string{fieldValue.String()}
+		if relationship.toMany {
+			ids = fieldValue.Interface().([]string)
+		}
+
+		linkage := jsh.ResourceLinkage{}
+		for _, relatedID := range ids {
+			if relatedID != "" {
+				linkage = append(linkage, &jsh.ResourceIdentifier{Type: relationship.relatedType, ID: relatedID})
+			}
+		}
+		object.Relationships[relationship.name] = &jsh.Relationship{Data: linkage}
+	}
+
+	return object, nil
+}
+
+/*
+FromObject returns a pointer to a new struct of the mapper type holding the id,
+attributes and relationships of object. Objects of other types are rejected with
+a 409, and invalid or read-only members with a 400 pointing at each. Members the
+struct doesn't map are ignored.
+*/
+func (m *Mapper) FromObject(object *jsh.Object) (interface{}, jsh.ErrorType) {
+	value, _, errs := m.decode(object)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return value.Interface(), nil
+}
+
+/*
+decode returns a pointer to a new struct holding the members of object, along with
+the names of the struct fields they set.
+*/
+func (m *Mapper) decode(object *jsh.Object) (reflect.Value, []string, jsh.ErrorList) {
+	value := reflect.New(m.structType)
+	var set []string
+	var errs jsh.ErrorList
+
+	if object == nil {
+		return value, set, append(errs, memberError("Bad Request", "Missing resource object", "/data", http.StatusBadRequest))
+	}
+
+	if object.Type != m.resourceType {
+		detail := fmt.Sprintf("Expected object type '%s', got '%s'", m.resourceType, object.Type)
+		return value, set, append(errs, memberError("Conflict", detail, "/data/type", http.StatusConflict))
+	}
+
+	structValue := value.Elem()
+	if m.id >= 0 && object.ID != "" {
+		structValue.Field(m.id).SetString(object.ID)
+		set = append(set, m.structType.Field(m.id).Name)
+	}
+
+	attributes := map[string]json.RawMessage{}
+	if len(bytes.TrimSpace(object.Attributes)) > 0 {
+		err := json.Unmarshal(object.Attributes, &attributes)
+		if err != nil {
+			return value, set, append(errs, memberError("Bad Request", "Member 'attributes' must be an object", "/data/attributes", http.StatusBadRequest))
+		}
+	}
+
+	for _, attribute := range m.attributes {
+		raw, present := attributes[attribute.name]
+		if !present {
+			continue
+		}
+
+		pointer := "/data/attributes/" + escapePointer(attribute.name)
+		if attribute.readOnly {
+			errs = append(errs, memberError("Bad Request", fmt.Sprintf("Attribute '%s' is read-only", attribute.name), pointer, http.StatusBadRequest))
+			continue
+		}
+
+		target := reflect.New(m.structType.Field(attribute.index).Type)
+		err := json.Unmarshal(raw, target.Interface())
+		if err != nil {
+			detail := fmt.Sprintf("Attribute '%s' is invalid: %s", attribute.name, err)
+			errs = append(errs, memberError("Bad Request", detail, pointer, http.StatusBadRequest))
+			continue
+		}
+
+		structValue.Field(attribute.index).Set(target.Elem())
+		set = append(set, attribute.goName)
+	}
+
+	for _, relationship := range m.relationships {
+		related := object.Relationships[relationship.name]
+		if related == nil {
+			continue
+		}
+
+		pointer := "/data/relationships/" + escapePointer(relationship.name)
+		relationshipErr := m.relationshipError(relationship, related.Data)
+		if relationshipErr != "" {
+			errs = append(errs, memberError("Bad Request", relationshipErr, pointer, http.StatusBadRequest))
+			continue
+		}
+
+		ids := []string{}
+		for _, identifier := range related.Data {
+			ids = append(ids, identifier.ID)
+		}
+
+		fieldValue := structValue.Field(relationship.index)
+		if relationship.toMany {
+			fieldValue.Set(reflect.ValueOf(ids))
+		} else if len(ids) > 0 {
+			fieldValue.SetString(ids[0])
+		}
+		set = append(set, relationship.goName)
+	}
+
+	return value, set, errs
+}
+
+// relationshipError returns why the linkage written to a relationship is invalid,
+// if it is
+func (m *Mapper) relationshipError(relationship *field, linkage jsh.ResourceLinkage) string {
+	if relationship.readOnly {
+		return fmt.Sprintf("Relationship '%s' is read-only", relationship.name)
+	}
+	if !relationship.toMany && len(linkage) > 1 {
+		return fmt.Sprintf("Relationship '%s' is to-one", relationship.name)
+	}
+
+	for _, identifier := range linkage {
+		if identifier == nil || identifier.Type != relationship.relatedType {
+			return fmt.Sprintf("Relationship '%s' links objects of type '%s'", relationship.name, relationship.relatedType)
+		}
+	}
+
+	return ""
+}
+
+// structValue returns the struct of value, a struct of the mapper type or a
+// non-nil pointer to one
+func (m *Mapper) structValue(value interface{}) (reflect.Value, jsh.ErrorType) {
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() == reflect.Ptr && !reflected.IsNil() {
+		reflected = reflected.Elem()
+	}
+
+	if !reflected.IsValid() || reflected.Type() != m.structType {
+		return reflected, jsh.ISE(fmt.Sprintf("Mapper of '%s' expects a %s, got %T", m.resourceType, m.structType, value))
+	}
+
+	return reflected, nil
+}
+
+// memberError builds an error pointing at a member of the request document
+func memberError(title string, detail string, pointer string, status int) *jsh.Error {
+	err := &jsh.Error{Title: title, Detail: detail, Status: status}
+	err.Source.Pointer = pointer
+
+	return err
+}
+
+// escapePointer escapes a member name as a JSON pointer token
+func escapePointer(token string) string {
+	token = strings.Replace(token, "~", "~0", -1)
+	return strings.Replace(token, "/", "~1", -1)
+}
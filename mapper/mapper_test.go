@@ -0,0 +1,273 @@
+package mapper_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api"
+	"github.com/derekdowling/jsh-api/mapper"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type post struct {
+	ID        string   `jsonapi:"id"`
+	Title     string   `jsonapi:"attr,title"`
+	Views     int      `jsonapi:"attr,views,omitempty"`
+	CreatedAt string   `jsonapi:"attr,created-at,readonly"`
+	Author    string   `jsonapi:"relation,author,people"`
+	Tags      []string `jsonapi:"relation,tags,tags"`
+}
+
+// postStore is typed storage of posts
+type postStore struct {
+	posts   map[string]*post
+	fields  []string
+	failure error
+}
+
+func (s *postStore) Save(ctx context.Context, value interface{}) (interface{}, error) {
+	saved := value.(*post)
+	saved.ID = strconv.Itoa(len(s.posts) + 1)
+	s.posts[saved.ID] = saved
+	return saved, s.failure
+}
+
+func (s *postStore) Get(ctx context.Context, id string) (interface{}, error) {
+	return s.posts[id], s.failure
+}
+
+func (s *postStore) List(ctx context.Context) (interface{}, error) {
+	list := []post{}
+	for id := 1; id <= len(s.posts); id++ {
+		list = append(list, *s.posts[strconv.Itoa(id)])
+	}
+	return list, s.failure
+}
+
+func (s *postStore) Update(ctx context.Context, id string, value interface{}, fields []string) (interface{}, error) {
+	s.fields = fields
+	existing := s.posts[id]
+	if existing == nil {
+		return nil, mapper.ErrNotFound
+	}
+
+	existing.Title = value.(*post).Title
+	return existing, s.failure
+}
+
+func (s *postStore) Delete(ctx context.Context, id string) error {
+	if s.posts[id] == nil {
+		return mapper.ErrNotFound
+	}
+	return s.failure
+}
+
+func TestMapper(t *testing.T) {
+
+	Convey("Mapper Tests", t, func() {
+
+		posts := mapper.New("posts", post{})
+
+		Convey("->ToObject()", func() {
+
+			Convey("should build objects from structs", func() {
+				object, err := posts.ToObject("", &post{ID: "1", Title: "Hello", Author: "9", Tags: []string{"a", "b"}})
+
+				So(err, ShouldBeNil)
+				So(object.ID, ShouldEqual, "1")
+				So(object.Type, ShouldEqual, "posts")
+				So(string(object.Attributes), ShouldEqual, `{"title":"Hello","created-at":""}`)
+				So(object.Relationships["author"].Data, ShouldResemble, jsh.ResourceLinkage{{Type: "people", ID: "9"}})
+				So(object.Relationships["tags"].Data, ShouldResemble, jsh.ResourceLinkage{{Type: "tags", ID: "a"}, {Type: "tags", ID: "b"}})
+			})
+
+			Convey("should prefer the id given", func() {
+				object, _ := posts.ToObject("2", post{ID: "1", Views: 3})
+
+				So(object.ID, ShouldEqual, "2")
+				So(string(object.Attributes), ShouldContainSubstring, `"views":3`)
+			})
+
+			Convey("should send empty linkage for empty relationships", func() {
+				object, _ := posts.ToObject("1", &post{})
+				So(object.Relationships["author"].Data, ShouldResemble, jsh.ResourceLinkage{})
+			})
+
+			Convey("should reject values of other types", func() {
+				_, err := posts.ToObject("1", &struct{}{})
+				So(err, ShouldNotBeNil)
+				So(err.StatusCode(), ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+
+		Convey("->FromObject()", func() {
+			object := &jsh.Object{
+				ID:         "1",
+				Type:       "posts",
+				Attributes: []byte(`{"title": "Hello", "unknown": true}`),
+				Relationships: map[string]*jsh.Relationship{
+					"author": {Data: jsh.ResourceLinkage{{Type: "people", ID: "9"}}},
+					"tags":   {Data: jsh.ResourceLinkage{{Type: "tags", ID: "a"}}},
+				},
+			}
+
+			Convey("should build structs from objects", func() {
+				value, err := posts.FromObject(object)
+
+				So(err, ShouldBeNil)
+				So(value, ShouldResemble, &post{ID: "1", Title: "Hello", Author: "9", Tags: []string{"a"}})
+			})
+
+			Convey("should reject objects of other types", func() {
+				object.Type = "people"
+
+				_, err := posts.FromObject(object)
+				So(err.StatusCode(), ShouldEqual, http.StatusConflict)
+				So(err.(jsh.ErrorList)[0].Source.Pointer, ShouldEqual, "/data/type")
+			})
+
+			Convey("should point at each invalid member", func() {
+				object.Attributes = []byte(`{"title": 1, "created-at": "now"}`)
+				object.Relationships["author"].Data = jsh.ResourceLinkage{{Type: "posts", ID: "1"}}
+
+				_, err := posts.FromObject(object)
+				So(err.StatusCode(), ShouldEqual, http.StatusBadRequest)
+
+				pointers := []string{}
+				for _, each := range err.(jsh.ErrorList) {
+					pointers = append(pointers, each.Source.Pointer)
+				}
+				So(pointers, ShouldResemble, []string{
+					"/data/attributes/title",
+					"/data/attributes/created-at",
+					"/data/relationships/author",
+				})
+			})
+		})
+
+		Convey("->DescribeSchema()", func() {
+
+			Convey("should describe attributes with their JSON types", func() {
+				attributes, _ := posts.DescribeSchema()
+				So(attributes, ShouldResemble, []jshapi.AttributeSchema{
+					{Name: "title", Type: "string"},
+					{Name: "views", Type: "number"},
+					{Name: "created-at", Type: "string", ReadOnly: true},
+				})
+			})
+
+			Convey("should describe relationships", func() {
+				_, relationships := posts.DescribeSchema()
+				So(relationships, ShouldResemble, []jshapi.RelationshipSchema{
+					{Name: "author", RelatedType: "people", Cardinality: jshapi.CardinalityToOne, Mutable: true},
+					{Name: "tags", RelatedType: "tags", Cardinality: jshapi.CardinalityToMany, Mutable: true},
+				})
+			})
+
+			Convey("should type fields by their JSON encoding", func() {
+				typed := mapper.New("typed", struct {
+					When    time.Time         `jsonapi:"attr,when"`
+					Flag    *bool             `jsonapi:"attr,flag"`
+					Data    []byte            `jsonapi:"attr,data"`
+					List    []int             `jsonapi:"attr,list"`
+					Extra   map[string]string `jsonapi:"attr,extra"`
+					Raw     json.RawMessage   `jsonapi:"attr,raw"`
+					Unknown interface{}       `jsonapi:"attr,unknown"`
+				}{})
+
+				types := []string{}
+				attributes, _ := typed.DescribeSchema()
+				for _, attribute := range attributes {
+					types = append(types, attribute.Type)
+				}
+				So(types, ShouldResemble, []string{"string", "boolean", "string", "array", "object", "", ""})
+			})
+		})
+
+		Convey("should list the attributes mapped", func() {
+			So(posts.Attributes(), ShouldResemble, []string{"title", "views", "created-at"})
+		})
+
+		Convey("should panic on invalid prototypes and tags", func() {
+			So(func() { mapper.New("posts", "post") }, ShouldPanicWith, "jshapi: mapper of 'posts' requires a struct, got string")
+			So(func() {
+				mapper.New("posts", struct {
+					Author int `jsonapi:"relation,author,people"`
+				}{})
+			}, ShouldPanicWith, "jshapi: invalid jsonapi tag of field 'Author' of 'posts': relationships must be strings or slices of strings")
+			So(func() {
+				mapper.New("posts", struct {
+					Title string `jsonapi:"attr"`
+				}{})
+			}, ShouldPanic)
+		})
+
+		Convey("->CRUD()", func() {
+			storage := &postStore{posts: map[string]*post{"1": {ID: "1", Title: "First", Author: "9"}}}
+
+			api := jshapi.New("")
+			api.Add(jshapi.NewCRUDResource("posts", posts.CRUD(storage)))
+
+			serve := func(method string, url string, body string) *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				request := httptest.NewRequest(method, url, strings.NewReader(body))
+				if body != "" {
+					request.Header.Set("Content-Type", jsh.ContentType)
+				}
+				api.ServeHTTP(recorder, request)
+				return recorder
+			}
+
+			Convey("should serve typed storage", func() {
+				recorder := serve("GET", "/posts/1", "")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"title": "First"`)
+
+				recorder = serve("GET", "/posts", "")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"id": "1"`)
+
+				recorder = serve("POST", "/posts", `{"data": {"type": "posts", "attributes": {"title": "Second"}}}`)
+				So(recorder.Code, ShouldEqual, http.StatusCreated)
+				So(storage.posts["2"].Title, ShouldEqual, "Second")
+			})
+
+			Convey("should pass the fields set by updates", func() {
+				recorder := serve("PATCH", "/posts/1", `{"data": {"type": "posts", "id": "1", "attributes": {"title": "New"}}}`)
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(storage.fields, ShouldResemble, []string{"ID", "Title"})
+				So(storage.posts["1"].Author, ShouldEqual, "9")
+			})
+
+			Convey("should answer missing objects with a 404", func() {
+				So(serve("GET", "/posts/3", "").Code, ShouldEqual, http.StatusNotFound)
+				So(serve("DELETE", "/posts/3", "").Code, ShouldEqual, http.StatusNotFound)
+			})
+
+			Convey("should reject read-only attributes", func() {
+				recorder := serve("POST", "/posts", `{"data": {"type": "posts", "attributes": {"created-at": "now"}}}`)
+
+				So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+				So(recorder.Body.String(), ShouldContainSubstring, `"pointer": "/data/attributes/created-at"`)
+			})
+
+			Convey("should convert storage errors", func() {
+				storage.failure = &jsh.Error{Title: "Bad Gateway", Status: http.StatusBadGateway}
+				So(serve("GET", "/posts/1", "").Code, ShouldEqual, http.StatusBadGateway)
+
+				storage.failure = errors.New("disk on fire")
+				So(serve("GET", "/posts/1", "").Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
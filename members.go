@@ -0,0 +1,213 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+StrictMemberNames enables validation of incoming attribute and relationship names
+against the JSON API member name rules: http://jsonapi.org/format/#document-member-names
+
+POST and PATCH requests containing invalid member names are rejected with a 400
+error per offending member, each pointing at it. Objects nested within attributes
+are validated up to "maxDepth" levels deep, a depth of 0 only validates top level
+attribute names. This protects storage that derives column names or document keys
+from attribute names.
+*/
+func (res *Resource) StrictMemberNames(maxDepth int) {
+	res.checkRegistration("strict member names")
+
+	res.strictMembers = true
+	res.memberDepth = maxDepth
+}
+
+/*
+ValidMemberName checks whether a name is allowed as a JSON API member name. Names
+must be non-empty, consist of alphanumeric or non-ASCII characters, and may contain
+hyphens, underscores, and spaces anywhere but at their start or end.
+*/
+func ValidMemberName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for index, char := range name {
+		switch {
+		case char >= 'a' && char <= 'z',
+			char >= 'A' && char <= 'Z',
+			char >= '0' && char <= '9',
+			char >= utf8.RuneSelf:
+		case char == '-' || char == '_' || char == ' ':
+			if index == 0 || index == len(name)-1 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// memberNameErrors returns an error for every invalid member name of the object
+// when strict member names are enabled
+func (res *Resource) memberNameErrors(object *jsh.Object) jsh.ErrorList {
+	if !res.strictMembers || object == nil {
+		return nil
+	}
+
+	var errs jsh.ErrorList
+	for name := range object.Relationships {
+		if !ValidMemberName(name) {
+			errs = append(errs, memberNameError(name, "/data/relationships/"+escapePointer(name)))
+		}
+	}
+
+	if len(object.Attributes) > 0 {
+		walker := &memberWalker{
+			decoder:  json.NewDecoder(bytes.NewReader(object.Attributes)),
+			maxDepth: res.memberDepth,
+		}
+
+		// a decoding failure means the attributes aren't valid JSON, which
+		// is reported by storage when it unmarshals them
+		walker.value(0)
+		errs = append(errs, walker.errs...)
+	}
+
+	return errs
+}
+
+// memberPathElement is a single key or array index within the attributes
+type memberPathElement struct {
+	key   string
+	index int
+}
+
+// memberWalker streams through attributes validating object keys without decoding
+// them into intermediate maps
+type memberWalker struct {
+	decoder  *json.Decoder
+	maxDepth int
+	path     []memberPathElement
+	errs     jsh.ErrorList
+}
+
+// value consumes the next JSON value, validating the keys of objects nested no
+// deeper than maxDepth
+func (w *memberWalker) value(depth int) error {
+	token, err := w.decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		for w.decoder.More() {
+			keyToken, err := w.decoder.Token()
+			if err != nil {
+				return err
+			}
+
+			key, _ := keyToken.(string)
+			w.path = append(w.path, memberPathElement{key: key, index: -1})
+
+			if !ValidMemberName(key) {
+				w.errs = append(w.errs, memberNameError(key, w.pointer()))
+			}
+
+			if depth < w.maxDepth {
+				err = w.value(depth + 1)
+			} else {
+				err = w.skip()
+			}
+			if err != nil {
+				return err
+			}
+
+			w.path = w.path[:len(w.path)-1]
+		}
+
+		_, err = w.decoder.Token()
+		return err
+
+	case json.Delim('['):
+		for index := 0; w.decoder.More(); index++ {
+			w.path = append(w.path, memberPathElement{index: index})
+
+			err = w.value(depth)
+			if err != nil {
+				return err
+			}
+
+			w.path = w.path[:len(w.path)-1]
+		}
+
+		_, err = w.decoder.Token()
+		return err
+	}
+
+	return nil
+}
+
+// skip consumes the next JSON value without validating it
+func (w *memberWalker) skip() error {
+	nesting := 0
+	for {
+		token, err := w.decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			nesting++
+		case json.Delim('}'), json.Delim(']'):
+			nesting--
+		}
+
+		if nesting == 0 {
+			return nil
+		}
+	}
+}
+
+// pointer builds the JSON pointer of the current path
+func (w *memberWalker) pointer() string {
+	segments := make([]string, 0, len(w.path))
+	for _, element := range w.path {
+		if element.index >= 0 {
+			segments = append(segments, strconv.Itoa(element.index))
+		} else {
+			segments = append(segments, escapePointer(element.key))
+		}
+	}
+
+	return "/data/attributes/" + strings.Join(segments, "/")
+}
+
+// escapePointer escapes a reference token for use within a JSON pointer
+func escapePointer(token string) string {
+	token = strings.Replace(token, "~", "~0", -1)
+	return strings.Replace(token, "/", "~1", -1)
+}
+
+// memberNameError builds the 400 error for an invalid member name
+func memberNameError(name string, pointer string) *jsh.Error {
+	err := &jsh.Error{
+		Title:  "Invalid Member Name",
+		Detail: fmt.Sprintf("'%s' is not a valid member name", name),
+		Status: http.StatusBadRequest,
+	}
+	err.Source.Pointer = pointer
+
+	return err
+}
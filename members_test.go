@@ -0,0 +1,94 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMemberNames(t *testing.T) {
+
+	Convey("Member Name Tests", t, func() {
+
+		Convey("->ValidMemberName()", func() {
+			valid := []string{"name", "first-name", "first_name", "first name", "a", "Ünïcode", "x1"}
+			for _, name := range valid {
+				So(ValidMemberName(name), ShouldBeTrue)
+			}
+
+			invalid := []string{"", "-name", "name-", "_name", "name_", " name", "na.me", "na/me", "na+me"}
+			for _, name := range invalid {
+				So(ValidMemberName(name), ShouldBeFalse)
+			}
+		})
+
+		Convey("->memberNameErrors()", func() {
+			resource := NewResource(testResourceType)
+			object := &jsh.Object{
+				Type:       testResourceType,
+				Attributes: []byte(`{"ok": {"bad.": 1, "deep": {"x/y": [{"bad!": 1}]}}, "list": [{"-a": 1}], "-top": 1}`),
+				Relationships: map[string]*jsh.Relationship{
+					"bad rel ": {},
+				},
+			}
+
+			Convey("should do nothing unless enabled", func() {
+				So(resource.memberNameErrors(object), ShouldBeEmpty)
+			})
+
+			Convey("should only validate top level names at depth 0", func() {
+				resource.StrictMemberNames(0)
+
+				errs := resource.memberNameErrors(object)
+				pointers := []string{}
+				for _, err := range errs {
+					pointers = append(pointers, err.Source.Pointer)
+				}
+
+				So(pointers, ShouldContain, "/data/attributes/-top")
+				So(pointers, ShouldContain, "/data/relationships/bad rel ")
+				So(len(errs), ShouldEqual, 2)
+			})
+
+			Convey("should validate nested names with precise pointers", func() {
+				resource.StrictMemberNames(5)
+
+				errs := resource.memberNameErrors(object)
+				pointers := []string{}
+				for _, err := range errs {
+					So(err.Status, ShouldEqual, http.StatusBadRequest)
+					pointers = append(pointers, err.Source.Pointer)
+				}
+
+				So(pointers, ShouldContain, "/data/attributes/ok/bad.")
+				So(pointers, ShouldContain, "/data/attributes/ok/deep/x~1y")
+				So(pointers, ShouldContain, "/data/attributes/ok/deep/x~1y/0/bad!")
+				So(pointers, ShouldContain, "/data/attributes/list/0/-a")
+				So(len(errs), ShouldEqual, 6)
+			})
+		})
+
+		Convey("should reject invalid names on POST", func() {
+			resource := NewMockResource(testResourceType, 1, testObjAttrs)
+			resource.StrictMemberNames(2)
+
+			api := New("")
+			api.Add(resource)
+
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			url := server.URL + "/" + testResourceType
+
+			resp, body := postBulk(url, `{"data": {"type": "bars", "attributes": {"foo-": "bar"}}}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(body, ShouldContainSubstring, "/data/attributes/foo-")
+
+			resp, _ = postBulk(url, `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+		})
+	})
+}
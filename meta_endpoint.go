@@ -0,0 +1,74 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// MetaFunc returns the meta member of the document answered by a meta endpoint
+type MetaFunc func(ctx context.Context, r *http.Request) (map[string]interface{}, jsh.ErrorType)
+
+/*
+MetaEndpoint registers a `GET /(prefix/)<route>` endpoint answering a meta-only
+document, for information that isn't a resource, such as server information or
+feature flags:
+
+	api.MetaEndpoint("server-info", func(ctx context.Context, r *http.Request) (map[string]interface{}, jsh.ErrorType) {
+		return map[string]interface{}{"version": version}, nil
+	})
+
+The document holds the meta returned by fn, the `jsonapi` member, and a top-level
+self link when EmitSelfLinks is enabled. It is sent through SendHandler, as is
+any error returned by fn, so that the API middleware, request ids and error
+translation apply as they do for resources.
+*/
+func (a *API) MetaEndpoint(route string, fn MetaFunc) {
+	a.checkRegistration("a meta endpoint")
+
+	matcher := path.Join(a.prefix, route)
+	a.Mux.HandleFuncC(pat.Get(matcher), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		a.metaHandler(ctx, w, r, fn)
+	})
+	a.routes = append(a.routes, Route{Method: get, Pattern: matcher})
+}
+
+// GET /(prefix/)<route> of meta endpoints
+func (a *API) metaHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, fn MetaFunc) {
+	ctx, w, endSpan := a.traceHandler(ctx, w, "jshapi.meta")
+	defer endSpan()
+
+	ctx = context.WithValue(ctx, sendingAPIKey, a)
+
+	meta, err := fn(ctx, r)
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		SendHandler(ctx, w, r, err)
+		return
+	}
+
+	if meta == nil {
+		meta = map[string]interface{}{}
+	}
+
+	document := metaDocument{Meta: meta}
+	document.JSONAPI.Version = jsh.JSONAPIVersion
+	if a.selfLinks {
+		document.Links = map[string]*jsh.Link{"self": {HREF: a.linkBase(r) + r.URL.RequestURI()}}
+	}
+
+	content, marshalErr := json.Marshal(&document)
+	if marshalErr != nil {
+		SendHandler(ctx, w, r, jsh.ISE("Unable to encode meta: "+marshalErr.Error()))
+		return
+	}
+
+	SendHandler(ctx, w, r, &RawDocument{Body: content})
+}
@@ -0,0 +1,77 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetaEndpoint(t *testing.T) {
+
+	Convey("Meta Endpoint Tests", t, func() {
+
+		var metaErr jsh.ErrorType
+		api := New("api")
+		api.UseC(RequestID())
+		api.MetaEndpoint("server-info", func(ctx context.Context, r *http.Request) (map[string]interface{}, jsh.ErrorType) {
+			if metaErr != nil {
+				return nil, metaErr
+			}
+			return map[string]interface{}{"version": "1.2.0"}, nil
+		})
+
+		send := func(url string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+			return recorder
+		}
+
+		Convey("->MetaEndpoint()", func() {
+
+			Convey("should answer a meta-only document", func() {
+				recorder := send("/api/server-info")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Header().Get("Content-Type"), ShouldEqual, jsh.ContentType)
+
+				document := map[string]interface{}{}
+				So(json.Unmarshal(recorder.Body.Bytes(), &document), ShouldBeNil)
+				So(document["meta"], ShouldResemble, map[string]interface{}{"version": "1.2.0"})
+				So(document["jsonapi"], ShouldResemble, map[string]interface{}{"version": jsh.JSONAPIVersion})
+				So(document, ShouldNotContainKey, "data")
+				So(document, ShouldNotContainKey, "links")
+			})
+
+			Convey("should send errors through the SendHandler", func() {
+				metaErr = jsh.ISE("unavailable")
+
+				recorder := send("/api/server-info")
+				So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+				So(recorder.Body.String(), ShouldContainSubstring, `"id": "`+recorder.Header().Get(RequestIDHeader)+`"`)
+			})
+
+			Convey("should list the route", func() {
+				So(api.RouteTree(), ShouldContainSubstring, "GET - /api/server-info\n")
+			})
+		})
+
+		Convey("->EmitSelfLinks()", func() {
+			linked := New("api")
+			linked.EmitSelfLinks(true)
+			linked.MetaEndpoint("flags", func(ctx context.Context, r *http.Request) (map[string]interface{}, jsh.ErrorType) {
+				return nil, nil
+			})
+
+			recorder := httptest.NewRecorder()
+			linked.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/flags?all=true", nil))
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"meta":{}`)
+			So(recorder.Body.String(), ShouldContainSubstring, `"self":{"href":"/api/flags?all=true"}`)
+		})
+	})
+}
@@ -0,0 +1,231 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+/*
+RelatedCollection configures the `GET /resource/:id/<relationship>` route of a
+ToManyMetaOnly relationship, which redirects to collection with a 302. collection
+is a path relative to the API prefix, where ":id" stands for the id of the parent
+object, such as "/users?filter[followed]=:id". Ignored by other helpers.
+*/
+func RelatedCollection(collection string) RouteOption {
+	return func(opts *routeOptions) {
+		opts.relatedCollection = collection
+	}
+}
+
+// ProxyRelated serves the RelatedCollection of a ToManyMetaOnly relationship in
+// place of its related route, through the API, rather than redirecting to it
+func ProxyRelated() RouteOption {
+	return func(opts *routeOptions) {
+		opts.proxyRelated = true
+	}
+}
+
+// metaOnlyRelationship is a relationship served without linkage, see
+// ToManyMetaOnly
+type metaOnlyRelationship struct {
+	count store.Count
+}
+
+/*
+ToManyMetaOnly registers a ToMany relationship named "relationship" verbatim, too
+large to ever be listed, such as the followers of a user. Its
+`GET /resource/:id/relationships/<relationship>` route sends the links of the
+relationship and the count of related objects as meta, without linkage:
+
+	{"links": {"self": {"href": "/users/1/relationships/followers"}}, "meta": {"count": 1200000}}
+
+The relationship is added, with the same links and meta, to the objects sent by the
+GET routes of the resource, and can't be included. The
+`GET /resource/:id/<relationship>` route, and the related link, are only
+registered along with a RelatedCollection:
+
+	users.ToManyMetaOnly("followers", storage.CountFollowers,
+		jshapi.RelatedCollection("/users?filter[followed]=:id"))
+*/
+func (res *Resource) ToManyMetaOnly(relationship string, count store.Count, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.ToManyMetaOnly(relationship, count, opts...) })()
+
+	options := applyRouteOptions(opts)
+	if options.relatedCollection != "" && !strings.HasPrefix(options.relatedCollection, "/") {
+		panic(fmt.Sprintf(
+			"jshapi: related collection '%s' of relationship '%s' must start with a '/'",
+			options.relatedCollection, relationship,
+		))
+	}
+
+	var named *routeMeta
+
+	// handle /.../:id/<relationship>
+	if options.relatedCollection != "" {
+		matcher := fmt.Sprintf("%s/%s", res.idRoute(), relationship)
+		named = res.handleRoute(
+			pat.Get(matcher),
+			OpRelationship,
+			func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				res.relatedCollectionHandler(ctx, w, r, options)
+			},
+		)
+		res.addRoute(get, matcher)
+	}
+
+	// handle /.../:id/relationships/<relationship>
+	related := metaOnlyRelationship{count: count}
+	relationshipMatcher := fmt.Sprintf("%s/relationships/%s", res.idRoute(), relationship)
+	meta := res.handleRoute(
+		pat.Get(relationshipMatcher),
+		OpRelationship,
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			res.metaOnlyHandler(ctx, w, r, relationship, related)
+		},
+	)
+	res.addRoute(get, relationshipMatcher)
+
+	if named == nil {
+		named = meta
+	}
+	res.nameRoute(named, opts)
+
+	if res.metaOnly == nil {
+		res.metaOnly = map[string]metaOnlyRelationship{}
+	}
+	res.metaOnly[relationship] = related
+	res.Relationships[relationship] = ToMany
+}
+
+// GET /resources/:id/relationships/<relationship> of meta-only relationships
+func (res *Resource) metaOnlyHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, relationship string, related metaOnlyRelationship) {
+	id := ResourceID(ctx, res)
+
+	storageCtx, finish := startStorage(ctx, r, "count")
+	count, err := related.count(storageCtx, id)
+	err = finish(err)
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		res.send(ctx, w, r, err)
+		return
+	}
+
+	relationshipLinks := res.metaOnlyLinks(res.linkBase(r), id, relationship)
+	links := map[string]*jsh.Link{"self": relationshipLinks.Self}
+	if relationshipLinks.Related != nil {
+		links["related"] = relationshipLinks.Related
+	}
+
+	sendMetaLinks(w, map[string]interface{}{"count": count}, links)
+}
+
+// GET /resources/:id/<relationship> of meta-only relationships
+func (res *Resource) relatedCollectionHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, options routeOptions) {
+	target := res.relatedCollectionPath(options.relatedCollection, ResourceID(ctx, res))
+
+	if !options.proxyRelated || res.api == nil {
+		http.Redirect(w, r, res.linkBase(r)+target, http.StatusFound)
+		return
+	}
+
+	// the API resolves the tenant of the proxied request again
+	target = tenantPath(r) + target
+	proxied := r.WithContext(r.Context())
+	proxied.URL, _ = url.Parse(target)
+	proxied.RequestURI = target
+	res.api.ServeHTTP(w, proxied)
+}
+
+// relatedCollectionPath is the path of the related collection of an object,
+// prefixed with the API prefix
+func (res *Resource) relatedCollectionPath(collection string, id string) string {
+	collectionPath, query := collection, ""
+	if index := strings.Index(collection, "?"); index >= 0 {
+		collectionPath, query = collection[:index], collection[index+1:]
+	}
+
+	prefix := "/"
+	if res.api != nil {
+		prefix = res.api.prefix
+	}
+
+	target := path.Join(prefix, strings.Replace(collectionPath, ":id", url.PathEscape(id), -1))
+	if query != "" {
+		target += "?" + strings.Replace(query, ":id", url.QueryEscape(id), -1)
+	}
+
+	return target
+}
+
+// metaOnlyLinks returns the links of a meta-only relationship of an object, for
+// the routes still registered
+func (res *Resource) metaOnlyLinks(base string, id string, relationship string) jsh.Links {
+	objectURL := base + res.objectPath(id)
+
+	links := jsh.Links{}
+	if res.hasRoute(get, res.idRoute()+"/relationships/"+relationship) {
+		links.Self = &jsh.Link{HREF: objectURL + "/relationships/" + relationship}
+	}
+	if res.hasRoute(get, res.idRoute()+"/"+relationship) {
+		links.Related = &jsh.Link{HREF: objectURL + "/" + relationship}
+	}
+
+	return links
+}
+
+/*
+countRelationships adds the meta-only relationships of the resources of objects,
+copies owned by the document, with their links and count. Relationships provided
+by the storage, or left out of the fieldsets, are not counted.
+*/
+func (res *Resource) countRelationships(ctx context.Context, r *http.Request, objects jsh.List, fields fieldsets) jsh.ErrorType {
+	base := res.linkBase(r)
+
+	for _, object := range objects {
+		target := res.linkTarget(object.Type)
+		if target == nil || len(target.metaOnly) == 0 || object.ID == "" {
+			continue
+		}
+
+		names := make([]string, 0, len(target.metaOnly))
+		fieldset, trimmed := fields[object.Type]
+		for name := range target.metaOnly {
+			if object.Relationships[name] == nil && (!trimmed || fieldset[name]) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			storageCtx, finish := startStorage(ctx, r, "count")
+			count, err := target.metaOnly[name].count(storageCtx, object.ID)
+			err = finish(err)
+			if HasError(err) {
+				return err
+			}
+
+			links := target.metaOnlyLinks(base, object.ID, name)
+			if object.Relationships == nil {
+				object.Relationships = map[string]*jsh.Relationship{}
+			}
+			object.Relationships[name] = &jsh.Relationship{
+				Links: &links,
+				Meta:  map[string]interface{}{"count": count},
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetaOnlyRelationships(t *testing.T) {
+
+	Convey("Meta Only Relationships Tests", t, func() {
+
+		counted := []string{}
+		count := func(ctx context.Context, id string) (int, jsh.ErrorType) {
+			counted = append(counted, id)
+			if id == "404" {
+				return 0, jsh.NotFound("users", id)
+			}
+			return 1200000, nil
+		}
+
+		users := NewResource("users")
+		users.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return jsh.NewObject(id, "users", testObjAttrs)
+		})
+		users.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+			followed, _ := jsh.NewObject("2", "users", testObjAttrs)
+			return jsh.List{followed}, nil
+		})
+
+		api := New("api")
+
+		type links struct {
+			Self    *struct{ HREF string } `json:"self"`
+			Related *struct{ HREF string } `json:"related"`
+		}
+		type relationship struct {
+			Links links
+			Meta  map[string]int
+			Data  json.RawMessage
+		}
+
+		get := func(url string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+			return recorder
+		}
+
+		Convey("without a related collection", func() {
+			users.ToManyMetaOnly("followers", count)
+			api.Add(users)
+
+			Convey("should register the relationship", func() {
+				So(users.Relationships["followers"], ShouldEqual, ToMany)
+				So(users.Routes, ShouldContain, "GET - /users/:id/relationships/followers")
+				So(users.Routes, ShouldNotContain, "GET - /users/:id/followers")
+			})
+
+			Convey("should serve links and count without linkage", func() {
+				recorder := get("/api/users/1/relationships/followers")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldNotContainSubstring, `"data"`)
+
+				doc := relationship{}
+				So(json.Unmarshal(recorder.Body.Bytes(), &doc), ShouldBeNil)
+				So(doc.Meta["count"], ShouldEqual, 1200000)
+				So(doc.Links.Self.HREF, ShouldEqual, "/api/users/1/relationships/followers")
+				So(doc.Links.Related, ShouldBeNil)
+			})
+
+			Convey("should send count errors", func() {
+				recorder := get("/api/users/404/relationships/followers")
+				So(recorder.Code, ShouldEqual, http.StatusNotFound)
+			})
+
+			Convey("should add the relationship to sent objects", func() {
+				recorder := get("/api/users/1")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+
+				doc := struct {
+					Data struct {
+						Relationships map[string]relationship
+					}
+				}{}
+				So(json.Unmarshal(recorder.Body.Bytes(), &doc), ShouldBeNil)
+
+				followers := doc.Data.Relationships["followers"]
+				So(followers.Data, ShouldBeNil)
+				So(followers.Meta["count"], ShouldEqual, 1200000)
+				So(followers.Links.Self.HREF, ShouldEqual, "/api/users/1/relationships/followers")
+			})
+
+			Convey("should not count relationships left out of the fieldset", func() {
+				recorder := get("/api/users/1?fields[users]=foo")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "followers")
+				So(counted, ShouldBeEmpty)
+			})
+
+			Convey("should not be included", func() {
+				recorder := get("/api/users/1?include=followers")
+				So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("with a related collection", func() {
+			users.ToManyMetaOnly("followers", count, RelatedCollection("/users?filter[followed]=:id"))
+
+			Convey("should redirect the related route to the collection", func() {
+				api.Add(users)
+
+				recorder := get("/api/users/1/followers")
+				So(recorder.Code, ShouldEqual, http.StatusFound)
+				So(recorder.Header().Get("Location"), ShouldEqual, "/api/users?filter[followed]=1")
+
+				doc := relationship{}
+				So(json.Unmarshal(get("/api/users/1/relationships/followers").Body.Bytes(), &doc), ShouldBeNil)
+				So(doc.Links.Related.HREF, ShouldEqual, "/api/users/1/followers")
+			})
+
+			Convey("should prefix the redirect with the base URL", func() {
+				api.BaseURL("https://example.com")
+				api.Add(users)
+
+				recorder := get("/api/users/1/followers")
+				So(recorder.Header().Get("Location"), ShouldEqual, "https://example.com/api/users?filter[followed]=1")
+			})
+		})
+
+		Convey("should proxy the related collection", func() {
+			users.ToManyMetaOnly("followers", count, RelatedCollection("/users?filter[followed]=:id"), ProxyRelated())
+			api.Add(users)
+
+			recorder := get("/api/users/1/followers")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			doc := struct{ Data []struct{ ID string } }{}
+			So(json.Unmarshal(recorder.Body.Bytes(), &doc), ShouldBeNil)
+			So(doc.Data, ShouldHaveLength, 1)
+			So(doc.Data[0].ID, ShouldEqual, "2")
+		})
+
+		Convey("should reject relative collections", func() {
+			So(func() { users.ToManyMetaOnly("followers", count, RelatedCollection("users")) }, ShouldPanicWith,
+				"jshapi: related collection 'users' of relationship 'followers' must start with a '/'")
+		})
+	})
+}
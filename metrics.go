@@ -0,0 +1,173 @@
+package jshapi
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+MetricsRecorder receives an observation for every request served by the API, see
+the metrics/prometheus package for a Prometheus implementation:
+
+	api.SetMetrics(recorder)
+
+Request and response sizes count body bytes. Requests abandoned because the client
+went away are reported with the StatusClientClosedRequest status.
+*/
+type MetricsRecorder interface {
+	ObserveRequest(route Route, status int, duration time.Duration, reqBytes, respBytes int64)
+}
+
+/*
+StorageRecorder can optionally be implemented by a MetricsRecorder to receive the
+duration of each storage call separately from the total request duration, which
+tells the cost of storage apart from parsing and serialization. call names the
+storage function: "save", "get", "list", "update", "delete", "to_many", "action"
+or "save_list".
+*/
+type StorageRecorder interface {
+	ObserveStorage(route Route, call string, duration time.Duration, failed bool)
+}
+
+// SetMetrics installs the recorder observing the requests served by the API
+func (a *API) SetMetrics(recorder MetricsRecorder) {
+	a.checkRegistration("a metrics recorder")
+
+	a.metrics = recorder
+}
+
+// serveObserved serves a request, reporting it to the metrics recorder
+func (a *API) serveObserved(ctx context.Context, w http.ResponseWriter, r *http.Request, info *routeInfo) {
+	body := &countingReader{ReadCloser: r.Body}
+	if r.Body != nil {
+		r.Body = body
+	}
+
+	writer, recorder := wrapAccessWriter(w)
+	start := time.Now()
+
+	a.Mux.ServeHTTPC(ctx, writer, r)
+
+	status := info.reportedStatus(recorder.status)
+	a.metrics.ObserveRequest(info.route(r), status, time.Since(start), body.bytes, int64(recorder.bytes))
+}
+
+// storageSpanNames are the span names of the storage calls, so that they are not
+// built on every call
+var storageSpanNames = map[string]string{
+	"save":            "store.save",
+	"get":             "store.get",
+	"list":            "store.list",
+	"update":          "store.update",
+	"delete":          "store.delete",
+	"to_many":         "store.to_many",
+	"action":          "store.action",
+	"save_list":       "store.save_list",
+	"update_list":     "store.update_list",
+	"delete_many":     "store.delete_many",
+	"delete_matching": "store.delete_matching",
+	"get_many":        "store.get_many",
+	"include":         "store.include",
+	"count":           "store.count",
+	"list_stream":     "store.list_stream",
+	"dry_run_save":    "store.dry_run_save",
+	"dry_run_update":  "store.dry_run_update",
+}
+
+// storageSpanName returns the span name of a storage call
+func storageSpanName(call string) string {
+	name, found := storageSpanNames[call]
+	if !found {
+		return "store." + call
+	}
+
+	return name
+}
+
+/*
+startStorage is called before every storage call with the storage function name,
+and returns the context to pass to storage along with the function to call with
+its result, which returns the error of the call, a StorageTimeoutError when it
+failed past its StorageTimeout.
+*/
+func startStorage(ctx context.Context, r *http.Request, call string) (context.Context, func(jsh.ErrorType) jsh.ErrorType) {
+	op, timeout := storageTimeout(ctx)
+
+	var api *API
+	info, _ := ctx.Value(routeInfoKey).(*routeInfo)
+	if info != nil {
+		api = info.api
+	}
+
+	var storageRecorder StorageRecorder
+	var timeoutRecorder StorageTimeoutRecorder
+	observed := false
+	if api != nil {
+		storageRecorder, observed = api.metrics.(StorageRecorder)
+		timeoutRecorder, _ = api.metrics.(StorageTimeoutRecorder)
+	}
+
+	var errorCounts *storageErrorCounts
+	if sending := sendingAPI(ctx); sending != nil {
+		errorCounts = sending.storageErrors
+	}
+	if timeout == 0 && !observed && errorCounts == nil && (api == nil || api.tracer == nil) {
+		return ctx, endStorage
+	}
+
+	parent := ctx
+	deadlineCtx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		deadlineCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	ctx, endSpan := api.startSpan(deadlineCtx, storageSpanName(call))
+
+	start := time.Now()
+	return ctx, func(err jsh.ErrorType) jsh.ErrorType {
+		timedOut := timeout > 0 && storageTimedOut(parent, deadlineCtx, err)
+		cancel()
+		if timedOut {
+			err = &StorageTimeoutError{Call: call, Operation: op, Timeout: timeout}
+		}
+
+		storageErr := spanError(err)
+		endSpan(storageErr)
+
+		if errorCounts != nil && storageErr != nil {
+			errorCounts.add(call)
+		}
+
+		if observed {
+			storageRecorder.ObserveStorage(info.route(r), call, time.Since(start), storageErr != nil)
+		}
+		if timedOut && timeoutRecorder != nil {
+			timeoutRecorder.ObserveStorageTimeout(info.route(r), call, timeout)
+		}
+
+		return err
+	}
+}
+
+// endStorage ends storage calls that are neither bounded, traced nor observed
+func endStorage(err jsh.ErrorType) jsh.ErrorType {
+	return err
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.bytes += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,11 @@
+/*
+Package prometheus provides a jshapi.MetricsRecorder backed by the Prometheus client,
+github.com/prometheus/client_golang. To avoid imposing the dependency on every
+jshapi user, the recorder is only built with the "prometheus" build tag:
+
+	go build -tags prometheus
+
+	recorder := prometheus.NewRecorder("myapi", prom.DefaultRegisterer)
+	api.SetMetrics(recorder)
+*/
+package prometheus
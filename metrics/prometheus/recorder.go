@@ -0,0 +1,94 @@
+//go:build prometheus
+// +build prometheus
+
+package prometheus
+
+import (
+	"strconv"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/derekdowling/jsh-api"
+)
+
+// Recorder implements jshapi.MetricsRecorder and jshapi.StorageRecorder
+type Recorder struct {
+	requests     *prom.CounterVec
+	duration     *prom.HistogramVec
+	requestSize  *prom.HistogramVec
+	responseSize *prom.HistogramVec
+	storage      *prom.HistogramVec
+}
+
+var (
+	requestLabels = []string{"method", "route", "resource", "operation", "status"}
+	storageLabels = []string{"route", "resource", "call", "failed"}
+)
+
+// NewRecorder creates a Recorder with metrics named after the namespace, and
+// registers them with the registerer
+func NewRecorder(namespace string, registerer prom.Registerer) *Recorder {
+	sizeBuckets := prom.ExponentialBuckets(100, 10, 6)
+
+	r := &Recorder{
+		requests: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Number of requests served.",
+		}, requestLabels),
+		duration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Total duration of request handling.",
+			Buckets:   prom.DefBuckets,
+		}, requestLabels),
+		requestSize: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_size_bytes",
+			Help:      "Size of request bodies.",
+			Buckets:   sizeBuckets,
+		}, requestLabels),
+		responseSize: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "response_size_bytes",
+			Help:      "Size of response bodies.",
+			Buckets:   sizeBuckets,
+		}, requestLabels),
+		storage: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_duration_seconds",
+			Help:      "Duration of storage calls.",
+			Buckets:   prom.DefBuckets,
+		}, storageLabels),
+	}
+
+	registerer.MustRegister(r.requests, r.duration, r.requestSize, r.responseSize, r.storage)
+	return r
+}
+
+// ObserveRequest implements jshapi.MetricsRecorder
+func (r *Recorder) ObserveRequest(route jshapi.Route, status int, duration time.Duration, reqBytes, respBytes int64) {
+	labels := prom.Labels{
+		"method":    route.Method,
+		"route":     route.Pattern,
+		"resource":  route.ResourceType,
+		"operation": string(route.Operation),
+		"status":    strconv.Itoa(status),
+	}
+
+	r.requests.With(labels).Inc()
+	r.duration.With(labels).Observe(duration.Seconds())
+	r.requestSize.With(labels).Observe(float64(reqBytes))
+	r.responseSize.With(labels).Observe(float64(respBytes))
+}
+
+// ObserveStorage implements jshapi.StorageRecorder
+func (r *Recorder) ObserveStorage(route jshapi.Route, call string, duration time.Duration, failed bool) {
+	r.storage.With(prom.Labels{
+		"route":    route.Pattern,
+		"resource": route.ResourceType,
+		"call":     call,
+		"failed":   strconv.FormatBool(failed),
+	}).Observe(duration.Seconds())
+}
@@ -0,0 +1,37 @@
+//go:build prometheus
+// +build prometheus
+
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/derekdowling/jsh-api"
+)
+
+func TestRecorder(t *testing.T) {
+
+	Convey("Prometheus Recorder Tests", t, func() {
+
+		recorder := NewRecorder("test", prom.NewRegistry())
+		route := jshapi.Route{Method: "GET", Pattern: "/users/:id", ResourceType: "users", Operation: jshapi.OpRead}
+
+		Convey("should count requests by route", func() {
+			recorder.ObserveRequest(route, 200, time.Millisecond, 0, 120)
+			recorder.ObserveRequest(route, 200, time.Millisecond, 0, 120)
+
+			count := testutil.ToFloat64(recorder.requests.WithLabelValues("GET", "/users/:id", "users", "read", "200"))
+			So(count, ShouldEqual, 2)
+		})
+
+		Convey("should observe storage calls", func() {
+			recorder.ObserveStorage(route, "get", time.Millisecond, false)
+			So(testutil.CollectAndCount(recorder.storage), ShouldEqual, 1)
+		})
+	})
+}
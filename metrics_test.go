@@ -0,0 +1,80 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recordMetrics keeps the observations it receives
+type recordMetrics struct {
+	routes    []Route
+	statuses  []int
+	reqBytes  []int64
+	respBytes []int64
+	calls     []string
+}
+
+func (m *recordMetrics) ObserveRequest(route Route, status int, duration time.Duration, reqBytes, respBytes int64) {
+	m.routes = append(m.routes, route)
+	m.statuses = append(m.statuses, status)
+	m.reqBytes = append(m.reqBytes, reqBytes)
+	m.respBytes = append(m.respBytes, respBytes)
+}
+
+func (m *recordMetrics) ObserveStorage(route Route, call string, duration time.Duration, failed bool) {
+	m.calls = append(m.calls, call)
+}
+
+func TestMetrics(t *testing.T) {
+
+	Convey("Metrics Tests", t, func() {
+
+		metrics := &recordMetrics{}
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+		resource.Action("reset", (&MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs}).Get)
+
+		api := New("")
+		api.SetMetrics(metrics)
+		api.Add(resource)
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/" + testResourceType
+
+		Convey("should observe requests by route pattern", func() {
+			body := `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`
+			_, content := negotiationRequest("POST", url, "application/vnd.api+json", "", body)
+
+			So(metrics.routes, ShouldResemble, []Route{{
+				Method:       "POST",
+				Pattern:      "/bars",
+				ResourceType: testResourceType,
+				Operation:    OpCreate,
+			}})
+			So(metrics.statuses, ShouldResemble, []int{http.StatusCreated})
+			So(metrics.reqBytes, ShouldResemble, []int64{int64(len(body))})
+			So(metrics.respBytes, ShouldResemble, []int64{int64(len(content))})
+			So(metrics.calls, ShouldResemble, []string{"save"})
+		})
+
+		Convey("should observe action routes", func() {
+			negotiationRequest("GET", url+"/1/reset", "", "", "")
+			So(metrics.routes[0].Pattern, ShouldEqual, "/bars/:id/reset")
+			So(metrics.routes[0].Operation, ShouldEqual, OpAction)
+			So(metrics.calls, ShouldResemble, []string{"action"})
+		})
+
+		Convey("should fall back to the API pattern for unmatched routes", func() {
+			negotiationRequest("GET", url+"/1/unknown", "", "", "")
+			So(metrics.routes[0].Pattern, ShouldEqual, "/bars/*")
+			So(metrics.statuses, ShouldResemble, []int{http.StatusNotFound})
+			So(metrics.calls, ShouldBeEmpty)
+		})
+	})
+}
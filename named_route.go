@@ -0,0 +1,160 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// RouteOption configures a route registered through a resource helper, such as
+// Get or Action
+type RouteOption func(*routeOptions)
+
+// routeOptions holds the configuration of a route being registered
+type routeOptions struct {
+	name string
+	// relatedCollection and proxyRelated configure the related route of meta-only
+	// relationships, see RelatedCollection
+	relatedCollection string
+	proxyRelated      bool
+	// hideDeletedCount makes DeleteBulk reply with a 204, see HideDeletedCount
+	hideDeletedCount bool
+}
+
+// Params holds the values of the variables of a route, by name, see API.Reverse
+type Params map[string]string
+
+/*
+Named names the route registered by a resource helper, for API.Reverse:
+
+	users.Get(storage.Get, jshapi.Named("user-detail"))
+	url, err := api.Reverse("user-detail", jshapi.Params{"id": "42"})
+
+Names are unique across an API, registering a name twice panics. Relationship
+helpers name their `/resource/:id/<relationship>` route. Names are listed by
+RegisteredRoutes, and API.Routes, for exports such as operation ids.
+*/
+func Named(name string) RouteOption {
+	return func(opts *routeOptions) {
+		opts.name = name
+	}
+}
+
+/*
+Reverse builds the URL of the route registered under name, substituting params
+for its variables, path escaped. URLs are prefixed with the BaseURL of the API,
+and are paths without it. An error is returned for unknown names, missing params
+and params the route doesn't use.
+*/
+func (a *API) Reverse(name string, params Params) (string, error) {
+	res := a.namedResource(name)
+	if res == nil {
+		return "", fmt.Errorf("jshapi: no route is named '%s'", name)
+	}
+
+	route := res.routeNames[name]
+	used := map[string]bool{}
+
+	segments := strings.Split(res.fullPattern(route), "/")
+	for index, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			value, hasValue := params[segment[1:]]
+			if !hasValue || value == "" {
+				return "", fmt.Errorf("jshapi: missing param '%s' to reverse route '%s'", segment[1:], name)
+			}
+			segments[index] = url.PathEscape(value)
+			used[segment[1:]] = true
+		case segment == "*" && index == len(segments)-1:
+			segments[index] = strings.TrimPrefix(params["*"], "/")
+			used["*"] = true
+		}
+	}
+
+	unused := []string{}
+	for param := range params {
+		if !used[param] {
+			unused = append(unused, param)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return "", fmt.Errorf("jshapi: route '%s' has no param %s", name, strings.Join(unused, ", "))
+	}
+
+	return a.baseURL + strings.TrimSuffix(strings.Join(segments, "/"), "/"), nil
+}
+
+// namedResource returns the resource of the API holding a route name, if any
+func (a *API) namedResource(name string) *Resource {
+	for _, resource := range a.Resources {
+		if _, named := resource.routeNames[name]; named {
+			return resource
+		}
+	}
+
+	return nil
+}
+
+// checkRouteNames panics if a route name of resource is registered by another
+// resource of the API, other than the one of the same type it replaces
+func (a *API) checkRouteNames(resource *Resource) {
+	for name := range resource.routeNames {
+		owner := a.namedResource(name)
+		if owner != nil && owner != resource && owner.Type != resource.Type {
+			panic(fmt.Sprintf("jshapi: route name '%s' of resource '%s' is already registered by resource '%s'", name, resource.Type, owner.Type))
+		}
+	}
+}
+
+// applyRouteOptions returns the configuration set by route options
+func applyRouteOptions(opts []RouteOption) routeOptions {
+	options := routeOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
+
+// nameRoute applies the route options of a registration to the metadata of its
+// route
+func (res *Resource) nameRoute(meta *routeMeta, opts []RouteOption) {
+	options := applyRouteOptions(opts)
+	if options.name == "" {
+		return
+	}
+
+	if _, named := res.routeNames[options.name]; named {
+		panic(fmt.Sprintf("jshapi: route name '%s' is already registered on resource '%s'", options.name, res.Type))
+	}
+	if res.api != nil {
+		owner := res.api.namedResource(options.name)
+		if owner != nil && owner != res {
+			panic(fmt.Sprintf("jshapi: route name '%s' is already registered by resource '%s'", options.name, owner.Type))
+		}
+	}
+
+	if res.routeNames == nil {
+		res.routeNames = map[string]string{}
+	}
+	res.routeNames[options.name] = meta.route
+	meta.name = options.name
+}
+
+// forgetRouteName removes the name of a disabled route
+func (res *Resource) forgetRouteName(meta *routeMeta) {
+	if meta.name == "" {
+		return
+	}
+
+	// the delete builtin is shadowed by the package method constant
+	names := make(map[string]string, len(res.routeNames))
+	for name, route := range res.routeNames {
+		if name != meta.name {
+			names[name] = route
+		}
+	}
+	res.routeNames = names
+}
@@ -0,0 +1,121 @@
+package jshapi
+
+import (
+	"net/http"
+	"testing"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNamedRoutes(t *testing.T) {
+
+	Convey("Named Routes Tests", t, func() {
+
+		storage := &MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs, ListCount: 1}
+		foos := func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return jsh.List{}, nil
+		}
+
+		resource := NewResource(testResourceType)
+		resource.Get(storage.Get, Named("bar-detail"))
+		resource.List(storage.List)
+		resource.ToMany("foos", foos, Named("bar-foos"))
+		resource.Action("testAction", storage.Get, Named("bar-action"))
+		resource.HandleFuncC(
+			pat.Get("/search/:name"),
+			resource.Wrap("search", "/search/:name", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {}, Named("bar-search")),
+		)
+
+		api := New("api")
+		api.Add(resource)
+
+		Convey("->Reverse()", func() {
+
+			Convey("should build the URL of named routes", func() {
+				url, err := api.Reverse("bar-detail", Params{"id": "42"})
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "/api/bars/42")
+
+				url, err = api.Reverse("bar-foos", Params{"id": "42"})
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "/api/bars/42/foos")
+
+				url, err = api.Reverse("bar-action", Params{"id": "42"})
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "/api/bars/42/testAction")
+			})
+
+			Convey("should build the URL of wrapped custom routes", func() {
+				url, err := api.Reverse("bar-search", Params{"name": "a b/c"})
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "/api/bars/search/a%20b%2Fc")
+			})
+
+			Convey("should prefix the base URL", func() {
+				api.BaseURL("https://api.example.com")
+
+				url, err := api.Reverse("bar-detail", Params{"id": "42"})
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "https://api.example.com/api/bars/42")
+			})
+
+			Convey("should error on unknown names and bad params", func() {
+				_, err := api.Reverse("unknown", nil)
+				So(err, ShouldNotBeNil)
+
+				_, err = api.Reverse("bar-detail", nil)
+				So(err, ShouldNotBeNil)
+
+				_, err = api.Reverse("bar-detail", Params{"id": "42", "name": "x"})
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("should forget disabled routes", func() {
+				So(resource.Disable("GET", "/:id/testAction"), ShouldBeNil)
+
+				_, err := api.Reverse("bar-action", Params{"id": "42"})
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("should list names in the route registry", func() {
+			names := map[string]string{}
+			for _, route := range api.Routes() {
+				names[route.Pattern] = route.Name
+			}
+
+			So(names["/api/bars/:id"], ShouldEqual, "bar-detail")
+			So(names["/api/bars/:id/foos"], ShouldEqual, "bar-foos")
+			So(names["/api/bars"], ShouldEqual, "")
+		})
+
+		Convey("should panic on duplicate names", func() {
+			So(func() { resource.Patch(storage.Update, Named("bar-detail")) }, ShouldPanic)
+
+			other := NewResource("foos")
+			So(func() { other.Get(storage.Get, Named("bar-detail")) }, ShouldNotPanic)
+			So(func() { api.Add(other) }, ShouldPanic)
+
+			added := NewResource("bazs")
+			api.Add(added)
+			So(func() { added.Get(storage.Get, Named("bar-action")) }, ShouldPanic)
+		})
+
+		Convey("should keep names on clones", func() {
+			v2 := New("v2")
+			v2.Add(resource.Clone())
+
+			url, err := v2.Reverse("bar-search", Params{"name": "x"})
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "/v2/bars/search/x")
+
+			url, err = v2.Reverse("bar-detail", Params{"id": "1"})
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "/v2/bars/1")
+		})
+	})
+}
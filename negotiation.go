@@ -0,0 +1,268 @@
+package jshapi
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+const (
+	extParam     = "ext"
+	profileParam = "profile"
+)
+
+// mediaType holds the JSON API media type parameters negotiated for a request
+type mediaType struct {
+	extensions []string
+	profiles   []string
+}
+
+// String formats the negotiated parameters as a JSON API Content-Type
+func (m *mediaType) String() string {
+	params := map[string]string{}
+	if len(m.extensions) > 0 {
+		params[extParam] = strings.Join(m.extensions, " ")
+	}
+	if len(m.profiles) > 0 {
+		params[profileParam] = strings.Join(m.profiles, " ")
+	}
+
+	return mime.FormatMediaType(jsh.ContentType, params)
+}
+
+/*
+SupportExtension registers a JSON API extension URI as supported by the API.
+Requests using an extension that has not been registered are rejected with a 415,
+or a 406 when it is only requested via the Accept header.
+*/
+func (a *API) SupportExtension(uri string) {
+	a.checkRegistration("an extension")
+
+	a.extensions[uri] = true
+}
+
+/*
+SupportProfile registers a JSON API profile URI as supported by the API. Supported
+profiles requested via the Accept header are echoed back in the response
+Content-Type, and can be retrieved by handlers via Profiles(). Unsupported
+profiles are ignored as per the specification.
+*/
+func (a *API) SupportProfile(uri string) {
+	a.checkRegistration("a profile")
+
+	a.profiles[uri] = true
+}
+
+/*
+Extensions returns the JSON API extensions applied to the current request.
+*/
+func Extensions(ctx context.Context) []string {
+	negotiated, _ := ctx.Value(mediaTypeKey).(*mediaType)
+	if negotiated == nil {
+		return nil
+	}
+
+	return negotiated.extensions
+}
+
+/*
+Profiles returns the JSON API profiles applied to the current request.
+*/
+func Profiles(ctx context.Context) []string {
+	negotiated, _ := ctx.Value(mediaTypeKey).(*mediaType)
+	if negotiated == nil {
+		return nil
+	}
+
+	return negotiated.profiles
+}
+
+// negotiationMiddleware validates the ext and profile media type parameters of
+// incoming requests and echoes the negotiated ones in the response Content-Type.
+// Content-Type errors of POST and PATCH requests are left for the handlers to
+// report alongside the other write path checks, see MediaTypeError().
+func (a *API) negotiationMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		ctx = a.withLanguage(ctx, r)
+		ctx = a.withSimpleJSON(ctx, w, r)
+
+		negotiated, contentTypeErr := a.negotiateContentType(r)
+		if contentTypeErr != nil {
+			if r.Method != post && r.Method != patch {
+				SendHandler(ctx, w, r, contentTypeErr)
+				return
+			}
+
+			ctx = context.WithValue(ctx, mediaTypeErrorKey, contentTypeErr)
+		}
+
+		acceptErr := a.negotiateAccept(r, negotiated)
+		if acceptErr != nil {
+			SendHandler(ctx, w, r, acceptErr)
+			return
+		}
+
+		// Extensions and Profiles report nothing when no media type is recorded
+		if len(negotiated.extensions) > 0 || len(negotiated.profiles) > 0 {
+			ctx = context.WithValue(ctx, mediaTypeKey, negotiated)
+			w = &negotiatedWriter{ResponseWriter: w, contentType: negotiated.String()}
+		}
+
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+/*
+MediaTypeError returns the 415 error raised by an unusable request Content-Type,
+if any. Handlers parsing a request body of a POST or PATCH request are responsible
+for sending it.
+*/
+func MediaTypeError(ctx context.Context) *jsh.Error {
+	err, _ := ctx.Value(mediaTypeErrorKey).(*jsh.Error)
+	return err
+}
+
+// negotiateContentType checks the request Content-Type parameters against the
+// supported extensions
+func (a *API) negotiateContentType(r *http.Request) (*mediaType, *jsh.Error) {
+	negotiated := &mediaType{}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" || contentType == jsh.ContentType {
+		return negotiated, nil
+	}
+
+	name, params, err := mime.ParseMediaType(contentType)
+	if err != nil || name != jsh.ContentType {
+		return negotiated, nil
+	}
+
+	for param := range params {
+		if param != extParam && param != profileParam {
+			return negotiated, unsupportedMediaType(fmt.Sprintf(
+				"Media type parameter '%s' is not allowed", param,
+			))
+		}
+	}
+
+	for _, ext := range strings.Fields(params[extParam]) {
+		if !a.extensions[ext] {
+			return negotiated, unsupportedMediaType(fmt.Sprintf(
+				"Extension '%s' is not supported", ext,
+			))
+		}
+		negotiated.extensions = append(negotiated.extensions, ext)
+	}
+
+	return negotiated, nil
+}
+
+// negotiateAccept checks that at least one of the JSON API media ranges of the
+// Accept header can be served, and picks the profiles to apply
+func (a *API) negotiateAccept(r *http.Request, negotiated *mediaType) *jsh.Error {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return nil
+	}
+
+	var jsonAPI, rejected int
+	var reason string
+	for _, mediaRange := range strings.Split(accept, ",") {
+		name, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil || name != jsh.ContentType {
+			continue
+		}
+		jsonAPI++
+
+		reason = a.acceptableParams(params)
+		if reason != "" {
+			rejected++
+			continue
+		}
+
+		for _, profile := range strings.Fields(params[profileParam]) {
+			if a.profiles[profile] {
+				negotiated.profiles = append(negotiated.profiles, profile)
+			}
+		}
+		break
+	}
+
+	if jsonAPI > 0 && jsonAPI == rejected {
+		return &jsh.Error{
+			Title:  "Not Acceptable",
+			Detail: reason,
+			Status: http.StatusNotAcceptable,
+		}
+	}
+
+	return nil
+}
+
+// acceptableParams returns why a JSON API Accept media range cannot be served, or
+// an empty string if it can
+func (a *API) acceptableParams(params map[string]string) string {
+	for param := range params {
+		if param != extParam && param != profileParam && param != "q" {
+			return fmt.Sprintf("Media type parameter '%s' is not allowed", param)
+		}
+	}
+
+	for _, ext := range strings.Fields(params[extParam]) {
+		if !a.extensions[ext] {
+			return fmt.Sprintf("Extension '%s' is not supported", ext)
+		}
+	}
+
+	return ""
+}
+
+// isJSONAPIContentType checks that a Content-Type is the JSON API media type,
+// ignoring its parameters which are validated during negotiation
+func isJSONAPIContentType(contentType string) bool {
+	name, _, err := mime.ParseMediaType(contentType)
+	return err == nil && name == jsh.ContentType
+}
+
+// unsupportedMediaType builds the 415 error for unusable request media types
+func unsupportedMediaType(detail string) *jsh.Error {
+	return &jsh.Error{
+		Title:  "Unsupported Media Type",
+		Detail: detail,
+		Status: http.StatusUnsupportedMediaType,
+	}
+}
+
+// negotiatedWriter rewrites the JSON API Content-Type of a response to include the
+// negotiated media type parameters
+type negotiatedWriter struct {
+	http.ResponseWriter
+	contentType string
+	wroteHeader bool
+}
+
+func (w *negotiatedWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+
+		if w.Header().Get("Content-Type") == jsh.ContentType {
+			w.Header().Set("Content-Type", w.contentType)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *negotiatedWriter) Write(content []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(content)
+}
@@ -0,0 +1,96 @@
+package jshapi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNegotiation(t *testing.T) {
+
+	Convey("Content Negotiation Tests", t, func() {
+
+		const (
+			extension = "https://example.com/ext/supported"
+			profile   = "https://example.com/profiles/supported"
+		)
+
+		api := New("")
+		api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+		api.SupportExtension(extension)
+		api.SupportProfile(profile)
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/" + testResourceType
+		body := `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`
+
+		Convey("should reject unknown media type parameters with a 415", func() {
+			resp, _ := negotiationRequest("POST", url, jsh.ContentType+"; charset=utf-8", "", body)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnsupportedMediaType)
+		})
+
+		Convey("should reject unsupported extensions with a 415", func() {
+			resp, content := negotiationRequest("POST", url, jsh.ContentType+`; ext="https://example.com/ext/unknown"`, "", body)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnsupportedMediaType)
+			So(content, ShouldContainSubstring, "https://example.com/ext/unknown")
+		})
+
+		Convey("should accept supported extensions", func() {
+			resp, _ := negotiationRequest("POST", url, jsh.ContentType+`; ext="`+extension+`"`, "", body)
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+			So(resp.Header.Get("Content-Type"), ShouldEqual, jsh.ContentType+`; ext="`+extension+`"`)
+		})
+
+		Convey("should respond 406 when no Accept media range can be served", func() {
+			resp, _ := negotiationRequest("GET", url, "", jsh.ContentType+`; ext="https://example.com/ext/unknown"`, "")
+			So(resp.StatusCode, ShouldEqual, http.StatusNotAcceptable)
+		})
+
+		Convey("should serve the first acceptable media range", func() {
+			accept := jsh.ContentType + `; ext="https://example.com/ext/unknown", ` + jsh.ContentType
+			resp, _ := negotiationRequest("GET", url, "", accept, "")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("should echo applied profiles", func() {
+			accept := jsh.ContentType + `; profile="https://example.com/profiles/unknown ` + profile + `"`
+			resp, _ := negotiationRequest("GET", url, "", accept, "")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Content-Type"), ShouldEqual, jsh.ContentType+`; profile="`+profile+`"`)
+		})
+	})
+}
+
+func negotiationRequest(method, url, contentType, accept, body string) (*http.Response, string) {
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	if err != nil {
+		panic(err)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+
+	return resp, string(raw)
+}
@@ -0,0 +1,169 @@
+package jshapi
+
+import (
+	stdcontext "context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"goji.io/pat"
+	"goji.io/pattern"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+/*
+NestCRUD nests the resource under parent, scoping its CRUD routes to a parent
+object:
+
+	GET    /projects/:project_id/tasks
+	POST   /projects/:project_id/tasks
+	GET    /projects/:project_id/tasks/:id
+	PATCH  /projects/:project_id/tasks/:id
+	DELETE /projects/:project_id/tasks/:id
+
+The parent variable is named after the parent type, with a single trailing "s"
+removed, followed by "_id". Before every call to storage, the parent object is
+loaded with the Get storage of parent, which must already be registered, so that
+requests under a missing parent get its 404. storage then receives the ids of the
+parents, outermost first, resources nested several levels deep checking each of
+their parents in turn.
+
+Nested resources are routed by their parent, they must be nested before being
+added to the API, and the parent must be added as well. Self links and Location
+headers include the path of the parent objects, while objects of the resource
+included in the documents of other resources get no links.
+*/
+func (res *Resource) NestCRUD(parent *Resource, storage store.ScopedCRUD) {
+	defer res.record(func(clone *Resource) { clone.NestCRUD(parent, storage) })()
+
+	res.checkRegistration("a route")
+
+	if parent == nil || parent.storage.get == nil {
+		panic(fmt.Sprintf("jshapi: resource '%s' must be nested under a resource with a get storage", res.Type))
+	}
+	if res.api != nil {
+		panic(fmt.Sprintf("jshapi: resource '%s' must be nested before being added to an API", res.Type))
+	}
+
+	res.parent = parent
+	res.parentParam = strings.TrimSuffix(parent.Type, "s") + "_id"
+	res.parentKey = pattern.Variable(res.parentParam)
+	res.prefix = path.Join(parent.prefix, parent.CollectionPath(), ":"+res.parentParam)
+
+	// /:parent_id/resources and /:parent_id/resources/* of the parent
+	matcher := fmt.Sprintf("/:%s/%s", res.parentParam, res.CollectionPath())
+	parent.HandleC(pat.New(matcher), res)
+	parent.HandleC(pat.New(path.Join(matcher, "*")), res)
+
+	res.tx, _ = storage.(store.Transactional)
+
+	res.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		parentIDs, err := res.loadParents(ctx)
+		if HasError(err) {
+			return nil, err
+		}
+		return storage.Get(ctx, parentIDs, id)
+	})
+	res.Patch(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+		parentIDs, err := res.loadParents(ctx)
+		if HasError(err) {
+			return nil, err
+		}
+		return storage.Update(ctx, parentIDs, object)
+	})
+	res.Post(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+		parentIDs, err := res.loadParents(ctx)
+		if HasError(err) {
+			return nil, err
+		}
+		return storage.Save(ctx, parentIDs, object)
+	})
+	res.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+		parentIDs, err := res.loadParents(ctx)
+		if HasError(err) {
+			return nil, err
+		}
+		return storage.List(ctx, parentIDs)
+	})
+	res.Delete(func(ctx context.Context, id string) jsh.ErrorType {
+		parentIDs, err := res.loadParents(ctx)
+		if HasError(err) {
+			return err
+		}
+		return storage.Delete(ctx, parentIDs, id)
+	})
+}
+
+// parentIDs returns the ids of the parents of a nested resource in the request
+// path, outermost first
+func (res *Resource) parentIDs(ctx context.Context) []string {
+	ids := []string{}
+	for nested := res; nested.parent != nil; nested = nested.parent {
+		id, _ := ctx.Value(nested.parentKey).(string)
+		ids = append([]string{id}, ids...)
+	}
+
+	return ids
+}
+
+// loadParents checks that the parent object of a nested resource exists through
+// the Get storage of the parent, returning the ids of the parents
+func (res *Resource) loadParents(ctx context.Context) ([]string, jsh.ErrorType) {
+	ids := res.parentIDs(ctx)
+
+	_, err := res.parent.storage.get(ctx, ids[len(ids)-1])
+	if HasError(err) {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// scopeRequest keeps the parent ids of a request to a nested resource in its
+// context, by variable name, for the links generated from it
+func (res *Resource) scopeRequest(ctx context.Context, r *http.Request) *http.Request {
+	if res.parent == nil {
+		return r
+	}
+
+	scope := map[string]string{}
+	for nested := res; nested.parent != nil; nested = nested.parent {
+		id, found := ctx.Value(nested.parentKey).(string)
+		if found {
+			scope[nested.parentParam] = id
+		}
+	}
+
+	return r.WithContext(stdcontext.WithValue(r.Context(), parentScopeKey, scope))
+}
+
+// requestObjectPath is the path of an object of the resource, with the parent ids
+// of the request in place of the variables of nested resources, false if the
+// request lacks one of them
+func (res *Resource) requestObjectPath(r *http.Request, id string) (string, bool) {
+	if res.parent == nil {
+		return res.objectPath(id), true
+	}
+
+	scope, _ := r.Context().Value(parentScopeKey).(map[string]string)
+
+	segments := strings.Split(res.basePath(res.CollectionPath()), "/")
+	for index, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+
+		value, found := scope[segment[1:]]
+		if !found {
+			return "", false
+		}
+		segments[index] = url.PathEscape(value)
+	}
+
+	return strings.Join(segments, "/") + "/" + res.idPath(id), true
+}
@@ -0,0 +1,137 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// scopedStorage records the parent ids given to each call
+type scopedStorage struct {
+	parentIDs [][]string
+}
+
+func (s *scopedStorage) Save(ctx context.Context, parentIDs []string, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	s.parentIDs = append(s.parentIDs, parentIDs)
+	object.ID = "7"
+	return object, nil
+}
+
+func (s *scopedStorage) Get(ctx context.Context, parentIDs []string, id string) (*jsh.Object, jsh.ErrorType) {
+	s.parentIDs = append(s.parentIDs, parentIDs)
+	return jsh.NewObject(id, "tasks", map[string]string{"parent": strings.Join(parentIDs, "/")})
+}
+
+func (s *scopedStorage) List(ctx context.Context, parentIDs []string) (jsh.List, jsh.ErrorType) {
+	s.parentIDs = append(s.parentIDs, parentIDs)
+	object, err := jsh.NewObject("7", "tasks", map[string]string{})
+	return jsh.List{object}, err
+}
+
+func (s *scopedStorage) Update(ctx context.Context, parentIDs []string, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	s.parentIDs = append(s.parentIDs, parentIDs)
+	return object, nil
+}
+
+func (s *scopedStorage) Delete(ctx context.Context, parentIDs []string, id string) jsh.ErrorType {
+	s.parentIDs = append(s.parentIDs, parentIDs)
+	return nil
+}
+
+func TestNestCRUD(t *testing.T) {
+
+	Convey("Nested Resource Tests", t, func() {
+
+		existing := func(resourceType string, ids ...string) *Resource {
+			resource := NewResource(resourceType)
+			resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+				for _, existing := range ids {
+					if id == existing {
+						return jsh.NewObject(id, resourceType, map[string]string{})
+					}
+				}
+				return nil, jsh.NotFound(resourceType, id)
+			})
+			return resource
+		}
+
+		projects := existing("projects", "1")
+		storage := &scopedStorage{}
+		tasks := NewResource("tasks")
+		tasks.NestCRUD(projects, storage)
+
+		api := New("api")
+		api.EmitSelfLinks(true)
+		api.Add(projects)
+		api.Add(tasks)
+
+		serve := func(method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			if body != "" {
+				request.Header.Set("Content-Type", jsh.ContentType)
+			}
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should hand the parent ids to storage", func() {
+			recorder := serve("GET", "/api/projects/1/tasks/3", "")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(storage.parentIDs, ShouldResemble, [][]string{{"1"}})
+			So(recorder.Body.String(), ShouldContainSubstring, `"href": "/api/projects/1/tasks/3"`)
+		})
+
+		Convey("should serve the whole CRUD under the parent", func() {
+			So(serve("GET", "/api/projects/1/tasks", "").Code, ShouldEqual, http.StatusOK)
+			So(serve("PATCH", "/api/projects/1/tasks/3", `{"data": {"type": "tasks", "id": "3", "attributes": {}}}`).Code, ShouldEqual, http.StatusOK)
+			So(serve("DELETE", "/api/projects/1/tasks/3", "").Code, ShouldEqual, http.StatusNoContent)
+			So(storage.parentIDs, ShouldResemble, [][]string{{"1"}, {"1"}, {"1"}})
+		})
+
+		Convey("should include the parent path in Location headers", func() {
+			recorder := serve("POST", "/api/projects/1/tasks", `{"data": {"type": "tasks", "attributes": {}}}`)
+
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+			So(recorder.Header().Get("Location"), ShouldEqual, "/api/projects/1/tasks/7")
+		})
+
+		Convey("should 404 under missing parents without calling storage", func() {
+			So(serve("GET", "/api/projects/2/tasks/3", "").Code, ShouldEqual, http.StatusNotFound)
+			So(serve("POST", "/api/projects/2/tasks", `{"data": {"type": "tasks", "attributes": {}}}`).Code, ShouldEqual, http.StatusNotFound)
+			So(storage.parentIDs, ShouldBeEmpty)
+		})
+
+		Convey("should keep serving the parent routes", func() {
+			So(serve("GET", "/api/projects/1", "").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("should report nested routes with the parent variable", func() {
+			So(api.RouteTree(), ShouldContainSubstring, "/api/projects/:project_id/tasks/:id")
+		})
+
+		Convey("should check every parent of deeply nested resources", func() {
+			comments := NewResource("comments")
+			deepStorage := &scopedStorage{}
+			comments.NestCRUD(tasks, deepStorage)
+			api.Add(comments)
+
+			So(serve("GET", "/api/projects/1/tasks/3/comments/5", "").Code, ShouldEqual, http.StatusOK)
+			So(deepStorage.parentIDs, ShouldResemble, [][]string{{"1", "3"}})
+
+			So(serve("GET", "/api/projects/2/tasks/3/comments/5", "").Code, ShouldEqual, http.StatusNotFound)
+			So(deepStorage.parentIDs, ShouldHaveLength, 1)
+		})
+
+		Convey("should panic without a parent get storage", func() {
+			So(func() { NewResource("tasks").NestCRUD(NewResource("projects"), storage) }, ShouldPanic)
+		})
+	})
+}
@@ -0,0 +1,141 @@
+package jshapi
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+NotFoundFunc customizes the 404 error sent for a missing object of a resource,
+identified by id, see Resource.NotFoundError. Returning nil sends the default
+error.
+*/
+type NotFoundFunc func(ctx context.Context, id string) *MissingObjectError
+
+/*
+MissingObjectError is the 404 error customized by a NotFoundFunc. jsh.Error has no
+meta nor links members, so it carries its own, and no status: it is always sent
+as a 404. An empty Title or Detail is replaced by that of the default error.
+*/
+type MissingObjectError struct {
+	Title  string
+	Detail string
+	// About is sent as the `about` link of the error object when set
+	About string
+	Meta  map[string]interface{}
+}
+
+// Error implements error
+func (e *MissingObjectError) Error() string {
+	return e.jshError().Error()
+}
+
+// Validate implements jsh.Sendable
+func (e *MissingObjectError) Validate(r *http.Request, response bool) *jsh.Error {
+	return e.jshError().Validate(r, response)
+}
+
+// StatusCode implements jsh.ErrorType
+func (e *MissingObjectError) StatusCode() int {
+	return http.StatusNotFound
+}
+
+// jshError is the error object sent for the missing object
+func (e *MissingObjectError) jshError() *jsh.Error {
+	return &jsh.Error{
+		Title:  e.Title,
+		Detail: e.Detail,
+		Status: http.StatusNotFound,
+	}
+}
+
+// members are the meta and links members of the error object sent, if any
+func (e *MissingObjectError) members() map[string]interface{} {
+	members := map[string]interface{}{}
+	if len(e.Meta) > 0 {
+		members["meta"] = e.Meta
+	}
+	if e.About != "" {
+		members["links"] = map[string]string{"about": e.About}
+	}
+
+	return members
+}
+
+/*
+NotFoundError customizes the 404 errors sent for the missing objects of the
+resource, such as pointing clients to a search or archive:
+
+	orders.NotFoundError(func(ctx context.Context, id string) *jshapi.MissingObjectError {
+		return &jshapi.MissingObjectError{
+			Detail: fmt.Sprintf("Order %s does not exist, it may have been archived", id),
+			About:  "https://example.com/orders/archive",
+			Meta:   map[string]interface{}{"search": "/orders?filter[ref]=" + id},
+		}
+	})
+
+fn is called whenever `GET`, `PATCH` or `DELETE /resource/:id`, or an action, get
+a nil object and error from storage, or a 404 error such as jsh.NotFound. The
+routes of relationships use the customization of the related resource, the one
+serving the type given to ToOne or ToMany, or else the relationship name, with an
+empty id since the related object is unknown. Objects missing from include paths
+are left out of compound documents, as before.
+*/
+func (res *Resource) NotFoundError(fn NotFoundFunc) {
+	res.checkRegistration("a not found error")
+
+	res.notFound = fn
+}
+
+/*
+missingObject is sent when a store.Get returns neither an object nor an error,
+which storage commonly does for a missing row. "relationship" names the ToOne
+relationship being fetched, it is empty for the resource itself.
+*/
+func (res *Resource) missingObject(ctx context.Context, relationship string, id string) jsh.ErrorType {
+	return res.customNotFound(ctx, relationship, id, res.missingObjectError(relationship, id))
+}
+
+// notFoundError returns the error sent for a storage error, 404 errors being
+// customized as missing objects
+func (res *Resource) notFoundError(ctx context.Context, relationship string, id string, err jsh.ErrorType) jsh.ErrorType {
+	if err.StatusCode() != http.StatusNotFound {
+		return err
+	}
+
+	return res.customNotFound(ctx, relationship, id, err)
+}
+
+// customNotFound returns the 404 error of the resource, or of the related
+// resource of relationship, for the missing object, or fallback if not customized
+func (res *Resource) customNotFound(ctx context.Context, relationship string, id string, fallback jsh.ErrorType) jsh.ErrorType {
+	owner, ownerID := res, id
+	if relationship != "" {
+		owner, ownerID = res.linkTarget(res.relatedTypes[relationship]), ""
+		if owner == nil {
+			owner = res.linkTarget(relationship)
+		}
+	}
+	if owner == nil || owner.notFound == nil {
+		return fallback
+	}
+
+	custom := owner.notFound(ctx, ownerID)
+	if custom == nil {
+		return fallback
+	}
+
+	missing := *custom
+	defaults := res.missingObjectError(relationship, id)
+	if missing.Title == "" {
+		missing.Title = defaults.Title
+	}
+	if missing.Detail == "" {
+		missing.Detail = defaults.Detail
+	}
+
+	return &missing
+}
@@ -0,0 +1,95 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNotFoundError(t *testing.T) {
+
+	Convey("Not Found Error Tests", t, func() {
+
+		orders := NewResource("orders")
+		orders.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			switch id {
+			case "missing":
+				return nil, nil
+			case "gone":
+				return nil, jsh.NotFound("orders", id)
+			}
+			return jsh.NewObject(id, "orders", map[string]string{"ref": id})
+		})
+		orders.ToOne("customers", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return nil, nil
+		})
+		orders.ToMany("items", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return nil, jsh.NotFound("items", id)
+		})
+		orders.NotFoundError(func(ctx context.Context, id string) *MissingObjectError {
+			return &MissingObjectError{
+				Detail: "Order " + id + " may have been archived",
+				About:  "https://example.com/archive",
+				Meta:   map[string]interface{}{"search": "/orders?filter[ref]=" + id},
+			}
+		})
+
+		customers := NewResource("customers")
+		customers.NotFoundError(func(ctx context.Context, id string) *MissingObjectError {
+			return &MissingObjectError{Title: "Unknown Customer"}
+		})
+
+		api := New("")
+		api.Add(orders)
+		api.Add(customers)
+		api.Add(NewResource("items"))
+
+		get := func(url string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+			return recorder
+		}
+
+		Convey("->NotFoundError()", func() {
+
+			Convey("should customize missing objects", func() {
+				recorder := get("/orders/missing")
+				So(recorder.Code, ShouldEqual, http.StatusNotFound)
+				So(recorder.Body.String(), ShouldContainSubstring, `"title": "Not Found"`)
+				So(recorder.Body.String(), ShouldContainSubstring, `"detail": "Order missing may have been archived"`)
+				So(recorder.Body.String(), ShouldContainSubstring, `"status": "404"`)
+				So(recorder.Body.String(), ShouldContainSubstring, `"about": "https://example.com/archive"`)
+				So(recorder.Body.String(), ShouldContainSubstring, `"search": "/orders?filter[ref]=missing"`)
+			})
+
+			Convey("should customize not found storage errors", func() {
+				recorder := get("/orders/gone")
+				So(recorder.Code, ShouldEqual, http.StatusNotFound)
+				So(recorder.Body.String(), ShouldContainSubstring, `"detail": "Order gone may have been archived"`)
+			})
+
+			Convey("should use the customization of the related resource", func() {
+				recorder := get("/orders/1/customer")
+				So(recorder.Code, ShouldEqual, http.StatusNotFound)
+				So(recorder.Body.String(), ShouldContainSubstring, `"title": "Unknown Customer"`)
+				So(recorder.Body.String(), ShouldContainSubstring, `"detail": "No 'customer' is related to the resource of type 'orders' with ID: 1"`)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "archived")
+			})
+
+			Convey("should send the default error without a related customization", func() {
+				recorder := get("/orders/1/items")
+				So(recorder.Code, ShouldEqual, http.StatusNotFound)
+				So(recorder.Body.String(), ShouldContainSubstring, `"detail": "No resource of type 'items' exists for ID: 1"`)
+			})
+
+			Convey("should leave found objects untouched", func() {
+				So(get("/orders/1").Code, ShouldEqual, http.StatusOK)
+			})
+		})
+	})
+}
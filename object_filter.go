@@ -0,0 +1,86 @@
+package jshapi
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+ObjectFilter performs object-level authorization of collections, hiding the objects
+the caller can't see rather than refusing the whole request:
+
+	func (f *ownerFilter) Permit(ctx context.Context, object *jsh.Object) bool {
+		return ownerOf(object) == userFromContext(ctx)
+	}
+
+Permit is called for every object of the filtered resource type sent by list and
+to-many routes, or included in compound documents, whichever resource serves the
+request. Objects it rejects are left out before serialization.
+*/
+type ObjectFilter interface {
+	Permit(ctx context.Context, object *jsh.Object) bool
+}
+
+// ObjectFilterFunc allows the use of an ordinary function as an ObjectFilter
+type ObjectFilterFunc func(ctx context.Context, object *jsh.Object) bool
+
+// Permit implements ObjectFilter
+func (f ObjectFilterFunc) Permit(ctx context.Context, object *jsh.Object) bool {
+	return f(ctx, object)
+}
+
+/*
+SetObjectFilter installs the ObjectFilter of the objects of the resource, applied
+to list, to-many and export results and to included objects. Counts returned by
+storage, such as those of meta-only relationships, are sent as is.
+*/
+func (res *Resource) SetObjectFilter(filter ObjectFilter) {
+	res.checkRegistration("an object filter")
+
+	res.objectFilter = filter
+}
+
+// permits reports whether the object filter of the resource serving objects of its
+// type, if any, lets the caller see object
+func (res *Resource) permits(ctx context.Context, object *jsh.Object) bool {
+	if object == nil {
+		return true
+	}
+
+	target := res.linkTarget(object.Type)
+	if target == nil || target.objectFilter == nil {
+		return true
+	}
+
+	return target.objectFilter.Permit(ctx, object)
+}
+
+/*
+permitted returns list without the objects the caller can't see. The list is
+returned as is when every object is permitted, and only the objects kept are
+referenced otherwise, leaving storage's list untouched.
+*/
+func (res *Resource) permitted(ctx context.Context, list jsh.List) jsh.List {
+	var kept jsh.List
+
+	for index, object := range list {
+		if res.permits(ctx, object) {
+			if kept != nil {
+				kept = append(kept, object)
+			}
+			continue
+		}
+
+		if kept == nil {
+			kept = make(jsh.List, index, len(list)-1)
+			copy(kept, list[:index])
+		}
+	}
+
+	if kept == nil {
+		return list
+	}
+
+	return kept
+}
@@ -0,0 +1,107 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestObjectFilter(t *testing.T) {
+
+	Convey("Object Filter Tests", t, func() {
+
+		// even ids are classified
+		object := func(id string, resourceType string) *jsh.Object {
+			secret := ""
+			if (id[len(id)-1]-'0')%2 == 0 {
+				secret = "classified-" + resourceType + "-" + id
+			}
+			created, _ := jsh.NewObject(id, resourceType, map[string]string{"secret": secret})
+			return created
+		}
+		visible := ObjectFilterFunc(func(ctx context.Context, object *jsh.Object) bool {
+			return (object.ID[len(object.ID)-1]-'0')%2 == 1
+		})
+
+		listed := jsh.List{object("1", "posts"), object("2", "posts"), object("3", "posts")}
+
+		posts := NewResource("posts")
+		posts.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+			return listed, nil
+		})
+		posts.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return object(id, "posts"), nil
+		})
+		posts.ToMany("comments", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return jsh.List{object("1", "comments"), object("2", "comments")}, nil
+		})
+
+		comments := NewResource("comments")
+
+		api := New("")
+		api.Add(posts)
+		api.Add(comments)
+
+		get := func(url string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+			return recorder
+		}
+
+		Convey("should send every object without a filter", func() {
+			recorder := get("/posts")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, "classified-posts-2")
+		})
+
+		Convey("->SetObjectFilter()", func() {
+			posts.SetObjectFilter(visible)
+			comments.SetObjectFilter(visible)
+
+			Convey("should leave filtered out objects out of list responses", func() {
+				recorder := get("/posts")
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "classified")
+				So(recorder.Body.String(), ShouldContainSubstring, `"id": "3"`)
+			})
+
+			Convey("should leave the list of storage untouched", func() {
+				get("/posts")
+				So(listed, ShouldHaveLength, 3)
+				So(listed[1].ID, ShouldEqual, "2")
+			})
+
+			Convey("should filter to-many results by the type of their objects", func() {
+				recorder := get("/posts/1/comments")
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "classified")
+				So(recorder.Body.String(), ShouldContainSubstring, `"id": "1"`)
+			})
+
+			Convey("should filter included objects", func() {
+				recorder := get("/posts/1?include=comments")
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "classified")
+				So(recorder.Body.String(), ShouldContainSubstring, `"type": "comments"`)
+			})
+
+			Convey("should filter exports", func() {
+				posts.Export(NDJSON())
+
+				recorder := get("/posts/export")
+
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "classified")
+			})
+		})
+	})
+}
@@ -0,0 +1,165 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+/*
+ResourceOption configures a resource before any of its routes are registered, see
+NewResource and Apply:
+
+	resource := jshapi.NewResource("users",
+		jshapi.WithPrefix("admin"),
+		jshapi.WithClientIDPolicy(jshapi.ClientIDForbidden),
+	)
+*/
+type ResourceOption func(*Resource)
+
+// WithPrefix routes the resource under `/(api prefix/)<prefix>/<type>`
+func WithPrefix(prefix string) ResourceOption {
+	return func(res *Resource) {
+		res.prefix = prefix
+	}
+}
+
+/*
+WithCollectionPath routes the resource under `/(api prefix/)<segment>` rather than
+under its type, such as `/people` for objects of type "person". Location headers
+and links use the collection path as well, while objects keep being sent, and
+checked, with the resource type.
+*/
+func WithCollectionPath(segment string) ResourceOption {
+	return func(res *Resource) {
+		res.collection = strings.Trim(segment, "/")
+	}
+}
+
+// CollectionPath returns the segment the resource is routed under, its type
+// unless set with WithCollectionPath
+func (res *Resource) CollectionPath() string {
+	if res.collection == "" {
+		return res.Type
+	}
+
+	return res.collection
+}
+
+// WithSender sends the responses of the resource with sender rather than the
+// package level SendHandler
+func WithSender(sender Sender) ResourceOption {
+	return func(res *Resource) {
+		res.sender = sender
+	}
+}
+
+// WithPluralizer names the relationships registered by ToMany with pluralize, in
+// place of appending an "s" to names that don't already end with one
+func WithPluralizer(pluralize func(string) string) ResourceOption {
+	return func(res *Resource) {
+		res.pluralize = pluralize
+	}
+}
+
+// WithClientIDPolicy sets whether objects created through the resource may, or
+// must, carry a client generated ID
+func WithClientIDPolicy(policy ClientIDPolicy) ResourceOption {
+	return func(res *Resource) {
+		res.clientIDs = policy
+	}
+}
+
+/*
+Apply applies options to a resource. Options change the patterns of the routes
+registered afterwards, so applying them once a route is registered panics.
+*/
+func (res *Resource) Apply(opts ...ResourceOption) {
+	res.checkRegistration("options")
+
+	if len(res.registry.pending) > 0 || res.registry.isDone() {
+		panic(fmt.Sprintf("jshapi: unable to apply options to resource '%s', routes are already registered", res.Type))
+	}
+
+	for _, opt := range opts {
+		opt(res)
+	}
+}
+
+// send sends a response, prepared and with self links when enabled, with the
+// resource sender, or SendHandler
+func (res *Resource) send(ctx context.Context, w http.ResponseWriter, r *http.Request, sendable jsh.Sendable) {
+	res.deliver(ctx, w, r, res.withLinks(r, res.prepare(ctx, sendable)))
+}
+
+// prepare returns the sendable with the attributes of its objects as sent, see
+// encode, and redacted by the field policy, the objects of storage left untouched
+func (res *Resource) prepare(ctx context.Context, sendable jsh.Sendable) jsh.Sendable {
+	return res.redact(ctx, res.encode(ctx, sendable))
+}
+
+// deliver sends a prepared sendable through the sender of the resource, retryable
+// storage errors as their JSON API error with a Retry-After header
+func (res *Resource) deliver(ctx context.Context, w http.ResponseWriter, r *http.Request, sendable jsh.Sendable) {
+	retryable, isRetryable := sendable.(store.RetryableError)
+	if isRetryable {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryable.RetryAfter().Seconds())))
+		sendable = retryable.JSONAPIError()
+	}
+
+	if res.api != nil && sendingAPI(ctx) != res.api {
+		ctx = context.WithValue(ctx, sendingAPIKey, res.api)
+	}
+
+	if res.sender != nil {
+		res.sender(ctx, w, r, sendable)
+		return
+	}
+
+	SendHandler(ctx, w, r, sendable)
+}
+
+// ClientIDPolicy sets how a resource treats client generated IDs on creation
+type ClientIDPolicy int
+
+const (
+	// ClientIDAllowed lets clients choose the ID of the objects they create, the
+	// default
+	ClientIDAllowed ClientIDPolicy = iota
+	// ClientIDForbidden rejects created objects carrying an ID with a 403, as the
+	// specification requires from servers not supporting client generated IDs
+	ClientIDForbidden
+	// ClientIDRequired rejects created objects without an ID with a 400
+	ClientIDRequired
+)
+
+// clientIDError checks an object to create against the client ID policy
+func (res *Resource) clientIDError(object *jsh.Object) *jsh.Error {
+	switch {
+	case res.clientIDs == ClientIDForbidden && object.ID != "":
+		err := &jsh.Error{
+			Title:  "Forbidden",
+			Detail: fmt.Sprintf("Client generated IDs are not supported for resources of type '%s'", res.Type),
+			Status: http.StatusForbidden,
+		}
+		err.Source.Pointer = "/data/id"
+		return err
+
+	case res.clientIDs == ClientIDRequired && object.ID == "":
+		err := &jsh.Error{
+			Title:  "Bad Request",
+			Detail: fmt.Sprintf("Resources of type '%s' require a client generated ID", res.Type),
+			Status: http.StatusBadRequest,
+		}
+		err.Source.Pointer = "/data/id"
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestResourceOptions(t *testing.T) {
+
+	Convey("Resource Option Tests", t, func() {
+
+		storage := &MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs, ListCount: 1}
+
+		send := func(api *API, method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		create := `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`
+		createWithID := `{"data": {"type": "bars", "id": "5", "attributes": {"foo": "bar"}}}`
+
+		Convey("->WithPrefix()", func() {
+			resource := NewCRUDResource(testResourceType, storage, WithPrefix("admin"))
+
+			api := New("api")
+			api.Add(resource)
+
+			Convey("should route the resource under the prefix", func() {
+				So(send(api, "GET", "/api/admin/bars", "").Code, ShouldEqual, http.StatusOK)
+				So(send(api, "GET", "/api/admin/bars/1", "").Code, ShouldEqual, http.StatusOK)
+				So(send(api, "GET", "/api/bars", "").Code, ShouldEqual, http.StatusNotFound)
+			})
+
+			Convey("should report prefixed routes", func() {
+				So(api.RouteTree(), ShouldContainSubstring, "GET - /api/admin/bars/:id\n")
+
+				recorder := send(api, "POST", "/api/admin/bars", createWithID)
+				So(recorder.Header().Get("Location"), ShouldStartWith, "/api/admin/bars/")
+			})
+		})
+
+		Convey("->WithCollectionPath()", func() {
+			people := &MockStorage{ResourceType: "person", ResourceAttributes: testObjAttrs, ListCount: 1}
+			resource := NewCRUDResource("person", people, WithCollectionPath("people"))
+
+			api := New("api")
+			api.EmitSelfLinks(true)
+			api.Add(resource)
+
+			Convey("should route the resource under the collection path", func() {
+				So(resource.CollectionPath(), ShouldEqual, "people")
+				So(send(api, "GET", "/api/people/1", "").Code, ShouldEqual, http.StatusOK)
+				So(send(api, "GET", "/api/person/1", "").Code, ShouldEqual, http.StatusNotFound)
+				So(api.RouteTree(), ShouldContainSubstring, "GET - /api/people/:id\n")
+			})
+
+			Convey("should build locations and links with the collection path", func() {
+				recorder := send(api, "POST", "/api/people", `{"data": {"type": "person", "attributes": {"foo": "bar"}}}`)
+				So(recorder.Code, ShouldEqual, http.StatusCreated)
+				So(recorder.Header().Get("Location"), ShouldStartWith, "/api/people/")
+
+				recorder = send(api, "GET", "/api/people/1", "")
+				So(recorder.Body.String(), ShouldContainSubstring, `"type": "person"`)
+				So(recorder.Body.String(), ShouldContainSubstring, `/api/people/1"`)
+
+				objectPath, err := resource.ObjectPath("1")
+				So(err, ShouldBeNil)
+				So(objectPath, ShouldEqual, "/api/people/1")
+			})
+
+			Convey("should check objects against the type", func() {
+				recorder := send(api, "POST", "/api/people", `{"data": {"type": "people", "attributes": {"foo": "bar"}}}`)
+				So(recorder.Code, ShouldEqual, http.StatusConflict)
+			})
+
+			Convey("should reject resources conflicting by type or path", func() {
+				So(func() { api.Add(NewResource("person")) }, ShouldPanicWith,
+					"jshapi: resource type 'person' is already registered")
+				So(func() { api.Add(NewResource("folks", WithCollectionPath("people"))) }, ShouldPanicWith,
+					"jshapi: path '/api/people' of resource 'folks' is already routed to resource 'person'")
+				So(func() { api.Add(NewResource("people")) }, ShouldPanicWith,
+					"jshapi: path '/api/people' of resource 'people' is already routed to resource 'person'")
+				So(func() { api.Add(NewResource("person2", WithCollectionPath("person"))) }, ShouldNotPanic)
+			})
+		})
+
+		Convey("->WithSender()", func() {
+			var sent jsh.Sendable
+			sender := func(ctx context.Context, w http.ResponseWriter, r *http.Request, sendable jsh.Sendable) {
+				sent = sendable
+				w.WriteHeader(http.StatusTeapot)
+			}
+
+			resource := NewCRUDResource(testResourceType, storage, WithSender(sender))
+
+			api := New("")
+			api.Add(resource)
+
+			Convey("should send the resource responses", func() {
+				So(send(api, "GET", "/bars/1", "").Code, ShouldEqual, http.StatusTeapot)
+				So(sent, ShouldHaveSameTypeAs, &jsh.Object{})
+
+				So(send(api, "PUT", "/bars/1", "").Code, ShouldEqual, http.StatusTeapot)
+				So(sent, ShouldHaveSameTypeAs, &jsh.Error{})
+			})
+		})
+
+		Convey("->WithPluralizer()", func() {
+			pluralize := func(name string) string {
+				if name == "person" {
+					return "people"
+				}
+				return name + "s"
+			}
+
+			toMany := func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+				return jsh.List{}, nil
+			}
+
+			resource := NewResource(testResourceType, WithPluralizer(pluralize))
+			resource.ToMany("person", toMany)
+			resource.ToManyExact("owners", toMany)
+
+			Convey("should name ToMany relationships", func() {
+				So(resource.Relationships, ShouldContainKey, "people")
+				So(resource.Relationships, ShouldContainKey, "owners")
+			})
+		})
+
+		Convey("->WithClientIDPolicy()", func() {
+
+			Convey("should allow client generated IDs by default", func() {
+				api := New("")
+				api.Add(NewCRUDResource(testResourceType, storage))
+
+				So(send(api, "POST", "/bars", create).Code, ShouldEqual, http.StatusCreated)
+				So(send(api, "POST", "/bars", createWithID).Code, ShouldEqual, http.StatusCreated)
+			})
+
+			Convey("should forbid client generated IDs", func() {
+				api := New("")
+				api.Add(NewCRUDResource(testResourceType, storage, WithClientIDPolicy(ClientIDForbidden)))
+
+				So(send(api, "POST", "/bars", create).Code, ShouldEqual, http.StatusCreated)
+
+				recorder := send(api, "POST", "/bars", createWithID)
+				So(recorder.Code, ShouldEqual, http.StatusForbidden)
+				So(recorder.Body.String(), ShouldContainSubstring, `"pointer": "/data/id"`)
+			})
+
+			Convey("should require client generated IDs", func() {
+				api := New("")
+				api.Add(NewCRUDResource(testResourceType, storage, WithClientIDPolicy(ClientIDRequired)))
+
+				So(send(api, "POST", "/bars", createWithID).Code, ShouldEqual, http.StatusCreated)
+				So(send(api, "POST", "/bars", create).Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("->Apply()", func() {
+			resource := NewResource(testResourceType)
+
+			Convey("should apply options before routes are registered", func() {
+				resource.Apply(WithPrefix("admin"))
+				So(resource.fullPattern("/:id"), ShouldEqual, "/admin/bars/:id")
+			})
+
+			Convey("should panic once routes are registered", func() {
+				resource.Get(storage.Get)
+				So(func() { resource.Apply(WithPrefix("admin")) }, ShouldPanicWith,
+					"jshapi: unable to apply options to resource 'bars', routes are already registered")
+			})
+		})
+	})
+}
@@ -0,0 +1,60 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// MethodOverrideHeader is the header used by limited clients to tunnel PATCH and
+// DELETE requests through POST
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+/*
+AllowMethodOverride enables support for the X-HTTP-Method-Override header, for
+clients sitting behind proxies that only let GET and POST through. A POST request
+carrying the header is routed, logged and handled as the PATCH or DELETE request
+it overrides to. Any other override value, or the header on a non-POST request, is
+rejected with a 400. When not enabled, the header is ignored entirely.
+*/
+func (a *API) AllowMethodOverride() {
+	a.checkRegistration("method overrides")
+
+	a.methodOverride = true
+}
+
+// overrideMethod rewrites the effective method of a request from its override
+// header, if any
+func overrideMethod(r *http.Request) *jsh.Error {
+	override := strings.ToUpper(strings.TrimSpace(r.Header.Get(MethodOverrideHeader)))
+	if override == "" {
+		return nil
+	}
+
+	if r.Method != post {
+		return methodOverrideError(fmt.Sprintf(
+			"Method override is only allowed on POST requests, got %s", r.Method,
+		))
+	}
+
+	switch override {
+	case patch, delete:
+		r.Method = override
+		return nil
+	default:
+		return methodOverrideError(fmt.Sprintf(
+			"Method override to '%s' is not allowed, expected PATCH or DELETE", override,
+		))
+	}
+}
+
+// methodOverrideError builds the 400 error for unusable method overrides
+func methodOverrideError(detail string) *jsh.Error {
+	return &jsh.Error{
+		Title:  "Bad Request",
+		Detail: detail,
+		Status: http.StatusBadRequest,
+	}
+}
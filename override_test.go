@@ -0,0 +1,95 @@
+package jshapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMethodOverride(t *testing.T) {
+
+	Convey("Method Override Tests", t, func() {
+
+		api := New("")
+		api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+
+		// record the method seen by middleware, as loggers and metrics would
+		var seenMethod string
+		api.UseC(func(next goji.Handler) goji.Handler {
+			return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				seenMethod = r.Method
+				next.ServeHTTPC(ctx, w, r)
+			})
+		})
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/" + testResourceType + "/1"
+		body := `{"data": {"type": "bars", "id": "1", "attributes": {"foo": "bar"}}}`
+
+		Convey("should ignore the header when not enabled", func() {
+			resp := overrideRequest("POST", url, "PATCH", body)
+			So(resp.StatusCode, ShouldNotEqual, http.StatusOK)
+			So(seenMethod, ShouldNotEqual, "PATCH")
+		})
+
+		Convey("when enabled", func() {
+			api.AllowMethodOverride()
+
+			Convey("should route POST requests as PATCH", func() {
+				resp := overrideRequest("POST", url, "patch", body)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(seenMethod, ShouldEqual, "PATCH")
+			})
+
+			Convey("should route POST requests as DELETE", func() {
+				resp := overrideRequest("POST", url, "DELETE", "")
+				So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+				So(seenMethod, ShouldEqual, "DELETE")
+			})
+
+			Convey("should reject overrides to GET or unknown methods", func() {
+				resp := overrideRequest("POST", url, "GET", "")
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+
+				resp = overrideRequest("POST", url, "PURGE", "")
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+
+			Convey("should reject overrides from GET", func() {
+				resp := overrideRequest("GET", url, "DELETE", "")
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+
+			Convey("should leave requests without the header untouched", func() {
+				resp := overrideRequest("GET", url, "", "")
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			})
+		})
+	})
+}
+
+// overrideRequest sends a request with an optional method override header
+func overrideRequest(method string, url string, override string, body string) *http.Response {
+	request, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	So(err, ShouldBeNil)
+
+	request.Header.Set("Content-Type", jsh.ContentType)
+	if override != "" {
+		request.Header.Set(MethodOverrideHeader, override)
+	}
+
+	resp, err := http.DefaultClient.Do(request)
+	So(err, ShouldBeNil)
+	resp.Body.Close()
+
+	return resp
+}
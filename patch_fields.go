@@ -0,0 +1,67 @@
+package jshapi
+
+import (
+	"encoding/json"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// patchFields records the attributes present in a PATCH request body
+type patchFields struct {
+	present map[string]bool
+	nulls   map[string]bool
+}
+
+/*
+PatchFields reports which attributes were actually sent by the client in the body
+of the current PATCH request, allowing storage to tell an attribute explicitly set
+to null apart from one that is absent and should be left untouched:
+
+	func Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+		present, nulls := jshapi.PatchFields(ctx)
+		if nulls["nickname"] {
+			// clear the nickname
+		} else if present["nickname"] {
+			// update the nickname
+		}
+		...
+	}
+
+Both maps are keyed by top level attribute name, and are empty outside of PATCH
+requests.
+*/
+func PatchFields(ctx context.Context) (present map[string]bool, nulls map[string]bool) {
+	fields, _ := ctx.Value(patchFieldsKey).(*patchFields)
+	if fields == nil {
+		return map[string]bool{}, map[string]bool{}
+	}
+
+	return fields.present, fields.nulls
+}
+
+// withPatchFields captures the raw attributes of a parsed object into the context
+// before storage unmarshals them
+func withPatchFields(ctx context.Context, object *jsh.Object) context.Context {
+	fields := &patchFields{
+		present: map[string]bool{},
+		nulls:   map[string]bool{},
+	}
+
+	if object != nil && len(object.Attributes) > 0 {
+		attributes := map[string]json.RawMessage{}
+
+		// invalid attributes are reported by storage when it unmarshals them
+		json.Unmarshal(object.Attributes, &attributes)
+
+		for name, value := range attributes {
+			fields.present[name] = true
+			if string(value) == "null" {
+				fields.nulls[name] = true
+			}
+		}
+	}
+
+	return context.WithValue(ctx, patchFieldsKey, fields)
+}
@@ -0,0 +1,46 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+func TestPatchFields(t *testing.T) {
+
+	Convey("Patch Fields Tests", t, func() {
+
+		var present, nulls map[string]bool
+
+		resource := NewResource(testResourceType)
+		resource.Patch(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+			present, nulls = PatchFields(ctx)
+			return object, nil
+		})
+
+		api := New("")
+		api.Add(resource)
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		Convey("should distinguish null, absent, and zero-value attributes", func() {
+			body := `{"data": {"type": "bars", "id": "1", "attributes": {"name": null, "count": 0, "label": ""}}}`
+			resp, _ := negotiationRequest("PATCH", server.URL+"/bars/1", jsh.ContentType, "", body)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(present, ShouldResemble, map[string]bool{"name": true, "count": true, "label": true})
+			So(nulls, ShouldResemble, map[string]bool{"name": true})
+		})
+
+		Convey("should be empty outside of PATCH requests", func() {
+			present, nulls := PatchFields(context.Background())
+			So(present, ShouldBeEmpty)
+			So(nulls, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,161 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+ResponseStage identifies a stage of the pipeline preparing the documents sent by
+the `GET /resource` and `GET /resource/:id` routes, run in order:
+
+	StageInclude  included objects are resolved, relationship linkage populated, and
+	              meta-only relationships counted
+	StageFields   sparse fieldsets are applied, per object type, to primary data
+	              and included objects
+	StageLinks    self and relationship links are added
+
+Each stage runs whether or not the request uses its feature, so that hooks see
+every fetched document.
+*/
+type ResponseStage string
+
+const (
+	// StageInclude resolves the include query parameter
+	StageInclude ResponseStage = "include"
+	// StageFields applies the fields query parameters
+	StageFields ResponseStage = "fields"
+	// StageLinks adds the links enabled on the API and its resources
+	StageLinks ResponseStage = "links"
+)
+
+/*
+ResponseHook inspects or amends the document of a GET response after a stage of the
+response pipeline. The objects of the document are copies, hooks may modify them
+without affecting storage. A returned error is sent instead of the document.
+*/
+type ResponseHook func(ctx context.Context, r *http.Request, document *jsh.Document) jsh.ErrorType
+
+/*
+AddResponseHook registers a hook run after stage of the response pipeline, for the
+documents sent by the `GET /resource` and `GET /resource/:id` routes:
+
+	posts.AddResponseHook(jshapi.StageFields, func(ctx context.Context, r *http.Request, document *jsh.Document) jsh.ErrorType {
+		document.Meta = map[string]interface{}{"included": len(document.Included)}
+		return nil
+	})
+
+Hooks of a stage run in registration order. Panics on unknown stages.
+*/
+func (res *Resource) AddResponseHook(stage ResponseStage, hook ResponseHook) {
+	res.checkRegistration("a response hook")
+
+	switch stage {
+	case StageInclude, StageFields, StageLinks:
+	default:
+		panic(fmt.Sprintf("jshapi: unknown response stage '%s'", stage))
+	}
+
+	if res.responseHooks == nil {
+		res.responseHooks = map[ResponseStage][]ResponseHook{}
+	}
+	res.responseHooks[stage] = append(res.responseHooks[stage], hook)
+}
+
+/*
+respond sends fetched primary data through the response pipeline, along with the
+objects included by the request, starting from those supplied by the storage.
+Responses without include, fieldsets, hooks or meta-only relationships are sent as
+is.
+*/
+func (res *Resource) respond(ctx context.Context, w http.ResponseWriter, r *http.Request, tree includeTree, fields fieldsets, primary jsh.Sendable, supplied jsh.List) {
+	if tree == nil && fields == nil && len(res.responseHooks) == 0 && len(res.metaOnly) == 0 {
+		res.send(ctx, w, r, primary)
+		return
+	}
+
+	document := buildDocument(validationRequest(r), primary)
+	if document.HasErrors() {
+		res.send(ctx, w, r, document)
+		return
+	}
+	for index, object := range document.Data {
+		document.Data[index] = documentCopy(object)
+	}
+
+	if tree != nil {
+		included, err := res.include(ctx, r, tree, document.Data, supplied)
+		if clientGone(ctx) {
+			return
+		}
+		if HasError(err) {
+			res.send(ctx, w, r, err)
+			return
+		}
+		document.Included = included
+	}
+
+	encoded, encodeErr := res.encodeList(ctx, document.Data, fields)
+	if !HasError(encodeErr) {
+		document.Included, encodeErr = res.encodeList(ctx, document.Included, fields)
+	}
+	if HasError(encodeErr) {
+		res.send(ctx, w, r, encodeErr)
+		return
+	}
+	document.Data = encoded
+
+	objects := append(append(jsh.List{}, document.Data...), document.Included...)
+	fields = res.policyFields(ctx, fields, objects)
+
+	err := res.countRelationships(ctx, r, objects, fields)
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		res.send(ctx, w, r, err)
+		return
+	}
+	if !res.afterStage(ctx, w, r, StageInclude, document) {
+		return
+	}
+
+	if fields != nil {
+		err := fields.apply(document.Data)
+		if !HasError(err) {
+			err = fields.apply(document.Included)
+		}
+		if HasError(err) {
+			res.send(ctx, w, r, err)
+			return
+		}
+	}
+	if !res.afterStage(ctx, w, r, StageFields, document) {
+		return
+	}
+
+	linked, _ := res.withLinks(r, document).(*jsh.Document)
+	if !res.afterStage(ctx, w, r, StageLinks, linked) {
+		return
+	}
+
+	res.deliver(ctx, w, r, linked)
+}
+
+// afterStage runs the hooks of a stage, sending the first error returned and
+// reporting whether the pipeline goes on
+func (res *Resource) afterStage(ctx context.Context, w http.ResponseWriter, r *http.Request, stage ResponseStage, document *jsh.Document) bool {
+	for _, hook := range res.responseHooks[stage] {
+		err := hook(ctx, r, document)
+		if HasError(err) {
+			res.send(ctx, w, r, err)
+			return false
+		}
+	}
+
+	return true
+}
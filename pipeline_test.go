@@ -0,0 +1,170 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// pageKey is the context key of the page requested in the pipeline tests
+type pageKey struct{}
+
+func TestResponsePipeline(t *testing.T) {
+
+	Convey("Response Pipeline Tests", t, func() {
+
+		object := func(id string, resourceType string, attributes map[string]string) *jsh.Object {
+			created, _ := jsh.NewObject(id, resourceType, attributes)
+			return created
+		}
+
+		posts := NewResource("posts")
+		posts.UseC(func(next goji.Handler) goji.Handler {
+			return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("page[number]"))
+				next.ServeHTTPC(context.WithValue(ctx, pageKey{}, page), w, r)
+			})
+		})
+		posts.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+			page, _ := ctx.Value(pageKey{}).(int)
+			first := page*2 + 1
+			return jsh.List{
+				object(strconv.Itoa(first), "posts", map[string]string{"title": "T", "body": "B"}),
+				object(strconv.Itoa(first+1), "posts", map[string]string{"title": "T", "body": "B"}),
+			}, nil
+		})
+		posts.ToOne("author", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return object("u"+id, "users", map[string]string{"name": "N", "email": "E"}), nil
+		})
+
+		users := NewResource("users")
+		users.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return object(id, "users", map[string]string{"name": "N", "email": "E"}), nil
+		})
+
+		api := New("")
+		api.EmitSelfLinks(true)
+		api.Add(users)
+
+		stages := []string{}
+		record := func(stage ResponseStage) ResponseHook {
+			return func(ctx context.Context, r *http.Request, document *jsh.Document) jsh.ErrorType {
+				state := string(stage)
+				if len(document.Included) > 0 {
+					state += " included"
+				}
+				if len(document.Data) > 0 && json.Valid(document.Data[0].Attributes) &&
+					string(document.Data[0].Attributes) == `{"title":"T"}` {
+					state += " trimmed"
+				}
+				if document.Links != nil {
+					state += " linked"
+				}
+				stages = append(stages, state)
+				return nil
+			}
+		}
+		posts.AddResponseHook(StageLinks, record(StageLinks))
+		posts.AddResponseHook(StageFields, record(StageFields))
+		posts.AddResponseHook(StageInclude, record(StageInclude))
+		api.Add(posts)
+
+		type resource struct {
+			ID         string
+			Type       string
+			Attributes map[string]string
+			Links      map[string]struct {
+				HREF string `json:"href"`
+			}
+			Relationships map[string]struct {
+				Data json.RawMessage
+			}
+		}
+		type document struct {
+			Data     []resource
+			Included []resource
+			Links    struct {
+				Self struct {
+					HREF string `json:"href"`
+				}
+			}
+		}
+
+		get := func(url string) (*httptest.ResponseRecorder, document) {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+
+			doc := document{}
+			json.Unmarshal(recorder.Body.Bytes(), &doc)
+			return recorder, doc
+		}
+
+		Convey("should combine include, fieldsets of both types and pagination", func() {
+			url := "/posts?page[number]=1&include=author&fields[posts]=title,author&fields[users]=name"
+			recorder, doc := get(url)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			So(doc.Links.Self.HREF, ShouldEqual, url)
+			So(doc.Data, ShouldHaveLength, 2)
+			So(doc.Data[0].ID, ShouldEqual, "3")
+			So(doc.Data[1].ID, ShouldEqual, "4")
+			for _, post := range doc.Data {
+				So(post.Attributes, ShouldResemble, map[string]string{"title": "T"})
+				So(string(post.Relationships["author"].Data), ShouldContainSubstring, `"u`+post.ID+`"`)
+				So(post.Links["self"].HREF, ShouldEqual, "/posts/"+post.ID)
+			}
+
+			So(doc.Included, ShouldHaveLength, 2)
+			for _, user := range doc.Included {
+				So(user.Type, ShouldEqual, "users")
+				So(user.Attributes, ShouldResemble, map[string]string{"name": "N"})
+				So(user.Links["self"].HREF, ShouldEqual, "/users/"+user.ID)
+			}
+		})
+
+		Convey("should run the stages in order", func() {
+			get("/posts?include=author&fields[posts]=title")
+			So(stages, ShouldResemble, []string{
+				"include included",
+				"fields included trimmed",
+				"links included trimmed linked",
+			})
+		})
+
+		Convey("should drop linkage left out of the fieldset", func() {
+			_, doc := get("/posts?include=author&fields[posts]=title")
+			So(doc.Included, ShouldHaveLength, 2)
+			So(doc.Data[0].Relationships, ShouldBeEmpty)
+		})
+
+		Convey("should send hook errors instead of the document", func() {
+			posts := NewResource("posts")
+			posts.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+				return jsh.List{}, nil
+			})
+			posts.AddResponseHook(StageFields, func(ctx context.Context, r *http.Request, document *jsh.Document) jsh.ErrorType {
+				return jsh.ISE("hook failed")
+			})
+
+			api := New("")
+			api.Add(posts)
+
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", "/posts", nil))
+			So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+
+		Convey("should reject unknown stages", func() {
+			So(func() { NewResource("tags").AddResponseHook("render", record("render")) }, ShouldPanicWith,
+				"jshapi: unknown response stage 'render'")
+		})
+	})
+}
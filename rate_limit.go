@@ -0,0 +1,219 @@
+package jshapi
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+RateLimiter decides whether a request may proceed. When it may not, retryAfter is
+the time after which the client can try again.
+*/
+type RateLimiter interface {
+	Allow(ctx context.Context, r *http.Request) (ok bool, retryAfter time.Duration, err error)
+}
+
+// RateLimitStatus is the state of the limit applied to a request
+type RateLimitStatus struct {
+	Allowed    bool
+	RetryAfter time.Duration
+	// Limit is the maximum number of requests the client can burst
+	Limit int
+	// Remaining is the number of requests the client can still make right away
+	Remaining int
+	// Reset is the time at which the client will be back to Limit requests
+	Reset time.Time
+}
+
+/*
+StatusRateLimiter can optionally be implemented by a RateLimiter to report the
+state of the limit, which is then included in the meta of 429 errors.
+*/
+type StatusRateLimiter interface {
+	RateLimiter
+	AllowStatus(ctx context.Context, r *http.Request) (RateLimitStatus, error)
+}
+
+/*
+RateLimit returns a middleware rejecting the requests its limiter does not allow
+with a 429 JSON API error and a Retry-After header. Limiter failures are sent as
+500 errors.
+
+	limiter := jshapi.NewTokenBucketLimiter(10, 20, jshapi.KeyByIP)
+	api.UseC(jshapi.RateLimit(limiter))
+*/
+func RateLimit(limiter RateLimiter) func(goji.Handler) goji.Handler {
+	return func(next goji.Handler) goji.Handler {
+		return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			status, err := checkRateLimit(ctx, r, limiter)
+			if err != nil {
+				SendHandler(ctx, w, r, jsh.ISE(fmt.Sprintf("Rate limiter failure: %s", err.Error())))
+				return
+			}
+
+			if status.Allowed {
+				next.ServeHTTPC(ctx, w, r)
+				return
+			}
+
+			retryAfter := int(math.Ceil(status.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+			meta := map[string]interface{}{}
+			if status.Limit > 0 {
+				meta["limit"] = status.Limit
+				meta["remaining"] = status.Remaining
+				meta["reset"] = status.Reset.Unix()
+			}
+
+			sendErrorWithMeta(ctx, w, r, &jsh.Error{
+				Title:  "Too Many Requests",
+				Detail: fmt.Sprintf("Rate limit exceeded, retry in %d seconds", retryAfter),
+				Status: http.StatusTooManyRequests,
+			}, meta)
+		})
+	}
+}
+
+// checkRateLimit queries the limiter, using its status when available
+func checkRateLimit(ctx context.Context, r *http.Request, limiter RateLimiter) (RateLimitStatus, error) {
+	statusLimiter, hasStatus := limiter.(StatusRateLimiter)
+	if hasStatus {
+		return statusLimiter.AllowStatus(ctx, r)
+	}
+
+	allowed, retryAfter, err := limiter.Allow(ctx, r)
+	return RateLimitStatus{Allowed: allowed, RetryAfter: retryAfter}, err
+}
+
+// KeyFunc extracts the key requests are rate limited by
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP rate limits requests by client IP address
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// KeyByHeader rate limits requests by the value of a header, such as an API key
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+/*
+TokenBucketLimiter is an in-memory StatusRateLimiter granting each key a bucket of
+burst tokens, refilled at rate tokens per second. Buckets left idle long enough to
+be full again are evicted, keeping memory bounded by the number of recently active
+keys.
+*/
+type TokenBucketLimiter struct {
+	rate  float64
+	burst int
+	key   KeyFunc
+	// now is replaceable for tests
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucketLimiter creates a TokenBucketLimiter
+func NewTokenBucketLimiter(rate float64, burst int, key KeyFunc) *TokenBucketLimiter {
+	if rate <= 0 || burst <= 0 {
+		panic("jshapi: token bucket rate and burst must be positive")
+	}
+
+	return &TokenBucketLimiter{
+		rate:    rate,
+		burst:   burst,
+		key:     key,
+		now:     time.Now,
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// Allow implements RateLimiter
+func (l *TokenBucketLimiter) Allow(ctx context.Context, r *http.Request) (bool, time.Duration, error) {
+	status, err := l.AllowStatus(ctx, r)
+	return status.Allowed, status.RetryAfter, err
+}
+
+// AllowStatus implements StatusRateLimiter
+func (l *TokenBucketLimiter) AllowStatus(ctx context.Context, r *http.Request) (RateLimitStatus, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	key := l.key(r)
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(float64(l.burst), bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	status := RateLimitStatus{Limit: l.burst}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		status.Allowed = true
+	} else {
+		status.RetryAfter = l.refillTime(1 - bucket.tokens)
+	}
+
+	status.Remaining = int(bucket.tokens)
+	status.Reset = now.Add(l.refillTime(float64(l.burst) - bucket.tokens))
+
+	return status, nil
+}
+
+// refillTime is the time needed to refill a number of tokens
+func (l *TokenBucketLimiter) refillTime(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep evicts the buckets that have been idle long enough to be full, at most
+// once per refill period
+func (l *TokenBucketLimiter) sweep(now time.Time) {
+	idle := l.refillTime(float64(l.burst))
+	if now.Sub(l.lastSweep) < idle {
+		return
+	}
+	l.lastSweep = now
+
+	active := make(map[string]*tokenBucket, len(l.buckets))
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) < idle {
+			active[key] = bucket
+		}
+	}
+	l.buckets = active
+}
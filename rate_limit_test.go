@@ -0,0 +1,89 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRateLimit(t *testing.T) {
+
+	Convey("Rate Limit Tests", t, func() {
+
+		now := time.Unix(1000, 0)
+		limiter := NewTokenBucketLimiter(1, 2, KeyByHeader("X-API-Key"))
+		limiter.now = func() time.Time { return now }
+
+		request := func(key string) *http.Request {
+			r, err := http.NewRequest("GET", "/bars", nil)
+			So(err, ShouldBeNil)
+			r.Header.Set("X-API-Key", key)
+			return r
+		}
+
+		Convey("->TokenBucketLimiter", func() {
+
+			Convey("should allow bursts up to the limit per key", func() {
+				for i := 0; i < 2; i++ {
+					allowed, _, err := limiter.Allow(nil, request("a"))
+					So(err, ShouldBeNil)
+					So(allowed, ShouldBeTrue)
+				}
+
+				allowed, retryAfter, _ := limiter.Allow(nil, request("a"))
+				So(allowed, ShouldBeFalse)
+				So(retryAfter, ShouldEqual, time.Second)
+
+				allowed, _, _ = limiter.Allow(nil, request("b"))
+				So(allowed, ShouldBeTrue)
+			})
+
+			Convey("should refill tokens over time", func() {
+				limiter.Allow(nil, request("a"))
+				limiter.Allow(nil, request("a"))
+
+				now = now.Add(time.Second)
+				status, _ := limiter.AllowStatus(nil, request("a"))
+				So(status.Allowed, ShouldBeTrue)
+				So(status.Remaining, ShouldEqual, 0)
+				So(status.Reset, ShouldResemble, now.Add(2*time.Second))
+			})
+
+			Convey("should evict idle buckets", func() {
+				limiter.Allow(nil, request("a"))
+				limiter.Allow(nil, request("b"))
+				So(limiter.buckets, ShouldHaveLength, 2)
+
+				now = now.Add(time.Minute)
+				limiter.Allow(nil, request("c"))
+				So(limiter.buckets, ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("->RateLimit()", func() {
+			api := New("")
+			api.UseC(RateLimit(limiter))
+			api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			url := server.URL + "/" + testResourceType + "/1"
+
+			Convey("should reject limited requests with a 429", func() {
+				negotiationRequest("GET", url, "", "", "")
+				negotiationRequest("GET", url, "", "", "")
+
+				resp, content := negotiationRequest("GET", url, "", "", "")
+				So(resp.StatusCode, ShouldEqual, http.StatusTooManyRequests)
+				So(resp.Header.Get("Retry-After"), ShouldEqual, "1")
+				So(content, ShouldContainSubstring, `"limit": 2`)
+				So(content, ShouldContainSubstring, `"remaining": 0`)
+				So(content, ShouldContainSubstring, `"reset": 1002`)
+			})
+		})
+	})
+}
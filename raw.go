@@ -0,0 +1,63 @@
+package jshapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+RawDocument is a response already serialized as a JSON API document, such as one
+cached by storage, which DefaultSender writes as is rather than building and
+serializing a document:
+
+	posts.HandleFuncC(pat.Get("/:id"), posts.Wrap(jshapi.OpRead, "/:id",
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			body, err := cache.Document(ctx, jshapi.ResourceID(ctx, posts))
+			...
+			jshapi.SendHandler(ctx, w, r, &jshapi.RawDocument{Body: body})
+		},
+	))
+
+The body is sent along with its Content-Type and Content-Length headers only:
+the features that work on documents, such as sparse fieldsets, field policies,
+link generation, linkage shaping and request ids on errors, are skipped, and the
+body is not checked to be a valid document. Middleware working on the response
+bytes, such as ETags and compression, are unaffected. Senders other than
+DefaultSender must handle RawDocument themselves.
+*/
+type RawDocument struct {
+	// ContentType defaults to jsh.ContentType
+	ContentType string
+	Body        []byte
+	// Status defaults to 200
+	Status int
+}
+
+// Validate implements jsh.Sendable, rejecting empty bodies
+func (d *RawDocument) Validate(r *http.Request, response bool) *jsh.Error {
+	if len(d.Body) == 0 {
+		return jsh.ISE("Raw document has no body")
+	}
+
+	return nil
+}
+
+// sendRaw writes a raw document as is
+func sendRaw(w http.ResponseWriter, document *RawDocument) {
+	contentType := document.ContentType
+	if contentType == "" {
+		contentType = jsh.ContentType
+	}
+
+	status := document.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(document.Body)))
+	w.WriteHeader(status)
+	w.Write(document.Body)
+}
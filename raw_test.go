@@ -0,0 +1,114 @@
+package jshapi
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"goji.io"
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// etagWriter buffers a response to set its ETag from the bytes written
+type etagWriter struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+func (w *etagWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *etagWriter) Write(content []byte) (int, error) {
+	w.body = append(w.body, content...)
+	return len(content), nil
+}
+
+// etagMiddleware sets the ETag of responses from their body
+func etagMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		writer := &etagWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTPC(ctx, writer, r)
+
+		sum := sha1.Sum(writer.body)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.WriteHeader(writer.status)
+		w.Write(writer.body)
+	})
+}
+
+func TestRawDocument(t *testing.T) {
+
+	Convey("Raw Document Tests", t, func() {
+
+		body := []byte(`{"data":{"type":"bars","id":"1","attributes":{"foo":"bar"}},"jsonapi":{"version":"1.1"}}`)
+		raw := &RawDocument{Body: body}
+
+		resource := NewResource(testResourceType)
+		resource.HandleFuncC(pat.Get("/:id"), resource.Wrap(OpRead, "/:id",
+			func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				resource.send(ctx, w, r, raw)
+			},
+		))
+
+		api := New("")
+		api.Add(resource)
+
+		get := func(method string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest(method, "/bars/1", nil))
+			return recorder
+		}
+
+		Convey("should send the body as is", func() {
+			recorder := get("GET")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldEqual, string(body))
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, jsh.ContentType)
+			So(recorder.Header().Get("Content-Length"), ShouldEqual, strconv.Itoa(len(body)))
+		})
+
+		Convey("should send the content type and status given", func() {
+			raw.ContentType = jsh.ContentType + `; ext="https://jsonapi.org/ext/atomic"`
+			raw.Status = http.StatusAccepted
+
+			recorder := get("GET")
+			So(recorder.Code, ShouldEqual, http.StatusAccepted)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, raw.ContentType)
+		})
+
+		Convey("should send the headers only to HEAD requests", func() {
+			recorder := get("HEAD")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.Len(), ShouldEqual, 0)
+			So(recorder.Header().Get("Content-Length"), ShouldEqual, strconv.Itoa(len(body)))
+		})
+
+		Convey("should work with middleware working on the response bytes", func() {
+			api.UseC(etagMiddleware)
+
+			sum := sha1.Sum(body)
+			recorder := get("GET")
+			So(recorder.Header().Get("ETag"), ShouldEqual, `"`+hex.EncodeToString(sum[:])+`"`)
+			So(recorder.Body.String(), ShouldEqual, string(body))
+		})
+
+		Convey("should reject empty bodies", func() {
+			raw.Body = nil
+
+			recorder := get("GET")
+			So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+			So(recorder.Body.String(), ShouldContainSubstring, `"errors"`)
+		})
+	})
+}
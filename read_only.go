@@ -0,0 +1,113 @@
+package jshapi
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// defaultReadOnlyNotice is sent until SetReadOnlyNotice is called
+var defaultReadOnlyNotice = readOnlyNotice{
+	message:    "The API is read-only for maintenance, please retry later",
+	retryAfter: time.Minute,
+}
+
+// readOnlyNotice configures the responses to writes in read-only mode
+type readOnlyNotice struct {
+	message    string
+	retryAfter time.Duration
+}
+
+/*
+SetReadOnly flips the API into, or out of, maintenance read-only mode: reads keep
+working but creates, updates, deletes, actions and atomic operations answer 503
+Service Unavailable with a Retry-After header, see SetReadOnlyNotice. It is safe
+to call while serving requests, reads don't pay for the check.
+*/
+func (a *API) SetReadOnly(readOnly bool) {
+	setFlag(&a.readOnly, readOnly)
+}
+
+// ReadOnlyMode reports whether the API is in maintenance read-only mode
+func (a *API) ReadOnlyMode() bool {
+	return atomic.LoadInt32(&a.readOnly) == 1
+}
+
+// SetReadOnlyNotice sets the message and Retry-After delay of the 503 responses
+// sent in read-only mode, by the API or any of its resources
+func (a *API) SetReadOnlyNotice(message string, retryAfter time.Duration) {
+	a.readOnlyNotice.Store(readOnlyNotice{message: message, retryAfter: retryAfter})
+}
+
+// SetReadOnly flips the resource alone into, or out of, maintenance read-only mode,
+// see API.SetReadOnly
+func (res *Resource) SetReadOnly(readOnly bool) {
+	setFlag(&res.readOnly, readOnly)
+}
+
+// ReadOnlyMode reports whether the resource is in maintenance read-only mode, by
+// itself or through its API
+func (res *Resource) ReadOnlyMode() bool {
+	return atomic.LoadInt32(&res.readOnly) == 1 || (res.api != nil && res.api.ReadOnlyMode())
+}
+
+// setFlag sets an atomic boolean flag
+func setFlag(flag *int32, value bool) {
+	if value {
+		atomic.StoreInt32(flag, 1)
+		return
+	}
+
+	atomic.StoreInt32(flag, 0)
+}
+
+// writeOperation reports whether an operation may change stored data
+func writeOperation(op Operation) bool {
+	return op == OpCreate || op == OpUpdate || op == OpDelete || op == OpAction
+}
+
+// notice returns the read-only notice of the API
+func (a *API) notice() readOnlyNotice {
+	notice, isSet := a.readOnlyNotice.Load().(readOnlyNotice)
+	if !isSet {
+		return defaultReadOnlyNotice
+	}
+
+	return notice
+}
+
+// notice returns the read-only notice of the API of the resource
+func (res *Resource) notice() readOnlyNotice {
+	if res.api == nil {
+		return defaultReadOnlyNotice
+	}
+
+	return res.api.notice()
+}
+
+// readOnlyError is the error of writes in read-only mode, for atomic operations
+func readOnlyError(notice readOnlyNotice) *jsh.Error {
+	return &jsh.Error{
+		Title:  "Service Unavailable",
+		Detail: notice.message,
+		Status: http.StatusServiceUnavailable,
+	}
+}
+
+// sendReadOnly answers a write in read-only mode
+func sendReadOnly(ctx context.Context, w http.ResponseWriter, r *http.Request, notice readOnlyNotice) {
+	retryAfter := int(math.Ceil(notice.retryAfter.Seconds()))
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+
+	sendErrorWithMeta(ctx, w, r, readOnlyError(notice), map[string]interface{}{
+		"message": notice.message,
+	})
+}
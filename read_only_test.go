@@ -0,0 +1,85 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadOnly(t *testing.T) {
+
+	Convey("Read Only Tests", t, func() {
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+		resource.Action("testAction", (&MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs}).Get)
+		other := NewMockResource("foos", 1, testObjAttrs)
+
+		api := New("")
+		api.Add(resource)
+		api.Add(other)
+		api.AtomicOperations("/operations")
+
+		send := func(method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		create := `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`
+
+		Convey("->API.SetReadOnly()", func() {
+			api.SetReadOnly(true)
+			So(api.ReadOnlyMode(), ShouldBeTrue)
+			So(resource.ReadOnlyMode(), ShouldBeTrue)
+
+			Convey("should keep serving reads", func() {
+				So(send("GET", "/bars", "").Code, ShouldEqual, http.StatusOK)
+				So(send("GET", "/bars/1", "").Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("should answer writes with a 503", func() {
+				recorder := send("POST", "/bars", create)
+				So(recorder.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(recorder.Header().Get("Retry-After"), ShouldEqual, "60")
+				So(recorder.Body.String(), ShouldContainSubstring, `"message": "The API is read-only for maintenance, please retry later"`)
+
+				So(send("DELETE", "/bars/1", "").Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(send("GET", "/bars/1/testAction", "").Code, ShouldEqual, http.StatusServiceUnavailable)
+				atomic := httptest.NewRecorder()
+				request := httptest.NewRequest("POST", "/operations", strings.NewReader(`{"atomic:operations": []}`))
+				request.Header.Set("Content-Type", AtomicContentType)
+				api.ServeHTTP(atomic, request)
+				So(atomic.Code, ShouldEqual, http.StatusServiceUnavailable)
+			})
+
+			Convey("should send the configured notice", func() {
+				api.SetReadOnlyNotice("Migrating to v2", 90*time.Second)
+
+				recorder := send("PATCH", "/bars/1", `{"data": {"type": "bars", "id": "1", "attributes": {"foo": "baz"}}}`)
+				So(recorder.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(recorder.Header().Get("Retry-After"), ShouldEqual, "90")
+				So(recorder.Body.String(), ShouldContainSubstring, `"detail": "Migrating to v2"`)
+			})
+
+			Convey("should serve writes again once disabled", func() {
+				api.SetReadOnly(false)
+				So(send("POST", "/bars", create).Code, ShouldEqual, http.StatusCreated)
+			})
+		})
+
+		Convey("->Resource.SetReadOnly()", func() {
+			resource.SetReadOnly(true)
+
+			So(send("POST", "/bars", create).Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(send("POST", "/foos", `{"data": {"type": "foos", "attributes": {"foo": "bar"}}}`).Code, ShouldEqual, http.StatusCreated)
+			So(api.ReadOnlyMode(), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRelationshipLinks(t *testing.T) {
+
+	Convey("Relationship Links Tests", t, func() {
+
+		post, _ := jsh.NewObject("1", "posts", testObjAttrs)
+		post.Relationships["comments"] = &jsh.Relationship{}
+		post.Relationships["tags"] = &jsh.Relationship{}
+
+		resource := NewResource("posts")
+		resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return post, nil
+		})
+		resource.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+			return jsh.List{post}, nil
+		})
+		resource.ToMany("comments", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return jsh.List{}, nil
+		})
+		resource.ToOne("author", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return jsh.NewObject("1", "authors", testObjAttrs)
+		})
+
+		api := New("api")
+		api.Add(resource)
+
+		type relationship struct {
+			Links map[string]struct {
+				HREF string `json:"href"`
+			} `json:"links"`
+		}
+		type object struct {
+			Relationships map[string]relationship `json:"relationships"`
+		}
+
+		get := func(url string) object {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+
+			doc := struct{ Data object }{}
+			json.Unmarshal(recorder.Body.Bytes(), &doc)
+			return doc.Data
+		}
+
+		Convey("should not emit links by default", func() {
+			sent := get("/api/posts/1")
+			So(sent.Relationships["comments"].Links, ShouldBeEmpty)
+		})
+
+		Convey("->EmitRelationshipLinks()", func() {
+			resource.EmitRelationshipLinks(true)
+
+			Convey("should link populated relationships", func() {
+				sent := get("/api/posts/1")
+				So(sent.Relationships["comments"].Links["self"].HREF, ShouldEqual, "/api/posts/1/relationships/comments")
+				So(sent.Relationships["comments"].Links["related"].HREF, ShouldEqual, "/api/posts/1/comments")
+			})
+
+			Convey("should not fabricate relationships", func() {
+				sent := get("/api/posts/1")
+				So(sent.Relationships, ShouldNotContainKey, "author")
+				So(sent.Relationships["tags"].Links, ShouldBeEmpty)
+			})
+
+			Convey("should link listed objects", func() {
+				recorder := httptest.NewRecorder()
+				api.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/posts", nil))
+
+				doc := struct{ Data []object }{}
+				So(json.Unmarshal(recorder.Body.Bytes(), &doc), ShouldBeNil)
+				So(doc.Data, ShouldHaveLength, 1)
+				So(doc.Data[0].Relationships["comments"].Links["related"].HREF, ShouldEqual, "/api/posts/1/comments")
+			})
+
+			Convey("should keep existing links", func() {
+				post.Relationships["comments"] = &jsh.Relationship{Links: &jsh.Links{
+					Related: &jsh.Link{HREF: "/custom/comments"},
+				}}
+
+				sent := get("/api/posts/1")
+				So(sent.Relationships["comments"].Links["self"].HREF, ShouldEqual, "/api/posts/1/relationships/comments")
+				So(sent.Relationships["comments"].Links["related"].HREF, ShouldEqual, "/custom/comments")
+			})
+
+			Convey("should only link registered routes", func() {
+				So(resource.Disable("GET", "/:id/comments"), ShouldBeNil)
+
+				sent := get("/api/posts/1")
+				So(sent.Relationships["comments"].Links["self"].HREF, ShouldEqual, "/api/posts/1/relationships/comments")
+				So(sent.Relationships["comments"].Links, ShouldNotContainKey, "related")
+			})
+
+			Convey("should leave stored objects untouched", func() {
+				get("/api/posts/1")
+				So(post.Relationships["comments"].Links, ShouldBeNil)
+			})
+		})
+	})
+}
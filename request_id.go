@@ -0,0 +1,171 @@
+package jshapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+const (
+	// RequestIDHeader is read from requests and echoed on responses
+	RequestIDHeader = "X-Request-ID"
+	// maxRequestIDLength caps the length of client provided request ids
+	maxRequestIDLength = 128
+)
+
+/*
+RequestID returns a middleware that assigns an id to each request, either the one
+provided by the client in the X-Request-ID header or a newly generated UUID. The id
+is echoed in the response header, set as the "id" member of every error object sent
+by the DefaultSender so that clients can quote it, and included in its logs:
+
+	api := jshapi.New("")
+	api.UseC(jshapi.RequestID())
+
+Errors sent by middleware registered before it, such as the content negotiation
+installed by New(), do not carry the id.
+*/
+func RequestID() func(goji.Handler) goji.Handler {
+	return func(next goji.Handler) goji.Handler {
+		return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTPC(context.WithValue(ctx, RequestIDKey, id), w, r)
+		})
+	}
+}
+
+// GetRequestID returns the id assigned to the current request by the RequestID
+// middleware, or an empty string if it is not in use
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDKey).(string)
+	return id
+}
+
+// validRequestID only accepts client ids made of printable ASCII characters, to
+// keep them safe to echo in headers and logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRequestID generates a random (version 4) UUID
+func newRequestID() string {
+	var uuid [16]byte
+	_, err := rand.Read(uuid[:])
+	if err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(fmt.Sprintf("jshapi: unable to generate request id: %s", err.Error()))
+	}
+
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], uuid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], uuid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], uuid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], uuid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], uuid[10:])
+
+	return string(buf[:])
+}
+
+/*
+sendWithErrorMembers sends a document like jsh.SendDocument, setting additional
+members, such as "id" or "meta", on each of its error objects. jsh.Error has no such
+members, so the marshaled errors are amended before being written.
+*/
+func sendWithErrorMembers(w http.ResponseWriter, r *http.Request, document *jsh.Document, api *API, members map[string]interface{}) *jsh.Error {
+	validationErr := document.Validate(r, true)
+	if validationErr != nil {
+		prepErr := validationErr.Validate(r, true)
+		if prepErr != nil {
+			http.Error(w, jsh.DefaultErrorTitle, http.StatusInternalServerError)
+			return prepErr
+		}
+
+		document = jsh.Build(validationErr)
+	}
+
+	if !document.HasErrors() {
+		return sendDocument(w, r, document, api)
+	}
+
+	content, err := withErrorMembers(document, members)
+	if err == nil {
+		err = writeJSON(w, jsh.ContentType, document.Status, content)
+	}
+	if err != nil {
+		http.Error(w, jsh.DefaultErrorTitle, http.StatusInternalServerError)
+		return jsh.ISE(fmt.Sprintf("Unable to marshal JSON payload: %s", err.Error()))
+	}
+
+	return validationErr
+}
+
+// withErrorMembers marshals an error document to compact JSON with members added
+// to each error object
+func withErrorMembers(document *jsh.Document, members map[string]interface{}) ([]byte, error) {
+	content, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	top := map[string]json.RawMessage{}
+	err = json.Unmarshal(content, &top)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := []map[string]json.RawMessage{}
+	err = json.Unmarshal(top["errors"], &errs)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := map[string]json.RawMessage{}
+	for name, value := range members {
+		encoded[name], err = json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, errObject := range errs {
+		for name, value := range encoded {
+			errObject[name] = value
+		}
+	}
+
+	top["errors"], err = json.Marshal(errs)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(top)
+}
@@ -0,0 +1,74 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRequestID(t *testing.T) {
+
+	Convey("Request ID Tests", t, func() {
+
+		api := New("")
+		api.UseC(RequestID())
+		api.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/" + testResourceType
+
+		Convey("->newRequestID()", func() {
+
+			Convey("should generate version 4 UUIDs", func() {
+				uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+				So(newRequestID(), ShouldNotEqual, newRequestID())
+				So(uuid.MatchString(newRequestID()), ShouldBeTrue)
+			})
+		})
+
+		Convey("should echo the client request id", func() {
+			request, err := http.NewRequest("GET", url+"/1", nil)
+			So(err, ShouldBeNil)
+			request.Header.Set(RequestIDHeader, "abc-123")
+
+			resp, err := http.DefaultClient.Do(request)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+
+			So(resp.Header.Get(RequestIDHeader), ShouldEqual, "abc-123")
+		})
+
+		Convey("should generate an id when none or an invalid one is provided", func() {
+			resp, _ := negotiationRequest("GET", url+"/1", "", "", "")
+			So(len(resp.Header.Get(RequestIDHeader)), ShouldEqual, 36)
+
+			request, err := http.NewRequest("GET", url+"/1", nil)
+			So(err, ShouldBeNil)
+			request.Header.Set(RequestIDHeader, "has spaces")
+
+			resp, err = http.DefaultClient.Do(request)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+
+			So(resp.Header.Get(RequestIDHeader), ShouldNotEqual, "has spaces")
+		})
+
+		Convey("should set the id on every error object", func() {
+			resp, content := negotiationRequest("POST", url, jsh.ContentType, "", `{"data": {"type": "foos", "attributes": {"foo": "bar"}}}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+			So(content, ShouldContainSubstring, `"id": "`+resp.Header.Get(RequestIDHeader)+`"`)
+		})
+
+		Convey("should leave successful documents untouched", func() {
+			resp, content := negotiationRequest("GET", url+"/1", "", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(content, ShouldNotContainSubstring, resp.Header.Get(RequestIDHeader))
+		})
+	})
+}
@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"net/http"
 	"path"
-	"reflect"
+	"regexp"
 	"strings"
+	"time"
 
 	"goji.io"
 	"goji.io/pat"
@@ -13,6 +14,7 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/go-stdlogger"
 	"github.com/derekdowling/jsh-api/store"
 )
 
@@ -22,7 +24,6 @@ const (
 	list    = "LIST"
 	delete  = "DELETE"
 	patch   = "PATCH"
-	patID   = "/:id"
 	patRoot = ""
 )
 
@@ -46,6 +47,13 @@ your own routes using the goji.Mux API:
 	resource := jshapi.NewCRUDResource("users", userStorage)
 	// creates /users/search/:name
 	resource.HandleC(pat.New("search/:name"), searchHandler)
+
+Wrapping custom handlers with Resource.Wrap makes them behave like the built in
+routes, see RouteInfoFromContext.
+
+Unlike with a plain goji.Mux, routes are matched most specific first rather than
+in registration order: /users/export is served by its own route even when
+registered after GET /users/:id.
 */
 type Resource struct {
 	*goji.Mux
@@ -55,6 +63,144 @@ type Resource struct {
 	Routes []string
 	// Map of relationships
 	Relationships map[string]Relationship
+	// api is the API the resource has been added to, if any
+	api *API
+	// maxBodyBytes overrides the API request body size limit when set
+	maxBodyBytes int64
+	// maxBatchSize caps the number of objects accepted by bulk requests
+	maxBatchSize int
+	// bulkMode sets how bulk updates handle failing objects
+	bulkMode BulkMode
+	// stream dispatches the events of the resource to its event stream clients
+	stream *eventStream
+	// importBatchSize and maxImportRows configure imports, see ImportLimits
+	importBatchSize int
+	maxImportRows   int
+	// storage tracks the registered storage handlers so that they can be used
+	// outside of the resource's own routes
+	storage registeredStorage
+	// tx is set when the CRUD storage supports transactions
+	tx store.Transactional
+	// strictMembers enables member name validation of incoming objects, nested
+	// up to memberDepth levels within attributes
+	strictMembers bool
+	memberDepth   int
+	// validators run against every incoming object before storage
+	validators []Validator
+	// schema validates incoming attributes when set
+	schema *Schema
+	// authorizer overrides the API authorizer when set
+	authorizer Authorizer
+	// noContent holds the write operations responding 204 No Content
+	noContent map[Operation]bool
+	// routeOperations maps the registered route patterns to their metadata
+	routeOperations map[goji.Pattern]*routeMeta
+	// frozen is set by Freeze, registration panics once it is
+	frozen int32
+	// registry holds the routes until they are registered with Mux, most
+	// specific first
+	registry routeRegistry
+	// prefix is the path between the API prefix and the resource type
+	prefix string
+	// parent routes the resource when nested by NestCRUD, under parentParam
+	parent      *Resource
+	parentParam string
+	// parentKey is the context key of parentParam
+	parentKey interface{}
+	// sender overrides SendHandler when set
+	sender Sender
+	// pluralize names ToMany relationships when set
+	pluralize func(string) string
+	// clientIDs is the policy for client generated IDs on creation
+	clientIDs ClientIDPolicy
+	// idPattern validates the ids of the resource routes when set, mismatches
+	// are answered with invalidIDStatus
+	idPattern       *regexp.Regexp
+	invalidIDStatus int
+	// idParam names the id variable of the resource routes, "id" by default
+	idParam string
+	// compositeID names the id variables of resources keyed by several values
+	compositeID []string
+	// aliases are alternate type segments serving the resource routes
+	aliases []resourceAlias
+	// registrations replay the registration calls made on the resource onto its
+	// clones, recording is the depth of the registration call being recorded
+	registrations []func(*Resource)
+	recording     int
+	// readOnly is set in maintenance read-only mode
+	readOnly int32
+	// routeNames maps the names given with Named to their relative routes
+	routeNames map[string]string
+	// includeStorage lists the related objects of each relationship for compound
+	// documents, within the include limits
+	includeStorage  map[string]store.ToMany
+	maxIncludeDepth int
+	maxIncluded     int
+	// allowedIncludes restricts the include paths when set, defaultIncludes are
+	// included when the request has no include parameter
+	allowedIncludes []string
+	defaultIncludes []string
+	// deniedIncludes sets how include paths denied by the authorizer are answered
+	deniedIncludes DeniedIncludePolicy
+	// includeConcurrency bounds the concurrent storage calls resolving include
+	// paths, failedIncludes sets how their failures are answered
+	includeConcurrency int
+	failedIncludes     FailedIncludePolicy
+	// keyCasing overrides the attribute name casing of the API when set, codecs
+	// convert the values of attributes by sent name
+	keyCasing *keyCasing
+	codecs    map[string]Codec
+	// timestamps maintains creation and update time attributes when set
+	timestamps *timestamps
+	// defaults are added to created objects omitting them, computed attributes
+	// are added to the objects sent
+	defaults []defaultAttribute
+	computed map[string]ComputedFunc
+	// knownAttributes are the attributes clients may write when set, others are
+	// logged to unknownLogger when set and rejected otherwise
+	knownAttributes map[string]bool
+	unknownLogger   std.Logger
+	// collection is the path segment the resource is routed under in place of
+	// its type when set, see WithCollectionPath
+	collection string
+	// describer describes the attributes and relationships of the resource schema
+	describer SchemaDescriber
+	// relationshipLinks adds links to the relationships of sent objects
+	relationshipLinks bool
+	// dryRunner checks the writes of dry runs in place of storage when set
+	dryRunner store.DryRunner
+	// fieldPolicy redacts the fields of the objects sent by the resource
+	fieldPolicy FieldPolicyFunc
+	// objectFilter hides the objects of the resource the caller can't see
+	objectFilter ObjectFilter
+	// storageTimeout bounds each storage call, storageTimeouts per operation
+	storageTimeout  time.Duration
+	storageTimeouts map[Operation]time.Duration
+	// metaOnly holds the relationships registered with ToManyMetaOnly
+	metaOnly map[string]metaOnlyRelationship
+	// responseHooks run after the stages of the response pipeline of GET routes
+	responseHooks map[ResponseStage][]ResponseHook
+	// longPoll lets GET /resources/:id wait for changes when set, see LongPoll
+	longPoll *longPoll
+	// notFound customizes the 404 errors of missing objects when set, see
+	// NotFoundError, relatedTypes maps relationships to the type of their objects
+	notFound     NotFoundFunc
+	relatedTypes map[string]string
+	// sealed holds the codecs of the sealed attributes by sent name, failedOpens
+	// sets how those failing to open are sent, see SealedAttributes
+	sealed      map[string]SealCodec
+	failedOpens FailedOpenPolicy
+}
+
+// registeredStorage holds the storage handlers registered with a resource
+type registeredStorage struct {
+	save    store.Save
+	get     store.Get
+	getMany store.GetMany
+	list    store.ListIncluded
+	stream  store.ListStream
+	update  store.Update
+	delete  store.Delete
 }
 
 /*
@@ -62,27 +208,99 @@ NewResource is a resource constructor that makes no assumptions about routes
 that you'd like to implement, but still provides some basic utilities for
 managing routes and handling API calls.
 
-The prefix parameter causes all routes created within the resource to be prefixed.
+Options configure the resource before any route is registered, e.g. WithPrefix
+causes all routes created within the resource to be prefixed.
 */
-func NewResource(resourceType string) *Resource {
-	return &Resource{
+func NewResource(resourceType string, opts ...ResourceOption) *Resource {
+	resource := &Resource{
 		// Mux is a goji.SubMux, inherits context from parent Mux
 		Mux: goji.SubMux(),
 		// Type of the resource, makes no assumptions about plurality
-		Type:          resourceType,
-		Relationships: map[string]Relationship{},
+		Type:           resourceType,
+		Relationships:  map[string]Relationship{},
+		includeStorage: map[string]store.ToMany{},
+		relatedTypes:   map[string]string{},
 		// A list of registered routes, useful for debugging
-		Routes: []string{},
+		Routes:          []string{},
+		maxBodyBytes:    inheritBodyLimit,
+		routeOperations: map[goji.Pattern]*routeMeta{},
+		idParam:         defaultIDParam,
 	}
+
+	// expose the matched route to any middleware added to the resource
+	resource.Mux.UseC(resource.routeInfoMiddleware)
+
+	resource.Apply(opts...)
+
+	return resource
 }
 
-// NewCRUDResource generates a resource
-func NewCRUDResource(resourceType string, storage store.CRUD) *Resource {
-	resource := NewResource(resourceType)
+// NewCRUDResource generates a resource, see NewResource for the options
+func NewCRUDResource(resourceType string, storage store.CRUD, opts ...ResourceOption) *Resource {
+	resource := NewResource(resourceType, opts...)
 	resource.CRUD(storage)
 	return resource
 }
 
+/*
+NewReadOnlyResource generates a resource serving `GET /resource` and
+`GET /resource/:id` only, see ReadOnly and NewResource for the options.
+*/
+func NewReadOnlyResource(resourceType string, get store.Get, list store.List, opts ...ResourceOption) *Resource {
+	resource := NewResource(resourceType, opts...)
+	resource.ReadOnly(get, list)
+	return resource
+}
+
+/*
+ReadOnly registers the read handlers of the resource, skipping nil storage:
+
+	GET    /resource
+	GET    /resource/:id
+
+Other methods are answered with a 405 listing the supported ones. Write handlers
+can still be registered afterwards.
+*/
+func (res *Resource) ReadOnly(get store.Get, list store.List) {
+	defer res.record(func(clone *Resource) { clone.ReadOnly(get, list) })()
+
+	res.checkRegistration("a route")
+
+	if get != nil {
+		res.Get(get)
+	}
+	if list != nil {
+		res.List(list)
+	}
+}
+
+/*
+WriteOnly registers the write handlers of the resource, skipping nil storage, for
+ingestion endpoints:
+
+	POST   /resource
+	PATCH  /resource/:id
+	DELETE /resource/:id
+
+Other methods are answered with a 405 listing the supported ones. Read handlers
+can still be registered afterwards.
+*/
+func (res *Resource) WriteOnly(save store.Save, update store.Update, delete store.Delete) {
+	defer res.record(func(clone *Resource) { clone.WriteOnly(save, update, delete) })()
+
+	res.checkRegistration("a route")
+
+	if save != nil {
+		res.Post(save)
+	}
+	if update != nil {
+		res.Patch(update)
+	}
+	if delete != nil {
+		res.Delete(delete)
+	}
+}
+
 /*
 CRUD is syntactic sugar and a shortcut for registering all JSON API CRUD
 routes for a compatible storage implementation:
@@ -95,6 +313,13 @@ Registers handlers for:
 	PATCH  /resource/:id
 */
 func (res *Resource) CRUD(storage store.CRUD) {
+	defer res.record(func(clone *Resource) { clone.CRUD(storage) })()
+
+	res.checkRegistration("a route")
+
+	res.tx, _ = storage.(store.Transactional)
+	res.dryRunner, _ = storage.(store.DryRunner)
+
 	res.Get(storage.Get)
 	res.Patch(storage.Update)
 	res.Post(storage.Save)
@@ -103,63 +328,117 @@ func (res *Resource) CRUD(storage store.CRUD) {
 }
 
 // Post registers a `POST /resource` handler with the resource
-func (res *Resource) Post(storage store.Save) {
-	res.HandleFuncC(
+func (res *Resource) Post(storage store.Save, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.Post(storage, opts...) })()
+
+	res.checkRegistration("a route")
+
+	res.storage.save = storage
+
+	meta := res.handleRoute(
 		pat.Post(patRoot),
+		OpCreate,
 		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 			res.postHandler(ctx, w, r, storage)
 		},
 	)
+	meta.dryRun = true
+	res.nameRoute(meta, opts)
 
 	res.addRoute(post, patRoot)
 }
 
 // Get registers a `GET /resource/:id` handler for the resource
-func (res *Resource) Get(storage store.Get) {
-	res.HandleFuncC(
-		pat.Get(patID),
-		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.getHandler(ctx, w, r, storage)
-		},
-	)
+func (res *Resource) Get(storage store.Get, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.Get(storage, opts...) })()
 
-	res.addRoute(get, patID)
+	res.GetIncluded(withoutIncluded(storage), opts...)
 }
 
 // List registers a `GET /resource` handler for the resource
-func (res *Resource) List(storage store.List) {
-	res.HandleFuncC(
-		pat.Get(patRoot),
+func (res *Resource) List(storage store.List, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.List(storage, opts...) })()
+
+	res.ListIncluded(listWithoutIncluded(storage), opts...)
+}
+
+// Delete registers a `DELETE /resource/:id` handler for the resource
+func (res *Resource) Delete(storage store.Delete, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.Delete(storage, opts...) })()
+
+	res.checkRegistration("a route")
+
+	res.storage.delete = storage
+
+	res.nameRoute(res.handleRoute(
+		pat.Delete(res.idRoute()),
+		OpDelete,
 		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.listHandler(ctx, w, r, storage)
+			res.deleteHandler(ctx, w, r, func(ctx context.Context, id string) (map[string]interface{}, jsh.ErrorType) {
+				return nil, storage(ctx, id)
+			})
 		},
-	)
+	), opts)
 
-	res.addRoute(get, patRoot)
+	res.addRoute(delete, res.idRoute())
 }
 
-// Delete registers a `DELETE /resource/:id` handler for the resource
-func (res *Resource) Delete(storage store.Delete) {
-	res.HandleFuncC(
-		pat.Delete(patID),
+/*
+DeleteMeta registers a `DELETE /resource/:id` handler for the resource, which
+responds 200 with a meta-only document when storage returns meta information,
+or 204 otherwise:
+
+	resource.DeleteMeta(func(ctx context.Context, id string) (map[string]interface{}, jsh.ErrorType) {
+		purgeAt, err := queuePurge(ctx, id)
+		if err != nil {
+			return nil, jsh.ISE(err.Error())
+		}
+
+		return map[string]interface{}{"purgeAt": purgeAt}, nil
+	})
+
+It takes the place of Delete. Atomic "remove" operations ignore the meta.
+*/
+func (res *Resource) DeleteMeta(storage store.DeleteWithMeta, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.DeleteMeta(storage, opts...) })()
+
+	res.checkRegistration("a route")
+
+	res.storage.delete = func(ctx context.Context, id string) jsh.ErrorType {
+		_, err := storage(ctx, id)
+		return err
+	}
+
+	res.nameRoute(res.handleRoute(
+		pat.Delete(res.idRoute()),
+		OpDelete,
 		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 			res.deleteHandler(ctx, w, r, storage)
 		},
-	)
+	), opts)
 
-	res.addRoute(delete, patID)
+	res.addRoute(delete, res.idRoute())
 }
 
 // Patch registers a `PATCH /resource/:id` handler for the resource
-func (res *Resource) Patch(storage store.Update) {
-	res.HandleFuncC(
-		pat.Patch(patID),
+func (res *Resource) Patch(storage store.Update, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.Patch(storage, opts...) })()
+
+	res.checkRegistration("a route")
+
+	res.storage.update = storage
+
+	meta := res.handleRoute(
+		pat.Patch(res.idRoute()),
+		OpUpdate,
 		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 			res.patchHandler(ctx, w, r, storage)
 		},
 	)
+	meta.dryRun = true
+	res.nameRoute(meta, opts)
 
-	res.addRoute(patch, patID)
+	res.addRoute(patch, res.idRoute())
 }
 
 // ToOne registers a `GET /resource/:id/(relationships/)<resourceType>` route which
@@ -167,67 +446,123 @@ func (res *Resource) Patch(storage store.Update) {
 // type and "resourceType" as specified here. The "/relationships/" uri component is
 // optional.
 //
+// A single trailing "s" is removed from "resourceType" to name the relationship,
+// both in the route and in Relationships: "users" and "user" register "user", but
+// "status" registers "statu". Use ToOneExact for names ending in "s".
+//
 // CRUD actions on a specific relationship "resourceType" object should be performed
 // via it's own top level /<resourceType> jsh-api handler as per JSONAPI specification.
 func (res *Resource) ToOne(
 	resourceType string,
 	storage store.Get,
+	opts ...RouteOption,
+) {
+	defer res.record(func(clone *Resource) { clone.ToOne(resourceType, storage, opts...) })()
+
+	relationship := strings.TrimSuffix(resourceType, "s")
+	res.ToOneExact(relationship, storage, opts...)
+	res.relatedTypes[relationship] = resourceType
+}
+
+// ToOneExact registers a ToOne relationship named "relationship" verbatim, for
+// both the `GET /resource/:id/(relationships/)<relationship>` route and the
+// Relationships key.
+func (res *Resource) ToOneExact(
+	relationship string,
+	storage store.Get,
+	opts ...RouteOption,
 ) {
-	resourceType = strings.TrimSuffix(resourceType, "s")
+	defer res.record(func(clone *Resource) { clone.ToOneExact(relationship, storage, opts...) })()
 
+	related := withoutIncluded(storage)
 	res.relationshipHandler(
-		resourceType,
+		relationship,
 		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.getHandler(ctx, w, r, storage)
+			res.getHandler(ctx, w, r, related, relationship)
 		},
+		opts,
 	)
 
-	res.Relationships[resourceType] = ToOne
+	res.Relationships[relationship] = ToOne
+	res.includeStorage[relationship] = toOneInclude(storage)
+	res.relatedTypes[relationship] = relationship
 }
 
 // ToMany registers a `GET /resource/:id/(relationships/)<resourceType>s` route which
 // returns a list of "resourceType"s in a One-To-Many relationship with the parent resource.
 // The "/relationships/" uri component is optional.
 //
+// An "s" is appended to "resourceType" to name the relationship, both in the route
+// and in Relationships, unless it already ends in "s": "user" and "users" register
+// "users", but "people" registers "peoples". Use WithPluralizer or ToManyExact for
+// irregular plurals.
+//
 // CRUD actions on a specific relationship "resourceType" object should be performed
 // via it's own top level /<resourceType> jsh-api handler as per JSONAPI specification.
 func (res *Resource) ToMany(
 	resourceType string,
 	storage store.ToMany,
+	opts ...RouteOption,
 ) {
-	if !strings.HasSuffix(resourceType, "s") {
-		resourceType = fmt.Sprintf("%ss", resourceType)
+	defer res.record(func(clone *Resource) { clone.ToMany(resourceType, storage, opts...) })()
+
+	relationship := resourceType
+	switch {
+	case res.pluralize != nil:
+		relationship = res.pluralize(resourceType)
+	case !strings.HasSuffix(resourceType, "s"):
+		relationship = fmt.Sprintf("%ss", resourceType)
 	}
 
+	res.ToManyExact(relationship, storage, opts...)
+	res.relatedTypes[relationship] = resourceType
+}
+
+// ToManyExact registers a ToMany relationship named "relationship" verbatim, for
+// both the `GET /resource/:id/(relationships/)<relationship>` route and the
+// Relationships key.
+func (res *Resource) ToManyExact(
+	relationship string,
+	storage store.ToMany,
+	opts ...RouteOption,
+) {
+	defer res.record(func(clone *Resource) { clone.ToManyExact(relationship, storage, opts...) })()
+
 	res.relationshipHandler(
-		resourceType,
+		relationship,
 		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			res.toManyHandler(ctx, w, r, storage)
+			res.toManyHandler(ctx, w, r, storage, relationship)
 		},
+		opts,
 	)
 
-	res.Relationships[resourceType] = ToMany
+	res.Relationships[relationship] = ToMany
+	res.includeStorage[relationship] = storage
+	res.relatedTypes[relationship] = relationship
 }
 
 // relationshipHandler does the dirty work of setting up both routes for a single
-// relationship
+// relationship, naming the first one
 func (res *Resource) relationshipHandler(
 	resourceType string,
 	handler goji.HandlerFunc,
+	opts []RouteOption,
 ) {
 
 	// handle /.../:id/<resourceType>
-	matcher := fmt.Sprintf("%s/%s", patID, resourceType)
-	res.HandleFuncC(
+	matcher := fmt.Sprintf("%s/%s", res.idRoute(), resourceType)
+	res.nameRoute(res.handleRoute(
 		pat.Get(matcher),
+		OpRelationship,
 		handler,
-	)
+	), opts)
 	res.addRoute(get, matcher)
 
 	// handle /.../:id/relationships/<resourceType>
-	relationshipMatcher := fmt.Sprintf("%s/relationships/%s", patID, resourceType)
-	res.HandleFuncC(
+	relationshipMatcher := fmt.Sprintf("%s/relationships/%s", res.idRoute(), resourceType)
+	res.handleRoute(
 		pat.Get(relationshipMatcher),
+		OpRelationship,
 		handler,
 	)
 	res.addRoute(get, relationshipMatcher)
@@ -235,67 +570,171 @@ func (res *Resource) relationshipHandler(
 
 // Action allows you to add custom actions to your resource types, it uses the
 // GET /(prefix/)resourceTypes/:id/<actionName> path format
-func (res *Resource) Action(actionName string, storage store.Get) {
-	matcher := path.Join(patID, actionName)
+func (res *Resource) Action(actionName string, storage store.Get, opts ...RouteOption) {
+	defer res.record(func(clone *Resource) { clone.Action(actionName, storage, opts...) })()
 
-	res.HandleFuncC(
+	matcher := path.Join(res.idRoute(), actionName)
+
+	res.nameRoute(res.handleRoute(
 		pat.Get(matcher),
+		OpAction,
 		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 			res.actionHandler(ctx, w, r, storage)
 		},
-	)
+	), opts)
 
-	res.addRoute(patch, matcher)
+	res.addRoute(get, matcher)
 }
 
 // POST /resources
 func (res *Resource) postHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Save) {
-	parsedObject, parseErr := jsh.ParseObject(r)
-	if parseErr != nil && reflect.ValueOf(parseErr).IsNil() == false {
-		SendHandler(ctx, w, r, parseErr)
+	parsedObject, parseErr := res.parseObject(w, r)
+	if HasError(parseErr) {
+		res.send(ctx, w, r, parseErr)
+		return
+	}
+
+	ctx = res.withUnknownAttributes(ctx, parsedObject)
+
+	writeErrs := res.writeErrors(ctx, r, parsedObject)
+	if len(writeErrs) > 0 {
+		res.send(ctx, w, r, writeErrs)
 		return
 	}
 
-	object, err := storage(ctx, parsedObject)
-	if err != nil && reflect.ValueOf(err).IsNil() == false {
-		SendHandler(ctx, w, r, err)
+	if res.dryRun(r) {
+		res.sendDryRun(ctx, w, r, OpCreate, parsedObject)
+		return
+	}
+
+	// storage may assign the ID to the parsed object
+	clientID := parsedObject.ID != ""
+
+	storageCtx, finish := startStorage(ctx, r, "save")
+	object, err := storage(storageCtx, parsedObject)
+	err = finish(err)
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		res.send(ctx, w, r, err)
+		return
+	}
+	if object == nil {
+		res.send(ctx, w, r, res.unsavedObject("save"))
 		return
 	}
 
-	SendHandler(ctx, w, r, object)
+	res.audit(ctx, OpCreate, "", parsedObject, object)
+	res.setLocation(w, r, object)
+	res.sendWritten(ctx, w, r, OpCreate, clientID, object)
 }
 
-// GET /resources/:id
-func (res *Resource) getHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Get) {
-	id := pat.Param(ctx, "id")
+// GET /resources/:id and /resources/:id/(relationships/)<relationship>
+func (res *Resource) getHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.GetIncluded, relationship string) {
+	id := ResourceID(ctx, res)
+
+	var include includeTree
+	var fields fieldsets
+	if relationship == "" {
+		var queryErr *jsh.Error
+		include, queryErr = res.parseInclude(r)
+		if queryErr == nil {
+			fields, queryErr = parseFields(r)
+		}
+		if queryErr != nil {
+			res.send(ctx, w, r, queryErr)
+			return
+		}
+
+		if !res.awaitChange(ctx, w, r, id) {
+			return
+		}
+	}
 
-	object, err := storage(ctx, id)
-	if err != nil && reflect.ValueOf(err).IsNil() == false {
-		SendHandler(ctx, w, r, err)
+	storageCtx, finish := startStorage(ctx, r, "get")
+	object, included, err := storage(storageCtx, id)
+	err = finish(err)
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		res.send(ctx, w, r, res.notFoundError(ctx, relationship, id, err))
+		return
+	}
+	if object == nil {
+		res.send(ctx, w, r, res.missingObject(ctx, relationship, id))
 		return
 	}
 
-	SendHandler(ctx, w, r, object)
+	authErr := res.authorizeObject(ctx, r, object)
+	if authErr != nil {
+		res.send(ctx, w, r, authErr)
+		return
+	}
+
+	if relationship != "" {
+		res.send(ctx, w, r, object)
+		return
+	}
+
+	res.respond(ctx, w, r, include, fields, object, included)
 }
 
 // GET /resources
-func (res *Resource) listHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.List) {
-	list, err := storage(ctx)
-	if err != nil && reflect.ValueOf(err).IsNil() == false {
-		SendHandler(ctx, w, r, err)
+func (res *Resource) listHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.ListIncluded) {
+	var fields fieldsets
+	include, queryErr := res.parseInclude(r)
+	if queryErr == nil {
+		fields, queryErr = parseFields(r)
+	}
+	if queryErr != nil {
+		res.send(ctx, w, r, queryErr)
 		return
 	}
 
-	SendHandler(ctx, w, r, list)
+	storageCtx, finish := startStorage(ctx, r, "list")
+	list, included, err := storage(storageCtx)
+	err = finish(err)
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		res.send(ctx, w, r, err)
+		return
+	}
+
+	// empty collections are sent as [] rather than null
+	if list == nil {
+		list = jsh.List{}
+	}
+	list = res.permitted(ctx, list)
+
+	res.respond(ctx, w, r, include, fields, list, included)
 }
 
 // DELETE /resources/:id
-func (res *Resource) deleteHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Delete) {
-	id := pat.Param(ctx, "id")
+func (res *Resource) deleteHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.DeleteWithMeta) {
+	// deletes have no body, drain it to keep the connection reusable
+	res.drainBody(w, r)
+
+	id := ResourceID(ctx, res)
 
-	err := storage(ctx, id)
-	if err != nil && reflect.ValueOf(err).IsNil() == false {
-		SendHandler(ctx, w, r, err)
+	storageCtx, finish := startStorage(ctx, r, "delete")
+	meta, err := storage(storageCtx, id)
+	err = finish(err)
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		res.send(ctx, w, r, res.notFoundError(ctx, "", id, err))
+		return
+	}
+
+	res.audit(ctx, OpDelete, id, nil, nil)
+
+	if meta != nil {
+		sendMeta(w, meta)
 		return
 	}
 
@@ -304,61 +743,111 @@ func (res *Resource) deleteHandler(ctx context.Context, w http.ResponseWriter, r
 
 // PATCH /resources/:id
 func (res *Resource) patchHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Update) {
-	parsedObject, parseErr := jsh.ParseObject(r)
-	if parseErr != nil && reflect.ValueOf(parseErr).IsNil() == false {
-		SendHandler(ctx, w, r, parseErr)
+	parsedObject, parseErr := res.parseObject(w, r)
+	if HasError(parseErr) {
+		res.send(ctx, w, r, parseErr)
 		return
 	}
 
-	object, err := storage(ctx, parsedObject)
-	if err != nil && reflect.ValueOf(err).IsNil() == false {
-		SendHandler(ctx, w, r, err)
+	ctx = res.withUnknownAttributes(ctx, parsedObject)
+
+	writeErrs := res.writeErrors(ctx, r, parsedObject)
+	if len(writeErrs) > 0 {
+		res.send(ctx, w, r, writeErrs)
 		return
 	}
 
-	SendHandler(ctx, w, r, object)
+	ctx = withPatchFields(ctx, parsedObject)
+
+	if res.dryRun(r) {
+		res.sendDryRun(ctx, w, r, OpUpdate, parsedObject)
+		return
+	}
+
+	storageCtx, finish := startStorage(ctx, r, "update")
+	object, err := storage(storageCtx, parsedObject)
+	err = finish(err)
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		res.send(ctx, w, r, res.notFoundError(ctx, "", ResourceID(ctx, res), err))
+		return
+	}
+	if object == nil {
+		res.send(ctx, w, r, res.unsavedObject("update"))
+		return
+	}
+
+	res.audit(ctx, OpUpdate, ResourceID(ctx, res), parsedObject, object)
+	res.sendWritten(ctx, w, r, OpUpdate, true, object)
 }
 
 // GET /resources/:id/(relationships/)<resourceType>s
-func (res *Resource) toManyHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.ToMany) {
-	id := pat.Param(ctx, "id")
+func (res *Resource) toManyHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.ToMany, relationship string) {
+	id := ResourceID(ctx, res)
 
-	list, err := storage(ctx, id)
-	if err != nil && reflect.ValueOf(err).IsNil() == false {
-		SendHandler(ctx, w, r, err)
+	storageCtx, finish := startStorage(ctx, r, "to_many")
+	list, err := storage(storageCtx, id)
+	err = finish(err)
+	if clientGone(ctx) {
 		return
 	}
+	if HasError(err) {
+		res.send(ctx, w, r, res.notFoundError(ctx, relationship, id, err))
+		return
+	}
+
+	// empty collections are sent as [] rather than null
+	if list == nil {
+		list = jsh.List{}
+	}
+	list = res.permitted(ctx, list)
 
-	SendHandler(ctx, w, r, list)
+	res.send(ctx, w, r, list)
 }
 
 // All HTTP Methods for /resources/:id/<mutate>
 func (res *Resource) actionHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, storage store.Get) {
-	id := pat.Param(ctx, "id")
+	id := ResourceID(ctx, res)
 
-	response, err := storage(ctx, id)
-	if err != nil && reflect.ValueOf(err).IsNil() == false {
-		SendHandler(ctx, w, r, err)
+	storageCtx, finish := startStorage(ctx, r, "action")
+	response, err := storage(storageCtx, id)
+	err = finish(err)
+	if clientGone(ctx) {
+		return
+	}
+	if HasError(err) {
+		res.send(ctx, w, r, res.notFoundError(ctx, "", id, err))
+		return
+	}
+	if response == nil {
+		res.send(ctx, w, r, res.missingObject(ctx, "", id))
 		return
 	}
 
-	SendHandler(ctx, w, r, response)
+	res.send(ctx, w, r, response)
+}
+
+// setLocation points the Location header of a creation response at the new object
+func (res *Resource) setLocation(w http.ResponseWriter, r *http.Request, object *jsh.Object) {
+	if object != nil && object.ID != "" {
+		objectPath, scoped := res.requestObjectPath(r, object.ID)
+		if scoped {
+			w.Header().Set("Location", res.linkBase(r)+objectPath)
+		}
+	}
 }
 
 // addRoute adds the new method and route to a route Tree for debugging and
 // informational purposes.
 func (res *Resource) addRoute(method string, route string) {
-	res.Routes = append(res.Routes, fmt.Sprintf("%s - /%s%s", method, res.Type, route))
+	res.Routes = append(res.Routes, fmt.Sprintf("%s - /%s%s", method, res.CollectionPath(), route))
 }
 
-// RouteTree prints a recursive route tree based on what the resource, and
-// all subresources have registered
+// RouteTree prints the routes registered on the resource, including its
+// relationship and action routes. Each line reads "METHOD - pattern", sorted by
+// pattern then method, see RegisteredRoutes for the structured equivalent.
 func (res *Resource) RouteTree() string {
-	var routes string
-
-	for _, route := range res.Routes {
-		routes = strings.Join([]string{routes, route}, "\n")
-	}
-
-	return routes
+	return routeTree(res.RegisteredRoutes())
 }
@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/derekdowling/go-json-spec-handler"
@@ -47,6 +48,7 @@ func TestResource(t *testing.T) {
 			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
 			So(err, ShouldBeNil)
 			So(doc.Data[0].ID, ShouldEqual, "1")
+			So(resp.Header.Get("Location"), ShouldEqual, "/bars/1")
 		})
 
 		Convey("->List()", func() {
@@ -58,6 +60,23 @@ func TestResource(t *testing.T) {
 			So(doc.Data[0].ID, ShouldEqual, "1")
 		})
 
+		Convey("->List() should send empty collections as arrays", func() {
+			empty := NewResource("empties")
+			empty.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+				return nil, nil
+			})
+
+			emptyAPI := New("")
+			emptyAPI.Add(empty)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest("GET", "/empties", nil)
+			emptyAPI.ServeHTTP(recorder, request)
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"data": []`)
+		})
+
 		Convey("->Fetch()", func() {
 			doc, resp, err := jsc.Fetch(baseURL, testResourceType, "3")
 
@@ -105,7 +124,7 @@ func TestActionHandler(t *testing.T) {
 
 		Convey("Resource State", func() {
 			So(len(resource.Routes), ShouldEqual, 6)
-			So(resource.Routes[len(resource.Routes)-1], ShouldEqual, "PATCH - /bars/:id/testAction")
+			So(resource.Routes[len(resource.Routes)-1], ShouldEqual, "GET - /bars/:id/testAction")
 		})
 
 		Convey("->Custom()", func() {
@@ -226,3 +245,330 @@ func TestToMany(t *testing.T) {
 		})
 	})
 }
+
+func TestNilStorageResults(t *testing.T) {
+
+	resource := NewResource(testResourceType)
+	resource.CRUD(&nilStorage{})
+	resource.ToOne("foo", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		return nil, nil
+	})
+	resource.ToMany("foos", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+		return nil, nil
+	})
+	resource.Action("reset", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		return nil, nil
+	})
+
+	api := New("")
+	api.Add(resource)
+
+	send := func(method string, url string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request.Header.Set("Content-Type", jsh.ContentType)
+		api.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	Convey("Nil Storage Result Tests", t, func() {
+
+		Convey("should respond 404 to a nil object from Get", func() {
+			recorder := send("GET", "/bars/1", "")
+			So(recorder.Code, ShouldEqual, http.StatusNotFound)
+			So(recorder.Body.String(), ShouldContainSubstring, "No resource of type 'bars' exists for ID: 1")
+		})
+
+		Convey("should respond 404 to a nil object from ToOne", func() {
+			recorder := send("GET", "/bars/1/relationships/foo", "")
+			So(recorder.Code, ShouldEqual, http.StatusNotFound)
+			So(recorder.Body.String(), ShouldContainSubstring, "No 'foo' is related to the resource of type 'bars' with ID: 1")
+		})
+
+		Convey("should respond 404 to a nil object from an Action", func() {
+			recorder := send("GET", "/bars/1/reset", "")
+			So(recorder.Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("should send an empty list for a nil List", func() {
+			recorder := send("GET", "/bars", "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"data": []`)
+		})
+
+		Convey("should send an empty list for a nil ToMany", func() {
+			recorder := send("GET", "/bars/1/foos", "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"data": []`)
+		})
+
+		Convey("should respond 500 to a nil object from Save", func() {
+			recorder := send("POST", "/bars", `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`)
+			So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+			So(recorder.Header().Get("Location"), ShouldBeEmpty)
+		})
+
+		Convey("should respond 500 to a nil object from Update", func() {
+			recorder := send("PATCH", "/bars/1", `{"data": {"type": "bars", "id": "1", "attributes": {"foo": "bar"}}}`)
+			So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+	})
+}
+
+// nilStorage returns neither results nor errors
+type nilStorage struct{}
+
+func (s *nilStorage) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	return nil, nil
+}
+
+func (s *nilStorage) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	return nil, nil
+}
+
+func (s *nilStorage) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	return nil, nil
+}
+
+func (s *nilStorage) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	return nil, nil
+}
+
+func (s *nilStorage) Delete(ctx context.Context, id string) jsh.ErrorType {
+	return nil
+}
+
+func TestExactRelationships(t *testing.T) {
+
+	Convey("Exact Relationship Tests", t, func() {
+
+		toOne := func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return sampleObject(id, "statuses", testObjAttrs), nil
+		}
+		toMany := func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return jsh.List{sampleObject("1", "people", testObjAttrs)}, nil
+		}
+
+		resource := NewResource(testResourceType)
+
+		api := New("")
+		api.Add(resource)
+
+		get := func(url string) int {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+			return recorder.Code
+		}
+
+		Convey("->ToOne() and ->ToMany()", func() {
+			resource.ToOne("status", toOne)
+			resource.ToMany("people", toMany)
+
+			Convey("should transform relationship names", func() {
+				So(resource.Relationships, ShouldResemble, map[string]Relationship{"statu": ToOne, "peoples": ToMany})
+				So(get("/bars/1/statu"), ShouldEqual, http.StatusOK)
+				So(get("/bars/1/peoples"), ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("->ToOneExact()", func() {
+			for _, name := range []string{"status", "series", "line-item"} {
+				resource.ToOneExact(name, toOne)
+			}
+
+			Convey("should use relationship names verbatim", func() {
+				So(resource.Relationships, ShouldResemble, map[string]Relationship{
+					"status":    ToOne,
+					"series":    ToOne,
+					"line-item": ToOne,
+				})
+
+				So(get("/bars/1/status"), ShouldEqual, http.StatusOK)
+				So(get("/bars/1/relationships/series"), ShouldEqual, http.StatusOK)
+				So(get("/bars/1/line-item"), ShouldEqual, http.StatusOK)
+				So(get("/bars/1/statu"), ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("->ToManyExact()", func() {
+			for _, name := range []string{"people", "series", "line-items"} {
+				resource.ToManyExact(name, toMany)
+			}
+
+			Convey("should use relationship names verbatim", func() {
+				So(resource.Relationships, ShouldResemble, map[string]Relationship{
+					"people":     ToMany,
+					"series":     ToMany,
+					"line-items": ToMany,
+				})
+
+				So(get("/bars/1/people"), ShouldEqual, http.StatusOK)
+				So(get("/bars/1/relationships/series"), ShouldEqual, http.StatusOK)
+				So(get("/bars/1/relationships/line-items"), ShouldEqual, http.StatusOK)
+				So(get("/bars/1/peoples"), ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+// closeRecorder records whether a request body was closed
+type closeRecorder struct {
+	*strings.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestDeleteMeta(t *testing.T) {
+
+	Convey("Delete Meta Tests", t, func() {
+
+		var removed []string
+
+		resource := NewResource(testResourceType)
+		resource.DeleteMeta(func(ctx context.Context, id string) (map[string]interface{}, jsh.ErrorType) {
+			if id == "missing" {
+				return nil, jsh.NotFound(testResourceType, id)
+			}
+
+			removed = append(removed, id)
+			if id == "queued" {
+				return map[string]interface{}{"purgeAt": "2016-01-01T00:00:00Z"}, nil
+			}
+
+			return nil, nil
+		})
+
+		api := New("")
+		api.Add(resource)
+		api.AtomicOperations("operations")
+
+		send := func(method string, url string, body string, contentType string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", contentType)
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should respond 200 with a meta-only document", func() {
+			recorder := send("DELETE", "/bars/queued", "", jsh.ContentType)
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, jsh.ContentType)
+			So(recorder.Body.String(), ShouldContainSubstring, `"purgeAt": "2016-01-01T00:00:00Z"`)
+			So(recorder.Body.String(), ShouldNotContainSubstring, `"data"`)
+		})
+
+		Convey("should respond 204 without meta", func() {
+			recorder := send("DELETE", "/bars/1", "", jsh.ContentType)
+
+			So(recorder.Code, ShouldEqual, http.StatusNoContent)
+			So(recorder.Body.Len(), ShouldEqual, 0)
+			So(removed, ShouldResemble, []string{"1"})
+		})
+
+		Convey("should send storage errors", func() {
+			recorder := send("DELETE", "/bars/missing", "", jsh.ContentType)
+			So(recorder.Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("should be used by atomic remove operations", func() {
+			recorder := send("POST", "/operations", `{"atomic:operations": [{"op": "remove", "ref": {"type": "bars", "id": "queued"}}]}`, AtomicContentType)
+
+			So(recorder.Code, ShouldEqual, http.StatusNoContent)
+			So(removed, ShouldResemble, []string{"queued"})
+		})
+
+		Convey("should drain and close request bodies", func() {
+			body := &closeRecorder{Reader: strings.NewReader(`{"ignored": true}`)}
+
+			request := httptest.NewRequest("DELETE", "/bars/1", nil)
+			request.Body = body
+			api.ServeHTTP(httptest.NewRecorder(), request)
+
+			So(body.Len(), ShouldEqual, 0)
+			So(body.closed, ShouldBeTrue)
+		})
+	})
+}
+
+func TestPartialResources(t *testing.T) {
+
+	Convey("Partial Resource Tests", t, func() {
+
+		storage := &MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs, ListCount: 1}
+
+		send := func(api *API, method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		object := `{"data": {"type": "bars", "id": "1", "attributes": {"foo": "bar"}}}`
+
+		Convey("->NewReadOnlyResource()", func() {
+			resource := NewReadOnlyResource(testResourceType, storage.Get, storage.List)
+
+			api := New("")
+			api.Add(resource)
+
+			Convey("should serve reads only", func() {
+				So(send(api, "GET", "/bars", "").Code, ShouldEqual, http.StatusOK)
+				So(send(api, "GET", "/bars/1", "").Code, ShouldEqual, http.StatusOK)
+
+				recorder := send(api, "POST", "/bars", object)
+				So(recorder.Code, ShouldEqual, http.StatusMethodNotAllowed)
+				So(recorder.Header().Get("Allow"), ShouldEqual, "GET, HEAD, OPTIONS")
+
+				recorder = send(api, "DELETE", "/bars/1", "")
+				So(recorder.Code, ShouldEqual, http.StatusMethodNotAllowed)
+				So(recorder.Header().Get("Allow"), ShouldEqual, "GET, HEAD, OPTIONS")
+			})
+
+			Convey("should still accept write handlers", func() {
+				resource.Patch(storage.Update)
+
+				So(send(api, "PATCH", "/bars/1", object).Code, ShouldEqual, http.StatusOK)
+				So(send(api, "DELETE", "/bars/1", "").Header().Get("Allow"), ShouldEqual, "GET, HEAD, OPTIONS, PATCH")
+			})
+		})
+
+		Convey("->ReadOnly()", func() {
+			resource := NewResource(testResourceType)
+			resource.ReadOnly(storage.Get, nil)
+
+			Convey("should not register nil storage", func() {
+				So(resource.RegisteredRoutes(), ShouldResemble, []Route{
+					{Method: "GET", Pattern: "/bars/:id", ResourceType: testResourceType, Operation: OpRead},
+				})
+			})
+		})
+
+		Convey("->WriteOnly()", func() {
+			resource := NewResource(testResourceType)
+			resource.WriteOnly(storage.Save, storage.Update, nil)
+
+			api := New("")
+			api.Add(resource)
+
+			Convey("should serve writes only", func() {
+				So(send(api, "POST", "/bars", object).Code, ShouldEqual, http.StatusCreated)
+				So(send(api, "PATCH", "/bars/1", object).Code, ShouldEqual, http.StatusOK)
+
+				recorder := send(api, "GET", "/bars", "")
+				So(recorder.Code, ShouldEqual, http.StatusMethodNotAllowed)
+				So(recorder.Header().Get("Allow"), ShouldEqual, "OPTIONS, POST")
+
+				recorder = send(api, "DELETE", "/bars/1", "")
+				So(recorder.Code, ShouldEqual, http.StatusMethodNotAllowed)
+				So(recorder.Header().Get("Allow"), ShouldEqual, "OPTIONS, PATCH")
+			})
+		})
+	})
+}
@@ -0,0 +1,473 @@
+package jshapi
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"sync/atomic"
+
+	"goji.io"
+	"goji.io/middleware"
+	"goji.io/pat"
+	"golang.org/x/net/context"
+)
+
+/*
+Route describes a route of the API, as listed by API.Routes and reported to
+metrics. Pattern is the full route pattern such as /users/:id. When reporting a
+request that no resource route matched, it is the pattern matched by the API
+instead, which keeps label cardinality low.
+*/
+type Route struct {
+	Method       string
+	Pattern      string
+	ResourceType string
+	Operation    Operation
+	// AliasOf is the canonical pattern of routes served under an alias of the
+	// resource type, see Resource.Alias
+	AliasOf string
+	// Name is the name given to the route with Named, unique across the API, for
+	// API.Reverse and exports
+	Name string
+}
+
+/*
+Routes returns the routes registered through the resource helpers, Resource.Wrap
+excepted, and by the API itself, sorted by pattern and method. A GET route also
+answers HEAD requests, which are not listed separately.
+*/
+func (a *API) Routes() []Route {
+	routes := append([]Route{}, a.routes...)
+
+	for _, resource := range a.Resources {
+		routes = append(routes, resource.RegisteredRoutes()...)
+	}
+
+	sort.Sort(routeList(routes))
+	return routes
+}
+
+/*
+RegisteredRoutes returns the routes registered through the resource helpers,
+Resource.Wrap excepted, sorted by pattern and method, including those served under
+its aliases. Patterns include the prefix of the API the resource was added to.
+*/
+func (res *Resource) RegisteredRoutes() []Route {
+	routes := []Route{}
+
+	for registered, meta := range res.routeOperations {
+		p := registered.(*pat.Pattern)
+
+		for _, method := range routeMethods(p) {
+			routes = append(routes, Route{
+				Method:       method,
+				Pattern:      res.fullPattern(p.String()),
+				ResourceType: res.Type,
+				Operation:    meta.op,
+				Name:         meta.name,
+			})
+		}
+	}
+
+	routes = append(routes, res.aliasRoutes(routes)...)
+
+	sort.Sort(routeList(routes))
+	return routes
+}
+
+// routeTree formats routes one per line, as "METHOD - pattern", followed by
+// "(alias of pattern)" for alias routes
+func routeTree(routes []Route) string {
+	var tree bytes.Buffer
+	for _, route := range routes {
+		if route.AliasOf != "" {
+			fmt.Fprintf(&tree, "%s - %s (alias of %s)\n", route.Method, route.Pattern, route.AliasOf)
+			continue
+		}
+		fmt.Fprintf(&tree, "%s - %s\n", route.Method, route.Pattern)
+	}
+
+	return tree.String()
+}
+
+// routeMethods lists the methods of a pattern, omitting the implicit HEAD of GET
+func routeMethods(p *pat.Pattern) []string {
+	methods := p.HTTPMethods()
+	_, hasGet := methods[get]
+
+	list := []string{}
+	for method := range methods {
+		if method == "HEAD" && hasGet {
+			continue
+		}
+		list = append(list, method)
+	}
+
+	return list
+}
+
+// routeList sorts routes by pattern, then method
+type routeList []Route
+
+func (l routeList) Len() int {
+	return len(l)
+}
+
+func (l routeList) Less(i, j int) bool {
+	if l[i].Pattern != l[j].Pattern {
+		return l[i].Pattern < l[j].Pattern
+	}
+	return l[i].Method < l[j].Method
+}
+
+func (l routeList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+/*
+RouteInfo describes the resource route handling a request. Pattern is the full
+route pattern, such as /users/:id.
+*/
+type RouteInfo struct {
+	ResourceType string
+	Pattern      string
+	Operation    Operation
+}
+
+/*
+RouteInfoFromContext returns the resource route handling the current request. It
+is available to storage, and to middleware added to the resource itself, for all
+routes registered through the resource helpers, and for custom routes wrapped
+with Resource.Wrap.
+*/
+func RouteInfoFromContext(ctx context.Context) (RouteInfo, bool) {
+	meta := routeFromContext(ctx)
+	if meta == nil {
+		return RouteInfo{}, false
+	}
+
+	return *meta.info(), true
+}
+
+// routeFromContext returns the metadata of the resource route handling a request
+func routeFromContext(ctx context.Context) *routeMeta {
+	meta, _ := ctx.Value(resourceRouteKey).(*routeMeta)
+	return meta
+}
+
+/*
+Wrap gives a custom route handler the behavior of the routes registered through
+the resource helpers: the route is exposed via RouteInfoFromContext and route
+reporting, traced, and authorized. route is the pattern relative to the resource,
+and op can be any Operation, including custom ones:
+
+	resource.HandleFuncC(
+		pat.Get("/search/:name"),
+		resource.Wrap("search", "/search/:name", searchHandler),
+	)
+*/
+func (res *Resource) Wrap(op Operation, route string, handler goji.HandlerFunc, opts ...RouteOption) goji.HandlerFunc {
+	meta := res.newRouteMeta(op, route)
+	if len(opts) > 0 {
+		defer res.record(func(clone *Resource) { clone.nameRoute(clone.newRouteMeta(op, route), opts) })()
+		res.nameRoute(meta, opts)
+	}
+
+	return res.wrapRoute(meta, handler)
+}
+
+// wrapRoute implements Wrap for a route whose metadata is already computed
+func (res *Resource) wrapRoute(meta *routeMeta, handler goji.HandlerFunc) goji.HandlerFunc {
+	return (&routeHandler{meta: meta, handler: handler}).ServeHTTPC
+}
+
+/*
+routeHandler serves a route of a resource. It is bound to the route metadata at
+registration, so that serving a request neither builds closures nor formats
+strings, and only adds the route to the context.
+*/
+type routeHandler struct {
+	meta    *routeMeta
+	handler goji.HandlerFunc
+}
+
+// ServeHTTPC implements goji.Handler
+func (h *routeHandler) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	meta, res := h.meta, h.meta.res
+	ctx = withRoute(ctx, meta)
+
+	meta.hits.Add(1)
+	if res.api != nil {
+		res.api.inFlight.Add(1)
+		defer res.api.inFlight.Add(-1)
+	}
+
+	ctx, w, endSpan := res.api.traceHandler(ctx, w, meta.spanName)
+	defer endSpan()
+
+	if writeOperation(meta.op) && res.ReadOnlyMode() {
+		sendReadOnly(ctx, w, r, res.notice())
+		return
+	}
+
+	if writeOperation(meta.op) && r.Method != get && !meta.dryRun && res.dryRun(r) {
+		res.send(ctx, w, r, dryRunUnsupported(r.Method))
+		return
+	}
+
+	if res.sealed != nil {
+		queryErr := res.sealedQueryError(r)
+		if queryErr != nil {
+			res.send(ctx, w, r, queryErr)
+			return
+		}
+	}
+
+	var id string
+	if res.activeAuthorizer() != nil || res.idPattern != nil {
+		// root routes have no id, pat.Param would panic
+		var hasID bool
+		id, hasID = res.routeID(ctx)
+
+		if hasID {
+			idErr := res.idError(id)
+			if idErr != nil {
+				res.send(ctx, w, r, idErr)
+				return
+			}
+		}
+	}
+
+	err := res.authorizeRequest(ctx, r, meta.op, id)
+	if err != nil {
+		res.send(ctx, w, r, err)
+		return
+	}
+
+	h.handler(ctx, w, r)
+}
+
+/*
+handleRoute registers the handler of a route of the resource. It panics when the
+route is already registered: goji would silently keep serving the first handler.
+*/
+func (res *Resource) handleRoute(p *pat.Pattern, op Operation, handler goji.HandlerFunc) *routeMeta {
+	res.checkRegistration(fmt.Sprintf("route %s", p.String()))
+
+	method, duplicate := res.duplicateRoute(p)
+	if duplicate {
+		panic(fmt.Sprintf(
+			"jshapi: route %s %s is already registered on resource '%s'",
+			method, res.fullPattern(p.String()), res.Type,
+		))
+	}
+
+	meta := res.newRouteMeta(op, p.String())
+
+	res.routeOperations[p] = meta
+	res.HandleC(p, &routeHandler{meta: meta, handler: handler})
+
+	return meta
+}
+
+// duplicateRoute looks for a registered route matching the pattern and one of its
+// methods, returning the method they share
+func (res *Resource) duplicateRoute(p *pat.Pattern) (string, bool) {
+	methods := routeMethods(p)
+
+	for registered := range res.routeOperations {
+		existing := registered.(*pat.Pattern)
+		if existing.String() != p.String() {
+			continue
+		}
+
+		for _, method := range routeMethods(existing) {
+			for _, candidate := range methods {
+				if method == candidate {
+					return method, true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
+// routeInfoMiddleware exposes the matched route before the resource middleware run
+func (res *Resource) routeInfoMiddleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		matched := middleware.Pattern(ctx)
+		if matched != nil {
+			meta, registered := res.routeOperations[matched]
+			if registered {
+				ctx = withRoute(ctx, meta)
+			}
+		}
+
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+/*
+routeMeta holds what is known of a route at registration, so that serving a
+request does not need to format patterns or span names.
+*/
+type routeMeta struct {
+	res      *Resource
+	op       Operation
+	route    string
+	spanName string
+	// name is given by the Named route option
+	name string
+	// dryRun is set on the routes handling dry runs, see AllowDryRun
+	dryRun bool
+	// idKeys are the context keys of the id variables, see Resource.idKeys
+	idKeys []interface{}
+	// resolved holds the *resolvedRoute for the API the resource was last added to
+	resolved atomic.Value
+	// hits counts the requests served by the route, see API.MountDebug
+	hits atomic.Uint64
+}
+
+// resolvedRoute is the RouteInfo of a route, which depends on the API prefix
+type resolvedRoute struct {
+	api  *API
+	info *RouteInfo
+}
+
+// newRouteMeta computes the metadata of a route of the resource
+func (res *Resource) newRouteMeta(op Operation, route string) *routeMeta {
+	return &routeMeta{
+		res:      res,
+		op:       op,
+		route:    route,
+		spanName: fmt.Sprintf("jshapi.%s.%s", res.Type, op),
+		idKeys:   variableKeys(res.idParams()),
+	}
+}
+
+// info returns the RouteInfo of the route, resolving it again if the resource has
+// since been added to another API
+func (m *routeMeta) info() *RouteInfo {
+	resolved, _ := m.resolved.Load().(*resolvedRoute)
+	if resolved != nil && resolved.api == m.res.api {
+		return resolved.info
+	}
+
+	resolved = &resolvedRoute{
+		api: m.res.api,
+		info: &RouteInfo{
+			ResourceType: m.res.Type,
+			Pattern:      m.res.fullPattern(m.route),
+			Operation:    m.op,
+		},
+	}
+	m.resolved.Store(resolved)
+
+	return resolved.info
+}
+
+// withRoute records the resource route handling a request in the context
+func withRoute(ctx context.Context, meta *routeMeta) context.Context {
+	// the route middleware and the route handler both record the route
+	if routeFromContext(ctx) == meta {
+		return ctx
+	}
+
+	info := meta.info()
+
+	reported, _ := ctx.Value(routeInfoKey).(*routeInfo)
+	if reported != nil {
+		reported.pattern = info.Pattern
+		reported.resourceType = info.ResourceType
+		reported.op = info.Operation
+	}
+
+	return context.WithValue(ctx, resourceRouteKey, meta)
+}
+
+// fullPattern prefixes a route of the resource with the API prefix, resource prefix
+// and collection path
+func (res *Resource) fullPattern(route string) string {
+	return res.basePath(res.CollectionPath()) + route
+}
+
+// basePath is the path of the resource routes for a type segment, the resource
+// type or one of its aliases
+func (res *Resource) basePath(typeSegment string) string {
+	prefix := "/"
+	if res.api != nil {
+		prefix = res.api.prefix
+	}
+
+	return path.Join(prefix, res.prefix, typeSegment)
+}
+
+// routeInfo is shared through the context by the middleware reporting on the
+// route, and filled in once it is matched
+type routeInfo struct {
+	api          *API
+	pattern      string
+	apiPattern   string
+	resourceType string
+	op           Operation
+	// clientGone is set when the response was skipped as the client went away
+	clientGone bool
+}
+
+// withRouteInfo returns the routeInfo of the context, adding one if needed
+func withRouteInfo(ctx context.Context) (context.Context, *routeInfo) {
+	info, _ := ctx.Value(routeInfoKey).(*routeInfo)
+	if info != nil {
+		return ctx, info
+	}
+
+	info = &routeInfo{}
+	return context.WithValue(ctx, routeInfoKey, info), info
+}
+
+// routePattern returns the matched pattern, falling back to the pattern matched by
+// the API when no resource route matched
+func (i *routeInfo) routePattern() string {
+	switch {
+	case i.pattern != "":
+		return i.pattern
+	case i.apiPattern != "":
+		return i.apiPattern
+	default:
+		return "unmatched"
+	}
+}
+
+// route builds the Route reported for a request
+func (i *routeInfo) route(r *http.Request) Route {
+	return Route{
+		Method:       r.Method,
+		Pattern:      i.routePattern(),
+		ResourceType: i.resourceType,
+		Operation:    i.op,
+	}
+}
+
+// recordAPIPattern records the pattern matched by the API router, used when no
+// resource route matches
+func recordAPIPattern(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		info, _ := ctx.Value(routeInfoKey).(*routeInfo)
+		if info != nil {
+			info.recordAPIPattern(ctx)
+		}
+
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+// recordAPIPattern records the pattern matched by the API router, if any
+func (i *routeInfo) recordAPIPattern(ctx context.Context) {
+	apiPattern, isStringer := middleware.Pattern(ctx).(fmt.Stringer)
+	if isStringer {
+		i.apiPattern = apiPattern.String()
+	}
+}
@@ -0,0 +1,141 @@
+package jshapi
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"goji.io"
+	"goji.io/pat"
+	"golang.org/x/net/context"
+)
+
+/*
+goji matches routes in the order they were registered, so a `GET /:id` route
+registered before a custom `GET /export` route would capture "export" as an id.
+Resources rather hold on to their routes until they first serve a request, or are
+frozen, and only then register them with their mux, most specific first, each path
+followed by its 405 route from methodNotAllowedRoutes.
+*/
+
+// pendingRoute is a route waiting to be registered with the resource mux
+type pendingRoute struct {
+	pattern  goji.Pattern
+	register func()
+}
+
+// routeRegistry holds the routes of a resource until they are registered
+type routeRegistry struct {
+	once    sync.Once
+	done    int32
+	pending []pendingRoute
+}
+
+/*
+handleOrdered runs the registration of a route with the resource mux once routes
+are ordered, or right away if that already happened: routes added once the
+resource serves requests, which Freeze prevents, are matched after all others.
+*/
+func (res *Resource) handleOrdered(p goji.Pattern, register func()) {
+	if res.registry.isDone() {
+		register()
+		return
+	}
+
+	res.registry.pending = append(res.registry.pending, pendingRoute{pattern: p, register: register})
+}
+
+// isDone reports whether the routes are registered with the resource mux
+func (registry *routeRegistry) isDone() bool {
+	return atomic.LoadInt32(&registry.done) == 1
+}
+
+// registerRoutes registers the pending routes with the resource mux, ordered by
+// specificity
+func (res *Resource) registerRoutes() {
+	res.registry.once.Do(func() {
+		routes := res.registry.pending
+		routes = append(routes, res.methodNotAllowedRoutes(routes)...)
+		sort.Stable(bySpecificity(routes))
+
+		for _, route := range routes {
+			route.register()
+		}
+
+		res.registry.pending = nil
+		atomic.StoreInt32(&res.registry.done, 1)
+	})
+}
+
+// ServeHTTP implements http.Handler, registering the resource routes beforehand
+func (res *Resource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	res.ServeHTTPC(context.TODO(), w, r)
+}
+
+// ServeHTTPC implements goji.Handler, registering the resource routes beforehand
+func (res *Resource) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	res.registerRoutes()
+	res.Mux.ServeHTTPC(ctx, w, res.scopeRequest(ctx, r))
+}
+
+/*
+bySpecificity orders routes so that literal path segments are matched before
+variables, and variables before wildcards, comparing segment by segment. Patterns
+other than *pat.Pattern can't be compared, they keep their registration order
+ahead of all others.
+*/
+type bySpecificity []pendingRoute
+
+func (s bySpecificity) Len() int {
+	return len(s)
+}
+
+func (s bySpecificity) Less(i, j int) bool {
+	a := segmentClasses(s[i].pattern)
+	b := segmentClasses(s[j].pattern)
+
+	for index := 0; index < len(a) && index < len(b); index++ {
+		if a[index] != b[index] {
+			return a[index] < b[index]
+		}
+	}
+
+	return len(a) < len(b)
+}
+
+func (s bySpecificity) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// segment classes, in decreasing order of specificity
+const (
+	segmentEnd = iota
+	segmentLiteral
+	segmentVariable
+	segmentWildcard
+)
+
+// segmentClasses classifies the path segments of a pattern, ending with
+// segmentEnd, or returns nil for patterns that aren't *pat.Pattern
+func segmentClasses(p goji.Pattern) []int {
+	typed, isPat := p.(*pat.Pattern)
+	if !isPat {
+		return nil
+	}
+
+	classes := []int{}
+	for _, segment := range strings.Split(strings.TrimPrefix(typed.String(), "/"), "/") {
+		switch {
+		case segment == "*":
+			classes = append(classes, segmentWildcard)
+		case strings.Contains(segment, ":"):
+			classes = append(classes, segmentVariable)
+		default:
+			classes = append(classes, segmentLiteral)
+		}
+	}
+
+	return append(classes, segmentEnd)
+}
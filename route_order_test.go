@@ -0,0 +1,120 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"goji.io"
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRouteOrder(t *testing.T) {
+
+	Convey("Route Order Tests", t, func() {
+
+		var served string
+
+		get := func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			served = "get " + id
+			return sampleObject(id, "users", testObjAttrs), nil
+		}
+		posts := func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			served = "posts " + id
+			return jsh.List{}, nil
+		}
+		export := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			served = "export"
+			w.WriteHeader(http.StatusOK)
+		}
+
+		registrations := map[string]func(resource *Resource){
+			"get":    func(resource *Resource) { resource.Get(get) },
+			"posts":  func(resource *Resource) { resource.ToMany("posts", posts) },
+			"export": func(resource *Resource) { resource.HandleFuncC(pat.Get("/export"), export) },
+		}
+
+		serve := func(order []string, url string) string {
+			resource := NewResource("users")
+			for _, name := range order {
+				registrations[name](resource)
+			}
+
+			api := New("")
+			api.Add(resource)
+
+			served = ""
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			return served
+		}
+
+		orders := [][]string{
+			{"get", "posts", "export"},
+			{"export", "posts", "get"},
+			{"posts", "get", "export"},
+		}
+
+		for _, order := range orders {
+			Convey("should route regardless of registration order "+order[0]+", "+order[1]+", "+order[2], func() {
+				So(serve(order, "/users/export"), ShouldEqual, "export")
+				So(serve(order, "/users/7"), ShouldEqual, "get 7")
+				So(serve(order, "/users/7/posts"), ShouldEqual, "posts 7")
+			})
+		}
+
+		Convey("should order the routes of frozen resources", func() {
+			resource := NewResource("users")
+			resource.Get(get)
+			resource.HandleFuncC(pat.Get("/export"), export)
+
+			api := New("")
+			api.Add(resource)
+			api.Freeze()
+
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", "/users/export", nil))
+			So(served, ShouldEqual, "export")
+		})
+
+		Convey("->bySpecificity", func() {
+			patterns := []string{"/*", "/:id/*", "/:id/posts", "/:id", "", "/:id/relationships/posts", "/export"}
+
+			routes := []pendingRoute{}
+			for _, p := range patterns {
+				routes = append(routes, pendingRoute{pattern: pat.Get(p)})
+			}
+			routes = append(routes, pendingRoute{pattern: goji.Pattern(nil)})
+
+			sort.Stable(bySpecificity(routes))
+
+			ordered := []string{}
+			for _, route := range routes {
+				typed, isPat := route.pattern.(*pat.Pattern)
+				if !isPat {
+					ordered = append(ordered, "custom")
+					continue
+				}
+				ordered = append(ordered, typed.String())
+			}
+
+			So(ordered, ShouldResemble, []string{
+				"custom",
+				"",
+				"/export",
+				"/:id",
+				"/:id/posts",
+				"/:id/relationships/posts",
+				"/:id/*",
+				"/*",
+			})
+		})
+	})
+}
@@ -0,0 +1,291 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"goji.io"
+	"goji.io/pat"
+	"goji.io/pattern"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRouteInfo(t *testing.T) {
+
+	Convey("Route Info Tests", t, func() {
+
+		var middlewareInfo, storageInfo, customInfo RouteInfo
+		var middlewareFound bool
+
+		resource := NewResource(testResourceType)
+		resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			storageInfo, _ = RouteInfoFromContext(ctx)
+			return (&MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs}).Get(ctx, id)
+		})
+		resource.HandleFuncC(
+			pat.Get("/search/:name"),
+			resource.Wrap("search", "/search/:name", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				customInfo, _ = RouteInfoFromContext(ctx)
+				w.WriteHeader(http.StatusNoContent)
+			}),
+		)
+		resource.UseC(func(next goji.Handler) goji.Handler {
+			return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				middlewareInfo, middlewareFound = RouteInfoFromContext(ctx)
+				next.ServeHTTPC(ctx, w, r)
+			})
+		})
+
+		api := New("api")
+		api.Add(resource)
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/api/" + testResourceType
+
+		Convey("should expose the route to resource middleware and storage", func() {
+			resp, _ := negotiationRequest("GET", url+"/1", "", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			expected := RouteInfo{ResourceType: testResourceType, Pattern: "/api/bars/:id", Operation: OpRead}
+			So(middlewareFound, ShouldBeTrue)
+			So(middlewareInfo, ShouldResemble, expected)
+			So(storageInfo, ShouldResemble, expected)
+		})
+
+		Convey("should expose custom routes wrapped with Wrap", func() {
+			resp, _ := negotiationRequest("GET", url+"/search/bob", "", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+			So(customInfo, ShouldResemble, RouteInfo{
+				ResourceType: testResourceType,
+				Pattern:      "/api/bars/search/:name",
+				Operation:    "search",
+			})
+		})
+
+		Convey("should not be set outside of resource routes", func() {
+			_, found := RouteInfoFromContext(context.Background())
+			So(found, ShouldBeFalse)
+		})
+	})
+}
+
+func TestRoutes(t *testing.T) {
+
+	Convey("Routes Tests", t, func() {
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+		resource.ToMany("foos", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return nil, nil
+		})
+
+		api := New("api")
+		api.Add(resource)
+		api.AtomicOperations("operations")
+
+		Convey("->Routes()", func() {
+
+			Convey("should list resource and API routes in order", func() {
+				So(api.Routes(), ShouldResemble, []Route{
+					{Method: "GET", Pattern: "/api/bars", ResourceType: testResourceType, Operation: OpList},
+					{Method: "POST", Pattern: "/api/bars", ResourceType: testResourceType, Operation: OpCreate},
+					{Method: "DELETE", Pattern: "/api/bars/:id", ResourceType: testResourceType, Operation: OpDelete},
+					{Method: "GET", Pattern: "/api/bars/:id", ResourceType: testResourceType, Operation: OpRead},
+					{Method: "PATCH", Pattern: "/api/bars/:id", ResourceType: testResourceType, Operation: OpUpdate},
+					{Method: "GET", Pattern: "/api/bars/:id/foos", ResourceType: testResourceType, Operation: OpRelationship},
+					{Method: "GET", Pattern: "/api/bars/:id/relationships/foos", ResourceType: testResourceType, Operation: OpRelationship},
+					{Method: "POST", Pattern: "/api/operations"},
+				})
+			})
+		})
+
+		Convey("->RouteTree()", func() {
+			resource.Action("touch", nil)
+
+			Convey("should list all routes sorted by pattern then method", func() {
+				So(api.RouteTree(), ShouldEqual, strings.Join([]string{
+					"GET - /api/bars",
+					"POST - /api/bars",
+					"DELETE - /api/bars/:id",
+					"GET - /api/bars/:id",
+					"PATCH - /api/bars/:id",
+					"GET - /api/bars/:id/foos",
+					"GET - /api/bars/:id/relationships/foos",
+					"GET - /api/bars/:id/touch",
+					"POST - /api/operations",
+				}, "\n")+"\n")
+			})
+
+			Convey("should list the routes of a resource", func() {
+				So(resource.RouteTree(), ShouldStartWith, "GET - /api/bars\nPOST - /api/bars\n")
+				So(resource.RouteTree(), ShouldNotContainSubstring, "/api/operations")
+			})
+
+			Convey("should be stable across registration orders", func() {
+				other := NewResource(testResourceType)
+				other.Action("touch", nil)
+				other.ToMany("foos", nil)
+				other.CRUD(&MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs, ListCount: 1})
+
+				otherAPI := New("api")
+				otherAPI.AtomicOperations("operations")
+				otherAPI.Add(other)
+
+				So(otherAPI.RouteTree(), ShouldEqual, api.RouteTree())
+			})
+		})
+
+		Convey("->RegisteredRoutes()", func() {
+			resource.Action("touch", nil)
+
+			routes := resource.RegisteredRoutes()
+			So(routes, ShouldHaveLength, 8)
+			So(routes[7], ShouldResemble, Route{Method: "GET", Pattern: "/api/bars/:id/touch", ResourceType: testResourceType, Operation: OpAction})
+		})
+	})
+}
+
+func TestDuplicateRoutes(t *testing.T) {
+
+	Convey("Duplicate Route Tests", t, func() {
+
+		storage := &MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs, ListCount: 1}
+		toOne := func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return nil, nil
+		}
+		bulk := func(ctx context.Context, list jsh.List) (jsh.List, jsh.ErrorType) {
+			return list, nil
+		}
+
+		resource := NewResource(testResourceType)
+
+		Convey("should panic when registering a route twice", func() {
+			resource.CRUD(storage)
+
+			So(func() { resource.Get(storage.Get) }, ShouldPanicWith, "jshapi: route GET /bars/:id is already registered on resource 'bars'")
+			So(func() { resource.List(storage.List) }, ShouldPanicWith, "jshapi: route GET /bars is already registered on resource 'bars'")
+			So(func() { resource.CRUD(storage) }, ShouldPanic)
+		})
+
+		Convey("should panic when registering both Post and PostBulk", func() {
+			resource.Post(storage.Save)
+			So(func() { resource.PostBulk(bulk) }, ShouldPanicWith, "jshapi: route POST /bars is already registered on resource 'bars'")
+		})
+
+		Convey("should panic when registering both PostBulk and Post", func() {
+			resource.PostBulk(bulk)
+			So(func() { resource.Post(storage.Save) }, ShouldPanicWith, "jshapi: route POST /bars is already registered on resource 'bars'")
+		})
+
+		Convey("should panic on relationship and action name collisions", func() {
+			resource.ToOne("foos", toOne)
+			So(func() { resource.ToOneExact("foo", toOne) }, ShouldPanic)
+			So(func() { resource.Action("foo", toOne) }, ShouldPanic)
+
+			resource.Action("reset", toOne)
+			So(func() { resource.ToOneExact("reset", toOne) }, ShouldPanic)
+		})
+
+		Convey("should report the API prefix", func() {
+			api := New("api")
+			api.Add(resource)
+
+			resource.Get(storage.Get)
+			So(func() { resource.Get(storage.Get) }, ShouldPanicWith, "jshapi: route GET /api/bars/:id is already registered on resource 'bars'")
+		})
+
+		Convey("should allow distinct methods on a pattern", func() {
+			So(func() {
+				resource.Get(storage.Get)
+				resource.Patch(storage.Update)
+				resource.Delete(storage.Delete)
+			}, ShouldNotPanic)
+
+			So(resource.RegisteredRoutes(), ShouldHaveLength, 3)
+		})
+	})
+}
+
+func TestRouteHandler(t *testing.T) {
+
+	Convey("Route Handler Tests", t, func() {
+
+		resource := NewResource(testResourceType)
+
+		Convey("should only allocate the route context value per request", func() {
+			var id string
+			handler := resource.Wrap(OpRead, "/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				id = ResourceID(ctx, resource)
+			})
+
+			ctx := context.WithValue(context.Background(), pattern.Variable("id"), "1")
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/bars/1", nil)
+
+			allocs := testing.AllocsPerRun(100, func() {
+				handler(ctx, w, r)
+			})
+			So(allocs, ShouldEqual, 1)
+			So(id, ShouldEqual, "1")
+		})
+
+		Convey("should serve concurrent requests to the same routes", func() {
+			resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+				return sampleObject(ResourceID(ctx, resource), testResourceType, testObjAttrs), nil
+			})
+			resource.HandleFuncC(pat.Get("/:id/echo"), resource.Wrap("echo", "/:id/echo",
+				func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+					info, _ := RouteInfoFromContext(ctx)
+					w.Write([]byte(info.Pattern + " " + ResourceID(ctx, resource)))
+				},
+			))
+
+			api := New("api")
+			api.SetTracer(&countTracer{})
+			api.Add(resource)
+
+			failures := make(chan string, concurrentWorkers*concurrentRequests)
+			var wg sync.WaitGroup
+			for worker := 0; worker < concurrentWorkers; worker++ {
+				wg.Add(1)
+
+				go func(worker int) {
+					defer wg.Done()
+
+					for i := 0; i < concurrentRequests; i++ {
+						id := strconv.Itoa(worker*concurrentRequests + i)
+						path, expected := "/api/bars/"+id, `"id":"`+id+`"`
+						if i%2 == 1 {
+							path, expected = path+"/echo", "/api/bars/:id/echo "+id
+						}
+
+						recorder := httptest.NewRecorder()
+						api.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+
+						body := strings.Replace(recorder.Body.String(), " ", "", -1)
+						if !strings.Contains(body, strings.Replace(expected, " ", "", -1)) {
+							failures <- path + ": " + recorder.Body.String()
+						}
+					}
+				}(worker)
+			}
+
+			wg.Wait()
+			close(failures)
+
+			failed := []string{}
+			for failure := range failures {
+				failed = append(failed, failure)
+			}
+			So(failed, ShouldBeEmpty)
+		})
+	})
+}
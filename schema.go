@@ -0,0 +1,387 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+AttributesSchema attaches a JSON Schema describing the attributes of the resource.
+POST bodies are validated against the full schema, while PATCH bodies only have
+the attributes they provide validated against the matching property schemas, top
+level "required" attributes are not enforced on partial updates. Violations are
+returned as 422 errors pointing at the offending attribute, aggregated with the
+other write path checks.
+
+The schema is compiled once, AttributesSchema panics if it is invalid. The
+following keywords are supported, others are ignored:
+
+	type, enum, properties, required, additionalProperties, items,
+	minLength, maxLength, pattern, minimum, maximum, minItems, maxItems
+*/
+func (res *Resource) AttributesSchema(schemaJSON []byte) {
+	res.checkRegistration("an attributes schema")
+
+	compiled, err := CompileSchema(schemaJSON)
+	if err != nil {
+		panic(fmt.Sprintf("jshapi: invalid attributes schema for '%s': %s", res.Type, err.Error()))
+	}
+
+	res.schema = compiled
+}
+
+/*
+Schema is a compiled JSON Schema, see Resource.AttributesSchema for the supported
+keywords.
+*/
+type Schema struct {
+	types                []string
+	enum                 []interface{}
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	noAdditional         bool
+	items                *Schema
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	minimum, maximum     *float64
+	minItems, maxItems   *int
+}
+
+// CompileSchema parses and compiles a JSON Schema document
+func CompileSchema(schemaJSON []byte) (*Schema, error) {
+	raw := map[string]interface{}{}
+	err := json.Unmarshal(schemaJSON, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("schema must be a JSON object: %s", err.Error())
+	}
+
+	return compileSchema(raw, "#")
+}
+
+// compileSchema compiles a single (sub-)schema, location is used in errors
+func compileSchema(raw map[string]interface{}, location string) (*Schema, error) {
+	schema := &Schema{}
+
+	switch types := raw["type"].(type) {
+	case nil:
+	case string:
+		schema.types = []string{types}
+	case []interface{}:
+		for _, singleType := range types {
+			name, isString := singleType.(string)
+			if !isString {
+				return nil, fmt.Errorf("%s/type must only contain strings", location)
+			}
+			schema.types = append(schema.types, name)
+		}
+	default:
+		return nil, fmt.Errorf("%s/type must be a string or an array", location)
+	}
+
+	for _, name := range schema.types {
+		switch name {
+		case "null", "boolean", "object", "array", "number", "integer", "string":
+		default:
+			return nil, fmt.Errorf("%s/type '%s' is not a valid type", location, name)
+		}
+	}
+
+	if enum, exists := raw["enum"]; exists {
+		values, isArray := enum.([]interface{})
+		if !isArray {
+			return nil, fmt.Errorf("%s/enum must be an array", location)
+		}
+		schema.enum = values
+	}
+
+	if properties, exists := raw["properties"]; exists {
+		propertyMap, isObject := properties.(map[string]interface{})
+		if !isObject {
+			return nil, fmt.Errorf("%s/properties must be an object", location)
+		}
+
+		schema.properties = map[string]*Schema{}
+		for name, property := range propertyMap {
+			subSchema, err := compileSubSchema(property, location+"/properties/"+name)
+			if err != nil {
+				return nil, err
+			}
+			schema.properties[name] = subSchema
+		}
+	}
+
+	if required, exists := raw["required"]; exists {
+		names, isArray := required.([]interface{})
+		if !isArray {
+			return nil, fmt.Errorf("%s/required must be an array", location)
+		}
+
+		for _, name := range names {
+			property, isString := name.(string)
+			if !isString {
+				return nil, fmt.Errorf("%s/required must only contain strings", location)
+			}
+			schema.required = append(schema.required, property)
+		}
+	}
+
+	switch additional := raw["additionalProperties"].(type) {
+	case nil:
+	case bool:
+		schema.noAdditional = !additional
+	default:
+		subSchema, err := compileSubSchema(additional, location+"/additionalProperties")
+		if err != nil {
+			return nil, err
+		}
+		schema.additionalProperties = subSchema
+	}
+
+	if items, exists := raw["items"]; exists {
+		subSchema, err := compileSubSchema(items, location+"/items")
+		if err != nil {
+			return nil, err
+		}
+		schema.items = subSchema
+	}
+
+	var err error
+	for keyword, target := range map[string]**int{
+		"minLength": &schema.minLength,
+		"maxLength": &schema.maxLength,
+		"minItems":  &schema.minItems,
+		"maxItems":  &schema.maxItems,
+	} {
+		*target, err = schemaInt(raw, keyword, location)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for keyword, target := range map[string]**float64{
+		"minimum": &schema.minimum,
+		"maximum": &schema.maximum,
+	} {
+		value, exists := raw[keyword]
+		if !exists {
+			continue
+		}
+
+		number, isNumber := value.(float64)
+		if !isNumber {
+			return nil, fmt.Errorf("%s/%s must be a number", location, keyword)
+		}
+		*target = &number
+	}
+
+	if pattern, exists := raw["pattern"]; exists {
+		expression, isString := pattern.(string)
+		if !isString {
+			return nil, fmt.Errorf("%s/pattern must be a string", location)
+		}
+
+		schema.pattern, err = regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("%s/pattern is invalid: %s", location, err.Error())
+		}
+	}
+
+	return schema, nil
+}
+
+// compileSubSchema compiles a schema nested within another one
+func compileSubSchema(raw interface{}, location string) (*Schema, error) {
+	subSchema, isObject := raw.(map[string]interface{})
+	if !isObject {
+		return nil, fmt.Errorf("%s must be a schema object", location)
+	}
+
+	return compileSchema(subSchema, location)
+}
+
+// schemaInt reads a non-negative integer keyword
+func schemaInt(raw map[string]interface{}, keyword string, location string) (*int, error) {
+	value, exists := raw[keyword]
+	if !exists {
+		return nil, nil
+	}
+
+	number, isNumber := value.(float64)
+	if !isNumber || number < 0 || number != math.Trunc(number) {
+		return nil, fmt.Errorf("%s/%s must be a non-negative integer", location, keyword)
+	}
+
+	integer := int(number)
+	return &integer, nil
+}
+
+/*
+ValidateAttributes validates raw JSON attributes against the schema, returning a
+422 error per violation. When partial is set, only the provided attributes are
+validated and top level required attributes are not enforced.
+*/
+func (s *Schema) ValidateAttributes(attributes json.RawMessage, partial bool) jsh.ErrorList {
+	errs := jsh.ErrorList{}
+
+	var value interface{}
+	if len(bytes.TrimSpace(attributes)) > 0 {
+		err := json.Unmarshal(attributes, &value)
+		if err != nil {
+			return append(errs, schemaError("/data/attributes", "Attributes must be valid JSON"))
+		}
+	} else {
+		value = map[string]interface{}{}
+	}
+
+	s.validate(value, "/data/attributes", partial, &errs)
+	return errs
+}
+
+// validate checks a single value, partial only applies to the top level
+func (s *Schema) validate(value interface{}, pointer string, partial bool, errs *jsh.ErrorList) {
+	if len(s.types) > 0 && !s.matchesType(value) {
+		*errs = append(*errs, schemaError(pointer, fmt.Sprintf(
+			"Expected %s, got %s", strings.Join(s.types, " or "), jsonType(value),
+		)))
+		return
+	}
+
+	if s.enum != nil {
+		allowed := false
+		for _, candidate := range s.enum {
+			if reflect.DeepEqual(candidate, value) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			*errs = append(*errs, schemaError(pointer, "Value is not one of the allowed values"))
+		}
+	}
+
+	switch typed := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(typed)
+		if s.minLength != nil && length < *s.minLength {
+			*errs = append(*errs, schemaError(pointer, fmt.Sprintf("Must be at least %d characters long", *s.minLength)))
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			*errs = append(*errs, schemaError(pointer, fmt.Sprintf("Must be at most %d characters long", *s.maxLength)))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(typed) {
+			*errs = append(*errs, schemaError(pointer, fmt.Sprintf("Must match pattern '%s'", s.pattern.String())))
+		}
+
+	case float64:
+		if s.minimum != nil && typed < *s.minimum {
+			*errs = append(*errs, schemaError(pointer, fmt.Sprintf("Must be greater than or equal to %v", *s.minimum)))
+		}
+		if s.maximum != nil && typed > *s.maximum {
+			*errs = append(*errs, schemaError(pointer, fmt.Sprintf("Must be less than or equal to %v", *s.maximum)))
+		}
+
+	case []interface{}:
+		if s.minItems != nil && len(typed) < *s.minItems {
+			*errs = append(*errs, schemaError(pointer, fmt.Sprintf("Must contain at least %d items", *s.minItems)))
+		}
+		if s.maxItems != nil && len(typed) > *s.maxItems {
+			*errs = append(*errs, schemaError(pointer, fmt.Sprintf("Must contain at most %d items", *s.maxItems)))
+		}
+		if s.items != nil {
+			for index, item := range typed {
+				s.items.validate(item, fmt.Sprintf("%s/%d", pointer, index), false, errs)
+			}
+		}
+
+	case map[string]interface{}:
+		if !partial {
+			for _, name := range s.required {
+				if _, exists := typed[name]; !exists {
+					*errs = append(*errs, schemaError(pointer+"/"+escapePointer(name), "Attribute is required"))
+				}
+			}
+		}
+
+		// iterate in a stable order so that errors are deterministic
+		names := make([]string, 0, len(typed))
+		for name := range typed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			propertyPointer := pointer + "/" + escapePointer(name)
+
+			property, defined := s.properties[name]
+			switch {
+			case defined:
+				property.validate(typed[name], propertyPointer, false, errs)
+			case s.additionalProperties != nil:
+				s.additionalProperties.validate(typed[name], propertyPointer, false, errs)
+			case s.noAdditional:
+				*errs = append(*errs, schemaError(propertyPointer, "Attribute is not allowed"))
+			}
+		}
+	}
+}
+
+// matchesType checks a value against the schema's allowed types
+func (s *Schema) matchesType(value interface{}) bool {
+	actual := jsonType(value)
+
+	for _, expected := range s.types {
+		if expected == actual {
+			return true
+		}
+
+		if expected == "number" && actual == "integer" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonType returns the JSON Schema type name of a decoded JSON value
+func jsonType(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if typed == math.Trunc(typed) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// schemaError builds a 422 error for a schema violation
+func schemaError(pointer string, detail string) *jsh.Error {
+	err := &jsh.Error{
+		Title:  "Invalid Attribute",
+		Detail: detail,
+		Status: 422,
+	}
+	err.Source.Pointer = pointer
+
+	return err
+}
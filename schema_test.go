@@ -0,0 +1,109 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["name"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 2, "pattern": "^[a-z]+$"},
+		"age": {"type": "integer", "minimum": 0},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}}
+	}
+}`
+
+func TestAttributesSchema(t *testing.T) {
+
+	Convey("Attributes Schema Tests", t, func() {
+
+		Convey("->CompileSchema()", func() {
+
+			Convey("should reject invalid schemas", func() {
+				invalid := []string{
+					`[]`,
+					`{"type": "thing"}`,
+					`{"properties": []}`,
+					`{"minLength": -1}`,
+					`{"pattern": "("}`,
+					`{"properties": {"name": {"type": 1}}}`,
+				}
+
+				for _, schema := range invalid {
+					_, err := CompileSchema([]byte(schema))
+					So(err, ShouldNotBeNil)
+				}
+			})
+
+			Convey("should panic on registration of an invalid schema", func() {
+				resource := NewResource(testResourceType)
+				So(func() { resource.AttributesSchema([]byte(`{"type": 1}`)) }, ShouldPanic)
+			})
+		})
+
+		Convey("->ValidateAttributes()", func() {
+			schema, err := CompileSchema([]byte(testSchema))
+			So(err, ShouldBeNil)
+
+			Convey("should accept valid attributes", func() {
+				errs := schema.ValidateAttributes([]byte(`{"name": "bob", "age": 3, "tags": ["a"]}`), false)
+				So(errs, ShouldBeEmpty)
+			})
+
+			Convey("should report every violation with a pointer", func() {
+				errs := schema.ValidateAttributes([]byte(`{"age": 1.5, "role": "root", "tags": ["a", 2, "c"], "extra": true}`), false)
+
+				pointers := []string{}
+				for _, err := range errs {
+					So(err.Status, ShouldEqual, 422)
+					pointers = append(pointers, err.Source.Pointer)
+				}
+
+				So(pointers, ShouldContain, "/data/attributes/name")
+				So(pointers, ShouldContain, "/data/attributes/age")
+				So(pointers, ShouldContain, "/data/attributes/role")
+				So(pointers, ShouldContain, "/data/attributes/tags")
+				So(pointers, ShouldContain, "/data/attributes/tags/1")
+				So(pointers, ShouldContain, "/data/attributes/extra")
+			})
+
+			Convey("should only validate provided attributes on partial updates", func() {
+				So(schema.ValidateAttributes([]byte(`{"age": 4}`), true), ShouldBeEmpty)
+
+				errs := schema.ValidateAttributes([]byte(`{"name": "B"}`), true)
+				So(len(errs), ShouldEqual, 2)
+			})
+		})
+
+		Convey("should validate POST and PATCH bodies", func() {
+			resource := NewMockResource(testResourceType, 1, testObjAttrs)
+			resource.AttributesSchema([]byte(testSchema))
+
+			api := New("")
+			api.Add(resource)
+
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			url := server.URL + "/" + testResourceType
+
+			resp, content := negotiationRequest("POST", url, jsh.ContentType, "", `{"data": {"type": "bars", "attributes": {"age": 2}}}`)
+			So(resp.StatusCode, ShouldEqual, 422)
+			So(content, ShouldContainSubstring, `"pointer": "/data/attributes/name"`)
+
+			resp, _ = negotiationRequest("POST", url, jsh.ContentType, "", `{"data": {"type": "bars", "attributes": {"name": "bob"}}}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusCreated)
+
+			resp, _ = negotiationRequest("PATCH", url+"/1", jsh.ContentType, "", `{"data": {"type": "bars", "id": "1", "attributes": {"age": 2}}}`)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		})
+	})
+}
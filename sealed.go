@@ -0,0 +1,198 @@
+package jshapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+SealCodec encrypts the values of sealed attributes before they reach storage, and
+decrypts them on their way out, see Resource.SealedAttributes. Values are sealed
+and opened as their JSON encoding, field naming the attribute as sent so that it
+can be bound to the ciphertext, such as additional data of an AEAD.
+*/
+type SealCodec interface {
+	Seal(field string, plaintext []byte) ([]byte, error)
+	Open(field string, sealed []byte) ([]byte, error)
+}
+
+// FailedOpenPolicy sets how a resource sends sealed attributes that fail to open
+type FailedOpenPolicy int
+
+const (
+	// RedactFailedOpens leaves the attributes that fail to open out of the objects
+	// sent, sending the rest
+	RedactFailedOpens FailedOpenPolicy = iota
+	// RejectFailedOpens answers the request with a 500
+	RejectFailedOpens
+)
+
+/*
+SealedAttributes seals the values of attributes of the resource type, named as
+sent, with codec:
+
+	users.SealedAttributes(vault, "ssn", "api-token")
+
+The attributes of the objects written by every write route, single, bulk,
+imported or atomic, are sealed once validated, before storage or the dry runner
+see them, and stored as base64 strings of the sealed values. They are opened
+wherever objects of the type are sent, unless the field policy or sparse
+fieldsets leave them out, in which case they are not opened at all. Attributes
+failing to open are redacted, see FailedOpens. Null values are left as they are.
+Requests filtering or sorting the resource on sealed attributes are answered
+with a 400, storage only holding their ciphertext. Panics when the codec is nil
+or no field is given.
+*/
+func (res *Resource) SealedAttributes(codec SealCodec, fields ...string) {
+	res.checkRegistration("sealed attributes")
+
+	if codec == nil || len(fields) == 0 {
+		panic(fmt.Sprintf("jshapi: sealed attributes of '%s' require a codec and fields", res.Type))
+	}
+
+	if res.sealed == nil {
+		res.sealed = map[string]SealCodec{}
+	}
+	for _, field := range fields {
+		res.sealed[field] = codec
+	}
+}
+
+// FailedOpens sets how the resource sends sealed attributes that fail to open,
+// RedactFailedOpens by default
+func (res *Resource) FailedOpens(policy FailedOpenPolicy) {
+	res.checkRegistration("a failed open policy")
+
+	res.failedOpens = policy
+}
+
+/*
+sealAttributes seals the sealed attributes of an incoming object of the resource,
+in place. Its attributes are named as stored by then, and converted to their sent
+names to find their codec.
+*/
+func (res *Resource) sealAttributes(object *jsh.Object) *jsh.Error {
+	if object == nil || res.sealed == nil {
+		return nil
+	}
+
+	casing := res.activeCasing()
+	attributes, err := rewriteAttributes(object.Attributes, func(name string, value json.RawMessage) (string, json.RawMessage, error) {
+		sent := name
+		if casing != nil {
+			sent = casing.encode(name)
+		}
+
+		codec := res.sealed[sent]
+		if codec == nil || isNull(value) {
+			return name, value, nil
+		}
+
+		sealed, err := codec.Seal(sent, value)
+		if err != nil {
+			return "", nil, fmt.Errorf("attribute '%s': %s", sent, err)
+		}
+		encoded, err := json.Marshal(base64.StdEncoding.EncodeToString(sealed))
+		return name, encoded, err
+	})
+	if err != nil {
+		return jsh.ISE(fmt.Sprintf("Unable to seal '%s' attributes: %s", object.Type, err))
+	}
+
+	object.Attributes = attributes
+	return nil
+}
+
+// openAttribute opens the stored value of a sealed attribute
+func openAttribute(codec SealCodec, field string, value json.RawMessage) (json.RawMessage, error) {
+	var encoded string
+	err := json.Unmarshal(value, &encoded)
+	if err != nil {
+		return nil, fmt.Errorf("expected a base64 string")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("expected a base64 string: %s", err)
+	}
+
+	opened, err := codec.Open(field, sealed)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(opened) {
+		return nil, fmt.Errorf("opened value is not valid JSON")
+	}
+
+	return opened, nil
+}
+
+// sentFields returns the fields of objects of type objectType the resource sends,
+// as allowed by its field policy and the sparse fieldset, nil when all are
+func (res *Resource) sentFields(ctx context.Context, objectType string, fieldset map[string]bool) map[string]bool {
+	if res.fieldPolicy == nil {
+		return fieldset
+	}
+
+	allowed := res.fieldPolicy(ctx, objectType)
+	if allowed == nil {
+		return fieldset
+	}
+
+	sent := map[string]bool{}
+	for _, field := range allowed {
+		if fieldset == nil || fieldset[field] {
+			sent[field] = true
+		}
+	}
+
+	return sent
+}
+
+// sealedQueryError rejects the requests filtering or sorting the resource on its
+// sealed attributes
+func (res *Resource) sealedQueryError(r *http.Request) *jsh.Error {
+	query := r.URL.Query()
+
+	for param := range query {
+		if !strings.HasPrefix(param, "filter[") {
+			continue
+		}
+
+		name := strings.TrimPrefix(param, "filter[")
+		end := strings.IndexByte(name, ']')
+		if end >= 0 {
+			name = name[:end]
+		}
+		if res.sealed[name] != nil {
+			return sealedParamError(param, name)
+		}
+	}
+
+	for _, sorts := range query["sort"] {
+		for _, field := range strings.Split(sorts, ",") {
+			name := strings.TrimPrefix(strings.TrimSpace(field), "-")
+			if res.sealed[name] != nil {
+				return sealedParamError("sort", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sealedParamError is the error of a query parameter naming a sealed attribute
+func sealedParamError(param string, name string) *jsh.Error {
+	return &jsh.Error{
+		Title:  "Bad Request",
+		Detail: fmt.Sprintf("Query parameter '%s' can't use attribute '%s', it is sealed", param, name),
+		Status: http.StatusBadRequest,
+	}
+}
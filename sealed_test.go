@@ -0,0 +1,156 @@
+package jshapi
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// prefixSeal seals values by prefixing them with the field name, failing to open
+// values sealed for another field
+type prefixSeal struct{}
+
+func (prefixSeal) Seal(field string, plaintext []byte) ([]byte, error) {
+	return append([]byte(field+":"), plaintext...), nil
+}
+
+func (prefixSeal) Open(field string, sealed []byte) ([]byte, error) {
+	if !bytes.HasPrefix(sealed, []byte(field+":")) {
+		return nil, errors.New("sealed for another field")
+	}
+	return sealed[len(field)+1:], nil
+}
+
+func TestSealedAttributes(t *testing.T) {
+
+	Convey("Sealed Attributes Tests", t, func() {
+
+		stored := map[string]*jsh.Object{}
+		users := NewResource("users")
+		users.Post(func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+			object.ID = "1"
+			stored[object.ID] = object
+			return object, nil
+		})
+		users.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return stored[id], nil
+		})
+		users.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+			return jsh.List{stored["1"]}, nil
+		})
+		users.Export(NDJSON())
+		users.SealedAttributes(prefixSeal{}, "ssn")
+
+		api := New("")
+		api.Add(users)
+
+		send := func(method string, url string, body string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			if body != "" {
+				request.Header.Set("Content-Type", jsh.ContentType)
+			}
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		recorder := send("POST", "/users", `{"data": {"type": "users", "attributes": {"name": "ann", "ssn": "123"}}}`)
+		So(recorder.Code, ShouldEqual, http.StatusCreated)
+
+		Convey("->SealedAttributes()", func() {
+
+			Convey("should store sealed values as base64 strings", func() {
+				So(string(stored["1"].Attributes), ShouldEqual, `{"name":"ann","ssn":"c3NuOiIxMjMi"}`)
+			})
+
+			Convey("should open sealed values when sending", func() {
+				So(recorder.Body.String(), ShouldContainSubstring, `"ssn": "123"`)
+				So(send("GET", "/users/1", "").Body.String(), ShouldContainSubstring, `"ssn": "123"`)
+			})
+
+			Convey("should redact values failing to open", func() {
+				stored["1"].Attributes = []byte(`{"name":"ann","ssn":"b3RoZXI6IjEyMyI="}`)
+
+				recorder := send("GET", "/users/1", "")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"name": "ann"`)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "ssn")
+			})
+
+			Convey("should not open values left out by the field policy", func() {
+				users.fieldPolicy = func(ctx context.Context, objectType string) []string {
+					return []string{"name"}
+				}
+				users.failedOpens = RejectFailedOpens
+				stored["1"].Attributes = []byte(`{"name":"ann","ssn":"not base64"}`)
+
+				recorder := send("GET", "/users/1", "")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"name": "ann"`)
+				So(recorder.Body.String(), ShouldNotContainSubstring, "ssn")
+			})
+
+			Convey("should reject filters and sorts on sealed attributes", func() {
+				So(send("GET", "/users?filter[ssn]=123", "").Code, ShouldEqual, http.StatusBadRequest)
+				So(send("GET", "/users?sort=name,-ssn", "").Code, ShouldEqual, http.StatusBadRequest)
+				So(send("GET", "/users?filter[name]=ann&sort=name", "").Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("should seal the objects of every write route", func() {
+				received := jsh.List{}
+				saveList := func(ctx context.Context, list jsh.List) (jsh.List, jsh.ErrorType) {
+					received = append(received, list...)
+					return list, nil
+				}
+
+				accounts := NewResource("accounts")
+				accounts.PostBulk(saveList)
+				accounts.PatchBulk(saveList)
+				accounts.Import(saveList)
+				accounts.SealedAttributes(prefixSeal{}, "ssn")
+				api.Add(accounts)
+
+				So(send("POST", "/accounts", `{"data": [{"type": "accounts", "attributes": {"ssn": "123-45"}}]}`).Code, ShouldEqual, http.StatusCreated)
+				So(send("PATCH", "/accounts", `{"data": [{"type": "accounts", "id": "1", "attributes": {"ssn": "123-45"}}]}`).Code, ShouldEqual, http.StatusOK)
+
+				request := httptest.NewRequest("POST", "/accounts/import", strings.NewReader(`{"type": "accounts", "attributes": {"ssn": "123-45"}}`))
+				request.Header.Set("Content-Type", ndjsonContentType)
+				api.ServeHTTP(httptest.NewRecorder(), request)
+
+				So(received, ShouldHaveLength, 3)
+				for _, object := range received {
+					So(string(object.Attributes), ShouldEqual, `{"ssn":"c3NuOiIxMjMtNDUi"}`)
+				}
+			})
+
+			Convey("should open sealed values of exports", func() {
+				recorder := send("GET", "/users/export", "")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"ssn":"123"`)
+
+				users.fieldPolicy = func(ctx context.Context, objectType string) []string {
+					return []string{"name"}
+				}
+				So(send("GET", "/users/export", "").Body.String(), ShouldNotContainSubstring, "ssn")
+			})
+		})
+
+		Convey("->FailedOpens()", func() {
+
+			Convey("should reject values failing to open when set", func() {
+				users.failedOpens = RejectFailedOpens
+				stored["1"].Attributes = []byte(`{"name":"ann","ssn":"not base64"}`)
+
+				So(send("GET", "/users/1", "").Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
@@ -1,7 +1,12 @@
 package jshapi
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 
 	"github.com/derekdowling/go-json-spec-handler"
 	"github.com/derekdowling/go-stdlogger"
@@ -16,18 +21,273 @@ type Sender func(context.Context, http.ResponseWriter, *http.Request, jsh.Sendab
 
 /*
 DefaultSender is the default sender that will log 5XX errors that it encounters
-in the process of sending a response.
+in the process of sending a response. Fully prepared *jsh.Document payloads are
+sent as is, which allows handlers to customize the response status. When the
+RequestID middleware is in use, the request id is set on every error object sent
+and prefixes the logged messages. A StorageTimeoutError is sent with its meta, and
+a MissingObjectError with its meta and about link. A RawDocument is written as is,
+and reads negotiated in simple JSON are flattened, see API.AllowSimpleJSON.
+Failures to write the response are logged along with the number of bytes written.
 */
 func DefaultSender(logger std.Logger) Sender {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, sendable jsh.Sendable) {
+		r = validationRequest(r)
+
+		requestID := GetRequestID(ctx)
+
+		var logPrefix string
+		if requestID != "" {
+			logPrefix = "[" + requestID + "] "
+		}
+
 		sendableError, isType := sendable.(jsh.ErrorType)
 		if isType && sendableError.StatusCode() >= 500 {
-			logger.Printf("Returning ISE: %s\n", sendableError.Error())
+			logger.Printf("%sReturning ISE: %s\n", logPrefix, sendableError.Error())
+		}
+
+		api := sendingAPI(ctx)
+
+		// jsh ignores write errors, record them to report truncated responses
+		writer := &sendWriter{ResponseWriter: w}
+		w = writer
+
+		members := map[string]interface{}{}
+		if requestID != "" {
+			members["id"] = requestID
+		}
+
+		// jsh.Error has no meta member, which storage timeouts are sent with
+		timeoutErr, isTimeout := sendable.(*StorageTimeoutError)
+		if isTimeout {
+			members["meta"] = timeoutErr.meta()
+			sendable = timeoutErr.jshError()
+		}
+
+		// customized 404s are sent with their meta and links members as well
+		missingErr, isMissing := sendable.(*MissingObjectError)
+		if isMissing {
+			for name, value := range missingErr.members() {
+				members[name] = value
+			}
+			sendable = missingErr.jshError()
+		}
+
+		sendable = translateErrors(ctx, w, sendable)
+
+		var sendError *jsh.Error
+		document, isDocument := sendable.(*jsh.Document)
+		raw, isRaw := sendable.(*RawDocument)
+		switch {
+		case isRaw && raw.Validate(r, true) == nil:
+			sendRaw(w, raw)
+		case isSimpleJSON(ctx):
+			if !isDocument {
+				document = buildDocument(r, sendable)
+			}
+			sendError = sendSimpleJSON(w, r, document)
+		case len(members) > 0:
+			if !isDocument {
+				document = buildDocument(r, sendable)
+			}
+			sendError = sendWithErrorMembers(w, r, document, api, members)
+		case isDocument:
+			sendError = sendDocument(w, r, document, api)
+		default:
+			sendError = sendDocument(w, r, buildDocument(r, sendable), api)
 		}
 
-		sendError := jsh.Send(w, r, sendable)
 		if sendError != nil && sendError.Status >= 500 {
-			logger.Printf("Error sending response: %s\n", sendError.Error())
+			logger.Printf("%sError sending response: %s\n", logPrefix, sendError.Error())
+		}
+
+		if writer.err != nil {
+			logger.Printf(
+				"%sError writing response after %d bytes: %s\n",
+				logPrefix, writer.bytes, writer.err.Error(),
+			)
 		}
 	}
 }
+
+// sendingAPI returns the API of the resource sending a response, which is that of
+// the route handling the request unless another resource sends it, see deliver
+func sendingAPI(ctx context.Context) *API {
+	api, isSet := ctx.Value(sendingAPIKey).(*API)
+	if isSet {
+		return api
+	}
+
+	meta := routeFromContext(ctx)
+	if meta == nil {
+		return nil
+	}
+
+	return meta.res.api
+}
+
+// validationRequest is the request responses are validated against: jsh validates
+// responses against the request method and rejects HEAD, which is answered with
+// the headers of the GET response it mirrors
+func validationRequest(r *http.Request) *http.Request {
+	if r.Method != "HEAD" {
+		return r
+	}
+
+	get := *r
+	get.Method = "GET"
+	return &get
+}
+
+// buildDocument prepares a sendable payload the way jsh.Send does
+func buildDocument(r *http.Request, sendable jsh.Sendable) *jsh.Document {
+	validationErr := sendable.Validate(r, true)
+	if validationErr != nil {
+		return jsh.Build(validationErr)
+	}
+
+	return jsh.Build(sendable)
+}
+
+/*
+maxPooledBuffer is the capacity above which buffers are not returned to the pool,
+so that a few very large responses do not pin memory. Pooled buffers are otherwise
+dropped by the garbage collector once unused for a while.
+*/
+const maxPooledBuffer = 4 << 20
+
+// bufferPool holds the buffers responses are serialized to
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &bytes.Buffer{}
+	},
+}
+
+/*
+sendDocument sends a document exactly as jsh.SendDocument does, serializing it in a
+single pass: the document is encoded once to a pooled buffer, see encodeDocument,
+then indented to a second one which is written. The top-level link of the document,
+if any, is sent as the self link of the links object, and relationship linkage is
+shaped after the relationships registered on api.
+*/
+func sendDocument(w http.ResponseWriter, r *http.Request, document *jsh.Document, api *API) *jsh.Error {
+	validationErr := document.Validate(r, true)
+	if validationErr != nil {
+		prepErr := validationErr.Validate(r, true)
+		if prepErr != nil {
+			http.Error(w, jsh.DefaultErrorTitle, http.StatusInternalServerError)
+			return prepErr
+		}
+
+		document = jsh.Build(validationErr)
+	}
+
+	compact := bufferPool.Get().(*bytes.Buffer)
+	defer releaseBuffer(compact)
+
+	err := encodeDocument(compact, document, api)
+	if err == nil {
+		err = writeJSON(w, jsh.ContentType, document.Status, compact.Bytes())
+	}
+	if err != nil {
+		http.Error(w, jsh.DefaultErrorTitle, http.StatusInternalServerError)
+		return jsh.ISE(fmt.Sprintf("Unable to marshal JSON payload: %s", err.Error()))
+	}
+
+	return validationErr
+}
+
+/*
+writeJSON indents compact JSON content to a pooled buffer, then writes it with
+status and contentType. Nothing is written when content can't be indented, which
+leaves the response to the caller.
+*/
+func writeJSON(w http.ResponseWriter, contentType string, status int, content []byte) error {
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	defer releaseBuffer(buffer)
+
+	// json.Indent matches the output of the json.MarshalIndent call of jsh
+	err := json.Indent(buffer, content, "", " ")
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(buffer.Len()))
+	w.WriteHeader(status)
+	w.Write(buffer.Bytes())
+
+	return nil
+}
+
+// releaseBuffer returns a buffer to the pool unless it grew too large
+func releaseBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() > maxPooledBuffer {
+		return
+	}
+
+	buffer.Reset()
+	bufferPool.Put(buffer)
+}
+
+/*
+sendErrorWithMeta sends an error carrying a meta member, along with the request id
+if any. jsh.Error has no meta member, so these are sent directly rather than
+through SendHandler.
+*/
+func sendErrorWithMeta(ctx context.Context, w http.ResponseWriter, r *http.Request, err *jsh.Error, meta map[string]interface{}) {
+	members := map[string]interface{}{"meta": meta}
+
+	requestID := GetRequestID(ctx)
+	if requestID != "" {
+		members["id"] = requestID
+	}
+
+	sendWithErrorMembers(w, r, jsh.Build(err), nil, members)
+}
+
+// metaDocument is a meta-only document, with optional links, which jsh.Document
+// can't represent as it always holds either data or errors
+type metaDocument struct {
+	Links   map[string]*jsh.Link   `json:"links,omitempty"`
+	Meta    map[string]interface{} `json:"meta"`
+	JSONAPI struct {
+		Version string `json:"version"`
+	} `json:"jsonapi"`
+}
+
+// sendMeta sends a 200 meta-only document
+func sendMeta(w http.ResponseWriter, meta map[string]interface{}) {
+	sendMetaLinks(w, meta, nil)
+}
+
+// sendMetaLinks sends a 200 document holding meta and links only
+func sendMetaLinks(w http.ResponseWriter, meta map[string]interface{}, links map[string]*jsh.Link) {
+	document := metaDocument{Links: links, Meta: meta}
+	document.JSONAPI.Version = jsh.JSONAPIVersion
+
+	content, err := json.Marshal(&document)
+	if err == nil {
+		err = writeJSON(w, jsh.ContentType, http.StatusOK, content)
+	}
+	if err != nil {
+		http.Error(w, jsh.DefaultErrorTitle, http.StatusInternalServerError)
+	}
+}
+
+// sendWriter records the first write error and the number of bytes written
+type sendWriter struct {
+	http.ResponseWriter
+	bytes int
+	err   error
+}
+
+func (w *sendWriter) Write(content []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(content)
+	w.bytes += n
+
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+
+	return n, err
+}
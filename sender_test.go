@@ -0,0 +1,70 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSendDocument(t *testing.T) {
+
+	Convey("Send Document Tests", t, func() {
+
+		object, _ := jsh.NewObject("1", "users", map[string]string{"name": "<jo> & co"})
+		object.Status = http.StatusOK
+		object.Links = map[string]*jsh.Link{"self": {HREF: "/users/1"}}
+		object.Relationships = map[string]*jsh.Relationship{
+			"posts": {Data: jsh.ResourceLinkage{{Type: "posts", ID: "2"}}},
+		}
+
+		documents := map[string]*jsh.Document{
+			"object": jsh.Build(object),
+			"list":   jsh.Build(jsh.List{object, object}),
+			"empty":  jsh.Build(jsh.List{}),
+			"error":  jsh.Build(jsh.NotFound("users", "1")),
+		}
+		withMeta := jsh.Build(object)
+		withMeta.Meta = map[string]interface{}{"total": 1}
+		withMeta.Included = jsh.List{object}
+		documents["meta"] = withMeta
+
+		Convey("should send documents exactly as jsh marshals them", func() {
+			for name, document := range documents {
+				recorder := httptest.NewRecorder()
+				sendDocument(recorder, httptest.NewRequest("GET", "/users", nil), document, nil)
+
+				expected, err := json.MarshalIndent(document, "", " ")
+				So(err, ShouldBeNil)
+				So(name+": "+recorder.Body.String(), ShouldEqual, name+": "+string(expected))
+				So(recorder.Header().Get("Content-Length"), ShouldEqual, strconv.Itoa(len(expected)))
+			}
+		})
+
+		Convey("should send the top-level link as the self link", func() {
+			document := jsh.Build(object)
+			document.Links = &jsh.Link{HREF: "/users/1"}
+
+			recorder := httptest.NewRecorder()
+			sendDocument(recorder, httptest.NewRequest("GET", "/users/1", nil), document, nil)
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"self": {`)
+		})
+
+		Convey("should only pool buffers up to the maximum size", func() {
+			small := bytes.NewBufferString("small")
+			releaseBuffer(small)
+			So(small.Len(), ShouldEqual, 0)
+
+			large := bytes.NewBuffer(make([]byte, 1, maxPooledBuffer+1))
+			releaseBuffer(large)
+			So(large.Len(), ShouldEqual, 1)
+		})
+	})
+}
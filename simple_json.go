@@ -0,0 +1,151 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// simpleJSONContentType is the Content-Type of responses in simple JSON
+const simpleJSONContentType = "application/json"
+
+/*
+AllowSimpleJSON answers the reads of clients that can't handle the JSON API
+envelope, accepting `application/json` but not the JSON API media type, in simple
+JSON: objects are flattened to their id and attributes, lists to arrays of them,
+and errors to `{"errors": [{"status": 404, "detail": "..."}]}`:
+
+	api.AllowSimpleJSON(true)
+
+Links, relationships, included objects and meta are left out. Writes are still
+answered in the JSON API format, and their bodies must still be JSON API
+documents. Responses then vary by Accept, and custom senders must handle simple
+JSON themselves.
+*/
+func (a *API) AllowSimpleJSON(enabled bool) {
+	a.checkRegistration("simple JSON")
+
+	a.simpleJSON = enabled
+}
+
+// withSimpleJSON records that a read is answered in simple JSON when negotiated
+func (a *API) withSimpleJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+	if !a.simpleJSON {
+		return ctx
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	if r.Method != get && r.Method != "HEAD" || !acceptsSimpleJSON(r.Header.Get("Accept")) {
+		return ctx
+	}
+
+	return context.WithValue(ctx, simpleJSONKey, true)
+}
+
+// acceptsSimpleJSON reports whether an Accept header lists application/json and
+// not the JSON API media type
+func acceptsSimpleJSON(accept string) bool {
+	simple := false
+	for _, mediaRange := range strings.Split(accept, ",") {
+		name, _, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		switch name {
+		case jsh.ContentType:
+			return false
+		case simpleJSONContentType:
+			simple = true
+		}
+	}
+
+	return simple
+}
+
+// isSimpleJSON reports whether the request is answered in simple JSON
+func isSimpleJSON(ctx context.Context) bool {
+	simple, _ := ctx.Value(simpleJSONKey).(bool)
+	return simple
+}
+
+// simpleError is an error object in simple JSON
+type simpleError struct {
+	Status int    `json:"status"`
+	Title  string `json:"title,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// sendSimpleJSON sends a document flattened to simple JSON
+func sendSimpleJSON(w http.ResponseWriter, r *http.Request, document *jsh.Document) *jsh.Error {
+	validationErr := document.Validate(r, true)
+	if validationErr != nil {
+		document = jsh.Build(validationErr)
+	}
+
+	var flattened interface{}
+	var flattenErr *jsh.Error
+	switch {
+	case document.HasErrors():
+		errors := []simpleError{}
+		for _, err := range document.Errors {
+			errors = append(errors, simpleError{Status: err.Status, Title: err.Title, Detail: err.Detail})
+		}
+		flattened = map[string]interface{}{"errors": errors}
+	case document.Mode == jsh.ObjectMode && len(document.Data) == 0:
+		flattened = nil
+	case document.Mode == jsh.ObjectMode:
+		flattened, flattenErr = flattenObject(document.Data[0])
+	default:
+		list := []map[string]interface{}{}
+		for _, object := range document.Data {
+			var item map[string]interface{}
+			item, flattenErr = flattenObject(object)
+			if flattenErr != nil {
+				break
+			}
+			list = append(list, item)
+		}
+		flattened = list
+	}
+
+	if flattenErr != nil {
+		http.Error(w, jsh.DefaultErrorTitle, http.StatusInternalServerError)
+		return flattenErr
+	}
+
+	content, err := json.Marshal(flattened)
+	if err == nil {
+		err = writeJSON(w, simpleJSONContentType, document.Status, content)
+	}
+	if err != nil {
+		http.Error(w, jsh.DefaultErrorTitle, http.StatusInternalServerError)
+		return jsh.ISE(fmt.Sprintf("Unable to marshal JSON payload: %s", err.Error()))
+	}
+
+	return validationErr
+}
+
+// flattenObject returns the attributes of an object along with its id
+func flattenObject(object *jsh.Object) (map[string]interface{}, *jsh.Error) {
+	flattened := map[string]interface{}{}
+	if len(object.Attributes) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(object.Attributes))
+		decoder.UseNumber()
+		err := decoder.Decode(&flattened)
+		if err != nil {
+			return nil, jsh.ISE(fmt.Sprintf("Unable to flatten the attributes of '%s': %s", object.Type, err.Error()))
+		}
+	}
+	flattened["id"] = object.ID
+
+	return flattened, nil
+}
@@ -0,0 +1,92 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSimpleJSON(t *testing.T) {
+
+	Convey("Simple JSON Tests", t, func() {
+
+		api := New("")
+		api.AllowSimpleJSON(true)
+		api.Add(NewMockResource(testResourceType, 2, testObjAttrs))
+
+		missing := NewResource("missing")
+		missing.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return nil, jsh.NotFound("missing", id)
+		})
+		api.Add(missing)
+
+		send := func(method string, url string, accept string, body string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Accept", accept)
+			if body != "" {
+				request.Header.Set("Content-Type", jsh.ContentType)
+			}
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("->AllowSimpleJSON()", func() {
+
+			Convey("should flatten objects and lists", func() {
+				recorder := send("GET", "/bars/1", "application/json", "")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Header().Get("Content-Type"), ShouldEqual, "application/json")
+				So(recorder.Header().Get("Vary"), ShouldEqual, "Accept")
+				So(recorder.Body.String(), ShouldEqual, "{\n \"foo\": \"bar\",\n \"id\": \"1\"\n}")
+
+				recorder = send("GET", "/bars", "application/json", "")
+				list := []map[string]string{}
+				So(json.Unmarshal(recorder.Body.Bytes(), &list), ShouldBeNil)
+				So(list, ShouldResemble, []map[string]string{
+					{"foo": "bar", "id": "1"},
+					{"foo": "bar", "id": "2"},
+				})
+			})
+
+			Convey("should flatten errors", func() {
+				recorder := send("GET", "/missing/1", "application/json", "")
+				So(recorder.Code, ShouldEqual, http.StatusNotFound)
+				So(recorder.Header().Get("Content-Type"), ShouldEqual, "application/json")
+				So(recorder.Body.String(), ShouldStartWith, "{\n \"errors\": [\n  {\n   \"status\": 404,")
+			})
+
+			Convey("should leave JSON API clients untouched", func() {
+				recorder := send("GET", "/bars/1", "application/json, "+jsh.ContentType, "")
+				So(recorder.Header().Get("Content-Type"), ShouldEqual, jsh.ContentType)
+				So(recorder.Header().Get("Vary"), ShouldEqual, "Accept")
+				So(recorder.Body.String(), ShouldContainSubstring, `"data": {`)
+			})
+
+			Convey("should answer writes in the JSON API format", func() {
+				recorder := send("PATCH", "/bars/1", "application/json", `{"data": {"type": "bars", "id": "1", "attributes": {"foo": "baz"}}}`)
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Header().Get("Content-Type"), ShouldEqual, jsh.ContentType)
+			})
+		})
+
+		Convey("should be disabled by default", func() {
+			plain := New("")
+			plain.Add(NewMockResource(testResourceType, 1, testObjAttrs))
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest("GET", "/bars/1", nil)
+			request.Header.Set("Accept", "application/json")
+			plain.ServeHTTP(recorder, request)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, jsh.ContentType)
+			So(recorder.Header().Get("Vary"), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,511 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/go-stdlogger"
+)
+
+// DefaultSpecMaxBodyBytes is the largest body validated by ValidateAgainstSpec
+// when SpecOptions.MaxBodyBytes is not set
+const DefaultSpecMaxBodyBytes = 1 << 20
+
+// SpecOptions configures ValidateAgainstSpec
+type SpecOptions struct {
+	// Fail answers mismatching requests with a 400 and mismatching responses with
+	// a 500 listing the mismatches, they are only logged otherwise. Responses are
+	// then buffered until validated.
+	Fail bool
+	// Logger defaults to logging to stderr
+	Logger std.Logger
+	// MaxBodyBytes bounds the bodies validated, larger ones are let through
+	// unchecked, defaults to DefaultSpecMaxBodyBytes
+	MaxBodyBytes int64
+}
+
+/*
+ValidateAgainstSpec returns a middleware validating requests and responses against
+spec, a schema document built by API.Schema, to catch drift between the document
+and what the API actually serves:
+
+	spec, _ := api.Schema()
+	api.UseC(jshapi.ValidateAgainstSpec(spec, jshapi.SpecOptions{}))
+
+Successful responses must be served by a route of the spec. The resource objects
+of request and response documents must be of a type of the spec, their attributes
+and relationships must match those it describes, and clients must only write the
+mutable ones. Mismatches are logged, and answered with an error when Fail is set.
+
+Only bodies up to MaxBodyBytes are validated, responses flushed before their end,
+such as exports and event streams, are let through unchecked. Panics when spec
+isn't a schema document.
+*/
+func ValidateAgainstSpec(spec []byte, opts SpecOptions) func(goji.Handler) goji.Handler {
+	schema := APISchema{}
+	err := json.Unmarshal(spec, &schema)
+	if err != nil {
+		panic(fmt.Sprintf("jshapi: invalid spec: %s", err))
+	}
+	if schema.Version != SchemaVersion {
+		panic(fmt.Sprintf("jshapi: unsupported spec version '%s', expected '%s'", schema.Version, SchemaVersion))
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = log.New(os.Stderr, "jshapi: ", log.LstdFlags)
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultSpecMaxBodyBytes
+	}
+
+	validator := newSpecValidator(schema, opts)
+	return validator.middleware
+}
+
+// specValidator validates requests and responses against a schema document
+type specValidator struct {
+	opts   SpecOptions
+	routes []specRoute
+	// resources maps resource types to their attributes and relationships,
+	// attributes being nil for resources that don't describe them
+	resources map[string]*specResource
+}
+
+// specRoute is a route of the spec, its pattern split into path segments
+type specRoute struct {
+	method       string
+	segments     []string
+	resourceType string
+}
+
+// specResource holds the members of a resource type of the spec by name
+type specResource struct {
+	attributes    map[string]AttributeSchema
+	relationships map[string]RelationshipSchema
+}
+
+// specMismatch is a difference between a document and the spec, at a JSON
+// pointer of the document
+type specMismatch struct {
+	pointer string
+	detail  string
+}
+
+func newSpecValidator(schema APISchema, opts SpecOptions) *specValidator {
+	v := &specValidator{opts: opts, resources: map[string]*specResource{}}
+
+	for _, route := range schema.Routes {
+		v.routes = append(v.routes, specRoute{method: route.Method, segments: strings.Split(route.Pattern, "/")})
+	}
+
+	for _, resource := range schema.Resources {
+		described := &specResource{relationships: map[string]RelationshipSchema{}}
+		if resource.Attributes != nil {
+			described.attributes = map[string]AttributeSchema{}
+			for _, attribute := range resource.Attributes {
+				described.attributes[attribute.Name] = attribute
+			}
+		}
+		for _, relationship := range resource.Relationships {
+			described.relationships[relationship.Name] = relationship
+		}
+		v.resources[resource.Type] = described
+
+		for _, route := range resource.Routes {
+			v.routes = append(v.routes, specRoute{
+				method:       route.Method,
+				segments:     strings.Split(route.Pattern, "/"),
+				resourceType: resource.Type,
+			})
+		}
+	}
+
+	return v
+}
+
+// middleware validates the requests handled by next and their responses
+func (v *specValidator) middleware(next goji.Handler) goji.Handler {
+	return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		route := v.route(r)
+
+		var mismatches []specMismatch
+		if route != nil && route.resourceType != "" && (r.Method == post || r.Method == patch) {
+			mismatches = v.requestMismatches(r)
+		}
+		if len(mismatches) > 0 {
+			v.report(ctx, r, "request", mismatches)
+			if v.opts.Fail {
+				SendHandler(ctx, w, r, specErrors(mismatches, http.StatusBadRequest))
+				return
+			}
+		}
+
+		writer := &specWriter{ResponseWriter: w, buffered: v.opts.Fail, maxBytes: v.opts.MaxBodyBytes}
+		next.ServeHTTPC(ctx, writer, r)
+
+		mismatches = nil
+		if route == nil && writer.statusCode() < 400 {
+			mismatches = append(mismatches, specMismatch{detail: fmt.Sprintf("Route %s %s is not in the spec", r.Method, r.URL.Path)})
+		}
+		if !writer.skipped && strings.HasPrefix(writer.Header().Get("Content-Type"), jsh.ContentType) {
+			mismatches = append(mismatches, v.documentMismatches(writer.body.Bytes(), false)...)
+		}
+
+		if len(mismatches) > 0 {
+			v.report(ctx, r, "response", mismatches)
+			if v.opts.Fail && !writer.released {
+				SendHandler(ctx, w, r, specErrors(mismatches, http.StatusInternalServerError))
+				return
+			}
+		}
+
+		writer.release()
+	})
+}
+
+// route returns the route of the spec matching the request, if any
+func (v *specValidator) route(r *http.Request) *specRoute {
+	method := r.Method
+	if method == "HEAD" {
+		method = get
+	}
+
+	segments := strings.Split(r.URL.Path, "/")
+	for index := range v.routes {
+		route := &v.routes[index]
+		if route.method == method && matchSegments(route.segments, segments) {
+			return route
+		}
+	}
+
+	return nil
+}
+
+// matchSegments matches the segments of a path against those of a pattern, where
+// ":name" matches any segment and a final "*" any remaining path
+func matchSegments(pattern []string, path []string) bool {
+	for index, segment := range pattern {
+		if segment == "*" && index == len(pattern)-1 {
+			return true
+		}
+		if index >= len(path) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if path[index] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != path[index] {
+			return false
+		}
+	}
+
+	return len(pattern) == len(path)
+}
+
+// requestMismatches validates the body of a request, restoring it for the next
+// handlers
+func (v *specValidator) requestMismatches(r *http.Request) []specMismatch {
+	if r.Body == nil || r.ContentLength > v.opts.MaxBodyBytes {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, v.opts.MaxBodyBytes+1))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || int64(len(body)) > v.opts.MaxBodyBytes {
+		return nil
+	}
+
+	return v.documentMismatches(body, true)
+}
+
+// readCloser reads a restored body, closing the original one
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// documentMismatches validates the resource objects of a document, written by a
+// client when written is set, leaving invalid documents to the handlers
+func (v *specValidator) documentMismatches(content []byte, written bool) []specMismatch {
+	document := map[string]json.RawMessage{}
+	if json.Unmarshal(content, &document) != nil {
+		return nil
+	}
+
+	var mismatches []specMismatch
+	for _, member := range []string{"data", "included"} {
+		raw := bytes.TrimSpace(document[member])
+		switch {
+		case len(raw) == 0:
+		case raw[0] == '[':
+			objects := []json.RawMessage{}
+			json.Unmarshal(raw, &objects)
+			for index, object := range objects {
+				pointer := "/" + member + "/" + strconv.Itoa(index)
+				mismatches = append(mismatches, v.objectMismatches(object, pointer, written)...)
+			}
+		case raw[0] == '{':
+			mismatches = append(mismatches, v.objectMismatches(raw, "/"+member, written)...)
+		}
+	}
+
+	return mismatches
+}
+
+// specObject is the part of a resource object validated against the spec
+type specObject struct {
+	Type          string                     `json:"type"`
+	Attributes    map[string]json.RawMessage `json:"attributes"`
+	Relationships map[string]struct {
+		Data json.RawMessage `json:"data"`
+	} `json:"relationships"`
+}
+
+// objectMismatches validates a resource object or identifier at pointer
+func (v *specValidator) objectMismatches(raw json.RawMessage, pointer string, written bool) []specMismatch {
+	object := specObject{}
+	if json.Unmarshal(raw, &object) != nil || object.Type == "" {
+		return nil
+	}
+
+	resource := v.resources[object.Type]
+	if resource == nil {
+		return []specMismatch{{pointer + "/type", fmt.Sprintf("Type '%s' is not in the spec", object.Type)}}
+	}
+
+	var mismatches []specMismatch
+	if resource.attributes != nil {
+		for _, name := range sortedMembers(object.Attributes) {
+			attributePointer := pointer + "/attributes/" + escapePointer(name)
+			attribute, described := resource.attributes[name]
+
+			switch {
+			case !described:
+				mismatches = append(mismatches, specMismatch{attributePointer, fmt.Sprintf("Attribute '%s' of '%s' is not in the spec", name, object.Type)})
+			case written && attribute.ReadOnly:
+				mismatches = append(mismatches, specMismatch{attributePointer, fmt.Sprintf("Attribute '%s' of '%s' is read-only in the spec", name, object.Type)})
+			case !matchesJSONType(attribute.Type, object.Attributes[name]):
+				mismatches = append(mismatches, specMismatch{attributePointer, fmt.Sprintf("Attribute '%s' of '%s' must be of type %s", name, object.Type, attribute.Type)})
+			}
+		}
+	}
+
+	names := make([]string, 0, len(object.Relationships))
+	for name := range object.Relationships {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		relationship, described := resource.relationships[name]
+		if !described {
+			continue
+		}
+
+		relationshipPointer := pointer + "/relationships/" + escapePointer(name)
+		if written && !relationship.Mutable {
+			mismatches = append(mismatches, specMismatch{relationshipPointer, fmt.Sprintf("Relationship '%s' of '%s' is immutable in the spec", name, object.Type)})
+			continue
+		}
+		mismatches = append(mismatches, linkageMismatches(relationship, object.Relationships[name].Data, relationshipPointer+"/data")...)
+	}
+
+	return mismatches
+}
+
+// sortedMembers returns the names of the members of an object, sorted
+func sortedMembers(members map[string]json.RawMessage) []string {
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// linkageMismatches validates the linkage of a relationship against its
+// cardinality and related type
+func linkageMismatches(relationship RelationshipSchema, data json.RawMessage, pointer string) []specMismatch {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+
+	identifiers := []jsh.ResourceIdentifier{}
+	switch {
+	case data[0] == '[' && relationship.Cardinality == CardinalityToMany:
+		json.Unmarshal(data, &identifiers)
+	case data[0] == '{' && relationship.Cardinality == CardinalityToOne:
+		identifier := jsh.ResourceIdentifier{}
+		json.Unmarshal(data, &identifier)
+		identifiers = append(identifiers, identifier)
+	case string(data) == "null" && relationship.Cardinality == CardinalityToOne:
+	default:
+		return []specMismatch{{pointer, fmt.Sprintf("Relationship '%s' is %s in the spec", relationship.Name, relationship.Cardinality)}}
+	}
+
+	if relationship.RelatedType == "" {
+		return nil
+	}
+
+	var mismatches []specMismatch
+	for _, identifier := range identifiers {
+		if identifier.Type != relationship.RelatedType {
+			detail := fmt.Sprintf("Relationship '%s' links objects of type '%s' in the spec", relationship.Name, relationship.RelatedType)
+			mismatches = append(mismatches, specMismatch{pointer, detail})
+		}
+	}
+
+	return mismatches
+}
+
+// matchesJSONType reports whether a JSON value is of a type of AttributeSchema,
+// null and unknown types matching any value
+func matchesJSONType(jsonType string, value json.RawMessage) bool {
+	value = bytes.TrimSpace(value)
+	if jsonType == "" || len(value) == 0 || string(value) == "null" {
+		return true
+	}
+
+	switch value[0] {
+	case '"':
+		return jsonType == "string"
+	case '{':
+		return jsonType == "object"
+	case '[':
+		return jsonType == "array"
+	case 't', 'f':
+		return jsonType == "boolean"
+	}
+
+	return jsonType == "number"
+}
+
+// report logs the mismatches of a request or response
+func (v *specValidator) report(ctx context.Context, r *http.Request, part string, mismatches []specMismatch) {
+	var logPrefix string
+	if requestID := GetRequestID(ctx); requestID != "" {
+		logPrefix = "[" + requestID + "] "
+	}
+
+	for _, mismatch := range mismatches {
+		location := ""
+		if mismatch.pointer != "" {
+			location = " at " + mismatch.pointer
+		}
+		v.opts.Logger.Printf("%sSpec mismatch of the %s to %s %s%s: %s\n", logPrefix, part, r.Method, r.URL.Path, location, mismatch.detail)
+	}
+}
+
+// specErrors builds the errors answering mismatches when failing on them
+func specErrors(mismatches []specMismatch, status int) jsh.ErrorList {
+	errs := jsh.ErrorList{}
+	for _, mismatch := range mismatches {
+		err := &jsh.Error{Title: "Spec Mismatch", Detail: mismatch.detail, Status: status}
+		if status < 500 {
+			err.Source.Pointer = mismatch.pointer
+		}
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+/*
+specWriter records a response for validation, up to maxBytes. Buffered responses are
+held back until released, the others written through as they are recorded. A
+response flushed or grown past maxBytes is skipped: it is written out and let
+through from then on.
+*/
+type specWriter struct {
+	http.ResponseWriter
+	buffered bool
+	maxBytes int64
+	status   int
+	body     bytes.Buffer
+	skipped  bool
+	// released is set once held back content is written out
+	released bool
+}
+
+func (w *specWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+
+	w.status = status
+	if !w.buffered {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *specWriter) Write(content []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+
+	if !w.skipped && int64(w.body.Len()+len(content)) > w.maxBytes {
+		w.skip()
+	}
+	if w.skipped {
+		return w.ResponseWriter.Write(content)
+	}
+
+	w.body.Write(content)
+	if w.buffered {
+		return len(content), nil
+	}
+
+	return w.ResponseWriter.Write(content)
+}
+
+// Flush skips the validation of streamed responses
+func (w *specWriter) Flush() {
+	w.skip()
+
+	flusher, canFlush := w.ResponseWriter.(http.Flusher)
+	if canFlush {
+		flusher.Flush()
+	}
+}
+
+// skip stops recording the response, writing out what was held back
+func (w *specWriter) skip() {
+	w.skipped = true
+	w.release()
+}
+
+// release writes out the response held back, if any
+func (w *specWriter) release() {
+	if !w.buffered || w.released {
+		return
+	}
+
+	w.released = true
+	w.ResponseWriter.WriteHeader(w.statusCode())
+	w.ResponseWriter.Write(w.body.Bytes())
+}
+
+// statusCode returns the status of the response, 200 when none was written
+func (w *specWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
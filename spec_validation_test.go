@@ -0,0 +1,152 @@
+package jshapi
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidateAgainstSpec(t *testing.T) {
+
+	Convey("Spec Validation Tests", t, func() {
+
+		attributes := `{"title": "Hello", "views": 3}`
+		linkage := `{"type": "people", "id": "9"}`
+		post := func(id string) *jsh.Object {
+			object, _ := jsh.NewObject(id, "posts", nil)
+			object.Attributes = []byte(attributes)
+			object.Relationships = map[string]*jsh.Relationship{}
+			return object
+		}
+		store := func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+			return post("1"), nil
+		}
+
+		posts := NewResource("posts")
+		posts.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			object := post(id)
+			if linkage != "" {
+				object.Relationships["author"] = &jsh.Relationship{}
+				object.Relationships["author"].Data.UnmarshalJSON([]byte(linkage))
+			}
+			return object, nil
+		})
+		posts.Post(store)
+		posts.ToOneExact("author", func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			return nil, nil
+		})
+		posts.DescribeWith(describer{
+			attributes: []AttributeSchema{
+				{Name: "title", Type: "string"},
+				{Name: "views", Type: "number", ReadOnly: true},
+			},
+			relationships: []RelationshipSchema{
+				{Name: "author", RelatedType: "people", Cardinality: CardinalityToOne, Mutable: true},
+			},
+		})
+
+		api := New("")
+		api.Add(posts)
+		spec, _ := api.Schema()
+
+		logged := &bytes.Buffer{}
+		opts := SpecOptions{Logger: log.New(logged, "", 0)}
+
+		serve := func(method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			if body != "" {
+				request.Header.Set("Content-Type", jsh.ContentType)
+			}
+			ValidateAgainstSpec(spec, opts)(api).ServeHTTPC(context.Background(), recorder, request)
+			return recorder
+		}
+
+		Convey("should let matching requests and responses through", func() {
+			So(serve("GET", "/posts/1", "").Code, ShouldEqual, http.StatusOK)
+			So(serve("POST", "/posts", `{"data": {"type": "posts", "attributes": {"title": "Hi"}}}`).Code, ShouldEqual, http.StatusCreated)
+			So(logged.String(), ShouldEqual, "")
+		})
+
+		Convey("should log mismatching responses", func() {
+			attributes = `{"title": 1, "draft": true}`
+			linkage = `{"type": "posts", "id": "2"}`
+
+			recorder := serve("GET", "/posts/1", "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(logged.String(), ShouldEqual, strings.Join([]string{
+				"Spec mismatch of the response to GET /posts/1 at /data/attributes/draft: Attribute 'draft' of 'posts' is not in the spec",
+				"Spec mismatch of the response to GET /posts/1 at /data/attributes/title: Attribute 'title' of 'posts' must be of type string",
+				"Spec mismatch of the response to GET /posts/1 at /data/relationships/author/data: Relationship 'author' links objects of type 'people' in the spec",
+				"",
+			}, "\n"))
+		})
+
+		Convey("should log mismatching requests", func() {
+			recorder := serve("POST", "/posts", `{"data": {"type": "posts", "attributes": {"views": 1}}}`)
+
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+			So(logged.String(), ShouldContainSubstring, "at /data/attributes/views: Attribute 'views' of 'posts' is read-only in the spec")
+		})
+
+		Convey("should log successful routes missing from the spec", func() {
+			api.SchemaEndpoint("_schema")
+
+			So(serve("GET", "/_schema", "").Code, ShouldEqual, http.StatusOK)
+			So(logged.String(), ShouldContainSubstring, "Route GET /_schema is not in the spec")
+
+			logged.Reset()
+			serve("GET", "/comments", "")
+			So(logged.String(), ShouldEqual, "")
+		})
+
+		Convey("when failing on mismatches", func() {
+			opts.Fail = true
+
+			Convey("should reject mismatching requests", func() {
+				recorder := serve("POST", "/posts", `{"data": {"type": "posts", "attributes": {"views": 1}}}`)
+
+				So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+				So(recorder.Body.String(), ShouldContainSubstring, `"pointer": "/data/attributes/views"`)
+			})
+
+			Convey("should replace mismatching responses", func() {
+				attributes = `{"draft": true}`
+
+				recorder := serve("GET", "/posts/1", "")
+				So(recorder.Code, ShouldEqual, http.StatusInternalServerError)
+				So(recorder.Body.String(), ShouldContainSubstring, "Attribute 'draft' of 'posts' is not in the spec")
+			})
+
+			Convey("should send matching responses as they are", func() {
+				recorder := serve("GET", "/posts/1", "")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"title": "Hello"`)
+			})
+
+			Convey("should let bodies above the limit through", func() {
+				opts.MaxBodyBytes = 10
+				attributes = `{"draft": true}`
+
+				recorder := serve("GET", "/posts/1", "")
+				So(recorder.Code, ShouldEqual, http.StatusOK)
+				So(recorder.Body.String(), ShouldContainSubstring, `"draft": true`)
+				So(logged.String(), ShouldEqual, "")
+			})
+		})
+
+		Convey("should panic on invalid specs", func() {
+			So(func() { ValidateAgainstSpec([]byte(`{"version": "0"}`), opts) }, ShouldPanicWith,
+				"jshapi: unsupported spec version '0', expected '1'")
+			So(func() { ValidateAgainstSpec([]byte(`[]`), opts) }, ShouldPanic)
+		})
+	})
+}
@@ -0,0 +1,81 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"golang.org/x/net/context"
+)
+
+/*
+NoContent makes successful writes of the given operations, OpCreate and OpUpdate,
+respond 204 No Content rather than with the written object:
+
+	resource.NoContent(OpUpdate)
+
+As required by the JSON API specification, a create still responds 201 with the
+created object unless the client generated its ID, since the client can't know
+the ID otherwise. See writeStatus for the complete decision.
+*/
+func (res *Resource) NoContent(ops ...Operation) {
+	res.checkRegistration("a setting")
+
+	for _, op := range ops {
+		if op != OpCreate && op != OpUpdate {
+			panic(fmt.Sprintf("jshapi: NoContent supports OpCreate and OpUpdate, not '%s'", op))
+		}
+
+		if res.noContent == nil {
+			res.noContent = map[Operation]bool{}
+		}
+		res.noContent[op] = true
+	}
+}
+
+/*
+writeStatus returns the status of a successful write operation, OpCreate or
+OpUpdate. noContent is set when the resource omits the written object from the
+response, and clientID when the client generated the ID of the object.
+
+	operation  noContent  clientID  status
+	OpCreate   false      any       201 Created, with the object
+	OpCreate   true       false     201 Created, with the object
+	OpCreate   true       true      204 No Content
+	OpUpdate   false      any       200 OK, with the object
+	OpUpdate   true       any       204 No Content
+*/
+func writeStatus(op Operation, noContent bool, clientID bool) int {
+	switch {
+	case op == OpCreate && noContent && clientID:
+		return http.StatusNoContent
+	case op == OpCreate:
+		return http.StatusCreated
+	case noContent:
+		return http.StatusNoContent
+	default:
+		return http.StatusOK
+	}
+}
+
+// sendWritten responds to the successful write of an object, clientID reports
+// whether the client generated its ID
+func (res *Resource) sendWritten(
+	ctx context.Context,
+	w http.ResponseWriter,
+	r *http.Request,
+	op Operation,
+	clientID bool,
+	written *jsh.Object,
+) {
+	status := writeStatus(op, res.noContent[op], clientID)
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+
+	doc := jsh.Build(written)
+	doc.Status = status
+	addUnknownAttributes(ctx, doc)
+	res.send(ctx, w, r, doc)
+}
@@ -0,0 +1,106 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store/memstore"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteStatus(t *testing.T) {
+
+	Convey("Write Status Tests", t, func() {
+
+		Convey("->writeStatus()", func() {
+			tests := []struct {
+				op        Operation
+				noContent bool
+				clientID  bool
+				status    int
+			}{
+				{OpCreate, false, false, http.StatusCreated},
+				{OpCreate, false, true, http.StatusCreated},
+				{OpCreate, true, false, http.StatusCreated},
+				{OpCreate, true, true, http.StatusNoContent},
+				{OpUpdate, false, false, http.StatusOK},
+				{OpUpdate, false, true, http.StatusOK},
+				{OpUpdate, true, false, http.StatusNoContent},
+				{OpUpdate, true, true, http.StatusNoContent},
+			}
+
+			for _, test := range tests {
+				name := fmt.Sprintf("%s, noContent: %t, clientID: %t", test.op, test.noContent, test.clientID)
+				Convey("should respond "+http.StatusText(test.status)+" to "+name, func() {
+					So(writeStatus(test.op, test.noContent, test.clientID), ShouldEqual, test.status)
+				})
+			}
+		})
+
+		storage := memstore.New(testResourceType)
+		storage.Save(context.Background(), sampleObject("", testResourceType, testObjAttrs))
+
+		resource := NewCRUDResource(testResourceType, storage)
+
+		api := New("")
+		api.Add(resource)
+
+		send := func(method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		create := `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`
+		createWithID := `{"data": {"type": "bars", "id": "5", "attributes": {"foo": "bar"}}}`
+		update := `{"data": {"type": "bars", "id": "1", "attributes": {"foo": "baz"}}}`
+
+		Convey("should respond with the written objects by default", func() {
+			recorder := send("POST", "/bars", create)
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+			So(recorder.Body.String(), ShouldContainSubstring, `"id": "2"`)
+
+			recorder = send("POST", "/bars", createWithID)
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+
+			recorder = send("PATCH", "/bars/1", update)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"foo": "baz"`)
+		})
+
+		Convey("->NoContent()", func() {
+			resource.NoContent(OpCreate, OpUpdate)
+
+			Convey("should respond 204 to updates", func() {
+				recorder := send("PATCH", "/bars/1", update)
+				So(recorder.Code, ShouldEqual, http.StatusNoContent)
+				So(recorder.Body.Len(), ShouldEqual, 0)
+			})
+
+			Convey("should respond 204 to creates with a client generated ID", func() {
+				recorder := send("POST", "/bars", createWithID)
+				So(recorder.Code, ShouldEqual, http.StatusNoContent)
+				So(recorder.Body.Len(), ShouldEqual, 0)
+				So(recorder.Header().Get("Location"), ShouldEqual, "/bars/5")
+			})
+
+			Convey("should respond 201 to creates without a client generated ID", func() {
+				recorder := send("POST", "/bars", create)
+				So(recorder.Code, ShouldEqual, http.StatusCreated)
+				So(recorder.Body.String(), ShouldContainSubstring, `"id": "2"`)
+			})
+
+			Convey("should reject other operations", func() {
+				So(func() { resource.NoContent(OpDelete) }, ShouldPanic)
+			})
+		})
+	})
+}
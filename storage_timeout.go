@@ -0,0 +1,128 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+StorageTimeout bounds the time each storage call made while serving the resource
+routes may take, independently of the request timeout. Every call is given its own
+deadline, so that the loads of included objects and relationships don't share one
+budget. Without operations, timeout applies to the calls of every route, otherwise
+it overrides it for the calls of the given operations, OpInclude covering those
+resolving compound documents:
+
+	users.StorageTimeout(2 * time.Second)
+	users.StorageTimeout(10*time.Second, jshapi.OpList)
+
+A storage call failing once its deadline has expired is answered with a 504 JSON
+API error whose meta names the call and operation, see StorageTimeoutError. A zero
+timeout removes the bound.
+*/
+func (res *Resource) StorageTimeout(timeout time.Duration, ops ...Operation) {
+	res.checkRegistration("a setting")
+
+	if timeout < 0 {
+		panic(fmt.Sprintf("jshapi: storage timeout must not be negative, got %s", timeout))
+	}
+
+	if len(ops) == 0 {
+		res.storageTimeout = timeout
+		return
+	}
+
+	if res.storageTimeouts == nil {
+		res.storageTimeouts = map[Operation]time.Duration{}
+	}
+	for _, op := range ops {
+		res.storageTimeouts[op] = timeout
+	}
+}
+
+/*
+StorageTimeoutRecorder can optionally be implemented by a MetricsRecorder to count
+the storage calls that timed out, which are also observed as failed calls by
+ObserveStorage.
+*/
+type StorageTimeoutRecorder interface {
+	ObserveStorageTimeout(route Route, call string, timeout time.Duration)
+}
+
+/*
+StorageTimeoutError is returned in place of the error of a storage call that failed
+after its StorageTimeout expired. It is sent as a 504 error with a meta member
+naming the call, and ends the storage span given to the Tracer, which can tell
+timeouts apart from other failures by its type.
+*/
+type StorageTimeoutError struct {
+	// Call is the storage function name, see StorageRecorder
+	Call      string
+	Operation Operation
+	Timeout   time.Duration
+}
+
+// Error implements error
+func (e *StorageTimeoutError) Error() string {
+	return e.jshError().Error()
+}
+
+// Validate implements jsh.Sendable
+func (e *StorageTimeoutError) Validate(r *http.Request, response bool) *jsh.Error {
+	return e.jshError().Validate(r, response)
+}
+
+// StatusCode implements jsh.ErrorType
+func (e *StorageTimeoutError) StatusCode() int {
+	return http.StatusGatewayTimeout
+}
+
+// jshError is the error object sent for the timeout
+func (e *StorageTimeoutError) jshError() *jsh.Error {
+	return &jsh.Error{
+		Title:  "Gateway Timeout",
+		Detail: fmt.Sprintf("Storage call '%s' did not complete within %s", e.Call, e.Timeout),
+		Status: http.StatusGatewayTimeout,
+	}
+}
+
+// meta is the meta member of the error object sent for the timeout
+func (e *StorageTimeoutError) meta() map[string]interface{} {
+	return map[string]interface{}{
+		"call":      e.Call,
+		"operation": e.Operation,
+		"timeout":   e.Timeout.String(),
+	}
+}
+
+// storageTimeout returns the operation of the storage calls made with ctx, and
+// their timeout if any
+func storageTimeout(ctx context.Context) (Operation, time.Duration) {
+	op, isOverridden := ctx.Value(storageOperationKey).(Operation)
+	if !isOverridden {
+		op = CurrentOperation(ctx)
+	}
+
+	meta := routeFromContext(ctx)
+	if meta == nil {
+		return op, 0
+	}
+
+	timeout, isSet := meta.res.storageTimeouts[op]
+	if !isSet {
+		timeout = meta.res.storageTimeout
+	}
+
+	return op, timeout
+}
+
+// storageTimedOut reports whether a storage call given deadlineCtx failed because
+// of its own deadline rather than the end of the request
+func storageTimedOut(parent context.Context, deadlineCtx context.Context, err jsh.ErrorType) bool {
+	return HasError(err) && deadlineCtx.Err() == context.DeadlineExceeded && parent.Err() == nil
+}
@@ -0,0 +1,135 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// timeoutMetrics keeps the storage calls that timed out
+type timeoutMetrics struct {
+	recordMetrics
+	timeouts []string
+}
+
+func (m *timeoutMetrics) ObserveStorageTimeout(route Route, call string, timeout time.Duration) {
+	m.timeouts = append(m.timeouts, call)
+}
+
+// slowly waits for delay before returning result, unless ctx ends first
+func slowly(ctx context.Context, delay time.Duration, result *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	select {
+	case <-time.After(delay):
+		return result, nil
+	case <-ctx.Done():
+		return nil, jsh.ISE(ctx.Err().Error())
+	}
+}
+
+func TestStorageTimeout(t *testing.T) {
+
+	Convey("Storage Timeout Tests", t, func() {
+
+		object := func(id string, resourceType string) *jsh.Object {
+			created, _ := jsh.NewObject(id, resourceType, map[string]string{"name": resourceType + id})
+			return created
+		}
+
+		posts := NewResource("posts")
+		posts.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			if id == "slow" {
+				return slowly(ctx, time.Second, nil)
+			}
+			return slowly(ctx, 30*time.Millisecond, object(id, "posts"))
+		})
+		posts.List(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+			listed, err := slowly(ctx, 80*time.Millisecond, object("1", "posts"))
+			if HasError(err) {
+				return nil, err
+			}
+			return jsh.List{listed}, nil
+		})
+		posts.ToMany("comments", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			related, err := slowly(ctx, 30*time.Millisecond, object("1", "comments"))
+			if HasError(err) {
+				return nil, err
+			}
+			return jsh.List{related}, nil
+		})
+		posts.StorageTimeout(50 * time.Millisecond)
+
+		metrics := &timeoutMetrics{}
+		tracer := &testTracer{}
+
+		api := New("")
+		api.SetMetrics(metrics)
+		api.SetTracer(tracer)
+		api.Add(posts)
+		api.Add(NewResource("comments"))
+
+		get := func(url string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+			return recorder
+		}
+
+		Convey("should answer storage calls past their deadline with a 504", func() {
+			recorder := get("/posts/slow")
+
+			So(recorder.Code, ShouldEqual, http.StatusGatewayTimeout)
+			So(recorder.Body.String(), ShouldContainSubstring, `"call": "get"`)
+			So(recorder.Body.String(), ShouldContainSubstring, `"operation": "read"`)
+			So(recorder.Body.String(), ShouldContainSubstring, `"timeout": "50ms"`)
+		})
+
+		Convey("should record timeouts distinctly", func() {
+			get("/posts/slow")
+
+			So(metrics.timeouts, ShouldResemble, []string{"get"})
+
+			var storageSpan *testSpan
+			for _, span := range tracer.spans {
+				if span.name == "store.get" {
+					storageSpan = span
+				}
+			}
+			So(storageSpan, ShouldNotBeNil)
+			So(storageSpan.err, ShouldHaveSameTypeAs, &StorageTimeoutError{})
+		})
+
+		Convey("should give each include call its own budget", func() {
+			recorder := get("/posts/1?include=comments")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `"type": "comments"`)
+			So(metrics.timeouts, ShouldBeEmpty)
+		})
+
+		Convey("should apply per-operation overrides", func() {
+			So(get("/posts").Code, ShouldEqual, http.StatusGatewayTimeout)
+
+			posts.StorageTimeout(time.Second, OpList)
+			So(get("/posts").Code, ShouldEqual, http.StatusOK)
+
+			posts.StorageTimeout(10*time.Millisecond, OpInclude)
+			recorder := get("/posts/1?include=comments")
+			So(recorder.Code, ShouldEqual, http.StatusGatewayTimeout)
+			So(recorder.Body.String(), ShouldContainSubstring, `"operation": "include"`)
+		})
+
+		Convey("should leave storage unbounded without a timeout", func() {
+			posts.StorageTimeout(0)
+			So(get("/posts").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("should panic on negative timeouts", func() {
+			So(func() { posts.StorageTimeout(-time.Second) }, ShouldPanic)
+		})
+	})
+}
@@ -0,0 +1,326 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/internal/nilness"
+	"golang.org/x/net/context"
+)
+
+// The circuits of WithBreaker, tripped separately
+const (
+	// ReadCircuit guards Get and List
+	ReadCircuit = "read"
+	// WriteCircuit guards Save, Update and Delete
+	WriteCircuit = "write"
+)
+
+// BreakerState is the state of a circuit of WithBreaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails every call immediately
+	BreakerOpen
+	// BreakerHalfOpen lets probe calls through, closing the circuit when they
+	// succeed and opening it again when one fails
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// BreakerOptions configures WithBreaker, zero values select the defaults
+type BreakerOptions struct {
+	// Threshold is the number of failures within Window opening a circuit, 5 by
+	// default
+	Threshold int
+	// Window is the rolling window failures are counted over, 10 seconds by default
+	Window time.Duration
+	// OpenFor is the time an open circuit fails calls before probing the storage,
+	// 30 seconds by default
+	OpenFor time.Duration
+	// Probes is the number of successful calls closing a half-open circuit, which
+	// lets that many calls through at a time, 1 by default
+	Probes int
+	// IsFailure reports whether a storage error counts as a failure of the storage,
+	// 5XX errors by default
+	IsFailure func(err jsh.ErrorType) bool
+	// OnStateChange is called with ReadCircuit or WriteCircuit whenever the state
+	// of the circuit changes, such as to count the times it opens
+	OnStateChange func(circuit string, from BreakerState, to BreakerState)
+}
+
+/*
+WithBreaker guards crud with circuit breakers, so that calls fail fast while the
+storage is failing rather than each spending its full timeout:
+
+	users := jshapi.NewCRUDResource("users", store.WithBreaker(db, store.BreakerOptions{
+		OnStateChange: func(circuit string, from, to store.BreakerState) {
+			breakerState.WithLabelValues(circuit).Set(float64(to))
+		},
+	}))
+
+Reads and writes are guarded by separate circuits, as replicas fail separately
+from the primary. A circuit opens after Threshold failures within Window, and
+open circuits answer calls with an OpenCircuitError, a 503 sent with a Retry-After
+header, until OpenFor has elapsed. The circuit is then half-open, letting Probes
+calls through to close it again.
+
+The returned CRUD only implements CRUD, optional interfaces of crud such as
+DryRunner are not forwarded.
+*/
+func WithBreaker(crud CRUD, opts BreakerOptions) CRUD {
+	if opts.Threshold <= 0 {
+		opts.Threshold = 5
+	}
+	if opts.Window <= 0 {
+		opts.Window = 10 * time.Second
+	}
+	if opts.OpenFor <= 0 {
+		opts.OpenFor = 30 * time.Second
+	}
+	if opts.Probes <= 0 {
+		opts.Probes = 1
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = func(err jsh.ErrorType) bool {
+			return err.StatusCode() >= http.StatusInternalServerError
+		}
+	}
+
+	return &breakerCRUD{
+		crud:  crud,
+		read:  &circuit{name: ReadCircuit, opts: opts},
+		write: &circuit{name: WriteCircuit, opts: opts},
+	}
+}
+
+// breakerCRUD is the CRUD returned by WithBreaker
+type breakerCRUD struct {
+	crud  CRUD
+	read  *circuit
+	write *circuit
+}
+
+func (b *breakerCRUD) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	err := b.write.allow()
+	if err != nil {
+		return nil, err
+	}
+
+	saved, saveErr := b.crud.Save(ctx, object)
+	b.write.done(saveErr)
+	return saved, saveErr
+}
+
+func (b *breakerCRUD) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	err := b.read.allow()
+	if err != nil {
+		return nil, err
+	}
+
+	object, getErr := b.crud.Get(ctx, id)
+	b.read.done(getErr)
+	return object, getErr
+}
+
+func (b *breakerCRUD) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	err := b.read.allow()
+	if err != nil {
+		return nil, err
+	}
+
+	list, listErr := b.crud.List(ctx)
+	b.read.done(listErr)
+	return list, listErr
+}
+
+func (b *breakerCRUD) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	err := b.write.allow()
+	if err != nil {
+		return nil, err
+	}
+
+	updated, updateErr := b.crud.Update(ctx, object)
+	b.write.done(updateErr)
+	return updated, updateErr
+}
+
+func (b *breakerCRUD) Delete(ctx context.Context, id string) jsh.ErrorType {
+	err := b.write.allow()
+	if err != nil {
+		return err
+	}
+
+	deleteErr := b.crud.Delete(ctx, id)
+	b.write.done(deleteErr)
+	return deleteErr
+}
+
+// circuit is a rolling-window circuit breaker
+type circuit struct {
+	name string
+	opts BreakerOptions
+
+	mu    sync.Mutex
+	state BreakerState
+	// failures holds the times of the failures within the window while closed
+	failures []time.Time
+	// opened is the time the circuit last opened
+	opened time.Time
+	// probing counts the probe calls in flight, succeeded those that succeeded
+	probing   int
+	succeeded int
+}
+
+// allow returns an OpenCircuitError when the call must fail fast
+func (c *circuit) allow() *OpenCircuitError {
+	c.mu.Lock()
+
+	if c.state == BreakerOpen {
+		remaining := c.opts.OpenFor - time.Since(c.opened)
+		if remaining > 0 {
+			c.mu.Unlock()
+			return &OpenCircuitError{Circuit: c.name, Remaining: remaining}
+		}
+		c.transition(BreakerHalfOpen)
+	}
+
+	if c.state == BreakerHalfOpen {
+		if c.probing+c.succeeded >= c.opts.Probes {
+			c.mu.Unlock()
+			return &OpenCircuitError{Circuit: c.name}
+		}
+		c.probing++
+	}
+
+	c.mu.Unlock()
+	return nil
+}
+
+// done records the result of a call allowed through
+func (c *circuit) done(err jsh.ErrorType) {
+	failed := nilness.HasError(err) && c.opts.IsFailure(err)
+
+	c.mu.Lock()
+
+	now := time.Now()
+	switch c.state {
+	case BreakerClosed:
+		if !failed {
+			break
+		}
+
+		recent := c.failures[:0]
+		for _, failure := range c.failures {
+			if now.Sub(failure) < c.opts.Window {
+				recent = append(recent, failure)
+			}
+		}
+		c.failures = append(recent, now)
+
+		if len(c.failures) >= c.opts.Threshold {
+			c.open(now)
+		}
+	case BreakerHalfOpen:
+		c.probing--
+		if failed {
+			c.open(now)
+			break
+		}
+
+		c.succeeded++
+		if c.succeeded >= c.opts.Probes {
+			c.transition(BreakerClosed)
+		}
+	}
+
+	c.mu.Unlock()
+}
+
+// open opens the circuit, must be called with the lock held
+func (c *circuit) open(now time.Time) {
+	c.opened = now
+	c.transition(BreakerOpen)
+}
+
+// transition changes the state of the circuit, must be called with the lock held
+func (c *circuit) transition(state BreakerState) {
+	from := c.state
+	c.state = state
+	c.failures = nil
+	c.probing = 0
+	c.succeeded = 0
+
+	if c.opts.OnStateChange != nil {
+		c.opts.OnStateChange(c.name, from, state)
+	}
+}
+
+/*
+RetryableError is implemented by storage errors of temporary failures, such as
+OpenCircuitError. Resources send them as their JSON API error, with a Retry-After
+header.
+*/
+type RetryableError interface {
+	jsh.ErrorType
+	RetryAfter() time.Duration
+	JSONAPIError() *jsh.Error
+}
+
+// OpenCircuitError is the error of the calls failed by an open circuit of
+// WithBreaker
+type OpenCircuitError struct {
+	// Circuit is ReadCircuit or WriteCircuit
+	Circuit string
+	// Remaining is the time left before the circuit lets probe calls through, zero
+	// while they are under way
+	Remaining time.Duration
+}
+
+// Error implements error
+func (e *OpenCircuitError) Error() string {
+	return e.JSONAPIError().Error()
+}
+
+// Validate implements jsh.Sendable
+func (e *OpenCircuitError) Validate(r *http.Request, response bool) *jsh.Error {
+	return e.JSONAPIError().Validate(r, response)
+}
+
+// StatusCode implements jsh.ErrorType
+func (e *OpenCircuitError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+// RetryAfter implements RetryableError, rounded up to the second
+func (e *OpenCircuitError) RetryAfter() time.Duration {
+	seconds := math.Max(1, math.Ceil(e.Remaining.Seconds()))
+	return time.Duration(seconds) * time.Second
+}
+
+// JSONAPIError implements RetryableError
+func (e *OpenCircuitError) JSONAPIError() *jsh.Error {
+	return &jsh.Error{
+		Title:  "Service Unavailable",
+		Detail: fmt.Sprintf("Storage %ss are failing, retry later", e.Circuit),
+		Status: http.StatusServiceUnavailable,
+	}
+}
@@ -0,0 +1,184 @@
+package store_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api"
+	"github.com/derekdowling/jsh-api/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// flakyCRUD fails its reads and writes while told to, counting the calls it gets
+type flakyCRUD struct {
+	mu         sync.Mutex
+	failReads  bool
+	failWrites bool
+	calls      int
+}
+
+func (f *flakyCRUD) result(fail bool) jsh.ErrorType {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	if fail {
+		return jsh.ISE("storage is down")
+	}
+	return nil
+}
+
+func (f *flakyCRUD) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	err := f.result(f.failWrites)
+	if err != nil {
+		return nil, err
+	}
+	object.ID = "1"
+	return object, nil
+}
+
+func (f *flakyCRUD) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	if id == "missing" {
+		return nil, jsh.NotFound("users", id)
+	}
+
+	err := f.result(f.failReads)
+	if err != nil {
+		return nil, err
+	}
+
+	object, objectErr := jsh.NewObject(id, "users", map[string]string{"name": "user"})
+	if objectErr != nil {
+		return nil, objectErr
+	}
+	return object, nil
+}
+
+func (f *flakyCRUD) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	err := f.result(f.failReads)
+	if err != nil {
+		return nil, err
+	}
+	return jsh.List{}, nil
+}
+
+func (f *flakyCRUD) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	err := f.result(f.failWrites)
+	if err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func (f *flakyCRUD) Delete(ctx context.Context, id string) jsh.ErrorType {
+	return f.result(f.failWrites)
+}
+
+func TestBreaker(t *testing.T) {
+
+	Convey("Breaker Tests", t, func() {
+
+		ctx := context.Background()
+		backend := &flakyCRUD{failReads: true}
+
+		type change struct {
+			circuit string
+			to      store.BreakerState
+		}
+		changes := []change{}
+
+		crud := store.WithBreaker(backend, store.BreakerOptions{
+			Threshold: 2,
+			Window:    time.Minute,
+			OpenFor:   50 * time.Millisecond,
+			Probes:    2,
+			OnStateChange: func(circuit string, from store.BreakerState, to store.BreakerState) {
+				changes = append(changes, change{circuit, to})
+			},
+		})
+
+		Convey("should open after the failure threshold and fail fast", func() {
+			crud.Get(ctx, "1")
+			crud.Get(ctx, "1")
+			So(changes, ShouldResemble, []change{{store.ReadCircuit, store.BreakerOpen}})
+
+			_, err := crud.Get(ctx, "1")
+			So(err.StatusCode(), ShouldEqual, http.StatusServiceUnavailable)
+			So(backend.calls, ShouldEqual, 2)
+
+			retryable, isRetryable := err.(store.RetryableError)
+			So(isRetryable, ShouldBeTrue)
+			So(retryable.RetryAfter(), ShouldEqual, time.Second)
+		})
+
+		Convey("should not count client errors as failures", func() {
+			guarded := store.WithBreaker(backend, store.BreakerOptions{Threshold: 1})
+
+			guarded.Get(ctx, "missing")
+			_, err := guarded.Get(ctx, "missing")
+			So(err.StatusCode(), ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("should break reads and writes independently", func() {
+			crud.List(ctx)
+			crud.List(ctx)
+
+			_, err := crud.Save(ctx, &jsh.Object{Type: "users"})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("should close once the probes succeed", func() {
+			crud.Get(ctx, "1")
+			crud.Get(ctx, "1")
+			backend.failReads = false
+			time.Sleep(60 * time.Millisecond)
+
+			_, err := crud.Get(ctx, "1")
+			So(err, ShouldBeNil)
+			_, err = crud.Get(ctx, "1")
+			So(err, ShouldBeNil)
+
+			So(changes, ShouldResemble, []change{
+				{store.ReadCircuit, store.BreakerOpen},
+				{store.ReadCircuit, store.BreakerHalfOpen},
+				{store.ReadCircuit, store.BreakerClosed},
+			})
+		})
+
+		Convey("should open again when a probe fails", func() {
+			crud.Get(ctx, "1")
+			crud.Get(ctx, "1")
+			time.Sleep(60 * time.Millisecond)
+
+			crud.Get(ctx, "1")
+			So(changes[len(changes)-1], ShouldResemble, change{store.ReadCircuit, store.BreakerOpen})
+
+			_, err := crud.Get(ctx, "1")
+			So(err.StatusCode(), ShouldEqual, http.StatusServiceUnavailable)
+		})
+
+		Convey("should be sent as a 503 with a Retry-After header", func() {
+			api := jshapi.New("")
+			api.Add(jshapi.NewCRUDResource("users", crud))
+
+			get := func() *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				api.ServeHTTP(recorder, httptest.NewRequest("GET", "/users/1", nil))
+				return recorder
+			}
+			get()
+			get()
+
+			recorder := get()
+			So(recorder.Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(recorder.Header().Get("Retry-After"), ShouldEqual, "1")
+			So(recorder.Body.String(), ShouldContainSubstring, "Storage reads are failing")
+		})
+	})
+}
@@ -0,0 +1,275 @@
+package store
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"golang.org/x/net/context"
+)
+
+// Fault is a failure injected by WithFaults
+type Fault int
+
+const (
+	// FaultLatency delays the call before it reaches the storage
+	FaultLatency Fault = iota
+	// FaultNotFound fails the call with a 404
+	FaultNotFound
+	// FaultConflict fails the call with a 409
+	FaultConflict
+	// FaultError fails the call with a 500
+	FaultError
+	// FaultDeadline fails the call once its context deadline is exceeded
+	FaultDeadline
+)
+
+func (f Fault) String() string {
+	switch f {
+	case FaultLatency:
+		return "latency"
+	case FaultNotFound:
+		return "not-found"
+	case FaultConflict:
+		return "conflict"
+	case FaultError:
+		return "error"
+	case FaultDeadline:
+		return "deadline"
+	default:
+		return fmt.Sprintf("Fault(%d)", int(f))
+	}
+}
+
+// LatencyDistribution draws the latency injected into a call from random
+type LatencyDistribution func(random *rand.Rand) time.Duration
+
+// UniformLatency draws latencies evenly between min and max
+func UniformLatency(min time.Duration, max time.Duration) LatencyDistribution {
+	return func(random *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(random.Int63n(int64(max-min)))
+	}
+}
+
+// ExponentialLatency draws latencies exponentially distributed around mean, most
+// calls being slightly delayed and a few by several times mean
+func ExponentialLatency(mean time.Duration) LatencyDistribution {
+	return func(random *rand.Rand) time.Duration {
+		return time.Duration(random.ExpFloat64() * float64(mean))
+	}
+}
+
+// FaultOptions configures WithFaults, rates being the probabilities, from 0 to 1,
+// of each fault per call
+type FaultOptions struct {
+	// Seed seeds the draws, so that the same sequence of calls meets the same
+	// faults from one run to the next
+	Seed int64
+	// LatencyRate is the rate of calls delayed by a latency drawn from Latency,
+	// which is uniform between 0 and 100 milliseconds by default. Delayed calls
+	// may meet another fault.
+	LatencyRate float64
+	Latency     LatencyDistribution
+	// NotFoundRate, ConflictRate and ErrorRate are the rates of calls failed with
+	// a 404, 409 and 500 error, and DeadlineRate that of calls failed once their
+	// context deadline is exceeded. They add up to the rate of failed calls.
+	NotFoundRate float64
+	ConflictRate float64
+	ErrorRate    float64
+	DeadlineRate float64
+	// OnFault is called with the storage call, such as "get", and the fault
+	// injected into it, such as to count faults alongside storage metrics
+	OnFault func(call string, fault Fault)
+	// AllowInProduction lets WithFaults wrap storage in binaries built without
+	// the faults tag, for game days
+	AllowInProduction bool
+}
+
+/*
+WithFaults injects faults into the calls of crud, to test how the API behaves when
+storage is slow or failing:
+
+	posts := jshapi.NewCRUDResource("posts", store.WithFaults(db, store.FaultOptions{
+		Seed:         1,
+		LatencyRate:  0.2,
+		Latency:      store.ExponentialLatency(300 * time.Millisecond),
+		ErrorRate:    0.05,
+		DeadlineRate: 0.01,
+	}))
+
+Injected errors are returned as storage errors, which resources observe with the
+API metrics and StorageTimeout as any other: a deadline fault blocks until the
+context of the call is done, and is answered with a 504 by a resource with a
+storage timeout. Failed calls don't reach crud.
+
+WithFaults panics unless the binary is built with the faults tag, as the tests
+injecting faults are, with `go test -tags faults`, or AllowInProduction is set.
+The returned CRUD only implements CRUD, optional interfaces of crud such as
+DryRunner are not forwarded.
+*/
+func WithFaults(crud CRUD, opts FaultOptions) CRUD {
+	if !opts.AllowInProduction && !faultsTagged {
+		panic("store: WithFaults requires the faults build tag, set AllowInProduction to inject faults")
+	}
+
+	if opts.Latency == nil {
+		opts.Latency = UniformLatency(0, 100*time.Millisecond)
+	}
+
+	return &faultCRUD{
+		crud:   crud,
+		opts:   opts,
+		random: rand.New(rand.NewSource(opts.Seed)),
+	}
+}
+
+// faultCRUD is the CRUD returned by WithFaults
+type faultCRUD struct {
+	crud CRUD
+	opts FaultOptions
+
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+func (f *faultCRUD) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	err := f.inject(ctx, "save")
+	if err != nil {
+		return nil, err
+	}
+
+	return f.crud.Save(ctx, object)
+}
+
+func (f *faultCRUD) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	err := f.inject(ctx, "get")
+	if err != nil {
+		return nil, err
+	}
+
+	return f.crud.Get(ctx, id)
+}
+
+func (f *faultCRUD) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	err := f.inject(ctx, "list")
+	if err != nil {
+		return nil, err
+	}
+
+	return f.crud.List(ctx)
+}
+
+func (f *faultCRUD) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	err := f.inject(ctx, "update")
+	if err != nil {
+		return nil, err
+	}
+
+	return f.crud.Update(ctx, object)
+}
+
+func (f *faultCRUD) Delete(ctx context.Context, id string) jsh.ErrorType {
+	err := f.inject(ctx, "delete")
+	if err != nil {
+		return err
+	}
+
+	return f.crud.Delete(ctx, id)
+}
+
+// inject draws the faults of a call, delaying it, and returns the error failing
+// it if any
+func (f *faultCRUD) inject(ctx context.Context, call string) *jsh.Error {
+	latency, fault, failed := f.draw()
+
+	if latency > 0 {
+		f.report(call, FaultLatency)
+
+		timer := time.NewTimer(latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return deadlineError(call)
+		}
+	}
+
+	if !failed {
+		return nil
+	}
+	f.report(call, fault)
+
+	switch fault {
+	case FaultNotFound:
+		return &jsh.Error{
+			Title:  "Not Found",
+			Detail: fmt.Sprintf("Injected fault of storage call '%s'", call),
+			Status: http.StatusNotFound,
+		}
+	case FaultConflict:
+		return &jsh.Error{
+			Title:  "Conflict",
+			Detail: fmt.Sprintf("Injected fault of storage call '%s'", call),
+			Status: http.StatusConflict,
+		}
+	case FaultDeadline:
+		_, hasDeadline := ctx.Deadline()
+		if hasDeadline {
+			<-ctx.Done()
+		}
+		return deadlineError(call)
+	default:
+		return jsh.ISE(fmt.Sprintf("Injected fault of storage call '%s'", call))
+	}
+}
+
+// draw draws the latency of a call, zero for none, and the fault failing it if
+// failed is set
+func (f *faultCRUD) draw() (latency time.Duration, fault Fault, failed bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.random.Float64() < f.opts.LatencyRate {
+		latency = f.opts.Latency(f.random)
+	}
+
+	draw := f.random.Float64()
+	for _, rated := range []struct {
+		fault Fault
+		rate  float64
+	}{
+		{FaultNotFound, f.opts.NotFoundRate},
+		{FaultConflict, f.opts.ConflictRate},
+		{FaultError, f.opts.ErrorRate},
+		{FaultDeadline, f.opts.DeadlineRate},
+	} {
+		if draw < rated.rate {
+			return latency, rated.fault, true
+		}
+		draw -= rated.rate
+	}
+
+	return latency, 0, false
+}
+
+// report passes an injected fault to OnFault
+func (f *faultCRUD) report(call string, fault Fault) {
+	if f.opts.OnFault != nil {
+		f.opts.OnFault(call, fault)
+	}
+}
+
+// deadlineError is the error of a call failed by a deadline fault
+func deadlineError(call string) *jsh.Error {
+	return &jsh.Error{
+		Title:  "Gateway Timeout",
+		Detail: fmt.Sprintf("Storage call '%s' exceeded its deadline", call),
+		Status: http.StatusGatewayTimeout,
+	}
+}
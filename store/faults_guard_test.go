@@ -0,0 +1,26 @@
+//go:build !faults
+// +build !faults
+
+package store_test
+
+import (
+	"testing"
+
+	"github.com/derekdowling/jsh-api/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFaultsGuard(t *testing.T) {
+
+	Convey("Faults Guard Tests", t, func() {
+
+		Convey("should refuse to inject faults without the faults tag", func() {
+			So(func() { store.WithFaults(&flakyCRUD{}, store.FaultOptions{}) }, ShouldPanicWith,
+				"store: WithFaults requires the faults build tag, set AllowInProduction to inject faults")
+		})
+
+		Convey("should inject faults when allowed in production", func() {
+			So(store.WithFaults(&flakyCRUD{}, store.FaultOptions{AllowInProduction: true}), ShouldNotBeNil)
+		})
+	})
+}
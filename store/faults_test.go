@@ -0,0 +1,100 @@
+//go:build faults
+// +build faults
+
+package store_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/jsh-api"
+	"github.com/derekdowling/jsh-api/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFaults(t *testing.T) {
+
+	Convey("Faults Tests", t, func() {
+
+		ctx := context.Background()
+		backend := &flakyCRUD{}
+
+		statuses := func(crud store.CRUD, calls int) []int {
+			results := []int{}
+			for i := 0; i < calls; i++ {
+				_, err := crud.Get(ctx, "1")
+				if jshapi.HasError(err) {
+					results = append(results, err.StatusCode())
+					continue
+				}
+				results = append(results, http.StatusOK)
+			}
+			return results
+		}
+
+		Convey("should inject the same faults for the same seed", func() {
+			opts := store.FaultOptions{Seed: 42, NotFoundRate: 0.2, ConflictRate: 0.2, ErrorRate: 0.2}
+
+			first := statuses(store.WithFaults(backend, opts), 50)
+			So(first, ShouldResemble, statuses(store.WithFaults(backend, opts), 50))
+			So(first, ShouldContain, http.StatusOK)
+			So(first, ShouldContain, http.StatusNotFound)
+			So(first, ShouldContain, http.StatusConflict)
+			So(first, ShouldContain, http.StatusInternalServerError)
+		})
+
+		Convey("should fail calls without reaching the storage", func() {
+			crud := store.WithFaults(backend, store.FaultOptions{ErrorRate: 1})
+
+			err := crud.Delete(ctx, "1")
+			So(err.StatusCode(), ShouldEqual, http.StatusInternalServerError)
+			So(backend.calls, ShouldEqual, 0)
+		})
+
+		Convey("should delay calls and report faults", func() {
+			faults := []string{}
+			crud := store.WithFaults(backend, store.FaultOptions{
+				LatencyRate: 1,
+				Latency:     store.UniformLatency(20*time.Millisecond, 30*time.Millisecond),
+				OnFault: func(call string, fault store.Fault) {
+					faults = append(faults, call+" "+fault.String())
+				},
+			})
+
+			start := time.Now()
+			_, err := crud.List(ctx)
+			So(err, ShouldBeNil)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+			So(faults, ShouldResemble, []string{"list latency"})
+			So(backend.calls, ShouldEqual, 1)
+		})
+
+		Convey("should fail deadline faults once the deadline is exceeded", func() {
+			crud := store.WithFaults(backend, store.FaultOptions{DeadlineRate: 1})
+
+			deadlineCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+
+			_, err := crud.Get(deadlineCtx, "1")
+			So(err.StatusCode(), ShouldEqual, http.StatusGatewayTimeout)
+			So(deadlineCtx.Err(), ShouldEqual, context.DeadlineExceeded)
+		})
+
+		Convey("should be answered as storage timeouts by resources", func() {
+			users := jshapi.NewCRUDResource("users", store.WithFaults(backend, store.FaultOptions{DeadlineRate: 1}))
+			users.StorageTimeout(20 * time.Millisecond)
+
+			api := jshapi.New("")
+			api.Add(users)
+
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, httptest.NewRequest("GET", "/users/1", nil))
+			So(recorder.Code, ShouldEqual, http.StatusGatewayTimeout)
+			So(recorder.Body.String(), ShouldContainSubstring, `"call": "get"`)
+		})
+	})
+}
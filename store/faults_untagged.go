@@ -0,0 +1,7 @@
+//go:build !faults
+// +build !faults
+
+package store
+
+// faultsTagged lets WithFaults wrap storage in binaries built with the faults tag
+const faultsTagged = false
@@ -0,0 +1,370 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/internal/nilness"
+	"golang.org/x/net/context"
+)
+
+// FixtureOption configures Recorder and Replayer
+type FixtureOption func(*fixtures)
+
+// Scrub leaves the attributes named out of the fixtures written to disk, such as
+// passwords or tokens. Replayer must be given the same attributes, as they are
+// left out of the objects fixtures are keyed by as well.
+func Scrub(attributes ...string) FixtureOption {
+	return func(f *fixtures) {
+		for _, attribute := range attributes {
+			f.scrub[attribute] = true
+		}
+	}
+}
+
+/*
+Recorder passes the calls of crud through, writing each call and its result to a
+JSON fixture in dir, to be served by Replayer later on, such as for frontend
+development without a database:
+
+	crud := store.Recorder(db, "testdata/fixtures", store.Scrub("password"))
+
+Fixtures are named after the storage call and a hash of its input, the id of Get
+and Delete or the object of Save and Update, a later identical call overwriting
+the fixture. They are indented with sorted attributes, so that they can be
+committed and diffed. A fixture that can't be written fails the call with a 500,
+although crud was called.
+*/
+func Recorder(crud CRUD, dir string, opts ...FixtureOption) CRUD {
+	return &recorderCRUD{crud: crud, fixtures: newFixtures(dir, opts)}
+}
+
+/*
+Replayer serves storage calls from the fixtures written to dir by Recorder. Calls
+without a fixture fail with a FixtureMissError.
+*/
+func Replayer(dir string, opts ...FixtureOption) CRUD {
+	return &replayerCRUD{fixtures: newFixtures(dir, opts)}
+}
+
+// FixtureMissError is the error of the calls of Replayer without a fixture
+type FixtureMissError struct {
+	// Call is the storage call, such as "get"
+	Call string
+	// Path is the path of the missing fixture
+	Path string
+}
+
+// Error implements error
+func (e *FixtureMissError) Error() string {
+	return e.jshError().Error()
+}
+
+// Validate implements jsh.Sendable
+func (e *FixtureMissError) Validate(r *http.Request, response bool) *jsh.Error {
+	return e.jshError().Validate(r, response)
+}
+
+// StatusCode implements jsh.ErrorType
+func (e *FixtureMissError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+// jshError is the error object sent for the miss
+func (e *FixtureMissError) jshError() *jsh.Error {
+	return &jsh.Error{
+		Title:  jsh.DefaultErrorTitle,
+		Detail: fmt.Sprintf("No fixture is recorded for storage call '%s': %s", e.Call, e.Path),
+		Status: http.StatusInternalServerError,
+	}
+}
+
+// fixture is a storage call and its result, as written to disk
+type fixture struct {
+	Call   string      `json:"call"`
+	ID     string      `json:"id,omitempty"`
+	Input  *jsh.Object `json:"input,omitempty"`
+	Object *jsh.Object `json:"object,omitempty"`
+	List   jsh.List    `json:"list,omitempty"`
+	Error  *jsh.Error  `json:"error,omitempty"`
+}
+
+// fixtures reads and writes the fixtures of a directory
+type fixtures struct {
+	dir   string
+	scrub map[string]bool
+}
+
+func newFixtures(dir string, opts []FixtureOption) *fixtures {
+	f := &fixtures{dir: dir, scrub: map[string]bool{}}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// call returns the fixture of a call, without its result, and the path of its
+// file
+func (f *fixtures) call(call string, id string, input *jsh.Object) (*fixture, string, jsh.ErrorType) {
+	normalized, err := f.normalize(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key, marshalErr := json.Marshal(fixture{Call: call, ID: id, Input: normalized})
+	if marshalErr != nil {
+		return nil, "", jsh.ISE(fmt.Sprintf("Unable to key fixture: %s", marshalErr.Error()))
+	}
+	hash := sha256.Sum256(key)
+
+	name := fmt.Sprintf("%s-%s.json", call, hex.EncodeToString(hash[:8]))
+	return &fixture{Call: call, ID: id, Input: normalized}, filepath.Join(f.dir, name), nil
+}
+
+// write records the result of a call
+func (f *fixtures) write(call string, id string, input *jsh.Object, object *jsh.Object, list jsh.List, callErr jsh.ErrorType) jsh.ErrorType {
+	recorded, path, err := f.call(call, id, input)
+	if err != nil {
+		return err
+	}
+
+	recorded.Object, err = f.normalize(object)
+	if err != nil {
+		return err
+	}
+	for _, listed := range list {
+		normalized, normalizeErr := f.normalize(listed)
+		if normalizeErr != nil {
+			return normalizeErr
+		}
+		recorded.List = append(recorded.List, normalized)
+	}
+	if nilness.HasError(callErr) {
+		recorded.Error = fixtureError(callErr)
+	}
+
+	content, marshalErr := json.MarshalIndent(recorded, "", "  ")
+	if marshalErr == nil {
+		marshalErr = os.MkdirAll(f.dir, 0755)
+	}
+	if marshalErr == nil {
+		marshalErr = ioutil.WriteFile(path, append(content, '\n'), 0644)
+	}
+	if marshalErr != nil {
+		return jsh.ISE(fmt.Sprintf("Unable to record fixture %s: %s", path, marshalErr.Error()))
+	}
+
+	return nil
+}
+
+// read returns the recorded result of a call
+func (f *fixtures) read(call string, id string, input *jsh.Object) (*fixture, jsh.ErrorType) {
+	_, path, err := f.call(call, id, input)
+	if err != nil {
+		return nil, err
+	}
+
+	content, readErr := ioutil.ReadFile(path)
+	if os.IsNotExist(readErr) {
+		return nil, &FixtureMissError{Call: call, Path: path}
+	}
+	if readErr != nil {
+		return nil, jsh.ISE(fmt.Sprintf("Unable to read fixture %s: %s", path, readErr.Error()))
+	}
+
+	recorded := &fixture{}
+	decodeErr := json.Unmarshal(content, recorded)
+	if decodeErr != nil {
+		return nil, jsh.ISE(fmt.Sprintf("Invalid fixture %s: %s", path, decodeErr.Error()))
+	}
+
+	return recorded, nil
+}
+
+// normalize returns a copy of object with its attributes sorted and scrubbed
+func (f *fixtures) normalize(object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	if object == nil {
+		return nil, nil
+	}
+
+	normalized := *object
+	if len(object.Attributes) == 0 {
+		return &normalized, nil
+	}
+
+	attributes := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(object.Attributes))
+	decoder.UseNumber()
+	err := decoder.Decode(&attributes)
+	if err != nil {
+		return nil, jsh.ISE(fmt.Sprintf("Unable to record attributes of '%s': %s", object.Type, err.Error()))
+	}
+
+	for attribute := range f.scrub {
+		delete(attributes, attribute)
+	}
+
+	normalized.Attributes, err = json.Marshal(attributes)
+	if err != nil {
+		return nil, jsh.ISE(fmt.Sprintf("Unable to record attributes of '%s': %s", object.Type, err.Error()))
+	}
+
+	return &normalized, nil
+}
+
+// fixtureError is the error object recorded for a storage error
+func fixtureError(err jsh.ErrorType) *jsh.Error {
+	switch typed := err.(type) {
+	case *jsh.Error:
+		return typed
+	case jsh.ErrorList:
+		if len(typed) > 0 {
+			return typed[0]
+		}
+	}
+
+	return &jsh.Error{Title: http.StatusText(err.StatusCode()), Detail: err.Error(), Status: err.StatusCode()}
+}
+
+// recorderCRUD is the CRUD returned by Recorder
+type recorderCRUD struct {
+	crud     CRUD
+	fixtures *fixtures
+}
+
+func (c *recorderCRUD) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	input, err := c.fixtures.normalize(object)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, saveErr := c.crud.Save(ctx, object)
+	err = c.fixtures.write("save", "", input, saved, nil, saveErr)
+	if err != nil {
+		return nil, err
+	}
+
+	return saved, saveErr
+}
+
+func (c *recorderCRUD) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	object, getErr := c.crud.Get(ctx, id)
+	err := c.fixtures.write("get", id, nil, object, nil, getErr)
+	if err != nil {
+		return nil, err
+	}
+
+	return object, getErr
+}
+
+func (c *recorderCRUD) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	list, listErr := c.crud.List(ctx)
+	err := c.fixtures.write("list", "", nil, nil, list, listErr)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, listErr
+}
+
+func (c *recorderCRUD) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	input, err := c.fixtures.normalize(object)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, updateErr := c.crud.Update(ctx, object)
+	err = c.fixtures.write("update", "", input, updated, nil, updateErr)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, updateErr
+}
+
+func (c *recorderCRUD) Delete(ctx context.Context, id string) jsh.ErrorType {
+	deleteErr := c.crud.Delete(ctx, id)
+	err := c.fixtures.write("delete", id, nil, nil, nil, deleteErr)
+	if err != nil {
+		return err
+	}
+
+	return deleteErr
+}
+
+// replayerCRUD is the CRUD returned by Replayer
+type replayerCRUD struct {
+	fixtures *fixtures
+}
+
+func (c *replayerCRUD) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	recorded, err := c.fixtures.read("save", "", object)
+	if err != nil {
+		return nil, err
+	}
+
+	return recorded.result()
+}
+
+func (c *replayerCRUD) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	recorded, err := c.fixtures.read("get", id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return recorded.result()
+}
+
+func (c *replayerCRUD) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	recorded, err := c.fixtures.read("list", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if recorded.Error != nil {
+		return nil, recorded.Error
+	}
+	if recorded.List == nil {
+		return jsh.List{}, nil
+	}
+
+	return recorded.List, nil
+}
+
+func (c *replayerCRUD) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	recorded, err := c.fixtures.read("update", "", object)
+	if err != nil {
+		return nil, err
+	}
+
+	return recorded.result()
+}
+
+func (c *replayerCRUD) Delete(ctx context.Context, id string) jsh.ErrorType {
+	recorded, err := c.fixtures.read("delete", id, nil)
+	if err != nil {
+		return err
+	}
+	if recorded.Error != nil {
+		return recorded.Error
+	}
+
+	return nil
+}
+
+// result returns the recorded object or error
+func (f *fixture) result() (*jsh.Object, jsh.ErrorType) {
+	if f.Error != nil {
+		return nil, f.Error
+	}
+
+	return f.Object, nil
+}
@@ -0,0 +1,85 @@
+package store_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFixtures(t *testing.T) {
+
+	Convey("Fixtures Tests", t, func() {
+
+		ctx := context.Background()
+		backend := &flakyCRUD{}
+
+		dir, err := ioutil.TempDir("", "fixtures")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		recorder := store.Recorder(backend, dir, store.Scrub("password"))
+		replayer := store.Replayer(dir, store.Scrub("password"))
+
+		Convey("should replay recorded calls", func() {
+			recorded, recordErr := recorder.Get(ctx, "1")
+			So(recordErr, ShouldBeNil)
+
+			replayed, replayErr := replayer.Get(ctx, "1")
+			So(replayErr, ShouldBeNil)
+			So(replayed.ID, ShouldEqual, recorded.ID)
+			So(string(replayed.Attributes), ShouldContainSubstring, `"name": "user"`)
+
+			_, replayErr = replayer.Get(ctx, "missing")
+			So(replayErr.StatusCode(), ShouldEqual, http.StatusInternalServerError)
+			_, isMiss := replayErr.(*store.FixtureMissError)
+			So(isMiss, ShouldBeTrue)
+
+			recorder.Get(ctx, "missing")
+			_, replayErr = replayer.Get(ctx, "missing")
+			So(replayErr.StatusCode(), ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("should key writes by their scrubbed object", func() {
+			object, _ := jsh.NewObject("", "users", map[string]string{"name": "user", "password": "secret"})
+			_, recordErr := recorder.Save(ctx, object)
+			So(recordErr, ShouldBeNil)
+			So(backend.calls, ShouldEqual, 1)
+
+			reordered, _ := jsh.NewObject("", "users", map[string]string{"password": "other", "name": "user"})
+			saved, replayErr := replayer.Save(ctx, reordered)
+			So(replayErr, ShouldBeNil)
+			So(saved.ID, ShouldEqual, "1")
+			So(string(saved.Attributes), ShouldNotContainSubstring, "secret")
+
+			files, _ := filepath.Glob(filepath.Join(dir, "save-*.json"))
+			So(files, ShouldHaveLength, 1)
+
+			content, _ := ioutil.ReadFile(files[0])
+			So(string(content), ShouldNotContainSubstring, "secret")
+			So(string(content), ShouldContainSubstring, "\n  \"call\": \"save\",\n")
+		})
+
+		Convey("should write the same fixtures for the same calls", func() {
+			recorder.List(ctx)
+			files, _ := filepath.Glob(filepath.Join(dir, "list-*.json"))
+			So(files, ShouldHaveLength, 1)
+			first, _ := ioutil.ReadFile(files[0])
+
+			recorder.List(ctx)
+			second, _ := ioutil.ReadFile(files[0])
+			So(string(second), ShouldEqual, string(first))
+
+			list, replayErr := replayer.List(ctx)
+			So(replayErr, ShouldBeNil)
+			So(list, ShouldResemble, jsh.List{})
+		})
+	})
+}
@@ -0,0 +1,233 @@
+/*
+Package memstore provides an in-memory implementation of the store interfaces, for
+tests, examples, and prototypes:
+
+	tasks := memstore.New("tasks")
+	api.Add(jshapi.NewCRUDResource("tasks", tasks))
+*/
+package memstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+/*
+Store implements store.CRUD for a single resource type, it is safe for concurrent
+use. Objects are listed in the order they were saved, and are copied in and out of
+the store so that callers can not modify stored objects.
+*/
+type Store struct {
+	ResourceType string
+	mutex        sync.RWMutex
+	objects      map[string]*jsh.Object
+	// ids records the order in which objects were saved
+	ids    []string
+	lastID int
+	// links are the related ids of each object, by relationship
+	links map[string]map[string][]string
+}
+
+// New creates an empty store for objects of the given resource type
+func New(resourceType string) *Store {
+	return &Store{
+		ResourceType: resourceType,
+		objects:      map[string]*jsh.Object{},
+		links:        map[string]map[string][]string{},
+	}
+}
+
+// Save stores a new object, generating a numeric id unless the client provided one,
+// in which case it must not already be in use
+func (s *Store) Save(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	saved := clone(object)
+	if saved.Type == "" {
+		saved.Type = s.ResourceType
+	}
+
+	if saved.ID == "" {
+		saved.ID = s.nextID()
+	} else if _, exists := s.objects[saved.ID]; exists {
+		conflict := &jsh.Error{
+			Title:  "Conflict",
+			Detail: fmt.Sprintf("A '%s' with id '%s' already exists", s.ResourceType, saved.ID),
+			Status: http.StatusConflict,
+		}
+		conflict.Source.Pointer = "/data/id"
+		return nil, conflict
+	}
+
+	s.objects[saved.ID] = saved
+	s.ids = append(s.ids, saved.ID)
+
+	return clone(saved), nil
+}
+
+// Get returns the object with the given id, or a 404
+func (s *Store) Get(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	object, exists := s.objects[id]
+	if !exists {
+		return nil, jsh.NotFound(s.ResourceType, id)
+	}
+
+	return clone(object), nil
+}
+
+// List returns all objects in the order they were saved
+func (s *Store) List(ctx context.Context) (jsh.List, jsh.ErrorType) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	list := make(jsh.List, 0, len(s.ids))
+	for _, id := range s.ids {
+		list = append(list, clone(s.objects[id]))
+	}
+
+	return list, nil
+}
+
+// Update merges the attributes of the object into the stored one, attributes that
+// are not provided are left unchanged
+func (s *Store) Update(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored, exists := s.objects[object.ID]
+	if !exists {
+		return nil, jsh.NotFound(s.ResourceType, object.ID)
+	}
+
+	attributes := map[string]json.RawMessage{}
+	if len(stored.Attributes) > 0 {
+		err := json.Unmarshal(stored.Attributes, &attributes)
+		if err != nil {
+			return nil, jsh.ISE(fmt.Sprintf("Unable to decode stored attributes: %s", err.Error()))
+		}
+	}
+
+	if len(object.Attributes) > 0 {
+		err := json.Unmarshal(object.Attributes, &attributes)
+		if err != nil {
+			return nil, jsh.ISE(fmt.Sprintf("Unable to decode attributes: %s", err.Error()))
+		}
+	}
+
+	merged, err := json.Marshal(attributes)
+	if err != nil {
+		return nil, jsh.ISE(fmt.Sprintf("Unable to encode attributes: %s", err.Error()))
+	}
+
+	updated := clone(stored)
+	updated.Attributes = merged
+	s.objects[updated.ID] = updated
+
+	return clone(updated), nil
+}
+
+// Delete removes the object with the given id, or returns a 404
+func (s *Store) Delete(ctx context.Context, id string) jsh.ErrorType {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, exists := s.objects[id]
+	if !exists {
+		return jsh.NotFound(s.ResourceType, id)
+	}
+
+	delete(s.objects, id)
+
+	for index, storedID := range s.ids {
+		if storedID == id {
+			s.ids = append(s.ids[:index], s.ids[index+1:]...)
+			break
+		}
+	}
+
+	for _, related := range s.links {
+		delete(related, id)
+	}
+
+	return nil
+}
+
+// Link relates the object with the given id to objects of another store, replacing
+// its previous links for the relationship
+func (s *Store) Link(id string, relationship string, relatedIDs ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	related, exists := s.links[relationship]
+	if !exists {
+		related = map[string][]string{}
+		s.links[relationship] = related
+	}
+
+	related[id] = append([]string{}, relatedIDs...)
+}
+
+/*
+ToMany returns storage for a to-many relationship, listing the objects of the
+target storage linked to an object through Link. Linked objects that no longer
+exist in the target storage are left out:
+
+	resource.ToMany("tags", tasks.ToMany("tags", tags))
+*/
+func (s *Store) ToMany(relationship string, target store.CRUD) store.ToMany {
+	return func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+		s.mutex.RLock()
+		_, exists := s.objects[id]
+		relatedIDs := s.links[relationship][id]
+		s.mutex.RUnlock()
+
+		if !exists {
+			return nil, jsh.NotFound(s.ResourceType, id)
+		}
+
+		list := jsh.List{}
+		for _, relatedID := range relatedIDs {
+			object, err := target.Get(ctx, relatedID)
+			switch {
+			case object != nil:
+				list = append(list, object)
+			case err != nil && err.StatusCode() != http.StatusNotFound:
+				return nil, err
+			}
+		}
+
+		return list, nil
+	}
+}
+
+// nextID generates an id that is not in use
+func (s *Store) nextID() string {
+	for {
+		s.lastID++
+
+		id := strconv.Itoa(s.lastID)
+		if _, exists := s.objects[id]; !exists {
+			return id
+		}
+	}
+}
+
+// clone copies an object along with its attributes
+func clone(object *jsh.Object) *jsh.Object {
+	copied := *object
+	copied.Attributes = append(json.RawMessage(nil), object.Attributes...)
+
+	return &copied
+}
@@ -0,0 +1,136 @@
+package memstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+	"github.com/derekdowling/jsh-api/store/storetest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCRUDContract(t *testing.T) {
+	storetest.RunCRUDContract(t, func() store.CRUD {
+		return New(storetest.ResourceType)
+	})
+}
+
+func TestStore(t *testing.T) {
+
+	Convey("Store Tests", t, func() {
+
+		ctx := context.Background()
+		tasks := New("tasks")
+
+		first, err := tasks.Save(ctx, &jsh.Object{Type: "tasks", Attributes: json.RawMessage(`{"title":"a","done":false}`)})
+		So(err, ShouldBeNil)
+
+		Convey("->Save()", func() {
+
+			Convey("should generate ids", func() {
+				So(first.ID, ShouldEqual, "1")
+
+				second, err := tasks.Save(ctx, &jsh.Object{Type: "tasks"})
+				So(err, ShouldBeNil)
+				So(second.ID, ShouldEqual, "2")
+			})
+
+			Convey("should keep client ids and reject duplicates", func() {
+				object, err := tasks.Save(ctx, &jsh.Object{Type: "tasks", ID: "2"})
+				So(err, ShouldBeNil)
+				So(object.ID, ShouldEqual, "2")
+
+				_, err = tasks.Save(ctx, &jsh.Object{Type: "tasks", ID: "2"})
+				So(err.StatusCode(), ShouldEqual, http.StatusConflict)
+
+				generated, _ := tasks.Save(ctx, &jsh.Object{Type: "tasks"})
+				So(generated.ID, ShouldEqual, "3")
+			})
+
+			Convey("should be safe for concurrent use", func() {
+				var wg sync.WaitGroup
+				for i := 0; i < 50; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						tasks.Save(ctx, &jsh.Object{Type: "tasks"})
+					}()
+				}
+				wg.Wait()
+
+				list, _ := tasks.List(ctx)
+				So(list, ShouldHaveLength, 51)
+				So(list[50].ID, ShouldEqual, strconv.Itoa(51))
+			})
+		})
+
+		Convey("->Get()", func() {
+
+			Convey("should return copies", func() {
+				object, err := tasks.Get(ctx, "1")
+				So(err, ShouldBeNil)
+
+				object.Attributes[2] = 'X'
+				again, _ := tasks.Get(ctx, "1")
+				So(string(again.Attributes), ShouldEqual, `{"title":"a","done":false}`)
+			})
+
+			Convey("should return a 404 for missing objects", func() {
+				_, err := tasks.Get(ctx, "9")
+				So(err.StatusCode(), ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("->Update()", func() {
+
+			Convey("should merge attributes", func() {
+				updated, err := tasks.Update(ctx, &jsh.Object{Type: "tasks", ID: "1", Attributes: json.RawMessage(`{"done":true}`)})
+				So(err, ShouldBeNil)
+				So(string(updated.Attributes), ShouldEqual, `{"done":true,"title":"a"}`)
+			})
+
+			Convey("should return a 404 for missing objects", func() {
+				_, err := tasks.Update(ctx, &jsh.Object{Type: "tasks", ID: "9"})
+				So(err.StatusCode(), ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("->Delete()", func() {
+			So(tasks.Delete(ctx, "1"), ShouldBeNil)
+
+			_, err := tasks.Get(ctx, "1")
+			So(err.StatusCode(), ShouldEqual, http.StatusNotFound)
+
+			list, _ := tasks.List(ctx)
+			So(list, ShouldBeEmpty)
+
+			So(tasks.Delete(ctx, "1").StatusCode(), ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("->ToMany()", func() {
+			tags := New("tags")
+			tag, _ := tags.Save(ctx, &jsh.Object{Type: "tags"})
+			tags.Save(ctx, &jsh.Object{Type: "tags"})
+
+			related := tasks.ToMany("tags", tags)
+
+			list, err := related(ctx, first.ID)
+			So(err, ShouldBeNil)
+			So(list, ShouldBeEmpty)
+
+			tasks.Link(first.ID, "tags", tag.ID, "9")
+			list, _ = related(ctx, first.ID)
+			So(list, ShouldHaveLength, 1)
+			So(list[0].ID, ShouldEqual, tag.ID)
+
+			_, err = related(ctx, "9")
+			So(err.StatusCode(), ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
@@ -0,0 +1,271 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"golang.org/x/net/context"
+)
+
+/*
+WithSingleflight collapses the concurrent calls of get for the same id into a
+single storage call, whose result is shared by every caller:
+
+	config := jshapi.NewResource("config")
+	config.Get(store.WithSingleflight(db.GetConfig))
+
+Each caller gets its own deep copy of the object, so that the links, fieldsets and
+other changes made to one response don't show in another. Errors are returned to
+every caller. The shared call is made with a context carrying the values of the
+first caller's, but neither its deadline nor its cancellation: a caller whose
+context ends stops waiting without aborting the call for the others.
+
+Calls are keyed by id alone: get must not be wrapped when its objects depend on
+the caller, such as on the tenant.
+*/
+func WithSingleflight(get Get) Get {
+	group := &flightGroup{}
+
+	return func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+		result, err := group.do(ctx, id, func(shared context.Context) flightResult {
+			object, err := get(shared, id)
+			return flightResult{object: object, err: err}
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return copyObject(result.object), result.err
+	}
+}
+
+/*
+WithSingleflightList collapses the concurrent calls of list with the same key into
+a single storage call, like WithSingleflight. key returns the key of the list
+requested with ctx, which must tell apart every list storage may return, such as
+the canonicalized query parameters and tenant it reads from ctx:
+
+	posts.List(store.WithSingleflightList(db.ListPosts, func(ctx context.Context) string {
+		return tenantFromContext(ctx) + "?" + filtersFromContext(ctx).Encode()
+	}))
+*/
+func WithSingleflightList(list List, key func(ctx context.Context) string) List {
+	group := &flightGroup{}
+
+	return func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+		result, err := group.do(ctx, key(ctx), func(shared context.Context) flightResult {
+			listed, err := list(shared)
+			return flightResult{list: listed, err: err}
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return copyList(result.list), result.err
+	}
+}
+
+// flightResult is the result of a shared storage call
+type flightResult struct {
+	object *jsh.Object
+	list   jsh.List
+	err    jsh.ErrorType
+}
+
+// flight is a storage call in progress, done is closed once result is set
+type flight struct {
+	done   chan struct{}
+	result flightResult
+}
+
+// flightGroup tracks the storage calls in progress by key
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+/*
+do makes call for key unless a call for key is in progress, and waits for its
+result. It returns an error without waiting for the result when ctx ends first,
+the call completing for the other callers.
+*/
+func (g *flightGroup) do(ctx context.Context, key string, call func(context.Context) flightResult) (flightResult, jsh.ErrorType) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = map[string]*flight{}
+	}
+
+	current, inProgress := g.flights[key]
+	if !inProgress {
+		current = &flight{done: make(chan struct{})}
+		g.flights[key] = current
+
+		go g.run(detachedContext{ctx}, key, current, call)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-current.done:
+		return current.result, nil
+	case <-ctx.Done():
+		return flightResult{}, jsh.ISE(fmt.Sprintf("Stopped waiting for storage: %s", ctx.Err()))
+	}
+}
+
+// run makes the call of a flight, reporting panics as errors as they would
+// otherwise crash the server outside of the request goroutine
+func (g *flightGroup) run(ctx context.Context, key string, current *flight, call func(context.Context) flightResult) {
+	defer func() {
+		recovered := recover()
+		if recovered != nil {
+			current.result = flightResult{err: jsh.ISE(fmt.Sprintf("Storage panicked: %v", recovered))}
+		}
+
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+
+		close(current.done)
+	}()
+
+	current.result = call(ctx)
+}
+
+// detachedContext carries the values of a context without its deadline and
+// cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// copyList returns a deep copy of list
+func copyList(list jsh.List) jsh.List {
+	if list == nil {
+		return nil
+	}
+
+	copied := make(jsh.List, len(list))
+	for index, object := range list {
+		copied[index] = copyObject(object)
+	}
+
+	return copied
+}
+
+// copyObject returns a deep copy of object
+func copyObject(object *jsh.Object) *jsh.Object {
+	if object == nil {
+		return nil
+	}
+
+	copied := *object
+	if object.Attributes != nil {
+		copied.Attributes = append(object.Attributes[:0:0], object.Attributes...)
+	}
+	copied.Links = copyLinks(object.Links)
+
+	if object.Relationships != nil {
+		copied.Relationships = make(map[string]*jsh.Relationship, len(object.Relationships))
+		for name, relationship := range object.Relationships {
+			copied.Relationships[name] = copyRelationship(relationship)
+		}
+	}
+
+	return &copied
+}
+
+// copyRelationship returns a deep copy of relationship
+func copyRelationship(relationship *jsh.Relationship) *jsh.Relationship {
+	if relationship == nil {
+		return nil
+	}
+
+	copied := &jsh.Relationship{Meta: copyMeta(relationship.Meta)}
+	if relationship.Links != nil {
+		links := *relationship.Links
+		links.Self = copyLink(links.Self)
+		links.Related = copyLink(links.Related)
+		copied.Links = &links
+	}
+	if relationship.Data != nil {
+		copied.Data = make(jsh.ResourceLinkage, len(relationship.Data))
+		for index, identifier := range relationship.Data {
+			if identifier != nil {
+				copiedIdentifier := *identifier
+				copied.Data[index] = &copiedIdentifier
+			}
+		}
+	}
+
+	return copied
+}
+
+// copyLinks returns a deep copy of links
+func copyLinks(links map[string]*jsh.Link) map[string]*jsh.Link {
+	if links == nil {
+		return nil
+	}
+
+	copied := make(map[string]*jsh.Link, len(links))
+	for name, link := range links {
+		copied[name] = copyLink(link)
+	}
+
+	return copied
+}
+
+// copyLink returns a deep copy of link
+func copyLink(link *jsh.Link) *jsh.Link {
+	if link == nil {
+		return nil
+	}
+
+	return &jsh.Link{HREF: link.HREF, Meta: copyMeta(link.Meta)}
+}
+
+// copyMeta returns a deep copy of a meta object, copying the nested objects and
+// arrays it holds
+func copyMeta(meta map[string]interface{}) map[string]interface{} {
+	if meta == nil {
+		return nil
+	}
+
+	copied := make(map[string]interface{}, len(meta))
+	for name, value := range meta {
+		copied[name] = copyMetaValue(value)
+	}
+
+	return copied
+}
+
+func copyMetaValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		return copyMeta(typed)
+	case []interface{}:
+		copied := make([]interface{}, len(typed))
+		for index, element := range typed {
+			copied[index] = copyMetaValue(element)
+		}
+		return copied
+	default:
+		return value
+	}
+}
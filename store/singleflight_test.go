@@ -0,0 +1,135 @@
+package store_test
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// filterKey holds the filter of the lists of the singleflight tests
+type filterKey struct{}
+
+func TestSingleflight(t *testing.T) {
+
+	Convey("Singleflight Tests", t, func() {
+
+		ctx := context.Background()
+
+		var calls int32
+		release := make(chan struct{})
+		started := make(chan context.Context, 100)
+
+		get := store.WithSingleflight(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			atomic.AddInt32(&calls, 1)
+			started <- ctx
+			<-release
+
+			if id == "missing" {
+				return nil, jsh.NotFound("config", id)
+			}
+			object, _ := jsh.NewObject(id, "config", map[string]string{"name": "config" + id})
+			object.Links = map[string]*jsh.Link{"self": {HREF: "/config/" + id}}
+			return object, nil
+		})
+
+		// callConcurrently makes count calls of get for id, once the first is waiting
+		// on storage
+		callConcurrently := func(ctx context.Context, id string, count int) ([]*jsh.Object, []jsh.ErrorType) {
+			objects := make([]*jsh.Object, count)
+			errs := make([]jsh.ErrorType, count)
+
+			var wait sync.WaitGroup
+			for index := 0; index < count; index++ {
+				wait.Add(1)
+				go func(index int) {
+					defer wait.Done()
+					objects[index], errs[index] = get(ctx, id)
+				}(index)
+
+				if index == 0 {
+					<-started
+				}
+			}
+
+			// let the other calls join the first
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			wait.Wait()
+			return objects, errs
+		}
+
+		Convey("should make a single storage call for concurrent calls", func() {
+			objects, errs := callConcurrently(ctx, "1", 20)
+
+			So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+			for index := range objects {
+				So(errs[index], ShouldBeNil)
+				So(objects[index].ID, ShouldEqual, "1")
+			}
+		})
+
+		Convey("should give each caller its own copy", func() {
+			objects, _ := callConcurrently(ctx, "1", 2)
+
+			So(objects[0], ShouldNotPointTo, objects[1])
+			objects[0].Links["self"].HREF = "/changed"
+			objects[0].Attributes[0] = ' '
+			So(objects[1].Links["self"].HREF, ShouldEqual, "/config/1")
+			So(string(objects[1].Attributes), ShouldContainSubstring, "config1")
+		})
+
+		Convey("should return errors to every caller", func() {
+			_, errs := callConcurrently(ctx, "missing", 5)
+
+			for _, err := range errs {
+				So(err.StatusCode(), ShouldEqual, http.StatusNotFound)
+			}
+		})
+
+		Convey("should not cancel the shared call when a caller gives up", func() {
+			canceled, cancel := context.WithCancel(ctx)
+
+			abandoned := make(chan jsh.ErrorType)
+			go func() {
+				_, err := get(canceled, "1")
+				abandoned <- err
+			}()
+			shared := <-started
+
+			waiting := make(chan *jsh.Object)
+			go func() {
+				object, _ := get(ctx, "1")
+				waiting <- object
+			}()
+
+			cancel()
+			So(<-abandoned, ShouldNotBeNil)
+			So(shared.Err(), ShouldBeNil)
+
+			close(release)
+			So((<-waiting).ID, ShouldEqual, "1")
+		})
+
+		Convey("should key lists", func() {
+			list := store.WithSingleflightList(func(ctx context.Context) (jsh.List, jsh.ErrorType) {
+				object, _ := jsh.NewObject(ctx.Value(filterKey{}).(string), "config", nil)
+				return jsh.List{object}, nil
+			}, func(ctx context.Context) string {
+				return ctx.Value(filterKey{}).(string)
+			})
+
+			first, _ := list(context.WithValue(ctx, filterKey{}, "a"))
+			second, _ := list(context.WithValue(ctx, filterKey{}, "b"))
+			So(first[0].ID, ShouldEqual, "a")
+			So(second[0].ID, ShouldEqual, "b")
+		})
+	})
+}
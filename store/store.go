@@ -16,21 +16,111 @@ type CRUD interface {
 	Delete(ctx context.Context, id string) jsh.ErrorType
 }
 
+/*
+ScopedCRUD implements the storage functions of a resource nested under parent
+resources, such as tasks under /projects/:project_id/tasks. parentIDs holds the
+ids of the parents in the request path, outermost first.
+*/
+type ScopedCRUD interface {
+	Save(ctx context.Context, parentIDs []string, object *jsh.Object) (*jsh.Object, jsh.ErrorType)
+	Get(ctx context.Context, parentIDs []string, id string) (*jsh.Object, jsh.ErrorType)
+	List(ctx context.Context, parentIDs []string) (jsh.List, jsh.ErrorType)
+	Update(ctx context.Context, parentIDs []string, object *jsh.Object) (*jsh.Object, jsh.ErrorType)
+	Delete(ctx context.Context, parentIDs []string, id string) jsh.ErrorType
+}
+
+/*
+DryRunner can be implemented by CRUD storage to check the writes of dry runs,
+such as uniqueness constraints, without persisting them. Each method returns the
+object as it would have been saved or updated.
+*/
+type DryRunner interface {
+	DryRunSave(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType)
+	DryRunUpdate(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType)
+}
+
+/*
+Transactional is implemented by storage that can group several storage calls into
+a single atomic unit of work. Begin returns a context carrying the transaction,
+which is then passed to every storage call made as part of it.
+*/
+type Transactional interface {
+	Begin(ctx context.Context) (context.Context, jsh.ErrorType)
+	Commit(ctx context.Context) jsh.ErrorType
+	Rollback(ctx context.Context) jsh.ErrorType
+}
+
+/*
+Watcher can be implemented by storage whose objects carry a version, such as the
+ETag of their representation, to support long polling. Wait blocks until the
+version of the object identified by id differs from version, returning nil, or
+until ctx is done, returning its error.
+*/
+type Watcher interface {
+	Wait(ctx context.Context, id string, version string) error
+}
+
 // Save a new resource to storage
 type Save func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType)
 
 // Get a specific instance of a resource by id from storage
 type Get func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType)
 
+// GetIncluded gets a specific instance of a resource by id from storage, along with
+// the related objects loaded by the same query, such as with a join, for compound
+// documents
+type GetIncluded func(ctx context.Context, id string) (*jsh.Object, jsh.List, jsh.ErrorType)
+
+// CompositeGet gets a specific instance of a resource keyed by several values from
+// storage, key maps the names of the composite id to their values
+type CompositeGet func(ctx context.Context, key map[string]string) (*jsh.Object, jsh.ErrorType)
+
+// GetMany gets the instances of a resource identified by ids from storage in a
+// single call, in any order, leaving out those that don't exist
+type GetMany func(ctx context.Context, ids []string) (jsh.List, jsh.ErrorType)
+
 // List all instances of a resource from storage
 type List func(ctx context.Context) (jsh.List, jsh.ErrorType)
 
+// ListIncluded lists all instances of a resource from storage, along with the
+// related objects loaded by the same query, see GetIncluded
+type ListIncluded func(ctx context.Context) (jsh.List, jsh.List, jsh.ErrorType)
+
 // Update an existing object in storage
 type Update func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType)
 
 // Delete an object from storage by id
 type Delete func(ctx context.Context, id string) jsh.ErrorType
 
+// DeleteWithMeta deletes an object from storage by id, returning meta information
+// about the deletion, such as when it will be purged, or nil if there is none
+type DeleteWithMeta func(ctx context.Context, id string) (map[string]interface{}, jsh.ErrorType)
+
 // ToMany retrieves a list of objects of a single resource type that are related to
 // the provided resource id
 type ToMany func(ctx context.Context, id string) (jsh.List, jsh.ErrorType)
+
+// Count returns the number of objects related to the provided resource id, for
+// relationships too large to be listed
+type Count func(ctx context.Context, id string) (int, jsh.ErrorType)
+
+// SaveList saves a batch of new resources to storage in a single call. Storage is
+// expected to treat the batch as all-or-nothing, and to return the created objects
+// in the order they were received.
+type SaveList func(ctx context.Context, list jsh.List) (jsh.List, jsh.ErrorType)
+
+// UpdateList updates a batch of existing resources in storage in a single call, and
+// returns the updated objects in the order they were received.
+type UpdateList func(ctx context.Context, list jsh.List) (jsh.List, jsh.ErrorType)
+
+// ListStream lists all resources in storage one at a time, passing each object to
+// emit as soon as it is loaded, and stops as soon as emit returns an error
+type ListStream func(ctx context.Context, emit func(object *jsh.Object) jsh.ErrorType) jsh.ErrorType
+
+// DeleteMany deletes a batch of objects from storage by id, returning the number
+// of objects deleted
+type DeleteMany func(ctx context.Context, ids []string) (int64, jsh.ErrorType)
+
+// DeleteMatching deletes the objects matching every filter, by name, returning the
+// number of objects deleted. filter is never empty.
+type DeleteMatching func(ctx context.Context, filter map[string]string) (int64, jsh.ErrorType)
@@ -0,0 +1,465 @@
+/*
+Package storetest runs a shared contract against storage implementations, proving
+that they all behave the way jshapi resources expect:
+
+	func TestCRUDContract(t *testing.T) {
+		storetest.RunCRUDContract(t, func() store.CRUD {
+			return memstore.New(storetest.ResourceType)
+		})
+	}
+
+Sub-contracts for optional storage features run when the storage returned by the
+factory implements them, and are skipped otherwise.
+*/
+package storetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+// ResourceType is the type of the objects stored by the contract
+const ResourceType = "items"
+
+// MissingID is used as the id of an object that does not exist
+const MissingID = "storetest-missing"
+
+const (
+	// concurrentWriters is the number of goroutines saving objects at once
+	concurrentWriters = 8
+	// concurrentSaves is the number of objects saved by each goroutine
+	concurrentSaves = 10
+)
+
+/*
+Linker is implemented by storage supporting to-many relationships, it enables the
+ToMany sub-contract. Link replaces the objects related to an object through a
+relationship, and ToMany lists the related objects found in the target storage.
+*/
+type Linker interface {
+	Link(id string, relationship string, relatedIDs ...string)
+	ToMany(relationship string, target store.CRUD) store.ToMany
+}
+
+// contract is a single check of the contract
+type contract struct {
+	name string
+	run  func(c *contractT)
+}
+
+var crudContracts = []contract{
+	{"create", checkCreate},
+	{"read", checkRead},
+	{"update", checkUpdate},
+	{"delete", checkDelete},
+	{"not-found", checkNotFound},
+	{"id-stability", checkIDStability},
+	{"list-after-create", checkListAfterCreate},
+	{"lifecycle", checkLifecycle},
+	{"concurrent-saves", checkConcurrentSaves},
+	{"to-many", checkToMany},
+}
+
+/*
+RunCRUDContract runs every check of the contract as a subtest. factory must return
+new, empty storage for objects of ResourceType on each call.
+*/
+func RunCRUDContract(t *testing.T, factory func() store.CRUD) {
+	for _, c := range crudContracts {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.run(newContractT(t, factory))
+		})
+	}
+}
+
+// contractT holds the state of a single check
+type contractT struct {
+	t       testing.TB
+	ctx     context.Context
+	factory func() store.CRUD
+	storage store.CRUD
+	// count numbers the objects built by the check
+	count int
+}
+
+func newContractT(t testing.TB, factory func() store.CRUD) *contractT {
+	return &contractT{
+		t:       t,
+		ctx:     context.Background(),
+		factory: factory,
+		storage: factory(),
+	}
+}
+
+// object builds a new object with distinct attributes
+func (c *contractT) object() *jsh.Object {
+	c.count++
+	return newObject(c.t, map[string]interface{}{
+		"name":  fmt.Sprintf("item %d", c.count),
+		"count": c.count,
+	})
+}
+
+// newObject builds an object of ResourceType without id
+func newObject(t testing.TB, attributes map[string]interface{}) *jsh.Object {
+	t.Helper()
+
+	object, err := jsh.NewObject("", ResourceType, attributes)
+	if err != nil {
+		t.Fatalf("storetest: unable to build object: %s", err.Error())
+	}
+
+	return object
+}
+
+// save stores an object, failing the check on error
+func (c *contractT) save(object *jsh.Object) *jsh.Object {
+	c.t.Helper()
+
+	saved, err := c.storage.Save(c.ctx, object)
+	if jshapi.HasError(err) {
+		c.t.Fatalf("Save: unexpected error: %s", err.Error())
+	}
+	if saved == nil || saved.ID == "" {
+		c.t.Fatalf("Save: expected the saved object with a generated id, got %+v", saved)
+	}
+
+	return saved
+}
+
+// get fetches an object, failing the check on error
+func (c *contractT) get(id string) *jsh.Object {
+	c.t.Helper()
+
+	object, err := c.storage.Get(c.ctx, id)
+	if jshapi.HasError(err) {
+		c.t.Fatalf("Get(%q): unexpected error: %s", id, err.Error())
+	}
+	if object == nil {
+		c.t.Fatalf("Get(%q): expected an object, got nil", id)
+	}
+
+	return object
+}
+
+// ids lists the ids of the stored objects, failing the check on error
+func (c *contractT) ids() map[string]bool {
+	c.t.Helper()
+
+	list, err := c.storage.List(c.ctx)
+	if jshapi.HasError(err) {
+		c.t.Fatalf("List: unexpected error: %s", err.Error())
+	}
+
+	ids := map[string]bool{}
+	for _, object := range list {
+		ids[object.ID] = true
+	}
+
+	return ids
+}
+
+// expectAttributes checks that an object holds the attributes of another
+func (c *contractT) expectAttributes(call string, got *jsh.Object, want *jsh.Object) {
+	c.t.Helper()
+
+	if !sameAttributes(got.Attributes, want.Attributes) {
+		c.t.Errorf("%s: expected attributes %s, got %s", call, want.Attributes, got.Attributes)
+	}
+}
+
+// expectNotFound checks that a storage call failed with a 404
+func (c *contractT) expectNotFound(call string, err jsh.ErrorType) {
+	c.t.Helper()
+
+	if !jshapi.HasError(err) {
+		c.t.Errorf("%s: expected a 404 error, got none", call)
+		return
+	}
+
+	if err.StatusCode() != http.StatusNotFound {
+		c.t.Errorf("%s: expected a 404 error, got %d: %s", call, err.StatusCode(), err.Error())
+	}
+}
+
+func checkCreate(c *contractT) {
+	object := c.object()
+	saved := c.save(object)
+
+	if saved.Type != ResourceType {
+		c.t.Errorf("Save: expected type %q, got %q", ResourceType, saved.Type)
+	}
+	c.expectAttributes("Save", saved, object)
+
+	other := c.save(c.object())
+	if other.ID == saved.ID {
+		c.t.Errorf("Save: expected distinct ids, got %q twice", saved.ID)
+	}
+}
+
+func checkRead(c *contractT) {
+	object := c.object()
+	saved := c.save(object)
+
+	fetched := c.get(saved.ID)
+	if fetched.ID != saved.ID || fetched.Type != ResourceType {
+		c.t.Errorf("Get(%q): expected %s/%s, got %s/%s", saved.ID, ResourceType, saved.ID, fetched.Type, fetched.ID)
+	}
+	c.expectAttributes("Get", fetched, object)
+}
+
+func checkUpdate(c *contractT) {
+	saved := c.save(c.object())
+
+	changes := c.object()
+	changes.ID = saved.ID
+
+	updated, err := c.storage.Update(c.ctx, changes)
+	if jshapi.HasError(err) {
+		c.t.Fatalf("Update(%q): unexpected error: %s", saved.ID, err.Error())
+	}
+	if updated == nil || updated.ID != saved.ID {
+		c.t.Fatalf("Update(%q): expected the updated object, got %+v", saved.ID, updated)
+	}
+	c.expectAttributes("Update", updated, changes)
+
+	c.expectAttributes("Get after Update", c.get(saved.ID), changes)
+}
+
+func checkDelete(c *contractT) {
+	saved := c.save(c.object())
+	kept := c.save(c.object())
+
+	err := c.storage.Delete(c.ctx, saved.ID)
+	if jshapi.HasError(err) {
+		c.t.Fatalf("Delete(%q): unexpected error: %s", saved.ID, err.Error())
+	}
+
+	_, err = c.storage.Get(c.ctx, saved.ID)
+	c.expectNotFound(fmt.Sprintf("Get(%q) after Delete", saved.ID), err)
+
+	ids := c.ids()
+	if ids[saved.ID] {
+		c.t.Errorf("List: deleted object %q is still listed", saved.ID)
+	}
+	if !ids[kept.ID] {
+		c.t.Errorf("List: object %q is missing after deleting another", kept.ID)
+	}
+
+	c.expectNotFound(fmt.Sprintf("Delete(%q) twice", saved.ID), c.storage.Delete(c.ctx, saved.ID))
+}
+
+func checkNotFound(c *contractT) {
+	_, err := c.storage.Get(c.ctx, MissingID)
+	c.expectNotFound("Get", err)
+
+	missing := c.object()
+	missing.ID = MissingID
+	_, err = c.storage.Update(c.ctx, missing)
+	c.expectNotFound("Update", err)
+
+	c.expectNotFound("Delete", c.storage.Delete(c.ctx, MissingID))
+}
+
+func checkIDStability(c *contractT) {
+	saved := c.save(c.object())
+
+	for i := 0; i < 2; i++ {
+		if fetched := c.get(saved.ID); fetched.ID != saved.ID {
+			c.t.Errorf("Get(%q): id changed to %q", saved.ID, fetched.ID)
+		}
+	}
+
+	changes := c.object()
+	changes.ID = saved.ID
+	updated, err := c.storage.Update(c.ctx, changes)
+	if !jshapi.HasError(err) && updated != nil && updated.ID != saved.ID {
+		c.t.Errorf("Update(%q): id changed to %q", saved.ID, updated.ID)
+	}
+
+	c.save(c.object())
+	if !c.ids()[saved.ID] {
+		c.t.Errorf("List: object %q is missing after further saves", saved.ID)
+	}
+}
+
+func checkListAfterCreate(c *contractT) {
+	if ids := c.ids(); len(ids) != 0 {
+		c.t.Fatalf("List: expected new storage to be empty, got %d objects", len(ids))
+	}
+
+	saved := []string{}
+	for i := 0; i < 3; i++ {
+		saved = append(saved, c.save(c.object()).ID)
+
+		ids := c.ids()
+		for _, id := range saved {
+			if !ids[id] {
+				c.t.Errorf("List: saved object %q is not listed", id)
+			}
+		}
+	}
+}
+
+// checkLifecycle runs create, read, update, and delete in order on one object
+func checkLifecycle(c *contractT) {
+	object := c.object()
+	saved := c.save(object)
+	c.expectAttributes("Get after Save", c.get(saved.ID), object)
+
+	changes := c.object()
+	changes.ID = saved.ID
+	_, err := c.storage.Update(c.ctx, changes)
+	if jshapi.HasError(err) {
+		c.t.Fatalf("Update(%q): unexpected error: %s", saved.ID, err.Error())
+	}
+	c.expectAttributes("Get after Update", c.get(saved.ID), changes)
+
+	err = c.storage.Delete(c.ctx, saved.ID)
+	if jshapi.HasError(err) {
+		c.t.Fatalf("Delete(%q): unexpected error: %s", saved.ID, err.Error())
+	}
+
+	_, err = c.storage.Get(c.ctx, saved.ID)
+	c.expectNotFound("Get after Delete", err)
+
+	changes.ID = saved.ID
+	_, err = c.storage.Update(c.ctx, changes)
+	c.expectNotFound("Update after Delete", err)
+}
+
+func checkConcurrentSaves(c *contractT) {
+	var mutex sync.Mutex
+	saved := map[string]*jsh.Object{}
+	errs := []string{}
+
+	var wg sync.WaitGroup
+	for writer := 0; writer < concurrentWriters; writer++ {
+		wg.Add(1)
+
+		go func(writer int) {
+			defer wg.Done()
+
+			for i := 0; i < concurrentSaves; i++ {
+				object := newObject(c.t, map[string]interface{}{
+					"name": fmt.Sprintf("writer %d item %d", writer, i),
+				})
+
+				created, err := c.storage.Save(c.ctx, object)
+
+				mutex.Lock()
+				switch {
+				case jshapi.HasError(err):
+					errs = append(errs, fmt.Sprintf("Save: unexpected error: %s", err.Error()))
+				case created == nil || created.ID == "":
+					errs = append(errs, "Save: expected the saved object with a generated id")
+				case saved[created.ID] != nil:
+					errs = append(errs, fmt.Sprintf("Save: id %q was generated twice", created.ID))
+				default:
+					saved[created.ID] = object
+				}
+				mutex.Unlock()
+			}
+		}(writer)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		c.t.Error(err)
+	}
+
+	ids := c.ids()
+	for id, object := range saved {
+		if !ids[id] {
+			c.t.Errorf("List: concurrently saved object %q is not listed", id)
+			continue
+		}
+
+		c.expectAttributes(fmt.Sprintf("Get(%q)", id), c.get(id), object)
+	}
+}
+
+func checkToMany(c *contractT) {
+	linker, implemented := c.storage.(Linker)
+	if !implemented {
+		c.t.Skipf("storage %T does not implement storetest.Linker", c.storage)
+	}
+
+	target := c.factory()
+	targetT := &contractT{t: c.t, ctx: c.ctx, storage: target, count: 100}
+	first := targetT.save(targetT.object())
+	second := targetT.save(targetT.object())
+
+	parent := c.save(c.object())
+	unlinked := c.save(c.object())
+
+	toMany := linker.ToMany("related", target)
+
+	linker.Link(parent.ID, "related", first.ID, second.ID)
+	c.expectRelated(toMany, parent.ID, first.ID, second.ID)
+	c.expectRelated(toMany, unlinked.ID)
+
+	linker.Link(parent.ID, "related", second.ID)
+	c.expectRelated(toMany, parent.ID, second.ID)
+
+	linker.Link(parent.ID, "related", first.ID, second.ID)
+	err := target.Delete(c.ctx, first.ID)
+	if jshapi.HasError(err) {
+		c.t.Fatalf("Delete(%q): unexpected error: %s", first.ID, err.Error())
+	}
+	c.expectRelated(toMany, parent.ID, second.ID)
+
+	_, err = toMany(c.ctx, MissingID)
+	c.expectNotFound("ToMany", err)
+}
+
+// expectRelated checks the ids listed by a ToMany storage function
+func (c *contractT) expectRelated(toMany store.ToMany, id string, expected ...string) {
+	c.t.Helper()
+
+	list, err := toMany(c.ctx, id)
+	if jshapi.HasError(err) {
+		c.t.Fatalf("ToMany(%q): unexpected error: %s", id, err.Error())
+	}
+	if list == nil {
+		c.t.Errorf("ToMany(%q): expected an empty list rather than nil", id)
+	}
+
+	got := []string{}
+	for _, object := range list {
+		got = append(got, object.ID)
+	}
+
+	if !reflect.DeepEqual(sorted(got), sorted(expected)) {
+		c.t.Errorf("ToMany(%q): expected ids %v, got %v", id, expected, got)
+	}
+}
+
+// sameAttributes compares two attribute documents regardless of formatting
+func sameAttributes(a json.RawMessage, b json.RawMessage) bool {
+	var decodedA, decodedB interface{}
+	if json.Unmarshal(a, &decodedA) != nil || json.Unmarshal(b, &decodedB) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(decodedA, decodedB)
+}
+
+// sorted returns a sorted copy of ids
+func sorted(ids []string) []string {
+	copied := append([]string{}, ids...)
+	sort.Strings(copied)
+	return copied
+}
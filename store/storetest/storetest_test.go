@@ -0,0 +1,119 @@
+package storetest
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+	"github.com/derekdowling/jsh-api/store/memstore"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recorder stands in for *testing.T to capture the failures of a check
+type recorder struct {
+	testing.TB
+	errors  []string
+	skipped string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Error(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+func (r *recorder) Skipf(format string, args ...interface{}) {
+	r.skipped = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// runCheck runs a single check against the storage built by factory
+func runCheck(factory func() store.CRUD, run func(c *contractT)) *recorder {
+	t := &recorder{}
+	done := make(chan bool)
+
+	go func() {
+		defer close(done)
+		run(newContractT(t, factory))
+	}()
+
+	<-done
+	return t
+}
+
+// softDelete keeps deleted objects readable, breaking the delete contract
+type softDelete struct {
+	*memstore.Store
+}
+
+func (s *softDelete) Delete(ctx context.Context, id string) jsh.ErrorType {
+	return nil
+}
+
+// crudOnly hides the Linker methods of a memstore
+type crudOnly struct {
+	store.CRUD
+}
+
+// newMemstore builds storage passing the contract
+func newMemstore() store.CRUD {
+	return memstore.New(ResourceType)
+}
+
+func TestRunCRUDContract(t *testing.T) {
+
+	Convey("RunCRUDContract Tests", t, func() {
+
+		Convey("should pass every check with a memstore", func() {
+			for _, c := range crudContracts {
+				result := runCheck(newMemstore, c.run)
+				So(result.errors, ShouldBeEmpty)
+				So(result.skipped, ShouldBeEmpty)
+			}
+		})
+
+		Convey("should report objects surviving Delete", func() {
+			result := runCheck(func() store.CRUD {
+				return &softDelete{memstore.New(ResourceType)}
+			}, checkDelete)
+
+			So(strings.Join(result.errors, "\n"), ShouldContainSubstring, `Get("1") after Delete: expected a 404 error, got none`)
+			So(strings.Join(result.errors, "\n"), ShouldContainSubstring, `List: deleted object "1" is still listed`)
+		})
+
+		Convey("should report storage that is not empty", func() {
+			shared := memstore.New(ResourceType)
+			result := runCheck(func() store.CRUD {
+				return shared
+			}, checkListAfterCreate)
+			So(result.errors, ShouldBeEmpty)
+
+			result = runCheck(func() store.CRUD {
+				return shared
+			}, checkListAfterCreate)
+			So(result.errors, ShouldResemble, []string{"List: expected new storage to be empty, got 3 objects"})
+		})
+
+		Convey("should skip the ToMany check without Linker", func() {
+			result := runCheck(func() store.CRUD {
+				return &crudOnly{newMemstore()}
+			}, checkToMany)
+
+			So(result.skipped, ShouldContainSubstring, "does not implement storetest.Linker")
+		})
+	})
+}
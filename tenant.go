@@ -0,0 +1,133 @@
+package jshapi
+
+import (
+	stdcontext "context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"goji.io/pat"
+	"goji.io/pattern"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+TenantExtractor resolves the tenant of a request matching the tenant prefix of the
+API, whose variables are available through pat.Param(ctx, name). It returns the
+tenant id, or the error to send when the tenant doesn't exist or the caller may not
+access it, such as a 404 or a 403.
+*/
+type TenantExtractor func(ctx context.Context, r *http.Request) (string, jsh.ErrorType)
+
+/*
+Tenanted mounts every route of the API under a tenant prefix, such as
+"t/:tenant", placed in front of the API prefix:
+
+	api := jshapi.New("api")
+	api.Tenanted("t/:tenant", func(ctx context.Context, r *http.Request) (string, jsh.ErrorType) {
+		tenant := pat.Param(ctx, "tenant")
+		if !tenants.Exists(tenant) {
+			return "", jsh.NotFound("tenant", tenant)
+		}
+		return tenant, nil
+	})
+
+	// GET /t/acme/api/users
+
+extract runs before any middleware or storage call of the API, and the tenant id
+it returns is available to them through TenantFromContext. Requests outside of the
+tenant prefix get a 404 rather than reaching untenanted routes. Every generated
+link, Location header and self link includes the tenant segment, while the route
+patterns of Routes and RouteTree stay relative to it.
+*/
+func (a *API) Tenanted(prefix string, extract TenantExtractor) {
+	a.checkRegistration("a tenant prefix")
+
+	if extract == nil {
+		panic("jshapi: tenant prefix requires an extractor")
+	}
+
+	prefix = path.Join("/", prefix)
+	if prefix == "/" || !strings.Contains(prefix, "/:") {
+		panic(fmt.Sprintf("jshapi: tenant prefix '%s' requires a variable, such as 't/:tenant'", prefix))
+	}
+
+	a.tenant = &tenantScope{
+		prefix:  prefix,
+		pattern: pat.New(prefix + "/*"),
+		extract: extract,
+	}
+}
+
+// TenantFromContext returns the id of the tenant of the current request, or an
+// empty string if the API is not tenanted
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey).(resolvedTenant)
+	return tenant.id
+}
+
+// tenantScope is the tenant prefix of an API, see Tenanted
+type tenantScope struct {
+	prefix  string
+	pattern *pat.Pattern
+	extract TenantExtractor
+}
+
+// resolvedTenant is the tenant of a request along with the escaped path segment
+// it was found under, such as "/t/acme"
+type resolvedTenant struct {
+	id   string
+	path string
+}
+
+/*
+resolve extracts the tenant of a request, returning the context holding it along
+with the request stripped of the tenant segment, for the routes of the API to
+match it unchanged. The tenant is also kept in the context of the returned request
+for the links generated from it.
+*/
+func (t *tenantScope) resolve(ctx context.Context, r *http.Request) (context.Context, *http.Request, jsh.ErrorType) {
+	escaped := r.URL.EscapedPath()
+
+	matched := t.pattern.Match(pattern.SetPath(ctx, escaped), r)
+	if matched == nil {
+		return ctx, r, &jsh.Error{
+			Title:  "Not Found",
+			Detail: fmt.Sprintf("Path '%s' is not under a tenant", escaped),
+			Status: http.StatusNotFound,
+		}
+	}
+
+	id, err := t.extract(matched, r)
+	if HasError(err) {
+		return ctx, r, err
+	}
+
+	remainder := pattern.Path(matched)
+	stripped, parseErr := url.Parse(remainder)
+	if parseErr != nil {
+		return ctx, r, jsh.ISE(fmt.Sprintf("Unable to strip tenant from path '%s': %s", escaped, parseErr.Error()))
+	}
+
+	tenant := resolvedTenant{id: id, path: strings.TrimSuffix(escaped, remainder)}
+
+	scoped := r.WithContext(stdcontext.WithValue(r.Context(), tenantKey, tenant))
+	scopedURL := *r.URL
+	scopedURL.Path = stripped.Path
+	scopedURL.RawPath = stripped.RawPath
+	scoped.URL = &scopedURL
+	scoped.RequestURI = scopedURL.RequestURI()
+
+	return context.WithValue(matched, tenantKey, tenant), scoped, nil
+}
+
+// tenantPath is the escaped tenant segment of a request, such as "/t/acme", empty
+// outside of tenanted APIs
+func tenantPath(r *http.Request) string {
+	tenant, _ := r.Context().Value(tenantKey).(resolvedTenant)
+	return tenant.path
+}
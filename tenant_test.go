@@ -0,0 +1,101 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"goji.io"
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTenanted(t *testing.T) {
+
+	Convey("Tenant Tests", t, func() {
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+
+		tenants := []string{}
+		resource.UseC(func(next goji.Handler) goji.Handler {
+			return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				tenants = append(tenants, TenantFromContext(ctx))
+				next.ServeHTTPC(ctx, w, r)
+			})
+		})
+
+		api := New("api")
+		api.Tenanted("t/:tenant", func(ctx context.Context, r *http.Request) (string, jsh.ErrorType) {
+			switch tenant := pat.Param(ctx, "tenant"); tenant {
+			case "acme":
+				return "tenant-" + tenant, nil
+			case "locked":
+				return "", &jsh.Error{Title: "Forbidden", Detail: "Tenant is locked", Status: http.StatusForbidden}
+			default:
+				return "", jsh.NotFound("tenant", tenant)
+			}
+		})
+		api.EmitSelfLinks(true)
+		api.Add(resource)
+
+		serve := func(method string, url string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			if body != "" {
+				request.Header.Set("Content-Type", jsh.ContentType)
+			}
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should route requests under the tenant prefix", func() {
+			recorder := serve("GET", "/t/acme/api/bars/1", "")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(tenants, ShouldResemble, []string{"tenant-acme"})
+			So(recorder.Body.String(), ShouldContainSubstring, `"href": "/t/acme/api/bars/1"`)
+		})
+
+		Convey("should include the tenant segment in Location headers", func() {
+			recorder := serve("POST", "/t/acme/api/bars", `{"data": {"type": "bars", "attributes": {"foo": "bar"}}}`)
+
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+			So(recorder.Header().Get("Location"), ShouldStartWith, "/t/acme/api/bars/")
+		})
+
+		Convey("should keep the query in top-level self links", func() {
+			recorder := serve("GET", "/t/acme/api/bars?page[number]=2", "")
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldContainSubstring, `/t/acme/api/bars?page[number]=2`)
+		})
+
+		Convey("should send the errors of the extractor", func() {
+			So(serve("GET", "/t/locked/api/bars", "").Code, ShouldEqual, http.StatusForbidden)
+			So(serve("GET", "/t/unknown/api/bars", "").Code, ShouldEqual, http.StatusNotFound)
+			So(tenants, ShouldBeEmpty)
+		})
+
+		Convey("should not fall through to untenanted routes", func() {
+			recorder := serve("GET", "/api/bars", "")
+
+			So(recorder.Code, ShouldEqual, http.StatusNotFound)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, jsh.ContentType)
+			So(tenants, ShouldBeEmpty)
+		})
+
+		Convey("should leave TenantFromContext empty outside of tenanted APIs", func() {
+			So(TenantFromContext(context.Background()), ShouldEqual, "")
+		})
+
+		Convey("should require a variable in the prefix", func() {
+			So(func() {
+				New("api").Tenanted("tenants", func(ctx context.Context, r *http.Request) (string, jsh.ErrorType) { return "", nil })
+			}, ShouldPanic)
+		})
+	})
+}
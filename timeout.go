@@ -0,0 +1,159 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"goji.io"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+Timeout returns a middleware bounding the time spent handling a request. The
+request context is given a deadline, which storage should honor by aborting when it
+is done. If the handler has not written the response headers by then, a 504
+JSON API error carrying the timeout in its meta is sent in its place, and further
+writes from the handler fail with http.ErrHandlerTimeout:
+
+	api.UseC(jshapi.Timeout(5 * time.Second))
+
+Responses already started when the deadline expires are left to complete, only
+the handler context is canceled.
+*/
+func Timeout(d time.Duration) func(goji.Handler) goji.Handler {
+	return func(next goji.Handler) goji.Handler {
+		return goji.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			writer := &timeoutWriter{ResponseWriter: w, header: http.Header{}}
+			done := make(chan struct{})
+			panics := make(chan interface{}, 1)
+
+			go func() {
+				defer func() {
+					recovered := recover()
+					if recovered != nil {
+						panics <- recovered
+						return
+					}
+					close(done)
+				}()
+
+				next.ServeHTTPC(ctx, writer, r)
+			}()
+
+			select {
+			case <-done:
+				writer.finish()
+			case recovered := <-panics:
+				panic(recovered)
+			case <-ctx.Done():
+				if !writer.timeout() {
+					// the response has started, let the handler complete it
+					select {
+					case <-done:
+					case recovered := <-panics:
+						panic(recovered)
+					}
+					return
+				}
+
+				sendErrorWithMeta(ctx, w, r, &jsh.Error{
+					Title:  "Gateway Timeout",
+					Detail: fmt.Sprintf("Request could not be completed within %s", d),
+					Status: http.StatusGatewayTimeout,
+				}, map[string]interface{}{"timeout": d.String()})
+			}
+		})
+	}
+}
+
+// timeoutWriter guards a ResponseWriter shared between a handler and the Timeout
+// middleware. The handler writes to its own header map, copied to the response
+// when it writes the headers, or when it returns without writing them.
+type timeoutWriter struct {
+	http.ResponseWriter
+	header http.Header
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeHeader(status)
+}
+
+// writeHeader must be called with the lock held
+func (w *timeoutWriter) writeHeader(status int) {
+	if w.timedOut || w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	target := w.ResponseWriter.Header()
+	for name, values := range w.header {
+		target[name] = values
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *timeoutWriter) Write(content []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	w.writeHeader(http.StatusOK)
+	return w.ResponseWriter.Write(content)
+}
+
+// Flush implements http.Flusher when the wrapped writer does
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	flusher, canFlush := w.ResponseWriter.(http.Flusher)
+	if !w.timedOut && canFlush {
+		w.writeHeader(http.StatusOK)
+		flusher.Flush()
+	}
+}
+
+// finish sends the headers of a handler returning without writing, as net/http
+// would with a 200
+func (w *timeoutWriter) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeHeader(http.StatusOK)
+}
+
+// timeout marks the writer as timed out if the response has not started, in which
+// case the caller is responsible for the response
+func (w *timeoutWriter) timeout() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.wroteHeader {
+		return false
+	}
+
+	w.timedOut = true
+	return true
+}
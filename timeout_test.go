@@ -0,0 +1,77 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"goji.io/pat"
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTimeout(t *testing.T) {
+
+	Convey("Timeout Tests", t, func() {
+
+		canceled := make(chan error, 1)
+
+		resource := NewResource(testResourceType)
+		resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			if id == "slow" {
+				<-ctx.Done()
+				canceled <- ctx.Err()
+				return nil, jsh.ISE("canceled")
+			}
+			return (&MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs}).Get(ctx, id)
+		})
+		resource.HandleFuncC(pat.Get("/:id/stream"), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			<-ctx.Done()
+			w.Write([]byte("done"))
+		})
+
+		resource.HandleFuncC(pat.Get("/:id/empty"), func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Empty", "true")
+		})
+
+		api := New("")
+		api.UseC(Timeout(50 * time.Millisecond))
+		api.Add(resource)
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/" + testResourceType
+
+		Convey("should not interfere with fast requests", func() {
+			resp, _ := negotiationRequest("GET", url+"/1", "", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("should respond 504 and cancel storage when the deadline expires", func() {
+			resp, content := negotiationRequest("GET", url+"/slow", "", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusGatewayTimeout)
+			So(resp.Header.Get("Content-Type"), ShouldEqual, jsh.ContentType)
+			So(content, ShouldContainSubstring, `"timeout": "50ms"`)
+
+			So(<-canceled, ShouldEqual, context.DeadlineExceeded)
+		})
+
+		Convey("should let started responses complete", func() {
+			resp, content := negotiationRequest("GET", url+"/1/stream", "", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(content, ShouldEqual, "done")
+		})
+
+		Convey("should send the headers of handlers returning without writing", func() {
+			resp, content := negotiationRequest("GET", url+"/1/empty", "", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("X-Empty"), ShouldEqual, "true")
+			So(content, ShouldBeEmpty)
+		})
+	})
+}
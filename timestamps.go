@@ -0,0 +1,146 @@
+package jshapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+// timestamps holds the attributes maintained by Timestamps
+type timestamps struct {
+	created, updated string
+	// now is replaceable for tests, see TimestampClock
+	now         func() time.Time
+	allowWrites bool
+}
+
+// TimestampOption configures the timestamps of a resource
+type TimestampOption func(*timestamps)
+
+// TimestampClock sets the clock of the timestamps, time.Now by default
+func TimestampClock(now func() time.Time) TimestampOption {
+	return func(stamps *timestamps) {
+		stamps.now = now
+	}
+}
+
+// AllowTimestampWrites keeps the timestamps written by clients rather than
+// rejecting them, the missing ones are still set
+func AllowTimestampWrites() TimestampOption {
+	return func(stamps *timestamps) {
+		stamps.allowWrites = true
+	}
+}
+
+/*
+Timestamps maintains creation and update time attributes of the objects of the
+resource, named as sent, so that storage doesn't have to: created objects are
+given both before storage saves them, updated objects the update time before
+storage updates them, and the update time is then part of the patched fields.
+Times are RFC3339 strings in UTC. Either name may be empty to maintain one only.
+
+	posts.Timestamps("created-at", "updated-at")
+
+Objects written by clients with either attribute are rejected with a 400 pointing
+at the attribute, unless AllowTimestampWrites is given. Bulk writes, imports and
+atomic operations are stamped alike. Panics when both names are empty.
+*/
+func (res *Resource) Timestamps(createdField string, updatedField string, opts ...TimestampOption) {
+	res.checkRegistration("timestamps")
+
+	if createdField == "" && updatedField == "" {
+		panic(fmt.Sprintf("jshapi: timestamps of '%s' require a created or updated field", res.Type))
+	}
+
+	stamps := &timestamps{created: createdField, updated: updatedField, now: time.Now}
+	for _, opt := range opts {
+		opt(stamps)
+	}
+
+	res.timestamps = stamps
+}
+
+/*
+stampTimestamps sets the timestamps of an incoming object of the resource for a
+create or update method, rejecting the timestamps written by the client unless
+allowed.
+*/
+func (res *Resource) stampTimestamps(method string, object *jsh.Object) jsh.ErrorList {
+	stamps := res.timestamps
+	if stamps == nil || object == nil || (method != post && method != patch) {
+		return nil
+	}
+
+	fields := []string{stamps.updated}
+	if method == post {
+		fields = append(fields, stamps.created)
+	}
+
+	written := attributeNames(object.Attributes)
+
+	var errs jsh.ErrorList
+	for _, field := range []string{stamps.created, stamps.updated} {
+		if field == "" || !written[field] || stamps.allowWrites {
+			continue
+		}
+
+		err := &jsh.Error{
+			Title:  "Bad Request",
+			Detail: fmt.Sprintf("Attribute '%s' is set by the server", field),
+			Status: http.StatusBadRequest,
+		}
+		err.Source.Pointer = "/data/attributes/" + escapePointer(field)
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	now, _ := json.Marshal(stamps.now().UTC().Format(time.RFC3339Nano))
+	for _, field := range fields {
+		if field != "" && !written[field] {
+			object.Attributes = appendAttribute(object.Attributes, field, now)
+		}
+	}
+
+	return nil
+}
+
+// attributeNames returns the names of the members of an attributes object
+func attributeNames(attributes json.RawMessage) map[string]bool {
+	names := map[string]bool{}
+	rewriteAttributes(attributes, func(name string, value json.RawMessage) (string, json.RawMessage, error) {
+		names[name] = true
+		return name, value, nil
+	})
+
+	return names
+}
+
+// appendAttribute returns attributes with a member added, attributes being an
+// object, null or empty
+func appendAttribute(attributes json.RawMessage, name string, value json.RawMessage) json.RawMessage {
+	appended := &bytes.Buffer{}
+
+	trimmed := bytes.TrimSpace(attributes)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		appended.WriteByte('{')
+	} else {
+		members := bytes.TrimSpace(trimmed[:len(trimmed)-1])
+		appended.Write(members)
+		if len(members) > 1 {
+			appended.WriteByte(',')
+		}
+	}
+
+	writeName(appended, name)
+	appended.WriteByte(':')
+	appended.Write(value)
+	appended.WriteByte('}')
+
+	return appended.Bytes()
+}
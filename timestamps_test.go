@@ -0,0 +1,129 @@
+package jshapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTimestamps(t *testing.T) {
+
+	Convey("Timestamps Tests", t, func() {
+
+		now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("CEST", 2*60*60))
+		clock := TimestampClock(func() time.Time { return now })
+
+		var saved map[string]interface{}
+		var patched map[string]bool
+		store := func(ctx context.Context, object *jsh.Object) (*jsh.Object, jsh.ErrorType) {
+			saved = map[string]interface{}{}
+			json.Unmarshal(object.Attributes, &saved)
+			patched, _ = PatchFields(ctx)
+			object.ID = "1"
+			return object, nil
+		}
+
+		posts := NewResource("posts")
+		posts.Post(store)
+		posts.Patch(store)
+
+		api := New("")
+		serve := func(method string, url string, attributes string) *httptest.ResponseRecorder {
+			if len(api.Resources) == 0 {
+				api.Add(posts)
+			}
+
+			body := `{"data": {"type": "posts", "attributes": ` + attributes + `}}`
+			if method == "PATCH" {
+				body = `{"data": {"type": "posts", "id": "1", "attributes": ` + attributes + `}}`
+			}
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(method, url, strings.NewReader(body))
+			request.Header.Set("Content-Type", jsh.ContentType)
+			api.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("should set both timestamps of created objects in UTC", func() {
+			posts.Timestamps("created-at", "updated-at", clock)
+
+			recorder := serve("POST", "/posts", `{"title": "hello"}`)
+
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+			So(saved, ShouldResemble, map[string]interface{}{
+				"title":      "hello",
+				"created-at": "2020-01-02T01:04:05Z",
+				"updated-at": "2020-01-02T01:04:05Z",
+			})
+		})
+
+		Convey("should set the update time of updated objects as a patched field", func() {
+			posts.Timestamps("created-at", "updated-at", clock)
+
+			recorder := serve("PATCH", "/posts/1", `{}`)
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(saved, ShouldResemble, map[string]interface{}{"updated-at": "2020-01-02T01:04:05Z"})
+			So(patched, ShouldResemble, map[string]bool{"updated-at": true})
+		})
+
+		Convey("should maintain a single timestamp", func() {
+			posts.Timestamps("", "updated-at", clock)
+
+			serve("POST", "/posts", `{"title": "hello"}`)
+			So(saved, ShouldResemble, map[string]interface{}{"title": "hello", "updated-at": "2020-01-02T01:04:05Z"})
+		})
+
+		Convey("should reject timestamps written by clients", func() {
+			posts.Timestamps("created-at", "updated-at", clock)
+
+			recorder := serve("PATCH", "/posts/1", `{"created-at": "2019-01-01T00:00:00Z"}`)
+
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Body.String(), ShouldContainSubstring, `"pointer": "/data/attributes/created-at"`)
+			So(saved, ShouldBeNil)
+		})
+
+		Convey("should keep timestamps written by clients when allowed", func() {
+			posts.Timestamps("created-at", "updated-at", clock, AllowTimestampWrites())
+
+			recorder := serve("POST", "/posts", `{"created-at": "2019-01-01T00:00:00Z"}`)
+
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+			So(saved, ShouldResemble, map[string]interface{}{
+				"created-at": "2019-01-01T00:00:00Z",
+				"updated-at": "2020-01-02T01:04:05Z",
+			})
+		})
+
+		Convey("should stamp names as sent before their conversion", func() {
+			posts.Timestamps("created-at", "updated-at", clock)
+			posts.KeyCasing(DashCase, SnakeCase)
+
+			serve("POST", "/posts", `null`)
+			So(saved, ShouldResemble, map[string]interface{}{
+				"created_at": "2020-01-02T01:04:05Z",
+				"updated_at": "2020-01-02T01:04:05Z",
+			})
+		})
+
+		Convey("should leave resources without timestamps untouched", func() {
+			serve("POST", "/posts", `{"created-at": "2019-01-01T00:00:00Z"}`)
+			So(saved, ShouldResemble, map[string]interface{}{"created-at": "2019-01-01T00:00:00Z"})
+		})
+
+		Convey("should panic without a field", func() {
+			So(func() { posts.Timestamps("", "") }, ShouldPanicWith,
+				"jshapi: timestamps of 'posts' require a created or updated field")
+		})
+	})
+}
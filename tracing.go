@@ -0,0 +1,74 @@
+package jshapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+)
+
+/*
+Tracer starts tracing spans, see the tracing/otel package for an OpenTelemetry
+implementation. Start returns the context carrying the new span, and the function
+ending it with the error of the traced operation, if any:
+
+	api.SetTracer(tracer)
+
+Resource handlers are traced as "jshapi.<type>.<operation>", atomic operations
+as "jshapi.operations", and each storage call as a child "store.<call>" span.
+The context of the storage span is the one passed to storage, so that storage
+can start its own child spans.
+*/
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, func(error))
+}
+
+// SetTracer installs the Tracer used for the requests served by the API
+func (a *API) SetTracer(tracer Tracer) {
+	a.checkRegistration("a tracer")
+
+	a.tracer = tracer
+}
+
+// startSpan starts a span if a tracer is installed
+func (a *API) startSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	if a == nil || a.tracer == nil {
+		return ctx, func(error) {}
+	}
+
+	return a.tracer.Start(ctx, name)
+}
+
+/*
+traceHandler starts the span of a handler, returning the context and writer to use
+for the request, and the function ending the span. 5XX responses are recorded as
+errors.
+*/
+func (a *API) traceHandler(ctx context.Context, w http.ResponseWriter, name string) (context.Context, http.ResponseWriter, func()) {
+	if a == nil || a.tracer == nil {
+		return ctx, w, func() {}
+	}
+
+	ctx, end := a.tracer.Start(ctx, name)
+	writer, recorder := wrapAccessWriter(w)
+
+	return ctx, writer, func() {
+		if recorder.status >= 500 {
+			end(fmt.Errorf("%d %s", recorder.status, http.StatusText(recorder.status)))
+			return
+		}
+
+		end(nil)
+	}
+}
+
+// spanError converts the error of a storage call for a span
+func spanError(err jsh.ErrorType) error {
+	if !HasError(err) {
+		return nil
+	}
+
+	return err
+}
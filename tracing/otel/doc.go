@@ -0,0 +1,10 @@
+/*
+Package otel provides a jshapi.Tracer backed by OpenTelemetry, go.opentelemetry.io/otel.
+To avoid imposing the dependency on every jshapi user, the tracer is only built
+with the "otel" build tag:
+
+	go build -tags otel
+
+	api.SetTracer(otel.NewTracer(otel.GetTracerProvider()))
+*/
+package otel
@@ -0,0 +1,38 @@
+//go:build otel
+// +build otel
+
+package otel
+
+import (
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+// InstrumentationName is the name of the OpenTelemetry tracer used for spans
+const InstrumentationName = "github.com/derekdowling/jsh-api"
+
+// Tracer implements jshapi.Tracer
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer creates a Tracer starting spans with the given provider
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: provider.Tracer(InstrumentationName)}
+}
+
+// Start implements jshapi.Tracer, errors are recorded on the span and set its
+// status
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, func(error)) {
+	ctx, span := t.tracer.Start(ctx, name)
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		span.End()
+	}
+}
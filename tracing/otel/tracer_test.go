@@ -0,0 +1,48 @@
+//go:build otel
+// +build otel
+
+package otel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/derekdowling/jsh-api"
+)
+
+func TestTracer(t *testing.T) {
+
+	Convey("OpenTelemetry Tracer Tests", t, func() {
+
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+		api := jshapi.New("")
+		api.SetTracer(NewTracer(provider))
+		api.Add(jshapi.NewMockResource("users", 1, map[string]string{"name": "bob"}))
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		Convey("should export handler and storage spans", func() {
+			resp, err := http.Get(server.URL + "/users/1")
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+
+			spans := recorder.Ended()
+			So(spans, ShouldHaveLength, 2)
+
+			storage, handler := spans[0], spans[1]
+			So(handler.Name(), ShouldEqual, "jshapi.users.read")
+			So(storage.Name(), ShouldEqual, "store.get")
+			So(storage.Parent().SpanID(), ShouldEqual, handler.SpanContext().SpanID())
+			So(handler.Status().Code, ShouldEqual, codes.Unset)
+		})
+	})
+}
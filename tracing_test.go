@@ -0,0 +1,95 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testSpanKey holds the name of the current test span
+type testSpanKey struct{}
+
+// testSpan is a span recorded by testTracer
+type testSpan struct {
+	name   string
+	parent string
+	err    error
+	ended  bool
+}
+
+// testTracer records the spans it starts
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, func(error)) {
+	parent, _ := ctx.Value(testSpanKey{}).(string)
+
+	span := &testSpan{name: name, parent: parent}
+	t.spans = append(t.spans, span)
+
+	return context.WithValue(ctx, testSpanKey{}, name), func(err error) {
+		span.err = err
+		span.ended = true
+	}
+}
+
+func TestTracing(t *testing.T) {
+
+	Convey("Tracing Tests", t, func() {
+
+		tracer := &testTracer{}
+
+		var storageSpan string
+		resource := NewResource(testResourceType)
+		resource.Get(func(ctx context.Context, id string) (*jsh.Object, jsh.ErrorType) {
+			storageSpan, _ = ctx.Value(testSpanKey{}).(string)
+			if id == "fail" {
+				return nil, jsh.ISE("storage failure")
+			}
+			return (&MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs}).Get(ctx, id)
+		})
+
+		api := New("")
+		api.SetTracer(tracer)
+		api.Add(resource)
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/" + testResourceType
+
+		Convey("should trace handlers and storage calls", func() {
+			resp, _ := negotiationRequest("GET", url+"/1", "", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			So(tracer.spans, ShouldHaveLength, 2)
+			So(tracer.spans[0].name, ShouldEqual, "jshapi.bars.read")
+			So(tracer.spans[1].name, ShouldEqual, "store.get")
+			So(tracer.spans[1].parent, ShouldEqual, "jshapi.bars.read")
+
+			for _, span := range tracer.spans {
+				So(span.ended, ShouldBeTrue)
+				So(span.err, ShouldBeNil)
+			}
+		})
+
+		Convey("should pass the storage span context to storage", func() {
+			negotiationRequest("GET", url+"/1", "", "", "")
+			So(storageSpan, ShouldEqual, "store.get")
+		})
+
+		Convey("should record errors on closing spans", func() {
+			resp, _ := negotiationRequest("GET", url+"/fail", "", "", "")
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+
+			So(tracer.spans[0].err, ShouldNotBeNil)
+			So(tracer.spans[1].err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,146 @@
+package jshapi
+
+import (
+	"fmt"
+	"path"
+)
+
+/*
+URLFor returns the canonical URL of the object of resourceType identified by id,
+such as "https://api.example.com/v1/users/42", built from the routes registered
+on the API rather than string concatenation. resourceType may be an alias, URLs
+always use the canonical type. URLs are prefixed with the BaseURL of the API, and
+are paths without it. An error is returned if the resource is not added to the
+API, serves no route for single objects, or id does not match its IDPattern.
+*/
+func (a *API) URLFor(resourceType string, id string) (string, error) {
+	res, err := a.resourceFor(resourceType)
+	if err != nil {
+		return "", err
+	}
+
+	objectPath, err := res.ObjectPath(id)
+	if err != nil {
+		return "", err
+	}
+
+	return a.baseURL + objectPath, nil
+}
+
+// RelationshipURL returns the URL of a relationship of an object, such as
+// "/users/42/relationships/posts", see URLFor
+func (a *API) RelationshipURL(resourceType string, id string, relationship string) (string, error) {
+	res, err := a.resourceFor(resourceType)
+	if err != nil {
+		return "", err
+	}
+
+	relationshipPath, err := res.RelationshipPath(id, relationship)
+	if err != nil {
+		return "", err
+	}
+
+	return a.baseURL + relationshipPath, nil
+}
+
+// ActionURL returns the URL of an action on an object, such as
+// "/users/42/activate", see URLFor
+func (a *API) ActionURL(resourceType string, id string, action string) (string, error) {
+	res, err := a.resourceFor(resourceType)
+	if err != nil {
+		return "", err
+	}
+
+	actionPath, err := res.ActionPath(id, action)
+	if err != nil {
+		return "", err
+	}
+
+	return a.baseURL + actionPath, nil
+}
+
+// ObjectPath returns the path of the object of the resource identified by id, the
+// URL returned by API.URLFor without the base URL, for handlers building relative
+// links
+func (res *Resource) ObjectPath(id string) (string, error) {
+	err := res.checkURL(id, res.idRoute(), OpRead, OpUpdate, OpDelete)
+	if err != nil {
+		return "", err
+	}
+
+	return res.objectPath(id), nil
+}
+
+// RelationshipPath returns the path of a relationship of an object of the
+// resource, see ObjectPath
+func (res *Resource) RelationshipPath(id string, relationship string) (string, error) {
+	route := fmt.Sprintf("%s/relationships/%s", res.idRoute(), relationship)
+
+	err := res.checkURL(id, route, OpRelationship)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/relationships/%s", res.objectPath(id), relationship), nil
+}
+
+// ActionPath returns the path of an action on an object of the resource, see
+// ObjectPath
+func (res *Resource) ActionPath(id string, action string) (string, error) {
+	err := res.checkURL(id, path.Join(res.idRoute(), action), OpAction)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(res.objectPath(id), action), nil
+}
+
+// objectPath is the path of an object of the resource, its collection path
+// followed by its id
+func (res *Resource) objectPath(id string) string {
+	return res.basePath(res.CollectionPath()) + "/" + res.idPath(id)
+}
+
+// checkURL checks that the resource serves route for one of operations, and that
+// id is valid
+func (res *Resource) checkURL(id string, route string, operations ...Operation) error {
+	if id == "" {
+		return fmt.Errorf("jshapi: an id is required to build URLs of resource '%s'", res.Type)
+	}
+	if res.idError(id) != nil {
+		return fmt.Errorf("jshapi: invalid id '%s' for resource '%s'", id, res.Type)
+	}
+
+	for _, meta := range res.routeOperations {
+		if meta.route != route {
+			continue
+		}
+
+		for _, op := range operations {
+			if meta.op == op {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("jshapi: no route %s is registered on resource '%s'", res.fullPattern(route), res.Type)
+}
+
+// resourceFor returns the resource added to the API for a type, or one of its
+// aliases
+func (a *API) resourceFor(resourceType string) (*Resource, error) {
+	res, added := a.Resources[resourceType]
+	if added {
+		return res, nil
+	}
+
+	for _, resource := range a.Resources {
+		for _, alias := range resource.aliases {
+			if alias.name == resourceType {
+				return resource, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("jshapi: no resource '%s' is added to the API", resourceType)
+}
@@ -0,0 +1,103 @@
+package jshapi
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestURLs(t *testing.T) {
+
+	Convey("URL Builder Tests", t, func() {
+
+		storage := &MockStorage{ResourceType: testResourceType, ResourceAttributes: testObjAttrs, ListCount: 1}
+		resource := NewCRUDResource(testResourceType, storage, WithPrefix("admin"))
+		resource.ToMany("foos", func(ctx context.Context, id string) (jsh.List, jsh.ErrorType) {
+			return jsh.List{}, nil
+		})
+		resource.Action("testAction", storage.Get)
+		resource.Alias("legacy")
+
+		api := New("v1")
+		api.Add(resource)
+
+		Convey("->URLFor()", func() {
+
+			Convey("should build object paths from the routes", func() {
+				url, err := api.URLFor(testResourceType, "42")
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "/v1/admin/bars/42")
+			})
+
+			Convey("should use the canonical type for aliases", func() {
+				url, err := api.URLFor("legacy", "42")
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "/v1/admin/bars/42")
+			})
+
+			Convey("should prefix the base URL", func() {
+				api.BaseURL("https://api.example.com")
+
+				url, err := api.URLFor(testResourceType, "42")
+				So(err, ShouldBeNil)
+				So(url, ShouldEqual, "https://api.example.com/v1/admin/bars/42")
+			})
+
+			Convey("should reject unknown resources and invalid ids", func() {
+				_, err := api.URLFor("unknowns", "42")
+				So(err, ShouldNotBeNil)
+
+				_, err = api.URLFor(testResourceType, "")
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("->RelationshipURL()", func() {
+			url, err := api.RelationshipURL(testResourceType, "42", "foos")
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "/v1/admin/bars/42/relationships/foos")
+
+			_, err = api.RelationshipURL(testResourceType, "42", "bazs")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("->ActionURL()", func() {
+			url, err := api.ActionURL(testResourceType, "42", "testAction")
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "/v1/admin/bars/42/testAction")
+
+			_, err = api.ActionURL(testResourceType, "42", "foos")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("->Resource.ObjectPath()", func() {
+			api.BaseURL("https://api.example.com")
+
+			objectPath, err := resource.ObjectPath("42")
+			So(err, ShouldBeNil)
+			So(objectPath, ShouldEqual, "/v1/admin/bars/42")
+		})
+
+		Convey("should reject disabled routes", func() {
+			So(resource.Disable("GET", "/:id/testAction"), ShouldBeNil)
+
+			_, err := api.ActionURL(testResourceType, "42", "testAction")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("should check the id pattern", func() {
+			numeric := NewCRUDResource("numbers", storage, WithIDPattern(Numeric))
+			api.Add(numeric)
+
+			_, err := api.URLFor("numbers", "abc")
+			So(err, ShouldNotBeNil)
+
+			url, err := api.URLFor("numbers", "12")
+			So(err, ShouldBeNil)
+			So(url, ShouldEqual, "/v1/numbers/12")
+		})
+	})
+}
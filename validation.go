@@ -0,0 +1,208 @@
+package jshapi
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"golang.org/x/net/context"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	"github.com/derekdowling/jsh-api/store"
+)
+
+/*
+Validator is a hook that checks an incoming object before it is handed to storage.
+Returned errors are aggregated with the other write path checks.
+*/
+type Validator func(ctx context.Context, object *jsh.Object) jsh.ErrorType
+
+/*
+AddValidator registers a validation hook that runs for every object received by
+the resource's POST and PATCH handlers.
+*/
+func (res *Resource) AddValidator(validator Validator) {
+	res.checkRegistration("a validator")
+
+	res.validators = append(res.validators, validator)
+}
+
+/*
+writeErrors runs all independent write path checks against a parsed object and
+aggregates their errors: the request media type parameters, the object type and
+id, member names, the attributes schema, and registered validators.
+*/
+func (res *Resource) writeErrors(ctx context.Context, r *http.Request, object *jsh.Object) jsh.ErrorList {
+	errs := jsh.ErrorList{}
+
+	mediaTypeErr := MediaTypeError(ctx)
+	if mediaTypeErr != nil {
+		errs = append(errs, mediaTypeErr)
+	}
+
+	errs = append(errs, res.objectErrors(ctx, r.Method, object)...)
+
+	return aggregateErrors(errs)
+}
+
+// objectErrors runs the checks that apply to a single incoming object written with
+// method, and seals its sealed attributes once it passes them
+func (res *Resource) objectErrors(ctx context.Context, method string, object *jsh.Object) jsh.ErrorList {
+	errs := jsh.ErrorList{}
+	if object == nil {
+		return errs
+	}
+
+	// a missing type is reported when parsing the object
+	if object.Type != "" && object.Type != res.Type {
+		conflict := &jsh.Error{
+			Title:  "Conflict",
+			Detail: fmt.Sprintf("Expected object type '%s', got '%s'", res.Type, object.Type),
+			Status: http.StatusConflict,
+		}
+		conflict.Source.Pointer = "/data/type"
+		errs = append(errs, conflict)
+	}
+
+	if method == post {
+		clientIDErr := res.clientIDError(object)
+		if clientIDErr != nil {
+			errs = append(errs, clientIDErr)
+		}
+	}
+
+	// bulk updates carry no id in the route
+	if method == patch {
+		id, routed := res.routeID(ctx)
+		if routed && object.ID != "" && object.ID != id {
+			conflict := &jsh.Error{
+				Title:  "Conflict",
+				Detail: fmt.Sprintf("Object id '%s' does not match the requested id '%s'", object.ID, id),
+				Status: http.StatusConflict,
+			}
+			conflict.Source.Pointer = "/data/id"
+			errs = append(errs, conflict)
+		}
+	}
+
+	attributesErr := attributesError(object)
+	if attributesErr != nil {
+		// the remaining checks expect an attributes object
+		return append(errs, attributesErr)
+	}
+
+	errs = append(errs, res.memberNameErrors(object)...)
+	errs = append(errs, res.unknownAttributeErrors(ctx, object)...)
+	errs = append(errs, res.stampTimestamps(method, object)...)
+	errs = append(errs, res.computedErrors(object)...)
+	if method == post {
+		res.applyDefaults(object)
+	}
+
+	if res.schema != nil {
+		errs = append(errs, res.schema.ValidateAttributes(object.Attributes, method == patch)...)
+	}
+
+	decodeErr := res.decodeAttributes(object)
+	if decodeErr != nil {
+		// validators expect the stored attributes
+		return append(errs, decodeErr)
+	}
+
+	for _, validator := range res.validators {
+		err := validator(ctx, object)
+		if HasError(err) {
+			errs = append(errs, toErrorList(err)...)
+		}
+	}
+
+	// valid objects are sealed before any storage sees them
+	if len(errs) == 0 {
+		sealErr := res.sealAttributes(object)
+		if sealErr != nil {
+			errs = append(errs, sealErr)
+		}
+	}
+
+	return errs
+}
+
+// attributesError rejects attributes that are not a JSON object, which jsh does
+// not check
+func attributesError(object *jsh.Object) *jsh.Error {
+	attributes := bytes.TrimSpace(object.Attributes)
+	if len(attributes) == 0 || attributes[0] == '{' || bytes.Equal(attributes, []byte("null")) {
+		return nil
+	}
+
+	err := &jsh.Error{
+		Title:  "Bad Request",
+		Detail: "Member 'attributes' must be an object",
+		Status: http.StatusBadRequest,
+	}
+	err.Source.Pointer = "/data/attributes"
+
+	return err
+}
+
+/*
+errorSeverity ranks error statuses so that an aggregated error document is sent
+with the status of its most severe error:
+
+	400 < 422 < 409 < 415 < 5XX
+
+Any other 4XX status ranks alongside 400.
+*/
+func errorSeverity(status int) int {
+	switch {
+	case status >= 500:
+		return 4
+	case status == http.StatusUnsupportedMediaType:
+		return 3
+	case status == http.StatusConflict:
+		return 2
+	case status == 422:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// aggregateErrors orders errors by decreasing severity, the first error of an
+// ErrorList determines the status of the response document
+func aggregateErrors(errs jsh.ErrorList) jsh.ErrorList {
+	sort.Stable(bySeverity(errs))
+	return errs
+}
+
+// bySeverity sorts errors from most to least severe
+type bySeverity jsh.ErrorList
+
+func (b bySeverity) Len() int {
+	return len(b)
+}
+
+func (b bySeverity) Less(i, j int) bool {
+	return errorSeverity(b[i].Status) > errorSeverity(b[j].Status)
+}
+
+func (b bySeverity) Swap(i, j int) {
+	b[i], b[j] = b[j], b[i]
+}
+
+// toErrorList converts any jsh.ErrorType into an ErrorList
+func toErrorList(err jsh.ErrorType) jsh.ErrorList {
+	switch typed := err.(type) {
+	case *jsh.Error:
+		return jsh.ErrorList{typed}
+	case jsh.ErrorList:
+		return typed
+	case *StorageTimeoutError:
+		return jsh.ErrorList{typed.jshError()}
+	case store.RetryableError:
+		return jsh.ErrorList{typed.JSONAPIError()}
+	default:
+		return jsh.ErrorList{jsh.ISE(err.Error())}
+	}
+}
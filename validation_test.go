@@ -0,0 +1,79 @@
+package jshapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/derekdowling/go-json-spec-handler"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+func TestWriteValidation(t *testing.T) {
+
+	Convey("Write Validation Tests", t, func() {
+
+		resource := NewMockResource(testResourceType, 1, testObjAttrs)
+		resource.StrictMemberNames(0)
+		resource.AddValidator(func(ctx context.Context, object *jsh.Object) jsh.ErrorType {
+			if strings.Contains(string(object.Attributes), "invalid") {
+				return jsh.InputError("foo is invalid", "foo")
+			}
+			return nil
+		})
+
+		api := New("")
+		api.Add(resource)
+
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		url := server.URL + "/" + testResourceType + "/1"
+
+		Convey("should aggregate independent errors", func() {
+			body := `{"data": {"type": "foos", "id": "2", "attributes": {"foo": "invalid", "-bad": 1}}}`
+			resp, content := negotiationRequest("PATCH", url, jsh.ContentType, "", body)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+			So(content, ShouldContainSubstring, `"pointer": "/data/type"`)
+			So(content, ShouldContainSubstring, `"pointer": "/data/id"`)
+			So(content, ShouldContainSubstring, `"pointer": "/data/attributes/-bad"`)
+			So(content, ShouldContainSubstring, `"pointer": "/data/attributes/foo"`)
+		})
+
+		Convey("should rank media type errors highest", func() {
+			body := `{"data": {"type": "foos", "id": "1", "attributes": {"foo": "bar"}}}`
+			resp, content := negotiationRequest("PATCH", url, jsh.ContentType+`; ext="https://example.com/unknown"`, "", body)
+
+			So(resp.StatusCode, ShouldEqual, http.StatusUnsupportedMediaType)
+			So(content, ShouldContainSubstring, `"pointer": "/data/type"`)
+		})
+
+		Convey("should still short-circuit unparseable bodies", func() {
+			resp, _ := negotiationRequest("PATCH", url, jsh.ContentType+`; ext="https://example.com/unknown"`, "", "{")
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("should pass valid objects through", func() {
+			body := `{"data": {"type": "bars", "id": "1", "attributes": {"foo": "bar"}}}`
+			resp, _ := negotiationRequest("PATCH", url, jsh.ContentType, "", body)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("->aggregateErrors()", func() {
+			errs := aggregateErrors(jsh.ErrorList{
+				{Status: http.StatusBadRequest},
+				{Status: 422},
+				{Status: http.StatusUnsupportedMediaType},
+				{Status: http.StatusConflict},
+			})
+
+			So(errs.StatusCode(), ShouldEqual, http.StatusUnsupportedMediaType)
+			So(errs[1].Status, ShouldEqual, http.StatusConflict)
+			So(errs[2].Status, ShouldEqual, 422)
+			So(errs[3].Status, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}